@@ -34,11 +34,17 @@
 //	+--------------------------------------------------+
 //
 // The plugin runs as a gRPC server and is managed by piped.
-// It implements the DeploymentPlugin interface to handle deployment stages.
+// It implements the DeploymentPlugin interface to handle deployment stages,
+// and the PlanPreviewPlugin and LivestatePlugin interfaces.
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
+	"net/http"
+	"os"
+	"time"
 
 	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
 
@@ -46,15 +52,46 @@ import (
 	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/plugin"
 )
 
+// healthAddressEnv is the environment variable setting the address of the
+// HTTP health endpoints (e.g. ":7002"). They are disabled if it is unset.
+const healthAddressEnv = "CLOUDRUN_PLUGIN_HEALTH_ADDRESS"
+
+// configFileEnv is the environment variable setting a file the plugin config
+// and deploy targets are reloaded from, on SIGHUP or when the file changes.
+const configFileEnv = "CLOUDRUN_PLUGIN_CONFIG_FILE"
+
 func main() {
+	// Verify the deploy targets instead of serving, e.g.
+	// cloudrun-plugin selftest -config plugin-config.yaml
+	if len(os.Args) > 1 && os.Args[1] == "selftest" {
+		os.Exit(selfTest(os.Args[2:]))
+	}
+
+	// Serve the health endpoints next to the plugin's gRPC server
+	if addr := os.Getenv(healthAddressEnv); addr != "" {
+		go func() {
+			if err := http.ListenAndServe(addr, plugin.NewHealthHandler()); err != nil {
+				log.Printf("Health endpoints stopped: %v", err)
+			}
+		}()
+	}
+
 	// Create the Cloud Run plugin instance
 	cloudrunPlugin := plugin.NewCloudRunPlugin()
 
+	// Reload the plugin config without restarting the process
+	if path := os.Getenv(configFileEnv); path != "" {
+		if err := cloudrunPlugin.WatchConfigFile(context.Background(), path, plugin.DefaultConfigPollInterval); err != nil {
+			log.Fatalf("Failed to load plugin config file: %v", err)
+		}
+	}
+
 	// Create the plugin using the SDK
 	// Parameters:
 	//   - "cloudrun": Plugin name (must match piped config)
 	//   - WithDeploymentPlugin: Registers this as a deployment plugin
 	//   - WithPlanPreviewPlugin: Registers plan preview/drift detection capability
+	//   - WithLivestatePlugin: Registers live state reporting
 	p, err := sdk.NewPlugin(
 		"cloudrun",
 		sdk.WithDeploymentPlugin[
@@ -67,6 +104,11 @@ func main() {
 			config.DeployTargetConfig,
 			config.ApplicationConfig,
 		](cloudrunPlugin),
+		sdk.WithLivestatePlugin[
+			config.PluginConfig,
+			config.DeployTargetConfig,
+			config.ApplicationConfig,
+		](cloudrunPlugin),
 	)
 	if err != nil {
 		log.Fatalf("Failed to create plugin: %v", err)
@@ -78,3 +120,28 @@ func main() {
 		log.Fatalf("Failed to run plugin: %v", err)
 	}
 }
+
+// selfTest runs the self-test against the deploy targets of the plugin config
+// file and returns the exit code: 0 if all are ready, 1 otherwise.
+func selfTest(args []string) int {
+	flags := flag.NewFlagSet("selftest", flag.ExitOnError)
+	path := flags.String("config", os.Getenv(configFileEnv), "plugin config file with the deploy targets to test")
+	timeout := flags.Duration("timeout", 2*time.Minute, "timeout of the self-test")
+	flags.Parse(args)
+	if *path == "" {
+		log.Printf("The plugin config file is required: set -config or %s", configFileEnv)
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	ready, err := plugin.RunSelfTest(ctx, *path, os.Stdout)
+	if err != nil {
+		log.Printf("Self-test failed: %v", err)
+		return 1
+	}
+	if !ready {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,208 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	monitoring "google.golang.org/api/monitoring/v3"
+	"google.golang.org/api/option"
+)
+
+// Aggregation is how the samples of a metric are combined into one value.
+type Aggregation string
+
+const (
+	AggregationMean Aggregation = "mean"
+	AggregationMax  Aggregation = "max"
+	AggregationMin  Aggregation = "min"
+	AggregationSum  Aggregation = "sum"
+
+	// Percentiles apply to distribution metrics such as
+	// run.googleapis.com/request_latencies.
+	AggregationP50 Aggregation = "p50"
+	AggregationP95 Aggregation = "p95"
+	AggregationP99 Aggregation = "p99"
+)
+
+// monitoringAggregations maps aggregations to the Cloud Monitoring per-series
+// aligner and cross-series reducer.
+var monitoringAggregations = map[Aggregation][2]string{
+	AggregationMean: {"ALIGN_MEAN", "REDUCE_MEAN"},
+	AggregationMax:  {"ALIGN_MAX", "REDUCE_MAX"},
+	AggregationMin:  {"ALIGN_MIN", "REDUCE_MIN"},
+	AggregationSum:  {"ALIGN_SUM", "REDUCE_SUM"},
+	AggregationP50:  {"ALIGN_DELTA", "REDUCE_PERCENTILE_50"},
+	AggregationP95:  {"ALIGN_DELTA", "REDUCE_PERCENTILE_95"},
+	AggregationP99:  {"ALIGN_DELTA", "REDUCE_PERCENTILE_99"},
+}
+
+// Operator compares a metric value with a threshold.
+type Operator string
+
+const (
+	OperatorLess         Operator = "<"
+	OperatorLessEqual    Operator = "<="
+	OperatorGreater      Operator = ">"
+	OperatorGreaterEqual Operator = ">="
+	OperatorEqual        Operator = "=="
+	OperatorNotEqual     Operator = "!="
+)
+
+// MetricQuery is a Cloud Monitoring metric of the candidate revision and the
+// condition its value must meet.
+type MetricQuery struct {
+	// Name identifies the query in logs.
+	Name string
+
+	// Metric is the metric type, e.g. "run.googleapis.com/request_latencies".
+	Metric string
+
+	// Filter is an additional Cloud Monitoring filter, e.g.
+	// `metric.labels.response_code_class="5xx"`.
+	Filter string
+
+	// Aggregation combines the samples of the alignment period.
+	Aggregation Aggregation
+
+	// AlignmentPeriod is the window the value is computed over, ending now.
+	AlignmentPeriod time.Duration
+
+	// Operator and Threshold define the condition the value must meet,
+	// e.g. "<" 500.
+	Operator  Operator
+	Threshold float64
+}
+
+// Validate checks the aggregation, operator, and alignment period of the query.
+func (q MetricQuery) Validate() error {
+	if q.Metric == "" {
+		return fmt.Errorf("query %s: metric is required", q.Name)
+	}
+	if _, ok := monitoringAggregations[q.Aggregation]; !ok {
+		return fmt.Errorf("query %s: unsupported aggregation %q (supported: mean, max, min, sum, p50, p95, p99)", q.Name, q.Aggregation)
+	}
+	switch q.Operator {
+	case OperatorLess, OperatorLessEqual, OperatorGreater, OperatorGreaterEqual, OperatorEqual, OperatorNotEqual:
+	default:
+		return fmt.Errorf("query %s: unsupported operator %q (supported: <, <=, >, >=, ==, !=)", q.Name, q.Operator)
+	}
+	// Cloud Monitoring aligns on whole seconds, with a minimum of 60s
+	if q.AlignmentPeriod < time.Minute || q.AlignmentPeriod%time.Second != 0 {
+		return fmt.Errorf("query %s: alignment period must be whole seconds of at least 1m, got %s", q.Name, q.AlignmentPeriod)
+	}
+	return nil
+}
+
+// Evaluate reports whether the value meets the condition of the query.
+func (q MetricQuery) Evaluate(value float64) bool {
+	switch q.Operator {
+	case OperatorLess:
+		return value < q.Threshold
+	case OperatorLessEqual:
+		return value <= q.Threshold
+	case OperatorGreater:
+		return value > q.Threshold
+	case OperatorGreaterEqual:
+		return value >= q.Threshold
+	case OperatorEqual:
+		return value == q.Threshold
+	case OperatorNotEqual:
+		return value != q.Threshold
+	default:
+		return false
+	}
+}
+
+// MetricScope is the revision a metric query is read for.
+type MetricScope struct {
+	Project  string
+	Region   string
+	Service  string
+	Revision string
+}
+
+// MetricReader reads the value of metric queries.
+type MetricReader interface {
+	// ReadMetric returns the value of the query over the alignment period
+	// ending at end. It returns false if there is no data for the period.
+	ReadMetric(ctx context.Context, scope MetricScope, q MetricQuery, end time.Time) (float64, bool, error)
+}
+
+// monitoringMetricReader reads metrics from Cloud Monitoring.
+type monitoringMetricReader struct {
+	service *monitoring.Service
+}
+
+// NewMetricReader creates a MetricReader backed by Cloud Monitoring.
+//
+// If credentialsFile is empty, Application Default Credentials will be used.
+// The credentials need the monitoring.timeSeries.list permission
+// (e.g. roles/monitoring.viewer).
+func NewMetricReader(ctx context.Context, credentialsFile string) (MetricReader, error) {
+	var opts []option.ClientOption
+	if credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	}
+
+	service, err := monitoring.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create monitoring client: %w", err)
+	}
+
+	return &monitoringMetricReader{service: service}, nil
+}
+
+// ReadMetric aligns each time series of the revision over the alignment
+// period, and reduces them into a single value.
+func (r *monitoringMetricReader) ReadMetric(ctx context.Context, scope MetricScope, q MetricQuery, end time.Time) (float64, bool, error) {
+	filter := fmt.Sprintf(
+		`metric.type=%q AND resource.labels.location=%q AND resource.labels.service_name=%q AND resource.labels.revision_name=%q`,
+		q.Metric, scope.Region, scope.Service, ShortRevisionName(scope.Revision),
+	)
+	if q.Filter != "" {
+		filter += " AND (" + q.Filter + ")"
+	}
+	aggregation := monitoringAggregations[q.Aggregation]
+
+	resp, err := r.service.Projects.TimeSeries.List("projects/" + scope.Project).
+		Filter(filter).
+		IntervalStartTime(end.Add(-q.AlignmentPeriod).Format(time.RFC3339)).
+		IntervalEndTime(end.Format(time.RFC3339)).
+		AggregationAlignmentPeriod(fmt.Sprintf("%ds", int64(q.AlignmentPeriod.Seconds()))).
+		AggregationPerSeriesAligner(aggregation[0]).
+		AggregationCrossSeriesReducer(aggregation[1]).
+		Context(ctx).
+		Do()
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to query %s: %w", q.Metric, err)
+	}
+
+	// Points are returned newest first
+	for _, ts := range resp.TimeSeries {
+		for _, p := range ts.Points {
+			switch {
+			case p.Value == nil:
+			case p.Value.DoubleValue != nil:
+				return *p.Value.DoubleValue, true, nil
+			case p.Value.Int64Value != nil:
+				return float64(*p.Value.Int64Value), true, nil
+			}
+		}
+	}
+	return 0, false, nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMetricQuery_Evaluate(t *testing.T) {
+	tests := []struct {
+		operator Operator
+		value    float64
+		want     bool
+	}{
+		{operator: OperatorLess, value: 499, want: true},
+		{operator: OperatorLess, value: 500, want: false},
+		{operator: OperatorLessEqual, value: 500, want: true},
+		{operator: OperatorGreater, value: 500, want: false},
+		{operator: OperatorGreaterEqual, value: 500, want: true},
+		{operator: OperatorEqual, value: 500, want: true},
+		{operator: OperatorNotEqual, value: 500, want: false},
+	}
+
+	for _, tt := range tests {
+		q := MetricQuery{Operator: tt.operator, Threshold: 500}
+		if got := q.Evaluate(tt.value); got != tt.want {
+			t.Errorf("%g %s 500: expected %v, got %v", tt.value, tt.operator, tt.want, got)
+		}
+	}
+}
+
+func TestMetricQuery_Validate(t *testing.T) {
+	valid := MetricQuery{
+		Name:            "latency",
+		Metric:          "run.googleapis.com/request_latencies",
+		Aggregation:     AggregationP95,
+		AlignmentPeriod: 5 * time.Minute,
+		Operator:        OperatorLess,
+	}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	tests := map[string]func(q *MetricQuery){
+		"no metric":            func(q *MetricQuery) { q.Metric = "" },
+		"unknown aggregation":  func(q *MetricQuery) { q.Aggregation = "median" },
+		"unknown operator":     func(q *MetricQuery) { q.Operator = "=~" },
+		"short alignment":      func(q *MetricQuery) { q.AlignmentPeriod = 30 * time.Second },
+		"fractional alignment": func(q *MetricQuery) { q.AlignmentPeriod = 90*time.Second + time.Millisecond },
+	}
+	for name, modify := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := valid
+			modify(&q)
+			if err := q.Validate(); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
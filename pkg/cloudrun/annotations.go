@@ -0,0 +1,70 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun/knative"
+)
+
+// AnnotateRevision sets the annotations on the revision template of the
+// service, replacing values set in the manifest. Annotations with an empty
+// value are skipped.
+func AnnotateRevision(service *runpb.Service, annotations map[string]string) error {
+	if service.Template == nil {
+		return fmt.Errorf("service has no revision template")
+	}
+	for key, value := range annotations {
+		if value == "" {
+			continue
+		}
+		if err := validateAnnotationKey(key); err != nil {
+			return err
+		}
+		if service.Template.Annotations == nil {
+			service.Template.Annotations = make(map[string]string, len(annotations))
+		}
+		service.Template.Annotations[key] = value
+	}
+	return nil
+}
+
+// validateAnnotationKey checks that the key is a Kubernetes-style annotation
+// key outside the namespaces reserved by Cloud Run.
+func validateAnnotationKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("annotation key is empty")
+	}
+	for _, prefix := range knative.ReservedPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return fmt.Errorf("annotation %s uses the namespace %s reserved by Cloud Run", key, strings.TrimSuffix(prefix, "/"))
+		}
+	}
+	name := key
+	if i := strings.LastIndex(key, "/"); i >= 0 {
+		name = key[i+1:]
+		if i == 0 || len(key[:i]) > 253 {
+			return fmt.Errorf("annotation %s has an invalid prefix", key)
+		}
+	}
+	if name == "" || len(name) > 63 {
+		return fmt.Errorf("annotation %s must have a name of 1 to 63 characters", key)
+	}
+	return nil
+}
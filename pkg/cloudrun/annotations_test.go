@@ -0,0 +1,50 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"testing"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+)
+
+func TestAnnotateRevision(t *testing.T) {
+	service := &runpb.Service{Template: &runpb.RevisionTemplate{
+		Annotations: map[string]string{"pipecd.dev/commit": "old", "team": "payments"},
+	}}
+	err := AnnotateRevision(service, map[string]string{
+		"pipecd.dev/commit":          "abc123",
+		"pipecd.dev/commit-author":   "Jane Doe",
+		"pipecd.dev/application-url": "",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{"pipecd.dev/commit": "abc123", "pipecd.dev/commit-author": "Jane Doe", "team": "payments"}
+	if len(service.Template.Annotations) != len(want) {
+		t.Fatalf("expected annotations %v, got %v", want, service.Template.Annotations)
+	}
+	for k, v := range want {
+		if service.Template.Annotations[k] != v {
+			t.Errorf("expected %s=%q, got %q", k, v, service.Template.Annotations[k])
+		}
+	}
+
+	for _, key := range []string{"run.googleapis.com/commit", "/commit", "example.com/"} {
+		if err := AnnotateRevision(service, map[string]string{key: "abc123"}); err == nil {
+			t.Errorf("expected annotation %q to be rejected", key)
+		}
+	}
+}
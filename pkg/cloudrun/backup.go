@@ -0,0 +1,213 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"time"
+
+	"cloud.google.com/go/iam/apiv1/iampb"
+	"cloud.google.com/go/run/apiv2/runpb"
+	"google.golang.org/api/option"
+	runv1 "google.golang.org/api/run/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// backupTimeFormat names backup objects by creation time, so their lexical
+// order is their chronological order.
+const backupTimeFormat = "20060102T150405Z"
+
+// ServiceBackup is a snapshot of the configuration of a service, for
+// disaster recovery: its spec, IAM policy, and domain mappings.
+type ServiceBackup struct {
+	Project   string    `json:"project"`
+	Region    string    `json:"region"`
+	Service   string    `json:"service"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	// Spec is the service without output-only fields (protojson), see
+	// NormalizeService.
+	Spec json.RawMessage `json:"spec"`
+
+	// IAMPolicy is the IAM policy of the service (protojson).
+	IAMPolicy json.RawMessage `json:"iamPolicy,omitempty"`
+
+	// DomainMappings are the domains mapped to the service.
+	DomainMappings []DomainMapping `json:"domainMappings,omitempty"`
+}
+
+// DomainMapping is a domain mapped to a service.
+type DomainMapping struct {
+	Domain string `json:"domain"`
+
+	// CertificateMode is the certificate provisioning mode, e.g. "AUTOMATIC".
+	CertificateMode string `json:"certificateMode,omitempty"`
+}
+
+// NewServiceBackup returns the backup of the live service, its IAM policy,
+// and its domain mappings.
+func NewServiceBackup(project, region string, svc *runpb.Service, policy *iampb.Policy, mappings []DomainMapping, now time.Time) (*ServiceBackup, error) {
+	spec, err := protojson.Marshal(NormalizeService(svc))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode service: %w", err)
+	}
+	backup := &ServiceBackup{
+		Project:        project,
+		Region:         region,
+		Service:        GetServiceName(svc.Name),
+		CreatedAt:      now.UTC(),
+		Spec:           spec,
+		DomainMappings: mappings,
+	}
+	if policy != nil {
+		if backup.IAMPolicy, err = protojson.Marshal(policy); err != nil {
+			return nil, fmt.Errorf("failed to encode IAM policy: %w", err)
+		}
+	}
+	return backup, nil
+}
+
+// ParseServiceBackup decodes a backup written by NewServiceBackup.
+func ParseServiceBackup(data []byte) (*ServiceBackup, error) {
+	var backup ServiceBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return nil, fmt.Errorf("invalid backup: %w", err)
+	}
+	if len(backup.Spec) == 0 {
+		return nil, fmt.Errorf("invalid backup: spec is missing")
+	}
+	return &backup, nil
+}
+
+// ServiceSpec returns the backed up service, to be restored with
+// CreateOrUpdateService.
+func (b *ServiceBackup) ServiceSpec() (*runpb.Service, error) {
+	var svc runpb.Service
+	if err := protojson.Unmarshal(b.Spec, &svc); err != nil {
+		return nil, fmt.Errorf("invalid backup spec: %w", err)
+	}
+	return &svc, nil
+}
+
+// Policy returns the backed up IAM policy, or nil if none was backed up.
+// The etag is cleared so the policy replaces the live one.
+func (b *ServiceBackup) Policy() (*iampb.Policy, error) {
+	if len(b.IAMPolicy) == 0 {
+		return nil, nil
+	}
+	var policy iampb.Policy
+	if err := protojson.Unmarshal(b.IAMPolicy, &policy); err != nil {
+		return nil, fmt.Errorf("invalid backup IAM policy: %w", err)
+	}
+	policy.Etag = nil
+	return &policy, nil
+}
+
+// BackupObjectPrefix returns the prefix of the backup objects of a service,
+// e.g. "cloudrun-backups/my-project/us-central1/my-service/".
+func BackupObjectPrefix(prefix, project, region, service string) string {
+	return path.Join(prefix, project, region, service) + "/"
+}
+
+// BackupObjectName returns the name of the backup object of a service
+// created at t, e.g.
+// "cloudrun-backups/my-project/us-central1/my-service/20260102T150405Z.json".
+func BackupObjectName(prefix, project, region, service string, t time.Time) string {
+	return BackupObjectPrefix(prefix, project, region, service) + t.UTC().Format(backupTimeFormat) + ".json"
+}
+
+// LatestBackupObject returns the most recent of the backup objects listed
+// under a BackupObjectPrefix, or false if there is none.
+func LatestBackupObject(objects []string) (string, bool) {
+	var backups []string
+	for _, o := range objects {
+		if path.Ext(o) == ".json" {
+			backups = append(backups, o)
+		}
+	}
+	if len(backups) == 0 {
+		return "", false
+	}
+	return slices.Max(backups), true
+}
+
+// ServiceDomainMappings returns the domain mappings of the service, sorted
+// by domain.
+//
+// If credentialsFile is empty, Application Default Credentials will be used.
+func ServiceDomainMappings(ctx context.Context, credentialsFile, project, region, service string) ([]DomainMapping, error) {
+	var gcpOpts []option.ClientOption
+	if credentialsFile != "" {
+		gcpOpts = append(gcpOpts, option.WithCredentialsFile(credentialsFile))
+	}
+
+	_, mappings, err := listDomainMappings(ctx, gcpOpts, project, region, service)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]DomainMapping, 0, len(mappings))
+	for _, dm := range mappings {
+		result = append(result, DomainMapping{Domain: dm.Metadata.Name, CertificateMode: dm.Spec.CertificateMode})
+	}
+	slices.SortFunc(result, func(a, b DomainMapping) int {
+		return cmp.Compare(a.Domain, b.Domain)
+	})
+	return result, nil
+}
+
+// CreateDomainMappings maps the domains to the service, skipping those
+// already mapped to it. It returns the created domains.
+//
+// If credentialsFile is empty, Application Default Credentials will be used.
+// The credentials need the run.domainmappings.create permission, and the
+// domains must be verified for the project.
+func CreateDomainMappings(ctx context.Context, credentialsFile, project, region, service string, mappings []DomainMapping) ([]string, error) {
+	var gcpOpts []option.ClientOption
+	if credentialsFile != "" {
+		gcpOpts = append(gcpOpts, option.WithCredentialsFile(credentialsFile))
+	}
+
+	svc, existing, err := listDomainMappings(ctx, gcpOpts, project, region, service)
+	if err != nil {
+		return nil, err
+	}
+	mapped := make(map[string]bool, len(existing))
+	for _, dm := range existing {
+		mapped[dm.Metadata.Name] = true
+	}
+
+	var created []string
+	for _, m := range mappings {
+		if mapped[m.Domain] {
+			continue
+		}
+		dm := &runv1.DomainMapping{
+			ApiVersion: "domains.cloudrun.com/v1",
+			Kind:       "DomainMapping",
+			Metadata:   &runv1.ObjectMeta{Name: m.Domain, Namespace: project},
+			Spec:       &runv1.DomainMappingSpec{RouteName: service, CertificateMode: m.CertificateMode},
+		}
+		if _, err := svc.Namespaces.Domainmappings.Create("namespaces/"+project, dm).Context(ctx).Do(); err != nil {
+			return created, fmt.Errorf("failed to map domain %s: %w", m.Domain, err)
+		}
+		created = append(created, m.Domain)
+	}
+	return created, nil
+}
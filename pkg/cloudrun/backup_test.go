@@ -0,0 +1,108 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/iam/apiv1/iampb"
+	"cloud.google.com/go/run/apiv2/runpb"
+)
+
+func TestServiceBackup(t *testing.T) {
+	svc := &runpb.Service{
+		Name:                  "projects/p/locations/r/services/my-service",
+		Uid:                   "1234",
+		LatestCreatedRevision: "projects/p/locations/r/services/my-service/revisions/my-service-00002",
+		Template: &runpb.RevisionTemplate{
+			Containers: []*runpb.Container{{Image: "gcr.io/p/app:v2"}},
+		},
+	}
+	policy := &iampb.Policy{
+		Etag:     []byte("etag"),
+		Bindings: []*iampb.Binding{{Role: "roles/run.invoker", Members: []string{"allUsers"}}},
+	}
+	mappings := []DomainMapping{{Domain: "api.example.com", CertificateMode: "AUTOMATIC"}}
+	now := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	backup, err := NewServiceBackup("p", "r", svc, policy, mappings, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseServiceBackup(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Service != "my-service" || !parsed.CreatedAt.Equal(now) {
+		t.Errorf("unexpected backup %+v", parsed)
+	}
+	if len(parsed.DomainMappings) != 1 || parsed.DomainMappings[0] != mappings[0] {
+		t.Errorf("unexpected domain mappings %+v", parsed.DomainMappings)
+	}
+
+	spec, err := parsed.ServiceSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Uid != "" || spec.LatestCreatedRevision != "" {
+		t.Errorf("expected output-only fields to be dropped, got %v", spec)
+	}
+	if got := spec.GetTemplate().GetContainers()[0].GetImage(); got != "gcr.io/p/app:v2" {
+		t.Errorf("expected image gcr.io/p/app:v2, got %q", got)
+	}
+
+	restored, err := parsed.Policy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Etag != nil {
+		t.Errorf("expected the etag to be cleared, got %q", restored.Etag)
+	}
+	if len(restored.Bindings) != 1 || restored.Bindings[0].Role != "roles/run.invoker" {
+		t.Errorf("unexpected bindings %v", restored.Bindings)
+	}
+}
+
+func TestParseServiceBackup_Invalid(t *testing.T) {
+	for _, data := range []string{`not json`, `{"service": "my-service"}`} {
+		if _, err := ParseServiceBackup([]byte(data)); err == nil {
+			t.Errorf("expected an error for %s", data)
+		}
+	}
+}
+
+func TestLatestBackupObject(t *testing.T) {
+	t1 := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	t2 := t1.Add(24 * time.Hour)
+	name1 := BackupObjectName("cloudrun-backups", "p", "r", "svc", t1)
+	name2 := BackupObjectName("cloudrun-backups", "p", "r", "svc", t2)
+	if name1 != "cloudrun-backups/p/r/svc/20260102T150405Z.json" {
+		t.Errorf("unexpected object name %q", name1)
+	}
+
+	latest, ok := LatestBackupObject([]string{name2, "cloudrun-backups/p/r/svc/notes.txt", name1})
+	if !ok || latest != name2 {
+		t.Errorf("expected %q, got %q", name2, latest)
+	}
+	if _, ok := LatestBackupObject(nil); ok {
+		t.Errorf("expected no backup")
+	}
+}
@@ -20,12 +20,16 @@ package cloudrun
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"cloud.google.com/go/iam/apiv1/iampb"
 	run "cloud.google.com/go/run/apiv2"
 	"cloud.google.com/go/run/apiv2/runpb"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
+	"google.golang.org/grpc"
 	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
@@ -43,7 +47,8 @@ type Client interface {
 	// Updating a service creates a new revision automatically.
 	CreateOrUpdateService(ctx context.Context, service *runpb.Service) (*runpb.Service, error)
 
-	// UpdateTraffic updates traffic allocation for a service.
+	// UpdateTraffic updates traffic allocation for a service. It returns once
+	// the traffic status of the service matches the allocation.
 	// Parameters:
 	//   - project: GCP project ID
 	//   - region: GCP region
@@ -51,26 +56,92 @@ type Client interface {
 	//   - traffic: List of traffic targets
 	UpdateTraffic(ctx context.Context, project, region, service string, traffic []*runpb.TrafficTarget) error
 
+	// ValidateService validates the creation of a service without creating
+	// it, checking the request and the permission to create services.
+	ValidateService(ctx context.Context, service *runpb.Service) error
+
+	// ListServices lists all services in a region.
+	ListServices(ctx context.Context, project, region string) ([]*runpb.Service, error)
+
+	// CheckAccess makes a lightweight call (listing at most one service) to
+	// check the credentials can access the Admin API in the region.
+	CheckAccess(ctx context.Context, project, region string) error
+
 	// ListRevisions lists all revisions of a service.
 	ListRevisions(ctx context.Context, project, region, service string) ([]*runpb.Revision, error)
 
+	// ListRevisionPages lists the revisions of a service page by page, as
+	// the Admin API returns them (newest first), until fn returns false.
+	ListRevisionPages(ctx context.Context, project, region, service string, fn func(page []*runpb.Revision) bool) error
+
 	// GetRevision gets a specific revision.
 	GetRevision(ctx context.Context, project, region, service, revision string) (*runpb.Revision, error)
 
 	// DeleteRevision deletes a specific revision.
 	DeleteRevision(ctx context.Context, project, region, service, revision string) error
 
-	// WaitForServiceReady waits for a service to be ready.
+	// DeleteService deletes a service and all of its revisions.
+	DeleteService(ctx context.Context, project, region, service string) error
+
+	// GetIAMPolicy gets the IAM policy of a service, including conditional bindings.
+	GetIAMPolicy(ctx context.Context, project, region, service string) (*iampb.Policy, error)
+
+	// SetIAMPolicy sets the IAM policy of a service.
+	// The policy etag guards against concurrent changes.
+	SetIAMPolicy(ctx context.Context, project, region, service string, policy *iampb.Policy) (*iampb.Policy, error)
+
+	// WaitForServiceReady waits for a service to be ready. It returns a
+	// *QuotaError if scaling was blocked by a quota or limit.
 	WaitForServiceReady(ctx context.Context, project, region, service string) error
 
+	// GetJob retrieves a Cloud Run job by name.
+	GetJob(ctx context.Context, project, region, job string) (*runpb.Job, error)
+
+	// CreateOrUpdateJob creates a new job or updates an existing one.
+	CreateOrUpdateJob(ctx context.Context, job *runpb.Job) (*runpb.Job, error)
+
+	// RunJob starts an execution of a job.
+	RunJob(ctx context.Context, project, region, job string) (*runpb.Execution, error)
+
+	// WaitForExecution waits for an execution (full resource name) to finish.
+	WaitForExecution(ctx context.Context, name string) (*runpb.Execution, error)
+
+	// ListExecutions lists the most recent executions of a job, newest
+	// first, up to limit.
+	ListExecutions(ctx context.Context, project, region, job string, limit int) ([]*runpb.Execution, error)
+
 	// Close closes the client connection.
 	Close() error
 }
 
 // client implements the Client interface using the official Cloud Run Go client.
 type client struct {
-	servicesClient  *run.ServicesClient
-	revisionsClient *run.RevisionsClient
+	servicesClient   *run.ServicesClient
+	revisionsClient  *run.RevisionsClient
+	jobsClient       *run.JobsClient
+	executionsClient *run.ExecutionsClient
+	timeouts         Timeouts
+	rateLimit        RateLimit
+	callObserver     CallObserver
+}
+
+// ClientOption configures optional behavior of the Cloud Run client.
+type ClientOption func(*clientOptions)
+
+// clientOptions holds the options applied by ClientOption.
+type clientOptions struct {
+	timeouts     Timeouts
+	rateLimit    RateLimit
+	callObserver CallObserver
+	endpoint     string
+}
+
+// WithTimeouts sets the per-call timeouts used by the client.
+// Zero fields disable the timeout for that kind of call.
+func WithTimeouts(timeouts Timeouts) ClientOption {
+	return func(o *clientOptions) {
+		o.timeouts = timeouts
+	}
 }
 
 // NewClient creates a new Cloud Run API client.
@@ -78,6 +149,7 @@ type client struct {
 // Parameters:
 //   - ctx: Context for the client creation
 //   - credentialsFile: Path to GCP service account key file (optional)
+//   - opts: Optional client options (e.g. WithTimeouts, WithEndpoint)
 //
 // If credentialsFile is empty, Application Default Credentials will be used.
 // This is useful for local development with `gcloud auth application-default login`.
@@ -89,59 +161,126 @@ type client struct {
 //
 //	// With Application Default Credentials
 //	client, err := cloudrun.NewClient(ctx, "")
-func NewClient(ctx context.Context, credentialsFile string) (Client, error) {
-	var opts []option.ClientOption
+//
+// By default every call is bounded by DefaultTimeouts so a wedged Admin API
+// call can't hang a stage until the pipeline timeout, and calls are limited
+// per project by DefaultRateLimit with backoff on RESOURCE_EXHAUSTED.
+func NewClient(ctx context.Context, credentialsFile string, opts ...ClientOption) (Client, error) {
+	options := clientOptions{
+		timeouts:  DefaultTimeouts(),
+		rateLimit: DefaultRateLimit(),
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	var gcpOpts []option.ClientOption
 	if credentialsFile != "" {
-		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+		gcpOpts = append(gcpOpts, option.WithCredentialsFile(credentialsFile))
+	}
+	if options.endpoint != "" {
+		gcpOpts = append(gcpOpts, option.WithEndpoint(options.endpoint))
 	}
+	// Log the payloads of calls made in a context with WithPayloadLog
+	gcpOpts = append(gcpOpts, option.WithGRPCDialOption(grpc.WithChainUnaryInterceptor(payloadLogInterceptor)))
 
 	// Create the services client for service operations
-	servicesClient, err := run.NewServicesClient(ctx, opts...)
+	servicesClient, err := run.NewServicesClient(ctx, gcpOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create services client: %w", err)
 	}
 
 	// Create the revisions client for revision operations
-	revisionsClient, err := run.NewRevisionsClient(ctx, opts...)
+	revisionsClient, err := run.NewRevisionsClient(ctx, gcpOpts...)
 	if err != nil {
 		servicesClient.Close()
 		return nil, fmt.Errorf("failed to create revisions client: %w", err)
 	}
 
+	// Create the jobs and executions clients for CloudRunJob applications
+	jobsClient, err := run.NewJobsClient(ctx, gcpOpts...)
+	if err != nil {
+		servicesClient.Close()
+		revisionsClient.Close()
+		return nil, fmt.Errorf("failed to create jobs client: %w", err)
+	}
+	executionsClient, err := run.NewExecutionsClient(ctx, gcpOpts...)
+	if err != nil {
+		servicesClient.Close()
+		revisionsClient.Close()
+		jobsClient.Close()
+		return nil, fmt.Errorf("failed to create executions client: %w", err)
+	}
+
 	return &client{
-		servicesClient:  servicesClient,
-		revisionsClient: revisionsClient,
+		servicesClient:   servicesClient,
+		revisionsClient:  revisionsClient,
+		jobsClient:       jobsClient,
+		executionsClient: executionsClient,
+		timeouts:         options.timeouts,
+		rateLimit:        options.rateLimit,
+		callObserver:     options.callObserver,
 	}, nil
 }
 
 // GetService retrieves a Cloud Run service by name.
 func (c *client) GetService(ctx context.Context, project, region, service string) (*runpb.Service, error) {
-	name := fmt.Sprintf("projects/%s/locations/%s/services/%s", project, region, service)
-	return c.servicesClient.GetService(ctx, &runpb.GetServiceRequest{
-		Name: name,
+	return c.getService(ctx, NewServiceName(project, region, service))
+}
+
+// getService fetches a service by its resource name, bounded by the get timeout.
+func (c *client) getService(ctx context.Context, name ResourceName) (*runpb.Service, error) {
+	callCtx, cancel := withCallTimeout(ctx, c.timeouts.Get)
+	defer cancel()
+
+	var svc *runpb.Service
+	err := c.throttle(callCtx, name.Project, func() error {
+		var err error
+		svc, err = c.servicesClient.GetService(callCtx, &runpb.GetServiceRequest{
+			Name: name.ServiceName(),
+		})
+		return err
 	})
+	return svc, wrapCallError(ctx, callCtx, "GetService", c.timeouts.Get, err)
 }
 
 // CreateOrUpdateService creates a new service or updates an existing one.
 // When updating, a new revision is automatically created.
 func (c *client) CreateOrUpdateService(ctx context.Context, service *runpb.Service) (*runpb.Service, error) {
+	name, err := ParseResourceName(service.Name)
+	if err != nil {
+		return nil, err
+	}
+	if name.Service == "" || name.Revision != "" {
+		return nil, fmt.Errorf("invalid service name %q", service.Name)
+	}
+
 	// Check if service exists
-	_, err := c.servicesClient.GetService(ctx, &runpb.GetServiceRequest{
-		Name: service.Name,
-	})
+	_, err = c.getService(ctx, name)
+
+	project := name.Project
 
 	if err != nil {
+		callCtx, cancel := withCallTimeout(ctx, c.timeouts.Update)
+		defer cancel()
+
 		// Service doesn't exist, create it
-		op, err := c.servicesClient.CreateService(ctx, &runpb.CreateServiceRequest{
-			Parent:    getParentFromServiceName(service.Name),
-			ServiceId: getServiceIDFromServiceName(service.Name),
-			Service:   service,
+		var op *run.CreateServiceOperation
+		err := c.throttle(callCtx, project, func() error {
+			var err error
+			op, err = c.servicesClient.CreateService(callCtx, &runpb.CreateServiceRequest{
+				Parent:    name.Parent(),
+				ServiceId: name.Service,
+				Service:   service,
+			})
+			return err
 		})
 		if err != nil {
-			return nil, fmt.Errorf("failed to create service: %w", err)
+			return nil, fmt.Errorf("failed to create service: %w", wrapCallError(ctx, callCtx, "CreateService", c.timeouts.Update, err))
 		}
 		// Wait for operation to complete
-		return op.Wait(ctx)
+		result, err := op.Wait(callCtx)
+		return result, wrapCallError(ctx, callCtx, "CreateService", c.timeouts.Update, err)
 	}
 
 	// Service exists, update it
@@ -152,111 +291,419 @@ func (c *client) CreateOrUpdateService(ctx context.Context, service *runpb.Servi
 			"traffic",
 		},
 	}
+	// Only manage service-level metadata when it is set, so metadata added
+	// outside of PipeCD is kept for services that don't define any
+	if len(service.Labels) > 0 {
+		updateMask.Paths = append(updateMask.Paths, "labels")
+	}
+	if len(service.Annotations) > 0 {
+		updateMask.Paths = append(updateMask.Paths, "annotations")
+	}
+	if service.Description != "" {
+		updateMask.Paths = append(updateMask.Paths, "description")
+	}
+
+	// Retry once an operation already in progress on the service finished
+	var result *runpb.Service
+	err = retryOperationInProgress(ctx, name.Service, c.timeouts.OperationInProgress, func() error {
+		var err error
+		result, err = c.updateService(ctx, service, updateMask)
+		return err
+	}, c.serviceReconciling(ctx, name))
+	return result, err
+}
+
+// updateService updates the fields of the service in the mask and waits for
+// the operation to complete.
+func (c *client) updateService(ctx context.Context, service *runpb.Service, updateMask *fieldmaskpb.FieldMask) (*runpb.Service, error) {
+	name, err := ParseResourceName(service.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	callCtx, cancel := withCallTimeout(ctx, c.timeouts.Update)
+	defer cancel()
 
-	op, err := c.servicesClient.UpdateService(ctx, &runpb.UpdateServiceRequest{
-		Service:    service,
-		UpdateMask: updateMask,
+	var op *run.UpdateServiceOperation
+	err = c.throttle(callCtx, name.Project, func() error {
+		var err error
+		op, err = c.servicesClient.UpdateService(callCtx, &runpb.UpdateServiceRequest{
+			Service:    service,
+			UpdateMask: updateMask,
+		})
+		return err
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to update service: %w", err)
+		return nil, fmt.Errorf("failed to update service: %w", wrapCallError(ctx, callCtx, "UpdateService", c.timeouts.Update, err))
 	}
 	// Wait for operation to complete
-	return op.Wait(ctx)
+	result, err := op.Wait(callCtx)
+	return result, wrapCallError(ctx, callCtx, "UpdateService", c.timeouts.Update, err)
 }
 
-// UpdateTraffic updates traffic allocation for a service.
+// UpdateTraffic updates traffic allocation for a service, and waits until
+// the service serves the requested split.
 func (c *client) UpdateTraffic(ctx context.Context, project, region, service string, traffic []*runpb.TrafficTarget) error {
-	name := fmt.Sprintf("projects/%s/locations/%s/services/%s", project, region, service)
+	name := NewServiceName(project, region, service)
+
+	// Retry once an operation already in progress on the service finished,
+	// re-reading the service it changed
+	err := retryOperationInProgress(ctx, service, c.timeouts.OperationInProgress, func() error {
+		// Get current service
+		svc, err := c.getService(ctx, name)
+		if err != nil {
+			return fmt.Errorf("failed to get service: %w", err)
+		}
+
+		// Update traffic configuration
+		svc.Traffic = traffic
+
+		callCtx, cancel := withCallTimeout(ctx, c.timeouts.Update)
+		defer cancel()
+
+		// Apply update
+		err = c.throttle(callCtx, project, func() error {
+			_, err := c.servicesClient.UpdateService(callCtx, &runpb.UpdateServiceRequest{
+				Service: svc,
+				UpdateMask: &fieldmaskpb.FieldMask{
+					Paths: []string{"traffic"},
+				},
+			})
+			return err
+		})
+
+		return wrapCallError(ctx, callCtx, "UpdateService", c.timeouts.Update, err)
+	}, c.serviceReconciling(ctx, name))
+	if err != nil {
+		return err
+	}
+
+	// The update is accepted before the traffic moves, so wait for it to
+	// actually converge
+	return c.waitForTraffic(ctx, name, traffic)
+}
+
+// waitForTraffic polls the traffic status of the service until it matches
+// the requested traffic, see TrafficConverged.
+func (c *client) waitForTraffic(ctx context.Context, name ResourceName, traffic []*runpb.TrafficTarget) error {
+	waitCtx, cancel := withCallTimeout(ctx, c.timeouts.TrafficConvergence)
+	defer cancel()
+	progress := startProgress(waitCtx, fmt.Sprintf("traffic of service %s to converge", name.Service), c.timeouts.TrafficConvergence)
+
+	var serving []*runpb.TrafficTargetStatus
+	for {
+		svc, err := c.getService(waitCtx, name)
+		if err != nil {
+			return wrapCallError(ctx, waitCtx, "traffic convergence", c.timeouts.TrafficConvergence, err)
+		}
+		serving = svc.TrafficStatuses
+		if TrafficConverged(serving, traffic) {
+			return nil
+		}
+		if cond := svc.TerminalCondition; cond != nil && cond.State == runpb.Condition_CONDITION_FAILED && svc.ObservedGeneration == svc.Generation {
+			return fmt.Errorf("traffic update failed: %s", cond.Message)
+		}
+		progress.report("serving %s, requested %s", formatTrafficAllocation(serving), formatTrafficAllocation(traffic))
+
+		select {
+		case <-waitCtx.Done():
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("traffic did not converge within %s: serving %s, requested %s", c.timeouts.TrafficConvergence, formatTrafficAllocation(serving), formatTrafficAllocation(traffic))
+		case <-time.After(trafficPollInterval):
+		}
+	}
+}
+
+// ListServices lists all services in a region.
+func (c *client) ListServices(ctx context.Context, project, region string) ([]*runpb.Service, error) {
+	parent := NewServiceName(project, region, "").LocationName()
 
-	// Get current service
-	svc, err := c.servicesClient.GetService(ctx, &runpb.GetServiceRequest{
-		Name: name,
+	callCtx, cancel := withCallTimeout(ctx, c.timeouts.List)
+	defer cancel()
+
+	var services []*runpb.Service
+	err := c.throttle(callCtx, project, func() error {
+		// Restart the listing from scratch on retries
+		services = nil
+		iter := c.servicesClient.ListServices(callCtx, &runpb.ListServicesRequest{
+			Parent: parent,
+		})
+		for {
+			svc, err := iter.Next()
+			if err != nil {
+				// Check if we've reached the end
+				if err.Error() == "iterator done" {
+					return nil
+				}
+				return err
+			}
+			services = append(services, svc)
+		}
 	})
 	if err != nil {
-		return fmt.Errorf("failed to get service: %w", err)
+		return nil, fmt.Errorf("failed to list services: %w", wrapCallError(ctx, callCtx, "ListServices", c.timeouts.List, err))
 	}
 
-	// Update traffic configuration
-	svc.Traffic = traffic
+	return services, nil
+}
 
-	// Apply update
-	_, err = c.servicesClient.UpdateService(ctx, &runpb.UpdateServiceRequest{
-		Service: svc,
-		UpdateMask: &fieldmaskpb.FieldMask{
-			Paths: []string{"traffic"},
-		},
+// ValidateService creates the service with validate only set.
+func (c *client) ValidateService(ctx context.Context, service *runpb.Service) error {
+	name, err := ParseResourceName(service.Name)
+	if err != nil {
+		return err
+	}
+	if name.Service == "" || name.Revision != "" {
+		return fmt.Errorf("invalid service name %q", service.Name)
+	}
+
+	callCtx, cancel := withCallTimeout(ctx, c.timeouts.Update)
+	defer cancel()
+
+	err = c.throttle(callCtx, name.Project, func() error {
+		_, err := c.servicesClient.CreateService(callCtx, &runpb.CreateServiceRequest{
+			Parent:       name.Parent(),
+			ServiceId:    name.Service,
+			Service:      service,
+			ValidateOnly: true,
+		})
+		return err
 	})
+	return wrapCallError(ctx, callCtx, "CreateService", c.timeouts.Update, err)
+}
+
+// CheckAccess lists at most one service in the region.
+func (c *client) CheckAccess(ctx context.Context, project, region string) error {
+	parent := NewServiceName(project, region, "").LocationName()
+
+	callCtx, cancel := withCallTimeout(ctx, c.timeouts.List)
+	defer cancel()
 
-	return err
+	err := c.throttle(callCtx, project, func() error {
+		iter := c.servicesClient.ListServices(callCtx, &runpb.ListServicesRequest{
+			Parent:   parent,
+			PageSize: 1,
+		})
+		_, err := iter.Next()
+		if err != nil && err.Error() == "iterator done" {
+			return nil
+		}
+		return err
+	})
+	return wrapCallError(ctx, callCtx, "ListServices", c.timeouts.List, err)
 }
 
 // ListRevisions lists all revisions of a service.
 func (c *client) ListRevisions(ctx context.Context, project, region, service string) ([]*runpb.Revision, error) {
-	parent := fmt.Sprintf("projects/%s/locations/%s/services/%s", project, region, service)
+	parent := NewServiceName(project, region, service).ServiceName()
 
-	iter := c.revisionsClient.ListRevisions(ctx, &runpb.ListRevisionsRequest{
-		Parent: parent,
-	})
+	callCtx, cancel := withCallTimeout(ctx, c.timeouts.List)
+	defer cancel()
 
 	var revisions []*runpb.Revision
-	for {
-		rev, err := iter.Next()
-		if err != nil {
-			// Check if we've reached the end
-			if err.Error() == "iterator done" {
-				break
+	err := c.throttle(callCtx, project, func() error {
+		// Restart the listing from scratch on retries
+		revisions = nil
+		iter := c.revisionsClient.ListRevisions(callCtx, &runpb.ListRevisionsRequest{
+			Parent: parent,
+		})
+		for {
+			rev, err := iter.Next()
+			if err != nil {
+				// Check if we've reached the end
+				if err.Error() == "iterator done" {
+					return nil
+				}
+				return err
 			}
-			return nil, fmt.Errorf("failed to list revisions: %w", err)
+			revisions = append(revisions, rev)
 		}
-		revisions = append(revisions, rev)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revisions: %w", wrapCallError(ctx, callCtx, "ListRevisions", c.timeouts.List, err))
 	}
 
 	return revisions, nil
 }
 
+// revisionPageSize is the number of revisions fetched per page.
+const revisionPageSize = 100
+
+// ListRevisionPages lists the revisions of a service page by page until fn
+// returns false, so callers looking for recent revisions don't list
+// services with many revisions entirely. Each page is bounded by the list
+// timeout.
+func (c *client) ListRevisionPages(ctx context.Context, project, region, service string, fn func(page []*runpb.Revision) bool) error {
+	parent := NewServiceName(project, region, service).ServiceName()
+
+	token := ""
+	for {
+		var (
+			page []*runpb.Revision
+			next string
+		)
+		callCtx, cancel := withCallTimeout(ctx, c.timeouts.List)
+		err := c.throttle(callCtx, project, func() error {
+			// Refetch the whole page on retries
+			page = nil
+			iter := c.revisionsClient.ListRevisions(callCtx, &runpb.ListRevisionsRequest{
+				Parent: parent,
+			})
+			var err error
+			next, err = iterator.NewPager(iter, revisionPageSize, token).NextPage(&page)
+			return err
+		})
+		err = wrapCallError(ctx, callCtx, "ListRevisions", c.timeouts.List, err)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to list revisions: %w", err)
+		}
+		if !fn(page) || next == "" {
+			return nil
+		}
+		token = next
+	}
+}
+
 // GetRevision gets a specific revision.
 func (c *client) GetRevision(ctx context.Context, project, region, service, revision string) (*runpb.Revision, error) {
-	name := fmt.Sprintf("projects/%s/locations/%s/services/%s/revisions/%s",
-		project, region, service, revision)
-	return c.revisionsClient.GetRevision(ctx, &runpb.GetRevisionRequest{
-		Name: name,
+	name := NewRevisionName(project, region, service, ShortRevisionName(revision)).RevisionName()
+
+	callCtx, cancel := withCallTimeout(ctx, c.timeouts.Get)
+	defer cancel()
+
+	var rev *runpb.Revision
+	err := c.throttle(callCtx, project, func() error {
+		var err error
+		rev, err = c.revisionsClient.GetRevision(callCtx, &runpb.GetRevisionRequest{
+			Name: name,
+		})
+		return err
 	})
+	return rev, wrapCallError(ctx, callCtx, "GetRevision", c.timeouts.Get, err)
 }
 
 // DeleteRevision deletes a specific revision.
 func (c *client) DeleteRevision(ctx context.Context, project, region, service, revision string) error {
-	name := fmt.Sprintf("projects/%s/locations/%s/services/%s/revisions/%s",
-		project, region, service, revision)
-	op, err := c.revisionsClient.DeleteRevision(ctx, &runpb.DeleteRevisionRequest{
-		Name: name,
+	name := NewRevisionName(project, region, service, ShortRevisionName(revision)).RevisionName()
+
+	callCtx, cancel := withCallTimeout(ctx, c.timeouts.Delete)
+	defer cancel()
+
+	var op *run.DeleteRevisionOperation
+	err := c.throttle(callCtx, project, func() error {
+		var err error
+		op, err = c.revisionsClient.DeleteRevision(callCtx, &runpb.DeleteRevisionRequest{
+			Name: name,
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete revision: %w", wrapCallError(ctx, callCtx, "DeleteRevision", c.timeouts.Delete, err))
+	}
+	// Wait for operation to complete
+	_, err = op.Wait(callCtx)
+	return wrapCallError(ctx, callCtx, "DeleteRevision", c.timeouts.Delete, err)
+}
+
+// DeleteService deletes a service and all of its revisions.
+func (c *client) DeleteService(ctx context.Context, project, region, service string) error {
+	name := NewServiceName(project, region, service).ServiceName()
+
+	callCtx, cancel := withCallTimeout(ctx, c.timeouts.Delete)
+	defer cancel()
+
+	var op *run.DeleteServiceOperation
+	err := c.throttle(callCtx, project, func() error {
+		var err error
+		op, err = c.servicesClient.DeleteService(callCtx, &runpb.DeleteServiceRequest{
+			Name: name,
+		})
+		return err
 	})
 	if err != nil {
-		return fmt.Errorf("failed to delete revision: %w", err)
+		return fmt.Errorf("failed to delete service: %w", wrapCallError(ctx, callCtx, "DeleteService", c.timeouts.Delete, err))
 	}
 	// Wait for operation to complete
-	_, err = op.Wait(ctx)
-	return err
+	_, err = op.Wait(callCtx)
+	return wrapCallError(ctx, callCtx, "DeleteService", c.timeouts.Delete, err)
+}
+
+// GetIAMPolicy gets the IAM policy of a service, including conditional bindings.
+func (c *client) GetIAMPolicy(ctx context.Context, project, region, service string) (*iampb.Policy, error) {
+	name := NewServiceName(project, region, service).ServiceName()
+
+	callCtx, cancel := withCallTimeout(ctx, c.timeouts.Get)
+	defer cancel()
+
+	var policy *iampb.Policy
+	err := c.throttle(callCtx, project, func() error {
+		var err error
+		policy, err = c.servicesClient.GetIamPolicy(callCtx, &iampb.GetIamPolicyRequest{
+			Resource: name,
+			Options: &iampb.GetPolicyOptions{
+				RequestedPolicyVersion: iamConditionsPolicyVersion,
+			},
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get IAM policy: %w", wrapCallError(ctx, callCtx, "GetIamPolicy", c.timeouts.Get, err))
+	}
+	return policy, nil
+}
+
+// SetIAMPolicy sets the IAM policy of a service.
+func (c *client) SetIAMPolicy(ctx context.Context, project, region, service string, policy *iampb.Policy) (*iampb.Policy, error) {
+	name := NewServiceName(project, region, service).ServiceName()
+
+	callCtx, cancel := withCallTimeout(ctx, c.timeouts.Update)
+	defer cancel()
+
+	var result *iampb.Policy
+	err := c.throttle(callCtx, project, func() error {
+		var err error
+		result, err = c.servicesClient.SetIamPolicy(callCtx, &iampb.SetIamPolicyRequest{
+			Resource: name,
+			Policy:   policy,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set IAM policy: %w", wrapCallError(ctx, callCtx, "SetIamPolicy", c.timeouts.Update, err))
+	}
+	return result, nil
 }
 
-// WaitForServiceReady waits for a service to be ready.
+// WaitForServiceReady waits for a service to be ready. If the service fails,
+// or the context deadline is reached, while a condition reports scaling
+// blocked by a quota or limit, it returns a *QuotaError.
 func (c *client) WaitForServiceReady(ctx context.Context, project, region, service string) error {
-	name := fmt.Sprintf("projects/%s/locations/%s/services/%s", project, region, service)
+	name := NewServiceName(project, region, service)
+	progress := startProgress(ctx, fmt.Sprintf("service %s to be ready", service), 0)
 
 	// Poll until service is ready
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
 
+	// The last condition reporting scaling blocked by a quota, so a timeout
+	// says why the service didn't become ready
+	var quota *runpb.Condition
 	for {
 		select {
 		case <-ctx.Done():
+			if quota != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return &QuotaError{Resource: "service " + service, Message: quota.Message, Err: ctx.Err()}
+			}
 			return ctx.Err()
 		case <-ticker.C:
-			svc, err := c.servicesClient.GetService(ctx, &runpb.GetServiceRequest{
-				Name: name,
-			})
+			svc, err := c.getService(ctx, name)
 			if err != nil {
 				return err
 			}
+			quota = QuotaCondition(append([]*runpb.Condition{svc.TerminalCondition}, svc.Conditions...)...)
 
 			// Check if service is ready
 			if svc.Conditions != nil {
@@ -266,11 +713,19 @@ func (c *client) WaitForServiceReady(ctx context.Context, project, region, servi
 							return nil
 						}
 						if cond.State == runpb.Condition_CONDITION_FAILED {
+							if quota != nil {
+								return &QuotaError{Resource: "service " + service, Message: quota.Message}
+							}
 							return fmt.Errorf("service failed to become ready: %s", cond.Message)
 						}
 					}
 				}
 			}
+			if quota != nil {
+				progress.report("latest condition: %s, %s", formatCondition(svc.TerminalCondition), formatQuotaCondition(quota))
+				continue
+			}
+			progress.report("latest condition: %s", formatCondition(svc.TerminalCondition))
 		}
 	}
 }
@@ -283,40 +738,11 @@ func (c *client) Close() error {
 	if c.revisionsClient != nil {
 		c.revisionsClient.Close()
 	}
-	return nil
-}
-
-// Helper function to extract parent from service name
-func getParentFromServiceName(name string) string {
-	// name format: projects/{project}/locations/{location}/services/{service}
-	// parent format: projects/{project}/locations/{location}
-	// Find the last slash and truncate
-	lastSlash := 0
-	for i := len(name) - 1; i >= 0; i-- {
-		if name[i] == '/' {
-			lastSlash = i
-			break
-		}
-	}
-	if lastSlash > 0 {
-		return name[:lastSlash]
+	if c.jobsClient != nil {
+		c.jobsClient.Close()
 	}
-	return name
-}
-
-// Helper function to extract service ID from service name
-func getServiceIDFromServiceName(name string) string {
-	// name format: projects/{project}/locations/{location}/services/{service}
-	// Extract the service ID (after last slash)
-	lastSlash := 0
-	for i := len(name) - 1; i >= 0; i-- {
-		if name[i] == '/' {
-			lastSlash = i
-			break
-		}
+	if c.executionsClient != nil {
+		c.executionsClient.Close()
 	}
-	if lastSlash > 0 && lastSlash < len(name)-1 {
-		return name[lastSlash+1:]
-	}
-	return name
+	return nil
 }
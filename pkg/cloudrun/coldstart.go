@@ -0,0 +1,69 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// StartupLatencyMetric is the Cloud Monitoring metric of the time container
+// instances take to start, in milliseconds. Each cold start adds a sample.
+const StartupLatencyMetric = "run.googleapis.com/container/startup_latencies"
+
+// ColdStartLatency is the startup latency of the instances of a revision
+// started within a window.
+type ColdStartLatency struct {
+	Revision string
+	P50      time.Duration
+	P95      time.Duration
+}
+
+// ReadColdStartLatency reads the median and 95th percentile startup latency
+// of the instances of the revision started within the window ending at end.
+// The window is rounded up to whole seconds of at least a minute, as Cloud
+// Monitoring requires. It returns false if no instance started in the window.
+func ReadColdStartLatency(ctx context.Context, reader MetricReader, scope MetricScope, window time.Duration, end time.Time) (ColdStartLatency, bool, error) {
+	window = max(window, time.Minute)
+	if r := window % time.Second; r != 0 {
+		window += time.Second - r
+	}
+
+	latency := ColdStartLatency{Revision: scope.Revision}
+	for _, p := range []struct {
+		aggregation Aggregation
+		value       *time.Duration
+	}{
+		{AggregationP50, &latency.P50},
+		{AggregationP95, &latency.P95},
+	} {
+		q := MetricQuery{
+			Name:            "startup latency " + string(p.aggregation),
+			Metric:          StartupLatencyMetric,
+			Aggregation:     p.aggregation,
+			AlignmentPeriod: window,
+		}
+		ms, ok, err := reader.ReadMetric(ctx, scope, q, end)
+		if err != nil {
+			return ColdStartLatency{}, false, fmt.Errorf("failed to read the startup latency of revision %s: %w", scope.Revision, err)
+		}
+		if !ok {
+			return ColdStartLatency{}, false, nil
+		}
+		*p.value = time.Duration(ms * float64(time.Millisecond))
+	}
+	return latency, true, nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeStartupLatencyReader returns the startup latencies of revisions, in
+// milliseconds, by aggregation.
+type fakeStartupLatencyReader struct {
+	latencies map[string]map[Aggregation]float64
+	queries   []MetricQuery
+}
+
+func (r *fakeStartupLatencyReader) ReadMetric(_ context.Context, scope MetricScope, q MetricQuery, _ time.Time) (float64, bool, error) {
+	r.queries = append(r.queries, q)
+	if scope.Revision == "broken" {
+		return 0, false, errors.New("permission denied")
+	}
+	v, ok := r.latencies[scope.Revision][q.Aggregation]
+	return v, ok, nil
+}
+
+func TestReadColdStartLatency(t *testing.T) {
+	ctx := context.Background()
+	reader := &fakeStartupLatencyReader{latencies: map[string]map[Aggregation]float64{
+		"my-service-00002": {AggregationP50: 850, AggregationP95: 1900.5},
+	}}
+
+	latency, ok, err := ReadColdStartLatency(ctx, reader, MetricScope{Revision: "my-service-00002"}, 90*time.Second+300*time.Millisecond, time.Now())
+	if err != nil || !ok {
+		t.Fatalf("expected the latency, got %v, %v", ok, err)
+	}
+	if latency.P50 != 850*time.Millisecond || latency.P95 != 1900500*time.Microsecond || latency.Revision != "my-service-00002" {
+		t.Errorf("unexpected latency %+v", latency)
+	}
+	for _, q := range reader.queries {
+		if q.Metric != StartupLatencyMetric || q.AlignmentPeriod != 91*time.Second {
+			t.Errorf("expected the startup latency over 91s, got %s over %s", q.Metric, q.AlignmentPeriod)
+		}
+	}
+
+	reader.queries = nil
+	if _, _, err := ReadColdStartLatency(ctx, reader, MetricScope{Revision: "my-service-00002"}, time.Second, time.Now()); err != nil || reader.queries[0].AlignmentPeriod != time.Minute {
+		t.Errorf("expected short windows to be read over a minute, got %v", err)
+	}
+
+	if _, ok, err := ReadColdStartLatency(ctx, reader, MetricScope{Revision: "my-service-00001"}, time.Hour, time.Now()); ok || err != nil {
+		t.Errorf("expected no data, got %v, %v", ok, err)
+	}
+	if _, _, err := ReadColdStartLatency(ctx, reader, MetricScope{Revision: "broken"}, time.Hour, time.Now()); err == nil {
+		t.Error("expected an error")
+	}
+}
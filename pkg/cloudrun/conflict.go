@@ -0,0 +1,81 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// operationInProgressInterval is the delay between checks of whether the
+// operation in progress on a service has finished.
+var operationInProgressInterval = 5 * time.Second
+
+// retryOperationInProgress runs update, and while it fails because another
+// operation on the service is in progress, waits for reconciling to report
+// that the service finished reconciling and runs it again. It gives up with
+// the last error after timeout. A zero timeout runs update once.
+func retryOperationInProgress(
+	ctx context.Context,
+	service string,
+	timeout time.Duration,
+	update func() error,
+	reconciling func() (bool, error),
+) error {
+	err := update()
+	if timeout <= 0 || !IsOperationInProgress(err) {
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	progress := startProgress(ctx, fmt.Sprintf("the operation in progress on service %s to finish", service), timeout)
+	for {
+		if !time.Now().Add(operationInProgressInterval).Before(deadline) {
+			return fmt.Errorf("another operation on service %s is still in progress after %s: %w", service, timeout, err)
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(operationInProgressInterval):
+		}
+
+		busy, rerr := reconciling()
+		if rerr != nil {
+			return rerr
+		}
+		if busy {
+			progress.report("service is reconciling")
+			continue
+		}
+
+		if err = update(); !IsOperationInProgress(err) {
+			return err
+		}
+		progress.report("update rejected: %v", err)
+	}
+}
+
+// serviceReconciling returns a function reporting whether the service is
+// reconciling, for retryOperationInProgress.
+func (c *client) serviceReconciling(ctx context.Context, name ResourceName) func() (bool, error) {
+	return func() (bool, error) {
+		svc, err := c.getService(ctx, name)
+		if err != nil {
+			return false, fmt.Errorf("failed to get service: %w", err)
+		}
+		return svc.Reconciling, nil
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetryOperationInProgress(t *testing.T) {
+	defer func(interval time.Duration) { operationInProgressInterval = interval }(operationInProgressInterval)
+	operationInProgressInterval = time.Millisecond
+	ctx := context.Background()
+	inProgress := status.Error(codes.FailedPrecondition, "operation in progress")
+
+	// The update is retried once the service stopped reconciling
+	var updates, checks int
+	err := retryOperationInProgress(ctx, "app", time.Second, func() error {
+		updates++
+		if updates == 1 {
+			return inProgress
+		}
+		return nil
+	}, func() (bool, error) {
+		checks++
+		return checks < 3, nil
+	})
+	if err != nil || updates != 2 || checks != 3 {
+		t.Errorf("expected a retry after 3 checks, got %v after %d updates, %d checks", err, updates, checks)
+	}
+
+	// Other errors are not retried
+	updates = 0
+	invalid := status.Error(codes.InvalidArgument, "invalid")
+	err = retryOperationInProgress(ctx, "app", time.Second, func() error {
+		updates++
+		return invalid
+	}, func() (bool, error) { return false, nil })
+	if !errors.Is(err, invalid) || updates != 1 {
+		t.Errorf("expected no retry, got %v after %d updates", err, updates)
+	}
+
+	// The last error is returned on timeout
+	err = retryOperationInProgress(ctx, "app", 20*time.Millisecond, func() error {
+		return inProgress
+	}, func() (bool, error) { return true, nil })
+	if !IsOperationInProgress(err) {
+		t.Errorf("expected the operation in progress error, got %v", err)
+	}
+
+	// Without a timeout, the update runs once
+	updates = 0
+	err = retryOperationInProgress(ctx, "app", 0, func() error {
+		updates++
+		return inProgress
+	}, func() (bool, error) { return false, nil })
+	if err != inProgress || updates != 1 {
+		t.Errorf("expected a single update, got %v after %d updates", err, updates)
+	}
+}
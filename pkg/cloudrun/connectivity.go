@@ -0,0 +1,80 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CallObserver is called with the result of every Admin API call made by the
+// client, after quota retries. A nil error means the call succeeded.
+type CallObserver func(err error)
+
+// WithCallObserver sets a function observing the result of Admin API calls,
+// e.g. to report the connectivity of a deploy target.
+func WithCallObserver(fn CallObserver) ClientOption {
+	return func(o *clientOptions) {
+		o.callObserver = fn
+	}
+}
+
+// IsConnectivityError reports whether the error means the Admin API could not
+// be reached or rejected the credentials, as opposed to an error about the
+// request itself (e.g. NOT_FOUND). Cancellation by the caller is not a
+// connectivity error.
+func IsConnectivityError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		// Token and transport errors are not gRPC statuses
+		return true
+	}
+	switch s.Code() {
+	case codes.Unauthenticated, codes.PermissionDenied, codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsRegionalOutage reports whether the error means the region could not serve
+// the request (the Admin API is unavailable, failed internally, or timed out),
+// so the same request may succeed in another region. Errors about the request
+// itself, credentials, and cancellation by the caller are not outages.
+func IsRegionalOutage(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var timeoutErr *CallTimeoutError
+	if errors.As(err, &timeoutErr) {
+		return true
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+	switch s.Code() {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Internal:
+		return true
+	default:
+		return false
+	}
+}
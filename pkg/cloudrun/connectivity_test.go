@@ -0,0 +1,58 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestIsRegionalOutage(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "nil", err: nil, expected: false},
+		{name: "unavailable", err: status.Error(codes.Unavailable, "service unavailable"), expected: true},
+		{name: "internal", err: status.Error(codes.Internal, "internal error"), expected: true},
+		{name: "deadline exceeded", err: status.Error(codes.DeadlineExceeded, "deadline exceeded"), expected: true},
+		{
+			name:     "call timeout",
+			err:      &CallTimeoutError{Op: "UpdateService", Timeout: time.Minute, Err: context.DeadlineExceeded},
+			expected: true,
+		},
+		{name: "wrapped", err: fmt.Errorf("failed to update service: %w", status.Error(codes.Unavailable, "")), expected: true},
+		{name: "not found", err: status.Error(codes.NotFound, "not found"), expected: false},
+		{name: "permission denied", err: status.Error(codes.PermissionDenied, "denied"), expected: false},
+		{name: "invalid argument", err: status.Error(codes.InvalidArgument, "bad manifest"), expected: false},
+		{name: "canceled", err: context.Canceled, expected: false},
+		{name: "not a status", err: errors.New("service failed to become ready: image not found"), expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRegionalOutage(tt.err); got != tt.expected {
+				t.Errorf("IsRegionalOutage(%v) = %v, expected %v", tt.err, got, tt.expected)
+			}
+		})
+	}
+}
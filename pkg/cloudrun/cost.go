@@ -0,0 +1,167 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+)
+
+// Prices of idle instances, as Tier 1 list prices in USD per second. They
+// only estimate the cost: regional prices, discounts, and free tiers differ.
+const (
+	// Instance-based billing: CPU is always allocated, and idle instances
+	// are billed like active ones.
+	alwaysAllocatedVCPUSecondPrice = 0.000018
+	alwaysAllocatedGiBSecondPrice  = 0.000002
+
+	// Request-based billing: idle minimum instances are billed at a
+	// reduced rate.
+	idleVCPUSecondPrice = 0.0000025
+	idleGiBSecondPrice  = 0.0000025
+
+	// secondsPerMonth is an average month of 730 hours.
+	secondsPerMonth = 730 * 60 * 60
+)
+
+// Resources of a container without limits.
+const (
+	defaultContainerCPU       = "1"
+	defaultContainerMemory    = "512Mi"
+	defaultContainerMemoryGiB = 0.5
+)
+
+// IdleCapacity is the capacity of a service that is allocated, and billed,
+// while it serves no requests: its minimum instances.
+type IdleCapacity struct {
+	// Instances is the minimum number of instances.
+	Instances int32
+
+	// VCPU and MemoryGiB are the resources of each instance, summed over
+	// its containers.
+	VCPU      float64
+	MemoryGiB float64
+
+	// CPUAlwaysAllocated is whether the CPU is allocated outside of
+	// requests (cpuIdle is false), which bills idle instances at the full
+	// instance-based rate.
+	CPUAlwaysAllocated bool
+}
+
+// ServiceIdleCapacity returns the idle capacity of the revision template of
+// the service. The minimum instances are the higher of the revision and
+// service minimums.
+func ServiceIdleCapacity(svc *runpb.Service) (IdleCapacity, error) {
+	tmpl := svc.GetTemplate()
+	c := IdleCapacity{
+		Instances: max(tmpl.GetScaling().GetMinInstanceCount(), svc.GetScaling().GetMinInstanceCount()),
+	}
+	for _, container := range tmpl.GetContainers() {
+		resources := container.GetResources()
+		cpu, err := ParseCPU(limit(resources.GetLimits(), "cpu", defaultContainerCPU))
+		if err != nil {
+			return IdleCapacity{}, fmt.Errorf("container %s: %w", container.Name, err)
+		}
+		memory, err := ParseMemoryGiB(limit(resources.GetLimits(), "memory", defaultContainerMemory))
+		if err != nil {
+			return IdleCapacity{}, fmt.Errorf("container %s: %w", container.Name, err)
+		}
+		c.VCPU += cpu
+		c.MemoryGiB += memory
+		if resources != nil && !resources.CpuIdle {
+			c.CPUAlwaysAllocated = true
+		}
+	}
+	if len(tmpl.GetContainers()) == 0 {
+		c.VCPU, c.MemoryGiB = 1, defaultContainerMemoryGiB
+	}
+	return c, nil
+}
+
+// limit returns the resource limit, or def if it isn't set.
+func limit(limits map[string]string, name, def string) string {
+	if v := limits[name]; v != "" {
+		return v
+	}
+	return def
+}
+
+// MonthlyCost estimates the monthly cost of the idle capacity in USD.
+func (c IdleCapacity) MonthlyCost() float64 {
+	vcpuPrice, gibPrice := idleVCPUSecondPrice, idleGiBSecondPrice
+	if c.CPUAlwaysAllocated {
+		vcpuPrice, gibPrice = alwaysAllocatedVCPUSecondPrice, alwaysAllocatedGiBSecondPrice
+	}
+	perInstance := c.VCPU*vcpuPrice + c.MemoryGiB*gibPrice
+	return float64(c.Instances) * perInstance * secondsPerMonth
+}
+
+// String formats the capacity, e.g.
+// "2 instance(s) x 1 vCPU, 0.5 GiB, CPU always allocated".
+func (c IdleCapacity) String() string {
+	if c.Instances == 0 {
+		return "no minimum instances"
+	}
+	allocation := "CPU allocated during requests"
+	if c.CPUAlwaysAllocated {
+		allocation = "CPU always allocated"
+	}
+	return fmt.Sprintf("%d instance(s) x %s vCPU, %s GiB, %s", c.Instances, formatFloat(c.VCPU), formatFloat(c.MemoryGiB), allocation)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// ParseCPU parses a CPU limit such as "2" or "500m" into vCPUs.
+func ParseCPU(s string) (float64, error) {
+	value, scale := s, 1.0
+	if strings.HasSuffix(s, "m") {
+		value, scale = strings.TrimSuffix(s, "m"), 0.001
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid cpu limit %q", s)
+	}
+	return f * scale, nil
+}
+
+// memoryUnits are the suffixes of memory limits and their size in bytes.
+var memoryUnits = []struct {
+	suffix string
+	bytes  float64
+}{
+	{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"Ti", 1 << 40},
+	{"k", 1e3}, {"K", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12},
+}
+
+// ParseMemoryGiB parses a memory limit such as "512Mi" or "2G" into GiB.
+func ParseMemoryGiB(s string) (float64, error) {
+	value, unit := s, 1.0
+	for _, u := range memoryUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			value, unit = strings.TrimSuffix(s, u.suffix), u.bytes
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid memory limit %q", s)
+	}
+	return f * unit / (1 << 30), nil
+}
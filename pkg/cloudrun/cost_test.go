@@ -0,0 +1,93 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"math"
+	"testing"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+)
+
+func TestServiceIdleCapacity(t *testing.T) {
+	svc := &runpb.Service{
+		Template: &runpb.RevisionTemplate{
+			Scaling: &runpb.RevisionScaling{MinInstanceCount: 2},
+			Containers: []*runpb.Container{
+				{Name: "app", Resources: &runpb.ResourceRequirements{Limits: map[string]string{"cpu": "2", "memory": "1Gi"}}},
+				{Name: "sidecar", Resources: &runpb.ResourceRequirements{Limits: map[string]string{"cpu": "500m"}, CpuIdle: true}},
+			},
+		},
+	}
+	c, err := ServiceIdleCapacity(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := IdleCapacity{Instances: 2, VCPU: 2.5, MemoryGiB: 1.5, CPUAlwaysAllocated: true}
+	if c != expected {
+		t.Errorf("expected %+v, got %+v", expected, c)
+	}
+	if s := c.String(); s != "2 instance(s) x 2.5 vCPU, 1.5 GiB, CPU always allocated" {
+		t.Errorf("unexpected string %q", s)
+	}
+
+	// 2 instances x (2.5 vCPU x 0.000018 + 1.5 GiB x 0.000002) x 730h
+	if cost := c.MonthlyCost(); math.Abs(cost-252.288) > 0.001 {
+		t.Errorf("expected a monthly cost of 252.288, got %f", cost)
+	}
+
+	// The service minimum applies when higher
+	svc.Scaling = &runpb.ServiceScaling{MinInstanceCount: 3}
+	if c, _ := ServiceIdleCapacity(svc); c.Instances != 3 {
+		t.Errorf("expected 3 instances, got %d", c.Instances)
+	}
+
+	// Containers without resources allocate CPU during requests only
+	c, err = ServiceIdleCapacity(&runpb.Service{Template: &runpb.RevisionTemplate{Containers: []*runpb.Container{{}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.CPUAlwaysAllocated || c.VCPU != 1 || c.MemoryGiB != 0.5 || c.MonthlyCost() != 0 {
+		t.Errorf("unexpected default capacity %+v", c)
+	}
+
+	svc.Template.Containers[0].Resources.Limits["memory"] = "lots"
+	if _, err := ServiceIdleCapacity(svc); err == nil {
+		t.Error("expected error for an invalid memory limit")
+	}
+}
+
+func TestParseResourceLimits(t *testing.T) {
+	cpus := map[string]float64{"1": 1, "0.5": 0.5, "250m": 0.25, "4": 4}
+	for s, expected := range cpus {
+		if got, err := ParseCPU(s); err != nil || got != expected {
+			t.Errorf("ParseCPU(%q) = %v, %v, expected %v", s, got, err, expected)
+		}
+	}
+	memories := map[string]float64{"512Mi": 0.5, "2Gi": 2, "1073741824": 1, "1048576Ki": 1}
+	for s, expected := range memories {
+		if got, err := ParseMemoryGiB(s); err != nil || got != expected {
+			t.Errorf("ParseMemoryGiB(%q) = %v, %v, expected %v", s, got, err, expected)
+		}
+	}
+	for _, s := range []string{"", "one", "-1", "1x"} {
+		if _, err := ParseCPU(s); err == nil {
+			t.Errorf("expected error for cpu %q", s)
+		}
+		if _, err := ParseMemoryGiB(s); err == nil {
+			t.Errorf("expected error for memory %q", s)
+		}
+	}
+}
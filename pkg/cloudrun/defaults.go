@@ -0,0 +1,114 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"sort"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+)
+
+// ServiceDefaults defines service-level metadata merged into every service
+// deployed to a deploy target.
+type ServiceDefaults struct {
+	// Labels are merged into the service labels.
+	Labels map[string]string
+
+	// Annotations are merged into the service annotations.
+	Annotations map[string]string
+
+	// Description is used when the manifest has no description.
+	Description string
+}
+
+// DefaultsCollision is a default that was not applied because the manifest
+// sets a different value.
+type DefaultsCollision struct {
+	// Kind is "label", "annotation", or "description".
+	Kind string
+
+	// Key is the label or annotation key (empty for the description).
+	Key string
+
+	// Default is the value defined by the deploy target.
+	Default string
+
+	// Manifest is the value kept from the manifest.
+	Manifest string
+}
+
+// ApplyServiceDefaults merges the defaults into the service. Values set in the
+// manifest take precedence; each default that differs from the manifest value
+// is returned as a collision, sorted by kind and key.
+func ApplyServiceDefaults(service *runpb.Service, d ServiceDefaults) []DefaultsCollision {
+	var collisions []DefaultsCollision
+
+	if len(d.Labels) > 0 {
+		if service.Labels == nil {
+			service.Labels = make(map[string]string, len(d.Labels))
+		}
+		collisions = append(collisions, mergeDefaults("label", service.Labels, d.Labels)...)
+	}
+
+	if len(d.Annotations) > 0 {
+		if service.Annotations == nil {
+			service.Annotations = make(map[string]string, len(d.Annotations))
+		}
+		collisions = append(collisions, mergeDefaults("annotation", service.Annotations, d.Annotations)...)
+	}
+
+	if d.Description != "" {
+		switch service.Description {
+		case "":
+			service.Description = d.Description
+		case d.Description:
+		default:
+			collisions = append(collisions, DefaultsCollision{
+				Kind:     "description",
+				Default:  d.Description,
+				Manifest: service.Description,
+			})
+		}
+	}
+
+	sort.SliceStable(collisions, func(i, j int) bool {
+		if collisions[i].Kind != collisions[j].Kind {
+			return collisions[i].Kind < collisions[j].Kind
+		}
+		return collisions[i].Key < collisions[j].Key
+	})
+	return collisions
+}
+
+// mergeDefaults sets each default missing from dst and returns the collisions.
+func mergeDefaults(kind string, dst, defaults map[string]string) []DefaultsCollision {
+	var collisions []DefaultsCollision
+	for k, v := range defaults {
+		current, ok := dst[k]
+		if !ok {
+			dst[k] = v
+			continue
+		}
+		if current != v {
+			collisions = append(collisions, DefaultsCollision{
+				Kind:     kind,
+				Key:      k,
+				Default:  v,
+				Manifest: current,
+			})
+		}
+	}
+	return collisions
+}
@@ -0,0 +1,112 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+)
+
+// ContainerStartupOrder returns the names of the containers of the revision
+// template in the order Cloud Run starts them: each container after the
+// containers in its dependsOn. Containers are otherwise kept in manifest
+// order. It returns an error if a dependency isn't another container of the
+// template, or if the dependencies form a cycle.
+func ContainerStartupOrder(tmpl *runpb.RevisionTemplate) ([]string, error) {
+	containers := tmpl.GetContainers()
+	names := make([]string, len(containers))
+	index := make(map[string]int, len(containers))
+	for i, c := range containers {
+		names[i] = containerLabel(c, i)
+		if c.Name != "" {
+			index[c.Name] = i
+		}
+	}
+
+	// pending counts the dependencies of each container not started yet
+	pending := make([]int, len(containers))
+	dependents := make([][]int, len(containers))
+	for i, c := range containers {
+		for _, dep := range c.DependsOn {
+			j, ok := index[dep]
+			switch {
+			case !ok:
+				return nil, fmt.Errorf("container %s depends on unknown container %q", names[i], dep)
+			case j == i:
+				return nil, fmt.Errorf("container %s depends on itself", names[i])
+			}
+			pending[i]++
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+
+	order := make([]string, 0, len(containers))
+	started := make([]bool, len(containers))
+	for len(order) < len(containers) {
+		// Start the first container in manifest order whose dependencies started
+		next := -1
+		for i, n := range pending {
+			if n == 0 && !started[i] {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			var cycle []string
+			for i, n := range pending {
+				if n > 0 && !started[i] {
+					cycle = append(cycle, names[i])
+				}
+			}
+			return nil, fmt.Errorf("containers %s can't start: their dependencies form a cycle", strings.Join(cycle, ", "))
+		}
+		started[next] = true
+		order = append(order, names[next])
+		for _, i := range dependents[next] {
+			pending[i]--
+		}
+	}
+	return order, nil
+}
+
+// ValidateContainerDependencies checks the dependsOn of the containers of
+// the revision template, as ContainerStartupOrder.
+func ValidateContainerDependencies(tmpl *runpb.RevisionTemplate) error {
+	_, err := ContainerStartupOrder(tmpl)
+	return err
+}
+
+// ContainerDependencies returns the dependsOn of each container of the
+// revision template that declares one, keyed by container name.
+func ContainerDependencies(tmpl *runpb.RevisionTemplate) map[string][]string {
+	deps := make(map[string][]string)
+	for i, c := range tmpl.GetContainers() {
+		if len(c.DependsOn) > 0 {
+			deps[containerLabel(c, i)] = c.DependsOn
+		}
+	}
+	return deps
+}
+
+// containerLabel returns the name of the container, or its index if it is
+// unnamed, as in "containers[1]".
+func containerLabel(c *runpb.Container, i int) string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return fmt.Sprintf("containers[%d]", i)
+}
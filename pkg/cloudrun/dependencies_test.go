@@ -0,0 +1,69 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+)
+
+func TestContainerStartupOrder(t *testing.T) {
+	tmpl := &runpb.RevisionTemplate{Containers: []*runpb.Container{
+		{Name: "app", DependsOn: []string{"proxy", "otel"}},
+		{Name: "proxy", DependsOn: []string{"otel"}},
+		{Name: "otel"},
+		{Name: "logs"},
+	}}
+	order, err := ContainerStartupOrder(tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"otel", "proxy", "app", "logs"}; !slices.Equal(order, want) {
+		t.Errorf("expected %v, got %v", want, order)
+	}
+	if deps := ContainerDependencies(tmpl); len(deps) != 2 || !slices.Equal(deps["app"], []string{"proxy", "otel"}) {
+		t.Errorf("unexpected dependencies %v", deps)
+	}
+
+	for name, tc := range map[string]struct {
+		containers []*runpb.Container
+		err        string
+	}{
+		"unknown": {
+			containers: []*runpb.Container{{Name: "app", DependsOn: []string{"sidecar"}}},
+			err:        `container app depends on unknown container "sidecar"`,
+		},
+		"self": {
+			containers: []*runpb.Container{{Name: "app", DependsOn: []string{"app"}}},
+			err:        "container app depends on itself",
+		},
+		"cycle": {
+			containers: []*runpb.Container{
+				{Name: "app", DependsOn: []string{"proxy"}},
+				{Name: "proxy", DependsOn: []string{"app"}},
+				{Name: "otel"},
+			},
+			err: "containers app, proxy can't start",
+		},
+	} {
+		err := ValidateContainerDependencies(&runpb.RevisionTemplate{Containers: tc.containers})
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: expected an error containing %q, got %v", name, tc.err, err)
+		}
+	}
+}
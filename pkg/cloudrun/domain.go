@@ -0,0 +1,221 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/api/option"
+	runv1 "google.golang.org/api/run/v1"
+)
+
+// ServiceDomains returns the domains mapped to the service by domain mappings.
+//
+// If credentialsFile is empty, Application Default Credentials will be used.
+func ServiceDomains(ctx context.Context, credentialsFile, project, region, service string) ([]string, error) {
+	var gcpOpts []option.ClientOption
+	if credentialsFile != "" {
+		gcpOpts = append(gcpOpts, option.WithCredentialsFile(credentialsFile))
+	}
+
+	_, mappings, err := listDomainMappings(ctx, gcpOpts, project, region, service)
+	if err != nil {
+		return nil, err
+	}
+	domains := make([]string, 0, len(mappings))
+	for _, dm := range mappings {
+		domains = append(domains, dm.Metadata.Name)
+	}
+	sort.Strings(domains)
+	return domains, nil
+}
+
+// listDomainMappings returns the domain mappings whose route is the service,
+// and the client used to list them. Domain mappings are only available in the
+// Admin API v1.
+func listDomainMappings(ctx context.Context, gcpOpts []option.ClientOption, project, region, service string) (*runv1.APIService, []*runv1.DomainMapping, error) {
+	opts := append(gcpOpts, option.WithEndpoint(fmt.Sprintf("https://%s-run.googleapis.com/", region)))
+	svc, err := runv1.NewService(ctx, opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create Cloud Run v1 client: %w", err)
+	}
+
+	resp, err := svc.Namespaces.Domainmappings.List("namespaces/" + project).Context(ctx).Do()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list domain mappings: %w", err)
+	}
+
+	var mappings []*runv1.DomainMapping
+	for _, dm := range resp.Items {
+		if dm.Metadata == nil || dm.Spec == nil || dm.Spec.RouteName != service {
+			continue
+		}
+		mappings = append(mappings, dm)
+	}
+	return svc, mappings, nil
+}
+
+// DomainCheckOptions defines how VerifyDomain checks a domain.
+type DomainCheckOptions struct {
+	// Path is the path requested over HTTPS, e.g. "/healthz".
+	Path string
+
+	// MinCertificateValidity is how long the certificate must remain valid.
+	MinCertificateValidity time.Duration
+
+	// Timeout bounds each network operation.
+	Timeout time.Duration
+
+	// LookupHost resolves the domain. Defaults to the system resolver.
+	LookupHost func(ctx context.Context, host string) ([]string, error)
+
+	// Port is the HTTPS port. Defaults to 443.
+	Port string
+
+	// RootCAs are the trusted certificate authorities. Defaults to the
+	// system roots.
+	RootCAs *x509.CertPool
+}
+
+// DomainCheckResult is the outcome of checking a domain.
+type DomainCheckResult struct {
+	Domain string `json:"domain"`
+
+	// Addresses are the addresses the domain resolves to.
+	Addresses []string `json:"addresses,omitempty"`
+
+	// CertificateExpiry is when the certificate served for the domain expires.
+	CertificateExpiry *time.Time `json:"certificateExpiry,omitempty"`
+
+	// StatusCode is the status of the HTTPS request.
+	StatusCode int `json:"statusCode,omitempty"`
+
+	// Error is why the check failed, or empty if it passed.
+	Error string `json:"error,omitempty"`
+}
+
+// Passed reports whether every check of the domain passed.
+func (r DomainCheckResult) Passed() bool {
+	return r.Error == ""
+}
+
+// VerifyDomain checks the public endpoint of a domain: the domain resolves,
+// the certificate it serves is trusted, valid for the domain (its SAN), and
+// doesn't expire within MinCertificateValidity, and an HTTPS request to Path
+// gets a response that isn't a server error.
+func VerifyDomain(ctx context.Context, domain string, opts DomainCheckOptions) DomainCheckResult {
+	result := DomainCheckResult{Domain: domain}
+	fail := func(format string, a ...interface{}) DomainCheckResult {
+		result.Error = fmt.Sprintf(format, a...)
+		return result
+	}
+
+	lookupHost := opts.LookupHost
+	if lookupHost == nil {
+		lookupHost = net.DefaultResolver.LookupHost
+	}
+	port := opts.Port
+	if port == "" {
+		port = "443"
+	}
+
+	// DNS
+	lookupCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	addrs, err := lookupHost(lookupCtx, domain)
+	cancel()
+	if err != nil {
+		return fail("DNS lookup failed: %v", err)
+	}
+	if len(addrs) == 0 {
+		return fail("DNS lookup returned no address")
+	}
+	sort.Strings(addrs)
+	result.Addresses = addrs
+
+	// TLS, connecting to the resolved address so the checks agree
+	tlsConfig := &tls.Config{ServerName: domain, RootCAs: opts.RootCAs}
+	dialer := &tls.Dialer{NetDialer: &net.Dialer{Timeout: opts.Timeout}, Config: tlsConfig}
+	addr := net.JoinHostPort(addrs[0], port)
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fail("TLS handshake failed: %s", describeTLSError(err))
+	}
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	conn.Close()
+	expiry := certs[0].NotAfter
+	result.CertificateExpiry = &expiry
+	if time.Until(expiry) < opts.MinCertificateValidity {
+		return fail("certificate expires at %s, within %s", expiry.UTC().Format(time.RFC3339), opts.MinCertificateValidity)
+	}
+
+	// HTTPS
+	httpClient := &http.Client{
+		Timeout: opts.Timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return (&net.Dialer{Timeout: opts.Timeout}).DialContext(ctx, network, addr)
+			},
+			TLSClientConfig: tlsConfig,
+		},
+		// Redirects are responses of the endpoint itself
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	defer httpClient.CloseIdleConnections()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+domain+opts.Path, nil)
+	if err != nil {
+		return fail("invalid request: %v", err)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fail("HTTPS request failed: %v", err)
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	resp.Body.Close()
+	result.StatusCode = resp.StatusCode
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fail("HTTPS request returned %d", resp.StatusCode)
+	}
+	return result
+}
+
+// describeTLSError explains certificate verification errors, e.g. which
+// names a certificate covers if it isn't valid for the domain.
+func describeTLSError(err error) string {
+	var hostnameErr x509.HostnameError
+	if errors.As(err, &hostnameErr) {
+		return fmt.Sprintf("certificate is not valid for %s (SANs: %s)", hostnameErr.Host, strings.Join(hostnameErr.Certificate.DNSNames, ", "))
+	}
+	var expiredErr x509.CertificateInvalidError
+	if errors.As(err, &expiredErr) && expiredErr.Reason == x509.Expired {
+		return "certificate has expired"
+	}
+	var authorityErr x509.UnknownAuthorityError
+	if errors.As(err, &authorityErr) {
+		return "certificate is signed by an unknown authority"
+	}
+	return err.Error()
+}
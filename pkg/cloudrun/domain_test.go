@@ -0,0 +1,89 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyDomain(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootCAs := server.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+	lookupHost := func(ctx context.Context, domain string) ([]string, error) {
+		if domain == "missing.example.com" {
+			return nil, errors.New("no such host")
+		}
+		return []string{host}, nil
+	}
+	opts := func(path string, minValidity time.Duration) DomainCheckOptions {
+		return DomainCheckOptions{
+			Path:                   path,
+			MinCertificateValidity: minValidity,
+			Timeout:                5 * time.Second,
+			LookupHost:             lookupHost,
+			Port:                   port,
+			RootCAs:                rootCAs,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		domain   string
+		opts     DomainCheckOptions
+		expected string
+	}{
+		// The test server certificate covers example.com
+		{name: "passes", domain: "example.com", opts: opts("/", time.Hour)},
+		{name: "DNS failure", domain: "missing.example.com", opts: opts("/", time.Hour), expected: "DNS lookup failed"},
+		{name: "certificate for another domain", domain: "api.other.test", opts: opts("/", time.Hour), expected: "certificate is not valid for api.other.test"},
+		{name: "certificate expiring soon", domain: "example.com", opts: opts("/", 100*365*24*time.Hour), expected: "certificate expires at"},
+		{name: "server error", domain: "example.com", opts: opts("/broken", time.Hour), expected: "HTTPS request returned 502"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := VerifyDomain(context.Background(), tt.domain, tt.opts)
+			if tt.expected == "" {
+				if !result.Passed() {
+					t.Fatalf("expected the domain to pass, got %q", result.Error)
+				}
+				if result.StatusCode != http.StatusOK || result.CertificateExpiry == nil {
+					t.Errorf("expected the status and certificate expiry to be recorded, got %+v", result)
+				}
+				return
+			}
+			if !strings.Contains(result.Error, tt.expected) {
+				t.Errorf("error = %q, expected it to contain %q", result.Error, tt.expected)
+			}
+		})
+	}
+}
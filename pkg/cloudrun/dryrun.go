@@ -0,0 +1,147 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/iam/apiv1/iampb"
+	"cloud.google.com/go/run/apiv2/runpb"
+	"google.golang.org/protobuf/proto"
+)
+
+// DryRunFunc receives the mutations skipped in a dry run, e.g.
+// "Would update traffic of service my-service: LATEST=10%, my-service-00041=90%".
+type DryRunFunc func(message string)
+
+type dryRunKey struct{}
+
+// WithDryRun returns a context in which this package makes no write calls.
+// Each skipped mutation is reported to fn instead.
+func WithDryRun(ctx context.Context, fn DryRunFunc) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, fn)
+}
+
+// DryRun returns the dry-run reporter of the context, and whether the
+// context is a dry run.
+func DryRun(ctx context.Context) (DryRunFunc, bool) {
+	fn, ok := ctx.Value(dryRunKey{}).(DryRunFunc)
+	return fn, ok
+}
+
+// dryRunClient passes reads to the wrapped client and reports writes
+// instead of making them.
+type dryRunClient struct {
+	Client
+	report DryRunFunc
+}
+
+// NewDryRunClient returns a client that reads through c, and reports the
+// create, update, delete, and run calls to report without making them.
+// Writes return the requested resource, and waits return immediately.
+func NewDryRunClient(c Client, report DryRunFunc) Client {
+	return &dryRunClient{Client: c, report: report}
+}
+
+func (c *dryRunClient) reportf(format string, args ...any) {
+	c.report(fmt.Sprintf(format, args...))
+}
+
+// CreateOrUpdateService reports the service and returns it.
+func (c *dryRunClient) CreateOrUpdateService(ctx context.Context, service *runpb.Service) (*runpb.Service, error) {
+	c.reportf("Would deploy service %s with image(s) %s", GetServiceName(service.Name), strings.Join(serviceImages(service), ", "))
+	return proto.Clone(service).(*runpb.Service), nil
+}
+
+// UpdateTraffic reports the traffic split.
+func (c *dryRunClient) UpdateTraffic(ctx context.Context, project, region, service string, traffic []*runpb.TrafficTarget) error {
+	c.reportf("Would update traffic of service %s: %s", service, formatTraffic(traffic))
+	return nil
+}
+
+// DeleteRevision reports the revision.
+func (c *dryRunClient) DeleteRevision(ctx context.Context, project, region, service, revision string) error {
+	c.reportf("Would delete revision %s of service %s", ShortRevisionName(revision), service)
+	return nil
+}
+
+// DeleteService reports the service.
+func (c *dryRunClient) DeleteService(ctx context.Context, project, region, service string) error {
+	c.reportf("Would delete service %s", service)
+	return nil
+}
+
+// SetIAMPolicy reports the policy bindings and returns the policy.
+func (c *dryRunClient) SetIAMPolicy(ctx context.Context, project, region, service string, policy *iampb.Policy) (*iampb.Policy, error) {
+	c.reportf("Would set the IAM policy of service %s (%d binding(s))", service, len(policy.GetBindings()))
+	return policy, nil
+}
+
+// WaitForServiceReady returns immediately, as nothing was deployed.
+func (c *dryRunClient) WaitForServiceReady(ctx context.Context, project, region, service string) error {
+	return nil
+}
+
+// CreateOrUpdateJob reports the job and returns it.
+func (c *dryRunClient) CreateOrUpdateJob(ctx context.Context, job *runpb.Job) (*runpb.Job, error) {
+	var images []string
+	for _, container := range job.GetTemplate().GetTemplate().GetContainers() {
+		images = append(images, container.Image)
+	}
+	c.reportf("Would deploy job %s with image(s) %s", job.Name[strings.LastIndex(job.Name, "/")+1:], strings.Join(images, ", "))
+	return proto.Clone(job).(*runpb.Job), nil
+}
+
+// RunJob reports the job and returns a placeholder execution.
+func (c *dryRunClient) RunJob(ctx context.Context, project, region, job string) (*runpb.Execution, error) {
+	c.reportf("Would run job %s", job)
+	return &runpb.Execution{
+		Name: JobName(project, region, job) + "/executions/dry-run",
+		Job:  job,
+	}, nil
+}
+
+// WaitForExecution returns the placeholder execution immediately.
+func (c *dryRunClient) WaitForExecution(ctx context.Context, name string) (*runpb.Execution, error) {
+	return &runpb.Execution{Name: name}, nil
+}
+
+// serviceImages returns the container images of the service template.
+func serviceImages(service *runpb.Service) []string {
+	var images []string
+	for _, container := range service.GetTemplate().GetContainers() {
+		images = append(images, container.Image)
+	}
+	return images
+}
+
+// formatTraffic formats traffic targets, e.g. "LATEST=10%, my-service-00041=90% (tag: stable)".
+func formatTraffic(traffic []*runpb.TrafficTarget) string {
+	parts := make([]string, 0, len(traffic))
+	for _, t := range traffic {
+		name := ShortRevisionName(t.Revision)
+		if t.Type == runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST {
+			name = "LATEST"
+		}
+		part := fmt.Sprintf("%s=%d%%", name, t.Percent)
+		if t.Tag != "" {
+			part += fmt.Sprintf(" (tag: %s)", t.Tag)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
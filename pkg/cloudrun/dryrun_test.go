@@ -0,0 +1,74 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+)
+
+func TestDryRunClient(t *testing.T) {
+	var reported []string
+	ctx := WithDryRun(context.Background(), func(message string) {
+		reported = append(reported, message)
+	})
+	report, ok := DryRun(ctx)
+	if !ok {
+		t.Fatal("expected a dry-run context")
+	}
+	if _, ok := DryRun(context.Background()); ok {
+		t.Error("expected no dry run by default")
+	}
+
+	// Writes must not reach the wrapped client, which would panic
+	client := NewDryRunClient(nil, report)
+
+	service := &runpb.Service{
+		Name: "projects/my-project/locations/us-central1/services/my-service",
+		Template: &runpb.RevisionTemplate{
+			Containers: []*runpb.Container{{Image: "gcr.io/my-project/app:v2"}},
+		},
+	}
+	got, err := client.CreateOrUpdateService(ctx, service)
+	if err != nil || got.Name != service.Name {
+		t.Fatalf("unexpected result: %v, %v", got, err)
+	}
+	err = client.UpdateTraffic(ctx, "my-project", "us-central1", "my-service", []*runpb.TrafficTarget{
+		{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST, Percent: 10},
+		{Revision: "my-service-00041", Percent: 90, Tag: "stable"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := client.DeleteRevision(ctx, "my-project", "us-central1", "my-service", "my-service-00030"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{
+		"Would deploy service my-service with image(s) gcr.io/my-project/app:v2",
+		"Would update traffic of service my-service: LATEST=10%, my-service-00041=90% (tag: stable)",
+		"Would delete revision my-service-00030 of service my-service",
+	}
+	if len(reported) != len(expected) {
+		t.Fatalf("expected %d reported mutations, got %v", len(expected), reported)
+	}
+	for i := range expected {
+		if reported[i] != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], reported[i])
+		}
+	}
+}
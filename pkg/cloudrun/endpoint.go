@@ -0,0 +1,50 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import "strings"
+
+// DefaultEndpoint is the global endpoint of the Cloud Run Admin API.
+const DefaultEndpoint = "run.googleapis.com:443"
+
+// RegionalEndpoint returns the regional endpoint of the Cloud Run Admin API,
+// which only serves the resources of its region.
+func RegionalEndpoint(region string) string {
+	return region + "-run.googleapis.com:443"
+}
+
+// WithEndpoint sets the Admin API endpoint (host[:port]) used by the client,
+// e.g. a regional or preview endpoint. The port defaults to 443.
+// Default: DefaultEndpoint
+func WithEndpoint(endpoint string) ClientOption {
+	return func(o *clientOptions) {
+		o.endpoint = NormalizeEndpoint(endpoint)
+	}
+}
+
+// NormalizeEndpoint strips the scheme and trailing slash of an endpoint and
+// adds the default port if it has none.
+func NormalizeEndpoint(endpoint string) string {
+	endpoint = strings.TrimSpace(endpoint)
+	endpoint = strings.TrimPrefix(endpoint, "https://")
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	if endpoint == "" {
+		return ""
+	}
+	if !strings.Contains(endpoint, ":") {
+		endpoint += ":443"
+	}
+	return endpoint
+}
@@ -0,0 +1,39 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import "testing"
+
+func TestWithEndpoint(t *testing.T) {
+	for _, tc := range []struct {
+		endpoint string
+		want     string
+	}{
+		{"", ""},
+		{"us-central1-run.googleapis.com", "us-central1-run.googleapis.com:443"},
+		{"https://us-central1-run.googleapis.com/", "us-central1-run.googleapis.com:443"},
+		{"run.sandbox.googleapis.com:8443", "run.sandbox.googleapis.com:8443"},
+	} {
+		var o clientOptions
+		WithEndpoint(tc.endpoint)(&o)
+		if o.endpoint != tc.want {
+			t.Errorf("%q: expected endpoint %q, got %q", tc.endpoint, tc.want, o.endpoint)
+		}
+	}
+
+	if got := RegionalEndpoint("europe-west1"); got != "europe-west1-run.googleapis.com:443" {
+		t.Errorf("unexpected regional endpoint %q", got)
+	}
+}
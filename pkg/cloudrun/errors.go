@@ -0,0 +1,124 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// IsAlreadyExists reports whether the error means the resource to create
+// already exists, e.g. a revision whose name is taken.
+func IsAlreadyExists(err error) bool {
+	s, ok := status.FromError(err)
+	return ok && err != nil && s.Code() == codes.AlreadyExists
+}
+
+// IsNotFound reports whether the error means the resource does not exist.
+func IsNotFound(err error) bool {
+	s, ok := status.FromError(err)
+	return ok && err != nil && s.Code() == codes.NotFound
+}
+
+// IsOperationInProgress reports whether the error means the resource could
+// not be changed because another operation on it is in progress, e.g. the
+// service is still reconciling a previous update.
+func IsOperationInProgress(err error) bool {
+	s, ok := status.FromError(err)
+	if !ok || err == nil {
+		return false
+	}
+	switch s.Code() {
+	case codes.Aborted:
+		return true
+	case codes.FailedPrecondition:
+		msg := strings.ToLower(s.Message())
+		return strings.Contains(msg, "in progress") || strings.Contains(msg, "reconcil")
+	}
+	return false
+}
+
+// ErrorDetails returns the google.rpc error details of an Admin API error,
+// such as field violations, quota failures, and precondition failures, one
+// line per detail, e.g.
+// "field spec.template.containers[0].image: Image 'app:v2' not found".
+// It returns nil if the error has no details.
+func ErrorDetails(err error) []string {
+	s, ok := status.FromError(err)
+	if !ok || err == nil {
+		return nil
+	}
+
+	var details []string
+	for _, d := range s.Details() {
+		switch d := d.(type) {
+		case *errdetails.BadRequest:
+			for _, v := range d.GetFieldViolations() {
+				details = append(details, fmt.Sprintf("field %s: %s", v.GetField(), v.GetDescription()))
+			}
+		case *errdetails.QuotaFailure:
+			for _, v := range d.GetViolations() {
+				details = append(details, fmt.Sprintf("quota %s: %s", v.GetSubject(), v.GetDescription()))
+			}
+		case *errdetails.PreconditionFailure:
+			for _, v := range d.GetViolations() {
+				details = append(details, fmt.Sprintf("precondition %s %s: %s", v.GetType(), v.GetSubject(), v.GetDescription()))
+			}
+		case *errdetails.ErrorInfo:
+			detail := fmt.Sprintf("reason %s (%s)", d.GetReason(), d.GetDomain())
+			for _, k := range slices.Sorted(maps.Keys(d.GetMetadata())) {
+				detail += fmt.Sprintf(" %s=%s", k, d.GetMetadata()[k])
+			}
+			details = append(details, detail)
+		case *errdetails.ResourceInfo:
+			detail := fmt.Sprintf("resource %s %s", d.GetResourceType(), d.GetResourceName())
+			if d.GetDescription() != "" {
+				detail += ": " + d.GetDescription()
+			}
+			details = append(details, detail)
+		case *errdetails.Help:
+			for _, l := range d.GetLinks() {
+				details = append(details, fmt.Sprintf("help: %s %s", l.GetDescription(), l.GetUrl()))
+			}
+		case *errdetails.LocalizedMessage:
+			details = append(details, d.GetMessage())
+		case *errdetails.RetryInfo:
+			details = append(details, fmt.Sprintf("retry after %s", d.GetRetryDelay().AsDuration()))
+		}
+	}
+	return details
+}
+
+// DescribeError formats an Admin API error with its error details, e.g.
+// "failed to update service: rpc error: code = InvalidArgument desc = ...
+// (field spec.template.containers[0].image: Image 'app:v2' not found)",
+// so failure reasons say what was rejected. Errors without details are
+// formatted as is.
+func DescribeError(err error) string {
+	if err == nil {
+		return ""
+	}
+	details := ErrorDetails(err)
+	if len(details) == 0 {
+		return err.Error()
+	}
+	return fmt.Sprintf("%v (%s)", err, strings.Join(details, "; "))
+}
@@ -0,0 +1,126 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestErrorDetails(t *testing.T) {
+	s, err := status.New(codes.InvalidArgument, "invalid service").WithDetails(
+		&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: "spec.template.containers[0].image", Description: "Image 'app:v2' not found"},
+		}},
+		&errdetails.QuotaFailure{Violations: []*errdetails.QuotaFailure_Violation{
+			{Subject: "projects/my-project", Description: "CPU quota exceeded"},
+		}},
+		&errdetails.PreconditionFailure{Violations: []*errdetails.PreconditionFailure_Violation{
+			{Type: "TOS", Subject: "projects/my-project", Description: "Terms of service not accepted"},
+		}},
+		&errdetails.ErrorInfo{Reason: "SERVICE_DISABLED", Domain: "googleapis.com", Metadata: map[string]string{"service": "run.googleapis.com", "consumer": "projects/1"}},
+		&errdetails.ResourceInfo{ResourceType: "run.googleapis.com/Service", ResourceName: "my-service"},
+		&errdetails.Help{Links: []*errdetails.Help_Link{{Description: "Enable the API", Url: "https://console.cloud.google.com"}}},
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(30 * time.Second)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"field spec.template.containers[0].image: Image 'app:v2' not found",
+		"quota projects/my-project: CPU quota exceeded",
+		"precondition TOS projects/my-project: Terms of service not accepted",
+		"reason SERVICE_DISABLED (googleapis.com) consumer=projects/1 service=run.googleapis.com",
+		"resource run.googleapis.com/Service my-service",
+		"help: Enable the API https://console.cloud.google.com",
+		"retry after 30s",
+	}
+	got := ErrorDetails(fmt.Errorf("failed to update service: %w", s.Err()))
+	if len(got) != len(expected) {
+		t.Fatalf("ErrorDetails() = %q, expected %q", got, expected)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("detail %d = %q, expected %q", i, got[i], expected[i])
+		}
+	}
+
+	if got := ErrorDetails(status.Error(codes.NotFound, "not found")); got != nil {
+		t.Errorf("expected no details for a status without details, got %q", got)
+	}
+	if got := ErrorDetails(errors.New("service failed to become ready")); got != nil {
+		t.Errorf("expected no details for a non-status error, got %q", got)
+	}
+}
+
+func TestDescribeError(t *testing.T) {
+	s, err := status.New(codes.InvalidArgument, "invalid service").WithDetails(
+		&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: "spec.template.containers[0].image", Description: "Image 'app:v2' not found"},
+		}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "rpc error: code = InvalidArgument desc = invalid service (field spec.template.containers[0].image: Image 'app:v2' not found)"
+	if got := DescribeError(s.Err()); got != expected {
+		t.Errorf("DescribeError() = %q, expected %q", got, expected)
+	}
+	if got := DescribeError(errors.New("boom")); got != "boom" {
+		t.Errorf("DescribeError() = %q, expected %q", got, "boom")
+	}
+	if got := DescribeError(nil); got != "" {
+		t.Errorf("DescribeError(nil) = %q, expected empty", got)
+	}
+}
+
+func TestIsAlreadyExists(t *testing.T) {
+	if !IsAlreadyExists(fmt.Errorf("failed to update service: %w", status.Error(codes.AlreadyExists, "revision my-service-v2 already exists"))) {
+		t.Error("expected a wrapped ALREADY_EXISTS status to be detected")
+	}
+	if IsAlreadyExists(status.Error(codes.NotFound, "not found")) || IsAlreadyExists(nil) {
+		t.Error("expected other errors not to be detected")
+	}
+}
+
+func TestIsOperationInProgress(t *testing.T) {
+	for _, err := range []error{
+		status.Error(codes.Aborted, "the resource was modified concurrently"),
+		fmt.Errorf("failed to update service: %w", status.Error(codes.FailedPrecondition, "Operation on service my-service is in progress")),
+		status.Error(codes.FailedPrecondition, "Service my-service is still reconciling"),
+	} {
+		if !IsOperationInProgress(err) {
+			t.Errorf("expected %v to be detected", err)
+		}
+	}
+	for _, err := range []error{
+		nil,
+		status.Error(codes.FailedPrecondition, "Cloud Run Admin API has not been used in project 123"),
+		status.Error(codes.InvalidArgument, "operation in progress"),
+	} {
+		if IsOperationInProgress(err) {
+			t.Errorf("expected %v not to be detected", err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"fmt"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"sigs.k8s.io/yaml"
+)
+
+// NormalizeService returns a copy of the live service without output-only
+// fields, so it can be committed back to Git as a manifest.
+func NormalizeService(svc *runpb.Service) *runpb.Service {
+	out := proto.Clone(svc).(*runpb.Service)
+
+	out.Uid = ""
+	out.Generation = 0
+	out.CreateTime = nil
+	out.UpdateTime = nil
+	out.DeleteTime = nil
+	out.ExpireTime = nil
+	out.Creator = ""
+	out.LastModifier = ""
+	out.ObservedGeneration = 0
+	out.TerminalCondition = nil
+	out.Conditions = nil
+	out.LatestReadyRevision = ""
+	out.LatestCreatedRevision = ""
+	out.TrafficStatuses = nil
+	out.Uri = ""
+	out.Urls = nil
+	out.Reconciling = false
+	out.Etag = ""
+	out.SatisfiesPzs = false
+	delete(out.Annotations, LastAppliedAnnotation)
+
+	// Use the short name, as in manifests
+	if name := GetServiceName(out.Name); name != "" {
+		out.Name = name
+	}
+
+	return out
+}
+
+// NormalizeRevision returns a copy of the revision without status fields.
+func NormalizeRevision(rev *runpb.Revision) *runpb.Revision {
+	out := proto.Clone(rev).(*runpb.Revision)
+
+	out.Uid = ""
+	out.Generation = 0
+	out.UpdateTime = nil
+	out.DeleteTime = nil
+	out.ExpireTime = nil
+	out.Reconciling = false
+	out.Conditions = nil
+	out.ObservedGeneration = 0
+	out.LogUri = ""
+	out.Etag = ""
+	out.SatisfiesPzs = false
+
+	out.Name = ShortRevisionName(out.Name)
+
+	return out
+}
+
+// NormalizeJob returns a copy of the live job without output-only fields, so
+// it can be committed back to Git as a manifest.
+func NormalizeJob(job *runpb.Job) *runpb.Job {
+	out := proto.Clone(job).(*runpb.Job)
+
+	out.Uid = ""
+	out.Generation = 0
+	out.CreateTime = nil
+	out.UpdateTime = nil
+	out.DeleteTime = nil
+	out.ExpireTime = nil
+	out.Creator = ""
+	out.LastModifier = ""
+	out.ObservedGeneration = 0
+	out.TerminalCondition = nil
+	out.Conditions = nil
+	out.ExecutionCount = 0
+	out.LatestCreatedExecution = nil
+	out.Reconciling = false
+	out.Etag = ""
+	out.SatisfiesPzs = false
+
+	// Use the short name, as in manifests
+	if _, id, err := ParseJobName(out.Name); err == nil {
+		out.Name = id
+	}
+
+	return out
+}
+
+// MarshalYAML marshals a Cloud Run resource to YAML using the Admin API v2
+// JSON field names.
+func MarshalYAML(m proto.Message) ([]byte, error) {
+	data, err := protojson.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal resource: %w", err)
+	}
+	out, err := yaml.JSONToYAML(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert resource to YAML: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	"google.golang.org/protobuf/proto"
+)
+
+// FaultRevisionName returns the name of a revision deployed for fault injection.
+// Example: "my-service-fault-20250102150405"
+func FaultRevisionName(service string, now time.Time) string {
+	return fmt.Sprintf("%s-fault-%s", service, now.UTC().Format("20060102150405"))
+}
+
+// PinLatestTraffic returns a copy of the traffic targets with targets following
+// the latest revision pinned to the given revision, so deploying a new revision
+// does not move traffic.
+func PinLatestTraffic(traffic []*runpb.TrafficTarget, revision string) []*runpb.TrafficTarget {
+	pinned := make([]*runpb.TrafficTarget, 0, len(traffic))
+	for _, t := range traffic {
+		t = proto.Clone(t).(*runpb.TrafficTarget)
+		if t.Type == runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST {
+			t.Type = runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION
+			t.Revision = ShortRevisionName(revision)
+		}
+		pinned = append(pinned, t)
+	}
+	return pinned
+}
+
+// TagURL returns the URL of the traffic tag, or an empty string if the service
+// does not serve the tag.
+func TagURL(svc *runpb.Service, tag string) string {
+	for _, t := range svc.TrafficStatuses {
+		if t.Tag == tag {
+			return t.Uri
+		}
+	}
+	return ""
+}
+
+// RevisionInTraffic reports whether a traffic target refers to the revision by name.
+func RevisionInTraffic(traffic []*runpb.TrafficTarget, revision string) bool {
+	revision = ShortRevisionName(revision)
+	for _, t := range traffic {
+		if t.Type == runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION && ShortRevisionName(t.Revision) == revision {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"testing"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+)
+
+func TestPinLatestTraffic(t *testing.T) {
+	traffic := []*runpb.TrafficTarget{
+		{
+			Type:    runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST,
+			Percent: 90,
+			Tag:     "candidate",
+		},
+		{
+			Type:     runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION,
+			Revision: "my-service-00001",
+			Percent:  10,
+		},
+	}
+
+	pinned := PinLatestTraffic(traffic, "projects/p/locations/r/services/my-service/revisions/my-service-00002")
+
+	if pinned[0].Type != runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION {
+		t.Errorf("expected latest target to be pinned, got %v", pinned[0].Type)
+	}
+	if pinned[0].Revision != "my-service-00002" || pinned[0].Percent != 90 || pinned[0].Tag != "candidate" {
+		t.Errorf("unexpected pinned target: %v", pinned[0])
+	}
+	if pinned[1].Revision != "my-service-00001" || pinned[1].Percent != 10 {
+		t.Errorf("unexpected revision target: %v", pinned[1])
+	}
+	if traffic[0].Type != runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST {
+		t.Errorf("expected original traffic to be unchanged")
+	}
+}
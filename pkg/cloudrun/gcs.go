@@ -0,0 +1,78 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"google.golang.org/api/option"
+	storage "google.golang.org/api/storage/v1"
+)
+
+// WriteGCSObject writes a Cloud Storage object, replacing it if it exists.
+//
+// If credentialsFile is empty, Application Default Credentials will be used.
+// The credentials need the storage.objects.create permission, and
+// storage.objects.delete to replace objects (e.g. roles/storage.objectUser).
+func WriteGCSObject(ctx context.Context, credentialsFile, bucket, object, contentType string, data []byte, opts ...option.ClientOption) error {
+	service, err := newStorageService(ctx, credentialsFile, opts)
+	if err != nil {
+		return err
+	}
+
+	obj := &storage.Object{Name: object, ContentType: contentType}
+	if _, err := service.Objects.Insert(bucket, obj).Media(bytes.NewReader(data)).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to write gs://%s/%s: %w", bucket, object, err)
+	}
+	return nil
+}
+
+// ListGCSObjects returns the names of the Cloud Storage objects starting
+// with prefix, in lexical order.
+//
+// If credentialsFile is empty, Application Default Credentials will be used.
+// The credentials need the storage.objects.list permission.
+func ListGCSObjects(ctx context.Context, credentialsFile, bucket, prefix string, opts ...option.ClientOption) ([]string, error) {
+	service, err := newStorageService(ctx, credentialsFile, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	err = service.Objects.List(bucket).Prefix(prefix).Fields("items/name", "nextPageToken").Pages(ctx, func(objects *storage.Objects) error {
+		for _, obj := range objects.Items {
+			names = append(names, obj.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list gs://%s/%s: %w", bucket, prefix, err)
+	}
+	return names, nil
+}
+
+// newStorageService creates a Cloud Storage client.
+func newStorageService(ctx context.Context, credentialsFile string, opts []option.ClientOption) (*storage.Service, error) {
+	if credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	}
+	service, err := storage.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage client: %w", err)
+	}
+	return service, nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/option"
+)
+
+func TestWriteGCSObject(t *testing.T) {
+	var gotPath, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"name": "deployments/app/deploy-1.json"}`)
+	}))
+	defer server.Close()
+
+	err := WriteGCSObject(context.Background(), "", "my-bucket", "deployments/app/deploy-1.json", "application/json", []byte(`{"outcome":"SUCCEEDED"}`),
+		option.WithEndpoint(server.URL+"/storage/v1/"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotPath != "/upload/storage/v1/b/my-bucket/o" {
+		t.Errorf("unexpected upload path %q", gotPath)
+	}
+	if !strings.Contains(gotBody, `"name":"deployments/app/deploy-1.json"`) {
+		t.Errorf("expected the object name in the upload, got %q", gotBody)
+	}
+	if !strings.Contains(gotBody, `{"outcome":"SUCCEEDED"}`) {
+		t.Errorf("expected the object data in the upload, got %q", gotBody)
+	}
+}
+
+func TestListGCSObjects(t *testing.T) {
+	var gotPath, gotPrefix string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotPrefix = r.URL.Query().Get("prefix")
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("pageToken") == "" {
+			io.WriteString(w, `{"items": [{"name": "backups/svc/20260101T000000Z.json"}], "nextPageToken": "next"}`)
+			return
+		}
+		io.WriteString(w, `{"items": [{"name": "backups/svc/20260102T000000Z.json"}]}`)
+	}))
+	defer server.Close()
+
+	names, err := ListGCSObjects(context.Background(), "", "my-bucket", "backups/svc/",
+		option.WithEndpoint(server.URL+"/storage/v1/"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotPath != "/storage/v1/b/my-bucket/o" {
+		t.Errorf("unexpected list path %q", gotPath)
+	}
+	if gotPrefix != "backups/svc/" {
+		t.Errorf("unexpected prefix %q", gotPrefix)
+	}
+	want := []string{"backups/svc/20260101T000000Z.json", "backups/svc/20260102T000000Z.json"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("expected %v, got %v", want, names)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	"google.golang.org/api/idtoken"
+	"google.golang.org/api/option"
+)
+
+// HealthCheck defines an HTTP check against a service URL.
+type HealthCheck struct {
+	// URL is the URL to request.
+	URL string
+
+	// ExpectedStatus is the expected HTTP status code.
+	// If zero, any 2xx status is accepted.
+	ExpectedStatus int
+
+	// Timeout is how long to keep retrying before failing.
+	Timeout time.Duration
+
+	// Interval is the delay between attempts.
+	Interval time.Duration
+
+	// Headers are added to each request.
+	Headers map[string]string
+
+	// HTTPClient is the client used for requests.
+	// If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+// CheckHealth requests the URL until it returns the expected status or the
+// timeout expires. It returns the last failure on timeout.
+func CheckHealth(ctx context.Context, hc HealthCheck) error {
+	httpClient := hc.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	interval := hc.Interval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, hc.Timeout)
+	defer cancel()
+	progress := startProgress(ctx, hc.URL+" to be healthy", hc.Timeout)
+
+	var lastErr error
+	for {
+		lastErr = checkOnce(ctx, httpClient, hc)
+		if lastErr == nil {
+			return nil
+		}
+		progress.report("last error: %v", lastErr)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("health check of %s did not pass within %s: %w", hc.URL, hc.Timeout, lastErr)
+		case <-time.After(interval):
+		}
+	}
+}
+
+// checkOnce performs a single health check request.
+func checkOnce(ctx context.Context, httpClient *http.Client, hc HealthCheck) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, hc.URL, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range hc.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if hc.ExpectedStatus != 0 {
+		if resp.StatusCode != hc.ExpectedStatus {
+			return fmt.Errorf("unexpected status %d (expected %d)", resp.StatusCode, hc.ExpectedStatus)
+		}
+		return nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// NewAuthenticatedHTTPClient returns an HTTP client that sends an ID token for
+// the audience, for services that don't allow unauthenticated invocations.
+//
+// If credentialsFile is empty, Application Default Credentials will be used.
+// The credentials need the run.routes.invoke permission (e.g. roles/run.invoker).
+func NewAuthenticatedHTTPClient(ctx context.Context, audience, credentialsFile string) (*http.Client, error) {
+	var opts []option.ClientOption
+	if credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	}
+
+	httpClient, err := idtoken.NewClient(ctx, audience, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create authenticated HTTP client: %w", err)
+	}
+	return httpClient, nil
+}
+
+// VerifyServingRevision checks that the service actually serves 100% of its
+// traffic from the revision, based on the observed traffic statuses.
+func VerifyServingRevision(svc *runpb.Service, revision string) error {
+	var total int32
+	for _, t := range svc.TrafficStatuses {
+		if t.Percent == 0 {
+			continue
+		}
+		if t.Revision != revision {
+			return fmt.Errorf("revision %s still serves %d%% of traffic", t.Revision, t.Percent)
+		}
+		total += t.Percent
+	}
+	if total != 100 {
+		return fmt.Errorf("revision %s serves %d%% of traffic (expected 100%%)", revision, total)
+	}
+	return nil
+}
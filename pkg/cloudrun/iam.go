@@ -0,0 +1,177 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"cloud.google.com/go/iam/apiv1/iampb"
+	"google.golang.org/genproto/googleapis/type/expr"
+)
+
+// InvokerRole is the role allowing to invoke a Cloud Run service.
+const InvokerRole = "roles/run.invoker"
+
+// PublicMembers are the members granting access to anyone on the internet
+// (allUsers), or to anyone with a Google account (allAuthenticatedUsers).
+var PublicMembers = []string{"allUsers", "allAuthenticatedUsers"}
+
+// iamConditionsPolicyVersion is the IAM policy version supporting conditional bindings.
+const iamConditionsPolicyVersion = 3
+
+// IAMCondition is a CEL condition restricting an IAM binding,
+// e.g. `request.time < timestamp("2025-12-31T00:00:00Z")`.
+type IAMCondition struct {
+	Title       string
+	Description string
+	Expression  string
+}
+
+// IAMBinding grants a role to members, optionally under a condition.
+type IAMBinding struct {
+	Members   []string
+	Condition *IAMCondition
+}
+
+// InvokerBindings returns the roles/run.invoker bindings of the policy.
+func InvokerBindings(policy *iampb.Policy) []IAMBinding {
+	var bindings []IAMBinding
+	for _, b := range policy.GetBindings() {
+		if b.Role != InvokerRole {
+			continue
+		}
+		binding := IAMBinding{Members: slices.Clone(b.Members)}
+		if c := b.Condition; c != nil {
+			binding.Condition = &IAMCondition{
+				Title:       c.Title,
+				Description: c.Description,
+				Expression:  c.Expression,
+			}
+		}
+		bindings = append(bindings, binding)
+	}
+	return bindings
+}
+
+// SetInvokerBindings replaces the roles/run.invoker bindings of the policy.
+// Bindings of other roles and the etag are kept. The policy version is raised
+// to 3 if any binding has a condition.
+func SetInvokerBindings(policy *iampb.Policy, bindings []IAMBinding) {
+	kept := make([]*iampb.Binding, 0, len(policy.Bindings)+len(bindings))
+	for _, b := range policy.Bindings {
+		if b.Role != InvokerRole {
+			kept = append(kept, b)
+		}
+	}
+
+	for _, b := range bindings {
+		binding := &iampb.Binding{
+			Role:    InvokerRole,
+			Members: slices.Clone(b.Members),
+		}
+		if c := b.Condition; c != nil {
+			binding.Condition = &expr.Expr{
+				Title:       c.Title,
+				Description: c.Description,
+				Expression:  c.Expression,
+			}
+		}
+		kept = append(kept, binding)
+	}
+	policy.Bindings = kept
+
+	for _, b := range policy.Bindings {
+		if b.Condition != nil {
+			policy.Version = iamConditionsPolicyVersion
+			break
+		}
+	}
+}
+
+// DiffInvokerBindings returns the member grants added ("+ ...") and removed
+// ("- ...") going from the current to the desired bindings, sorted. A changed
+// condition shows as the grant being removed and added.
+func DiffInvokerBindings(current, desired []IAMBinding) []string {
+	currentGrants := invokerGrants(current)
+	desiredGrants := invokerGrants(desired)
+
+	var diff []string
+	for g := range desiredGrants {
+		if !currentGrants[g] {
+			diff = append(diff, "+ "+g)
+		}
+	}
+	for g := range currentGrants {
+		if !desiredGrants[g] {
+			diff = append(diff, "- "+g)
+		}
+	}
+	// Order by grant, so a changed condition is listed next to the old one
+	sort.Slice(diff, func(i, j int) bool {
+		return diff[i][2:] < diff[j][2:]
+	})
+	return diff
+}
+
+// DiffPublicInvokers returns the public members gaining and losing invoker
+// access going from the current to the desired bindings. A member granted
+// under any condition counts as having access.
+func DiffPublicInvokers(current, desired []IAMBinding) (granted, revoked []string) {
+	for _, m := range PublicMembers {
+		had, has := hasMember(current, m), hasMember(desired, m)
+		switch {
+		case has && !had:
+			granted = append(granted, m)
+		case had && !has:
+			revoked = append(revoked, m)
+		}
+	}
+	return granted, revoked
+}
+
+// hasMember reports whether any of the bindings grants the member.
+func hasMember(bindings []IAMBinding, member string) bool {
+	for _, b := range bindings {
+		if slices.Contains(b.Members, member) {
+			return true
+		}
+	}
+	return false
+}
+
+// invokerGrants flattens the bindings to one entry per member and condition.
+func invokerGrants(bindings []IAMBinding) map[string]bool {
+	grants := make(map[string]bool)
+	for _, b := range bindings {
+		for _, m := range b.Members {
+			grants[formatGrant(m, b.Condition)] = true
+		}
+	}
+	return grants
+}
+
+// formatGrant formats a member and its condition, e.g.
+// `allUsers if request.time < timestamp("2025-12-31T00:00:00Z") (temporary-access)`.
+func formatGrant(member string, c *IAMCondition) string {
+	if c == nil {
+		return member
+	}
+	if c.Title == "" {
+		return fmt.Sprintf("%s if %s", member, c.Expression)
+	}
+	return fmt.Sprintf("%s if %s (%s)", member, c.Expression, c.Title)
+}
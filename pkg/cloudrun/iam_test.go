@@ -0,0 +1,78 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/iam/apiv1/iampb"
+)
+
+func TestInvokerBindings(t *testing.T) {
+	policy := &iampb.Policy{
+		Version: 1,
+		Etag:    []byte("etag"),
+		Bindings: []*iampb.Binding{
+			{Role: "roles/run.developer", Members: []string{"group:devs@example.com"}},
+			{Role: InvokerRole, Members: []string{"allUsers", "serviceAccount:caller@p.iam.gserviceaccount.com"}},
+		},
+	}
+	expiry := &IAMCondition{Title: "temporary", Expression: `request.time < timestamp("2025-12-31T00:00:00Z")`}
+	desired := []IAMBinding{
+		{Members: []string{"serviceAccount:caller@p.iam.gserviceaccount.com"}},
+		{Members: []string{"allUsers"}, Condition: expiry},
+	}
+
+	diff := DiffInvokerBindings(InvokerBindings(policy), desired)
+	expected := []string{
+		"- allUsers",
+		`+ allUsers if request.time < timestamp("2025-12-31T00:00:00Z") (temporary)`,
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("expected diff %v, got %v", expected, diff)
+	}
+
+	SetInvokerBindings(policy, desired)
+	if policy.Version != 3 || string(policy.Etag) != "etag" {
+		t.Errorf("expected version 3 with the etag kept, got %d, %q", policy.Version, policy.Etag)
+	}
+	if len(policy.Bindings) != 3 || policy.Bindings[0].Role != "roles/run.developer" {
+		t.Errorf("unexpected bindings: %v", policy.Bindings)
+	}
+	if diff := DiffInvokerBindings(InvokerBindings(policy), desired); len(diff) != 0 {
+		t.Errorf("expected no diff after update, got %v", diff)
+	}
+}
+
+func TestDiffPublicInvokers(t *testing.T) {
+	caller := IAMBinding{Members: []string{"serviceAccount:caller@p.iam.gserviceaccount.com"}}
+	public := IAMBinding{Members: []string{"allUsers"}}
+	authenticated := IAMBinding{
+		Members:   []string{"allAuthenticatedUsers"},
+		Condition: &IAMCondition{Title: "temporary", Expression: `request.time < timestamp("2025-12-31T00:00:00Z")`},
+	}
+
+	granted, revoked := DiffPublicInvokers([]IAMBinding{caller, public}, []IAMBinding{caller, authenticated})
+	if !reflect.DeepEqual(granted, []string{"allAuthenticatedUsers"}) || !reflect.DeepEqual(revoked, []string{"allUsers"}) {
+		t.Errorf("expected allAuthenticatedUsers granted and allUsers revoked, got %v, %v", granted, revoked)
+	}
+
+	// A changed condition doesn't change public access
+	granted, revoked = DiffPublicInvokers([]IAMBinding{public}, []IAMBinding{{Members: []string{"allUsers"}, Condition: authenticated.Condition}})
+	if len(granted) != 0 || len(revoked) != 0 {
+		t.Errorf("expected no public access change, got %v, %v", granted, revoked)
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	monitoring "google.golang.org/api/monitoring/v3"
+	"google.golang.org/api/option"
+)
+
+// InstanceCounter reports the number of running instances of a revision.
+type InstanceCounter interface {
+	// CountInstances returns the current number of instances of the revision.
+	CountInstances(ctx context.Context, project, region, service, revision string) (int64, error)
+}
+
+// instanceCountMetric is the Cloud Monitoring metric for Cloud Run instance counts.
+const instanceCountMetric = "run.googleapis.com/container/instance_count"
+
+// instanceCountWindow is how far back to look for the latest instance count sample.
+// The metric is sampled every 60 seconds.
+const instanceCountWindow = 3 * time.Minute
+
+// monitoringInstanceCounter counts instances using Cloud Monitoring.
+type monitoringInstanceCounter struct {
+	service *monitoring.Service
+}
+
+// NewInstanceCounter creates an InstanceCounter backed by Cloud Monitoring.
+//
+// If credentialsFile is empty, Application Default Credentials will be used.
+// The credentials need the monitoring.timeSeries.list permission
+// (e.g. roles/monitoring.viewer).
+func NewInstanceCounter(ctx context.Context, credentialsFile string) (InstanceCounter, error) {
+	var opts []option.ClientOption
+	if credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	}
+
+	service, err := monitoring.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create monitoring client: %w", err)
+	}
+
+	return &monitoringInstanceCounter{service: service}, nil
+}
+
+// CountInstances returns the sum of the latest instance count samples (active and idle)
+// of the revision.
+func (c *monitoringInstanceCounter) CountInstances(ctx context.Context, project, region, service, revision string) (int64, error) {
+	// The metric is labelled with the short revision name
+	revision = ShortRevisionName(revision)
+
+	filter := fmt.Sprintf(
+		`metric.type=%q AND resource.labels.location=%q AND resource.labels.service_name=%q AND resource.labels.revision_name=%q`,
+		instanceCountMetric, region, service, revision,
+	)
+	end := time.Now()
+	start := end.Add(-instanceCountWindow)
+
+	resp, err := c.service.Projects.TimeSeries.List("projects/" + project).
+		Filter(filter).
+		IntervalStartTime(start.Format(time.RFC3339)).
+		IntervalEndTime(end.Format(time.RFC3339)).
+		Context(ctx).
+		Do()
+	if err != nil {
+		return 0, fmt.Errorf("failed to query instance count: %w", err)
+	}
+
+	var total int64
+	for _, ts := range resp.TimeSeries {
+		// Points are returned newest first
+		if len(ts.Points) == 0 || ts.Points[0].Value == nil || ts.Points[0].Value.Int64Value == nil {
+			continue
+		}
+		total += *ts.Points[0].Value.Int64Value
+	}
+
+	return total, nil
+}
+
+// ReadyInstancesGate defines how long to wait for a revision to have enough
+// ready instances.
+type ReadyInstancesGate struct {
+	// MinInstances is the minimum number of running instances.
+	MinInstances int64
+
+	// Timeout is how long to wait before failing.
+	Timeout time.Duration
+
+	// Interval is the delay between checks.
+	// Default: 15s
+	Interval time.Duration
+}
+
+// WaitForReadyInstances waits until the revision is ready according to the
+// Admin API and has at least gate.MinInstances running instances according to
+// Cloud Monitoring. It returns the last observed instance count.
+func WaitForReadyInstances(
+	ctx context.Context,
+	client Client,
+	counter InstanceCounter,
+	project, region, service, revision string,
+	gate ReadyInstancesGate,
+) (int64, error) {
+	interval := gate.Interval
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+	deadline := time.Now().Add(gate.Timeout)
+	progress := startProgress(ctx, fmt.Sprintf("revision %s to have %d ready instance(s)", revision, gate.MinInstances), gate.Timeout)
+
+	var count int64
+	for {
+		rev, err := client.GetRevision(ctx, project, region, service, revision)
+		if err != nil {
+			return 0, err
+		}
+		ready, err := revisionReady(rev)
+		if err != nil {
+			return 0, err
+		}
+		if ready {
+			count, err = counter.CountInstances(ctx, project, region, service, revision)
+			if err != nil {
+				return 0, err
+			}
+			if count >= gate.MinInstances {
+				return count, nil
+			}
+		}
+
+		quota := QuotaCondition(rev.Conditions...)
+		if quota != nil {
+			progress.report("revision ready: %v, instances: %d, %s", ready, count, formatQuotaCondition(quota))
+		} else {
+			progress.report("revision ready: %v, instances: %d", ready, count)
+		}
+
+		if !time.Now().Add(interval).Before(deadline) {
+			err := fmt.Errorf("revision %s did not become ready within %s", revision, gate.Timeout)
+			if quota != nil {
+				return count, &QuotaError{Resource: "revision " + revision, Message: quota.Message, Err: err}
+			}
+			if !ready {
+				return count, err
+			}
+			return count, fmt.Errorf("revision %s has %d ready instance(s) after %s (expected at least %d)", revision, count, gate.Timeout, gate.MinInstances)
+		}
+
+		select {
+		case <-ctx.Done():
+			return count, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// ScaleDownGate defines how long to wait for revisions to scale to zero.
+type ScaleDownGate struct {
+	// Timeout is how long to wait before failing.
+	Timeout time.Duration
+
+	// Interval is the delay between checks.
+	// Default: 30s
+	Interval time.Duration
+}
+
+// WaitForScaleDown waits until none of the revisions has running instances
+// according to Cloud Monitoring. On timeout, it returns the instance counts
+// of the revisions still running instances.
+func WaitForScaleDown(
+	ctx context.Context,
+	counter InstanceCounter,
+	project, region, service string,
+	revisions []string,
+	gate ScaleDownGate,
+) (map[string]int64, error) {
+	interval := gate.Interval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	deadline := time.Now().Add(gate.Timeout)
+	progress := startProgress(ctx, fmt.Sprintf("revisions %s to scale to zero", strings.Join(revisions, ", ")), gate.Timeout)
+
+	pending := slices.Clone(revisions)
+	for {
+		running := make(map[string]int64)
+		for _, rev := range pending {
+			count, err := counter.CountInstances(ctx, project, region, service, rev)
+			if err != nil {
+				return nil, err
+			}
+			if count > 0 {
+				running[rev] = count
+			}
+		}
+		if len(running) == 0 {
+			return nil, nil
+		}
+		pending = slices.DeleteFunc(pending, func(rev string) bool { return running[rev] == 0 })
+
+		progress.report("instances: %s", formatInstanceCounts(pending, running))
+		if !time.Now().Add(interval).Before(deadline) {
+			return running, fmt.Errorf("revisions still have instances after %s: %s", gate.Timeout, formatInstanceCounts(pending, running))
+		}
+
+		select {
+		case <-ctx.Done():
+			return running, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// formatInstanceCounts formats the instance counts of the revisions, e.g.
+// "app-00001-abc: 2, app-00002-def: 1".
+func formatInstanceCounts(revisions []string, counts map[string]int64) string {
+	parts := make([]string, 0, len(revisions))
+	for _, rev := range revisions {
+		parts = append(parts, fmt.Sprintf("%s: %d", rev, counts[rev]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// revisionReady reports whether the revision's Ready condition succeeded.
+// It returns an error if the revision failed to become ready.
+func revisionReady(rev *runpb.Revision) (bool, error) {
+	for _, cond := range rev.Conditions {
+		if cond.Type != "Ready" {
+			continue
+		}
+		switch cond.State {
+		case runpb.Condition_CONDITION_SUCCEEDED:
+			return true, nil
+		case runpb.Condition_CONDITION_FAILED:
+			if quota := QuotaCondition(rev.Conditions...); quota != nil {
+				return false, &QuotaError{Resource: "revision " + ShortRevisionName(rev.Name), Message: quota.Message}
+			}
+			return false, fmt.Errorf("revision %s failed: %s", ShortRevisionName(rev.Name), cond.Message)
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,159 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+)
+
+// fakeInstanceCounter returns the instance counts of each revision in turn,
+// repeating the last one.
+type fakeInstanceCounter struct {
+	counts map[string][]int64
+	calls  map[string]int
+}
+
+func (c *fakeInstanceCounter) CountInstances(_ context.Context, _, _, _, revision string) (int64, error) {
+	counts := c.counts[revision]
+	if len(counts) == 0 {
+		return 0, nil
+	}
+	count := counts[min(c.calls[revision], len(counts)-1)]
+	c.calls[revision]++
+	return count, nil
+}
+
+func TestWaitForScaleDown(t *testing.T) {
+	ctx := context.Background()
+	gate := ScaleDownGate{Timeout: 50 * time.Millisecond, Interval: time.Millisecond}
+
+	counter := &fakeInstanceCounter{
+		counts: map[string][]int64{"app-00001": {2, 1, 0}, "app-00002": {1, 0}},
+		calls:  map[string]int{},
+	}
+	running, err := WaitForScaleDown(ctx, counter, "p", "r", "app", []string{"app-00001", "app-00002"}, gate)
+	if err != nil || len(running) != 0 {
+		t.Fatalf("expected the revisions to scale to zero, got %v, %v", running, err)
+	}
+	if counter.calls["app-00002"] != 2 {
+		t.Errorf("expected revisions at zero not to be checked again, got %d calls", counter.calls["app-00002"])
+	}
+
+	counter = &fakeInstanceCounter{
+		counts: map[string][]int64{"app-00001": {3}, "app-00002": {0}},
+		calls:  map[string]int{},
+	}
+	running, err = WaitForScaleDown(ctx, counter, "p", "r", "app", []string{"app-00001", "app-00002"}, gate)
+	if err == nil {
+		t.Fatal("expected a timeout error")
+	}
+	if len(running) != 1 || running["app-00001"] != 3 {
+		t.Errorf("expected app-00001 to still run 3 instances, got %v", running)
+	}
+}
+
+// readinessClient is a Client serving a revision with the given Ready
+// condition state.
+type readinessClient struct {
+	Client
+	state runpb.Condition_State
+	calls int
+}
+
+func (c *readinessClient) GetRevision(_ context.Context, _, _, _, revision string) (*runpb.Revision, error) {
+	c.calls++
+	rev := &runpb.Revision{Name: revision}
+	if c.state != runpb.Condition_STATE_UNSPECIFIED {
+		rev.Conditions = []*runpb.Condition{{Type: "Ready", State: c.state, Message: "container failed to start"}}
+	}
+	return rev, nil
+}
+
+func TestWaitForReadyInstances(t *testing.T) {
+	ctx := context.Background()
+	ready := runpb.Condition_CONDITION_SUCCEEDED
+
+	tests := []struct {
+		name      string
+		state     runpb.Condition_State
+		counts    []int64
+		min       int64
+		wantCount int64
+		wantCalls int
+		wantErr   string
+	}{
+		{
+			name:      "minimum reached",
+			state:     ready,
+			counts:    []int64{0, 1, 2},
+			min:       2,
+			wantCount: 2,
+			wantCalls: 3,
+		},
+		{
+			name:      "timeout below minimum",
+			state:     ready,
+			counts:    []int64{1},
+			min:       3,
+			wantCount: 1,
+			wantErr:   "revision app-00002 has 1 ready instance(s) after 20ms (expected at least 3)",
+		},
+		{
+			name:      "minimum zero",
+			state:     ready,
+			min:       0,
+			wantCount: 0,
+			wantCalls: 1,
+		},
+		{
+			name:    "revision never ready",
+			min:     0,
+			wantErr: "revision app-00002 did not become ready within 20ms",
+		},
+		{
+			name:      "revision failed",
+			state:     runpb.Condition_CONDITION_FAILED,
+			min:       1,
+			wantCalls: 1,
+			wantErr:   "revision app-00002 failed: container failed to start",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &readinessClient{state: tt.state}
+			counter := &fakeInstanceCounter{counts: map[string][]int64{"app-00002": tt.counts}, calls: map[string]int{}}
+			gate := ReadyInstancesGate{MinInstances: tt.min, Timeout: 20 * time.Millisecond, Interval: 5 * time.Millisecond}
+
+			count, err := WaitForReadyInstances(ctx, client, counter, "p", "r", "app", "app-00002", gate)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("expected the gate to pass, got %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+			}
+			if count != tt.wantCount {
+				t.Errorf("expected %d instances, got %d", tt.wantCount, count)
+			}
+			if tt.wantCalls > 0 && client.calls != tt.wantCalls {
+				t.Errorf("expected %d checks, got %d", tt.wantCalls, client.calls)
+			}
+		})
+	}
+}
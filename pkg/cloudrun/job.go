@@ -0,0 +1,265 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	run "cloud.google.com/go/run/apiv2"
+	"cloud.google.com/go/run/apiv2/runpb"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// JobName returns projects/{project}/locations/{region}/jobs/{job}.
+func JobName(project, region, job string) string {
+	return fmt.Sprintf("%s/jobs/%s", NewServiceName(project, region, "").LocationName(), job)
+}
+
+// ParseJobName parses a full job resource name into its location and job ID.
+func ParseJobName(name string) (ResourceName, string, error) {
+	i := strings.LastIndex(name, "/jobs/")
+	if i < 0 {
+		return ResourceName{}, "", fmt.Errorf("invalid job name %q", name)
+	}
+	location, err := ParseResourceName(name[:i])
+	if err != nil || location.Service != "" {
+		return ResourceName{}, "", fmt.Errorf("invalid job name %q", name)
+	}
+	job := name[i+len("/jobs/"):]
+	if job == "" || strings.Contains(job, "/") {
+		return ResourceName{}, "", fmt.Errorf("invalid job name %q", name)
+	}
+	return location, job, nil
+}
+
+// ParseJobManifest parses a job manifest (JSON format).
+func ParseJobManifest(data []byte) (*runpb.Job, error) {
+	var job runpb.Job
+	if err := protojson.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("failed to parse job manifest (expected JSON): %w", err)
+	}
+	return &job, nil
+}
+
+// ApplyJobImageOverride overrides the container image in the job's task template.
+func ApplyJobImageOverride(job *runpb.Job, image string) {
+	if image == "" {
+		return
+	}
+	for _, container := range job.GetTemplate().GetTemplate().GetContainers() {
+		container.Image = image
+	}
+}
+
+// ExecutionDone reports whether an execution has finished.
+func ExecutionDone(execution *runpb.Execution) bool {
+	return execution.CompletionTime != nil
+}
+
+// ExecutionSucceeded reports whether a finished execution succeeded, i.e.
+// no task failed or was cancelled.
+func ExecutionSucceeded(execution *runpb.Execution) bool {
+	if execution.FailedCount > 0 || execution.CancelledCount > 0 {
+		return false
+	}
+	for _, cond := range execution.Conditions {
+		if cond.Type == "Completed" {
+			return cond.State == runpb.Condition_CONDITION_SUCCEEDED
+		}
+	}
+	return execution.SucceededCount >= execution.TaskCount
+}
+
+// GetJob retrieves a Cloud Run job by name.
+func (c *client) GetJob(ctx context.Context, project, region, job string) (*runpb.Job, error) {
+	callCtx, cancel := withCallTimeout(ctx, c.timeouts.Get)
+	defer cancel()
+
+	var result *runpb.Job
+	err := c.throttle(callCtx, project, func() error {
+		var err error
+		result, err = c.jobsClient.GetJob(callCtx, &runpb.GetJobRequest{
+			Name: JobName(project, region, job),
+		})
+		return err
+	})
+	return result, wrapCallError(ctx, callCtx, "GetJob", c.timeouts.Get, err)
+}
+
+// CreateOrUpdateJob creates a new job or updates an existing one.
+// Executions started before the update keep running with the previous template.
+func (c *client) CreateOrUpdateJob(ctx context.Context, job *runpb.Job) (*runpb.Job, error) {
+	location, _, err := ParseJobName(job.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	callCtx, cancel := withCallTimeout(ctx, c.timeouts.Update)
+	defer cancel()
+
+	// Updating with allowMissing creates the job if it doesn't exist
+	var op *run.UpdateJobOperation
+	err = c.throttle(callCtx, location.Project, func() error {
+		var err error
+		op, err = c.jobsClient.UpdateJob(callCtx, &runpb.UpdateJobRequest{
+			Job:          job,
+			AllowMissing: true,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update job: %w", wrapCallError(ctx, callCtx, "UpdateJob", c.timeouts.Update, err))
+	}
+	// Wait for operation to complete
+	result, err := op.Wait(callCtx)
+	return result, wrapCallError(ctx, callCtx, "UpdateJob", c.timeouts.Update, err)
+}
+
+// RunJob starts an execution of the job and returns it without waiting for
+// it to finish.
+func (c *client) RunJob(ctx context.Context, project, region, job string) (*runpb.Execution, error) {
+	callCtx, cancel := withCallTimeout(ctx, c.timeouts.Update)
+	defer cancel()
+
+	var op *run.RunJobOperation
+	err := c.throttle(callCtx, project, func() error {
+		var err error
+		op, err = c.jobsClient.RunJob(callCtx, &runpb.RunJobRequest{
+			Name: JobName(project, region, job),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to run job: %w", wrapCallError(ctx, callCtx, "RunJob", c.timeouts.Update, err))
+	}
+
+	// The operation metadata is the execution, available once it is created
+	execution, err := op.Metadata()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get execution: %w", err)
+	}
+	if execution == nil {
+		return nil, fmt.Errorf("job %s started no execution", job)
+	}
+	return execution, nil
+}
+
+// WaitForExecution waits for an execution to finish. It returns the finished
+// execution, and an error if any of its tasks failed.
+func (c *client) WaitForExecution(ctx context.Context, name string) (*runpb.Execution, error) {
+	location, err := ParseResourceName(strings.SplitN(name, "/jobs/", 2)[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid execution name %q: %w", name, err)
+	}
+	progress := startProgress(ctx, fmt.Sprintf("execution %s to finish", name[strings.LastIndex(name, "/")+1:]), 0)
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+			execution, err := c.getExecution(ctx, location.Project, name)
+			if err != nil {
+				return nil, err
+			}
+			if ExecutionDone(execution) {
+				if !ExecutionSucceeded(execution) {
+					return execution, fmt.Errorf("execution failed: %d task(s) succeeded, %d failed, %d cancelled",
+						execution.SucceededCount, execution.FailedCount, execution.CancelledCount)
+				}
+				return execution, nil
+			}
+			progress.report("%d of %d task(s) succeeded, %d running", execution.SucceededCount, execution.TaskCount, execution.RunningCount)
+		}
+	}
+}
+
+// getExecution fetches an execution by its resource name, bounded by the get timeout.
+func (c *client) getExecution(ctx context.Context, project, name string) (*runpb.Execution, error) {
+	callCtx, cancel := withCallTimeout(ctx, c.timeouts.Get)
+	defer cancel()
+
+	var execution *runpb.Execution
+	err := c.throttle(callCtx, project, func() error {
+		var err error
+		execution, err = c.executionsClient.GetExecution(callCtx, &runpb.GetExecutionRequest{
+			Name: name,
+		})
+		return err
+	})
+	return execution, wrapCallError(ctx, callCtx, "GetExecution", c.timeouts.Get, err)
+}
+
+// ListExecutions lists the most recent executions of a job, newest first,
+// up to limit.
+func (c *client) ListExecutions(ctx context.Context, project, region, job string, limit int) ([]*runpb.Execution, error) {
+	callCtx, cancel := withCallTimeout(ctx, c.timeouts.List)
+	defer cancel()
+
+	var executions []*runpb.Execution
+	err := c.throttle(callCtx, project, func() error {
+		// Restart the listing from scratch on retries
+		executions = nil
+		iter := c.executionsClient.ListExecutions(callCtx, &runpb.ListExecutionsRequest{
+			Parent:   JobName(project, region, job),
+			PageSize: int32(limit),
+		})
+		for len(executions) < limit {
+			execution, err := iter.Next()
+			if err != nil {
+				// Check if we've reached the end
+				if err.Error() == "iterator done" {
+					return nil
+				}
+				return err
+			}
+			executions = append(executions, execution)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list executions: %w", wrapCallError(ctx, callCtx, "ListExecutions", c.timeouts.List, err))
+	}
+
+	SortExecutions(executions)
+	return executions, nil
+}
+
+// SortExecutions sorts executions by creation time, newest first.
+func SortExecutions(executions []*runpb.Execution) {
+	sort.SliceStable(executions, func(i, j int) bool {
+		return executions[i].GetCreateTime().AsTime().After(executions[j].GetCreateTime().AsTime())
+	})
+}
+
+// ExecutionDuration returns how long an execution ran, until now if it is
+// still running. It returns 0 if it hasn't started.
+func ExecutionDuration(execution *runpb.Execution, now time.Time) time.Duration {
+	if execution.StartTime == nil {
+		return 0
+	}
+	end := now
+	if execution.CompletionTime != nil {
+		end = execution.CompletionTime.AsTime()
+	}
+	return end.Sub(execution.StartTime.AsTime())
+}
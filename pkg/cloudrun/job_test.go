@@ -0,0 +1,51 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"testing"
+)
+
+func TestParseJobManifest(t *testing.T) {
+	job, err := ParseJobManifest([]byte(`{
+		"name": "projects/my-project/locations/us-central1/jobs/nightly-report",
+		"template": {"template": {"containers": [{"image": "gcr.io/my-project/report:v1"}]}}
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	location, id, err := ParseJobName(job.Name)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location.Project != "my-project" || location.Region != "us-central1" || id != "nightly-report" {
+		t.Errorf("unexpected job name: %+v, %s", location, id)
+	}
+	if got := JobName("my-project", "us-central1", "nightly-report"); got != job.Name {
+		t.Errorf("expected %s, got %s", job.Name, got)
+	}
+
+	ApplyJobImageOverride(job, "gcr.io/my-project/report:v2")
+	if got := job.Template.Template.Containers[0].Image; got != "gcr.io/my-project/report:v2" {
+		t.Errorf("expected overridden image, got %s", got)
+	}
+
+	for _, name := range []string{"nightly-report", "projects/p/locations/r/jobs/", "projects/p/locations/r/services/s/jobs/j"} {
+		if _, _, err := ParseJobName(name); err == nil {
+			t.Errorf("expected error for %q", name)
+		}
+	}
+}
@@ -0,0 +1,455 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package knative
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	"google.golang.org/genproto/googleapis/api"
+)
+
+// ReservedPrefixes are the label and annotation namespaces reserved by Cloud
+// Run. The Admin API v2 rejects them, so their settings are set as fields.
+var ReservedPrefixes = []string{
+	"run.googleapis.com/",
+	"cloud.googleapis.com/",
+	"serving.knative.dev/",
+	"autoscaling.knative.dev/",
+}
+
+// outputAnnotations are reserved annotations set by Cloud Run or gcloud,
+// found in exported manifests, which don't configure the service.
+var outputAnnotations = []string{
+	"run.googleapis.com/client-name",
+	"run.googleapis.com/client-version",
+	"run.googleapis.com/operation-id",
+	"run.googleapis.com/urls",
+	"serving.knative.dev/creator",
+	"serving.knative.dev/lastModifier",
+}
+
+// annotation maps a Cloud Run annotation of T to its Admin API v2 field.
+type annotation[T any] struct {
+	key string
+
+	// set sets the field from the annotation value.
+	set func(T, string) error
+
+	// get returns the annotation value of the field, or an empty string if
+	// the field is unset.
+	get func(T) string
+}
+
+var ingressValues = map[string]runpb.IngressTraffic{
+	"all":                               runpb.IngressTraffic_INGRESS_TRAFFIC_ALL,
+	"internal":                          runpb.IngressTraffic_INGRESS_TRAFFIC_INTERNAL_ONLY,
+	"internal-and-cloud-load-balancing": runpb.IngressTraffic_INGRESS_TRAFFIC_INTERNAL_LOAD_BALANCER,
+}
+
+var executionEnvironmentValues = map[string]runpb.ExecutionEnvironment{
+	"gen1": runpb.ExecutionEnvironment_EXECUTION_ENVIRONMENT_GEN1,
+	"gen2": runpb.ExecutionEnvironment_EXECUTION_ENVIRONMENT_GEN2,
+}
+
+var vpcEgressValues = map[string]runpb.VpcAccess_VpcEgress{
+	"all-traffic":         runpb.VpcAccess_ALL_TRAFFIC,
+	"private-ranges-only": runpb.VpcAccess_PRIVATE_RANGES_ONLY,
+}
+
+// serviceAnnotations are the Cloud Run annotations of services.
+var serviceAnnotations = []annotation[*runpb.Service]{
+	{
+		key: "run.googleapis.com/ingress",
+		set: func(svc *runpb.Service, v string) error {
+			return setEnum(&svc.Ingress, ingressValues, v, "ingress")
+		},
+		get: func(svc *runpb.Service) string { return enumName(ingressValues, svc.Ingress) },
+	},
+	{
+		key: "run.googleapis.com/launch-stage",
+		set: func(svc *runpb.Service, v string) error {
+			stage, ok := api.LaunchStage_value[v]
+			if !ok {
+				return fmt.Errorf("unsupported launch stage %q", v)
+			}
+			svc.LaunchStage = api.LaunchStage(stage)
+			return nil
+		},
+		get: func(svc *runpb.Service) string {
+			if svc.LaunchStage == api.LaunchStage_LAUNCH_STAGE_UNSPECIFIED {
+				return ""
+			}
+			return svc.LaunchStage.String()
+		},
+	},
+	{
+		key: "run.googleapis.com/description",
+		set: func(svc *runpb.Service, v string) error {
+			svc.Description = v
+			return nil
+		},
+		get: func(svc *runpb.Service) string { return svc.Description },
+	},
+	{
+		key: "run.googleapis.com/binary-authorization",
+		set: func(svc *runpb.Service, v string) error {
+			if v != "default" {
+				return fmt.Errorf("only the default policy is supported, got %q", v)
+			}
+			svc.BinaryAuthorization = &runpb.BinaryAuthorization{BinauthzMethod: &runpb.BinaryAuthorization_UseDefault{UseDefault: true}}
+			return nil
+		},
+		get: func(svc *runpb.Service) string {
+			if svc.GetBinaryAuthorization().GetUseDefault() {
+				return "default"
+			}
+			return ""
+		},
+	},
+}
+
+// revisionAnnotations are the Cloud Run annotations of revision templates.
+// Some apply to the containers and volumes, so they are set once those are.
+var revisionAnnotations = []annotation[*runpb.RevisionTemplate]{
+	{
+		key: "autoscaling.knative.dev/minScale",
+		set: func(t *runpb.RevisionTemplate, v string) error {
+			return setInt32(&scaling(t).MinInstanceCount, v)
+		},
+		get: func(t *runpb.RevisionTemplate) string { return formatInt32(t.GetScaling().GetMinInstanceCount()) },
+	},
+	{
+		key: "autoscaling.knative.dev/maxScale",
+		set: func(t *runpb.RevisionTemplate, v string) error {
+			return setInt32(&scaling(t).MaxInstanceCount, v)
+		},
+		get: func(t *runpb.RevisionTemplate) string { return formatInt32(t.GetScaling().GetMaxInstanceCount()) },
+	},
+	{
+		key: "run.googleapis.com/execution-environment",
+		set: func(t *runpb.RevisionTemplate, v string) error {
+			return setEnum(&t.ExecutionEnvironment, executionEnvironmentValues, v, "execution environment")
+		},
+		get: func(t *runpb.RevisionTemplate) string {
+			return enumName(executionEnvironmentValues, t.ExecutionEnvironment)
+		},
+	},
+	{
+		key: "run.googleapis.com/vpc-access-connector",
+		set: func(t *runpb.RevisionTemplate, v string) error {
+			vpcAccess(t).Connector = v
+			return nil
+		},
+		get: func(t *runpb.RevisionTemplate) string { return t.GetVpcAccess().GetConnector() },
+	},
+	{
+		key: "run.googleapis.com/vpc-access-egress",
+		set: func(t *runpb.RevisionTemplate, v string) error {
+			return setEnum(&vpcAccess(t).Egress, vpcEgressValues, v, "VPC egress")
+		},
+		get: func(t *runpb.RevisionTemplate) string {
+			return enumName(vpcEgressValues, t.GetVpcAccess().GetEgress())
+		},
+	},
+	{
+		// Knative mounts the instances at /cloudsql of the first container
+		key: "run.googleapis.com/cloudsql-instances",
+		set: func(t *runpb.RevisionTemplate, v string) error {
+			if len(t.Containers) == 0 {
+				return fmt.Errorf("no container to mount the instances in")
+			}
+			var instances []string
+			for _, instance := range strings.Split(v, ",") {
+				if instance = strings.TrimSpace(instance); instance != "" {
+					instances = append(instances, instance)
+				}
+			}
+			t.Volumes = append(t.Volumes, &runpb.Volume{
+				Name:       cloudSQLVolume,
+				VolumeType: &runpb.Volume_CloudSqlInstance{CloudSqlInstance: &runpb.CloudSqlInstance{Instances: instances}},
+			})
+			c := t.Containers[0]
+			c.VolumeMounts = append(c.VolumeMounts, &runpb.VolumeMount{Name: cloudSQLVolume, MountPath: "/cloudsql"})
+			return nil
+		},
+		get: func(t *runpb.RevisionTemplate) string {
+			var instances []string
+			for _, v := range t.GetVolumes() {
+				instances = append(instances, v.GetCloudSqlInstance().GetInstances()...)
+			}
+			return strings.Join(instances, ",")
+		},
+	},
+	{
+		// A JSON object of the dependsOn of each container, by name
+		key: "run.googleapis.com/container-dependencies",
+		set: func(t *runpb.RevisionTemplate, v string) error {
+			var deps map[string][]string
+			if err := json.Unmarshal([]byte(v), &deps); err != nil {
+				return fmt.Errorf("invalid container dependencies %q: expected a JSON object of container names to lists of container names", v)
+			}
+			for _, name := range slices.Sorted(maps.Keys(deps)) {
+				i := slices.IndexFunc(t.Containers, func(c *runpb.Container) bool { return c.Name == name })
+				if i < 0 {
+					return fmt.Errorf("unknown container %q", name)
+				}
+				t.Containers[i].DependsOn = deps[name]
+			}
+			return nil
+		},
+		get: func(t *runpb.RevisionTemplate) string {
+			deps := make(map[string][]string)
+			for _, c := range t.GetContainers() {
+				if len(c.DependsOn) > 0 {
+					deps[c.Name] = c.DependsOn
+				}
+			}
+			if len(deps) == 0 {
+				return ""
+			}
+			data, _ := json.Marshal(deps)
+			return string(data)
+		},
+	},
+	{
+		// Knative throttles the CPU outside of requests by default
+		key: "run.googleapis.com/cpu-throttling",
+		set: func(t *runpb.RevisionTemplate, v string) error {
+			return setResources(t, v, func(r *runpb.ResourceRequirements, b bool) { r.CpuIdle = b })
+		},
+		get: func(t *runpb.RevisionTemplate) string {
+			return getResources(t, true, func(r *runpb.ResourceRequirements) bool { return r.CpuIdle })
+		},
+	},
+	{
+		key: "run.googleapis.com/startup-cpu-boost",
+		set: func(t *runpb.RevisionTemplate, v string) error {
+			return setResources(t, v, func(r *runpb.ResourceRequirements, b bool) { r.StartupCpuBoost = b })
+		},
+		get: func(t *runpb.RevisionTemplate) string {
+			return getResources(t, false, func(r *runpb.ResourceRequirements) bool { return r.StartupCpuBoost })
+		},
+	},
+	{
+		key: "run.googleapis.com/session-affinity",
+		set: func(t *runpb.RevisionTemplate, v string) error {
+			return setBool(&t.SessionAffinity, v)
+		},
+		get: func(t *runpb.RevisionTemplate) string {
+			if !t.SessionAffinity {
+				return ""
+			}
+			return "true"
+		},
+	},
+	{
+		// Older spelling of session-affinity, only read
+		key: "run.googleapis.com/sessionAffinity",
+		set: func(t *runpb.RevisionTemplate, v string) error {
+			return setBool(&t.SessionAffinity, v)
+		},
+		get: func(*runpb.RevisionTemplate) string { return "" },
+	},
+	{
+		key: "run.googleapis.com/encryption-key",
+		set: func(t *runpb.RevisionTemplate, v string) error {
+			t.EncryptionKey = v
+			return nil
+		},
+		get: func(t *runpb.RevisionTemplate) string { return t.EncryptionKey },
+	},
+}
+
+// cloudSQLVolume is the volume of the Cloud SQL instances of a revision.
+const cloudSQLVolume = "cloudsql"
+
+// ApplyAnnotations sets the Admin API v2 fields of the Cloud Run annotations
+// of the service and its revision template, and removes the annotations.
+// Annotations Cloud Run sets in exported manifests are removed, and other
+// reserved annotations are kept and fail as unsupported. All annotations are
+// converted even if some fail.
+func ApplyAnnotations(svc *runpb.Service) error {
+	errs := []error{applyAnnotations(svc, svc.Annotations, serviceAnnotations)}
+	if svc.Template != nil {
+		errs = append(errs, ApplyRevisionAnnotations(svc.Template))
+	}
+	return errors.Join(errs...)
+}
+
+// ApplyRevisionAnnotations sets the Admin API v2 fields of the Cloud Run
+// annotations of the revision template, as ApplyAnnotations.
+func ApplyRevisionAnnotations(tmpl *runpb.RevisionTemplate) error {
+	return applyAnnotations(tmpl, tmpl.Annotations, revisionAnnotations)
+}
+
+func applyAnnotations[T any](obj T, annotations map[string]string, known []annotation[T]) error {
+	var errs []error
+	for _, key := range slices.Sorted(maps.Keys(annotations)) {
+		value := annotations[key]
+		i := slices.IndexFunc(known, func(a annotation[T]) bool { return a.key == key })
+		switch {
+		case i >= 0:
+			if err := known[i].set(obj, value); err != nil {
+				errs = append(errs, fmt.Errorf("annotation %s: %w", key, err))
+				continue
+			}
+		case slices.Contains(outputAnnotations, key):
+		case IsReserved(key):
+			errs = append(errs, fmt.Errorf("annotation %s: unsupported annotation, set the Admin API v2 field instead", key))
+			continue
+		default:
+			continue
+		}
+		delete(annotations, key)
+	}
+	return errors.Join(errs...)
+}
+
+// Annotations returns the Cloud Run annotations equivalent to the Admin API
+// v2 fields of the service and of its revision template, e.g.
+// autoscaling.knative.dev/maxScale for the maximum instance count. Unset
+// fields have no annotation.
+func Annotations(svc *runpb.Service) (service, template map[string]string) {
+	return annotationsOf(svc, serviceAnnotations), annotationsOf(svc.GetTemplate(), revisionAnnotations)
+}
+
+func annotationsOf[T any](obj T, known []annotation[T]) map[string]string {
+	annotations := make(map[string]string)
+	for _, a := range known {
+		if v := a.get(obj); v != "" {
+			annotations[a.key] = v
+		}
+	}
+	return annotations
+}
+
+// IsReserved reports whether the label or annotation key is in a namespace
+// reserved by Cloud Run.
+func IsReserved(key string) bool {
+	for _, prefix := range ReservedPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// userLabels returns the labels without those reserved by Cloud Run, e.g.
+// cloud.googleapis.com/location in exported manifests.
+func userLabels(labels map[string]string) map[string]string {
+	var out map[string]string
+	for k, v := range labels {
+		if IsReserved(k) {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]string)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+func scaling(t *runpb.RevisionTemplate) *runpb.RevisionScaling {
+	if t.Scaling == nil {
+		t.Scaling = &runpb.RevisionScaling{}
+	}
+	return t.Scaling
+}
+
+func vpcAccess(t *runpb.RevisionTemplate) *runpb.VpcAccess {
+	if t.VpcAccess == nil {
+		t.VpcAccess = &runpb.VpcAccess{}
+	}
+	return t.VpcAccess
+}
+
+// setResources sets a boolean resource setting of every container.
+func setResources(t *runpb.RevisionTemplate, v string, set func(*runpb.ResourceRequirements, bool)) error {
+	var b bool
+	if err := setBool(&b, v); err != nil {
+		return err
+	}
+	for _, c := range t.Containers {
+		if c.Resources == nil {
+			c.Resources = &runpb.ResourceRequirements{CpuIdle: true}
+		}
+		set(c.Resources, b)
+	}
+	return nil
+}
+
+// getResources returns a boolean resource setting of the first container, or
+// an empty string if it is the Knative default.
+func getResources(t *runpb.RevisionTemplate, def bool, get func(*runpb.ResourceRequirements) bool) string {
+	containers := t.GetContainers()
+	if len(containers) == 0 || containers[0].Resources == nil {
+		return ""
+	}
+	if v := get(containers[0].Resources); v != def {
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+func setEnum[E comparable](field *E, values map[string]E, v, name string) error {
+	e, ok := values[v]
+	if !ok {
+		return fmt.Errorf("unsupported %s %q", name, v)
+	}
+	*field = e
+	return nil
+}
+
+func enumName[E comparable](values map[string]E, e E) string {
+	for name, value := range values {
+		if value == e {
+			return name
+		}
+	}
+	return ""
+}
+
+func setInt32(field *int32, v string) error {
+	n, err := strconv.ParseInt(v, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid instance count %q", v)
+	}
+	*field = int32(n)
+	return nil
+}
+
+func formatInt32(n int32) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(int(n))
+}
+
+func setBool(field *bool, v string) error {
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("invalid boolean %q", v)
+	}
+	*field = b
+	return nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package knative
+
+import (
+	"maps"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+)
+
+func TestAnnotationsRoundTrip(t *testing.T) {
+	service := map[string]string{
+		"run.googleapis.com/ingress":              "internal",
+		"run.googleapis.com/launch-stage":         "BETA",
+		"run.googleapis.com/binary-authorization": "default",
+	}
+	template := map[string]string{
+		"autoscaling.knative.dev/minScale":          "1",
+		"autoscaling.knative.dev/maxScale":          "10",
+		"run.googleapis.com/vpc-access-connector":   "my-connector",
+		"run.googleapis.com/vpc-access-egress":      "all-traffic",
+		"run.googleapis.com/cloudsql-instances":     "my-project:us-central1:db",
+		"run.googleapis.com/cpu-throttling":         "false",
+		"run.googleapis.com/execution-environment":  "gen2",
+		"run.googleapis.com/container-dependencies": `{"app":["otel"]}`,
+	}
+	svc := &runpb.Service{
+		Annotations: maps.Clone(service),
+		Template: &runpb.RevisionTemplate{
+			Annotations: maps.Clone(template),
+			Containers:  []*runpb.Container{{Name: "app", Image: "app"}, {Name: "otel", Image: "otel"}},
+		},
+	}
+	svc.Template.Annotations["owner"] = "payments"
+
+	if err := ApplyAnnotations(svc); err != nil {
+		t.Fatal(err)
+	}
+	if len(svc.Annotations) != 0 || len(svc.Template.Annotations) != 1 || svc.Template.Annotations["owner"] != "payments" {
+		t.Errorf("expected only the user annotations to be kept, got %v and %v", svc.Annotations, svc.Template.Annotations)
+	}
+	if svc.Template.Scaling.GetMaxInstanceCount() != 10 || svc.Template.VpcAccess.GetConnector() != "my-connector" || svc.Template.Containers[0].Resources.GetCpuIdle() {
+		t.Errorf("unexpected template: %v", svc.Template)
+	}
+
+	gotService, gotTemplate := Annotations(svc)
+	if !maps.Equal(gotService, service) {
+		t.Errorf("expected service annotations %v, got %v", service, gotService)
+	}
+	if !maps.Equal(gotTemplate, template) {
+		t.Errorf("expected template annotations %v, got %v", template, gotTemplate)
+	}
+}
+
+func TestApplyAnnotationsErrors(t *testing.T) {
+	tmpl := &runpb.RevisionTemplate{Annotations: map[string]string{
+		"autoscaling.knative.dev/maxScale":                 "many",
+		"autoscaling.knative.dev/minScale":                 "2",
+		"run.googleapis.com/client-name":                   "gcloud",
+		"run.googleapis.com/gpu-zonal-redundancy-disabled": "true",
+	}}
+
+	// All annotations are converted even if some fail
+	err := ApplyRevisionAnnotations(tmpl)
+	for _, want := range []string{"autoscaling.knative.dev/maxScale", "run.googleapis.com/gpu-zonal-redundancy-disabled"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected an error for %s, got %v", want, err)
+		}
+	}
+	if tmpl.Scaling.GetMinInstanceCount() != 2 {
+		t.Errorf("expected the minimum instance count to be set, got %v", tmpl.Scaling)
+	}
+	if _, ok := tmpl.Annotations["run.googleapis.com/client-name"]; ok || len(tmpl.Annotations) != 2 {
+		t.Errorf("expected the failed annotations to be kept, got %v", tmpl.Annotations)
+	}
+}
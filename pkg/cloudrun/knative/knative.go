@@ -0,0 +1,368 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package knative converts between Knative serving.knative.dev/v1 services,
+// the format gcloud exports and most Cloud Run repositories keep, and the
+// Cloud Run Admin API v2 services the plugin deploys.
+//
+// Cloud Run settings are annotations in Knative services, e.g.
+// autoscaling.knative.dev/maxScale, and fields in the Admin API v2, e.g.
+// template.scaling.maxInstanceCount. The conversion maps each in both
+// directions, so settings compare equal whichever way they were declared.
+package knative
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"sigs.k8s.io/yaml"
+)
+
+// APIVersion is the API version of Knative service manifests, as
+// exported by `gcloud run services describe --format export`.
+const APIVersion = "serving.knative.dev/v1"
+
+// typeMeta is the part of a manifest identifying its schema.
+type typeMeta struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+}
+
+// serviceManifest is a serving.knative.dev/v1 Service, with the fields Cloud
+// Run supports. Fields set by the server in exported manifests are accepted
+// and ignored.
+type serviceManifest struct {
+	APIVersion string      `json:"apiVersion"`
+	Kind       string      `json:"kind"`
+	Metadata   objectMeta  `json:"metadata"`
+	Spec       serviceSpec `json:"spec"`
+	Status     any         `json:"status,omitempty"`
+}
+
+type objectMeta struct {
+	Name        string            `json:"name,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Set by the server
+	Namespace         string `json:"namespace,omitempty"`
+	UID               string `json:"uid,omitempty"`
+	Generation        int64  `json:"generation,omitempty"`
+	ResourceVersion   string `json:"resourceVersion,omitempty"`
+	SelfLink          string `json:"selfLink,omitempty"`
+	CreationTimestamp string `json:"creationTimestamp,omitempty"`
+}
+
+type serviceSpec struct {
+	Template revisionTemplateSpec `json:"template"`
+	Traffic  []trafficTargetSpec  `json:"traffic,omitempty"`
+}
+
+type revisionTemplateSpec struct {
+	Metadata objectMeta   `json:"metadata"`
+	Spec     revisionSpec `json:"spec"`
+}
+
+type revisionSpec struct {
+	ContainerConcurrency *int32          `json:"containerConcurrency,omitempty"`
+	TimeoutSeconds       *int64          `json:"timeoutSeconds,omitempty"`
+	ServiceAccountName   string          `json:"serviceAccountName,omitempty"`
+	Containers           []containerSpec `json:"containers"`
+	Volumes              []volumeSpec    `json:"volumes,omitempty"`
+}
+
+type containerSpec struct {
+	Name         string            `json:"name,omitempty"`
+	Image        string            `json:"image"`
+	Command      []string          `json:"command,omitempty"`
+	Args         []string          `json:"args,omitempty"`
+	WorkingDir   string            `json:"workingDir,omitempty"`
+	Env          []envVarSpec      `json:"env,omitempty"`
+	Ports        []portSpec        `json:"ports,omitempty"`
+	Resources    resourcesSpec     `json:"resources,omitempty"`
+	VolumeMounts []volumeMountSpec `json:"volumeMounts,omitempty"`
+	StartupProbe *probeSpec        `json:"startupProbe,omitempty"`
+	Liveness     *probeSpec        `json:"livenessProbe,omitempty"`
+}
+
+type envVarSpec struct {
+	Name      string `json:"name"`
+	Value     string `json:"value,omitempty"`
+	ValueFrom *struct {
+		// SecretKeyRef is a Secret Manager secret, with its version as key
+		SecretKeyRef *struct {
+			Name string `json:"name"`
+			Key  string `json:"key"`
+		} `json:"secretKeyRef,omitempty"`
+	} `json:"valueFrom,omitempty"`
+}
+
+type portSpec struct {
+	Name          string `json:"name,omitempty"`
+	ContainerPort int32  `json:"containerPort"`
+}
+
+type resourcesSpec struct {
+	Limits map[string]string `json:"limits,omitempty"`
+}
+
+type volumeMountSpec struct {
+	Name      string `json:"name"`
+	MountPath string `json:"mountPath"`
+}
+
+type probeSpec struct {
+	InitialDelaySeconds int32 `json:"initialDelaySeconds,omitempty"`
+	TimeoutSeconds      int32 `json:"timeoutSeconds,omitempty"`
+	PeriodSeconds       int32 `json:"periodSeconds,omitempty"`
+	FailureThreshold    int32 `json:"failureThreshold,omitempty"`
+	HTTPGet             *struct {
+		Path        string `json:"path,omitempty"`
+		Port        int32  `json:"port,omitempty"`
+		HTTPHeaders []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"httpHeaders,omitempty"`
+	} `json:"httpGet,omitempty"`
+	TCPSocket *struct {
+		Port int32 `json:"port,omitempty"`
+	} `json:"tcpSocket,omitempty"`
+	GRPC *struct {
+		Port    int32  `json:"port,omitempty"`
+		Service string `json:"service,omitempty"`
+	} `json:"grpc,omitempty"`
+}
+
+type volumeSpec struct {
+	Name   string `json:"name"`
+	Secret *struct {
+		// SecretName is a Secret Manager secret, and each item key a version
+		SecretName string `json:"secretName"`
+		Items      []struct {
+			Key  string `json:"key"`
+			Path string `json:"path"`
+		} `json:"items,omitempty"`
+	} `json:"secret,omitempty"`
+	EmptyDir *struct {
+		Medium    string `json:"medium,omitempty"`
+		SizeLimit string `json:"sizeLimit,omitempty"`
+	} `json:"emptyDir,omitempty"`
+}
+
+type trafficTargetSpec struct {
+	RevisionName   string `json:"revisionName,omitempty"`
+	LatestRevision *bool  `json:"latestRevision,omitempty"`
+	Percent        int32  `json:"percent,omitempty"`
+	Tag            string `json:"tag,omitempty"`
+}
+
+// IsManifest reports whether the manifest (JSON or YAML) is a Knative
+// service manifest rather than an Admin API v2 service.
+func IsManifest(data []byte) bool {
+	var m typeMeta
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return false
+	}
+	return strings.HasPrefix(m.APIVersion, "serving.knative.dev/")
+}
+
+// ParseService converts a serving.knative.dev/v1 Service manifest to
+// an Admin API v2 service. Cloud Run annotations are converted to their
+// fields, e.g. autoscaling.knative.dev/maxScale to the maximum instance
+// count. Fields and annotations without an Admin API v2 equivalent fail
+// the conversion instead of being dropped.
+func ParseService(data []byte) (*runpb.Service, error) {
+	var ks serviceManifest
+	if err := yaml.UnmarshalStrict(data, &ks); err != nil {
+		return nil, fmt.Errorf("invalid Knative service: %w", err)
+	}
+	if ks.APIVersion != APIVersion || ks.Kind != "Service" {
+		return nil, fmt.Errorf("unsupported Knative resource %s %s, expected %s Service", ks.APIVersion, ks.Kind, APIVersion)
+	}
+
+	svc := &runpb.Service{
+		Name:        ks.Metadata.Name,
+		Labels:      userLabels(ks.Metadata.Labels),
+		Annotations: maps.Clone(ks.Metadata.Annotations),
+	}
+	if err := applyAnnotations(svc, svc.Annotations, serviceAnnotations); err != nil {
+		return nil, fmt.Errorf("metadata: %w", err)
+	}
+
+	template, err := revisionTemplateToV2(ks.Spec.Template)
+	if err != nil {
+		return nil, fmt.Errorf("spec.template.%w", err)
+	}
+	svc.Template = template
+
+	for i, t := range ks.Spec.Traffic {
+		target := &runpb.TrafficTarget{Percent: t.Percent, Tag: t.Tag}
+		switch {
+		case t.LatestRevision != nil && *t.LatestRevision:
+			if t.RevisionName != "" {
+				return nil, fmt.Errorf("spec.traffic[%d]: revisionName can't be set with latestRevision", i)
+			}
+			target.Type = runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST
+		case t.RevisionName != "":
+			target.Type = runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION
+			target.Revision = t.RevisionName
+		default:
+			return nil, fmt.Errorf("spec.traffic[%d]: revisionName or latestRevision is required", i)
+		}
+		svc.Traffic = append(svc.Traffic, target)
+	}
+
+	if len(svc.Annotations) == 0 {
+		svc.Annotations = nil
+	}
+	return svc, nil
+}
+
+// revisionTemplateToV2 converts the revision template. Errors are
+// prefixed with the path of the field below spec.template.
+func revisionTemplateToV2(kt revisionTemplateSpec) (*runpb.RevisionTemplate, error) {
+	template := &runpb.RevisionTemplate{
+		Revision:       kt.Metadata.Name,
+		Labels:         userLabels(kt.Metadata.Labels),
+		Annotations:    maps.Clone(kt.Metadata.Annotations),
+		ServiceAccount: kt.Spec.ServiceAccountName,
+	}
+	if c := kt.Spec.ContainerConcurrency; c != nil {
+		template.MaxInstanceRequestConcurrency = *c
+	}
+	if t := kt.Spec.TimeoutSeconds; t != nil {
+		template.Timeout = durationpb.New(time.Duration(*t) * time.Second)
+	}
+
+	for i, kc := range kt.Spec.Containers {
+		container, err := containerToV2(kc)
+		if err != nil {
+			return nil, fmt.Errorf("spec.containers[%d].%w", i, err)
+		}
+		template.Containers = append(template.Containers, container)
+	}
+	for i, kv := range kt.Spec.Volumes {
+		volume, err := volumeToV2(kv)
+		if err != nil {
+			return nil, fmt.Errorf("spec.volumes[%d]: %w", i, err)
+		}
+		template.Volumes = append(template.Volumes, volume)
+	}
+
+	// Annotations last, as some apply to the containers and volumes
+	if err := ApplyRevisionAnnotations(template); err != nil {
+		return nil, fmt.Errorf("metadata: %w", err)
+	}
+	if len(template.Annotations) == 0 {
+		template.Annotations = nil
+	}
+	return template, nil
+}
+
+func containerToV2(kc containerSpec) (*runpb.Container, error) {
+	container := &runpb.Container{
+		Name:       kc.Name,
+		Image:      kc.Image,
+		Command:    kc.Command,
+		Args:       kc.Args,
+		WorkingDir: kc.WorkingDir,
+	}
+	for i, e := range kc.Env {
+		env := &runpb.EnvVar{Name: e.Name}
+		switch {
+		case e.ValueFrom != nil && e.ValueFrom.SecretKeyRef != nil:
+			env.Values = &runpb.EnvVar_ValueSource{ValueSource: &runpb.EnvVarSource{
+				SecretKeyRef: &runpb.SecretKeySelector{Secret: e.ValueFrom.SecretKeyRef.Name, Version: e.ValueFrom.SecretKeyRef.Key},
+			}}
+		case e.ValueFrom != nil:
+			return nil, fmt.Errorf("env[%d].valueFrom: only secretKeyRef is supported", i)
+		default:
+			env.Values = &runpb.EnvVar_Value{Value: e.Value}
+		}
+		container.Env = append(container.Env, env)
+	}
+	for _, p := range kc.Ports {
+		container.Ports = append(container.Ports, &runpb.ContainerPort{Name: p.Name, ContainerPort: p.ContainerPort})
+	}
+	if len(kc.Resources.Limits) > 0 {
+		// Knative throttles the CPU outside of requests by default
+		container.Resources = &runpb.ResourceRequirements{Limits: kc.Resources.Limits, CpuIdle: true}
+	}
+	for _, m := range kc.VolumeMounts {
+		container.VolumeMounts = append(container.VolumeMounts, &runpb.VolumeMount{Name: m.Name, MountPath: m.MountPath})
+	}
+
+	var err error
+	if container.StartupProbe, err = probeToV2(kc.StartupProbe); err != nil {
+		return nil, fmt.Errorf("startupProbe: %w", err)
+	}
+	if container.LivenessProbe, err = probeToV2(kc.Liveness); err != nil {
+		return nil, fmt.Errorf("livenessProbe: %w", err)
+	}
+	return container, nil
+}
+
+func probeToV2(kp *probeSpec) (*runpb.Probe, error) {
+	if kp == nil {
+		return nil, nil
+	}
+	probe := &runpb.Probe{
+		InitialDelaySeconds: kp.InitialDelaySeconds,
+		TimeoutSeconds:      kp.TimeoutSeconds,
+		PeriodSeconds:       kp.PeriodSeconds,
+		FailureThreshold:    kp.FailureThreshold,
+	}
+	switch {
+	case kp.HTTPGet != nil:
+		action := &runpb.HTTPGetAction{Path: kp.HTTPGet.Path, Port: kp.HTTPGet.Port}
+		for _, h := range kp.HTTPGet.HTTPHeaders {
+			action.HttpHeaders = append(action.HttpHeaders, &runpb.HTTPHeader{Name: h.Name, Value: h.Value})
+		}
+		probe.ProbeType = &runpb.Probe_HttpGet{HttpGet: action}
+	case kp.TCPSocket != nil:
+		probe.ProbeType = &runpb.Probe_TcpSocket{TcpSocket: &runpb.TCPSocketAction{Port: kp.TCPSocket.Port}}
+	case kp.GRPC != nil:
+		probe.ProbeType = &runpb.Probe_Grpc{Grpc: &runpb.GRPCAction{Port: kp.GRPC.Port, Service: kp.GRPC.Service}}
+	default:
+		return nil, fmt.Errorf("httpGet, tcpSocket, or grpc is required")
+	}
+	return probe, nil
+}
+
+func volumeToV2(kv volumeSpec) (*runpb.Volume, error) {
+	volume := &runpb.Volume{Name: kv.Name}
+	switch {
+	case kv.Secret != nil:
+		source := &runpb.SecretVolumeSource{Secret: kv.Secret.SecretName}
+		for _, item := range kv.Secret.Items {
+			source.Items = append(source.Items, &runpb.VersionToPath{Path: item.Path, Version: item.Key})
+		}
+		volume.VolumeType = &runpb.Volume_Secret{Secret: source}
+	case kv.EmptyDir != nil:
+		if kv.EmptyDir.Medium != "Memory" {
+			return nil, fmt.Errorf("emptyDir medium must be Memory, got %q", kv.EmptyDir.Medium)
+		}
+		volume.VolumeType = &runpb.Volume_EmptyDir{EmptyDir: &runpb.EmptyDirVolumeSource{
+			Medium:    runpb.EmptyDirVolumeSource_MEMORY,
+			SizeLimit: kv.EmptyDir.SizeLimit,
+		}}
+	default:
+		return nil, fmt.Errorf("secret or emptyDir is required")
+	}
+	return volume, nil
+}
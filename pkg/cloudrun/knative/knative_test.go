@@ -0,0 +1,147 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package knative
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+)
+
+func TestParseService(t *testing.T) {
+	svc, err := ParseService([]byte(`
+apiVersion: serving.knative.dev/v1
+kind: Service
+metadata:
+  name: my-service
+  namespace: "123456789"
+  labels:
+    team: payments
+    cloud.googleapis.com/location: us-central1
+  annotations:
+    run.googleapis.com/ingress: internal-and-cloud-load-balancing
+    run.googleapis.com/client-name: gcloud
+spec:
+  template:
+    metadata:
+      name: my-service-v2
+      annotations:
+        autoscaling.knative.dev/minScale: "1"
+        autoscaling.knative.dev/maxScale: "10"
+        run.googleapis.com/execution-environment: gen2
+        run.googleapis.com/cpu-throttling: "false"
+        run.googleapis.com/cloudsql-instances: my-project:us-central1:db
+        owner: payments
+    spec:
+      containerConcurrency: 80
+      timeoutSeconds: 300
+      serviceAccountName: app@my-project.iam.gserviceaccount.com
+      containers:
+        - image: gcr.io/my-project/app:v2
+          ports:
+            - containerPort: 8080
+          env:
+            - name: MODE
+              value: production
+            - name: API_KEY
+              valueFrom:
+                secretKeyRef:
+                  name: api-key
+                  key: latest
+          resources:
+            limits:
+              cpu: 1000m
+              memory: 512Mi
+          startupProbe:
+            httpGet:
+              path: /healthz
+              port: 8080
+  traffic:
+    - latestRevision: true
+      percent: 90
+    - revisionName: my-service-v1
+      percent: 10
+      tag: stable
+status:
+  url: https://my-service-abc.a.run.app
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if svc.Name != "my-service" || svc.Ingress != runpb.IngressTraffic_INGRESS_TRAFFIC_INTERNAL_LOAD_BALANCER {
+		t.Errorf("unexpected service: %s, %s", svc.Name, svc.Ingress)
+	}
+	if len(svc.Labels) != 1 || svc.Labels["team"] != "payments" || len(svc.Annotations) != 0 {
+		t.Errorf("expected only the user labels, got %v and %v", svc.Labels, svc.Annotations)
+	}
+
+	tmpl := svc.Template
+	if tmpl.Revision != "my-service-v2" || tmpl.Scaling.GetMinInstanceCount() != 1 || tmpl.Scaling.GetMaxInstanceCount() != 10 {
+		t.Errorf("unexpected revision or scaling: %s, %v", tmpl.Revision, tmpl.Scaling)
+	}
+	if tmpl.MaxInstanceRequestConcurrency != 80 || tmpl.Timeout.AsDuration() != 5*time.Minute || tmpl.ServiceAccount == "" {
+		t.Errorf("unexpected revision spec: %v", tmpl)
+	}
+	if tmpl.ExecutionEnvironment != runpb.ExecutionEnvironment_EXECUTION_ENVIRONMENT_GEN2 || tmpl.Annotations["owner"] != "payments" || len(tmpl.Annotations) != 1 {
+		t.Errorf("unexpected execution environment or annotations: %s, %v", tmpl.ExecutionEnvironment, tmpl.Annotations)
+	}
+
+	c := tmpl.Containers[0]
+	if c.Image != "gcr.io/my-project/app:v2" || c.Ports[0].ContainerPort != 8080 || c.Resources.Limits["memory"] != "512Mi" || c.Resources.CpuIdle {
+		t.Errorf("unexpected container: %v", c)
+	}
+	if c.Env[0].GetValue() != "production" || c.Env[1].GetValueSource().GetSecretKeyRef().GetSecret() != "api-key" {
+		t.Errorf("unexpected env: %v", c.Env)
+	}
+	if c.StartupProbe.GetHttpGet().GetPath() != "/healthz" {
+		t.Errorf("unexpected startup probe: %v", c.StartupProbe)
+	}
+	if len(tmpl.Volumes) != 1 || tmpl.Volumes[0].GetCloudSqlInstance().GetInstances()[0] != "my-project:us-central1:db" || c.VolumeMounts[0].MountPath != "/cloudsql" {
+		t.Errorf("expected the Cloud SQL instance to be mounted, got %v and %v", tmpl.Volumes, c.VolumeMounts)
+	}
+
+	if len(svc.Traffic) != 2 ||
+		svc.Traffic[0].Type != runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST || svc.Traffic[0].Percent != 90 ||
+		svc.Traffic[1].Revision != "my-service-v1" || svc.Traffic[1].Tag != "stable" {
+		t.Errorf("unexpected traffic: %v", svc.Traffic)
+	}
+}
+
+func TestParseServiceUnsupported(t *testing.T) {
+	for name, tc := range map[string]struct {
+		manifest string
+		err      string
+	}{
+		"unknown field": {
+			manifest: `{"apiVersion": "serving.knative.dev/v1", "kind": "Service", "spec": {"template": {"spec": {"containers": [{"image": "app", "securityContext": {}}]}}}}`,
+			err:      "securityContext",
+		},
+		"reserved annotation": {
+			manifest: `{"apiVersion": "serving.knative.dev/v1", "kind": "Service", "spec": {"template": {"metadata": {"annotations": {"run.googleapis.com/gpu-zonal-redundancy-disabled": "true"}}, "spec": {"containers": [{"image": "app"}]}}}}`,
+			err:      "run.googleapis.com/gpu-zonal-redundancy-disabled",
+		},
+		"other kind": {
+			manifest: `{"apiVersion": "serving.knative.dev/v1", "kind": "Route"}`,
+			err:      "unsupported Knative resource",
+		},
+	} {
+		if _, err := ParseService([]byte(tc.manifest)); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: expected an error containing %q, got %v", name, tc.err, err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"errors"
+	"fmt"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+)
+
+// Cloud Run service constraints, checked before deploying so violations fail
+// in seconds instead of after the Admin API round trip.
+// See https://cloud.google.com/run/docs/configuring/services/memory-limits,
+// https://cloud.google.com/run/docs/configuring/services/cpu, and
+// https://cloud.google.com/run/docs/configuring/request-timeout.
+const (
+	// MaxRequestTimeoutSeconds is the longest request timeout of a service.
+	MaxRequestTimeoutSeconds = 3600
+
+	// MaxConcurrency is the highest maxInstanceRequestConcurrency.
+	MaxConcurrency = 1000
+
+	// MaxInstanceCPU and MaxInstanceMemoryGiB bound the resources of an
+	// instance, summed over its containers.
+	MaxInstanceCPU       = 8
+	MaxInstanceMemoryGiB = 32
+
+	// MinContainerMemoryGiB is the smallest memory limit (128Mi).
+	MinContainerMemoryGiB = 0.125
+
+	// maxFractionalCPUMemoryGiB is the most memory an instance with less than
+	// one CPU can have.
+	maxFractionalCPUMemoryGiB = 0.5
+)
+
+// wholeCPUs are the CPU limits allowed from one CPU up.
+var wholeCPUs = map[float64]bool{1: true, 2: true, 4: true, 6: true, 8: true}
+
+// cpuMemoryLimits are the memory an instance needs at least, and may have at
+// most, with a number of CPUs. Sorted by CPU.
+var cpuMemoryLimits = []struct {
+	CPU       float64
+	MinMemory float64
+	MaxMemory float64
+}{
+	{1, 0, 4},
+	{2, 0, 8},
+	{4, 2, 16},
+	{6, 4, 24},
+	{8, 4, 32},
+}
+
+// ValidateServiceLimits checks the revision template of the service against
+// the Cloud Run limits: request timeout, concurrency, scaling, and the CPU
+// and memory of its containers. Each violation names the offending field,
+// e.g. "template.timeout: 4000s exceeds the maximum of 3600s".
+func ValidateServiceLimits(svc *runpb.Service) error {
+	tmpl := svc.GetTemplate()
+	if tmpl == nil {
+		return nil
+	}
+
+	var errs []error
+	violation := func(field, format string, a ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, a...)))
+	}
+
+	if timeout := tmpl.GetTimeout(); timeout != nil {
+		if d := timeout.AsDuration(); d <= 0 || d.Seconds() > MaxRequestTimeoutSeconds {
+			violation("template.timeout", "%s must be between 1s and %ds", d, MaxRequestTimeoutSeconds)
+		}
+	}
+
+	concurrency := tmpl.GetMaxInstanceRequestConcurrency()
+	if concurrency < 0 || concurrency > MaxConcurrency {
+		violation("template.maxInstanceRequestConcurrency", "%d must be between 1 and %d", concurrency, MaxConcurrency)
+	}
+
+	scaling := tmpl.GetScaling()
+	if scaling.GetMaxInstanceCount() > 0 && scaling.GetMinInstanceCount() > scaling.GetMaxInstanceCount() {
+		violation("template.scaling.minInstanceCount", "%d exceeds maxInstanceCount %d", scaling.GetMinInstanceCount(), scaling.GetMaxInstanceCount())
+	}
+
+	// Resources of the containers, then of the instance
+	var totalCPU, totalMemory float64
+	for i, c := range tmpl.GetContainers() {
+		field := fmt.Sprintf("template.containers[%d].resources.limits", i)
+		limits := c.GetResources().GetLimits()
+		cpuLimit, memoryLimit := limit(limits, "cpu", defaultContainerCPU), limit(limits, "memory", defaultContainerMemory)
+
+		cpu, err := ParseCPU(cpuLimit)
+		if err != nil {
+			violation(field+".cpu", "%v", err)
+		} else if cpu <= 0 || (cpu >= 1 && !wholeCPUs[cpu]) {
+			violation(field+".cpu", "%s must be less than 1, or 1, 2, 4, 6, or 8", cpuLimit)
+		}
+		memory, err := ParseMemoryGiB(memoryLimit)
+		if err != nil {
+			violation(field+".memory", "%v", err)
+		} else if memory < MinContainerMemoryGiB {
+			violation(field+".memory", "%s is below the minimum of 128Mi", memoryLimit)
+		}
+		totalCPU += cpu
+		totalMemory += memory
+	}
+	if len(errs) > 0 || len(tmpl.GetContainers()) == 0 {
+		return errors.Join(errs...)
+	}
+
+	field := "template.containers[0].resources.limits"
+	if len(tmpl.GetContainers()) > 1 {
+		field = "template.containers[*].resources.limits"
+	}
+	switch {
+	case totalCPU > MaxInstanceCPU:
+		violation(field+".cpu", "%s CPUs exceed the maximum of %d per instance", formatFloat(totalCPU), MaxInstanceCPU)
+	case totalMemory > MaxInstanceMemoryGiB:
+		violation(field+".memory", "%sGi exceeds the maximum of %dGi per instance", formatFloat(totalMemory), MaxInstanceMemoryGiB)
+	case totalCPU < 1:
+		if totalMemory > maxFractionalCPUMemoryGiB {
+			violation(field+".memory", "%sGi exceeds the maximum of 512Mi with less than 1 CPU", formatFloat(totalMemory))
+		}
+		if concurrency != 1 {
+			violation("template.maxInstanceRequestConcurrency", "must be set to 1 with less than 1 CPU")
+		}
+	default:
+		for _, l := range cpuMemoryLimits {
+			if totalMemory > l.MaxMemory {
+				continue
+			}
+			if totalCPU < l.CPU {
+				violation(field+".cpu", "%sGi of memory requires at least %s CPUs, got %s", formatFloat(totalMemory), formatFloat(l.CPU), formatFloat(totalCPU))
+			}
+			break
+		}
+		for i := len(cpuMemoryLimits) - 1; i >= 0; i-- {
+			if l := cpuMemoryLimits[i]; totalCPU >= l.CPU {
+				if totalMemory < l.MinMemory {
+					violation(field+".memory", "%s CPUs require at least %sGi of memory, got %sGi", formatFloat(totalCPU), formatFloat(l.MinMemory), formatFloat(totalMemory))
+				}
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
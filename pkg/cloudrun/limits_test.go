@@ -0,0 +1,75 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestValidateServiceLimits(t *testing.T) {
+	service := func(cpu, memory string, concurrency int32, timeout time.Duration) *runpb.Service {
+		tmpl := &runpb.RevisionTemplate{
+			MaxInstanceRequestConcurrency: concurrency,
+			Containers: []*runpb.Container{{
+				Image:     "gcr.io/my-project/app:v1",
+				Resources: &runpb.ResourceRequirements{Limits: map[string]string{"cpu": cpu, "memory": memory}},
+			}},
+		}
+		if timeout != 0 {
+			tmpl.Timeout = durationpb.New(timeout)
+		}
+		return &runpb.Service{Template: tmpl}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		svc     *runpb.Service
+		wantErr string
+	}{
+		{"defaults", &runpb.Service{Template: &runpb.RevisionTemplate{Containers: []*runpb.Container{{}}}}, ""},
+		{"valid", service("2", "4Gi", 80, time.Hour), ""},
+		{"fractional CPU", service("500m", "512Mi", 1, 0), ""},
+		{"timeout too long", service("1", "512Mi", 80, 61*time.Minute), "template.timeout"},
+		{"concurrency too high", service("1", "512Mi", 1001, 0), "template.maxInstanceRequestConcurrency"},
+		{"uneven CPU", service("3", "2Gi", 80, 0), "template.containers[0].resources.limits.cpu: 3 must be"},
+		{"memory too low", service("1", "64Mi", 80, 0), "template.containers[0].resources.limits.memory: 64Mi"},
+		{"memory per CPU minimum", service("4", "1Gi", 80, 0), "4 CPUs require at least 2Gi"},
+		{"CPU per memory minimum", service("2", "16Gi", 80, 0), "16Gi of memory requires at least 4 CPUs"},
+		{"fractional CPU with concurrency", service("500m", "512Mi", 80, 0), "must be set to 1"},
+		{"fractional CPU with too much memory", service("500m", "1Gi", 1, 0), "512Mi with less than 1 CPU"},
+	} {
+		err := ValidateServiceLimits(tc.svc)
+		switch {
+		case tc.wantErr == "" && err != nil:
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+			t.Errorf("%s: expected error containing %q, got %v", tc.name, tc.wantErr, err)
+		}
+	}
+
+	// Instance limits are summed over the containers
+	svc := service("4", "2Gi", 80, 0)
+	svc.Template.Containers = append(svc.Template.Containers, &runpb.Container{
+		Resources: &runpb.ResourceRequirements{Limits: map[string]string{"cpu": "6", "memory": "4Gi"}},
+	})
+	if err := ValidateServiceLimits(svc); err == nil || !strings.Contains(err.Error(), "template.containers[*].resources.limits.cpu: 10 CPUs") {
+		t.Errorf("expected the instance CPU limit to be exceeded, got %v", err)
+	}
+}
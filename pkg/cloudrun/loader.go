@@ -0,0 +1,133 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ManifestLoader evaluates a manifest written in a configuration language
+// into a JSON manifest that ParseServiceManifests can parse. A JSON array is
+// a manifest of several services.
+type ManifestLoader interface {
+	// Load evaluates the manifest source. dir is the directory of the
+	// manifest, to resolve its imports.
+	Load(ctx context.Context, dir string, source []byte) ([]byte, error)
+}
+
+// ManifestLoaderFunc adapts a function to a ManifestLoader.
+type ManifestLoaderFunc func(ctx context.Context, dir string, source []byte) ([]byte, error)
+
+// Load calls f.
+func (f ManifestLoaderFunc) Load(ctx context.Context, dir string, source []byte) ([]byte, error) {
+	return f(ctx, dir, source)
+}
+
+var (
+	loadersMu sync.RWMutex
+	loaders   = map[string]ManifestLoader{
+		".cue":     ManifestLoaderFunc(loadCUE),
+		".jsonnet": ManifestLoaderFunc(loadJsonnet),
+	}
+)
+
+// RegisterManifestLoader registers a loader for the manifests with the file
+// extension ext (e.g. ".cue"), replacing any loader registered for it.
+func RegisterManifestLoader(ext string, l ManifestLoader) {
+	loadersMu.Lock()
+	defer loadersMu.Unlock()
+	loaders[strings.ToLower(ext)] = l
+}
+
+// EvaluateManifest evaluates the source of the manifest at path with the
+// loader registered for its extension. Manifests without a loader, such as
+// YAML and JSON manifests, are returned as is. A JSON array of services is
+// returned as a manifest of several YAML documents.
+func EvaluateManifest(ctx context.Context, path string, source []byte) ([]byte, error) {
+	loadersMu.RLock()
+	l, ok := loaders[strings.ToLower(filepath.Ext(path))]
+	loadersMu.RUnlock()
+	if !ok {
+		return source, nil
+	}
+
+	out, err := l.Load(ctx, filepath.Dir(path), source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate %s: %w", filepath.Base(path), err)
+	}
+	return splitJSONArray(out)
+}
+
+// splitJSONArray returns the items of a JSON array as YAML documents
+// separated by "---". Other JSON values are returned as is.
+func splitJSONArray(data []byte) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	if !bytes.HasPrefix(data, []byte("[")) {
+		return data, nil
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	docs := make([][]byte, 0, len(items))
+	for _, item := range items {
+		docs = append(docs, item)
+	}
+	return bytes.Join(docs, []byte("\n---\n")), nil
+}
+
+// loadCUE evaluates a CUE manifest with the cue command, e.g.
+// "cue export --out json cue: -". The manifest is read from stdin, so
+// imports are resolved from the CUE module containing dir.
+func loadCUE(ctx context.Context, dir string, source []byte) ([]byte, error) {
+	return runEvaluator(ctx, dir, source, "cue", "export", "--out", "json", "cue:", "-")
+}
+
+// loadJsonnet evaluates a Jsonnet manifest with the jsonnet command, e.g.
+// "jsonnet -J <dir> -". Imports are resolved relative to dir.
+func loadJsonnet(ctx context.Context, dir string, source []byte) ([]byte, error) {
+	return runEvaluator(ctx, dir, source, "jsonnet", "-J", dir, "-")
+}
+
+// runEvaluator runs the command in dir with the source on stdin and returns
+// its output.
+func runEvaluator(ctx context.Context, dir string, source []byte, name string, args ...string) ([]byte, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not installed on the piped host: install it, or commit the rendered manifest: %w", name, err)
+	}
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Dir = dir
+	cmd.Stdin = bytes.NewReader(source)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() > 0 {
+			return nil, fmt.Errorf("%s: %s", name, strings.TrimSpace(stderr.String()))
+		}
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return out, nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestEvaluateManifest(t *testing.T) {
+	ctx := context.Background()
+	var gotDir string
+	t.Cleanup(func() {
+		loadersMu.Lock()
+		defer loadersMu.Unlock()
+		delete(loaders, ".test")
+	})
+	RegisterManifestLoader(".test", ManifestLoaderFunc(func(_ context.Context, dir string, source []byte) ([]byte, error) {
+		gotDir = dir
+		names := strings.Fields(string(source))
+		items := make([]string, 0, len(names))
+		for _, name := range names {
+			items = append(items, `{"name": "`+name+`", "template": {"containers": [{"image": "gcr.io/p/app:v1"}]}}`)
+		}
+		return []byte("[" + strings.Join(items, ",") + "]"), nil
+	}))
+
+	out, err := EvaluateManifest(ctx, "/app/cloudrun/services.test", []byte("api worker"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotDir != "/app/cloudrun" {
+		t.Errorf("expected the manifest directory, got %q", gotDir)
+	}
+	services, err := ParseServiceManifests(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 2 || services[0].Name != "api" || services[1].Name != "worker" {
+		t.Errorf("expected services api and worker, got %v", services)
+	}
+
+	yaml := []byte("name: my-service\n")
+	out, err = EvaluateManifest(ctx, "/app/service.yaml", yaml)
+	if err != nil || string(out) != string(yaml) {
+		t.Errorf("expected YAML manifests as is, got %q, %v", out, err)
+	}
+}
+
+func TestEvaluateManifest_MissingCommand(t *testing.T) {
+	t.Setenv("PATH", "")
+	for _, path := range []string{"service.cue", "service.jsonnet"} {
+		_, err := EvaluateManifest(context.Background(), path, []byte("{}"))
+		if err == nil || !strings.Contains(err.Error(), "is not installed on the piped host") {
+			t.Errorf("%s: expected an error about the missing command, got %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"fmt"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// LastAppliedAnnotation is the service annotation recording the service
+// manifest applied by the last deployment, for ThreeWayMerge.
+const LastAppliedAnnotation = "pipecd.dev/last-applied-service"
+
+// SetLastApplied records the service in its LastAppliedAnnotation.
+func SetLastApplied(svc *runpb.Service) error {
+	last := proto.Clone(svc).(*runpb.Service)
+	delete(last.Annotations, LastAppliedAnnotation)
+
+	data, err := protojson.Marshal(last)
+	if err != nil {
+		return fmt.Errorf("failed to encode last applied service: %w", err)
+	}
+	if svc.Annotations == nil {
+		svc.Annotations = make(map[string]string)
+	}
+	svc.Annotations[LastAppliedAnnotation] = string(data)
+	return nil
+}
+
+// LastApplied returns the service recorded in the LastAppliedAnnotation,
+// or nil if none was recorded.
+func LastApplied(svc *runpb.Service) (*runpb.Service, error) {
+	data, ok := svc.Annotations[LastAppliedAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	var last runpb.Service
+	if err := protojson.Unmarshal([]byte(data), &last); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %w", LastAppliedAnnotation, err)
+	}
+	return &last, nil
+}
+
+// ThreeWayMerge builds the service to apply from the last applied, live, and
+// desired services, like kubectl apply:
+//
+//   - Fields set in desired are taken from desired
+//   - Fields set in last but not in desired were removed from the manifest and are cleared
+//   - Other fields are kept from live, e.g. server defaults and fields set outside of PipeCD
+//
+// Messages are merged recursively and maps per key. Lists are replaced as a
+// whole. If last is nil, nothing is cleared.
+//
+// The template's revision name is always taken from desired, since reusing a
+// live revision name would fail.
+func ThreeWayMerge(last, live, desired *runpb.Service) *runpb.Service {
+	merged := proto.Clone(live).(*runpb.Service)
+	var lastMsg protoreflect.Message
+	if last != nil {
+		lastMsg = last.ProtoReflect()
+	}
+	mergeMessage(lastMsg, merged.ProtoReflect(), desired.ProtoReflect())
+
+	if merged.Template != nil {
+		merged.Template.Revision = desired.GetTemplate().GetRevision()
+	}
+	// Don't send the live etag, so the update applies to the latest version
+	merged.Etag = ""
+	return merged
+}
+
+// mergeMessage merges the last applied and desired messages into dst, which
+// holds the live message. last may be nil.
+func mergeMessage(last, dst, desired protoreflect.Message) {
+	fields := dst.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		inDesired := desired.Has(fd)
+		inLast := last != nil && last.IsValid() && last.Has(fd)
+
+		switch {
+		case fd.IsMap():
+			if inDesired || inLast {
+				mergeMap(last, dst, desired, fd)
+			}
+		case fd.IsList():
+			if inDesired {
+				dst.Set(fd, desired.Get(fd))
+			} else if inLast {
+				dst.Clear(fd)
+			}
+		case fd.Message() != nil:
+			switch {
+			case inDesired && dst.Has(fd):
+				var lastField protoreflect.Message
+				if inLast {
+					lastField = last.Get(fd).Message()
+				}
+				mergeMessage(lastField, dst.Mutable(fd).Message(), desired.Get(fd).Message())
+			case inDesired:
+				dst.Set(fd, desired.Get(fd))
+			case inLast:
+				dst.Clear(fd)
+			}
+		default:
+			if inDesired {
+				dst.Set(fd, desired.Get(fd))
+			} else if inLast {
+				dst.Clear(fd)
+			}
+		}
+	}
+}
+
+// mergeMap merges a map field per key.
+func mergeMap(last, dst, desired protoreflect.Message, fd protoreflect.FieldDescriptor) {
+	dstMap := dst.Mutable(fd).Map()
+	desiredMap := desired.Get(fd).Map()
+
+	if last != nil && last.IsValid() && last.Has(fd) {
+		last.Get(fd).Map().Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+			if !desiredMap.Has(k) {
+				dstMap.Clear(k)
+			}
+			return true
+		})
+	}
+	desiredMap.Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+		dstMap.Set(k, v)
+		return true
+	})
+}
@@ -0,0 +1,86 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"testing"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+)
+
+func TestThreeWayMerge(t *testing.T) {
+	last := &runpb.Service{
+		Labels: map[string]string{"team": "payments", "removed": "true"},
+		Template: &runpb.RevisionTemplate{
+			Containers: []*runpb.Container{{Image: "gcr.io/p/app:v1"}},
+		},
+		Description: "old description",
+	}
+	live := &runpb.Service{
+		Name:   "projects/p/locations/r/services/my-service",
+		Labels: map[string]string{"team": "payments", "removed": "true", "cost-center": "42"},
+		Template: &runpb.RevisionTemplate{
+			Revision:                      "my-service-fault",
+			Containers:                    []*runpb.Container{{Image: "gcr.io/p/app:v1"}},
+			MaxInstanceRequestConcurrency: 80,
+			ServiceAccount:                "123-compute@developer.gserviceaccount.com",
+		},
+		Description: "old description",
+		Etag:        "etag",
+	}
+	desired := &runpb.Service{
+		Name:   "projects/p/locations/r/services/my-service",
+		Labels: map[string]string{"team": "payments"},
+		Template: &runpb.RevisionTemplate{
+			Containers: []*runpb.Container{{Image: "gcr.io/p/app:v2"}},
+		},
+	}
+
+	merged := ThreeWayMerge(last, live, desired)
+
+	if merged.Template.Containers[0].Image != "gcr.io/p/app:v2" {
+		t.Errorf("expected desired image, got %s", merged.Template.Containers[0].Image)
+	}
+	if _, ok := merged.Labels["removed"]; ok {
+		t.Error("expected label removed from the manifest to be cleared")
+	}
+	if merged.Labels["cost-center"] != "42" {
+		t.Error("expected label set outside of the manifest to be kept")
+	}
+	if merged.Description != "" {
+		t.Errorf("expected description removed from the manifest to be cleared, got %q", merged.Description)
+	}
+	if merged.Template.MaxInstanceRequestConcurrency != 80 || merged.Template.ServiceAccount == "" {
+		t.Error("expected server defaults to be kept")
+	}
+	if merged.Template.Revision != "" || merged.Etag != "" {
+		t.Errorf("expected revision name and etag not to be reused, got %q, %q", merged.Template.Revision, merged.Etag)
+	}
+	if live.Template.Containers[0].Image != "gcr.io/p/app:v1" {
+		t.Error("expected live service to be unchanged")
+	}
+
+	// Round trip through the annotation
+	if err := SetLastApplied(desired); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	recorded, err := LastApplied(desired)
+	if err != nil || recorded.Template.Containers[0].Image != "gcr.io/p/app:v2" {
+		t.Errorf("unexpected last applied service: %v (%v)", recorded, err)
+	}
+	if _, ok := recorded.Annotations[LastAppliedAnnotation]; ok {
+		t.Error("expected last applied service not to record itself")
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"fmt"
+	"regexp"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	"google.golang.org/protobuf/proto"
+)
+
+// meshNamePattern matches Cloud Service Mesh resource names.
+var meshNamePattern = regexp.MustCompile(`^projects/[^/]+/locations/global/meshes/[^/]+$`)
+
+// ServiceMesh returns the Cloud Service Mesh the service's revisions join,
+// or "" if they don't join a mesh.
+func ServiceMesh(svc *runpb.Service) string {
+	return svc.GetTemplate().GetServiceMesh().GetMesh()
+}
+
+// ValidateServiceMesh checks that the mesh of the service, if any, is a full
+// mesh resource name, e.g. "projects/my-project/locations/global/meshes/my-mesh".
+func ValidateServiceMesh(svc *runpb.Service) error {
+	if mesh := ServiceMesh(svc); mesh != "" && !meshNamePattern.MatchString(mesh) {
+		return fmt.Errorf("invalid service mesh %q: expected projects/PROJECT/locations/global/meshes/MESH", mesh)
+	}
+	return nil
+}
+
+// AppliedServiceMesh returns the mesh the service will join once desired is
+// applied over live with ThreeWayMerge: the mesh of desired if it declares
+// one, none if the last applied manifest declared one (it was removed from
+// the manifest), and the mesh of live otherwise (it is managed outside of
+// PipeCD).
+func AppliedServiceMesh(live, desired *runpb.Service) string {
+	if mesh := ServiceMesh(desired); mesh != "" || live == nil {
+		return mesh
+	}
+	if last, err := LastApplied(live); err == nil && ServiceMesh(last) != "" {
+		return ""
+	}
+	return ServiceMesh(live)
+}
+
+// KeepServiceMesh sets the mesh of desired to the mesh of live (none if live
+// is nil), so applying desired leaves the mesh unchanged.
+func KeepServiceMesh(desired, live *runpb.Service) {
+	if desired.Template == nil {
+		return
+	}
+	desired.Template.ServiceMesh = nil
+	if mesh := live.GetTemplate().GetServiceMesh(); mesh != nil {
+		desired.Template.ServiceMesh = proto.Clone(mesh).(*runpb.ServiceMesh)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"testing"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+)
+
+func meshService(mesh string) *runpb.Service {
+	svc := &runpb.Service{Template: &runpb.RevisionTemplate{}}
+	if mesh != "" {
+		svc.Template.ServiceMesh = &runpb.ServiceMesh{Mesh: mesh}
+	}
+	return svc
+}
+
+func TestValidateServiceMesh(t *testing.T) {
+	tests := []struct {
+		mesh    string
+		wantErr bool
+	}{
+		{mesh: "", wantErr: false},
+		{mesh: "projects/my-project/locations/global/meshes/my-mesh", wantErr: false},
+		{mesh: "my-mesh", wantErr: true},
+		{mesh: "projects/my-project/locations/us-central1/meshes/my-mesh", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mesh, func(t *testing.T) {
+			if err := ValidateServiceMesh(meshService(tt.mesh)); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateServiceMesh() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAppliedServiceMesh(t *testing.T) {
+	const (
+		meshA = "projects/p/locations/global/meshes/a"
+		meshB = "projects/p/locations/global/meshes/b"
+	)
+	withLastApplied := func(svc, last *runpb.Service) *runpb.Service {
+		if err := SetLastApplied(last); err != nil {
+			t.Fatal(err)
+		}
+		svc.Annotations = last.Annotations
+		return svc
+	}
+
+	tests := []struct {
+		name     string
+		live     *runpb.Service
+		desired  *runpb.Service
+		expected string
+	}{
+		{name: "new service", live: nil, desired: meshService(meshA), expected: meshA},
+		{name: "declared", live: meshService(meshA), desired: meshService(meshB), expected: meshB},
+		{name: "managed outside of PipeCD", live: meshService(meshA), desired: meshService(""), expected: meshA},
+		{name: "removed from the manifest", live: withLastApplied(meshService(meshA), meshService(meshA)), desired: meshService(""), expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AppliedServiceMesh(tt.live, tt.desired); got != tt.expected {
+				t.Errorf("AppliedServiceMesh() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestKeepServiceMesh(t *testing.T) {
+	desired := meshService("projects/p/locations/global/meshes/b")
+	KeepServiceMesh(desired, meshService("projects/p/locations/global/meshes/a"))
+	if got := ServiceMesh(desired); got != "projects/p/locations/global/meshes/a" {
+		t.Errorf("expected the live mesh to be kept, got %q", got)
+	}
+
+	KeepServiceMesh(desired, nil)
+	if got := ServiceMesh(desired); got != "" {
+		t.Errorf("expected no mesh for a new service, got %q", got)
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/api/option"
+)
+
+// MirrorRequest is a request replayed against the stable and candidate
+// revisions, either captured from production or defined in the stage config.
+type MirrorRequest struct {
+	// Method is the HTTP method.
+	// Default: "GET"
+	Method string `json:"method,omitempty"`
+
+	// Path is the request path and query, e.g. "/orders?limit=10".
+	Path string `json:"path"`
+
+	// Headers are added to the request.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Body is the request body.
+	Body string `json:"body,omitempty"`
+}
+
+// IsSafe reports whether the request method doesn't change state, so
+// replaying the request twice has no side effects.
+func (r MirrorRequest) IsSafe() bool {
+	switch strings.ToUpper(r.Method) {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+// String formats the request for logs, e.g. "GET /orders".
+func (r MirrorRequest) String() string {
+	method := r.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	return strings.ToUpper(method) + " " + r.Path
+}
+
+// ParseMirrorCapture parses a request capture: one JSON MirrorRequest per
+// line. Blank lines are ignored.
+func ParseMirrorCapture(data []byte) ([]MirrorRequest, error) {
+	var requests []MirrorRequest
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var req MirrorRequest
+		if err := json.Unmarshal(text, &req); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if req.Path == "" {
+			return nil, fmt.Errorf("line %d: path is required", line)
+		}
+		requests = append(requests, req)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// SampleMirrorRequests returns n requests picked at random, or all of them if
+// n is zero or not less than the number of requests.
+func SampleMirrorRequests(requests []MirrorRequest, n int, rnd *rand.Rand) []MirrorRequest {
+	if n <= 0 || n >= len(requests) {
+		return requests
+	}
+	sample := append([]MirrorRequest(nil), requests...)
+	for i := 0; i < n; i++ {
+		j := i + rnd.Intn(len(sample)-i)
+		sample[i], sample[j] = sample[j], sample[i]
+	}
+	return sample[:n]
+}
+
+// ParseGCSURI parses a Cloud Storage URI such as "gs://bucket/path/object".
+func ParseGCSURI(uri string) (bucket, object string, err error) {
+	rest, ok := strings.CutPrefix(uri, "gs://")
+	if !ok {
+		return "", "", fmt.Errorf("invalid Cloud Storage URI %q: must start with gs://", uri)
+	}
+	bucket, object, _ = strings.Cut(rest, "/")
+	if bucket == "" || object == "" {
+		return "", "", fmt.Errorf("invalid Cloud Storage URI %q: bucket and object are required", uri)
+	}
+	return bucket, object, nil
+}
+
+// ReadGCSObject reads a Cloud Storage object, e.g. a request capture.
+//
+// If credentialsFile is empty, Application Default Credentials will be used.
+// The credentials need the storage.objects.get permission
+// (e.g. roles/storage.objectViewer).
+func ReadGCSObject(ctx context.Context, credentialsFile, uri string, opts ...option.ClientOption) ([]byte, error) {
+	bucket, object, err := ParseGCSURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	service, err := newStorageService(ctx, credentialsFile, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := service.Objects.Get(bucket, object).Context(ctx).Download()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", uri, err)
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// MirrorResponse is the outcome of a request to one revision.
+type MirrorResponse struct {
+	// Status is the HTTP status code, or zero if the request failed.
+	Status int
+
+	// Latency is the time until the response body was read.
+	Latency time.Duration
+
+	// Err is set if no response was received.
+	Err error
+}
+
+// MirrorResult is the outcome of replaying a request against both revisions.
+type MirrorResult struct {
+	Request   MirrorRequest
+	Stable    MirrorResponse
+	Candidate MirrorResponse
+}
+
+// Mismatch returns why the candidate's response differs from the stable one,
+// or an empty string if it matches. A latency increase is a mismatch only if
+// maxLatencyIncrease is positive. Requests the stable revision couldn't serve
+// are not compared.
+func (r MirrorResult) Mismatch(maxLatencyIncrease time.Duration) string {
+	if r.Stable.Err != nil {
+		return ""
+	}
+	if r.Candidate.Err != nil {
+		return fmt.Sprintf("request failed: %v", r.Candidate.Err)
+	}
+	if r.Candidate.Status != r.Stable.Status {
+		return fmt.Sprintf("status %d (stable %d)", r.Candidate.Status, r.Stable.Status)
+	}
+	if maxLatencyIncrease > 0 && r.Candidate.Latency-r.Stable.Latency > maxLatencyIncrease {
+		return fmt.Sprintf("latency %s (stable %s)", r.Candidate.Latency.Round(time.Millisecond), r.Stable.Latency.Round(time.Millisecond))
+	}
+	return ""
+}
+
+// MirrorTarget defines the revisions requests are replayed against.
+type MirrorTarget struct {
+	// StableURL and CandidateURL are the base URLs of the revisions,
+	// e.g. their traffic tag URLs.
+	StableURL    string
+	CandidateURL string
+
+	// Timeout is the timeout of each request.
+	Timeout time.Duration
+
+	// Concurrency is the number of requests replayed at the same time.
+	// Default: 1
+	Concurrency int
+
+	// HTTPClient is the client used for requests.
+	// If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+// ReplayMirrorRequests sends each request to the stable and then the
+// candidate revision, and returns the results in the order of the requests.
+func ReplayMirrorRequests(ctx context.Context, target MirrorTarget, requests []MirrorRequest) ([]MirrorResult, error) {
+	httpClient := target.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	results := make([]MirrorResult, len(requests))
+	err := forEachConcurrently(ctx, target.Concurrency, len(requests), func(ctx context.Context, i int) error {
+		results[i] = MirrorResult{
+			Request:   requests[i],
+			Stable:    sendMirrorRequest(ctx, httpClient, target.StableURL, requests[i], target.Timeout),
+			Candidate: sendMirrorRequest(ctx, httpClient, target.CandidateURL, requests[i], target.Timeout),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// sendMirrorRequest sends the request to the base URL and measures its latency.
+func sendMirrorRequest(ctx context.Context, httpClient *http.Client, baseURL string, r MirrorRequest, timeout time.Duration) MirrorResponse {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	method := strings.ToUpper(r.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if r.Body != "" {
+		body = strings.NewReader(r.Body)
+	}
+	url := strings.TrimSuffix(baseURL, "/") + "/" + strings.TrimPrefix(r.Path, "/")
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return MirrorResponse{Err: err}
+	}
+	for k, v := range r.Headers {
+		req.Header.Set(k, v)
+	}
+
+	start := time.Now()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return MirrorResponse{Err: err, Latency: time.Since(start)}
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return MirrorResponse{Status: resp.StatusCode, Latency: time.Since(start)}
+}
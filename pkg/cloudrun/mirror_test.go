@@ -0,0 +1,175 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseMirrorCapture(t *testing.T) {
+	data := []byte(`{"path": "/orders?limit=10"}
+
+{"method": "POST", "path": "/orders", "headers": {"Content-Type": "application/json"}, "body": "{}"}
+`)
+	requests, err := ParseMirrorCapture(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+	if requests[0].String() != "GET /orders?limit=10" || !requests[0].IsSafe() {
+		t.Errorf("unexpected first request: %+v", requests[0])
+	}
+	if requests[1].String() != "POST /orders" || requests[1].IsSafe() || requests[1].Headers["Content-Type"] != "application/json" {
+		t.Errorf("unexpected second request: %+v", requests[1])
+	}
+
+	if _, err := ParseMirrorCapture([]byte("{\"path\": \"/\"}\n{\"method\": \"GET\"}\n")); err == nil {
+		t.Error("expected error for a request without path")
+	}
+	if _, err := ParseMirrorCapture([]byte("not json\n")); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
+
+func TestSampleMirrorRequests(t *testing.T) {
+	requests := []MirrorRequest{{Path: "/a"}, {Path: "/b"}, {Path: "/c"}, {Path: "/d"}}
+	rnd := rand.New(rand.NewSource(1))
+
+	sample := SampleMirrorRequests(requests, 2, rnd)
+	if len(sample) != 2 || sample[0].Path == sample[1].Path {
+		t.Errorf("expected 2 distinct requests, got %+v", sample)
+	}
+	if requests[0].Path != "/a" || requests[3].Path != "/d" {
+		t.Errorf("expected requests to be unchanged, got %+v", requests)
+	}
+	if got := SampleMirrorRequests(requests, 10, rnd); len(got) != 4 {
+		t.Errorf("expected all requests, got %d", len(got))
+	}
+}
+
+func TestParseGCSURI(t *testing.T) {
+	bucket, object, err := ParseGCSURI("gs://my-bucket/captures/orders.jsonl")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bucket != "my-bucket" || object != "captures/orders.jsonl" {
+		t.Errorf("unexpected bucket %q and object %q", bucket, object)
+	}
+	for _, uri := range []string{"my-bucket/orders.jsonl", "gs://my-bucket", "gs://my-bucket/", "gs:///orders.jsonl"} {
+		if _, _, err := ParseGCSURI(uri); err == nil {
+			t.Errorf("expected error for %q", uri)
+		}
+	}
+}
+
+func TestMirrorResultMismatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   MirrorResult
+		expected string
+	}{
+		{
+			name: "match",
+			result: MirrorResult{
+				Stable:    MirrorResponse{Status: 200, Latency: 100 * time.Millisecond},
+				Candidate: MirrorResponse{Status: 200, Latency: 300 * time.Millisecond},
+			},
+		},
+		{
+			name: "status",
+			result: MirrorResult{
+				Stable:    MirrorResponse{Status: 200},
+				Candidate: MirrorResponse{Status: 500},
+			},
+			expected: "status 500 (stable 200)",
+		},
+		{
+			name: "latency",
+			result: MirrorResult{
+				Stable:    MirrorResponse{Status: 200, Latency: 100 * time.Millisecond},
+				Candidate: MirrorResponse{Status: 200, Latency: 900 * time.Millisecond},
+			},
+			expected: "latency 900ms (stable 100ms)",
+		},
+		{
+			name: "candidate error",
+			result: MirrorResult{
+				Stable:    MirrorResponse{Status: 200},
+				Candidate: MirrorResponse{Err: errors.New("connection reset")},
+			},
+			expected: "request failed: connection reset",
+		},
+		{
+			name: "stable error",
+			result: MirrorResult{
+				Stable:    MirrorResponse{Err: errors.New("connection reset")},
+				Candidate: MirrorResponse{Status: 500},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.result.Mismatch(500 * time.Millisecond); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestReplayMirrorRequests(t *testing.T) {
+	stable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer stable.Close()
+	candidate := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" || r.Header.Get("X-Fail") != "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer candidate.Close()
+
+	requests := []MirrorRequest{
+		{Path: "/orders"},
+		{Path: "/broken"},
+		{Path: "/orders", Headers: map[string]string{"X-Fail": "1"}},
+	}
+	results, err := ReplayMirrorRequests(context.Background(), MirrorTarget{
+		StableURL:    stable.URL,
+		CandidateURL: candidate.URL + "/",
+		Timeout:      5 * time.Second,
+		Concurrency:  2,
+	}, requests)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"", "status 500 (stable 200)", "status 500 (stable 200)"}
+	for i, result := range results {
+		if got := result.Mismatch(0); got != expected[i] {
+			t.Errorf("request %d: expected mismatch %q, got %q", i, expected[i], got)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ResourceName identifies a Cloud Run location, service, or revision.
+//
+// Full resource names have the following formats:
+//
+//	projects/{project}/locations/{region}
+//	projects/{project}/locations/{region}/services/{service}
+//	projects/{project}/locations/{region}/services/{service}/revisions/{revision}
+type ResourceName struct {
+	Project  string
+	Region   string
+	Service  string
+	Revision string
+}
+
+// NewServiceName returns the resource name of a service.
+func NewServiceName(project, region, service string) ResourceName {
+	return ResourceName{Project: project, Region: region, Service: service}
+}
+
+// NewRevisionName returns the resource name of a revision.
+func NewRevisionName(project, region, service, revision string) ResourceName {
+	return ResourceName{Project: project, Region: region, Service: service, Revision: revision}
+}
+
+// ParseResourceName parses a full location, service, or revision resource name.
+func ParseResourceName(name string) (ResourceName, error) {
+	parts := strings.Split(name, "/")
+	if slices.Contains(parts, "") {
+		return ResourceName{}, fmt.Errorf("invalid resource name %q: empty component", name)
+	}
+
+	var n ResourceName
+	switch len(parts) {
+	case 8:
+		if parts[6] != "revisions" {
+			return ResourceName{}, fmt.Errorf("invalid resource name %q: expected \"revisions\" collection", name)
+		}
+		n.Revision = parts[7]
+		fallthrough
+	case 6:
+		if parts[4] != "services" {
+			return ResourceName{}, fmt.Errorf("invalid resource name %q: expected \"services\" collection", name)
+		}
+		n.Service = parts[5]
+		fallthrough
+	case 4:
+		if parts[0] != "projects" || parts[2] != "locations" {
+			return ResourceName{}, fmt.Errorf("invalid resource name %q: expected projects/{project}/locations/{region} prefix", name)
+		}
+		n.Project = parts[1]
+		n.Region = parts[3]
+	default:
+		return ResourceName{}, fmt.Errorf("invalid resource name %q", name)
+	}
+
+	if err := n.Validate(); err != nil {
+		return ResourceName{}, fmt.Errorf("invalid resource name %q: %w", name, err)
+	}
+	return n, nil
+}
+
+// Validate checks that the set components are non-empty, contain no slashes,
+// and that no component is set without its parent.
+func (n ResourceName) Validate() error {
+	components := []struct {
+		kind, value string
+	}{
+		{"project", n.Project},
+		{"region", n.Region},
+		{"service", n.Service},
+		{"revision", n.Revision},
+	}
+
+	// The first two components are always required
+	for i, c := range components {
+		if c.value == "" {
+			if i < 2 {
+				return fmt.Errorf("%s must not be empty", c.kind)
+			}
+			// A missing component must not be followed by a set one
+			for _, child := range components[i+1:] {
+				if child.value != "" {
+					return fmt.Errorf("%s is set without %s", child.kind, c.kind)
+				}
+			}
+			break
+		}
+		if strings.Contains(c.value, "/") {
+			return fmt.Errorf("%s %q must not contain '/'", c.kind, c.value)
+		}
+	}
+	return nil
+}
+
+// LocationName returns projects/{project}/locations/{region}.
+func (n ResourceName) LocationName() string {
+	return fmt.Sprintf("projects/%s/locations/%s", n.Project, n.Region)
+}
+
+// ServiceName returns projects/{project}/locations/{region}/services/{service}.
+func (n ResourceName) ServiceName() string {
+	return fmt.Sprintf("%s/services/%s", n.LocationName(), n.Service)
+}
+
+// RevisionName returns the full revision resource name.
+func (n ResourceName) RevisionName() string {
+	return fmt.Sprintf("%s/revisions/%s", n.ServiceName(), n.Revision)
+}
+
+// Parent returns the resource name of the parent collection owner:
+// the location for a service, and the service for a revision.
+func (n ResourceName) Parent() string {
+	if n.Revision != "" {
+		return n.ServiceName()
+	}
+	return n.LocationName()
+}
+
+// String returns the full resource name of the most specific component set.
+func (n ResourceName) String() string {
+	switch {
+	case n.Revision != "":
+		return n.RevisionName()
+	case n.Service != "":
+		return n.ServiceName()
+	default:
+		return n.LocationName()
+	}
+}
+
+// ShortRevisionName returns the revision ID of a full revision resource name.
+// Names that are not full resource names are returned unchanged, so it is
+// safe to call on names that are already short.
+func ShortRevisionName(name string) string {
+	n, err := ParseResourceName(name)
+	if err != nil || n.Revision == "" {
+		return name
+	}
+	return n.Revision
+}
+
+// maxRevisionNameLength is the maximum length of a revision name.
+const maxRevisionNameLength = 63
+
+// IncrementRevisionName returns the name to retry a revision name that
+// already exists with, e.g. "my-service-v2-2" for "my-service-v2" and
+// attempt 2. It returns false if the name would be too long.
+func IncrementRevisionName(name string, attempt int) (string, bool) {
+	next := fmt.Sprintf("%s-%d", name, attempt)
+	return next, len(next) <= maxRevisionNameLength
+}
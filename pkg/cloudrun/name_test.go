@@ -0,0 +1,126 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseResourceName(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected ResourceName
+		wantErr  bool
+	}{
+		{
+			name:     "location",
+			input:    "projects/my-project/locations/us-central1",
+			expected: ResourceName{Project: "my-project", Region: "us-central1"},
+		},
+		{
+			name:     "service",
+			input:    "projects/my-project/locations/us-central1/services/my-service",
+			expected: NewServiceName("my-project", "us-central1", "my-service"),
+		},
+		{
+			name:     "revision",
+			input:    "projects/my-project/locations/us-central1/services/my-service/revisions/my-service-00001-abc",
+			expected: NewRevisionName("my-project", "us-central1", "my-service", "my-service-00001-abc"),
+		},
+		{name: "short name", input: "my-service", wantErr: true},
+		{name: "wrong collection", input: "projects/p/regions/r/services/s", wantErr: true},
+		{name: "empty service", input: "projects/p/locations/r/services/", wantErr: true},
+		{name: "trailing slash", input: "projects/p/locations/r/services/s/", wantErr: true},
+		{name: "empty", input: "", wantErr: true},
+		{name: "empty project", input: "projects//locations/x/services/y", wantErr: true},
+		{name: "empty location", input: "projects/p/locations//services/s", wantErr: true},
+		{name: "empty revision", input: "projects/p/locations/r/services/s/revisions/", wantErr: true},
+		{name: "double slash", input: "projects/p/locations/r//services/s", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseResourceName(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, got)
+			}
+			if got.String() != tt.input {
+				t.Errorf("expected String() to round-trip to %q, got %q", tt.input, got.String())
+			}
+		})
+	}
+}
+
+func TestResourceName_Parent(t *testing.T) {
+	svc := NewServiceName("p", "r", "s")
+	if svc.Parent() != "projects/p/locations/r" {
+		t.Errorf("unexpected service parent: %s", svc.Parent())
+	}
+
+	rev := NewRevisionName("p", "r", "s", "s-00001")
+	if rev.Parent() != "projects/p/locations/r/services/s" {
+		t.Errorf("unexpected revision parent: %s", rev.Parent())
+	}
+}
+
+func TestResourceName_Validate(t *testing.T) {
+	if err := (ResourceName{Project: "p", Region: "r", Revision: "rev"}).Validate(); err == nil {
+		t.Error("expected error for revision without service")
+	}
+	if err := NewServiceName("p", "r", "a/b").Validate(); err == nil {
+		t.Error("expected error for service containing a slash")
+	}
+	if err := NewServiceName("", "r", "s").Validate(); err == nil {
+		t.Error("expected error for empty project")
+	}
+}
+
+func TestGetServiceName(t *testing.T) {
+	if got := GetServiceName("projects/p/locations/r/services/my-service"); got != "my-service" {
+		t.Errorf("expected my-service, got %q", got)
+	}
+	if got := GetServiceName("my-service"); got != "" {
+		t.Errorf("expected empty name for invalid input, got %q", got)
+	}
+}
+
+func TestShortRevisionName(t *testing.T) {
+	if got := ShortRevisionName("projects/p/locations/r/services/s/revisions/s-00001"); got != "s-00001" {
+		t.Errorf("expected s-00001, got %q", got)
+	}
+	if got := ShortRevisionName("s-00001"); got != "s-00001" {
+		t.Errorf("expected short name to be unchanged, got %q", got)
+	}
+}
+
+func TestIncrementRevisionName(t *testing.T) {
+	if got, ok := IncrementRevisionName("my-service-v2", 2); !ok || got != "my-service-v2-2" {
+		t.Errorf("expected my-service-v2-2, got %q, %v", got, ok)
+	}
+	if _, ok := IncrementRevisionName(strings.Repeat("a", 62), 2); ok {
+		t.Error("expected a name over 63 characters to be rejected")
+	}
+}
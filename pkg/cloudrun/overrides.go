@@ -0,0 +1,198 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+)
+
+// CanaryOverridesAnnotation is the revision template annotation that records
+// the original settings replaced by canary overrides, so they can be restored
+// at full promotion.
+const CanaryOverridesAnnotation = "pipecd.dev/canary-overrides"
+
+// RevisionOverrides defines settings applied only to a canary revision.
+type RevisionOverrides struct {
+	// Env sets environment variables on the first container.
+	Env map[string]string
+
+	// MinInstances overrides the minimum number of instances.
+	MinInstances *int32
+
+	// MaxInstances overrides the maximum number of instances.
+	MaxInstances *int32
+}
+
+// IsEmpty returns true if no override is set.
+func (o RevisionOverrides) IsEmpty() bool {
+	return len(o.Env) == 0 && o.MinInstances == nil && o.MaxInstances == nil
+}
+
+// overridesRecord holds the original values replaced by RevisionOverrides.
+type overridesRecord struct {
+	// Env maps each overridden variable to its original value (nil if unset).
+	Env map[string]*string `json:"env,omitempty"`
+
+	// Scaling is the original scaling configuration (nil if unset).
+	Scaling *scalingRecord `json:"scaling,omitempty"`
+}
+
+// scalingRecord holds the original revision scaling configuration.
+type scalingRecord struct {
+	Min int32 `json:"min"`
+	Max int32 `json:"max"`
+}
+
+// ApplyRevisionOverrides applies canary overrides to the service template and
+// records the original values in the CanaryOverridesAnnotation.
+func ApplyRevisionOverrides(service *runpb.Service, o RevisionOverrides) error {
+	if o.IsEmpty() {
+		return nil
+	}
+	if service.Template == nil {
+		return fmt.Errorf("service has no revision template")
+	}
+	tmpl := service.Template
+
+	record := overridesRecord{}
+
+	if len(o.Env) > 0 {
+		if len(tmpl.Containers) == 0 {
+			return fmt.Errorf("service has no containers to set env on")
+		}
+		container := tmpl.Containers[0]
+		record.Env = make(map[string]*string, len(o.Env))
+		for name, value := range o.Env {
+			record.Env[name] = getEnvValue(container, name)
+			setEnvValue(container, name, value)
+		}
+	}
+
+	if o.MinInstances != nil || o.MaxInstances != nil {
+		if tmpl.Scaling != nil {
+			record.Scaling = &scalingRecord{
+				Min: tmpl.Scaling.MinInstanceCount,
+				Max: tmpl.Scaling.MaxInstanceCount,
+			}
+		} else {
+			tmpl.Scaling = &runpb.RevisionScaling{}
+		}
+		if o.MinInstances != nil {
+			tmpl.Scaling.MinInstanceCount = *o.MinInstances
+		}
+		if o.MaxInstances != nil {
+			tmpl.Scaling.MaxInstanceCount = *o.MaxInstances
+		}
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to record canary overrides: %w", err)
+	}
+	if tmpl.Annotations == nil {
+		tmpl.Annotations = make(map[string]string)
+	}
+	tmpl.Annotations[CanaryOverridesAnnotation] = string(data)
+
+	return nil
+}
+
+// HasRevisionOverrides returns true if the template was deployed with canary overrides.
+func HasRevisionOverrides(tmpl *runpb.RevisionTemplate) bool {
+	if tmpl == nil {
+		return false
+	}
+	_, ok := tmpl.Annotations[CanaryOverridesAnnotation]
+	return ok
+}
+
+// RevertRevisionOverrides restores the original values recorded by
+// ApplyRevisionOverrides and removes the record annotation.
+// It returns false if the template has no canary overrides.
+func RevertRevisionOverrides(tmpl *runpb.RevisionTemplate) (bool, error) {
+	if !HasRevisionOverrides(tmpl) {
+		return false, nil
+	}
+
+	var record overridesRecord
+	if err := json.Unmarshal([]byte(tmpl.Annotations[CanaryOverridesAnnotation]), &record); err != nil {
+		return false, fmt.Errorf("failed to parse canary overrides record: %w", err)
+	}
+
+	if len(record.Env) > 0 && len(tmpl.Containers) > 0 {
+		container := tmpl.Containers[0]
+		for name, value := range record.Env {
+			if value == nil {
+				removeEnvValue(container, name)
+			} else {
+				setEnvValue(container, name, *value)
+			}
+		}
+	}
+
+	if record.Scaling != nil {
+		tmpl.Scaling = &runpb.RevisionScaling{
+			MinInstanceCount: record.Scaling.Min,
+			MaxInstanceCount: record.Scaling.Max,
+		}
+	} else if tmpl.Scaling != nil {
+		tmpl.Scaling = nil
+	}
+
+	delete(tmpl.Annotations, CanaryOverridesAnnotation)
+	// A new revision name is generated for the reverted template
+	tmpl.Revision = ""
+
+	return true, nil
+}
+
+// getEnvValue returns the plain value of an environment variable, or nil if unset.
+func getEnvValue(container *runpb.Container, name string) *string {
+	for _, env := range container.Env {
+		if env.Name == name {
+			value := env.GetValue()
+			return &value
+		}
+	}
+	return nil
+}
+
+// setEnvValue sets a plain environment variable on the container.
+func setEnvValue(container *runpb.Container, name, value string) {
+	for _, env := range container.Env {
+		if env.Name == name {
+			env.Values = &runpb.EnvVar_Value{Value: value}
+			return
+		}
+	}
+	container.Env = append(container.Env, &runpb.EnvVar{
+		Name:   name,
+		Values: &runpb.EnvVar_Value{Value: value},
+	})
+}
+
+// removeEnvValue removes an environment variable from the container.
+func removeEnvValue(container *runpb.Container, name string) {
+	env := container.Env[:0]
+	for _, e := range container.Env {
+		if e.Name != name {
+			env = append(env, e)
+		}
+	}
+	container.Env = env
+}
@@ -0,0 +1,81 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"testing"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+)
+
+func TestRevisionOverrides_ApplyAndRevert(t *testing.T) {
+	maxInstances := int32(2)
+	service := &runpb.Service{
+		Template: &runpb.RevisionTemplate{
+			Scaling: &runpb.RevisionScaling{MinInstanceCount: 1, MaxInstanceCount: 10},
+			Containers: []*runpb.Container{
+				{
+					Image: "gcr.io/project/app:v1.0.0",
+					Env: []*runpb.EnvVar{
+						{Name: "LOG_LEVEL", Values: &runpb.EnvVar_Value{Value: "info"}},
+					},
+				},
+			},
+		},
+	}
+
+	err := ApplyRevisionOverrides(service, RevisionOverrides{
+		Env:          map[string]string{"LOG_LEVEL": "debug", "CANARY": "true"},
+		MaxInstances: &maxInstances,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	container := service.Template.Containers[0]
+	if v := getEnvValue(container, "LOG_LEVEL"); v == nil || *v != "debug" {
+		t.Errorf("expected LOG_LEVEL to be debug, got %v", v)
+	}
+	if v := getEnvValue(container, "CANARY"); v == nil || *v != "true" {
+		t.Errorf("expected CANARY to be true, got %v", v)
+	}
+	if service.Template.Scaling.MaxInstanceCount != 2 {
+		t.Errorf("expected max instances to be 2, got %d", service.Template.Scaling.MaxInstanceCount)
+	}
+	if !HasRevisionOverrides(service.Template) {
+		t.Fatalf("expected template to record canary overrides")
+	}
+
+	reverted, err := RevertRevisionOverrides(service.Template)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reverted {
+		t.Fatalf("expected overrides to be reverted")
+	}
+
+	if v := getEnvValue(container, "LOG_LEVEL"); v == nil || *v != "info" {
+		t.Errorf("expected LOG_LEVEL to be restored to info, got %v", v)
+	}
+	if v := getEnvValue(container, "CANARY"); v != nil {
+		t.Errorf("expected CANARY to be removed, got %s", *v)
+	}
+	if service.Template.Scaling.MinInstanceCount != 1 || service.Template.Scaling.MaxInstanceCount != 10 {
+		t.Errorf("expected scaling to be restored, got %v", service.Template.Scaling)
+	}
+	if HasRevisionOverrides(service.Template) {
+		t.Errorf("expected canary overrides record to be removed")
+	}
+}
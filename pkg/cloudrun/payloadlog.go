@@ -0,0 +1,294 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	logging "google.golang.org/api/logging/v2"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// PayloadLogAnnotation is the service annotation enabling the payload log
+// for the deployments of the service. Its value is the fraction of Admin API
+// calls logged, e.g. "0.1"; "1" logs every call.
+const PayloadLogAnnotation = "pipecd.dev/debug-payload-log"
+
+// DefaultPayloadLogName is the Cloud Logging log payloads are written to.
+const DefaultPayloadLogName = "pipecd-cloudrun-payloads"
+
+// payloadWriteTimeout bounds writing a payload, which doesn't share the call
+// deadline so slow calls are logged too.
+const payloadWriteTimeout = 5 * time.Second
+
+// redactedValue replaces redacted values in logged payloads.
+const redactedValue = "[REDACTED]"
+
+// PayloadLogSampleRate returns the sample rate set by the PayloadLogAnnotation
+// of the service, and whether it is set.
+func PayloadLogSampleRate(svc *runpb.Service) (float64, bool, error) {
+	value, ok := svc.GetAnnotations()[PayloadLogAnnotation]
+	if !ok {
+		return 0, false, nil
+	}
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate <= 0 || rate > 1 {
+		return 0, false, fmt.Errorf("annotation %s must be a sample rate in (0, 1], got %q", PayloadLogAnnotation, value)
+	}
+	return rate, true, nil
+}
+
+// PayloadRecord is a logged Admin API call.
+type PayloadRecord struct {
+	Time   time.Time         `json:"time"`
+	Method string            `json:"method"`
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Request and Response are the redacted payloads as protobuf JSON.
+	// Response is unset if the call failed.
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    string          `json:"error,omitempty"`
+
+	// Duration is how long the call took, e.g. "1.2s".
+	Duration string `json:"duration"`
+}
+
+// PayloadSink receives the logged Admin API calls.
+type PayloadSink interface {
+	WritePayload(ctx context.Context, r PayloadRecord) error
+}
+
+// filePayloadSink appends the records to a local file as JSON lines.
+type filePayloadSink struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFilePayloadSink returns a PayloadSink appending the records to the file
+// at path, one JSON object per line.
+func NewFilePayloadSink(path string) PayloadSink {
+	return &filePayloadSink{path: path}
+}
+
+// WritePayload appends the record to the file.
+func (s *filePayloadSink) WritePayload(_ context.Context, r PayloadRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open payload log: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write payload log: %w", err)
+	}
+	return f.Close()
+}
+
+// cloudLoggingPayloadSink writes the records to Cloud Logging.
+type cloudLoggingPayloadSink struct {
+	service *logging.Service
+	logName string
+}
+
+// NewCloudLoggingPayloadSink returns a PayloadSink writing the records to
+// the log logName of the project in Cloud Logging, as JSON payloads.
+//
+// If credentialsFile is empty, Application Default Credentials will be used.
+// The credentials need the logging.logEntries.create permission
+// (e.g. roles/logging.logWriter).
+func NewCloudLoggingPayloadSink(ctx context.Context, credentialsFile, project, logName string, opts ...option.ClientOption) (PayloadSink, error) {
+	if credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	}
+	service, err := logging.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud Logging client: %w", err)
+	}
+	if logName == "" {
+		logName = DefaultPayloadLogName
+	}
+	return &cloudLoggingPayloadSink{
+		service: service,
+		logName: fmt.Sprintf("projects/%s/logs/%s", project, logName),
+	}, nil
+}
+
+// WritePayload writes the record as a log entry, failed calls with the
+// ERROR severity.
+func (s *cloudLoggingPayloadSink) WritePayload(ctx context.Context, r PayloadRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	severity := "DEBUG"
+	if r.Error != "" {
+		severity = "ERROR"
+	}
+	_, err = s.service.Entries.Write(&logging.WriteLogEntriesRequest{
+		LogName:  s.logName,
+		Resource: &logging.MonitoredResource{Type: "global"},
+		Labels:   r.Labels,
+		Entries: []*logging.LogEntry{{
+			Timestamp:   r.Time.UTC().Format(time.RFC3339Nano),
+			Severity:    severity,
+			JsonPayload: data,
+		}},
+	}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to write payload log entry: %w", err)
+	}
+	return nil
+}
+
+// PayloadLogger samples Admin API calls into a sink.
+type PayloadLogger struct {
+	// Sink receives the sampled calls.
+	Sink PayloadSink
+
+	// SampleRate is the fraction of calls logged, in (0, 1].
+	SampleRate float64
+
+	// Labels are added to every record, e.g. the deployment ID.
+	Labels map[string]string
+
+	// OnError is called when a record can't be written. Failing to log a
+	// call doesn't fail the call.
+	OnError func(error)
+}
+
+type payloadLoggerKey struct{}
+
+// WithPayloadLog returns a context in which the Admin API calls of clients
+// created by NewClient are sampled into logger.
+func WithPayloadLog(ctx context.Context, logger *PayloadLogger) context.Context {
+	return context.WithValue(ctx, payloadLoggerKey{}, logger)
+}
+
+// payloadLoggerFrom returns the payload logger of the context, if any.
+func payloadLoggerFrom(ctx context.Context) *PayloadLogger {
+	logger, _ := ctx.Value(payloadLoggerKey{}).(*PayloadLogger)
+	return logger
+}
+
+// payloadLogInterceptor logs the sampled unary gRPC calls made in contexts
+// with a payload logger, and passes other calls through.
+func payloadLogInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	logger := payloadLoggerFrom(ctx)
+	if logger == nil || logger.Sink == nil || rand.Float64() >= logger.SampleRate {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	started := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	record := PayloadRecord{
+		Time:     started,
+		Method:   method,
+		Labels:   logger.Labels,
+		Request:  redactedPayload(req),
+		Duration: time.Since(started).String(),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	} else {
+		record.Response = redactedPayload(reply)
+	}
+
+	writeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), payloadWriteTimeout)
+	defer cancel()
+	if werr := logger.Sink.WritePayload(writeCtx, record); werr != nil && logger.OnError != nil {
+		logger.OnError(werr)
+	}
+	return err
+}
+
+// redactedPayload returns the protobuf JSON of the message, with secrets
+// redacted: environment variable values, which may hold credentials, and
+// the last applied manifest annotation, which holds them too.
+func redactedPayload(v any) json.RawMessage {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil
+	}
+	m = proto.Clone(m)
+	redactMessage(m.ProtoReflect())
+	data, err := protojson.Marshal(m)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprintf("unmarshalable %T: %v", v, err))
+	}
+	return data
+}
+
+// redactMessage redacts the message and its nested messages in place,
+// including the messages packed in Any fields, e.g. operation results.
+func redactMessage(m protoreflect.Message) {
+	if a, ok := m.Interface().(*anypb.Any); ok {
+		inner, err := a.UnmarshalNew()
+		if err != nil {
+			return
+		}
+		redactMessage(inner.ProtoReflect())
+		_ = a.MarshalFrom(inner)
+		return
+	}
+	if _, ok := m.Interface().(*runpb.EnvVar); ok {
+		if fd := m.Descriptor().Fields().ByName("value"); m.Has(fd) {
+			m.Set(fd, protoreflect.ValueOfString(redactedValue))
+		}
+	}
+
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsMap():
+			if fd.Name() == "annotations" && v.Map().Has(protoreflect.ValueOfString(LastAppliedAnnotation).MapKey()) {
+				v.Map().Set(protoreflect.ValueOfString(LastAppliedAnnotation).MapKey(), protoreflect.ValueOfString(redactedValue))
+			}
+			if fd.MapValue().Message() != nil {
+				v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+					redactMessage(mv.Message())
+					return true
+				})
+			}
+		case fd.IsList():
+			if fd.Message() != nil {
+				for i := 0; i < v.List().Len(); i++ {
+					redactMessage(v.List().Get(i).Message())
+				}
+			}
+		case fd.Message() != nil:
+			redactMessage(v.Message())
+		}
+		return true
+	})
+}
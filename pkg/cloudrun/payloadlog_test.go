@@ -0,0 +1,117 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"cloud.google.com/go/run/apiv2/runpb"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func TestPayloadLogSampleRate(t *testing.T) {
+	for value, want := range map[string]float64{"1": 1, "0.25": 0.25} {
+		rate, ok, err := PayloadLogSampleRate(&runpb.Service{Annotations: map[string]string{PayloadLogAnnotation: value}})
+		if err != nil || !ok || rate != want {
+			t.Errorf("%s: expected %g, got %g, %v, %v", value, want, rate, ok, err)
+		}
+	}
+	for _, value := range []string{"0", "2", "all"} {
+		if _, _, err := PayloadLogSampleRate(&runpb.Service{Annotations: map[string]string{PayloadLogAnnotation: value}}); err == nil {
+			t.Errorf("%s: expected an error", value)
+		}
+	}
+	if _, ok, err := PayloadLogSampleRate(&runpb.Service{}); ok || err != nil {
+		t.Errorf("expected no sample rate, got %v, %v", ok, err)
+	}
+}
+
+func TestPayloadLogInterceptor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "payloads.jsonl")
+	service := &runpb.Service{
+		Name:        "projects/p/locations/r/services/my-service",
+		Annotations: map[string]string{LastAppliedAnnotation: `{"env": "hunter2"}`},
+		Template: &runpb.RevisionTemplate{Containers: []*runpb.Container{{
+			Env: []*runpb.EnvVar{
+				{Name: "PASSWORD", Values: &runpb.EnvVar_Value{Value: "hunter2"}},
+				{Name: "API_KEY", Values: &runpb.EnvVar_ValueSource{ValueSource: &runpb.EnvVarSource{SecretKeyRef: &runpb.SecretKeySelector{Secret: "api-key"}}}},
+			},
+		}}},
+	}
+	packed, err := anypb.New(service)
+	if err != nil {
+		t.Fatal(err)
+	}
+	invoker := func(_ context.Context, _ string, _, reply any, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+		proto.Merge(reply.(proto.Message), &longrunningpb.Operation{Name: "op", Result: &longrunningpb.Operation_Response{Response: packed}})
+		return nil
+	}
+
+	// Calls without a payload logger are not logged
+	req := &runpb.UpdateServiceRequest{Service: service}
+	if err := payloadLogInterceptor(context.Background(), "/UpdateService", req, &longrunningpb.Operation{}, nil, invoker); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected no payload log, got %v", err)
+	}
+
+	ctx := WithPayloadLog(context.Background(), &PayloadLogger{
+		Sink:       NewFilePayloadSink(path),
+		SampleRate: 1,
+		Labels:     map[string]string{"deployment": "d1"},
+	})
+	if err := payloadLogInterceptor(ctx, "/UpdateService", req, &longrunningpb.Operation{}, nil, invoker); err != nil {
+		t.Fatal(err)
+	}
+	failed := errors.New("permission denied")
+	err = payloadLogInterceptor(ctx, "/GetService", &runpb.GetServiceRequest{Name: service.Name}, &runpb.Service{}, nil,
+		func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error { return failed })
+	if err != failed {
+		t.Fatalf("expected the call error, got %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 records, got:\n%s", data)
+	}
+	if strings.Contains(string(data), "hunter2") || !strings.Contains(lines[0], redactedValue) || !strings.Contains(lines[0], "api-key") {
+		t.Errorf("expected the values to be redacted, got:\n%s", data)
+	}
+	if req.Service.Template.Containers[0].Env[0].GetValue() != "hunter2" {
+		t.Errorf("expected the request not to be modified")
+	}
+
+	var record PayloadRecord
+	if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Method != "/GetService" || record.Error != "permission denied" || record.Response != nil || record.Labels["deployment"] != "d1" {
+		t.Errorf("unexpected record %+v", record)
+	}
+}
@@ -0,0 +1,278 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+)
+
+// DefaultImagePlatform is the platform Cloud Run runs containers on.
+const DefaultImagePlatform = "linux/amd64"
+
+// manifestMediaTypes are the manifest types accepted from registries, both
+// image indexes (multi-platform) and single-platform manifests.
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// ImageReference is a parsed container image reference.
+type ImageReference struct {
+	// Registry is the registry host, e.g. "us-docker.pkg.dev".
+	Registry string
+
+	// Repository is the repository path, e.g. "my-project/my-repo/app".
+	Repository string
+
+	// Reference is the tag or digest, e.g. "v1" or "sha256:...".
+	Reference string
+}
+
+// ParseImageReference parses an image such as "gcr.io/my-project/app:v1" or
+// "nginx". Images without a registry are on Docker Hub, and images without
+// a tag or digest use "latest".
+func ParseImageReference(image string) (ImageReference, error) {
+	if image == "" {
+		return ImageReference{}, fmt.Errorf("image is empty")
+	}
+
+	ref := ImageReference{Registry: "registry-1.docker.io"}
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, ref.Reference = name[:i], name[i+1:]
+	} else if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, ref.Reference = name[:i], name[i+1:]
+	}
+	if ref.Reference == "" {
+		ref.Reference = "latest"
+	}
+
+	// The first component is a registry if it looks like a host
+	if i := strings.Index(name, "/"); i >= 0 {
+		host := name[:i]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			ref.Registry = host
+			name = name[i+1:]
+		}
+	}
+	if ref.Registry == "registry-1.docker.io" && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	if name == "" {
+		return ImageReference{}, fmt.Errorf("invalid image %q", image)
+	}
+	ref.Repository = name
+	return ref, nil
+}
+
+// IsGoogleRegistry reports whether the image is stored in Artifact Registry
+// or Container Registry, which accept Google credentials.
+func (r ImageReference) IsGoogleRegistry() bool {
+	return r.Registry == "gcr.io" || strings.HasSuffix(r.Registry, ".gcr.io") || strings.HasSuffix(r.Registry, "-docker.pkg.dev")
+}
+
+// baseURL returns the registry API URL. Local registries are served over HTTP.
+func (r ImageReference) baseURL() string {
+	scheme := "https"
+	if host := strings.Split(r.Registry, ":")[0]; host == "localhost" || host == "127.0.0.1" {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s/v2/%s", scheme, r.Registry, r.Repository)
+}
+
+// NewRegistryHTTPClient returns an HTTP client for the registry of the image.
+// Google registries are accessed with the credentials (Application Default
+// Credentials if credentialsFile is empty), others anonymously.
+func NewRegistryHTTPClient(ctx context.Context, credentialsFile string, ref ImageReference) (*http.Client, error) {
+	if !ref.IsGoogleRegistry() {
+		return http.DefaultClient, nil
+	}
+	opts := []option.ClientOption{option.WithScopes("https://www.googleapis.com/auth/cloud-platform")}
+	if credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	}
+	httpClient, _, err := htransport.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create registry client: %w", err)
+	}
+	return httpClient, nil
+}
+
+// imageManifest holds the fields of image indexes and manifests used to
+// determine their platforms.
+type imageManifest struct {
+	MediaType string `json:"mediaType"`
+	Manifests []struct {
+		Platform *imagePlatform `json:"platform"`
+	} `json:"manifests"`
+	Config *struct {
+		Digest string `json:"digest"`
+	} `json:"config"`
+}
+
+// imagePlatform is the platform of an image, as in image indexes and configs.
+type imagePlatform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+func (p imagePlatform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// ImagePlatforms returns the platforms the image is built for, e.g.
+// ["linux/amd64", "linux/arm64/v8"], from its index or, for a
+// single-platform image, from its config.
+func ImagePlatforms(ctx context.Context, httpClient *http.Client, ref ImageReference) ([]string, error) {
+	var manifest imageManifest
+	if err := registryGetJSON(ctx, httpClient, ref.baseURL()+"/manifests/"+ref.Reference, strings.Join(manifestMediaTypes, ", "), &manifest); err != nil {
+		return nil, fmt.Errorf("failed to get manifest: %w", err)
+	}
+
+	if len(manifest.Manifests) > 0 {
+		var platforms []string
+		for _, m := range manifest.Manifests {
+			// Attestation manifests are listed as unknown/unknown
+			if m.Platform == nil || m.Platform.OS == "unknown" {
+				continue
+			}
+			platforms = append(platforms, m.Platform.String())
+		}
+		return platforms, nil
+	}
+
+	if manifest.Config == nil || manifest.Config.Digest == "" {
+		return nil, fmt.Errorf("manifest has no config")
+	}
+	var platform imagePlatform
+	if err := registryGetJSON(ctx, httpClient, ref.baseURL()+"/blobs/"+manifest.Config.Digest, "*/*", &platform); err != nil {
+		return nil, fmt.Errorf("failed to get image config: %w", err)
+	}
+	return []string{platform.String()}, nil
+}
+
+// HasPlatform reports whether the platforms include the wanted one. A wanted
+// platform without a variant (e.g. "linux/arm64") matches any variant.
+func HasPlatform(platforms []string, want string) bool {
+	for _, p := range platforms {
+		if p == want || strings.HasPrefix(p, want+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// registryGetJSON gets a registry URL and decodes the JSON response. On a
+// Bearer challenge, an anonymous token is requested and the request retried.
+func registryGetJSON(ctx context.Context, httpClient *http.Client, u, accept string, v interface{}) error {
+	resp, err := registryGet(ctx, httpClient, u, accept, "")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+
+		token, err := registryToken(ctx, httpClient, challenge)
+		if err != nil {
+			return err
+		}
+		if resp, err = registryGet(ctx, httpClient, u, accept, token); err != nil {
+			return err
+		}
+	}
+	return decodeRegistryResponse(resp, v)
+}
+
+// decodeRegistryResponse decodes the JSON body of a successful response.
+func decodeRegistryResponse(resp *http.Response, v interface{}) error {
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("registry returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func registryGet(ctx context.Context, httpClient *http.Client, u, accept, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return httpClient.Do(req)
+}
+
+// challengeParamPattern matches the parameters of a WWW-Authenticate header.
+var challengeParamPattern = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// registryToken requests an anonymous token for a Bearer challenge, e.g.
+// `Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull"`.
+func registryToken(ctx context.Context, httpClient *http.Client, challenge string) (string, error) {
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return "", fmt.Errorf("registry requires unsupported authentication %q", challenge)
+	}
+	params := make(map[string]string)
+	for _, m := range challengeParamPattern.FindAllStringSubmatch(challenge, -1) {
+		params[m[1]] = m[2]
+	}
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("registry authentication challenge has no realm")
+	}
+
+	query := url.Values{}
+	for _, k := range []string{"service", "scope"} {
+		if params[k] != "" {
+			query.Set(k, params[k])
+		}
+	}
+	var resp struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	tokenResp, err := registryGet(ctx, httpClient, realm+"?"+query.Encode(), "application/json", "")
+	if err == nil {
+		err = decodeRegistryResponse(tokenResp, &resp)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get registry token: %w", err)
+	}
+	if resp.Token != "" {
+		return resp.Token, nil
+	}
+	return resp.AccessToken, nil
+}
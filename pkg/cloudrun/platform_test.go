@@ -0,0 +1,106 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseImageReference(t *testing.T) {
+	tests := []struct {
+		image string
+		want  ImageReference
+	}{
+		{
+			image: "us-docker.pkg.dev/my-project/my-repo/app:v1",
+			want:  ImageReference{Registry: "us-docker.pkg.dev", Repository: "my-project/my-repo/app", Reference: "v1"},
+		},
+		{
+			image: "gcr.io/my-project/app@sha256:abc",
+			want:  ImageReference{Registry: "gcr.io", Repository: "my-project/app", Reference: "sha256:abc"},
+		},
+		{
+			image: "nginx",
+			want:  ImageReference{Registry: "registry-1.docker.io", Repository: "library/nginx", Reference: "latest"},
+		},
+		{
+			image: "localhost:5000/app:v2",
+			want:  ImageReference{Registry: "localhost:5000", Repository: "app", Reference: "v2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			got, err := ParseImageReference(tt.image)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestImagePlatforms(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/multi/manifests/v1":
+			w.Write([]byte(`{"manifests": [
+				{"platform": {"os": "linux", "architecture": "amd64"}},
+				{"platform": {"os": "linux", "architecture": "arm64", "variant": "v8"}},
+				{"platform": {"os": "unknown", "architecture": "unknown"}}
+			]}`))
+		case "/v2/single/manifests/v1":
+			w.Write([]byte(`{"config": {"digest": "sha256:cfg"}}`))
+		case "/v2/single/blobs/sha256:cfg":
+			w.Write([]byte(`{"os": "linux", "architecture": "arm64"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	registry := strings.TrimPrefix(server.URL, "http://")
+
+	tests := []struct {
+		repository string
+		want       []string
+		amd64      bool
+	}{
+		{repository: "multi", want: []string{"linux/amd64", "linux/arm64/v8"}, amd64: true},
+		{repository: "single", want: []string{"linux/arm64"}, amd64: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.repository, func(t *testing.T) {
+			ref := ImageReference{Registry: registry, Repository: tt.repository, Reference: "v1"}
+			got, err := ImagePlatforms(context.Background(), server.Client(), ref)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+			if HasPlatform(got, DefaultImagePlatform) != tt.amd64 {
+				t.Errorf("expected HasPlatform(%v, %s) = %v", got, DefaultImagePlatform, tt.amd64)
+			}
+		})
+	}
+}
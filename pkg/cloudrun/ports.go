@@ -0,0 +1,92 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"fmt"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+)
+
+// Container port names supported by Cloud Run. The name selects the protocol
+// requests are forwarded to the container with.
+const (
+	// PortNameHTTP1 serves HTTP/1.1. It is the default.
+	PortNameHTTP1 = "http1"
+
+	// PortNameH2C serves end-to-end HTTP/2 without TLS, required for gRPC streaming.
+	PortNameH2C = "h2c"
+)
+
+// DefaultPort is the port requests are sent to when none is declared.
+const DefaultPort int32 = 8080
+
+// ServingPort describes the port and protocol a revision receives requests on.
+type ServingPort struct {
+	// Container is the name of the container declaring the port.
+	Container string
+
+	// Port is the port number.
+	Port int32
+
+	// Name is the port name: "http1" or "h2c".
+	Name string
+}
+
+// Protocol returns the protocol requests are served with.
+func (p ServingPort) Protocol() string {
+	if p.Name == PortNameH2C {
+		return "HTTP/2 (h2c)"
+	}
+	return "HTTP/1"
+}
+
+// GetServingPort returns the serving port of the revision template.
+// Cloud Run accepts a single port, declared on the ingress container; it
+// returns an error if several ports are declared or the port is invalid.
+func GetServingPort(tmpl *runpb.RevisionTemplate) (ServingPort, error) {
+	port := ServingPort{
+		Port: DefaultPort,
+		Name: PortNameHTTP1,
+	}
+
+	found := false
+	for _, c := range tmpl.GetContainers() {
+		for _, p := range c.Ports {
+			if found {
+				return port, fmt.Errorf("only one serving port can be declared, found another on container %q", c.Name)
+			}
+			found = true
+
+			if p.ContainerPort < 0 || p.ContainerPort > 65535 {
+				return port, fmt.Errorf("invalid port %d on container %q", p.ContainerPort, c.Name)
+			}
+			switch p.Name {
+			case "", PortNameHTTP1, PortNameH2C:
+			default:
+				return port, fmt.Errorf("invalid port name %q on container %q (must be %q or %q)", p.Name, c.Name, PortNameHTTP1, PortNameH2C)
+			}
+
+			port.Container = c.Name
+			if p.ContainerPort != 0 {
+				port.Port = p.ContainerPort
+			}
+			if p.Name != "" {
+				port.Name = p.Name
+			}
+		}
+	}
+	return port, nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+)
+
+const (
+	// PreviewLabel marks an ephemeral preview service. Its value is the name
+	// of the service the preview was created from.
+	PreviewLabel = "pipecd-preview"
+
+	// PreviewExpiresLabel is the Unix time (in seconds) after which a preview
+	// service can be deleted.
+	PreviewExpiresLabel = "pipecd-preview-expires"
+)
+
+// maxServiceNameLength is the maximum length of a Cloud Run service name.
+const maxServiceNameLength = 49
+
+// PreviewServiceName returns the name of the preview service of base for the
+// identifier (e.g. "pr-123" or a branch name). The identifier is lowercased
+// and characters not allowed in service names are replaced with '-'.
+// Example: PreviewServiceName("my-svc", "PR-123") = "my-svc-pr-123"
+func PreviewServiceName(base, id string) (string, error) {
+	var b strings.Builder
+	for _, r := range strings.ToLower(id) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('-')
+		}
+	}
+	id = strings.Trim(b.String(), "-")
+	if id == "" {
+		return "", fmt.Errorf("preview identifier is empty")
+	}
+
+	name := base + "-" + id
+	if len(name) > maxServiceNameLength {
+		name = strings.TrimRight(name[:maxServiceNameLength], "-")
+	}
+	return name, nil
+}
+
+// MarkPreview labels the service as a preview of base that expires at the given time.
+func MarkPreview(svc *runpb.Service, base string, expires time.Time) {
+	if svc.Labels == nil {
+		svc.Labels = make(map[string]string)
+	}
+	svc.Labels[PreviewLabel] = base
+	svc.Labels[PreviewExpiresLabel] = strconv.FormatInt(expires.Unix(), 10)
+}
+
+// PreviewExpiry returns the expiry time of a preview service.
+// It returns false if the service is not a preview or has no valid expiry.
+func PreviewExpiry(svc *runpb.Service) (time.Time, bool) {
+	if _, ok := svc.Labels[PreviewLabel]; !ok {
+		return time.Time{}, false
+	}
+	sec, err := strconv.ParseInt(svc.Labels[PreviewExpiresLabel], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(sec, 0), true
+}
+
+// ExpiredPreviews returns the short names of the preview services that have
+// expired at now. If base is set, only previews of that service are returned.
+func ExpiredPreviews(services []*runpb.Service, base string, now time.Time) []string {
+	var expired []string
+	for _, svc := range services {
+		if base != "" && svc.Labels[PreviewLabel] != base {
+			continue
+		}
+		expires, ok := PreviewExpiry(svc)
+		if !ok || now.Before(expires) {
+			continue
+		}
+		name := GetServiceName(svc.Name)
+		if name == "" {
+			name = svc.Name
+		}
+		expired = append(expired, name)
+	}
+	return expired
+}
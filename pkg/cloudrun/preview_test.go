@@ -0,0 +1,52 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+)
+
+func TestPreviewServices(t *testing.T) {
+	name, err := PreviewServiceName("my-svc", "PR-123")
+	if err != nil || name != "my-svc-pr-123" {
+		t.Errorf("expected my-svc-pr-123, got %q (%v)", name, err)
+	}
+	name, _ = PreviewServiceName("my-svc", "feature/a-very-long-branch-name-for-the-preview")
+	if len(name) > maxServiceNameLength || name[len(name)-1] == '-' {
+		t.Errorf("invalid service name %q", name)
+	}
+	if _, err := PreviewServiceName("my-svc", "/"); err == nil {
+		t.Error("expected error for empty identifier")
+	}
+
+	now := time.Now()
+	expired := &runpb.Service{Name: "projects/p/locations/r/services/my-svc-pr-1"}
+	MarkPreview(expired, "my-svc", now.Add(-time.Hour))
+	active := &runpb.Service{Name: "projects/p/locations/r/services/my-svc-pr-2"}
+	MarkPreview(active, "my-svc", now.Add(time.Hour))
+	other := &runpb.Service{Name: "projects/p/locations/r/services/other-pr-1"}
+	MarkPreview(other, "other", now.Add(-time.Hour))
+	services := []*runpb.Service{expired, active, other, {Name: "projects/p/locations/r/services/my-svc"}}
+
+	if got := ExpiredPreviews(services, "my-svc", now); len(got) != 1 || got[0] != "my-svc-pr-1" {
+		t.Errorf("unexpected expired previews: %v", got)
+	}
+	if got := ExpiredPreviews(services, "", now); len(got) != 2 {
+		t.Errorf("expected 2 expired previews, got %v", got)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+)
+
+// DefaultProgressInterval is the default interval between progress messages.
+const DefaultProgressInterval = 30 * time.Second
+
+// ProgressFunc receives progress messages of long waits, e.g. to write them to
+// the stage log.
+type ProgressFunc func(message string)
+
+type progressKey struct{}
+
+// progressConfig is the progress reporting attached to a context.
+type progressConfig struct {
+	fn       ProgressFunc
+	interval time.Duration
+}
+
+// WithProgress returns a context that makes the waits of this package report
+// their progress to fn at most once per interval, e.g.
+// "Waiting for service my-service to be ready (1m30s/10m0s), latest condition: Reconciling (Deploying revision)".
+func WithProgress(ctx context.Context, fn ProgressFunc, interval time.Duration) context.Context {
+	if interval <= 0 {
+		interval = DefaultProgressInterval
+	}
+	return context.WithValue(ctx, progressKey{}, &progressConfig{fn: fn, interval: interval})
+}
+
+// progress reports the progress of a single wait.
+type progress struct {
+	cfg     *progressConfig
+	what    string
+	start   time.Time
+	last    time.Time
+	timeout time.Duration
+}
+
+// startProgress starts reporting the progress of waiting for what. If timeout
+// is zero, the context deadline is used, if any.
+func startProgress(ctx context.Context, what string, timeout time.Duration) *progress {
+	cfg, _ := ctx.Value(progressKey{}).(*progressConfig)
+	now := time.Now()
+	if timeout <= 0 {
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout = deadline.Sub(now)
+		}
+	}
+	return &progress{
+		cfg:     cfg,
+		what:    what,
+		start:   now,
+		last:    now,
+		timeout: timeout,
+	}
+}
+
+// report reports the status if the interval has passed since the last report.
+func (p *progress) report(format string, args ...any) {
+	if p.cfg == nil {
+		return
+	}
+	now := time.Now()
+	if now.Sub(p.last) < p.cfg.interval {
+		return
+	}
+	p.last = now
+
+	elapsed := now.Sub(p.start).Round(time.Second)
+	var b strings.Builder
+	if p.timeout > 0 {
+		fmt.Fprintf(&b, "Waiting for %s (%s/%s)", p.what, elapsed, p.timeout.Round(time.Second))
+	} else {
+		fmt.Fprintf(&b, "Waiting for %s (%s)", p.what, elapsed)
+	}
+	if format != "" {
+		b.WriteString(", ")
+		fmt.Fprintf(&b, format, args...)
+	}
+	p.cfg.fn(b.String())
+}
+
+// formatCondition formats a condition for progress messages,
+// e.g. "Reconciling (Deploying revision)".
+func formatCondition(cond *runpb.Condition) string {
+	if cond == nil {
+		return "unknown"
+	}
+	state := strings.TrimPrefix(cond.State.String(), "CONDITION_")
+	state = strings.ToUpper(state[:1]) + strings.ToLower(state[1:])
+	if cond.Message == "" {
+		return state
+	}
+	return fmt.Sprintf("%s (%s)", state, cond.Message)
+}
@@ -0,0 +1,49 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+)
+
+func TestProgress(t *testing.T) {
+	var messages []string
+	ctx := WithProgress(context.Background(), func(message string) {
+		messages = append(messages, message)
+	}, time.Millisecond)
+
+	p := startProgress(ctx, "service my-service to be ready", 10*time.Minute)
+	cond := &runpb.Condition{State: runpb.Condition_CONDITION_RECONCILING, Message: "Deploying revision"}
+
+	p.report("latest condition: %s", formatCondition(cond))
+	if len(messages) != 0 {
+		t.Errorf("expected no message before the interval, got %v", messages)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	p.report("latest condition: %s", formatCondition(cond))
+	if len(messages) != 1 || !strings.HasPrefix(messages[0], "Waiting for service my-service to be ready (") ||
+		!strings.HasSuffix(messages[0], "/10m0s), latest condition: Reconciling (Deploying revision)") {
+		t.Errorf("unexpected messages: %v", messages)
+	}
+
+	// Without a progress function, nothing is reported
+	startProgress(context.Background(), "nothing", 0).report("")
+}
@@ -0,0 +1,51 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"google.golang.org/api/option"
+	pubsub "google.golang.org/api/pubsub/v1"
+)
+
+// PublishPubSubMessage publishes a message to a Pub/Sub topic, named
+// "projects/<project>/topics/<topic>".
+//
+// If credentialsFile is empty, Application Default Credentials will be used.
+// The credentials need the pubsub.topics.publish permission
+// (roles/pubsub.publisher).
+func PublishPubSubMessage(ctx context.Context, credentialsFile, topic string, data []byte, attributes map[string]string, opts ...option.ClientOption) error {
+	if credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	}
+	service, err := pubsub.NewService(ctx, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create pubsub client: %w", err)
+	}
+
+	req := &pubsub.PublishRequest{
+		Messages: []*pubsub.PubsubMessage{{
+			Data:       base64.StdEncoding.EncodeToString(data),
+			Attributes: attributes,
+		}},
+	}
+	if _, err := service.Projects.Topics.Publish(topic, req).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", topic, err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/option"
+)
+
+func TestPublishPubSubMessage(t *testing.T) {
+	var gotPath, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"messageIds": ["1"]}`)
+	}))
+	defer server.Close()
+
+	err := PublishPubSubMessage(context.Background(), "", "projects/p/topics/deployments", []byte(`{"event":"SUCCEEDED"}`), map[string]string{"event": "SUCCEEDED"},
+		option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotPath != "/v1/projects/p/topics/deployments:publish" {
+		t.Errorf("unexpected publish path %q", gotPath)
+	}
+	// {"event":"SUCCEEDED"} in base64
+	if !strings.Contains(gotBody, `"data":"eyJldmVudCI6IlNVQ0NFRURFRCJ9"`) || !strings.Contains(gotBody, `"attributes":{"event":"SUCCEEDED"}`) {
+		t.Errorf("unexpected publish request %q", gotBody)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+)
+
+// ErrQuotaExceeded is returned (wrapped) when a service or revision can't
+// scale to become ready because of a quota or limit, e.g. the CPU quota of
+// the region or the maximum number of instances. Use
+// errors.Is(err, ErrQuotaExceeded) to detect it.
+var ErrQuotaExceeded = errors.New("cloud run quota exceeded")
+
+// quotaMarkers are the phrases of condition messages reporting scaling
+// blocked by a quota or limit.
+var quotaMarkers = []string{
+	"quota",
+	"exhausted",
+	"limit reached",
+	"limit exceeded",
+	"limit was reached",
+	"instance limit",
+}
+
+// QuotaError describes a service or revision that did not become ready
+// because scaling was blocked by a quota or limit. It is distinct from a
+// readiness timeout of a revision that is slow to start.
+type QuotaError struct {
+	// Resource is the blocked resource, e.g. "service my-service".
+	Resource string
+
+	// Message is the message of the condition reporting the quota.
+	Message string
+
+	// Err is the error ending the wait, e.g. the context deadline, or nil if
+	// the condition failed.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *QuotaError) Error() string {
+	msg := fmt.Sprintf("%s can't scale because of a quota or limit: %s; request a quota increase or lower the instance count or resources", e.Resource, e.Message)
+	if e.Err != nil {
+		msg += fmt.Sprintf(" (%v)", e.Err)
+	}
+	return msg
+}
+
+// Unwrap returns the error ending the wait.
+func (e *QuotaError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the target is ErrQuotaExceeded.
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// QuotaCondition returns the first of the conditions that hasn't succeeded
+// and reports scaling blocked by a quota or limit, or nil if there is none.
+// A condition with warning severity means the resource is near the limit and
+// may still become ready, one that failed means it won't.
+func QuotaCondition(conds ...*runpb.Condition) *runpb.Condition {
+	for _, cond := range conds {
+		if cond == nil || cond.State == runpb.Condition_CONDITION_SUCCEEDED {
+			continue
+		}
+		if cond.GetRevisionReason() == runpb.Condition_ACTIVE_REVISION_LIMIT_REACHED {
+			return cond
+		}
+		msg := strings.ToLower(cond.Message)
+		for _, marker := range quotaMarkers {
+			if strings.Contains(msg, marker) {
+				return cond
+			}
+		}
+	}
+	return nil
+}
+
+// formatQuotaCondition formats a quota condition for progress reports, e.g.
+// "near quota: Quota exceeded for total allowable CPU per project per region".
+func formatQuotaCondition(cond *runpb.Condition) string {
+	if cond.State == runpb.Condition_CONDITION_FAILED {
+		return "blocked by quota: " + cond.Message
+	}
+	return "near quota: " + cond.Message
+}
@@ -0,0 +1,122 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+)
+
+func TestQuotaCondition(t *testing.T) {
+	tests := []struct {
+		name  string
+		cond  *runpb.Condition
+		quota bool
+	}{
+		{
+			name:  "CPU quota",
+			cond:  &runpb.Condition{Type: "Ready", State: runpb.Condition_CONDITION_FAILED, Message: "Quota exceeded for total allowable CPU per project per region."},
+			quota: true,
+		},
+		{
+			name:  "instance limit warning",
+			cond:  &runpb.Condition{Type: "Ready", State: runpb.Condition_CONDITION_RECONCILING, Severity: runpb.Condition_WARNING, Message: "Max instance limit reached, scaling is blocked"},
+			quota: true,
+		},
+		{
+			name: "active revision limit",
+			cond: &runpb.Condition{
+				Type:    "Active",
+				State:   runpb.Condition_CONDITION_FAILED,
+				Reasons: &runpb.Condition_RevisionReason_{RevisionReason: runpb.Condition_ACTIVE_REVISION_LIMIT_REACHED},
+			},
+			quota: true,
+		},
+		{
+			name:  "succeeded",
+			cond:  &runpb.Condition{Type: "Ready", State: runpb.Condition_CONDITION_SUCCEEDED, Message: "quota ok"},
+			quota: false,
+		},
+		{
+			name:  "container failure",
+			cond:  &runpb.Condition{Type: "Ready", State: runpb.Condition_CONDITION_FAILED, Message: "The user-provided container failed to start and listen on the port"},
+			quota: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := QuotaCondition(nil, tt.cond)
+			if (got != nil) != tt.quota {
+				t.Errorf("expected quota condition %v, got %v", tt.quota, got)
+			}
+		})
+	}
+}
+
+func TestQuotaError(t *testing.T) {
+	err := error(&QuotaError{Resource: "service app", Message: "Quota exceeded for total allowable CPU", Err: context.DeadlineExceeded})
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Error("expected the error to be ErrQuotaExceeded")
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("expected the error to wrap the deadline")
+	}
+	if !strings.Contains(err.Error(), "service app can't scale because of a quota or limit: Quota exceeded") {
+		t.Errorf("unexpected message %q", err)
+	}
+}
+
+func TestWaitForReadyInstances_Quota(t *testing.T) {
+	ctx := context.Background()
+	gate := ReadyInstancesGate{MinInstances: 1, Timeout: 10 * time.Millisecond, Interval: time.Millisecond}
+	counter := &fakeInstanceCounter{counts: map[string][]int64{}, calls: map[string]int{}}
+
+	pending := &revisionGetter{revisions: map[string]*runpb.Revision{
+		"app-00002": {
+			Name: "projects/p/locations/r/services/app/revisions/app-00002",
+			Conditions: []*runpb.Condition{{
+				Type:     "Ready",
+				State:    runpb.Condition_CONDITION_RECONCILING,
+				Severity: runpb.Condition_WARNING,
+				Message:  "Quota exceeded for total allowable CPU per project per region",
+			}},
+		},
+	}}
+	_, err := WaitForReadyInstances(ctx, pending, counter, "p", "r", "app", "app-00002", gate)
+	var quotaErr *QuotaError
+	if !errors.As(err, &quotaErr) || quotaErr.Err == nil {
+		t.Fatalf("expected a quota error ending with the timeout, got %v", err)
+	}
+
+	failed := &revisionGetter{revisions: map[string]*runpb.Revision{
+		"app-00002": {
+			Name: "projects/p/locations/r/services/app/revisions/app-00002",
+			Conditions: []*runpb.Condition{{
+				Type:    "Ready",
+				State:   runpb.Condition_CONDITION_FAILED,
+				Message: "Resource exhausted: max instances limit exceeded",
+			}},
+		},
+	}}
+	_, err = WaitForReadyInstances(ctx, failed, counter, "p", "r", "app", "app-00002", gate)
+	if !errors.As(err, &quotaErr) || quotaErr.Err != nil || quotaErr.Resource != "revision app-00002" {
+		t.Fatalf("expected a quota error of the failed revision, got %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RateLimit defines a token-bucket rate limit applied to Admin API calls per project.
+type RateLimit struct {
+	// QPS is the sustained number of calls per second. Zero disables rate limiting.
+	QPS float64
+
+	// Burst is the maximum number of calls allowed at once.
+	// Defaults to 1 when QPS is set.
+	Burst int
+
+	// MaxRetries is the maximum number of retries on RESOURCE_EXHAUSTED errors.
+	MaxRetries int
+
+	// InitialBackoff is the delay before the first retry. It doubles on each
+	// retry up to MaxBackoff.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between retries.
+	MaxBackoff time.Duration
+}
+
+// DefaultRateLimit returns the default rate limit configuration.
+// The Admin API allows roughly 1000 read and 180 write requests per minute
+// per project, so the defaults stay well below that while tolerating bursts.
+func DefaultRateLimit() RateLimit {
+	return RateLimit{
+		QPS:            5,
+		Burst:          10,
+		MaxRetries:     5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     30 * time.Second,
+	}
+}
+
+// WithRateLimit sets the rate limit and quota backoff used by the client.
+func WithRateLimit(limit RateLimit) ClientOption {
+	return func(o *clientOptions) {
+		o.rateLimit = limit
+	}
+}
+
+// projectLimiters holds one limiter per project shared by all clients in the
+// process, since clients are created per stage but quota is per project. The
+// first client calling the project sets the limit; the limits of clients
+// created later for the same project, e.g. by another deploy target, are
+// ignored until ResetRateLimiters.
+var projectLimiters = struct {
+	sync.Mutex
+	m map[string]*rate.Limiter
+}{m: make(map[string]*rate.Limiter)}
+
+// limiterFor returns the shared limiter for the project, created with the
+// given limit if the project has none yet.
+func limiterFor(project string, limit RateLimit) *rate.Limiter {
+	if limit.QPS <= 0 {
+		return nil
+	}
+	burst := limit.Burst
+	if burst <= 0 {
+		burst = 1
+	}
+
+	projectLimiters.Lock()
+	defer projectLimiters.Unlock()
+
+	l, ok := projectLimiters.m[project]
+	if !ok {
+		l = rate.NewLimiter(rate.Limit(limit.QPS), burst)
+		projectLimiters.m[project] = l
+	}
+	return l
+}
+
+// throttle runs fn under the project rate limit and retries it with
+// exponential backoff while it fails with RESOURCE_EXHAUSTED.
+func (c *client) throttle(ctx context.Context, project string, fn func() error) error {
+	limiter := limiterFor(project, c.rateLimit)
+	backoff := c.rateLimit.InitialBackoff
+
+	for attempt := 0; ; attempt++ {
+		if limiter != nil {
+			if err := limiter.Wait(ctx); err != nil {
+				return err
+			}
+		}
+
+		err := fn()
+		if err == nil || !IsQuotaExceeded(err) || attempt >= c.rateLimit.MaxRetries {
+			if c.callObserver != nil {
+				c.callObserver(err)
+			}
+			return err
+		}
+
+		// Full jitter to avoid synchronized retries across stages
+		delay := backoff
+		if delay > 0 {
+			delay = time.Duration(rand.Int63n(int64(delay)) + 1)
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+
+		backoff *= 2
+		if c.rateLimit.MaxBackoff > 0 && backoff > c.rateLimit.MaxBackoff {
+			backoff = c.rateLimit.MaxBackoff
+		}
+	}
+}
+
+// IsQuotaExceeded reports whether the error is a RESOURCE_EXHAUSTED error
+// returned by the Admin API.
+func IsQuotaExceeded(err error) bool {
+	if err == nil {
+		return false
+	}
+	s, ok := status.FromError(err)
+	return ok && s.Code() == codes.ResourceExhausted
+}
+
+// ResetRateLimiters drops the shared project limiters, e.g. after the plugin
+// config is reloaded. Clients already created get a new limiter on their
+// next call.
+func ResetRateLimiters() {
+	projectLimiters.Lock()
+	defer projectLimiters.Unlock()
+	projectLimiters.m = make(map[string]*rate.Limiter)
+}
@@ -0,0 +1,151 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLimiterFor(t *testing.T) {
+	t.Cleanup(ResetRateLimiters)
+	limit := RateLimit{QPS: 5, Burst: 10}
+
+	l := limiterFor("limiter-project-a", limit)
+	if l == nil || l.Limit() != 5 || l.Burst() != 10 {
+		t.Fatalf("unexpected limiter %+v", l)
+	}
+	if limiterFor("limiter-project-a", limit) != l {
+		t.Error("expected the limiter shared across calls for the project")
+	}
+	if limiterFor("limiter-project-b", limit) == l {
+		t.Error("expected a separate limiter per project")
+	}
+
+	// The first limit of the project is kept
+	if got := limiterFor("limiter-project-a", RateLimit{QPS: 2}); got != l || l.Limit() != rate.Limit(5) || l.Burst() != 10 {
+		t.Errorf("expected the shared limiter kept at 5 QPS, burst 10, got %v QPS, burst %d", l.Limit(), l.Burst())
+	}
+
+	if limiterFor("limiter-project-c", RateLimit{}) != nil {
+		t.Error("expected no limiter without QPS")
+	}
+
+	ResetRateLimiters()
+	if limiterFor("limiter-project-a", limit) == l {
+		t.Error("expected a new limiter after a reset")
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	quotaErr := status.Error(codes.ResourceExhausted, "quota exceeded")
+	limit := RateLimit{MaxRetries: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+	tests := []struct {
+		name     string
+		errs     []error
+		attempts int
+		wantErr  bool
+	}{
+		{name: "success", errs: []error{nil}, attempts: 1},
+		{name: "retried until success", errs: []error{quotaErr, quotaErr, nil}, attempts: 3},
+		{name: "retries exhausted", errs: []error{quotaErr, quotaErr, quotaErr, quotaErr, nil}, attempts: 4, wantErr: true},
+		{name: "other errors not retried", errs: []error{status.Error(codes.NotFound, "not found"), nil}, attempts: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var observed []error
+			c := &client{rateLimit: limit, callObserver: func(err error) {
+				observed = append(observed, err)
+			}}
+
+			attempts := 0
+			err := c.throttle(context.Background(), "throttle-project", func() error {
+				err := tt.errs[attempts]
+				attempts++
+				return err
+			})
+			if attempts != tt.attempts {
+				t.Errorf("expected %d attempts, got %d", tt.attempts, attempts)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error: %v, got %v", tt.wantErr, err)
+			}
+			// The observer sees the final result of the call only
+			if len(observed) != 1 || observed[0] != err {
+				t.Errorf("expected the final result observed once, got %v", observed)
+			}
+		})
+	}
+
+	t.Run("cancelled during backoff", func(t *testing.T) {
+		c := &client{rateLimit: RateLimit{MaxRetries: 5, InitialBackoff: time.Hour}}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		attempts := 0
+		err := c.throttle(ctx, "throttle-project", func() error {
+			attempts++
+			return quotaErr
+		})
+		if attempts != 1 || !IsQuotaExceeded(err) {
+			t.Errorf("expected the quota error after one attempt, got %v after %d", err, attempts)
+		}
+	})
+
+	t.Run("clients sharing a project", func(t *testing.T) {
+		t.Cleanup(ResetRateLimiters)
+		first := &client{rateLimit: RateLimit{QPS: 100, Burst: 1}}
+		second := &client{rateLimit: RateLimit{QPS: 1000, Burst: 10}}
+
+		started := time.Now()
+		for _, c := range []*client{first, second, second, second} {
+			if err := c.throttle(context.Background(), "throttle-shared-project", func() error { return nil }); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// The second client is held to the limit of the first, one call
+		// every 10ms after the burst, instead of its own burst of 10
+		if elapsed := time.Since(started); elapsed < 25*time.Millisecond {
+			t.Errorf("expected the calls spaced by the first client's limit, took %s", elapsed)
+		}
+		// The limiter keeps the first client's limit
+		if l := limiterFor("throttle-shared-project", second.rateLimit); l.Limit() != 100 || l.Burst() != 1 {
+			t.Errorf("expected the first client's limit kept, got %v QPS, burst %d", l.Limit(), l.Burst())
+		}
+	})
+
+	t.Run("rate limited", func(t *testing.T) {
+		t.Cleanup(ResetRateLimiters)
+		c := &client{rateLimit: RateLimit{QPS: 100, Burst: 1}}
+
+		started := time.Now()
+		for i := 0; i < 3; i++ {
+			if err := c.throttle(context.Background(), "throttle-limited-project", func() error { return nil }); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// The burst allows one call, then one call every 10ms
+		if elapsed := time.Since(started); elapsed < 15*time.Millisecond {
+			t.Errorf("expected the calls spaced by the rate limit, took %s", elapsed)
+		}
+	})
+}
@@ -0,0 +1,171 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	artifactregistry "google.golang.org/api/artifactregistry/v1"
+	cloudresourcemanager "google.golang.org/api/cloudresourcemanager/v1"
+	"google.golang.org/api/option"
+)
+
+// ArtifactRegistryImage is an image stored in Artifact Registry.
+type ArtifactRegistryImage struct {
+	Location   string
+	Project    string
+	Repository string
+}
+
+// RepositoryName returns projects/{project}/locations/{location}/repositories/{repository}.
+func (i ArtifactRegistryImage) RepositoryName() string {
+	return fmt.Sprintf("projects/%s/locations/%s/repositories/%s", i.Project, i.Location, i.Repository)
+}
+
+// ParseArtifactRegistryImage parses an image such as
+// "us-docker.pkg.dev/my-project/my-repo/app:v1". It returns false for images
+// outside of Artifact Registry.
+func ParseArtifactRegistryImage(image string) (ArtifactRegistryImage, bool) {
+	parts := strings.Split(image, "/")
+	if len(parts) < 4 || !strings.HasSuffix(parts[0], "-docker.pkg.dev") {
+		return ArtifactRegistryImage{}, false
+	}
+	return ArtifactRegistryImage{
+		Location:   strings.TrimSuffix(parts[0], "-docker.pkg.dev"),
+		Project:    parts[1],
+		Repository: parts[2],
+	}, true
+}
+
+// imageReaderRoles are the predefined roles allowing to pull images.
+var imageReaderRoles = []string{
+	"roles/artifactregistry.reader",
+	"roles/artifactregistry.writer",
+	"roles/artifactregistry.repoAdmin",
+	"roles/artifactregistry.admin",
+	"roles/viewer",
+	"roles/editor",
+	"roles/owner",
+}
+
+// ImagePullAccess is the outcome of CheckImagePullAccess.
+type ImagePullAccess struct {
+	// Granted reports whether one of the members can pull the image.
+	Granted bool
+
+	// ServiceAgent is the Cloud Run service agent of the deploy project,
+	// which pulls the images.
+	ServiceAgent string
+
+	// RuntimeServiceAccount is the service account the revision runs as.
+	RuntimeServiceAccount string
+}
+
+// Remediation returns the command granting the Cloud Run service agent
+// access to the repository of the image.
+func (a ImagePullAccess) Remediation(img ArtifactRegistryImage) string {
+	return fmt.Sprintf(
+		"gcloud artifacts repositories add-iam-policy-binding %s --project=%s --location=%s --member=serviceAccount:%s --role=roles/artifactregistry.reader",
+		img.Repository, img.Project, img.Location, a.ServiceAgent,
+	)
+}
+
+// CheckImagePullAccess checks whether the Cloud Run service agent or the
+// runtime service account of the deploy project can pull an image from
+// another project, from the IAM policies of the repository and its project.
+// Conditional bindings are assumed to grant access. If runtimeServiceAccount
+// is empty, the Compute Engine default service account is assumed.
+//
+// The credentials need permission to get the deploy project and the IAM
+// policies of the repository and its project (e.g. roles/iam.securityReviewer).
+func CheckImagePullAccess(ctx context.Context, credentialsFile, deployProject, runtimeServiceAccount string, img ArtifactRegistryImage) (ImagePullAccess, error) {
+	var opts []option.ClientOption
+	if credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	}
+
+	crm, err := cloudresourcemanager.NewService(ctx, opts...)
+	if err != nil {
+		return ImagePullAccess{}, fmt.Errorf("failed to create resource manager client: %w", err)
+	}
+	project, err := crm.Projects.Get(deployProject).Context(ctx).Do()
+	if err != nil {
+		return ImagePullAccess{}, fmt.Errorf("failed to get project %s: %w", deployProject, err)
+	}
+
+	access := ImagePullAccess{
+		ServiceAgent:          fmt.Sprintf("service-%d@serverless-robot-prod.iam.gserviceaccount.com", project.ProjectNumber),
+		RuntimeServiceAccount: runtimeServiceAccount,
+	}
+	if access.RuntimeServiceAccount == "" {
+		access.RuntimeServiceAccount = fmt.Sprintf("%d-compute@developer.gserviceaccount.com", project.ProjectNumber)
+	}
+	members := []string{
+		"serviceAccount:" + access.ServiceAgent,
+		"serviceAccount:" + access.RuntimeServiceAccount,
+		"allUsers",
+		"allAuthenticatedUsers",
+	}
+
+	ar, err := artifactregistry.NewService(ctx, opts...)
+	if err != nil {
+		return ImagePullAccess{}, fmt.Errorf("failed to create Artifact Registry client: %w", err)
+	}
+	repoPolicy, err := ar.Projects.Locations.Repositories.GetIamPolicy(img.RepositoryName()).
+		OptionsRequestedPolicyVersion(3).
+		Context(ctx).
+		Do()
+	if err != nil {
+		return ImagePullAccess{}, fmt.Errorf("failed to get IAM policy of repository %s: %w", img.RepositoryName(), err)
+	}
+	for _, b := range repoPolicy.Bindings {
+		if grantsImagePull(b.Role, b.Members, members) {
+			access.Granted = true
+			return access, nil
+		}
+	}
+
+	projectPolicy, err := crm.Projects.GetIamPolicy(img.Project, &cloudresourcemanager.GetIamPolicyRequest{
+		Options: &cloudresourcemanager.GetPolicyOptions{RequestedPolicyVersion: 3},
+	}).Context(ctx).Do()
+	if err != nil {
+		return ImagePullAccess{}, fmt.Errorf("failed to get IAM policy of project %s: %w", img.Project, err)
+	}
+	for _, b := range projectPolicy.Bindings {
+		if grantsImagePull(b.Role, b.Members, members) {
+			access.Granted = true
+			return access, nil
+		}
+	}
+
+	return access, nil
+}
+
+// grantsImagePull reports whether a binding grants one of the members a role
+// allowing to pull images.
+func grantsImagePull(role string, bindingMembers, members []string) bool {
+	if !slices.Contains(imageReaderRoles, role) {
+		return false
+	}
+	for _, m := range members {
+		if slices.Contains(bindingMembers, m) {
+			return true
+		}
+	}
+	return false
+}
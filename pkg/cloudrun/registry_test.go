@@ -0,0 +1,41 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import "testing"
+
+func TestParseArtifactRegistryImage(t *testing.T) {
+	img, ok := ParseArtifactRegistryImage("us-central1-docker.pkg.dev/shared-images/apps/api@sha256:abc")
+	if !ok {
+		t.Fatal("expected Artifact Registry image")
+	}
+	if got, want := img.RepositoryName(), "projects/shared-images/locations/us-central1/repositories/apps"; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	for _, image := range []string{"gcr.io/my-project/app:v1", "nginx:latest", "us-docker.pkg.dev/my-project"} {
+		if _, ok := ParseArtifactRegistryImage(image); ok {
+			t.Errorf("expected %s not to be parsed as an Artifact Registry image", image)
+		}
+	}
+
+	members := []string{"serviceAccount:service-123@serverless-robot-prod.iam.gserviceaccount.com"}
+	if !grantsImagePull("roles/artifactregistry.reader", members, members) {
+		t.Error("expected reader role to grant pull access")
+	}
+	if grantsImagePull("roles/run.invoker", members, members) {
+		t.Error("expected other roles not to grant pull access")
+	}
+}
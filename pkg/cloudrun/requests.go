@@ -0,0 +1,169 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	monitoring "google.golang.org/api/monitoring/v3"
+	"google.golang.org/api/option"
+)
+
+// RequestCounts is the number of requests served by a revision per response class.
+type RequestCounts struct {
+	Success     int64
+	ClientError int64
+	ServerError int64
+}
+
+// RequestCounter reports the number of requests served by a revision.
+type RequestCounter interface {
+	// CountRequests returns the number of requests the revision served since the given time.
+	CountRequests(ctx context.Context, project, region, service, revision string, since time.Time) (RequestCounts, error)
+}
+
+// requestCountMetric is the Cloud Monitoring metric for Cloud Run request counts.
+const requestCountMetric = "run.googleapis.com/request_count"
+
+// monitoringRequestCounter counts requests using Cloud Monitoring.
+type monitoringRequestCounter struct {
+	service *monitoring.Service
+}
+
+// NewRequestCounter creates a RequestCounter backed by Cloud Monitoring.
+//
+// If credentialsFile is empty, Application Default Credentials will be used.
+// The credentials need the monitoring.timeSeries.list permission
+// (e.g. roles/monitoring.viewer).
+func NewRequestCounter(ctx context.Context, credentialsFile string) (RequestCounter, error) {
+	var opts []option.ClientOption
+	if credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	}
+
+	service, err := monitoring.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create monitoring client: %w", err)
+	}
+
+	return &monitoringRequestCounter{service: service}, nil
+}
+
+// CountRequests sums the request count samples of the revision since the given
+// time, grouped by response code class.
+func (c *monitoringRequestCounter) CountRequests(ctx context.Context, project, region, service, revision string, since time.Time) (RequestCounts, error) {
+	// The metric is labelled with the short revision name
+	revision = ShortRevisionName(revision)
+
+	filter := fmt.Sprintf(
+		`metric.type=%q AND resource.labels.location=%q AND resource.labels.service_name=%q AND resource.labels.revision_name=%q`,
+		requestCountMetric, region, service, revision,
+	)
+
+	var counts RequestCounts
+	err := c.service.Projects.TimeSeries.List("projects/"+project).
+		Filter(filter).
+		IntervalStartTime(since.Format(time.RFC3339)).
+		IntervalEndTime(time.Now().Format(time.RFC3339)).
+		Pages(ctx, func(resp *monitoring.ListTimeSeriesResponse) error {
+			for _, ts := range resp.TimeSeries {
+				var total int64
+				for _, p := range ts.Points {
+					if p.Value != nil && p.Value.Int64Value != nil {
+						total += *p.Value.Int64Value
+					}
+				}
+
+				var class string
+				if ts.Metric != nil {
+					class = ts.Metric.Labels["response_code_class"]
+				}
+				switch class {
+				case "2xx":
+					counts.Success += total
+				case "4xx":
+					counts.ClientError += total
+				case "5xx":
+					counts.ServerError += total
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return RequestCounts{}, fmt.Errorf("failed to query request count: %w", err)
+	}
+
+	return counts, nil
+}
+
+// SuccessfulRequestsGate defines how long to wait for a revision to serve
+// enough successful requests.
+type SuccessfulRequestsGate struct {
+	// MinRequests is the minimum number of 2xx responses.
+	MinRequests int64
+
+	// Since is the start of the counted period, e.g. the revision creation time.
+	Since time.Time
+
+	// Timeout is how long to wait before failing.
+	Timeout time.Duration
+
+	// Interval is the delay between checks.
+	// Default: 30s
+	Interval time.Duration
+}
+
+// WaitForSuccessfulRequests waits until the revision has served at least
+// gate.MinRequests successful (2xx) requests according to Cloud Monitoring.
+// It returns the last observed counts.
+func WaitForSuccessfulRequests(
+	ctx context.Context,
+	counter RequestCounter,
+	project, region, service, revision string,
+	gate SuccessfulRequestsGate,
+) (RequestCounts, error) {
+	interval := gate.Interval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	deadline := time.Now().Add(gate.Timeout)
+	progress := startProgress(ctx, fmt.Sprintf("revision %s to serve %d successful request(s)", revision, gate.MinRequests), gate.Timeout)
+
+	for {
+		counts, err := counter.CountRequests(ctx, project, region, service, revision, gate.Since)
+		if err != nil {
+			return RequestCounts{}, err
+		}
+		if counts.Success >= gate.MinRequests {
+			return counts, nil
+		}
+		progress.report("successful requests: %d, 5xx: %d, 4xx: %d", counts.Success, counts.ServerError, counts.ClientError)
+
+		if !time.Now().Add(interval).Before(deadline) {
+			return counts, fmt.Errorf(
+				"revision %s served %d successful request(s) after %s (expected at least %d, %d 5xx and %d 4xx responses)",
+				revision, counts.Success, gate.Timeout, gate.MinRequests, counts.ServerError, counts.ClientError,
+			)
+		}
+
+		select {
+		case <-ctx.Done():
+			return counts, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type fakeRequestCounter struct {
+	counts []RequestCounts
+	calls  int
+}
+
+func (c *fakeRequestCounter) CountRequests(context.Context, string, string, string, string, time.Time) (RequestCounts, error) {
+	counts := c.counts[min(c.calls, len(c.counts)-1)]
+	c.calls++
+	return counts, nil
+}
+
+func TestWaitForSuccessfulRequests(t *testing.T) {
+	ctx := context.Background()
+	gate := SuccessfulRequestsGate{
+		MinRequests: 5,
+		Timeout:     50 * time.Millisecond,
+		Interval:    time.Millisecond,
+	}
+
+	counter := &fakeRequestCounter{counts: []RequestCounts{{}, {Success: 2}, {Success: 5}}}
+	counts, err := WaitForSuccessfulRequests(ctx, counter, "p", "r", "my-service", "my-service-00002", gate)
+	if err != nil || counts.Success != 5 {
+		t.Errorf("expected 5 successful requests, got %v (%v)", counts, err)
+	}
+
+	// A revision failing every request never passes the gate
+	counter = &fakeRequestCounter{counts: []RequestCounts{{ServerError: 100}}}
+	if _, err := WaitForSuccessfulRequests(ctx, counter, "p", "r", "my-service", "my-service-00002", gate); err == nil {
+		t.Error("expected error for a revision failing every request")
+	}
+}
@@ -17,20 +17,37 @@ package cloudrun
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/run/apiv2/runpb"
 )
 
+// DefaultRevisionConcurrency is the default number of concurrent calls made
+// by a RevisionManager to fetch revisions.
+const DefaultRevisionConcurrency = 8
+
 // RevisionManager provides operations for managing Cloud Run revisions.
 type RevisionManager struct {
-	client Client
+	client      Client
+	concurrency int
 }
 
 // NewRevisionManager creates a new RevisionManager.
 func NewRevisionManager(client Client) *RevisionManager {
-	return &RevisionManager{client: client}
+	return &RevisionManager{client: client, concurrency: DefaultRevisionConcurrency}
+}
+
+// WithConcurrency sets the number of concurrent calls used to fetch
+// revisions. Values below 1 fetch them one at a time.
+func (rm *RevisionManager) WithConcurrency(n int) *RevisionManager {
+	rm.concurrency = max(n, 1)
+	return rm
 }
 
 // RevisionInfo contains information about a Cloud Run revision.
@@ -44,48 +61,253 @@ type RevisionInfo struct {
 	TrafficPercent int32
 	IsLatest       bool
 	Conditions     map[string]bool
+
+	// Tags are the traffic tags pointing at the revision by name.
+	Tags []string
+
+	// Revision is the revision the info was built from.
+	Revision *runpb.Revision
 }
 
-// ListRevisions lists all revisions for a service.
+// ListRevisions lists all revisions for a service, newest first, with their
+// traffic. The revisions and the service are fetched concurrently.
 func (rm *RevisionManager) ListRevisions(ctx context.Context, project, region, service string) ([]*RevisionInfo, error) {
+	infos, _, err := rm.listRevisionsWithService(ctx, project, region, service)
+	return infos, err
+}
+
+// listRevisionsWithService lists the revisions with their traffic, and
+// returns the service they were enriched from.
+func (rm *RevisionManager) listRevisionsWithService(ctx context.Context, project, region, service string) ([]*RevisionInfo, *runpb.Service, error) {
+	var (
+		wg     sync.WaitGroup
+		svc    *runpb.Service
+		svcErr error
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		svc, svcErr = rm.client.GetService(ctx, project, region, service)
+	}()
+
+	infos, err := rm.ListRevisionsWithoutTraffic(ctx, project, region, service)
+	wg.Wait()
+	if err != nil {
+		return nil, nil, err
+	}
+	if svcErr != nil {
+		return nil, nil, svcErr
+	}
+
+	EnrichRevisions(infos, svc)
+	return infos, svc, nil
+}
+
+// ListRevisionsWithoutTraffic lists all revisions for a service, newest first,
+// without reading the service. TrafficPercent, IsLatest, and Tags are not set;
+// use EnrichRevisions to set them.
+func (rm *RevisionManager) ListRevisionsWithoutTraffic(ctx context.Context, project, region, service string) ([]*RevisionInfo, error) {
 	revisions, err := rm.client.ListRevisions(ctx, project, region, service)
 	if err != nil {
 		return nil, err
 	}
+	return RevisionInfos(revisions, nil), nil
+}
 
-	// Get current service to find latest revision
-	svc, err := rm.client.GetService(ctx, project, region, service)
+// ListRevisionsFiltered lists the revisions matching all of the filters,
+// newest first, with their traffic.
+//
+// Example (old revisions serving no traffic):
+//
+//	rm.ListRevisionsFiltered(ctx, project, region, service,
+//		cloudrun.Not(cloudrun.HasTraffic()), cloudrun.OlderThan(time.Now().Add(-7*24*time.Hour)))
+func (rm *RevisionManager) ListRevisionsFiltered(ctx context.Context, project, region, service string, filters ...RevisionFilter) ([]*RevisionInfo, error) {
+	revisions, err := rm.ListRevisions(ctx, project, region, service)
 	if err != nil {
 		return nil, err
 	}
+	return FilterRevisions(revisions, filters...), nil
+}
 
-	latestRevision := ""
-	if svc.Template != nil {
-		latestRevision = svc.Template.Revision
+// GetRevisions fetches the revisions concurrently, newest first. Like
+// ListRevisionsWithoutTraffic, the traffic fields are not set.
+func (rm *RevisionManager) GetRevisions(ctx context.Context, project, region, service string, names []string) ([]*RevisionInfo, error) {
+	infos := make([]*RevisionInfo, len(names))
+	err := forEachConcurrently(ctx, rm.concurrency, len(names), func(ctx context.Context, i int) error {
+		rev, err := rm.client.GetRevision(ctx, project, region, service, names[i])
+		if err != nil {
+			return fmt.Errorf("failed to get revision %s: %w", names[i], err)
+		}
+		infos[i] = newRevisionInfo(rev)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	sortRevisionsNewestFirst(infos)
+	return infos, nil
+}
 
-	// Build traffic map
-	trafficMap := make(map[string]int32)
-	for _, t := range svc.Traffic {
-		if t.Type == runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST {
-			trafficMap[latestRevision] = t.Percent
-		} else if t.Type == runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION {
-			trafficMap[t.Revision] = t.Percent
+// RevisionInfos builds the RevisionInfo of the revisions, newest first. If
+// svc is set, the traffic fields are set from it.
+func RevisionInfos(revisions []*runpb.Revision, svc *runpb.Service) []*RevisionInfo {
+	infos := make([]*RevisionInfo, 0, len(revisions))
+	for _, rev := range revisions {
+		infos = append(infos, newRevisionInfo(rev))
+	}
+	sortRevisionsNewestFirst(infos)
+	if svc != nil {
+		EnrichRevisions(infos, svc)
+	}
+	return infos
+}
+
+// EnrichRevisions sets the traffic percent, tags, and latest flag of the
+// revisions from the service's traffic and template.
+func EnrichRevisions(infos []*RevisionInfo, svc *runpb.Service) {
+	latestRevision, trafficMap, tagMap := serviceTrafficMaps(svc)
+	for _, info := range infos {
+		info.TrafficPercent = trafficMap[info.Name]
+		info.IsLatest = info.Name == latestRevision
+		info.Tags = tagMap[info.Name]
+	}
+}
+
+// RevisionFilter reports whether a revision is selected.
+type RevisionFilter func(rev *RevisionInfo) bool
+
+// HasTraffic selects revisions serving traffic.
+func HasTraffic() RevisionFilter {
+	return func(rev *RevisionInfo) bool {
+		return rev.TrafficPercent > 0
+	}
+}
+
+// OlderThan selects revisions created before t.
+func OlderThan(t time.Time) RevisionFilter {
+	return func(rev *RevisionInfo) bool {
+		return rev.CreatedAt.Before(t)
+	}
+}
+
+// Tagged selects revisions with a traffic tag.
+func Tagged() RevisionFilter {
+	return func(rev *RevisionInfo) bool {
+		return len(rev.Tags) > 0
+	}
+}
+
+// Unhealthy selects revisions whose Ready condition failed. Revisions that
+// are still being reconciled are not selected.
+func Unhealthy() RevisionFilter {
+	return func(rev *RevisionInfo) bool {
+		ready, ok := rev.Conditions["Ready"]
+		return ok && !ready
+	}
+}
+
+// Ready selects revisions whose Ready condition succeeded.
+func Ready() RevisionFilter {
+	return func(rev *RevisionInfo) bool {
+		return rev.Conditions["Ready"]
+	}
+}
+
+// Named selects the revisions with one of the names (short or full).
+func Named(names ...string) RevisionFilter {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[ShortRevisionName(name)] = true
+	}
+	return func(rev *RevisionInfo) bool {
+		return set[rev.Name]
+	}
+}
+
+// Or selects the revisions selected by any of the filters.
+func Or(filters ...RevisionFilter) RevisionFilter {
+	return func(rev *RevisionInfo) bool {
+		for _, f := range filters {
+			if f(rev) {
+				return true
+			}
 		}
+		return false
 	}
+}
+
+// Not selects the revisions not selected by f.
+func Not(f RevisionFilter) RevisionFilter {
+	return func(rev *RevisionInfo) bool {
+		return !f(rev)
+	}
+}
 
-	var infos []*RevisionInfo
+// FilterRevisions returns the revisions matching all of the filters, in order.
+func FilterRevisions(revisions []*RevisionInfo, filters ...RevisionFilter) []*RevisionInfo {
+	var matched []*RevisionInfo
+next:
 	for _, rev := range revisions {
-		info := rm.buildRevisionInfo(rev, latestRevision, trafficMap)
-		infos = append(infos, info)
+		for _, f := range filters {
+			if !f(rev) {
+				continue next
+			}
+		}
+		matched = append(matched, rev)
 	}
+	return matched
+}
 
-	// Sort by creation time (newest first)
+// sortRevisionsNewestFirst sorts revisions by creation time, newest first.
+func sortRevisionsNewestFirst(infos []*RevisionInfo) {
 	sort.Slice(infos, func(i, j int) bool {
 		return infos[i].CreatedAt.After(infos[j].CreatedAt)
 	})
+}
 
-	return infos, nil
+// forEachConcurrently calls fn for 0..n-1 with at most workers calls at a
+// time. Once a call fails, the context of the other calls is cancelled and
+// no new call is started; the first error is returned.
+func forEachConcurrently(ctx context.Context, workers, n int, fn func(ctx context.Context, i int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	indexes := make(chan int)
+	for w := 0; w < min(max(workers, 1), n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := fn(ctx, i); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := 0; i < n; i++ {
+		select {
+		case <-ctx.Done():
+			break feed
+		case indexes <- i:
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
 }
 
 // GetRevision gets detailed information about a specific revision.
@@ -101,21 +323,9 @@ func (rm *RevisionManager) GetRevision(ctx context.Context, project, region, ser
 		return nil, err
 	}
 
-	latestRevision := ""
-	if svc.Template != nil {
-		latestRevision = svc.Template.Revision
-	}
-
-	trafficMap := make(map[string]int32)
-	for _, t := range svc.Traffic {
-		if t.Type == runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST {
-			trafficMap[latestRevision] = t.Percent
-		} else if t.Type == runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION {
-			trafficMap[t.Revision] = t.Percent
-		}
-	}
-
-	return rm.buildRevisionInfo(rev, latestRevision, trafficMap), nil
+	info := newRevisionInfo(rev)
+	EnrichRevisions([]*RevisionInfo{info}, svc)
+	return info, nil
 }
 
 // DeleteRevision deletes a specific revision.
@@ -128,19 +338,68 @@ func (rm *RevisionManager) DeleteRevision(ctx context.Context, project, region,
 //   - keepCount: Number of recent revisions to keep
 //   - keepLatest: Whether to always keep the latest revision
 func (rm *RevisionManager) CleanupOldRevisions(ctx context.Context, project, region, service string, keepCount int, keepLatest bool) error {
-	revisions, err := rm.ListRevisions(ctx, project, region, service)
-	if err != nil {
-		return err
-	}
+	_, err := rm.CleanupRevisions(ctx, project, region, service, CleanupOptions{
+		KeepCount:  keepCount,
+		KeepLatest: keepLatest,
+	})
+	return err
+}
 
-	if len(revisions) <= keepCount {
-		return nil // Nothing to clean up
-	}
+// CleanupOptions defines options for CleanupRevisions.
+type CleanupOptions struct {
+	// KeepCount is the number of recent revisions to keep.
+	KeepCount int
 
-	// Get latest revision name
-	svc, err := rm.client.GetService(ctx, project, region, service)
+	// KeepLatest indicates whether to always keep the latest revision.
+	KeepLatest bool
+
+	// DrainWait is how long to wait before deleting revisions with 0% traffic,
+	// so in-flight requests can complete.
+	DrainWait time.Duration
+
+	// InstanceCounter, if set, is used to check that a revision has no running
+	// instances before it is deleted. Revisions still running instances after
+	// DrainTimeout are skipped.
+	InstanceCounter InstanceCounter
+
+	// DrainTimeout is how long to wait for a revision's instances to reach zero.
+	DrainTimeout time.Duration
+
+	// DrainPollInterval is how often the instance count is checked.
+	DrainPollInterval time.Duration
+
+	// KeepTagged is the number of most recent tagged revisions to keep
+	// regardless of KeepCount, e.g. for per-release preview URLs.
+	// Tags of other deleted revisions are removed first.
+	KeepTagged int
+
+	// Protected are revisions that are never deleted, e.g. the rollback target.
+	Protected []string
+}
+
+// CleanupResult contains the outcome of CleanupRevisions.
+type CleanupResult struct {
+	// Deleted is the list of deleted revisions.
+	Deleted []string
+
+	// Skipped is the list of revisions not deleted because they were still draining.
+	Skipped []string
+
+	// Untagged is the list of tags removed from deleted revisions.
+	Untagged []string
+}
+
+// CleanupRevisions removes old revisions that have no traffic, optionally
+// waiting for them to drain first.
+func (rm *RevisionManager) CleanupRevisions(ctx context.Context, project, region, service string, opts CleanupOptions) (*CleanupResult, error) {
+	revisions, svc, err := rm.listRevisionsWithService(ctx, project, region, service)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	result := &CleanupResult{}
+	if len(revisions) <= opts.KeepCount {
+		return result, nil // Nothing to clean up
 	}
 
 	latestRevision := ""
@@ -148,29 +407,123 @@ func (rm *RevisionManager) CleanupOldRevisions(ctx context.Context, project, reg
 		latestRevision = svc.Template.Revision
 	}
 
-	// Delete old revisions with no traffic
-	deleted := 0
-	for i, rev := range revisions {
-		// Keep the specified number of recent revisions
-		if i < keepCount {
-			continue
+	// Keep the most recent tagged revisions (revisions are sorted newest first)
+	keptTagged := make(map[string]bool, opts.KeepTagged)
+	for _, rev := range FilterRevisions(revisions, Tagged()) {
+		if len(keptTagged) == opts.KeepTagged {
+			break
 		}
+		keptTagged[rev.Name] = true
+	}
 
-		// Skip if this is the latest revision and keepLatest is true
-		if keepLatest && rev.Name == latestRevision {
-			continue
+	// Collect old revisions with no traffic, keeping the specified number of
+	// recent revisions
+	var candidates []*RevisionInfo
+	if opts.KeepCount < len(revisions) {
+		candidates = FilterRevisions(revisions[max(opts.KeepCount, 0):], Not(HasTraffic()), func(rev *RevisionInfo) bool {
+			if keptTagged[rev.Name] || slices.Contains(opts.Protected, rev.Name) {
+				return false
+			}
+			// Skip if this is the latest revision and keepLatest is true
+			return !opts.KeepLatest || rev.Name != latestRevision
+		})
+	}
+
+	if len(candidates) == 0 {
+		return result, nil
+	}
+
+	// A revision can't be deleted while a tag points at it
+	traffic, untagged := removeRevisionTags(svc.Traffic, candidates)
+	if len(untagged) > 0 {
+		if err := rm.client.UpdateTraffic(ctx, project, region, service, traffic); err != nil {
+			return nil, fmt.Errorf("failed to remove tags of revisions to delete: %w", err)
 		}
+		result.Untagged = untagged
+	}
+
+	// Give in-flight requests time to complete
+	if opts.DrainWait > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(opts.DrainWait):
+		}
+	}
 
-		// Only delete revisions with 0% traffic
-		if rev.TrafficPercent == 0 {
-			if err := rm.client.DeleteRevision(ctx, project, region, service, rev.Name); err != nil {
-				return fmt.Errorf("failed to delete revision %s: %w", rev.Name, err)
+	for _, rev := range candidates {
+		if opts.InstanceCounter != nil {
+			drained, err := rm.waitForZeroInstances(ctx, project, region, service, rev.Name, opts)
+			if err != nil {
+				return result, fmt.Errorf("failed to check instances of revision %s: %w", rev.Name, err)
 			}
-			deleted++
+			if !drained {
+				result.Skipped = append(result.Skipped, rev.Name)
+				continue
+			}
+		}
+
+		if err := rm.client.DeleteRevision(ctx, project, region, service, rev.Name); err != nil {
+			return result, fmt.Errorf("failed to delete revision %s: %w", rev.Name, err)
 		}
+		result.Deleted = append(result.Deleted, rev.Name)
 	}
 
-	return nil
+	return result, nil
+}
+
+// removeRevisionTags returns the traffic targets without the tagged 0% targets
+// of the revisions, and the removed tags.
+func removeRevisionTags(traffic []*runpb.TrafficTarget, revisions []*RevisionInfo) ([]*runpb.TrafficTarget, []string) {
+	names := make(map[string]bool, len(revisions))
+	for _, rev := range revisions {
+		names[rev.Name] = true
+	}
+
+	var (
+		kept    []*runpb.TrafficTarget
+		removed []string
+	)
+	for _, t := range traffic {
+		if t.Type == runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION &&
+			t.Percent == 0 && t.Tag != "" && names[t.Revision] {
+			removed = append(removed, t.Tag)
+			continue
+		}
+		kept = append(kept, t)
+	}
+	return kept, removed
+}
+
+// waitForZeroInstances polls the instance count of a revision until it is zero
+// or the drain timeout expires. It returns false if the revision still has instances.
+func (rm *RevisionManager) waitForZeroInstances(ctx context.Context, project, region, service, revision string, opts CleanupOptions) (bool, error) {
+	interval := opts.DrainPollInterval
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+	deadline := time.Now().Add(opts.DrainTimeout)
+	progress := startProgress(ctx, fmt.Sprintf("revision %s to drain", revision), opts.DrainTimeout)
+
+	for {
+		count, err := opts.InstanceCounter.CountInstances(ctx, project, region, service, revision)
+		if err != nil {
+			return false, err
+		}
+		if count == 0 {
+			return true, nil
+		}
+		progress.report("instances: %d", count)
+		if !time.Now().Add(interval).Before(deadline) {
+			return false, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
 }
 
 // GetLatestRevision returns the latest revision of a service.
@@ -187,27 +540,74 @@ func (rm *RevisionManager) GetLatestRevision(ctx context.Context, project, regio
 	return revisions[0], nil
 }
 
-// GetPreviousRevision returns the previous revision (second most recent).
-func (rm *RevisionManager) GetPreviousRevision(ctx context.Context, project, region, service string) (*RevisionInfo, error) {
-	revisions, err := rm.ListRevisions(ctx, project, region, service)
+// GetPreviousRevision returns the most recent revision, other than the
+// latest created one, that is Ready and carried traffic: it serves traffic
+// now or is one of the served revisions (e.g. from a recorded traffic
+// history). If served is empty, any Ready revision qualifies.
+//
+// Revisions are listed page by page and the listing stops at the first page
+// with a match, so services with many revisions are not listed entirely.
+func (rm *RevisionManager) GetPreviousRevision(ctx context.Context, project, region, service string, served []string) (*RevisionInfo, error) {
+	svc, err := rm.client.GetService(ctx, project, region, service)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(revisions) < 2 {
-		return nil, fmt.Errorf("no previous revision found for service %s", service)
+	filters := []RevisionFilter{Ready(), Not(Named(svc.LatestCreatedRevision))}
+	if len(served) > 0 {
+		filters = append(filters, Or(HasTraffic(), Named(served...)))
 	}
+	var found *RevisionInfo
+	err = rm.client.ListRevisionPages(ctx, project, region, service, func(page []*runpb.Revision) bool {
+		if matched := FilterRevisions(RevisionInfos(page, svc), filters...); len(matched) > 0 {
+			found = matched[0]
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		if len(served) > 0 {
+			return nil, fmt.Errorf("no ready previous revision that served traffic found for service %s", service)
+		}
+		return nil, fmt.Errorf("no ready previous revision found for service %s", service)
+	}
+	return found, nil
+}
 
-	return revisions[1], nil
+// serviceTrafficMaps returns the latest revision of the service template, and
+// the traffic percent and the tags of each revision.
+func serviceTrafficMaps(svc *runpb.Service) (string, map[string]int32, map[string][]string) {
+	latestRevision := ""
+	if svc.Template != nil {
+		latestRevision = svc.Template.Revision
+	}
+
+	trafficMap := make(map[string]int32)
+	tagMap := make(map[string][]string)
+	for _, t := range svc.Traffic {
+		if t.Type == runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST {
+			trafficMap[latestRevision] = t.Percent
+		} else if t.Type == runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION {
+			trafficMap[t.Revision] = t.Percent
+			if t.Tag != "" {
+				tagMap[t.Revision] = append(tagMap[t.Revision], t.Tag)
+			}
+		}
+	}
+	return latestRevision, trafficMap, tagMap
 }
 
-// buildRevisionInfo builds a RevisionInfo from a runpb.Revision.
-func (rm *RevisionManager) buildRevisionInfo(rev *runpb.Revision, latestRevision string, trafficMap map[string]int32) *RevisionInfo {
+// newRevisionInfo builds a RevisionInfo from a runpb.Revision, without the
+// traffic fields.
+func newRevisionInfo(rev *runpb.Revision) *RevisionInfo {
+	// Traffic targets and the template refer to revisions by their short name
 	info := &RevisionInfo{
-		Name:           rev.Name,
-		TrafficPercent: trafficMap[rev.Name],
-		IsLatest:       rev.Name == latestRevision,
-		Conditions:     make(map[string]bool),
+		Name:       ShortRevisionName(rev.Name),
+		Conditions: make(map[string]bool),
+		Revision:   rev,
 	}
 
 	if rev.CreateTime != nil {
@@ -232,10 +632,38 @@ type RevisionDiff struct {
 	OldRevision *RevisionInfo
 	NewRevision *RevisionInfo
 	ImageDiff   string
-	ConfigDiff  string
+
+	// ConfigDiff lists the changes, one per line.
+	ConfigDiff string
+
+	// Changes are the settings that differ, sorted by setting.
+	Changes []RevisionChange
+}
+
+// RevisionChange is a setting that differs between two revisions.
+type RevisionChange struct {
+	// Setting is the flattened setting name, e.g. "env.LOG_LEVEL".
+	Setting string
+
+	// Old and New are the values, empty if the setting is unset.
+	Old string
+	New string
+}
+
+// String formats the change, e.g. "env.LOG_LEVEL: info -> debug".
+func (c RevisionChange) String() string {
+	old, new := c.Old, c.New
+	if old == "" {
+		old = "(unset)"
+	}
+	if new == "" {
+		new = "(unset)"
+	}
+	return fmt.Sprintf("%s: %s -> %s", c.Setting, old, new)
 }
 
 // CompareRevisions compares two revisions and returns their differences.
+// The configuration is compared if both infos were built from a revision.
 func CompareRevisions(old, new *RevisionInfo) *RevisionDiff {
 	diff := &RevisionDiff{
 		OldRevision: old,
@@ -246,5 +674,99 @@ func CompareRevisions(old, new *RevisionInfo) *RevisionDiff {
 		diff.ImageDiff = fmt.Sprintf("%s -> %s", old.Image, new.Image)
 	}
 
+	if old.Revision == nil || new.Revision == nil {
+		return diff
+	}
+	oldSettings, newSettings := RevisionSettings(old.Revision), RevisionSettings(new.Revision)
+	keys := make(map[string]struct{}, len(oldSettings)+len(newSettings))
+	for k := range oldSettings {
+		keys[k] = struct{}{}
+	}
+	for k := range newSettings {
+		keys[k] = struct{}{}
+	}
+	lines := make([]string, 0, len(keys))
+	for _, k := range slices.Sorted(maps.Keys(keys)) {
+		if oldSettings[k] == newSettings[k] {
+			continue
+		}
+		change := RevisionChange{Setting: k, Old: oldSettings[k], New: newSettings[k]}
+		diff.Changes = append(diff.Changes, change)
+		lines = append(lines, change.String())
+	}
+	diff.ConfigDiff = strings.Join(lines, "\n")
+
 	return diff
 }
+
+// RevisionSettings flattens the configuration of a revision: the image,
+// command, env vars, and resources of each container, and the service
+// account, scaling, concurrency, timeout, execution environment, VPC access,
+// and encryption key. Container settings are prefixed with the container
+// name when the revision has more than one container. Env vars from secrets
+// are shown as "secret:NAME:VERSION".
+func RevisionSettings(rev *runpb.Revision) map[string]string {
+	settings := make(map[string]string)
+	set := func(key, value string) {
+		if value != "" {
+			settings[key] = value
+		}
+	}
+
+	for i, c := range rev.Containers {
+		prefix := ""
+		if len(rev.Containers) > 1 {
+			name := c.Name
+			if name == "" {
+				name = fmt.Sprintf("container-%d", i)
+			}
+			prefix = name + "/"
+		}
+		set(prefix+"image", c.Image)
+		set(prefix+"command", strings.Join(c.Command, " "))
+		set(prefix+"args", strings.Join(c.Args, " "))
+		for _, e := range c.Env {
+			if ref := e.GetValueSource().GetSecretKeyRef(); ref != nil {
+				set(prefix+"env."+e.Name, fmt.Sprintf("secret:%s:%s", ref.Secret, ref.Version))
+				continue
+			}
+			set(prefix+"env."+e.Name, e.GetValue())
+		}
+		if c.Resources != nil {
+			for k, v := range c.Resources.Limits {
+				set(prefix+"limits."+k, v)
+			}
+			if c.Resources.CpuIdle {
+				set(prefix+"cpuIdle", "true")
+			}
+			if c.Resources.StartupCpuBoost {
+				set(prefix+"startupCpuBoost", "true")
+			}
+		}
+	}
+
+	set("serviceAccount", rev.ServiceAccount)
+	if rev.Scaling != nil {
+		if rev.Scaling.MinInstanceCount != 0 {
+			set("minInstanceCount", strconv.Itoa(int(rev.Scaling.MinInstanceCount)))
+		}
+		if rev.Scaling.MaxInstanceCount != 0 {
+			set("maxInstanceCount", strconv.Itoa(int(rev.Scaling.MaxInstanceCount)))
+		}
+	}
+	if rev.MaxInstanceRequestConcurrency != 0 {
+		set("maxInstanceRequestConcurrency", strconv.Itoa(int(rev.MaxInstanceRequestConcurrency)))
+	}
+	if rev.Timeout != nil {
+		set("timeout", rev.Timeout.AsDuration().String())
+	}
+	if rev.ExecutionEnvironment != runpb.ExecutionEnvironment_EXECUTION_ENVIRONMENT_UNSPECIFIED {
+		set("executionEnvironment", rev.ExecutionEnvironment.String())
+	}
+	set("vpcAccess.connector", rev.GetVpcAccess().GetConnector())
+	if egress := rev.GetVpcAccess().GetEgress(); egress != runpb.VpcAccess_VPC_EGRESS_UNSPECIFIED {
+		set("vpcAccess.egress", egress.String())
+	}
+	set("encryptionKey", rev.EncryptionKey)
+	return settings
+}
@@ -0,0 +1,280 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestRemoveRevisionTags(t *testing.T) {
+	traffic := []*runpb.TrafficTarget{
+		{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST, Percent: 100, Tag: "v3"},
+		{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "my-service-00002", Tag: "v2"},
+		{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "my-service-00001", Tag: "v1"},
+	}
+
+	kept, removed := removeRevisionTags(traffic, []*RevisionInfo{{Name: "my-service-00001"}})
+
+	if !slices.Equal(removed, []string{"v1"}) {
+		t.Errorf("expected [v1] to be removed, got %v", removed)
+	}
+	if len(kept) != 2 || kept[0].Tag != "v3" || kept[1].Tag != "v2" {
+		t.Errorf("unexpected remaining traffic: %v", kept)
+	}
+}
+
+// revisionGetter is a Client serving GetRevision from a map, tracking the
+// number of concurrent calls.
+type revisionGetter struct {
+	Client
+	revisions map[string]*runpb.Revision
+
+	mu                    sync.Mutex
+	inFlight, maxInFlight int
+}
+
+func (c *revisionGetter) GetRevision(ctx context.Context, project, region, service, revision string) (*runpb.Revision, error) {
+	c.mu.Lock()
+	c.inFlight++
+	c.maxInFlight = max(c.maxInFlight, c.inFlight)
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.inFlight--
+		c.mu.Unlock()
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	rev, ok := c.revisions[revision]
+	if !ok {
+		return nil, fmt.Errorf("revision %s not found", revision)
+	}
+	return rev, nil
+}
+
+func TestGetRevisions(t *testing.T) {
+	now := time.Now()
+	client := &revisionGetter{revisions: make(map[string]*runpb.Revision)}
+	var names []string
+	for i := 1; i <= 10; i++ {
+		name := fmt.Sprintf("my-service-%05d", i)
+		client.revisions[name] = &runpb.Revision{
+			Name:       NewRevisionName("my-project", "us-central1", "my-service", name).RevisionName(),
+			CreateTime: timestamppb.New(now.Add(time.Duration(i) * time.Minute)),
+		}
+		names = append(names, name)
+	}
+
+	rm := NewRevisionManager(client).WithConcurrency(3)
+	infos, err := rm.GetRevisions(context.Background(), "my-project", "us-central1", "my-service", names)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(infos) != 10 || infos[0].Name != "my-service-00010" || infos[9].Name != "my-service-00001" {
+		t.Errorf("expected revisions newest first, got %d starting with %s", len(infos), infos[0].Name)
+	}
+	if client.maxInFlight > 3 {
+		t.Errorf("expected at most 3 concurrent calls, got %d", client.maxInFlight)
+	}
+
+	EnrichRevisions(infos, &runpb.Service{
+		Template: &runpb.RevisionTemplate{Revision: "my-service-00010"},
+		Traffic: []*runpb.TrafficTarget{
+			{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST, Percent: 90},
+			{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "my-service-00009", Percent: 10, Tag: "stable"},
+		},
+	})
+	if !infos[0].IsLatest || infos[0].TrafficPercent != 90 || infos[1].TrafficPercent != 10 || len(infos[1].Tags) != 1 {
+		t.Errorf("unexpected traffic: %+v, %+v", infos[0], infos[1])
+	}
+
+	if _, err := rm.GetRevisions(context.Background(), "my-project", "us-central1", "my-service", []string{"my-service-00001", "missing"}); err == nil {
+		t.Error("expected error for a missing revision")
+	}
+}
+
+func TestFilterRevisions(t *testing.T) {
+	now := time.Now()
+	revisions := []*RevisionInfo{
+		{Name: "my-service-00004", CreatedAt: now, TrafficPercent: 10, Conditions: map[string]bool{"Ready": false}},
+		{Name: "my-service-00003", CreatedAt: now.Add(-time.Hour), TrafficPercent: 90, Conditions: map[string]bool{"Ready": true}},
+		{Name: "my-service-00002", CreatedAt: now.Add(-48 * time.Hour), Tags: []string{"v2"}, Conditions: map[string]bool{"Ready": true}},
+		{Name: "my-service-00001", CreatedAt: now.Add(-72 * time.Hour), Conditions: map[string]bool{}},
+	}
+	names := func(revs []*RevisionInfo) []string {
+		var out []string
+		for _, rev := range revs {
+			out = append(out, rev.Name)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name     string
+		filters  []RevisionFilter
+		expected []string
+	}{
+		{"no filter", nil, []string{"my-service-00004", "my-service-00003", "my-service-00002", "my-service-00001"}},
+		{"has traffic", []RevisionFilter{HasTraffic()}, []string{"my-service-00004", "my-service-00003"}},
+		{"unhealthy with traffic", []RevisionFilter{HasTraffic(), Unhealthy()}, []string{"my-service-00004"}},
+		{"tagged", []RevisionFilter{Tagged()}, []string{"my-service-00002"}},
+		{"old without traffic", []RevisionFilter{Not(HasTraffic()), OlderThan(now.Add(-24 * time.Hour))}, []string{"my-service-00002", "my-service-00001"}},
+		{"missing condition is not unhealthy", []RevisionFilter{Not(HasTraffic()), Not(Unhealthy()), Not(Tagged())}, []string{"my-service-00001"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := names(FilterRevisions(revisions, tt.filters...)); !slices.Equal(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+// revisionPager is a Client listing revisions in pages of 100, newest
+// first, counting the pages listed.
+type revisionPager struct {
+	Client
+	service   *runpb.Service
+	revisions []*runpb.Revision
+	pages     int
+}
+
+func (c *revisionPager) GetService(ctx context.Context, project, region, service string) (*runpb.Service, error) {
+	return c.service, nil
+}
+
+func (c *revisionPager) ListRevisionPages(ctx context.Context, project, region, service string, fn func(page []*runpb.Revision) bool) error {
+	for i := 0; i < len(c.revisions); i += 100 {
+		c.pages++
+		if !fn(c.revisions[i:min(i+100, len(c.revisions))]) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func TestGetPreviousRevision(t *testing.T) {
+	now := time.Now()
+	ready := []*runpb.Condition{{Type: "Ready", State: runpb.Condition_CONDITION_SUCCEEDED}}
+	failed := []*runpb.Condition{{Type: "Ready", State: runpb.Condition_CONDITION_FAILED}}
+
+	// 250 revisions, newest first: the latest one serves, the next one
+	// failed, and the others are ready but never served except 00120
+	client := &revisionPager{service: &runpb.Service{LatestCreatedRevision: "my-service-00250"}}
+	for i := 250; i >= 1; i-- {
+		name := fmt.Sprintf("my-service-%05d", i)
+		rev := &runpb.Revision{
+			Name:       NewRevisionName("my-project", "us-central1", "my-service", name).RevisionName(),
+			CreateTime: timestamppb.New(now.Add(time.Duration(i) * time.Minute)),
+			Conditions: ready,
+		}
+		if i == 249 {
+			rev.Conditions = failed
+		}
+		client.revisions = append(client.revisions, rev)
+	}
+	rm := NewRevisionManager(client)
+
+	// Without history, the most recent ready revision other than the latest
+	prev, err := rm.GetPreviousRevision(context.Background(), "my-project", "us-central1", "my-service", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if prev.Name != "my-service-00248" || client.pages != 1 {
+		t.Errorf("expected my-service-00248 from the first page, got %s after %d page(s)", prev.Name, client.pages)
+	}
+
+	// With history, only revisions that served traffic
+	client.pages = 0
+	prev, err = rm.GetPreviousRevision(context.Background(), "my-project", "us-central1", "my-service", []string{"my-service-00250", "my-service-00120"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if prev.Name != "my-service-00120" || client.pages != 2 {
+		t.Errorf("expected my-service-00120 from the second page, got %s after %d page(s)", prev.Name, client.pages)
+	}
+
+	// A revision serving traffic now qualifies without being in the history
+	client.service.Traffic = []*runpb.TrafficTarget{
+		{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "my-service-00200", Percent: 100},
+	}
+	if prev, err = rm.GetPreviousRevision(context.Background(), "my-project", "us-central1", "my-service", []string{"my-service-00120"}); err != nil || prev.Name != "my-service-00200" {
+		t.Errorf("expected the serving my-service-00200, got %v, %v", prev, err)
+	}
+
+	client.service.Traffic = nil
+	if _, err := rm.GetPreviousRevision(context.Background(), "my-project", "us-central1", "my-service", []string{"my-service-00249"}); err == nil {
+		t.Error("expected error when only a failed revision served traffic")
+	}
+}
+
+func TestCompareRevisions(t *testing.T) {
+	stable := newRevisionInfo(&runpb.Revision{
+		Name:           "my-service-00001",
+		ServiceAccount: "app@my-project.iam.gserviceaccount.com",
+		Containers: []*runpb.Container{{
+			Image: "gcr.io/my-project/app:v1",
+			Env: []*runpb.EnvVar{
+				{Name: "LOG_LEVEL", Values: &runpb.EnvVar_Value{Value: "info"}},
+				{Name: "LEGACY", Values: &runpb.EnvVar_Value{Value: "true"}},
+			},
+			Resources: &runpb.ResourceRequirements{Limits: map[string]string{"memory": "512Mi"}},
+		}},
+		Scaling: &runpb.RevisionScaling{MaxInstanceCount: 10},
+	})
+	candidate := newRevisionInfo(&runpb.Revision{
+		Name:           "my-service-00002",
+		ServiceAccount: "app@my-project.iam.gserviceaccount.com",
+		Containers: []*runpb.Container{{
+			Image: "gcr.io/my-project/app:v2",
+			Env: []*runpb.EnvVar{
+				{Name: "LOG_LEVEL", Values: &runpb.EnvVar_Value{Value: "debug"}},
+				{Name: "API_KEY", Values: &runpb.EnvVar_ValueSource{ValueSource: &runpb.EnvVarSource{
+					SecretKeyRef: &runpb.SecretKeySelector{Secret: "api-key", Version: "3"},
+				}}},
+			},
+			Resources: &runpb.ResourceRequirements{Limits: map[string]string{"memory": "1Gi"}},
+		}},
+		Scaling: &runpb.RevisionScaling{MaxInstanceCount: 10},
+	})
+
+	diff := CompareRevisions(stable, candidate)
+	if diff.ImageDiff != "gcr.io/my-project/app:v1 -> gcr.io/my-project/app:v2" {
+		t.Errorf("unexpected image diff %q", diff.ImageDiff)
+	}
+	expected := []string{
+		"env.API_KEY: (unset) -> secret:api-key:3",
+		"env.LEGACY: true -> (unset)",
+		"env.LOG_LEVEL: info -> debug",
+		"image: gcr.io/my-project/app:v1 -> gcr.io/my-project/app:v2",
+		"limits.memory: 512Mi -> 1Gi",
+	}
+	if diff.ConfigDiff != strings.Join(expected, "\n") {
+		t.Errorf("expected config diff:\n%s\ngot:\n%s", strings.Join(expected, "\n"), diff.ConfigDiff)
+	}
+
+	if diff := CompareRevisions(stable, stable); len(diff.Changes) != 0 {
+		t.Errorf("expected no changes, got %v", diff.Changes)
+	}
+}
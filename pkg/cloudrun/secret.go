@@ -0,0 +1,198 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/option"
+	secretmanager "google.golang.org/api/secretmanager/v1"
+)
+
+// SecretRefKey is the key used in stage and plugin configs to reference
+// a value stored in GCP Secret Manager.
+//
+// Example stage config:
+//
+//	with:
+//	  webhook:
+//	    token:
+//	      secretRef: projects/my-project/secrets/webhook-token/versions/latest
+//
+// A short secret name (e.g. "webhook-token" or "webhook-token/versions/3")
+// is resolved against the default project of the resolver.
+const SecretRefKey = "secretRef"
+
+// SecretResolver resolves secretRef values from GCP Secret Manager.
+type SecretResolver struct {
+	accessor       secretAccessor
+	defaultProject string
+}
+
+// secretAccessor returns the payload of a secret version by its full name.
+type secretAccessor interface {
+	accessSecretVersion(ctx context.Context, name string) (string, error)
+}
+
+// secretManagerAccessor accesses secret versions with the Secret Manager API.
+type secretManagerAccessor struct {
+	service *secretmanager.Service
+}
+
+func (a *secretManagerAccessor) accessSecretVersion(ctx context.Context, name string) (string, error) {
+	resp, err := a.service.Projects.Secrets.Versions.Access(name).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to access secret %s: %w", name, err)
+	}
+	if resp.Payload == nil {
+		return "", fmt.Errorf("secret %s has no payload", name)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(resp.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode secret %s: %w", name, err)
+	}
+
+	return string(data), nil
+}
+
+// NewSecretResolver creates a new SecretResolver.
+//
+// If credentialsFile is empty, Application Default Credentials will be used.
+// defaultProject is used to expand short secret names.
+func NewSecretResolver(ctx context.Context, credentialsFile, defaultProject string) (*SecretResolver, error) {
+	var opts []option.ClientOption
+	if credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	}
+
+	service, err := secretmanager.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create secret manager client: %w", err)
+	}
+
+	return &SecretResolver{
+		accessor:       &secretManagerAccessor{service: service},
+		defaultProject: defaultProject,
+	}, nil
+}
+
+// HasSecretRefs reports whether the given JSON config may contain secretRef values.
+// It is a cheap check used to avoid creating a Secret Manager client when not needed.
+func HasSecretRefs(data []byte) bool {
+	return bytes.Contains(data, []byte(`"`+SecretRefKey+`"`))
+}
+
+// AccessSecret returns the payload of the given secret version.
+func (r *SecretResolver) AccessSecret(ctx context.Context, ref string) (string, error) {
+	name, err := r.SecretVersionName(ref)
+	if err != nil {
+		return "", err
+	}
+	return r.accessor.accessSecretVersion(ctx, name)
+}
+
+// ResolveSecretRefs replaces every {"secretRef": "..."} object in the given
+// JSON config with the plain string value of the referenced secret.
+func (r *SecretResolver) ResolveSecretRefs(ctx context.Context, data []byte) ([]byte, error) {
+	if len(data) == 0 || !HasSecretRefs(data) {
+		return data, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	resolved, err := r.resolveValue(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(resolved)
+}
+
+// resolveValue walks the decoded JSON value and resolves secretRef objects.
+func (r *SecretResolver) resolveValue(ctx context.Context, v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if ref, ok := secretRefFromMap(val); ok {
+			return r.AccessSecret(ctx, ref)
+		}
+		for k, item := range val {
+			resolved, err := r.resolveValue(ctx, item)
+			if err != nil {
+				return nil, err
+			}
+			val[k] = resolved
+		}
+		return val, nil
+	case []interface{}:
+		for i, item := range val {
+			resolved, err := r.resolveValue(ctx, item)
+			if err != nil {
+				return nil, err
+			}
+			val[i] = resolved
+		}
+		return val, nil
+	default:
+		return v, nil
+	}
+}
+
+// secretRefFromMap returns the reference if m is exactly {"secretRef": "<ref>"}.
+func secretRefFromMap(m map[string]interface{}) (string, bool) {
+	if len(m) != 1 {
+		return "", false
+	}
+	ref, ok := m[SecretRefKey].(string)
+	return ref, ok
+}
+
+// SecretVersionName expands a secret reference into a full secret version name.
+//
+// Accepted formats:
+//   - projects/{project}/secrets/{secret}/versions/{version}
+//   - projects/{project}/secrets/{secret} (uses "latest")
+//   - {secret}/versions/{version}
+//   - {secret} (uses "latest")
+func (r *SecretResolver) SecretVersionName(ref string) (string, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return "", fmt.Errorf("empty secretRef")
+	}
+
+	if !strings.HasPrefix(ref, "projects/") {
+		if r.defaultProject == "" {
+			return "", fmt.Errorf("secretRef %q requires a project but no default project is configured", ref)
+		}
+		ref = fmt.Sprintf("projects/%s/secrets/%s", r.defaultProject, ref)
+	}
+
+	if !strings.Contains(ref, "/versions/") {
+		ref += "/versions/latest"
+	}
+
+	return ref, nil
+}
@@ -0,0 +1,159 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// fakeSecretAccessor returns the secrets by their full version name.
+type fakeSecretAccessor map[string]string
+
+func (a fakeSecretAccessor) accessSecretVersion(_ context.Context, name string) (string, error) {
+	value, ok := a[name]
+	if !ok {
+		return "", fmt.Errorf("secret %s not found", name)
+	}
+	return value, nil
+}
+
+func TestSecretVersionName(t *testing.T) {
+	tests := []struct {
+		name           string
+		ref            string
+		defaultProject string
+		expected       string
+		wantErr        bool
+	}{
+		{name: "full version", ref: "projects/p/secrets/s/versions/3", expected: "projects/p/secrets/s/versions/3"},
+		{name: "full secret", ref: "projects/p/secrets/s", expected: "projects/p/secrets/s/versions/latest"},
+		{name: "short version", ref: "s/versions/3", defaultProject: "d", expected: "projects/d/secrets/s/versions/3"},
+		{name: "short secret", ref: " s ", defaultProject: "d", expected: "projects/d/secrets/s/versions/latest"},
+		{name: "full name ignores default project", ref: "projects/p/secrets/s", defaultProject: "d", expected: "projects/p/secrets/s/versions/latest"},
+		{name: "short without default project", ref: "s", wantErr: true},
+		{name: "empty", ref: "", defaultProject: "d", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &SecretResolver{defaultProject: tt.defaultProject}
+			got, err := r.SecretVersionName(tt.ref)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestResolveValue(t *testing.T) {
+	r := &SecretResolver{
+		accessor: fakeSecretAccessor{
+			"projects/p/secrets/api-key/versions/latest": "key",
+			"projects/p/secrets/token/versions/2":        "token",
+		},
+		defaultProject: "p",
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "nested object",
+			input:    `{"webhook":{"token":{"secretRef":"token/versions/2"}},"percent":10}`,
+			expected: `{"webhook":{"token":"token"},"percent":10}`,
+		},
+		{
+			name:     "list",
+			input:    `{"keys":[{"secretRef":"api-key"},"plain"]}`,
+			expected: `{"keys":["key","plain"]}`,
+		},
+		{
+			name:     "object with other keys is not a reference",
+			input:    `{"ref":{"secretRef":"api-key","optional":true}}`,
+			expected: `{"ref":{"secretRef":"api-key","optional":true}}`,
+		},
+		{
+			name:     "non-string reference is kept",
+			input:    `{"ref":{"secretRef":1}}`,
+			expected: `{"ref":{"secretRef":1}}`,
+		},
+		{
+			name:    "missing secret",
+			input:   `{"token":{"secretRef":"missing"}}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var input, expected interface{}
+			if err := json.Unmarshal([]byte(tt.input), &input); err != nil {
+				t.Fatal(err)
+			}
+			got, err := r.resolveValue(context.Background(), input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := json.Unmarshal([]byte(tt.expected), &expected); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("expected %v, got %v", expected, got)
+			}
+		})
+	}
+}
+
+func TestResolveSecretRefs(t *testing.T) {
+	r := &SecretResolver{accessor: fakeSecretAccessor{"projects/p/secrets/s/versions/latest": "v"}}
+
+	// Configs without references are returned as is, without any access
+	plain := []byte(`{"percent": 10}`)
+	got, err := r.ResolveSecretRefs(context.Background(), plain)
+	if err != nil || string(got) != string(plain) {
+		t.Errorf("expected the config unchanged, got %s (%v)", got, err)
+	}
+
+	got, err = r.ResolveSecretRefs(context.Background(), []byte(`{"threshold": 0.5, "key": {"secretRef": "projects/p/secrets/s"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Numbers keep their precision
+	if string(got) != `{"key":"v","threshold":0.5}` {
+		t.Errorf("unexpected resolved config %s", got)
+	}
+}
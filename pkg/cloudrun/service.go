@@ -20,9 +20,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 
 	"cloud.google.com/go/run/apiv2/runpb"
 	"google.golang.org/protobuf/encoding/protojson"
+	"sigs.k8s.io/yaml"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun/knative"
 )
 
 // ServiceManager provides high-level operations for managing Cloud Run services.
@@ -62,25 +67,102 @@ func NewServiceManager(client Client) *ServiceManager {
 //	  traffic:
 //	    - latestRevision: true
 //	      percent: 100
+//
+// Manifests with a registered ManifestLoader, such as CUE and Jsonnet
+// manifests, are evaluated first.
 func LoadServiceManifest(path string) (*runpb.Service, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read service manifest: %w", err)
 	}
+	if data, err = EvaluateManifest(context.Background(), path, data); err != nil {
+		return nil, err
+	}
+	return ParseServiceManifest(data)
+}
 
-	var service runpb.Service
+// ParseServiceManifest parses a service manifest, either a Knative service
+// or an Admin API v2 service, as JSON or YAML. Cloud Run annotations of Admin
+// API v2 services are converted to their fields as in Knative services.
+// Of a manifest with several YAML documents, the first service is returned.
+func ParseServiceManifest(data []byte) (*runpb.Service, error) {
+	services, err := ParseServiceManifests(data)
+	if err != nil {
+		return nil, err
+	}
+	return services[0], nil
+}
+
+// documentSeparator matches the lines separating YAML documents.
+var documentSeparator = regexp.MustCompile(`(?m)^---[ \t]*(#.*)?$`)
+
+// ParseServiceManifests parses the services of a manifest made of one or
+// more YAML documents separated by "---", as ParseServiceManifest. Empty
+// documents are skipped, and errors name the document of a manifest with
+// several services.
+func ParseServiceManifests(data []byte) ([]*runpb.Service, error) {
+	docs := SplitManifestDocuments(data)
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("failed to parse service manifest: no service")
+	}
+
+	services := make([]*runpb.Service, 0, len(docs))
+	for i, doc := range docs {
+		svc, err := parseServiceDocument(doc)
+		if err != nil && len(docs) > 1 {
+			return nil, fmt.Errorf("document %d: %w", i+1, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, svc)
+	}
+	return services, nil
+}
+
+// SplitManifestDocuments returns the YAML documents of a manifest separated
+// by "---", skipping empty documents.
+func SplitManifestDocuments(data []byte) [][]byte {
+	var docs [][]byte
+	for _, doc := range documentSeparator.Split(string(data), -1) {
+		if !isEmptyDocument(doc) {
+			docs = append(docs, []byte(doc))
+		}
+	}
+	return docs
+}
 
-	// Try JSON first
-	if err := protojson.Unmarshal(data, &service); err != nil {
-		// If JSON fails, try YAML (convert to JSON first)
-		// For simplicity, we assume the file is in JSON format
-		// In production, you'd want to support YAML as well
-		return nil, fmt.Errorf("failed to parse service manifest (expected JSON): %w", err)
+// parseServiceDocument parses a service manifest of a single document.
+func parseServiceDocument(data []byte) (*runpb.Service, error) {
+	if knative.IsManifest(data) {
+		return knative.ParseService(data)
 	}
 
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse service manifest: %w", err)
+	}
+	var service runpb.Service
+	if err := protojson.Unmarshal(jsonData, &service); err != nil {
+		return nil, fmt.Errorf("failed to parse service manifest: %w", err)
+	}
+	if err := knative.ApplyAnnotations(&service); err != nil {
+		return nil, fmt.Errorf("invalid service manifest: %w", err)
+	}
 	return &service, nil
 }
 
+// isEmptyDocument reports whether the YAML document only has blank lines
+// and comments.
+func isEmptyDocument(doc string) bool {
+	for _, line := range strings.Split(doc, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
+
 // LoadServiceManifestFromDir loads a service manifest from an application directory.
 func LoadServiceManifestFromDir(appDir, manifestPath string) (*runpb.Service, error) {
 	fullPath := filepath.Join(appDir, manifestPath)
@@ -98,17 +180,43 @@ func ApplyImageOverride(service *runpb.Service, image string) {
 	}
 }
 
+// ContainerImages returns the images of the containers of the service, in
+// the order of the containers.
+func ContainerImages(service *runpb.Service) []string {
+	images := make([]string, 0, len(service.GetTemplate().GetContainers()))
+	for _, container := range service.GetTemplate().GetContainers() {
+		images = append(images, container.Image)
+	}
+	return images
+}
+
+// SetContainerImages sets the images of the containers of the service, in
+// the order of the containers. It fails if the numbers of images and
+// containers differ.
+func SetContainerImages(service *runpb.Service, images []string) error {
+	containers := service.GetTemplate().GetContainers()
+	if len(images) != len(containers) {
+		return fmt.Errorf("service has %d containers, got %d images", len(containers), len(images))
+	}
+	for i, container := range containers {
+		container.Image = images[i]
+	}
+	return nil
+}
+
 // SetServiceName sets the full resource name for the service.
 func SetServiceName(service *runpb.Service, project, region, name string) {
-	service.Name = fmt.Sprintf("projects/%s/locations/%s/services/%s", project, region, name)
+	service.Name = NewServiceName(project, region, name).ServiceName()
 }
 
 // GetServiceName extracts the service name from a full resource name.
+// It returns an empty string if fullName is not a service or revision name.
 func GetServiceName(fullName string) string {
-	// Full name format: projects/{project}/locations/{location}/services/{service}
-	var project, region, name string
-	fmt.Sscanf(fullName, "projects/%s/locations/%s/services/%s", &project, &region, &name)
-	return name
+	n, err := ParseResourceName(fullName)
+	if err != nil {
+		return ""
+	}
+	return n.Service
 }
 
 // Deploy deploys a new revision of a service.
@@ -0,0 +1,75 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseServiceManifestV2(t *testing.T) {
+	// Admin API v2 services are accepted as JSON and YAML
+	for _, manifest := range []string{
+		`{"template": {"containers": [{"image": "gcr.io/my-project/app:v1"}]}}`,
+		"template:\n  containers:\n    - image: gcr.io/my-project/app:v1\n",
+	} {
+		svc, err := ParseServiceManifest([]byte(manifest))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := svc.Template.Containers[0].Image; got != "gcr.io/my-project/app:v1" {
+			t.Errorf("expected the image, got %s", got)
+		}
+	}
+}
+
+func TestParseServiceManifests(t *testing.T) {
+	services, err := ParseServiceManifests([]byte(`---
+name: api
+template:
+  containers:
+    - image: gcr.io/my-project/api:v1
+--- # internal admin service
+# knative services are accepted too
+apiVersion: serving.knative.dev/v1
+kind: Service
+metadata:
+  name: admin
+spec:
+  template:
+    spec:
+      containers:
+        - image: gcr.io/my-project/admin:v1
+---
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 2 || services[0].Name != "api" || services[1].Name != "admin" {
+		t.Fatalf("expected the api and admin services, got %v", services)
+	}
+
+	// The first service is the one of ParseServiceManifest
+	if svc, err := ParseServiceManifest([]byte("name: api\n---\nname: admin\n")); err != nil || svc.Name != "api" {
+		t.Errorf("expected the first service, got %v, %v", svc, err)
+	}
+
+	if _, err := ParseServiceManifests([]byte("name: api\n---\nname: [admin\n")); err == nil || !strings.Contains(err.Error(), "document 2") {
+		t.Errorf("expected an error naming the document, got %v", err)
+	}
+	if _, err := ParseServiceManifests([]byte("# no service\n---\n")); err == nil {
+		t.Error("expected an error for a manifest without service")
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	monitoring "google.golang.org/api/monitoring/v3"
+	"google.golang.org/api/option"
+)
+
+// Custom metric types of stage executions, labeled by application, deploy
+// target, stage, and status.
+const (
+	// StageExecutionsMetric counts the stage executions (cumulative).
+	StageExecutionsMetric = "custom.googleapis.com/pipecd/cloudrun/stage_executions"
+
+	// StageDurationMetric is the duration of each stage execution in seconds.
+	StageDurationMetric = "custom.googleapis.com/pipecd/cloudrun/stage_duration"
+)
+
+// StageMetric is the outcome of a stage execution.
+type StageMetric struct {
+	Application  string
+	DeployTarget string
+	Stage        string
+	Status       string
+
+	// Count is the number of executions with the same labels since Start,
+	// when the plugin process started counting.
+	Count int64
+	Start time.Time
+
+	// Duration is how long the execution took, until End.
+	Duration time.Duration
+	End      time.Time
+}
+
+// labels returns the metric labels of the execution.
+func (m StageMetric) labels() map[string]string {
+	return map[string]string{
+		"application":   m.Application,
+		"deploy_target": m.DeployTarget,
+		"stage":         m.Stage,
+		"status":        m.Status,
+	}
+}
+
+// MetricWriter writes stage execution metrics.
+type MetricWriter interface {
+	// WriteStageMetric writes the execution count and duration of the
+	// stage to the project.
+	WriteStageMetric(ctx context.Context, project string, m StageMetric) error
+}
+
+// monitoringMetricWriter writes custom metrics to Cloud Monitoring.
+type monitoringMetricWriter struct {
+	service *monitoring.Service
+}
+
+// NewMetricWriter creates a MetricWriter backed by Cloud Monitoring.
+//
+// If credentialsFile is empty, Application Default Credentials will be used.
+// The credentials need the monitoring.timeSeries.create permission
+// (e.g. roles/monitoring.metricWriter).
+func NewMetricWriter(ctx context.Context, credentialsFile string, opts ...option.ClientOption) (MetricWriter, error) {
+	if credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	}
+
+	service, err := monitoring.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create monitoring client: %w", err)
+	}
+
+	return &monitoringMetricWriter{service: service}, nil
+}
+
+// WriteStageMetric writes the execution count as a cumulative point since
+// m.Start, and the duration as a gauge point, on the global resource of the
+// project.
+func (w *monitoringMetricWriter) WriteStageMetric(ctx context.Context, project string, m StageMetric) error {
+	resource := &monitoring.MonitoredResource{
+		Type:   "global",
+		Labels: map[string]string{"project_id": project},
+	}
+	end := m.End.UTC().Format(time.RFC3339Nano)
+	count := m.Count
+	seconds := m.Duration.Seconds()
+
+	req := &monitoring.CreateTimeSeriesRequest{
+		TimeSeries: []*monitoring.TimeSeries{
+			{
+				Metric:     &monitoring.Metric{Type: StageExecutionsMetric, Labels: m.labels()},
+				Resource:   resource,
+				MetricKind: "CUMULATIVE",
+				ValueType:  "INT64",
+				Points: []*monitoring.Point{{
+					Interval: &monitoring.TimeInterval{StartTime: m.Start.UTC().Format(time.RFC3339Nano), EndTime: end},
+					Value:    &monitoring.TypedValue{Int64Value: &count},
+				}},
+			},
+			{
+				Metric:     &monitoring.Metric{Type: StageDurationMetric, Labels: m.labels()},
+				Resource:   resource,
+				MetricKind: "GAUGE",
+				ValueType:  "DOUBLE",
+				Unit:       "s",
+				Points: []*monitoring.Point{{
+					Interval: &monitoring.TimeInterval{EndTime: end},
+					Value:    &monitoring.TypedValue{DoubleValue: &seconds},
+				}},
+			},
+		},
+	}
+	if _, err := w.service.Projects.TimeSeries.Create("projects/"+project, req).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to write stage metrics: %w", err)
+	}
+	return nil
+}
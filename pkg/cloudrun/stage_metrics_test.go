@@ -0,0 +1,79 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	monitoring "google.golang.org/api/monitoring/v3"
+	"google.golang.org/api/option"
+)
+
+func TestWriteStageMetric(t *testing.T) {
+	var (
+		gotPath string
+		got     monitoring.CreateTimeSeriesRequest
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("invalid request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	writer, err := NewMetricWriter(context.Background(), "", option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)
+	err = writer.WriteStageMetric(context.Background(), "my-project", StageMetric{
+		Application:  "my-app",
+		DeployTarget: "production",
+		Stage:        "CLOUDRUN_SYNC",
+		Status:       "SUCCESS",
+		Count:        3,
+		Start:        start,
+		Duration:     90 * time.Second,
+		End:          start.Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gotPath != "/v3/projects/my-project/timeSeries" {
+		t.Errorf("unexpected path %q", gotPath)
+	}
+	if len(got.TimeSeries) != 2 {
+		t.Fatalf("expected 2 time series, got %d", len(got.TimeSeries))
+	}
+	count, duration := got.TimeSeries[0], got.TimeSeries[1]
+	if count.Metric.Type != StageExecutionsMetric || count.MetricKind != "CUMULATIVE" || *count.Points[0].Value.Int64Value != 3 {
+		t.Errorf("unexpected count series %+v", count)
+	}
+	if count.Points[0].Interval.StartTime != "2025-06-01T09:00:00Z" || count.Metric.Labels["deploy_target"] != "production" {
+		t.Errorf("unexpected count interval or labels: %+v, %v", count.Points[0].Interval, count.Metric.Labels)
+	}
+	if duration.Metric.Type != StageDurationMetric || *duration.Points[0].Value.DoubleValue != 90 || duration.Resource.Labels["project_id"] != "my-project" {
+		t.Errorf("unexpected duration series %+v", duration)
+	}
+}
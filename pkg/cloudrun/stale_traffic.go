@@ -0,0 +1,191 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	"google.golang.org/protobuf/proto"
+)
+
+// StaleTarget is a traffic target pointing at a revision that was deleted,
+// e.g. manually, or whose Ready condition failed. The Admin API rejects
+// traffic that includes it.
+type StaleTarget struct {
+	Revision string
+	Tag      string
+	Percent  int32
+
+	// Deleted is whether the revision doesn't exist anymore, rather than
+	// failed.
+	Deleted bool
+}
+
+// String returns e.g. "my-service-00041 (deleted, 20%, tag stable)".
+func (t StaleTarget) String() string {
+	state := "not ready"
+	if t.Deleted {
+		state = "deleted"
+	}
+	details := []string{state, fmt.Sprintf("%d%%", t.Percent)}
+	if t.Tag != "" {
+		details = append(details, "tag "+t.Tag)
+	}
+	return fmt.Sprintf("%s (%s)", t.Revision, strings.Join(details, ", "))
+}
+
+// ReconcileTraffic removes the targets of the traffic that point at deleted
+// or failed revisions, given the revisions of the service. The traffic of
+// the removed targets moves to the remaining target with the most traffic,
+// or to the latest revision if none has traffic left, so the split still
+// adds up to 100. The traffic passed in is not modified.
+func ReconcileTraffic(traffic []*runpb.TrafficTarget, revisions []*runpb.Revision) ([]*runpb.TrafficTarget, []StaleTarget) {
+	stale := staleTargets(traffic, revisions)
+	if len(stale) == 0 {
+		return traffic, nil
+	}
+
+	var (
+		reconciled []*runpb.TrafficTarget
+		freed      int32
+		largest    *runpb.TrafficTarget
+	)
+	for _, t := range traffic {
+		if isStale(t, stale) {
+			freed += t.Percent
+			continue
+		}
+		t = proto.Clone(t).(*runpb.TrafficTarget)
+		if t.Percent > 0 && (largest == nil || t.Percent > largest.Percent) {
+			largest = t
+		}
+		reconciled = append(reconciled, t)
+	}
+
+	switch {
+	case freed == 0:
+	case largest != nil:
+		largest.Percent += freed
+	default:
+		reconciled = append([]*runpb.TrafficTarget{{
+			Type:    runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST,
+			Percent: freed,
+		}}, reconciled...)
+	}
+	return reconciled, stale
+}
+
+// reconcileServiceTraffic returns a copy of the service without the stale
+// targets in its traffic and traffic statuses, see ReconcileTraffic.
+func reconcileServiceTraffic(svc *runpb.Service, revisions []*runpb.Revision) (*runpb.Service, []StaleTarget) {
+	traffic, stale := ReconcileTraffic(svc.GetTraffic(), revisions)
+	if len(stale) == 0 {
+		return svc, nil
+	}
+
+	reconciled := proto.Clone(svc).(*runpb.Service)
+	reconciled.Traffic = traffic
+	reconciled.TrafficStatuses = nil
+	for _, s := range svc.GetTrafficStatuses() {
+		if !isStale(&runpb.TrafficTarget{Revision: s.Revision}, stale) {
+			reconciled.TrafficStatuses = append(reconciled.TrafficStatuses, s)
+		}
+	}
+	return reconciled, stale
+}
+
+// StaleTraffic returns the targets of the traffic of the service that point
+// at deleted or failed revisions.
+func (tm *TrafficManager) StaleTraffic(ctx context.Context, project, region, service string) ([]StaleTarget, error) {
+	svc, err := tm.client.GetService(ctx, project, region, service)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service: %w", err)
+	}
+	if !hasRevisionTargets(svc.GetTraffic()) {
+		return nil, nil
+	}
+	revisions, err := tm.client.ListRevisions(ctx, project, region, service)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revisions: %w", err)
+	}
+	return staleTargets(svc.GetTraffic(), revisions), nil
+}
+
+// hasRevisionTargets reports whether the traffic names a revision, which
+// may be stale, besides the latest revision.
+func hasRevisionTargets(traffic []*runpb.TrafficTarget) bool {
+	for _, t := range traffic {
+		if t.Revision != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// staleTargets returns the targets of the traffic naming revisions that
+// aren't among the revisions, or whose Ready condition failed.
+func staleTargets(traffic []*runpb.TrafficTarget, revisions []*runpb.Revision) []StaleTarget {
+	byName := make(map[string]*runpb.Revision, len(revisions))
+	for _, rev := range revisions {
+		byName[ShortRevisionName(rev.Name)] = rev
+	}
+
+	var stale []StaleTarget
+	for _, t := range traffic {
+		if t.Revision == "" {
+			continue
+		}
+		name := ShortRevisionName(t.Revision)
+		rev, ok := byName[name]
+		if ok && !revisionFailed(rev) {
+			continue
+		}
+		stale = append(stale, StaleTarget{
+			Revision: name,
+			Tag:      t.Tag,
+			Percent:  t.Percent,
+			Deleted:  !ok,
+		})
+	}
+	return stale
+}
+
+// isStale reports whether the target points at one of the stale revisions.
+func isStale(t *runpb.TrafficTarget, stale []StaleTarget) bool {
+	if t.Revision == "" {
+		return false
+	}
+	name := ShortRevisionName(t.Revision)
+	for _, s := range stale {
+		if s.Revision == name {
+			return true
+		}
+	}
+	return false
+}
+
+// revisionFailed reports whether the Ready condition of the revision failed.
+// Revisions that are still being reconciled have not failed.
+func revisionFailed(rev *runpb.Revision) bool {
+	for _, cond := range rev.Conditions {
+		if cond.Type == "Ready" {
+			return cond.State == runpb.Condition_CONDITION_FAILED
+		}
+	}
+	return false
+}
@@ -0,0 +1,205 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	cloudtasks "google.golang.org/api/cloudtasks/v2"
+	"google.golang.org/api/option"
+)
+
+// QueueTarget defines the HTTP target a Cloud Tasks queue delivers tasks to.
+type QueueTarget struct {
+	// Queue is the full queue name.
+	// Format: projects/{project}/locations/{location}/queues/{queue}
+	Queue string
+
+	// URL is the URL tasks are delivered to. Its scheme, host, port and path
+	// override the ones set on each task.
+	URL string
+
+	// Audience is the OIDC token audience.
+	// If empty, no OIDC token settings are changed.
+	Audience string
+
+	// ServiceAccountEmail is the service account used to generate the OIDC token.
+	// If empty, the queue's current service account is kept.
+	ServiceAccountEmail string
+}
+
+// QueueTargetUpdate describes a queue target before and after an update.
+type QueueTargetUpdate struct {
+	PreviousURL      string
+	PreviousAudience string
+	URL              string
+	Audience         string
+}
+
+// Changed reports whether the update changed the queue target.
+func (u *QueueTargetUpdate) Changed() bool {
+	return u.PreviousURL != u.URL || u.PreviousAudience != u.Audience
+}
+
+// QueueName returns the full name of a Cloud Tasks queue.
+// A queue that is already a full name is returned unchanged.
+func QueueName(project, location, queue string) string {
+	if strings.HasPrefix(queue, "projects/") {
+		return queue
+	}
+	return fmt.Sprintf("projects/%s/locations/%s/queues/%s", project, location, queue)
+}
+
+// UpdateQueueTarget points the queue's HTTP target override at target.URL and,
+// if set, updates the OIDC audience so task delivery follows the deployment.
+//
+// If credentialsFile is empty, Application Default Credentials will be used.
+// The credentials need the cloudtasks.queues.update permission.
+func UpdateQueueTarget(ctx context.Context, credentialsFile string, target QueueTarget) (*QueueTargetUpdate, error) {
+	override, err := uriOverrideFromURL(target.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []option.ClientOption
+	if credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	}
+	svc, err := cloudtasks.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud Tasks client: %w", err)
+	}
+
+	queue, err := svc.Projects.Locations.Queues.Get(target.Queue).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get queue %s: %w", target.Queue, err)
+	}
+
+	update := &QueueTargetUpdate{
+		URL:      target.URL,
+		Audience: target.Audience,
+	}
+	httpTarget := queue.HttpTarget
+	if httpTarget == nil {
+		httpTarget = &cloudtasks.HttpTarget{}
+	}
+	update.PreviousURL = urlFromUriOverride(httpTarget.UriOverride)
+	if httpTarget.OidcToken != nil {
+		update.PreviousAudience = httpTarget.OidcToken.Audience
+	}
+
+	updateMask := []string{"httpTarget.uriOverride"}
+	httpTarget.UriOverride = override
+	if target.Audience != "" {
+		email := target.ServiceAccountEmail
+		if email == "" && httpTarget.OidcToken != nil {
+			email = httpTarget.OidcToken.ServiceAccountEmail
+		}
+		if email == "" {
+			return nil, fmt.Errorf("queue %s has no OIDC service account; set one to update the audience", target.Queue)
+		}
+		httpTarget.OidcToken = &cloudtasks.OidcToken{
+			Audience:            target.Audience,
+			ServiceAccountEmail: email,
+		}
+		updateMask = append(updateMask, "httpTarget.oidcToken")
+	} else {
+		update.Audience = update.PreviousAudience
+	}
+
+	if !update.Changed() {
+		return update, nil
+	}
+	if report, ok := DryRun(ctx); ok {
+		report(fmt.Sprintf("Would update queue %s: URL %s, OIDC audience %s", target.Queue, update.URL, update.Audience))
+		return update, nil
+	}
+
+	patch := &cloudtasks.Queue{
+		Name:       queue.Name,
+		HttpTarget: httpTarget,
+	}
+	_, err = svc.Projects.Locations.Queues.Patch(target.Queue, patch).
+		UpdateMask(strings.Join(updateMask, ",")).
+		Context(ctx).
+		Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to update queue %s: %w", target.Queue, err)
+	}
+
+	return update, nil
+}
+
+// uriOverrideFromURL converts a URL to a queue URI override.
+func uriOverrideFromURL(rawURL string) (*cloudtasks.UriOverride, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid queue target URL %q: %w", rawURL, err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid queue target URL %q: missing host", rawURL)
+	}
+
+	override := &cloudtasks.UriOverride{
+		Host:                   u.Hostname(),
+		UriOverrideEnforceMode: "ALWAYS",
+	}
+	switch u.Scheme {
+	case "https":
+		override.Scheme = "HTTPS"
+	case "http":
+		override.Scheme = "HTTP"
+	default:
+		return nil, fmt.Errorf("invalid queue target URL %q: unsupported scheme %q", rawURL, u.Scheme)
+	}
+	if p := u.Port(); p != "" {
+		port, err := strconv.ParseInt(p, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid queue target URL %q: %w", rawURL, err)
+		}
+		override.Port = port
+	}
+	if u.Path != "" {
+		override.PathOverride = &cloudtasks.PathOverride{Path: u.Path}
+	}
+	return override, nil
+}
+
+// urlFromUriOverride formats a queue URI override as a URL.
+// It returns "" if the override has no host.
+func urlFromUriOverride(o *cloudtasks.UriOverride) string {
+	if o == nil || o.Host == "" {
+		return ""
+	}
+
+	u := url.URL{
+		Scheme: strings.ToLower(o.Scheme),
+		Host:   o.Host,
+	}
+	if u.Scheme == "" {
+		u.Scheme = "https"
+	}
+	if o.Port != 0 {
+		u.Host = fmt.Sprintf("%s:%d", o.Host, o.Port)
+	}
+	if o.PathOverride != nil {
+		u.Path = o.PathOverride.Path
+	}
+	return u.String()
+}
@@ -0,0 +1,114 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrCallTimeout is returned (wrapped) when a single Admin API call exceeds
+// its per-call timeout. Use errors.Is(err, ErrCallTimeout) to detect it.
+var ErrCallTimeout = errors.New("cloud run API call timed out")
+
+// CallTimeoutError describes an Admin API call that exceeded its per-call timeout.
+// It is distinct from the stage or pipeline context being cancelled.
+type CallTimeoutError struct {
+	// Op is the name of the timed out operation (e.g. "GetService").
+	Op string
+
+	// Timeout is the per-call timeout that was exceeded.
+	Timeout time.Duration
+
+	// Err is the underlying error returned by the API client.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *CallTimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %s: %v", e.Op, e.Timeout, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *CallTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the target is ErrCallTimeout.
+func (e *CallTimeoutError) Is(target error) bool {
+	return target == ErrCallTimeout
+}
+
+// Timeouts defines per-call timeouts for Admin API operations.
+// A zero value disables the timeout for that kind of call, leaving only
+// the caller's context deadline in effect.
+type Timeouts struct {
+	// Get applies to single resource reads (GetService, GetRevision).
+	Get time.Duration
+
+	// List applies to list operations (ListRevisions), including pagination.
+	List time.Duration
+
+	// Update applies to create/update operations, including waiting for
+	// the long-running operation to complete.
+	Update time.Duration
+
+	// Delete applies to delete operations, including waiting for
+	// the long-running operation to complete.
+	Delete time.Duration
+
+	// OperationInProgress is how long an update failing because another
+	// operation on the service is in progress waits for that operation to
+	// finish, before being retried.
+	OperationInProgress time.Duration
+
+	// TrafficConvergence is how long a traffic update waits for the traffic
+	// status of the service to match the requested split.
+	TrafficConvergence time.Duration
+}
+
+// DefaultTimeouts returns the default per-call timeouts.
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		Get:                 30 * time.Second,
+		List:                time.Minute,
+		Update:              10 * time.Minute,
+		Delete:              5 * time.Minute,
+		OperationInProgress: 5 * time.Minute,
+		TrafficConvergence:  2 * time.Minute,
+	}
+}
+
+// withCallTimeout derives a context bounded by the given per-call timeout.
+func withCallTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// wrapCallError converts a deadline exceedance of the per-call context into
+// a CallTimeoutError. Errors caused by the parent context are returned as-is.
+func wrapCallError(parent, callCtx context.Context, op string, timeout time.Duration, err error) error {
+	if err == nil {
+		return nil
+	}
+	if timeout > 0 && parent.Err() == nil && errors.Is(callCtx.Err(), context.DeadlineExceeded) {
+		return &CallTimeoutError{Op: op, Timeout: timeout, Err: err}
+	}
+	return err
+}
@@ -0,0 +1,97 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestWrapCallError(t *testing.T) {
+	apiErr := status.Error(codes.DeadlineExceeded, "context deadline exceeded")
+
+	t.Run("per-call timeout", func(t *testing.T) {
+		parent := context.Background()
+		callCtx, cancel := withCallTimeout(parent, time.Millisecond)
+		defer cancel()
+		<-callCtx.Done()
+
+		err := wrapCallError(parent, callCtx, "GetService", time.Millisecond, apiErr)
+		var timeoutErr *CallTimeoutError
+		if !errors.As(err, &timeoutErr) || !errors.Is(err, ErrCallTimeout) {
+			t.Fatalf("expected a call timeout, got %v", err)
+		}
+		if timeoutErr.Op != "GetService" || timeoutErr.Timeout != time.Millisecond || !errors.Is(err, apiErr) {
+			t.Errorf("unexpected call timeout %+v", timeoutErr)
+		}
+		if !strings.Contains(err.Error(), "GetService timed out after 1ms") {
+			t.Errorf("expected the error to name the API call, got %q", err)
+		}
+	})
+
+	t.Run("parent cancelled", func(t *testing.T) {
+		parent, cancelParent := context.WithCancel(context.Background())
+		callCtx, cancel := withCallTimeout(parent, time.Minute)
+		defer cancel()
+		cancelParent()
+
+		err := wrapCallError(parent, callCtx, "GetService", time.Minute, context.Canceled)
+		if errors.Is(err, ErrCallTimeout) || !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the cancellation returned as is, got %v", err)
+		}
+	})
+
+	t.Run("parent deadline", func(t *testing.T) {
+		parent, cancelParent := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancelParent()
+		callCtx, cancel := withCallTimeout(parent, time.Minute)
+		defer cancel()
+		<-callCtx.Done()
+
+		// The stage deadline is not the call's timeout
+		if err := wrapCallError(parent, callCtx, "ListRevisions", time.Minute, apiErr); errors.Is(err, ErrCallTimeout) {
+			t.Errorf("expected the parent deadline not reported as a call timeout, got %v", err)
+		}
+	})
+
+	t.Run("timeout disabled", func(t *testing.T) {
+		parent, cancelParent := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancelParent()
+		callCtx, cancel := withCallTimeout(parent, 0)
+		defer cancel()
+		if _, ok := callCtx.Deadline(); !ok {
+			t.Error("expected the parent deadline kept")
+		}
+		<-callCtx.Done()
+
+		if err := wrapCallError(parent, callCtx, "GetService", 0, apiErr); err != apiErr {
+			t.Errorf("expected the error returned as is, got %v", err)
+		}
+	})
+
+	t.Run("no error", func(t *testing.T) {
+		callCtx, cancel := withCallTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := wrapCallError(context.Background(), callCtx, "GetService", time.Minute, nil); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	})
+}
@@ -17,7 +17,12 @@ package cloudrun
 import (
 	"context"
 	"fmt"
+	"maps"
+	"path"
+	"slices"
 	"sort"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/run/apiv2/runpb"
 )
@@ -71,87 +76,278 @@ func BuildTrafficTargets(splits []TrafficSplit) []*runpb.TrafficTarget {
 	return targets
 }
 
+// TrafficTags defines the tags attached to traffic targets written by
+// Promote and Rollback. Tags give each target a stable URL of the form
+// https://{tag}---{service}-{hash}.a.run.app.
+type TrafficTags struct {
+	// Candidate is the tag attached to the new (latest) revision.
+	Candidate string
+
+	// Stable is the tag attached to the previous (stable) revision.
+	Stable string
+}
+
 // Promote promotes a revision by adjusting traffic split.
 // Parameters:
 //   - percent: Percentage of traffic to route to the latest revision (0-100)
+//   - tags: Tags to attach to the candidate and stable targets (optional)
 //
 // When percent is 100, all traffic goes to the latest revision.
-// When percent is less than 100, the remaining traffic goes to the previous revision.
-func (tm *TrafficManager) Promote(ctx context.Context, project, region, service string, percent int32) error {
+// When percent is less than 100, the remaining traffic goes to the previous revision,
+// the newest one besides the latest whose Ready condition didn't fail.
+// If a stable tag is set, the previous revision keeps its tag even at 100%
+// (with 0% traffic) so the stable URL remains reachable. Other tags, such as
+// those set by earlier stages, are kept without traffic, except those
+// pointing at deleted or failed revisions, see ReconcileTraffic.
+func (tm *TrafficManager) Promote(ctx context.Context, project, region, service string, percent int32, tags TrafficTags) error {
 	if percent < 0 || percent > 100 {
 		return fmt.Errorf("invalid traffic percentage: %d (must be 0-100)", percent)
 	}
 
-	// Get current service to find revisions
-	_, err := tm.client.GetService(ctx, project, region, service)
+	// Get current service to keep its tags
+	svc, err := tm.client.GetService(ctx, project, region, service)
 	if err != nil {
 		return fmt.Errorf("failed to get service: %w", err)
 	}
 
-	var traffic []*runpb.TrafficTarget
-
-	if percent == 100 {
-		// Route all traffic to latest revision
-		traffic = []*runpb.TrafficTarget{
-			{
-				Type:    runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST,
-				Percent: 100,
-			},
-		}
-	} else {
-		// Get revisions to find the previous one
-		revisions, err := tm.client.ListRevisions(ctx, project, region, service)
+	// Get revisions to find the previous one and the stale targets
+	var revisions []*runpb.Revision
+	if percent < 100 || tags.Stable != "" || hasRevisionTargets(svc.GetTraffic()) {
+		revisions, err = tm.client.ListRevisions(ctx, project, region, service)
 		if err != nil {
 			return fmt.Errorf("failed to list revisions: %w", err)
 		}
+	}
+	current, _ := ReconcileTraffic(svc.GetTraffic(), revisions)
 
-		if len(revisions) < 2 {
-			// Not enough revisions for splitting, route all to latest
-			traffic = []*runpb.TrafficTarget{
-				{
-					Type:    runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST,
-					Percent: 100,
-				},
-			}
-		} else {
-			// Sort revisions by creation time (newest first)
-			sortRevisionsByCreationTime(revisions)
+	// Route traffic to the latest revision
+	traffic := []*runpb.TrafficTarget{
+		{
+			Type:    runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST,
+			Percent: 100,
+			Tag:     tags.Candidate,
+		},
+	}
 
-			// Get the previous revision (second in the sorted list)
-			previousRev := revisions[1].Name
+	if percent < 100 || tags.Stable != "" {
+		// Sort revisions by creation time (newest first)
+		sortRevisionsByCreationTime(revisions)
+
+		// Get the previous revision, skipping failed ones the API
+		// refuses to route traffic to
+		var previousRev string
+		for _, rev := range revisions[min(1, len(revisions)):] {
+			if !revisionFailed(rev) {
+				previousRev = ShortRevisionName(rev.Name)
+				break
+			}
+		}
 
+		// Without a previous revision, all traffic stays on latest
+		if previousRev != "" {
 			// Split traffic between latest and previous
-			traffic = []*runpb.TrafficTarget{
-				{
-					Type:    runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST,
-					Percent: percent,
-				},
-				{
-					Type:     runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION,
-					Revision: previousRev,
-					Percent:  100 - percent,
-				},
-			}
+			traffic[0].Percent = percent
+			traffic = append(traffic, &runpb.TrafficTarget{
+				Type:     runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION,
+				Revision: previousRev,
+				Percent:  100 - percent,
+				Tag:      tags.Stable,
+			})
 		}
 	}
 
 	// Update traffic
+	traffic = append(traffic, untouchedTags(current, traffic)...)
 	return tm.client.UpdateTraffic(ctx, project, region, service, traffic)
 }
 
+// LatestTraffic returns traffic routing all traffic to the latest revision,
+// tagged with tag if set, keeping the other tags of current without traffic.
+// Targets of current pointing at deleted or failed revisions must be
+// reconciled first, see ReconcileTraffic.
+func LatestTraffic(current []*runpb.TrafficTarget, tag string) []*runpb.TrafficTarget {
+	traffic := []*runpb.TrafficTarget{
+		{
+			Type:    runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST,
+			Percent: 100,
+			Tag:     tag,
+		},
+	}
+	return append(traffic, untouchedTags(current, traffic)...)
+}
+
+// untouchedTags returns the tagged targets of current whose tag traffic
+// doesn't set, as targets without traffic, so rewriting the traffic split
+// doesn't drop the URLs of earlier tags.
+func untouchedTags(current, traffic []*runpb.TrafficTarget) []*runpb.TrafficTarget {
+	set := make(map[string]bool)
+	for _, t := range traffic {
+		set[t.Tag] = true
+	}
+	var kept []*runpb.TrafficTarget
+	for _, t := range current {
+		if t.Tag == "" || set[t.Tag] {
+			continue
+		}
+		set[t.Tag] = true
+		kept = append(kept, &runpb.TrafficTarget{Type: t.Type, Revision: t.Revision, Tag: t.Tag})
+	}
+	return kept
+}
+
+// PromoteTag routes percent of the traffic to the revision the tag points at,
+// and the rest to the revision serving the most traffic besides it, see
+// TagTraffic. Targets pointing at deleted or failed revisions are reconciled
+// first, see ReconcileTraffic. It returns the tagged revision.
+func (tm *TrafficManager) PromoteTag(ctx context.Context, project, region, service, tag string, percent int32, stableTag string) (string, error) {
+	svc, err := tm.client.GetService(ctx, project, region, service)
+	if err != nil {
+		return "", fmt.Errorf("failed to get service: %w", err)
+	}
+	if hasRevisionTargets(svc.GetTraffic()) {
+		revisions, err := tm.client.ListRevisions(ctx, project, region, service)
+		if err != nil {
+			return "", fmt.Errorf("failed to list revisions: %w", err)
+		}
+		svc, _ = reconcileServiceTraffic(svc, revisions)
+	}
+	traffic, revision, err := TagTraffic(svc, tag, percent, stableTag)
+	if err != nil {
+		return "", err
+	}
+	return revision, tm.client.UpdateTraffic(ctx, project, region, service, traffic)
+}
+
+// TagTraffic returns the traffic of the service with percent routed to the
+// revision the tag points at, keeping the tag on it, and the rest to the
+// revision serving the most traffic besides it, tagged with stableTag if set.
+// Other tags without traffic are kept. The tag is resolved when called, so
+// pipelines can address a revision by tag whatever its name.
+func TagTraffic(svc *runpb.Service, tag string, percent int32, stableTag string) ([]*runpb.TrafficTarget, string, error) {
+	if percent < 0 || percent > 100 {
+		return nil, "", fmt.Errorf("invalid traffic percentage: %d (must be 0-100)", percent)
+	}
+
+	// Statuses resolve tags on the latest revision to the revision name
+	var revision string
+	for _, t := range svc.GetTrafficStatuses() {
+		if t.Tag == tag && t.Revision != "" {
+			revision = ShortRevisionName(t.Revision)
+		}
+	}
+	if revision == "" {
+		for _, t := range svc.GetTraffic() {
+			if t.Tag == tag && t.Revision != "" {
+				revision = ShortRevisionName(t.Revision)
+			}
+		}
+	}
+	if revision == "" {
+		return nil, "", fmt.Errorf("traffic tag %s doesn't point at a revision of service %s", tag, GetServiceName(svc.Name))
+	}
+
+	traffic := []*runpb.TrafficTarget{{
+		Type:     runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION,
+		Revision: revision,
+		Percent:  percent,
+		Tag:      tag,
+	}}
+
+	var (
+		stable        string
+		stablePercent int32
+	)
+	for _, t := range svc.GetTrafficStatuses() {
+		name := ShortRevisionName(t.Revision)
+		if name != "" && name != revision && t.Percent > stablePercent {
+			stable, stablePercent = name, t.Percent
+		}
+	}
+	switch {
+	case stable != "" && (percent < 100 || stableTag != ""):
+		traffic = append(traffic, &runpb.TrafficTarget{
+			Type:     runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION,
+			Revision: stable,
+			Percent:  100 - percent,
+			Tag:      stableTag,
+		})
+	case stable == "" && percent < 100:
+		return nil, "", fmt.Errorf("no revision besides %s (tag %s) serves traffic to keep %d%% on", revision, tag, 100-percent)
+	}
+
+	kept, _, _ := TagOnlyTargets(svc.GetTraffic(), nil)
+	for _, t := range kept {
+		if t.Tag != tag && t.Tag != stableTag {
+			traffic = append(traffic, t)
+		}
+	}
+	return traffic, revision, nil
+}
+
 // Rollback rolls back to a specific revision.
-func (tm *TrafficManager) Rollback(ctx context.Context, project, region, service, revision string) error {
+// If tag is set, it is attached to the rollback target.
+func (tm *TrafficManager) Rollback(ctx context.Context, project, region, service, revision, tag string) error {
 	traffic := []*runpb.TrafficTarget{
 		{
 			Type:     runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION,
 			Revision: revision,
 			Percent:  100,
+			Tag:      tag,
 		},
 	}
 
 	return tm.client.UpdateTraffic(ctx, project, region, service, traffic)
 }
 
+// trafficPollInterval is how often the traffic status is polled while
+// waiting for a traffic update to converge.
+const trafficPollInterval = 2 * time.Second
+
+// trafficTarget is a traffic target or the status of one.
+type trafficTarget interface {
+	GetType() runpb.TrafficTargetAllocationType
+	GetRevision() string
+	GetPercent() int32
+}
+
+// trafficAllocation returns the percent of traffic per revision, "LATEST"
+// standing for the latest revision. Targets without traffic, such as tags,
+// are left out.
+func trafficAllocation[T trafficTarget](targets []T) map[string]int32 {
+	allocation := make(map[string]int32, len(targets))
+	for _, t := range targets {
+		if t.GetPercent() == 0 {
+			continue
+		}
+		name := ShortRevisionName(t.GetRevision())
+		if t.GetType() == runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST {
+			name = "LATEST"
+		}
+		allocation[name] += t.GetPercent()
+	}
+	return allocation
+}
+
+// formatTrafficAllocation formats the traffic allocation of targets, e.g.
+// "LATEST=10%, my-service-00041=90%".
+func formatTrafficAllocation[T trafficTarget](targets []T) string {
+	allocation := trafficAllocation(targets)
+	if len(allocation) == 0 {
+		return "no traffic"
+	}
+	parts := make([]string, 0, len(allocation))
+	for _, name := range slices.Sorted(maps.Keys(allocation)) {
+		parts = append(parts, fmt.Sprintf("%s=%d%%", name, allocation[name]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// TrafficConverged reports whether the traffic statuses of a service serve
+// the requested traffic split. Tags are not compared.
+func TrafficConverged(statuses []*runpb.TrafficTargetStatus, traffic []*runpb.TrafficTarget) bool {
+	return maps.Equal(trafficAllocation(statuses), trafficAllocation(traffic))
+}
+
 // GetCurrentTraffic returns the current traffic allocation.
 func (tm *TrafficManager) GetCurrentTraffic(ctx context.Context, project, region, service string) ([]TrafficSplit, error) {
 	svc, err := tm.client.GetService(ctx, project, region, service)
@@ -179,6 +375,33 @@ func (tm *TrafficManager) GetCurrentTraffic(ctx context.Context, project, region
 	return splits, nil
 }
 
+// TagOnlyTargets returns the targets of the traffic that carry a tag but no
+// traffic, such as preview or stable URLs, so they can be kept when the
+// traffic is rewritten. Targets whose tag matches one of the prune patterns
+// (glob patterns, e.g. "pr-*") are left out, and their tags returned.
+func TagOnlyTargets(traffic []*runpb.TrafficTarget, prune []string) (kept []*runpb.TrafficTarget, pruned []string, err error) {
+	for _, t := range traffic {
+		if t.Percent != 0 || t.Tag == "" {
+			continue
+		}
+		match := false
+		for _, p := range prune {
+			if match, err = path.Match(p, t.Tag); err != nil {
+				return nil, nil, fmt.Errorf("invalid tag pattern %q: %w", p, err)
+			}
+			if match {
+				break
+			}
+		}
+		if match {
+			pruned = append(pruned, t.Tag)
+			continue
+		}
+		kept = append(kept, t)
+	}
+	return kept, pruned, nil
+}
+
 // sortRevisionsByCreationTime sorts revisions by creation time (newest first).
 func sortRevisionsByCreationTime(revisions []*runpb.Revision) {
 	sort.Slice(revisions, func(i, j int) bool {
@@ -195,19 +418,35 @@ type CanaryConfig struct {
 	// Steps defines the traffic percentages for each canary step.
 	// Example: [10, 25, 50, 100]
 	Steps []int32
-
-	// AnalysisEnabled indicates if automated analysis should be performed.
-	AnalysisEnabled bool
-
-	// AnalysisThreshold is the failure threshold for automated analysis.
-	AnalysisThreshold float64
 }
 
 // DefaultCanaryConfig returns a default canary configuration.
 func DefaultCanaryConfig() *CanaryConfig {
 	return &CanaryConfig{
-		Steps:             []int32{10, 25, 50, 100},
-		AnalysisEnabled:   false,
-		AnalysisThreshold: 0.5,
+		Steps: []int32{10, 25, 50, 100},
+	}
+}
+
+// Validate checks that the steps are within 0-100 and strictly increasing.
+func (c *CanaryConfig) Validate() error {
+	if len(c.Steps) == 0 {
+		return fmt.Errorf("canary steps must not be empty")
+	}
+	for i, step := range c.Steps {
+		if step < 0 || step > 100 {
+			return fmt.Errorf("invalid canary step %d: must be 0-100", step)
+		}
+		if i > 0 && step <= c.Steps[i-1] {
+			return fmt.Errorf("canary steps must be strictly increasing: %v", c.Steps)
+		}
+	}
+	return nil
+}
+
+// Step returns the traffic percentage of the step at index.
+func (c *CanaryConfig) Step(index int) (int32, error) {
+	if index < 0 || index >= len(c.Steps) {
+		return 0, fmt.Errorf("no canary step %d: %d step(s) defined", index+1, len(c.Steps))
 	}
+	return c.Steps[index], nil
 }
@@ -0,0 +1,333 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrun
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestTagOnlyTargets(t *testing.T) {
+	traffic := []*runpb.TrafficTarget{
+		{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "my-service-00002", Percent: 90, Tag: "stable"},
+		{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST, Percent: 10},
+		{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "my-service-00001", Tag: "pr-12"},
+		{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "my-service-00001", Tag: "debug"},
+		{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "my-service-00001"},
+	}
+
+	kept, pruned, err := TagOnlyTargets(traffic, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kept) != 2 || kept[0].Tag != "pr-12" || kept[1].Tag != "debug" || len(pruned) != 0 {
+		t.Errorf("expected the tags without traffic to be kept, got %v, pruned %v", kept, pruned)
+	}
+
+	kept, pruned, err = TagOnlyTargets(traffic, []string{"pr-*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kept) != 1 || kept[0].Tag != "debug" || !slices.Equal(pruned, []string{"pr-12"}) {
+		t.Errorf("expected pr-12 to be pruned, got %v, pruned %v", kept, pruned)
+	}
+
+	if _, _, err := TagOnlyTargets(traffic, []string{"["}); err == nil {
+		t.Error("expected an invalid pattern error")
+	}
+}
+
+func TestTagTraffic(t *testing.T) {
+	svc := &runpb.Service{
+		Name: "projects/p/locations/r/services/my-service",
+		Traffic: []*runpb.TrafficTarget{
+			{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "my-service-00002", Percent: 100},
+			{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST, Tag: "canary"},
+			{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "my-service-00001", Tag: "debug"},
+		},
+		TrafficStatuses: []*runpb.TrafficTargetStatus{
+			{Revision: "my-service-00002", Percent: 100},
+			{Revision: "my-service-00003", Tag: "canary"},
+			{Revision: "my-service-00001", Tag: "debug"},
+		},
+	}
+
+	traffic, revision, err := TagTraffic(svc, "canary", 10, "stable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revision != "my-service-00003" {
+		t.Errorf("expected the tag to resolve to my-service-00003, got %s", revision)
+	}
+	want := []struct {
+		revision string
+		percent  int32
+		tag      string
+	}{
+		{"my-service-00003", 10, "canary"},
+		{"my-service-00002", 90, "stable"},
+		{"my-service-00001", 0, "debug"},
+	}
+	if len(traffic) != len(want) {
+		t.Fatalf("expected %d targets, got %v", len(want), traffic)
+	}
+	for i, w := range want {
+		if traffic[i].Revision != w.revision || traffic[i].Percent != w.percent || traffic[i].Tag != w.tag {
+			t.Errorf("target %d: expected %v, got %v", i, w, traffic[i])
+		}
+	}
+
+	// At 100% without a stable tag, the stable revision is dropped
+	traffic, _, err = TagTraffic(svc, "canary", 100, "")
+	if err != nil || len(traffic) != 2 || traffic[0].Percent != 100 || traffic[1].Tag != "debug" {
+		t.Errorf("expected all traffic on the tagged revision, got %v, %v", traffic, err)
+	}
+
+	if _, _, err := TagTraffic(svc, "missing", 10, ""); err == nil {
+		t.Error("expected an unknown tag error")
+	}
+	if _, _, err := TagTraffic(svc, "debug", 10, ""); err != nil {
+		t.Errorf("expected a tag on an older revision to resolve, got %v", err)
+	}
+}
+
+// trafficRecorder is a Client serving a service whose traffic is replaced
+// by UpdateTraffic, so consecutive promotions see each other's tags.
+type trafficRecorder struct {
+	Client
+	service   *runpb.Service
+	revisions []*runpb.Revision
+}
+
+func (c *trafficRecorder) GetService(ctx context.Context, project, region, service string) (*runpb.Service, error) {
+	return c.service, nil
+}
+
+func (c *trafficRecorder) ListRevisions(ctx context.Context, project, region, service string) ([]*runpb.Revision, error) {
+	return slices.Clone(c.revisions), nil
+}
+
+func (c *trafficRecorder) UpdateTraffic(ctx context.Context, project, region, service string, traffic []*runpb.TrafficTarget) error {
+	c.service.Traffic = traffic
+	return nil
+}
+
+func TestPromoteKeepsTags(t *testing.T) {
+	now := time.Now()
+	revision := func(name string, age time.Duration) *runpb.Revision {
+		return &runpb.Revision{
+			Name:       "projects/p/locations/r/services/my-service/revisions/" + name,
+			CreateTime: timestamppb.New(now.Add(-age)),
+		}
+	}
+	latest := runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST
+	byRevision := runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION
+	client := &trafficRecorder{
+		service: &runpb.Service{
+			Traffic: []*runpb.TrafficTarget{
+				{Type: byRevision, Revision: "my-service-00002", Percent: 100},
+				{Type: byRevision, Revision: "my-service-00001", Tag: "preview"},
+				// Tags on deleted revisions are dropped
+				{Type: byRevision, Revision: "my-service-00000", Tag: "old"},
+			},
+		},
+		revisions: []*runpb.Revision{
+			revision("my-service-00001", 2*time.Hour),
+			revision("my-service-00003", 0),
+			revision("my-service-00002", time.Hour),
+		},
+	}
+	tm := NewTrafficManager(client)
+
+	type target struct {
+		typ      runpb.TrafficTargetAllocationType
+		revision string
+		percent  int32
+		tag      string
+	}
+	steps := []struct {
+		name    string
+		percent int32
+		tags    TrafficTags
+		want    []target
+	}{
+		{
+			name:    "tags set",
+			percent: 10,
+			tags:    TrafficTags{Candidate: "canary", Stable: "stable"},
+			want: []target{
+				{latest, "", 10, "canary"},
+				{byRevision, "my-service-00002", 90, "stable"},
+				{byRevision, "my-service-00001", 0, "preview"},
+			},
+		},
+		{
+			name:    "tags kept without traffic",
+			percent: 50,
+			want: []target{
+				{latest, "", 50, ""},
+				{byRevision, "my-service-00002", 50, ""},
+				{latest, "", 0, "canary"},
+				{byRevision, "my-service-00002", 0, "stable"},
+				{byRevision, "my-service-00001", 0, "preview"},
+			},
+		},
+		{
+			name:    "tags set again",
+			percent: 100,
+			tags:    TrafficTags{Candidate: "canary", Stable: "stable"},
+			want: []target{
+				{latest, "", 100, "canary"},
+				{byRevision, "my-service-00002", 0, "stable"},
+				{byRevision, "my-service-00001", 0, "preview"},
+			},
+		},
+	}
+	for _, step := range steps {
+		if err := tm.Promote(context.Background(), "p", "r", "my-service", step.percent, step.tags); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		traffic := client.service.Traffic
+		if len(traffic) != len(step.want) {
+			t.Fatalf("%s: expected %d targets, got %v", step.name, len(step.want), traffic)
+		}
+		for i, w := range step.want {
+			got := target{traffic[i].Type, traffic[i].Revision, traffic[i].Percent, traffic[i].Tag}
+			if got != w {
+				t.Errorf("%s: target %d: expected %v, got %v", step.name, i, w, got)
+			}
+		}
+	}
+}
+
+func TestReconcileTraffic(t *testing.T) {
+	ready := func(name string, state runpb.Condition_State) *runpb.Revision {
+		return &runpb.Revision{
+			Name:       "projects/p/locations/r/services/my-service/revisions/" + name,
+			Conditions: []*runpb.Condition{{Type: "Ready", State: state}},
+		}
+	}
+	revisions := []*runpb.Revision{
+		ready("my-service-00003", runpb.Condition_CONDITION_SUCCEEDED),
+		ready("my-service-00002", runpb.Condition_CONDITION_FAILED),
+		ready("my-service-00001", runpb.Condition_CONDITION_SUCCEEDED),
+	}
+	traffic := []*runpb.TrafficTarget{
+		{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "my-service-00003", Percent: 60},
+		{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "my-service-00001", Percent: 10},
+		{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "my-service-00002", Percent: 20, Tag: "stable"},
+		{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "my-service-00000", Percent: 10},
+		{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "my-service-00000", Tag: "old"},
+	}
+
+	reconciled, stale := ReconcileTraffic(traffic, revisions)
+	if len(reconciled) != 2 || reconciled[0].Percent != 90 || reconciled[1].Percent != 10 {
+		t.Errorf("expected the stale traffic to move to the largest target, got %v", reconciled)
+	}
+	if traffic[0].Percent != 60 {
+		t.Error("expected the traffic passed in not to be modified")
+	}
+	var got []string
+	for _, s := range stale {
+		got = append(got, s.String())
+	}
+	want := []string{"my-service-00002 (not ready, 20%, tag stable)", "my-service-00000 (deleted, 10%)", "my-service-00000 (deleted, 0%, tag old)"}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected stale targets %v, got %v", want, got)
+	}
+
+	// Without traffic left, the latest revision gets it
+	reconciled, _ = ReconcileTraffic(traffic[2:], revisions)
+	if len(reconciled) != 1 || reconciled[0].Type != runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST || reconciled[0].Percent != 30 {
+		t.Errorf("expected the traffic to move to the latest revision, got %v", reconciled)
+	}
+
+	if reconciled, stale := ReconcileTraffic(traffic[:2], revisions); len(stale) != 0 || len(reconciled) != 2 {
+		t.Errorf("expected valid traffic to be kept, got %v, stale %v", reconciled, stale)
+	}
+
+	// Promoting by tag doesn't keep traffic on a stale revision
+	svc := &runpb.Service{
+		Name: "projects/p/locations/r/services/my-service",
+		Traffic: []*runpb.TrafficTarget{
+			{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "my-service-00002", Percent: 80},
+			{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "my-service-00001", Percent: 20},
+			{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "my-service-00003", Tag: "canary"},
+		},
+		TrafficStatuses: []*runpb.TrafficTargetStatus{
+			{Revision: "my-service-00002", Percent: 80},
+			{Revision: "my-service-00001", Percent: 20},
+			{Revision: "my-service-00003", Tag: "canary"},
+		},
+	}
+	reconciledSvc, _ := reconcileServiceTraffic(svc, revisions)
+	tagged, _, err := TagTraffic(reconciledSvc, "canary", 10, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tagged) != 2 || tagged[1].Revision != "my-service-00001" || tagged[1].Percent != 90 {
+		t.Errorf("expected the remaining traffic on my-service-00001, got %v", tagged)
+	}
+	if len(svc.Traffic) != 3 {
+		t.Error("expected the service passed in not to be modified")
+	}
+}
+
+func TestTrafficConverged(t *testing.T) {
+	traffic := []*runpb.TrafficTarget{
+		{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST, Percent: 10, Tag: "canary"},
+		{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "my-service-00041", Percent: 90},
+		{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "my-service-00040", Tag: "pr-12"},
+	}
+
+	tests := []struct {
+		name     string
+		statuses []*runpb.TrafficTargetStatus
+		want     bool
+	}{
+		{
+			name: "converged",
+			statuses: []*runpb.TrafficTargetStatus{
+				{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST, Revision: "my-service-00042", Percent: 10},
+				{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "projects/p/locations/r/services/my-service/revisions/my-service-00041", Percent: 90, Tag: "stable"},
+			},
+			want: true,
+		},
+		{
+			name: "previous split",
+			statuses: []*runpb.TrafficTargetStatus{
+				{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "my-service-00041", Percent: 100},
+			},
+		},
+		{name: "no status"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TrafficConverged(tt.statuses, traffic); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	if got := formatTrafficAllocation(traffic); got != "LATEST=10%, my-service-00041=90%" {
+		t.Errorf("unexpected allocation %s", got)
+	}
+}
@@ -44,13 +44,27 @@ type ApplicationConfig struct {
 	// Name is the name of the application.
 	Name string `json:"name"`
 
+	// Kind is the kind of Cloud Run resource the application deploys:
+	// "CloudRunApp" for a service or "CloudRunJob" for a job.
+	// The plugin doesn't receive the document kind, so jobs must set it here.
+	// Default: "CloudRunApp"
+	Kind string `json:"kind,omitempty"`
+
 	// Labels are key-value pairs for organizing applications.
 	Labels map[string]string `json:"labels,omitempty"`
 
 	// ServiceManifestPath is the path to the Cloud Run service manifest file
-	// relative to the application directory.
-	// Default: "service.yaml"
-	ServiceManifestPath string `json:"serviceManifestPath"`
+	// relative to the application directory, or a list of paths. Paths may be
+	// glob patterns (e.g. "services/*.yaml"); every matched manifest is
+	// deployed, the first service being the application's service.
+	// Default: the first existing file of the plugin's serviceManifestCandidates
+	// (service.yaml, service.yml, cloudrun.yaml, ...)
+	ServiceManifestPath ManifestPaths `json:"serviceManifestPath"`
+
+	// JobManifestPath is the path to the Cloud Run job manifest file
+	// relative to the application directory. Used by CloudRunJob applications.
+	// Default: the first existing file of job.yaml, job.yml, cloudrun/job.yaml
+	JobManifestPath string `json:"jobManifestPath,omitempty"`
 
 	// Input configuration for the deployment.
 	Input InputConfig `json:"input"`
@@ -62,6 +76,163 @@ type ApplicationConfig struct {
 	// PipelineSync defines the pipeline sync strategy options.
 	// Used when a custom pipeline is specified.
 	PipelineSync *PipelineSyncConfig `json:"pipelineSync,omitempty"`
+
+	// Canary defines the canary steps used by CLOUDRUN_PROMOTE stages
+	// that don't specify a percent.
+	Canary *CanaryConfig `json:"canary,omitempty"`
+
+	// Budget is the time the whole deployment may take, e.g. "45m", counted
+	// from the start of its first stage. Waits of each stage are bounded by the
+	// remaining budget, and stages fail early when less than their
+	// `requiredTime` remains.
+	Budget Duration `json:"budget,omitempty"`
+
+	// DryRun rehearses the deployment: every stage reads the live state and
+	// logs the changes it would make, but no create, update, delete, or run
+	// call is made.
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// AllowLargeChange deploys changes scoring above the change budget of the
+	// deploy target. Adding "[allow-large-change]" to the commit message
+	// overrides the budget for that commit only.
+	AllowLargeChange bool `json:"allowLargeChange,omitempty"`
+
+	// ConfirmIdleCost deploys changes increasing the idle cost of the
+	// service to production deploy targets. Adding [confirm-idle-cost] to
+	// the commit message confirms them for that commit only.
+	ConfirmIdleCost bool `json:"confirmIdleCost,omitempty"`
+
+	// Renderer selects how the service or job manifest is rendered before it
+	// is deployed. If not specified, the deployment variables are rendered
+	// into the manifest with Go template syntax ("go-template").
+	Renderer *RendererConfig `json:"renderer,omitempty"`
+
+	// IgnoreServiceMesh leaves the Cloud Service Mesh of the service to
+	// another system: the mesh in the service manifest is ignored, and the
+	// mesh of the live service is kept and not diffed. By default, the mesh
+	// declared in the manifest (template.serviceMesh) is managed.
+	IgnoreServiceMesh bool `json:"ignoreServiceMesh,omitempty"`
+
+	// Invokers declares the roles/run.invoker bindings of the service.
+	// If set, CLOUDRUN_SYNC replaces the service's invoker bindings with them.
+	Invokers []InvokerBinding `json:"invokers,omitempty"`
+
+	// ResultUpload uploads the deployment result document to Cloud Storage
+	// once the deployment completes, fails, or is rolled back.
+	ResultUpload *ResultUploadConfig `json:"resultUpload,omitempty"`
+
+	// Params declares typed parameters, available as {{ .Params.<name> }} in
+	// the service or job manifest, the application input, and stage configs.
+	Params []ParamConfig `json:"params,omitempty"`
+}
+
+// Parameter types.
+const (
+	ParamTypeString = "string"
+	ParamTypeInt    = "int"
+	ParamTypeNumber = "number"
+	ParamTypeBool   = "bool"
+)
+
+// ParamConfig declares a parameter of the application. Its value is the
+// value of the deploy target, or the default. Parameters without either fail
+// the deployment and plan preview.
+//
+// Example:
+//
+//	params:
+//	  - name: maxInstances
+//	    type: int
+//	    default: 3
+//	    targets:
+//	      production: 20
+type ParamConfig struct {
+	// Name is the name of the parameter, a Go identifier such as "logLevel".
+	Name string `json:"name"`
+
+	// Type is the type of the value: "string", "int", "number", or "bool".
+	// Default: "string"
+	Type string `json:"type,omitempty"`
+
+	// Description documents the parameter.
+	Description string `json:"description,omitempty"`
+
+	// Default is the value of deploy targets not listed in Targets.
+	Default interface{} `json:"default,omitempty"`
+
+	// Targets are the values of deploy targets, by deploy target name.
+	Targets map[string]interface{} `json:"targets,omitempty"`
+}
+
+// Application kinds.
+const (
+	// KindCloudRunApp deploys a Cloud Run service.
+	KindCloudRunApp = "CloudRunApp"
+
+	// KindCloudRunJob deploys a Cloud Run job.
+	KindCloudRunJob = "CloudRunJob"
+)
+
+// IsJob reports whether the application deploys a Cloud Run job.
+func (c *ApplicationConfig) IsJob() bool {
+	return c.Kind == KindCloudRunJob
+}
+
+// InvokerBinding grants roles/run.invoker to members, optionally under a condition.
+//
+// Example:
+//
+//	invokers:
+//	  - members: ["allUsers"]
+//	    condition:
+//	      title: temporary-public-access
+//	      expression: request.time < timestamp("2025-12-31T00:00:00Z")
+type InvokerBinding struct {
+	// Members are the IAM principals, e.g. "allUsers" or
+	// "serviceAccount:caller@my-project.iam.gserviceaccount.com".
+	Members []string `json:"members"`
+
+	// Condition restricts the binding, e.g. with an expiry.
+	Condition *IAMCondition `json:"condition,omitempty"`
+}
+
+// IAMCondition is a CEL condition on an IAM binding.
+type IAMCondition struct {
+	// Title identifies the condition.
+	Title string `json:"title"`
+
+	// Description describes the condition.
+	Description string `json:"description,omitempty"`
+
+	// Expression is the CEL expression, e.g. request.host == "api.example.com".
+	Expression string `json:"expression"`
+}
+
+// CanaryConfig defines the traffic steps of a canary deployment.
+// Each CLOUDRUN_PROMOTE stage without a percent moves to the next step.
+//
+// Example:
+//
+//	canary:
+//	  steps: [5, 25, 50, 100]
+//	  autoPromote:
+//	    within: 1h
+type CanaryConfig struct {
+	// Steps are the traffic percentages routed to the new revision, in order.
+	Steps []int32 `json:"steps"`
+
+	// AutoPromote promotes the new revision to 100% and cleans up the canary
+	// once the last stage of the pipeline succeeds, so the pipeline doesn't
+	// need the final CLOUDRUN_PROMOTE and CLOUDRUN_CANARY_CLEANUP stages.
+	AutoPromote *AutoPromoteConfig `json:"autoPromote,omitempty"`
+}
+
+// AutoPromoteConfig defines when a canary is promoted automatically.
+type AutoPromoteConfig struct {
+	// Within is the time the stages of the deployment must succeed in,
+	// counted from the start of its first stage, e.g. "1h". A canary taking
+	// longer fails the last stage instead of being promoted.
+	Within Duration `json:"within"`
 }
 
 // InputConfig defines input parameters for Cloud Run deployment.
@@ -70,11 +241,22 @@ type InputConfig struct {
 	// If not specified, the service name from the manifest is used.
 	ServiceName string `json:"serviceName,omitempty"`
 
+	// JobName is the name of the Cloud Run job of a CloudRunJob application.
+	// If not specified, the job name from the manifest is used.
+	JobName string `json:"jobName,omitempty"`
+
 	// Image is the container image to deploy.
 	// This overrides the image specified in the service manifest.
 	// Example: "gcr.io/my-project/my-app:v1.0.0"
 	Image string `json:"image,omitempty"`
 
+	// ImageFile is the path, relative to the application directory, of a
+	// file written by CI that contains the container image to deploy, e.g.
+	// ".artifact" containing "gcr.io/my-project/my-app@sha256:...".
+	// The image is read at deploy time, so CI doesn't need to rewrite the
+	// application config on every build. It can't be set together with Image.
+	ImageFile string `json:"imageFile,omitempty"`
+
 	// ProjectID is the GCP project ID.
 	// This overrides the deploy target configuration.
 	ProjectID string `json:"projectID,omitempty"`
@@ -82,6 +264,51 @@ type InputConfig struct {
 	// Region is the GCP region.
 	// This overrides the deploy target configuration.
 	Region string `json:"region,omitempty"`
+
+	// CredentialsFile is the path, on the piped host, of the GCP service
+	// account key file to deploy this application with instead of the
+	// deploy target credentials. The application must be allowed by the
+	// plugin config credentialsOverride.
+	CredentialsFile string `json:"credentialsFile,omitempty"`
+
+	// CredentialsSecret is the Secret Manager secret holding the GCP service
+	// account key to deploy this application with instead of the deploy
+	// target credentials, read with the deploy target credentials. Short
+	// names such as "team-deployer-key" are resolved against the deploy
+	// target project. It can't be set together with CredentialsFile.
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+}
+
+// RendererConfig selects the manifest renderer of an application.
+//
+// Example:
+//
+//	renderer:
+//	  name: patch
+//	  with:
+//	    patches: [patches/production.json]
+type RendererConfig struct {
+	// Name is the renderer: "raw", "go-template", "patch", or "remote".
+	Name string `json:"name"`
+
+	// With contains renderer-specific options.
+	With map[string]interface{} `json:"with,omitempty"`
+}
+
+// ResultUploadConfig defines where deployment result documents are uploaded,
+// as <prefix>/<application ID>/<deployment ID>.json in the bucket.
+//
+// Example:
+//
+//	resultUpload:
+//	  bucket: my-compliance-bucket
+//	  prefix: deployments/cloudrun
+type ResultUploadConfig struct {
+	// Bucket is the Cloud Storage bucket.
+	Bucket string `json:"bucket"`
+
+	// Prefix is the object name prefix, without a trailing slash.
+	Prefix string `json:"prefix,omitempty"`
 }
 
 // QuickSyncConfig defines quick sync strategy options.
@@ -101,7 +328,10 @@ type PipelineSyncConfig struct {
 // PipelineStage defines a single stage in the deployment pipeline.
 type PipelineStage struct {
 	// Name is the stage name.
-	// Supported stages: CLOUDRUN_SYNC, CLOUDRUN_PROMOTE, CLOUDRUN_ROLLBACK, CLOUDRUN_CANARY_CLEANUP
+	// Supported stages: CLOUDRUN_SYNC, CLOUDRUN_PROMOTE, CLOUDRUN_ROLLBACK, CLOUDRUN_CANARY_CLEANUP,
+	// CLOUDRUN_HOLD, CLOUDRUN_UPDATE_TASK_QUEUE, CLOUDRUN_FAULT_INJECTION, CLOUDRUN_PREVIEW_CLEANUP,
+	// CLOUDRUN_ANALYSIS, CLOUDRUN_JOB_SYNC, CLOUDRUN_JOB_RUN, CLOUDRUN_JOB_ROLLBACK, CLOUDRUN_MIRROR_VERIFY,
+	// CLOUDRUN_DOMAIN_VERIFY, CLOUDRUN_BACKUP, CLOUDRUN_RESTORE
 	Name string `json:"name"`
 
 	// With contains stage-specific configuration.
@@ -0,0 +1,58 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ManifestPaths is a list of manifest paths configured as a single path
+// ("service.yaml") or a list of paths. Paths may be glob patterns.
+type ManifestPaths []string
+
+// UnmarshalJSON decodes the paths from a string or a list of strings.
+func (p *ManifestPaths) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	switch value := v.(type) {
+	case string:
+		if value == "" {
+			*p = nil
+			return nil
+		}
+		*p = ManifestPaths{value}
+		return nil
+	case []interface{}:
+		paths := make(ManifestPaths, 0, len(value))
+		for _, e := range value {
+			path, ok := e.(string)
+			if !ok || path == "" {
+				return fmt.Errorf("invalid manifest path: %s", string(data))
+			}
+			paths = append(paths, path)
+		}
+		*p = paths
+		return nil
+	case nil:
+		*p = nil
+		return nil
+	default:
+		return fmt.Errorf("invalid manifest paths: %s", string(data))
+	}
+}
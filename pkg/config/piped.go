@@ -56,8 +56,199 @@ type PluginConfig struct {
 	// If not specified, the plugin will use Application Default Credentials.
 	// Example: "/etc/piped/gcp-key.json"
 	CredentialsFile string `json:"credentialsFile"`
+
+	// APITimeouts defines per-call timeouts for Cloud Run Admin API calls.
+	// This can be overridden per deploy target.
+	APITimeouts APITimeoutConfig `json:"apiTimeouts,omitempty"`
+
+	// RateLimit defines the client-side rate limit for Cloud Run Admin API calls.
+	// This can be overridden per deploy target.
+	RateLimit RateLimitConfig `json:"rateLimit,omitempty"`
+
+	// APIEndpoint is the Cloud Run Admin API endpoint (host[:port]), e.g. a
+	// regional endpoint like "us-central1-run.googleapis.com" or a preview
+	// endpoint, or "regional" for the regional endpoint of each deploy
+	// target's region. This can be overridden per deploy target.
+	// Default: "run.googleapis.com:443"
+	APIEndpoint string `json:"apiEndpoint,omitempty"`
+
+	// CredentialsCheckInterval is how often the credentials of each deploy
+	// target are validated in the background, so expired or revoked
+	// credentials are detected before a deployment needs them.
+	// Default: 10m
+	CredentialsCheckInterval Duration `json:"credentialsCheckInterval,omitempty"`
+
+	// ServiceManifestCandidates are the paths, relative to the application
+	// directory, searched in order for the service manifest of applications
+	// that don't set serviceManifestPath.
+	// Default: ["service.yaml", "service.yml", "cloudrun.yaml", "cloudrun.yml",
+	// "cloudrun/service.yaml", "cloudrun/service.yml"]
+	ServiceManifestCandidates []string `json:"serviceManifestCandidates,omitempty"`
+
+	// OutputStyle is how plan preview details and stage logs are decorated:
+	// "emoji" uses emoji and Unicode symbols, "plain" only ASCII text for
+	// terminals and ticketing integrations that can't render them, and
+	// "markdown" plain text with Markdown headings in plan preview details.
+	// Default: "emoji"
+	OutputStyle string `json:"outputStyle,omitempty"`
+
+	// StageMetrics pushes the count and duration of stage executions to
+	// Cloud Monitoring as custom metrics.
+	StageMetrics *StageMetricsConfig `json:"stageMetrics,omitempty"`
+
+	// RevisionAnnotations annotates each revision created by CLOUDRUN_SYNC
+	// with the commit, author, and PipeCD application that produced it.
+	RevisionAnnotations *RevisionAnnotationsConfig `json:"revisionAnnotations,omitempty"`
+
+	// CredentialsOverride allows applications to deploy with their own
+	// credentials instead of the deploy target's, set with
+	// input.credentialsFile or input.credentialsSecret.
+	// Default: no application may override credentials
+	CredentialsOverride *CredentialsOverrideConfig `json:"credentialsOverride,omitempty"`
+
+	// PayloadLog logs sampled Admin API requests and responses of the
+	// deployments whose service manifest sets the pipecd.dev/debug-payload-log
+	// annotation, for debugging reconciliation mismatches.
+	// Default: payloads are never logged
+	PayloadLog *PayloadLogConfig `json:"payloadLog,omitempty"`
+
+	// StageHooks are notified when a stage starts, succeeds, or fails, with
+	// the application, deploy target, stage, revision, and traffic.
+	// Failing to notify a hook does not fail the stage.
+	StageHooks []StageHookConfig `json:"stageHooks,omitempty"`
 }
 
+// APIEndpointRegional selects the regional Admin API endpoint of the deploy
+// target's region as apiEndpoint.
+const APIEndpointRegional = "regional"
+
+// Events of stage hooks.
+const (
+	StageHookEventStarted   = "STARTED"
+	StageHookEventSucceeded = "SUCCEEDED"
+	StageHookEventFailed    = "FAILED"
+)
+
+// StageHookConfig defines where stage transitions are sent: an HTTP
+// endpoint or a Pub/Sub topic.
+//
+// Example:
+//
+//	stageHooks:
+//	  - url: https://hooks.example.com/pipecd
+//	    headers:
+//	      Authorization:
+//	        secretRef: projects/my-project/secrets/hook-token/versions/latest
+//	    events: [FAILED]
+//	  - topic: projects/my-project/topics/deployments
+type StageHookConfig struct {
+	// URL receives the notifications as JSON POST requests.
+	URL string `json:"url,omitempty"`
+
+	// Headers are added to the HTTP requests, e.g. an authorization header.
+	// Values may be secretRefs.
+	Headers map[string]SecretString `json:"headers,omitempty"`
+
+	// Topic is the Pub/Sub topic the notifications are published to,
+	// "projects/<project>/topics/<topic>". The deploy target credentials
+	// need roles/pubsub.publisher on it.
+	Topic string `json:"topic,omitempty"`
+
+	// Events are the notified events: STARTED, SUCCEEDED, FAILED.
+	// Default: all events
+	Events []string `json:"events,omitempty"`
+}
+
+// PayloadLogConfig defines where Admin API payloads are logged: a local file,
+// Cloud Logging, or both. Environment variable values are redacted.
+//
+// Example:
+//
+//	payloadLog:
+//	  file: /var/log/piped/cloudrun-payloads.jsonl
+//	  cloudLoggingProject: my-ops-project
+type PayloadLogConfig struct {
+	// File is the local file payloads are appended to as JSON lines.
+	File string `json:"file,omitempty"`
+
+	// CloudLoggingProject is the project payloads are written to in Cloud
+	// Logging.
+	CloudLoggingProject string `json:"cloudLoggingProject,omitempty"`
+
+	// LogName is the Cloud Logging log of the payloads.
+	// Default: "pipecd-cloudrun-payloads"
+	LogName string `json:"logName,omitempty"`
+}
+
+// CredentialsOverrideConfig defines the applications allowed to override the
+// deploy target credentials, and the credentials they may use.
+//
+// Example:
+//
+//	credentialsOverride:
+//	  allowedApplications: [app-id-1, app-id-2]
+//	  allowedCredentials:
+//	    - /etc/piped/teams/*.json
+//	    - projects/payments-*/secrets/*/versions/*
+type CredentialsOverrideConfig struct {
+	// AllowedApplications lists the IDs of the applications allowed to
+	// override credentials.
+	AllowedApplications []string `json:"allowedApplications,omitempty"`
+
+	// AllowedCredentials lists glob patterns the credentials file paths and
+	// secret version names (projects/{project}/secrets/{secret}/versions/{version})
+	// must match.
+	// Default: any
+	AllowedCredentials []string `json:"allowedCredentials,omitempty"`
+}
+
+// RevisionAnnotationsConfig defines the audit annotations of revisions.
+// Annotations whose value is unknown, e.g. the application URL without
+// consoleURL, are omitted.
+//
+// Example:
+//
+//	revisionAnnotations:
+//	  consoleURL: https://pipecd.example.com
+//	  commitKey: example.com/commit
+type RevisionAnnotationsConfig struct {
+	// ConsoleURL is the base URL of the PipeCD console, used to build the
+	// application URL.
+	// Example: "https://pipecd.example.com"
+	ConsoleURL string `json:"consoleURL,omitempty"`
+
+	// CommitKey is the annotation key of the deployed commit hash.
+	// Default: "pipecd.dev/commit"
+	CommitKey string `json:"commitKey,omitempty"`
+
+	// AuthorKey is the annotation key of the deployed commit author.
+	// Default: "pipecd.dev/commit-author"
+	AuthorKey string `json:"authorKey,omitempty"`
+
+	// ApplicationURLKey is the annotation key of the PipeCD application URL.
+	// Default: "pipecd.dev/application-url"
+	ApplicationURLKey string `json:"applicationURLKey,omitempty"`
+}
+
+// StageMetricsConfig defines where stage execution metrics are pushed.
+//
+// Example:
+//
+//	stageMetrics:
+//	  projectID: my-monitoring-project
+type StageMetricsConfig struct {
+	// ProjectID is the project the metrics are written to.
+	// Default: the project of the deploy target
+	ProjectID string `json:"projectID,omitempty"`
+}
+
+// Output styles of plan preview details and stage logs.
+const (
+	OutputStyleEmoji    = "emoji"
+	OutputStylePlain    = "plain"
+	OutputStyleMarkdown = "markdown"
+)
+
 // DeployTargetConfig defines deploy target specific configuration.
 // Each deploy target represents a different environment (staging, production, etc.)
 // with its own GCP project and settings.
@@ -77,4 +268,214 @@ type DeployTargetConfig struct {
 	// CredentialsFile is the path to the GCP service account key file.
 	// Overrides the plugin-level credentialsFile if specified.
 	CredentialsFile string `json:"credentialsFile"`
+
+	// APITimeouts defines per-call timeouts for Cloud Run Admin API calls.
+	// Non-zero fields override the plugin-level apiTimeouts.
+	APITimeouts APITimeoutConfig `json:"apiTimeouts,omitempty"`
+
+	// RateLimit defines the client-side rate limit for Cloud Run Admin API calls.
+	// Non-zero fields override the plugin-level rateLimit.
+	RateLimit RateLimitConfig `json:"rateLimit,omitempty"`
+
+	// APIEndpoint is the Cloud Run Admin API endpoint (host[:port]) for this
+	// deploy target, or "regional" for the regional endpoint of its region.
+	// Overrides the plugin-level apiEndpoint if specified.
+	APIEndpoint string `json:"apiEndpoint,omitempty"`
+
+	// ServiceDefaults defines labels, annotations, and a description merged
+	// into every service deployed to this target.
+	ServiceDefaults *ServiceDefaultsConfig `json:"serviceDefaults,omitempty"`
+
+	// Values are the values manifests reference as {{ .Values.<name> }} when
+	// deployed to this target, e.g. scaling or env vars differing between
+	// environments.
+	//
+	// Example:
+	//
+	//	values:
+	//	  minScale: 2
+	//	  logLevel: warn
+	Values map[string]any `json:"values,omitempty"`
+
+	// ValuesFile is the path of a YAML or JSON values file relative to the
+	// application directory, e.g. "values/production.yaml". Its values
+	// override Values, maps being merged key by key. Applications without
+	// the file only get Values.
+	ValuesFile string `json:"valuesFile,omitempty"`
+
+	// AllowedServices lists the service names this target accepts, as glob
+	// patterns (e.g. "payments-*"). If empty, every service is accepted.
+	AllowedServices []string `json:"allowedServices,omitempty"`
+
+	// DeniedServices lists service names this target rejects, as glob patterns.
+	// It takes precedence over AllowedServices.
+	DeniedServices []string `json:"deniedServices,omitempty"`
+
+	// AllowedStages lists the stages that may run against this target, e.g.
+	// only CLOUDRUN_PROMOTE and CLOUDRUN_ROLLBACK for a target whose revisions
+	// are deployed elsewhere. If empty, every stage may run.
+	AllowedStages []string `json:"allowedStages,omitempty"`
+
+	// NamingPolicy enforces naming conventions on the services and revisions
+	// deployed to this target.
+	NamingPolicy *NamingPolicyConfig `json:"namingPolicy,omitempty"`
+
+	// SkipImagePullCheck disables checking that images from Artifact Registry
+	// repositories in other projects can be pulled before deploying.
+	SkipImagePullCheck bool `json:"skipImagePullCheck,omitempty"`
+
+	// ImagePlatform is the platform images must be built for, checked in the
+	// image manifest before deploying, as "os/architecture[/variant]".
+	// Default: "linux/amd64"
+	ImagePlatform string `json:"imagePlatform,omitempty"`
+
+	// SkipImagePlatformCheck disables checking the image platform.
+	SkipImagePlatformCheck bool `json:"skipImagePlatformCheck,omitempty"`
+
+	// Failover defines a secondary region CLOUDRUN_SYNC deploys to when the
+	// primary region is unavailable.
+	Failover *FailoverConfig `json:"failover,omitempty"`
+
+	// ChangeBudget limits how sweeping a single deployment to this target
+	// may be without an explicit override.
+	ChangeBudget *ChangeBudgetConfig `json:"changeBudget,omitempty"`
+
+	// Production marks the deploy target as production: CLOUDRUN_SYNC fails
+	// on changes increasing the idle cost of a service (minimum instances,
+	// or their CPU allocation and resources) unless they are confirmed.
+	Production bool `json:"production,omitempty"`
+
+	// PromotionCooldown is the minimum time after the last successful
+	// deployment of an application completed before another deployment may
+	// shift its traffic: CLOUDRUN_PROMOTE, and CLOUDRUN_SYNC routing traffic
+	// to the new revision, fail until it elapsed. Adding [skip-cooldown] to
+	// the commit message skips it.
+	// Example: "30m"
+	PromotionCooldown Duration `json:"promotionCooldown,omitempty"`
+}
+
+// ChangeBudgetConfig defines the change budget of a deploy target. Each
+// deployment is scored by its image changes, scaling delta, and environment
+// variable churn against the live service.
+//
+// Example:
+//
+//	changeBudget:
+//	  maxScore: 20
+type ChangeBudgetConfig struct {
+	// MaxScore is the highest change score deployed without an override.
+	MaxScore int `json:"maxScore"`
+}
+
+// FailoverConfig defines the secondary region of a deploy target.
+type FailoverConfig struct {
+	// Region is the secondary region, in the same project.
+	// Example: "us-east1"
+	Region string `json:"region"`
+}
+
+// NamingPolicyConfig defines the naming conventions of a deploy target, as
+// regular expressions the whole name must match. Empty patterns accept any name.
+//
+// Example:
+//
+//	namingPolicy:
+//	  servicePattern: "^payments-[a-z0-9-]+$"
+//	  revisionPattern: "^payments-[a-z0-9-]+-v[0-9]+$"
+type NamingPolicyConfig struct {
+	// ServicePattern is the pattern service names must match.
+	ServicePattern string `json:"servicePattern,omitempty"`
+
+	// RevisionPattern is the pattern revision names set in the service
+	// manifest (spec.template.revision) must match. Revisions named by
+	// Cloud Run are not checked.
+	RevisionPattern string `json:"revisionPattern,omitempty"`
+}
+
+// ServiceDefaultsConfig defines service-level metadata merged into every
+// service deployed to a deploy target. Values set in the service manifest
+// take precedence; collisions are reported in the plan preview and stage logs.
+//
+// Example:
+//
+//	serviceDefaults:
+//	  labels:
+//	    team: platform
+//	    env: prod
+//	  description: "{{ .Service }} deployed by PipeCD to {{ .Target }}"
+type ServiceDefaultsConfig struct {
+	// Labels are merged into the service labels.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations are merged into the service annotations.
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Description is a Go template for the service description, used when the
+	// manifest has none. Available fields: .Service, .Target, .Project, .Region.
+	Description string `json:"description,omitempty"`
+}
+
+// APITimeoutConfig defines per-call timeouts for Cloud Run Admin API calls.
+// Unset fields fall back to the plugin defaults (get: 30s, list: 1m,
+// update: 10m, delete: 5m, operationInProgress: 5m, trafficConvergence: 2m).
+//
+// Example:
+//
+//	apiTimeouts:
+//	  get: 15s
+//	  update: 15m
+type APITimeoutConfig struct {
+	// Get is the timeout for single resource reads.
+	Get Duration `json:"get,omitempty"`
+
+	// List is the timeout for list operations.
+	List Duration `json:"list,omitempty"`
+
+	// Update is the timeout for create/update operations,
+	// including waiting for the operation to complete.
+	Update Duration `json:"update,omitempty"`
+
+	// Delete is the timeout for delete operations,
+	// including waiting for the operation to complete.
+	Delete Duration `json:"delete,omitempty"`
+
+	// OperationInProgress is how long an update rejected because another
+	// operation on the service is in progress waits for it to finish before
+	// being retried.
+	OperationInProgress Duration `json:"operationInProgress,omitempty"`
+
+	// TrafficConvergence is how long a traffic update waits for the service
+	// to serve the requested split before failing.
+	TrafficConvergence Duration `json:"trafficConvergence,omitempty"`
+}
+
+// RateLimitConfig defines a token-bucket rate limit for Cloud Run Admin API
+// calls, shared by all stages targeting the same GCP project, and the backoff
+// applied when the API returns RESOURCE_EXHAUSTED. When deploy targets on the
+// same project set different limits, the limit of the target whose stage
+// calls the project first applies to all of them until the config is
+// reloaded; the backoff applies per target.
+// Unset fields fall back to the plugin defaults (qps: 5, burst: 10,
+// maxRetries: 5, initialBackoff: 1s, maxBackoff: 30s).
+//
+// Example:
+//
+//	rateLimit:
+//	  qps: 2
+//	  burst: 5
+type RateLimitConfig struct {
+	// QPS is the sustained number of calls per second per project.
+	QPS float64 `json:"qps,omitempty"`
+
+	// Burst is the maximum number of calls allowed at once.
+	Burst int `json:"burst,omitempty"`
+
+	// MaxRetries is the maximum number of retries on RESOURCE_EXHAUSTED.
+	MaxRetries int `json:"maxRetries,omitempty"`
+
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff Duration `json:"initialBackoff,omitempty"`
+
+	// MaxBackoff caps the delay between retries.
+	MaxBackoff Duration `json:"maxBackoff,omitempty"`
 }
@@ -0,0 +1,59 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// SecretString is a string configured either as plain text or as a reference
+// to a GCP Secret Manager secret version, resolved when a stage executes:
+//
+//	Authorization:
+//	  secretRef: projects/my-project/secrets/hook-token/versions/latest
+type SecretString struct {
+	// Value is the plain text value, or the resolved secret.
+	Value string
+
+	// SecretRef is the reference of the secret, empty once resolved.
+	SecretRef string
+}
+
+// MarshalJSON encodes the value as a string, or the reference as a
+// {"secretRef": "..."} object.
+func (s SecretString) MarshalJSON() ([]byte, error) {
+	if s.SecretRef != "" {
+		return json.Marshal(map[string]string{"secretRef": s.SecretRef})
+	}
+	return json.Marshal(s.Value)
+}
+
+// UnmarshalJSON decodes a string or a {"secretRef": "..."} object.
+func (s *SecretString) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		*s = SecretString{Value: value}
+		return nil
+	}
+	var ref struct {
+		SecretRef string `json:"secretRef"`
+	}
+	if err := json.Unmarshal(data, &ref); err != nil || ref.SecretRef == "" {
+		return fmt.Errorf("invalid secret value: expected a string or secretRef, got %s", string(data))
+	}
+	*s = SecretString{SecretRef: ref.SecretRef}
+	return nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"time"
+
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// autoPromoteConfig returns the canary auto-promotion of the application,
+// or nil if its canary isn't promoted automatically.
+func autoPromoteConfig(spec *config.ApplicationConfig) *config.AutoPromoteConfig {
+	if spec == nil || spec.Canary == nil || spec.PipelineSync == nil || spec.IsJob() {
+		return nil
+	}
+	return spec.Canary.AutoPromote
+}
+
+// recordAutoPromoteStart records the start of the deployment when its first
+// stage runs, as the auto-promotion is counted from it.
+func recordAutoPromoteStart(ctx context.Context, input *sdk.ExecuteStageInput[config.ApplicationConfig], lp sdk.StageLogPersister, now time.Time) {
+	if autoPromoteConfig(input.Request.TargetDeploymentSource.ApplicationConfig.Spec) == nil {
+		return
+	}
+	if _, err := deploymentStartTime(ctx, newMetadataStore(input.Client, metadataNamespaceDeployment), now); err != nil {
+		lp.Infof("Warning: Failed to record the start of the deployment: %v", err)
+	}
+}
+
+// autoPromote promotes the new revision to 100% and cleans up the canary
+// after the last stage of the pipeline succeeded, if the application's
+// canary is promoted automatically. A canary that took longer than allowed
+// fails the stage instead, so it is rolled back rather than left on partial
+// traffic. It returns the result of the stage including the promotion.
+func (e *StageExecutor) autoPromote(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	lp sdk.StageLogPersister,
+	result *StageResult,
+	now time.Time,
+) (*StageResult, error) {
+	spec := input.Request.TargetDeploymentSource.ApplicationConfig.Spec
+	auto := autoPromoteConfig(spec)
+	if auto == nil || result.Status != StageStatusSuccess ||
+		input.Request.StageName == StageCloudRunCanaryCleanup ||
+		!isFinalStage(spec, input.Request.StageName, input.Request.StageIndex) {
+		return result, nil
+	}
+
+	start, err := deploymentStartTime(ctx, newMetadataStore(input.Client, metadataNamespaceDeployment), now)
+	if err != nil {
+		lp.Errorf("Failed to get the start of the deployment: %v", err)
+		return &StageResult{
+			Status:  StageStatusFailure,
+			Message: err.Error(),
+		}, err
+	}
+	elapsed := now.Sub(start)
+	err = checkAutoPromoteWithin(elapsed, auto.Within.Duration())
+	if err == nil {
+		err = checkAutoPromoteAllowed(deployTargets)
+	}
+	if err != nil {
+		lp.Errorf("%v", err)
+		return &StageResult{
+			Status:   StageStatusFailure,
+			Message:  err.Error(),
+			Revision: result.Revision,
+			Traffic:  result.Traffic,
+		}, err
+	}
+	lp.Infof("Canary passed in %s: promoting to 100%% and cleaning up", elapsed.Round(time.Second))
+
+	promoteInput := *input
+	promoteInput.Request.StageConfig = []byte(`{"percent": 100}`)
+	promoted, err := e.ExecutePromoteStage(ctx, cfg, deployTargets, &promoteInput, lp)
+	if err != nil {
+		return promoted, err
+	}
+
+	cleanupInput := *input
+	cleanupInput.Request.StageConfig = nil
+	cleaned, err := e.ExecuteCanaryCleanupStage(ctx, cfg, deployTargets, &cleanupInput, lp)
+	if err != nil {
+		cleaned.Revision = promoted.Revision
+		cleaned.Traffic = promoted.Traffic
+		return cleaned, err
+	}
+
+	metadata := maps.Clone(result.Metadata)
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	maps.Copy(metadata, promoted.Metadata)
+	metadata[MetadataKeyAutoPromoted] = "true"
+	return &StageResult{
+		Status:           StageStatusSuccess,
+		Revision:         promoted.Revision,
+		Traffic:          promoted.Traffic,
+		DeletedRevisions: cleaned.DeletedRevisions,
+		Metadata:         metadata,
+	}, nil
+}
+
+// checkAutoPromoteAllowed checks that the deploy targets allow the stages
+// the auto-promotion runs.
+func checkAutoPromoteAllowed(deployTargets []*sdk.DeployTarget[config.DeployTargetConfig]) error {
+	for _, dt := range deployTargets {
+		for _, stage := range []string{StageCloudRunPromote, StageCloudRunCanaryCleanup} {
+			if err := checkStageAllowed(dt, stage, stage); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkAutoPromoteWithin checks that the canary passed within the time the
+// auto-promotion allows.
+func checkAutoPromoteWithin(elapsed, within time.Duration) error {
+	if within <= 0 {
+		return fmt.Errorf("canary.autoPromote.within must be set")
+	}
+	if elapsed > within {
+		return fmt.Errorf("not promoting automatically: the canary took %s, longer than the %s allowed by canary.autoPromote.within", elapsed.Round(time.Second), within)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// stageBudgetConfig is the part of every stage config holding the time the
+// stage needs from the deployment budget.
+type stageBudgetConfig struct {
+	// RequiredTime is the minimum remaining budget to start the stage, e.g.
+	// the time a promotion needs to complete. Without enough time left, the
+	// stage fails before making any change.
+	RequiredTime config.Duration `json:"requiredTime,omitempty"`
+}
+
+// applyDeploymentBudget bounds the stage by the remaining deployment budget.
+// The returned context expires when the budget does, so every wait of the
+// stage is shortened accordingly. It fails if less than the stage's required
+// time remains.
+func applyDeploymentBudget(
+	ctx context.Context,
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	lp sdk.StageLogPersister,
+	now time.Time,
+) (context.Context, context.CancelFunc, error) {
+	budget := input.Request.TargetDeploymentSource.ApplicationConfig.Spec.Budget.Duration()
+	if budget <= 0 {
+		return ctx, func() {}, nil
+	}
+
+	var stageCfg stageBudgetConfig
+	if err := parseStageConfig(input.Request.StageConfig, &stageCfg); err != nil {
+		return ctx, func() {}, fmt.Errorf("failed to parse stage required time: %w", err)
+	}
+
+	start, err := deploymentStartTime(ctx, newMetadataStore(input.Client, metadataNamespaceDeployment), now)
+	if err != nil {
+		lp.Infof("Warning: Not enforcing the deployment budget: %v", err)
+		return ctx, func() {}, nil
+	}
+
+	remaining, err := remainingBudget(start, budget, stageCfg.RequiredTime.Duration(), now)
+	if err != nil {
+		return ctx, func() {}, err
+	}
+	lp.Infof("Deployment budget: %s remaining of %s", remaining.Round(time.Second), budget)
+
+	ctx, cancel := context.WithDeadline(ctx, start.Add(budget))
+	return ctx, cancel, nil
+}
+
+// remainingBudget returns the budget remaining at now for a deployment
+// started at start. It fails if less than required remains.
+func remainingBudget(start time.Time, budget, required time.Duration, now time.Time) (time.Duration, error) {
+	remaining := start.Add(budget).Sub(now)
+	if remaining <= 0 {
+		return 0, fmt.Errorf("insufficient time remaining: the deployment budget of %s was exhausted %s ago", budget, (-remaining).Round(time.Second))
+	}
+	if remaining < required {
+		return remaining, fmt.Errorf("insufficient time remaining: %s left of the deployment budget, the stage requires %s", remaining.Round(time.Second), required)
+	}
+	return remaining, nil
+}
+
+// deploymentStartTime returns when the first stage of the deployment started,
+// recording now if no stage has started yet.
+func deploymentStartTime(ctx context.Context, store *metadataStore, now time.Time) (time.Time, error) {
+	var start string
+	err := store.Update(ctx, metadataKeyStartedAt, func(current string, ok bool) (string, error) {
+		if ok && current != "" {
+			start = current
+		} else {
+			start = now.UTC().Format(time.RFC3339)
+		}
+		return start, nil
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := time.Parse(time.RFC3339, start)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid deployment start time %q: %w", start, err)
+	}
+	return t, nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// Weights of the change score.
+const (
+	// changeScorePerImage is scored for each container whose image changes.
+	changeScorePerImage = 10
+
+	// changeScorePerScalingStep is scored for each 10% change of the minimum
+	// or maximum instances, counted from at least one instance.
+	changeScorePerScalingStep = 1
+
+	// changeScorePerEnvVar is scored for each environment variable added,
+	// removed, or changed.
+	changeScorePerEnvVar = 1
+)
+
+// allowLargeChangeMarker in the commit message overrides the change budget.
+const allowLargeChangeMarker = "[allow-large-change]"
+
+// changeScore measures how sweeping a change to a service is.
+type changeScore struct {
+	Images  int
+	Scaling int
+	Env     int
+}
+
+// Total returns the score of the change.
+func (s changeScore) Total() int {
+	return s.Images + s.Scaling + s.Env
+}
+
+// String formats the score, e.g. "23 (images 10, scaling 3, env 10)".
+func (s changeScore) String() string {
+	return fmt.Sprintf("%d (images %d, scaling %d, env %d)", s.Total(), s.Images, s.Scaling, s.Env)
+}
+
+// computeChangeScore scores the change from the live service to the desired
+// one. Containers are matched by name, or by position if unnamed.
+func computeChangeScore(live, desired *runpb.Service) changeScore {
+	var score changeScore
+	liveTmpl, desiredTmpl := live.GetTemplate(), desired.GetTemplate()
+
+	liveContainers := make(map[string]*runpb.Container)
+	for i, c := range liveTmpl.GetContainers() {
+		liveContainers[containerKey(c, i)] = c
+	}
+	for i, c := range desiredTmpl.GetContainers() {
+		lc := liveContainers[containerKey(c, i)]
+		if lc.GetImage() != c.Image {
+			score.Images += changeScorePerImage
+		}
+		score.Env += envChurn(lc.GetEnv(), c.Env) * changeScorePerEnvVar
+	}
+
+	liveScaling, desiredScaling := liveTmpl.GetScaling(), desiredTmpl.GetScaling()
+	score.Scaling += scalingSteps(liveScaling.GetMinInstanceCount(), desiredScaling.GetMinInstanceCount()) * changeScorePerScalingStep
+	// An unset maximum keeps the server default
+	if desiredScaling.GetMaxInstanceCount() != 0 {
+		score.Scaling += scalingSteps(liveScaling.GetMaxInstanceCount(), desiredScaling.GetMaxInstanceCount()) * changeScorePerScalingStep
+	}
+	return score
+}
+
+// containerKey identifies a container of a revision template.
+func containerKey(c *runpb.Container, i int) string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return fmt.Sprintf("container-%d", i)
+}
+
+// envChurn counts the environment variables added, removed, or changed.
+func envChurn(live, desired []*runpb.EnvVar) int {
+	values := func(env []*runpb.EnvVar) map[string]string {
+		m := make(map[string]string, len(env))
+		for _, e := range env {
+			if ref := e.GetValueSource().GetSecretKeyRef(); ref != nil {
+				m[e.Name] = fmt.Sprintf("secret:%s:%s", ref.Secret, ref.Version)
+				continue
+			}
+			m[e.Name] = e.GetValue()
+		}
+		return m
+	}
+	liveValues, desiredValues := values(live), values(desired)
+
+	churn := 0
+	for name, v := range desiredValues {
+		if lv, ok := liveValues[name]; !ok || lv != v {
+			churn++
+		}
+	}
+	for name := range liveValues {
+		if _, ok := desiredValues[name]; !ok {
+			churn++
+		}
+	}
+	return churn
+}
+
+// scalingSteps returns the number of started 10% steps between the instance
+// counts, relative to the live count (at least one instance).
+func scalingSteps(live, desired int32) int {
+	delta := int(desired - live)
+	if delta < 0 {
+		delta = -delta
+	}
+	base := max(int(live), 1)
+	return (delta*10 + base - 1) / base
+}
+
+// changeBudgetOverride returns what overrides the change budget: the
+// application config or the commit message, or "" if nothing does.
+func changeBudgetOverride(ctx context.Context, spec *config.ApplicationConfig, appDir string) string {
+	if spec.AllowLargeChange {
+		return "allowLargeChange in the application config"
+	}
+	if appDir == "" {
+		return ""
+	}
+	msg, err := gitCommitMessage(ctx, appDir)
+	if err == nil && strings.Contains(msg, allowLargeChangeMarker) {
+		return allowLargeChangeMarker + " in the commit message"
+	}
+	return ""
+}
+
+// checkChangeBudget returns an error if the change score exceeds the change
+// budget of the deploy target and nothing overrides it.
+func checkChangeBudget(dt *sdk.DeployTarget[config.DeployTargetConfig], score changeScore, override string) error {
+	budget := dt.Config.ChangeBudget
+	if budget == nil || score.Total() <= budget.MaxScore || override != "" {
+		return nil
+	}
+	return fmt.Errorf("change score %s exceeds the change budget %d of deploy target %s: set allowLargeChange in the application config or add %s to the commit message to deploy it", score, budget.MaxScore, dt.Name, allowLargeChangeMarker)
+}
@@ -0,0 +1,153 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// coldStartReport is the cold start latency of the candidate revision, and of
+// the revision serving before it for comparison.
+type coldStartReport struct {
+	coldStartLatency
+	Baseline *coldStartLatency `json:"baseline,omitempty"`
+}
+
+// coldStartLatency is the startup latency of the instances of a revision.
+type coldStartLatency struct {
+	Revision string `json:"revision"`
+	P50Ms    int64  `json:"p50Ms"`
+	P95Ms    int64  `json:"p95Ms"`
+}
+
+func newColdStartLatency(l cloudrun.ColdStartLatency) coldStartLatency {
+	return coldStartLatency{
+		Revision: l.Revision,
+		P50Ms:    l.P50.Milliseconds(),
+		P95Ms:    l.P95.Milliseconds(),
+	}
+}
+
+// metadata returns the report as stage metadata.
+func (r coldStartReport) metadata() map[string]string {
+	data, _ := json.Marshal(r)
+	return map[string]string{
+		MetadataKeyColdStart: string(data),
+	}
+}
+
+// String returns e.g. "p50 850ms, p95 1.9s (my-service-00041: p50 800ms, p95 1.2s, p95 +58%)".
+func (r coldStartReport) String() string {
+	s := fmt.Sprintf("p50 %s, p95 %s", time.Duration(r.P50Ms)*time.Millisecond, time.Duration(r.P95Ms)*time.Millisecond)
+	if b := r.Baseline; b != nil {
+		s += fmt.Sprintf(" (%s: p50 %s, p95 %s", b.Revision, time.Duration(b.P50Ms)*time.Millisecond, time.Duration(b.P95Ms)*time.Millisecond)
+		if b.P95Ms > 0 {
+			s += fmt.Sprintf(", p95 %+.0f%%", float64(r.P95Ms-b.P95Ms)*100/float64(b.P95Ms))
+		}
+		s += ")"
+	}
+	return s
+}
+
+// reportColdStart reads the cold start latency of the candidate revision, and
+// of the baseline revision if any, over the same window. Failing to read them
+// doesn't fail the stage: it returns no metadata.
+func reportColdStart(
+	ctx context.Context,
+	client cloudrun.Client,
+	dt *sdk.DeployTarget[config.DeployTargetConfig],
+	project, region, serviceName, candidate, baseline string,
+	reportCfg *ColdStartReportConfig,
+	lp sdk.StageLogPersister,
+) map[string]string {
+	if delay := reportCfg.Delay.Duration(); delay > 0 {
+		lp.Infof("Waiting %s before reading the cold start latency", delay)
+		select {
+		case <-ctx.Done():
+			lp.Infof("Warning: Not reporting the cold start latency: %v", ctx.Err())
+			return nil
+		case <-time.After(delay):
+		}
+	}
+
+	now := time.Now()
+	window := reportCfg.Window.Duration()
+	if window <= 0 {
+		rev, err := client.GetRevision(ctx, project, region, serviceName, candidate)
+		if err != nil {
+			lp.Infof("Warning: Failed to get revision %s: %v", candidate, err)
+			return nil
+		}
+		window = now.Sub(rev.CreateTime.AsTime())
+	}
+
+	reader, err := cloudrun.NewMetricReader(ctx, dt.Config.CredentialsFile)
+	if err != nil {
+		lp.Infof("Warning: Failed to read the cold start latency: %v", err)
+		return nil
+	}
+	scope := cloudrun.MetricScope{Project: project, Region: region, Service: serviceName, Revision: candidate}
+	latency, ok, err := cloudrun.ReadColdStartLatency(ctx, reader, scope, window, now)
+	if err != nil {
+		lp.Infof("Warning: Failed to read the cold start latency: %v", err)
+		return nil
+	}
+	if !ok {
+		lp.Infof("No instance of revision %s started in the last %s to report the cold start latency of", candidate, window.Round(time.Second))
+		return nil
+	}
+
+	report := coldStartReport{coldStartLatency: newColdStartLatency(latency)}
+	if baseline != "" {
+		scope.Revision = baseline
+		latency, ok, err := cloudrun.ReadColdStartLatency(ctx, reader, scope, window, now)
+		switch {
+		case err != nil:
+			lp.Infof("Warning: Failed to read the cold start latency of the baseline: %v", err)
+		case ok:
+			b := newColdStartLatency(latency)
+			report.Baseline = &b
+		}
+	}
+
+	lp.Infof("Cold start latency of revision %s: %s", candidate, report)
+	return report.metadata()
+}
+
+// baselineRevision returns the revision serving the most traffic besides the
+// candidate, or "" if none.
+func baselineRevision(traffic []cloudrun.TrafficSplit, candidate string) string {
+	var (
+		baseline string
+		percent  int32
+	)
+	for _, t := range traffic {
+		if t.IsLatest || t.RevisionName == "" || cloudrun.ShortRevisionName(t.RevisionName) == candidate {
+			continue
+		}
+		if t.Percent > percent {
+			baseline, percent = cloudrun.ShortRevisionName(t.RevisionName), t.Percent
+		}
+	}
+	return baseline
+}
@@ -0,0 +1,78 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// skipCooldownMarker in the commit message skips the promotion cooldown of
+// the deploy target, e.g. for a hotfix.
+const skipCooldownMarker = "[skip-cooldown]"
+
+// cooldownRemaining returns how long the deploy target still cools down
+// after the last successful deployment, or zero if it doesn't. The
+// deployment that completed last doesn't cool down itself.
+func cooldownRemaining(last *lastSuccessfulDeployment, deploymentID string, cooldown time.Duration, now time.Time) time.Duration {
+	if last == nil || cooldown <= 0 || last.DeploymentID == deploymentID {
+		return 0
+	}
+	return max(last.CompletedAt.Add(cooldown).Sub(now), 0)
+}
+
+// checkPromotionCooldown returns an error if the promotion cooldown of the
+// deploy target hasn't elapsed since the last successful deployment
+// completed, unless the commit message skips it. Back-to-back rollouts
+// would otherwise mask the regressions of the previous one.
+func checkPromotionCooldown(
+	ctx context.Context,
+	dt *sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	lp sdk.StageLogPersister,
+	now time.Time,
+) error {
+	cooldown := dt.Config.PromotionCooldown.Duration()
+	if cooldown <= 0 {
+		return nil
+	}
+	last, ok, err := getLastSuccessfulDeployment(ctx, input.Client)
+	if err != nil {
+		lp.Infof("Warning: Promotion cooldown not checked: %v", err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	remaining := cooldownRemaining(last, input.Request.Deployment.ID, cooldown, now)
+	if remaining == 0 {
+		return nil
+	}
+
+	if appDir := input.Request.TargetDeploymentSource.ApplicationDirectory; appDir != "" {
+		if msg, err := gitCommitMessage(ctx, appDir); err == nil && strings.Contains(msg, skipCooldownMarker) {
+			lp.Infof("Promotion cooldown skipped by %s in the commit message", skipCooldownMarker)
+			return nil
+		}
+	}
+	return fmt.Errorf("deploy target %s is cooling down: the previous deployment %s completed %s ago, promotions are allowed %s after it (in %s); add %s to the commit message to promote now",
+		dt.Name, last.DeploymentID, now.Sub(last.CompletedAt).Round(time.Second), cooldown, remaining.Round(time.Second), skipCooldownMarker)
+}
@@ -0,0 +1,140 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// defaultCredentialsCheckInterval is how often deploy target credentials are
+// validated when the plugin config doesn't set it.
+const defaultCredentialsCheckInterval = 10 * time.Minute
+
+// credentialsCheckTimeout bounds a single credentials check.
+const credentialsCheckTimeout = 30 * time.Second
+
+// credentialsWatcher validates the credentials of each deploy target as soon
+// as the plugin receives it, then periodically in the background, logging
+// whether the target is ready. Results are also reported by the health endpoints.
+//
+// Piped passes the deploy targets with each call, so a target is first
+// validated on the first livestate, plan preview, or stage call using it.
+type credentialsWatcher struct {
+	// check validates the credentials of the deploy target.
+	check func(ctx context.Context, cfg *config.PluginConfig, dt *sdk.DeployTarget[config.DeployTargetConfig], project, region string) error
+
+	mu      sync.Mutex
+	watched map[string]chan struct{}
+}
+
+// newCredentialsWatcher returns a watcher checking credentials with the Admin API.
+func newCredentialsWatcher() *credentialsWatcher {
+	return &credentialsWatcher{
+		check:   checkDeployTargetCredentials,
+		watched: make(map[string]chan struct{}),
+	}
+}
+
+// watch starts validating the deploy targets not watched yet.
+func (w *credentialsWatcher) watch(cfg *config.PluginConfig, deployTargets []*sdk.DeployTarget[config.DeployTargetConfig]) {
+	interval := defaultCredentialsCheckInterval
+	if cfg != nil && cfg.CredentialsCheckInterval > 0 {
+		interval = cfg.CredentialsCheckInterval.Duration()
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, dt := range deployTargets {
+		if _, ok := w.watched[dt.Name]; ok {
+			continue
+		}
+		stop := make(chan struct{})
+		w.watched[dt.Name] = stop
+
+		project, region := targetLocation(cfg, dt)
+		if project == "" || region == "" {
+			// The project or region is set by each application
+			log.Printf("Skipping credentials check of deploy target %s: projectID and region are not set in the deploy target or plugin config", dt.Name)
+			continue
+		}
+		go w.run(cfg, dt, project, region, interval, stop)
+	}
+}
+
+// rewatch stops validating the watched deploy targets and starts again with
+// the given config, so reloaded credentials are validated right away.
+func (w *credentialsWatcher) rewatch(cfg *config.PluginConfig, deployTargets []*sdk.DeployTarget[config.DeployTargetConfig]) {
+	w.mu.Lock()
+	for name, stop := range w.watched {
+		close(stop)
+		delete(w.watched, name)
+	}
+	w.mu.Unlock()
+
+	w.watch(cfg, deployTargets)
+}
+
+// run validates the deploy target credentials until it is stopped.
+// After the first check, only changes of readiness are logged.
+func (w *credentialsWatcher) run(cfg *config.PluginConfig, dt *sdk.DeployTarget[config.DeployTargetConfig], project, region string, interval time.Duration, stop <-chan struct{}) {
+	var lastErr error
+	for first := true; ; first = false {
+		err := w.validate(cfg, dt, project, region)
+		switch {
+		case err != nil && (first || lastErr == nil):
+			log.Printf("Deploy target %s is not ready: %v", dt.Name, err)
+		case err == nil && (first || lastErr != nil):
+			log.Printf("Deploy target %s is ready", dt.Name)
+		}
+		lastErr = err
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// validate runs a single bounded credentials check.
+func (w *credentialsWatcher) validate(cfg *config.PluginConfig, dt *sdk.DeployTarget[config.DeployTargetConfig], project, region string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), credentialsCheckTimeout)
+	defer cancel()
+	return w.check(ctx, cfg, dt, project, region)
+}
+
+// checkDeployTargetCredentials lists at most one service in the project and
+// region with the deploy target's credentials. Creating a new client each
+// time picks up rotated credential files.
+func checkDeployTargetCredentials(ctx context.Context, cfg *config.PluginConfig, dt *sdk.DeployTarget[config.DeployTargetConfig], project, region string) error {
+	client, err := newCloudRunClient(ctx, cfg, dt)
+	if err != nil {
+		return fmt.Errorf("failed to create Cloud Run client: %w", err)
+	}
+	defer client.Close()
+
+	if err := client.CheckAccess(ctx, project, region); err != nil {
+		return fmt.Errorf("failed to access Cloud Run in %s/%s: %w", project, region, err)
+	}
+	return nil
+}
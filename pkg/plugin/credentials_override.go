@@ -0,0 +1,145 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// credentialsOverrideDir is the directory the service account keys read from
+// Secret Manager are written to, as the Google clients read keys from files.
+var credentialsOverrideDir = filepath.Join(os.TempDir(), "cloudrun-plugin-credentials")
+
+// applyCredentialsOverride returns the deploy targets with the credentials of
+// the application, if its config overrides them, in place of their own. The
+// application must be allowed to override credentials by the plugin config.
+func applyCredentialsOverride(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	applicationID string,
+	in config.InputConfig,
+) ([]*sdk.DeployTarget[config.DeployTargetConfig], error) {
+	if in.CredentialsFile == "" && in.CredentialsSecret == "" {
+		return deployTargets, nil
+	}
+
+	credentialsFile := in.CredentialsFile
+	if in.CredentialsSecret != "" {
+		if in.CredentialsFile != "" {
+			return nil, fmt.Errorf("input.credentialsFile and input.credentialsSecret can't be set together")
+		}
+		resolver, err := newSecretResolver(ctx, cfg, deployTargets)
+		if err != nil {
+			return nil, err
+		}
+		name, err := resolver.SecretVersionName(in.CredentialsSecret)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkCredentialsOverride(cfg, applicationID, name); err != nil {
+			return nil, err
+		}
+		key, err := resolver.AccessSecret(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if credentialsFile, err = writeCredentialsFile(credentialsOverrideDir, []byte(key)); err != nil {
+			return nil, fmt.Errorf("secret %s: %w", name, err)
+		}
+	} else if err := checkCredentialsOverride(cfg, applicationID, credentialsFile); err != nil {
+		return nil, err
+	}
+
+	overridden := make([]*sdk.DeployTarget[config.DeployTargetConfig], 0, len(deployTargets))
+	for _, dt := range deployTargets {
+		o := *dt
+		o.Config.CredentialsFile = credentialsFile
+		overridden = append(overridden, &o)
+	}
+	return overridden, nil
+}
+
+// checkCredentialsOverride checks that the application is allowed to
+// override credentials, with the credentials file or secret version.
+func checkCredentialsOverride(cfg *config.PluginConfig, applicationID, credentials string) error {
+	if cfg == nil || cfg.CredentialsOverride == nil || !slices.Contains(cfg.CredentialsOverride.AllowedApplications, applicationID) {
+		return fmt.Errorf("application %s is not allowed to override credentials (see credentialsOverride.allowedApplications in the plugin config)", applicationID)
+	}
+
+	allowed := cfg.CredentialsOverride.AllowedCredentials
+	if len(allowed) == 0 {
+		return nil
+	}
+	for _, pattern := range allowed {
+		match, err := path.Match(pattern, credentials)
+		if err != nil {
+			return fmt.Errorf("invalid credentials pattern %q: %w", pattern, err)
+		}
+		if match {
+			return nil
+		}
+	}
+	return fmt.Errorf("credentials %s are not allowed (allowed: %v)", credentials, allowed)
+}
+
+// writeCredentialsFile writes the service account key to a file readable only
+// by the plugin in dir, named after its content so the stages of a
+// deployment share its Cloud Run clients, and returns the file path.
+func writeCredentialsFile(dir string, key []byte) (string, error) {
+	if !json.Valid(key) {
+		return "", errors.New("not a service account key: not JSON")
+	}
+
+	sum := sha256.Sum256(key)
+	file := filepath.Join(dir, hex.EncodeToString(sum[:16])+".json")
+	if _, err := os.Stat(file); err == nil {
+		return file, nil
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to write credentials file: %w", err)
+	}
+	// Write to a temporary file first so the key is never read half-written
+	tmp, err := os.CreateTemp(dir, ".key-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to write credentials file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(key); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write credentials file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write credentials file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return "", fmt.Errorf("failed to write credentials file: %w", err)
+	}
+	return file, nil
+}
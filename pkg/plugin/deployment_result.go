@@ -0,0 +1,218 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// deploymentResultAPIVersion and deploymentResultKind identify the deployment
+// result document, so consumers can detect format changes.
+const (
+	deploymentResultAPIVersion = "cloudrun.pipecd.dev/v1"
+	deploymentResultKind       = "DeploymentResult"
+)
+
+// Outcomes of a deployment in the deployment result document.
+const (
+	deploymentOutcomeSucceeded  = "SUCCEEDED"
+	deploymentOutcomeFailed     = "FAILED"
+	deploymentOutcomeRolledBack = "ROLLED_BACK"
+)
+
+// deploymentResultReports are the deployment metadata keys of stage reports
+// included in the deployment result document.
+var deploymentResultReports = []string{MetadataKeyAnalysis, MetadataKeyMirror, MetadataKeyDomains, MetadataKeyColdStart}
+
+// deploymentResult is the machine-readable outcome of a deployment, for
+// external compliance and gating systems.
+type deploymentResult struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+
+	DeploymentID    string   `json:"deploymentID"`
+	ApplicationID   string   `json:"applicationID"`
+	ApplicationName string   `json:"applicationName"`
+	CommitHash      string   `json:"commitHash"`
+	DeployTargets   []string `json:"deployTargets"`
+
+	// APIEndpoints are the Admin API endpoints that served the deployment,
+	// keyed by deploy target.
+	APIEndpoints map[string]string `json:"apiEndpoints,omitempty"`
+
+	// Outcome is SUCCEEDED, FAILED, or ROLLED_BACK.
+	Outcome string `json:"outcome"`
+
+	// Stage is the stage that ended the deployment.
+	Stage deploymentResultStage `json:"stage"`
+
+	// Revision and Traffic are the state of the service after the stage.
+	Revision string           `json:"revision,omitempty"`
+	Traffic  map[string]int32 `json:"traffic,omitempty"`
+
+	// Reports are the reports of the verification stages that ran, keyed
+	// by their metadata key (analysis, mirror, domains, coldStart).
+	Reports map[string]json.RawMessage `json:"reports,omitempty"`
+
+	DryRun      bool      `json:"dryRun,omitempty"`
+	CompletedAt time.Time `json:"completedAt"`
+}
+
+// deploymentResultStage is the stage that ended the deployment.
+type deploymentResultStage struct {
+	Name    string `json:"name"`
+	Index   int    `json:"index"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// deploymentOutcome returns the outcome of the deployment after the stage,
+// or false if the deployment continues with the next stage. A failed stage
+// ends the deployment, though a rollback may follow and replace the result.
+func deploymentOutcome(spec *config.ApplicationConfig, stageName string, index int, result *StageResult) (string, bool) {
+	switch {
+	case stageName == StageCloudRunRollback || stageName == StageCloudRunJobRollback:
+		if result.Status == StageStatusSuccess {
+			return deploymentOutcomeRolledBack, true
+		}
+		return deploymentOutcomeFailed, true
+	case result.Status != StageStatusSuccess:
+		return deploymentOutcomeFailed, true
+	case isFinalStage(spec, stageName, index):
+		return deploymentOutcomeSucceeded, true
+	default:
+		return "", false
+	}
+}
+
+// buildDeploymentResult builds the result document of the deployment ended
+// by the stage, with the stage reports stored in the deployment metadata.
+func buildDeploymentResult(
+	ctx context.Context,
+	client metadataClient,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	result *StageResult,
+	outcome string,
+	now time.Time,
+) (*deploymentResult, error) {
+	doc := &deploymentResult{
+		APIVersion:      deploymentResultAPIVersion,
+		Kind:            deploymentResultKind,
+		DeploymentID:    input.Request.Deployment.ID,
+		ApplicationID:   input.Request.Deployment.ApplicationID,
+		ApplicationName: input.Request.Deployment.ApplicationName,
+		CommitHash:      input.Request.TargetDeploymentSource.CommitHash,
+		DeployTargets:   make([]string, 0, len(deployTargets)),
+		APIEndpoints:    make(map[string]string, len(deployTargets)),
+		Outcome:         outcome,
+		Stage: deploymentResultStage{
+			Name:    input.Request.StageName,
+			Index:   input.Request.StageIndex,
+			Status:  string(result.Status),
+			Message: result.Message,
+		},
+		Revision:    result.Revision,
+		Traffic:     result.Traffic,
+		DryRun:      result.Metadata[MetadataKeyDryRun] == "true",
+		CompletedAt: now.UTC(),
+	}
+	for _, dt := range deployTargets {
+		doc.DeployTargets = append(doc.DeployTargets, dt.Name)
+		doc.APIEndpoints[dt.Name] = apiEndpoint(cfg, dt)
+	}
+
+	for _, key := range deploymentResultReports {
+		report, ok, err := client.GetDeploymentPluginMetadata(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the %s report: %w", key, err)
+		}
+		if !ok || !json.Valid([]byte(report)) {
+			continue
+		}
+		if doc.Reports == nil {
+			doc.Reports = make(map[string]json.RawMessage)
+		}
+		doc.Reports[key] = json.RawMessage(report)
+	}
+	return doc, nil
+}
+
+// resultObjectName returns the Cloud Storage object name of the deployment
+// result: <prefix>/<application ID>/<deployment ID>.json.
+func resultObjectName(prefix, applicationID, deploymentID string) string {
+	return path.Join(prefix, applicationID, deploymentID+".json")
+}
+
+// reportDeploymentResult stores the result document of the deployment, once
+// the stage ended it, as deployment metadata and, if configured, in Cloud
+// Storage. Failing to report it does not fail the stage.
+func reportDeploymentResult(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	lp sdk.StageLogPersister,
+	result *StageResult,
+) {
+	spec := input.Request.TargetDeploymentSource.ApplicationConfig.Spec
+	outcome, ok := deploymentOutcome(spec, input.Request.StageName, input.Request.StageIndex, result)
+	if !ok {
+		return
+	}
+
+	doc, err := buildDeploymentResult(ctx, input.Client, cfg, deployTargets, input, result, outcome, time.Now())
+	if err != nil {
+		lp.Infof("Warning: Failed to build the deployment result: %v", err)
+		return
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		lp.Infof("Warning: Failed to encode the deployment result: %v", err)
+		return
+	}
+	if err := input.Client.PutDeploymentPluginMetadata(ctx, MetadataKeyResult, string(data)); err != nil {
+		lp.Infof("Warning: Failed to store the deployment result: %v", err)
+	}
+
+	upload := spec.ResultUpload
+	if upload == nil || upload.Bucket == "" {
+		return
+	}
+	object := resultObjectName(upload.Prefix, doc.ApplicationID, doc.DeploymentID)
+	if report, ok := cloudrun.DryRun(ctx); ok {
+		report(fmt.Sprintf("upload the deployment result to gs://%s/%s", upload.Bucket, object))
+		return
+	}
+	var credentialsFile string
+	if len(deployTargets) > 0 {
+		credentialsFile = deployTargets[0].Config.CredentialsFile
+	}
+	if err := cloudrun.WriteGCSObject(ctx, credentialsFile, upload.Bucket, object, "application/json", data); err != nil {
+		lp.Infof("Warning: Failed to upload the deployment result: %v", err)
+		return
+	}
+	lp.Infof("Uploaded the deployment result (%s) to gs://%s/%s", outcome, upload.Bucket, object)
+}
@@ -0,0 +1,155 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// failoverRegion returns the secondary region to deploy to after deploying to
+// region failed with err, or "" if the deploy target has no secondary region
+// or the error is not a regional outage.
+func failoverRegion(dt *sdk.DeployTarget[config.DeployTargetConfig], region string, err error) string {
+	failover := dt.Config.Failover
+	if failover == nil || failover.Region == "" || failover.Region == region {
+		return ""
+	}
+	if !cloudrun.IsRegionalOutage(err) {
+		return ""
+	}
+	return failover.Region
+}
+
+// maxRevisionNameAttempts is how many names are tried for a revision whose
+// configured name already exists.
+const maxRevisionNameAttempts = 5
+
+// deployService creates or updates the service and waits for it to be ready.
+//
+// If the revision name set in the manifest already exists, e.g. when a stage
+// is retried, the name is incremented ("-2", "-3", ...) and the deployment
+// retried, so the created revision is the one reported by the result.
+func deployService(
+	ctx context.Context,
+	client cloudrun.Client,
+	desired *runpb.Service,
+	project, region, serviceName string,
+	lp sdk.StageLogPersister,
+) (*runpb.Service, error) {
+	result, err := client.CreateOrUpdateService(ctx, desired)
+	if revision := desired.GetTemplate().GetRevision(); revision != "" {
+		for attempt := 2; cloudrun.IsAlreadyExists(err) && attempt <= maxRevisionNameAttempts; attempt++ {
+			next, ok := cloudrun.IncrementRevisionName(revision, attempt)
+			if !ok {
+				break
+			}
+			lp.Infof("Warning: Revision %s already exists, deploying revision %s", desired.Template.Revision, next)
+			desired.Template.Revision = next
+			result, err = client.CreateOrUpdateService(ctx, desired)
+		}
+	}
+	if err != nil {
+		lp.Errorf("Failed to deploy service: %v", err)
+		return nil, err
+	}
+
+	lp.Info("Waiting for service to be ready...")
+	if err := client.WaitForServiceReady(ctx, project, region, serviceName); err != nil {
+		lp.Errorf("Service failed to become ready: %v", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// failoverService returns the service to deploy to the secondary region, or
+// another service deployed outside of the traffic management of the stage:
+// the manifest with its name set to the region and all traffic routed to the
+// new revision, merged with the live service of the region if it exists.
+func failoverService(
+	ctx context.Context,
+	client cloudrun.Client,
+	service *runpb.Service,
+	project, region, serviceName string,
+	lp sdk.StageLogPersister,
+) (*runpb.Service, error) {
+	svc := proto.Clone(service).(*runpb.Service)
+	cloudrun.SetServiceName(svc, project, region, serviceName)
+	svc.Traffic = []*runpb.TrafficTarget{
+		{
+			Type:    runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST,
+			Percent: 100,
+		},
+	}
+	if err := cloudrun.SetLastApplied(svc); err != nil {
+		return nil, err
+	}
+
+	existing, err := client.GetService(ctx, project, region, serviceName)
+	if err != nil {
+		lp.Infof("Service does not exist in %s, creating new service", region)
+		return svc, nil
+	}
+	last, err := cloudrun.LastApplied(existing)
+	if err != nil {
+		lp.Infof("Warning: Ignoring the last applied service: %v", err)
+	}
+	return cloudrun.ThreeWayMerge(last, existing, svc), nil
+}
+
+// recordFailover stores the region CLOUDRUN_SYNC failed over to, so the
+// following stages operate on the service deployed there. Failing to store it
+// does not fail the stage.
+func recordFailover(
+	ctx context.Context,
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	region string,
+	lp sdk.StageLogPersister,
+) {
+	store := newMetadataStore(input.Client, targetNamespace(ctx, metadataNamespaceSync))
+	if err := store.PutString(ctx, metadataKeyFailoverRegion, region); err != nil {
+		lp.Infof("Warning: Failed to record failover region: %v", err)
+	}
+}
+
+// deployedRegion returns the region CLOUDRUN_SYNC deployed the service to in
+// this deployment: the failover region if it failed over, otherwise region.
+func deployedRegion(
+	ctx context.Context,
+	dt *sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	region string,
+	lp sdk.StageLogPersister,
+) string {
+	if dt.Config.Failover == nil {
+		return region
+	}
+	failover, ok, err := newMetadataStore(input.Client, targetNamespace(ctx, metadataNamespaceSync)).GetString(ctx, metadataKeyFailoverRegion)
+	if err != nil {
+		lp.Infof("Warning: Failed to read failover region: %v", err)
+		return region
+	}
+	if !ok || failover == "" {
+		return region
+	}
+	lp.Infof("CLOUDRUN_SYNC failed over to region %s, using it instead of %s", failover, region)
+	return failover
+}
@@ -0,0 +1,139 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+)
+
+// targetConnectivity is the Admin API connectivity of a deploy target, as
+// observed from the calls made by the plugin.
+type targetConnectivity struct {
+	// LastSuccess is the time of the last call that reached the Admin API
+	// with accepted credentials.
+	LastSuccess time.Time `json:"lastSuccess,omitzero"`
+
+	// LastError is the last connectivity error, e.g. rejected credentials.
+	LastError string `json:"lastError,omitempty"`
+
+	// LastErrorTime is the time of LastError.
+	LastErrorTime time.Time `json:"lastErrorTime,omitzero"`
+}
+
+// healthy reports whether the last observed call succeeded.
+func (c targetConnectivity) healthy() bool {
+	return c.LastErrorTime.IsZero() || c.LastSuccess.After(c.LastErrorTime)
+}
+
+// connectivityRegistry records the connectivity of each deploy target.
+type connectivityRegistry struct {
+	mu      sync.Mutex
+	targets map[string]targetConnectivity
+}
+
+// targetHealth is the connectivity of the deploy targets used by this process.
+var targetHealth = newConnectivityRegistry()
+
+// newConnectivityRegistry returns an empty registry.
+func newConnectivityRegistry() *connectivityRegistry {
+	return &connectivityRegistry{
+		targets: make(map[string]targetConnectivity),
+	}
+}
+
+// observer returns a call observer recording the calls for the deploy target.
+func (r *connectivityRegistry) observer(target string) cloudrun.CallObserver {
+	return func(err error) {
+		r.record(target, err, time.Now())
+	}
+}
+
+// record records the result of a call for the deploy target. Errors about
+// the request itself (e.g. NOT_FOUND) still show the target is reachable.
+func (r *connectivityRegistry) record(target string, err error, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := r.targets[target]
+	switch {
+	case cloudrun.IsConnectivityError(err):
+		c.LastError = err.Error()
+		c.LastErrorTime = now
+	case !errors.Is(err, context.Canceled):
+		c.LastSuccess = now
+	}
+	r.targets[target] = c
+}
+
+// snapshot returns a copy of the connectivity of every deploy target.
+func (r *connectivityRegistry) snapshot() map[string]targetConnectivity {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make(map[string]targetConnectivity, len(r.targets))
+	for name, c := range r.targets {
+		out[name] = c
+	}
+	return out
+}
+
+// healthResponse is the body of the readiness endpoint.
+type healthResponse struct {
+	Ready   bool                          `json:"ready"`
+	Targets map[string]targetConnectivity `json:"targets"`
+}
+
+// NewHealthHandler returns an HTTP handler serving the health of the plugin:
+//
+//   - /healthz reports the process is alive
+//   - /readyz reports the Admin API connectivity of each deploy target, and
+//     fails with 503 if the last call of any target failed to connect
+//
+// Targets appear once the plugin has made a call for them.
+func NewHealthHandler() http.Handler {
+	return newHealthHandler(targetHealth)
+}
+
+func newHealthHandler(registry *connectivityRegistry) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, _ *http.Request) {
+		resp := healthResponse{
+			Ready:   true,
+			Targets: registry.snapshot(),
+		}
+		for _, c := range resp.Targets {
+			if !c.healthy() {
+				resp.Ready = false
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if !resp.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+	return mux
+}
@@ -0,0 +1,138 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/iam/apiv1/iampb"
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// invokerBindings converts the invoker bindings of the application config.
+func invokerBindings(cfgs []config.InvokerBinding) ([]cloudrun.IAMBinding, error) {
+	bindings := make([]cloudrun.IAMBinding, 0, len(cfgs))
+	for i, c := range cfgs {
+		if len(c.Members) == 0 {
+			return nil, fmt.Errorf("invokers[%d]: members is required", i)
+		}
+		binding := cloudrun.IAMBinding{Members: c.Members}
+		if c.Condition != nil {
+			if c.Condition.Title == "" || c.Condition.Expression == "" {
+				return nil, fmt.Errorf("invokers[%d]: condition title and expression are required", i)
+			}
+			binding.Condition = &cloudrun.IAMCondition{
+				Title:       c.Condition.Title,
+				Description: c.Condition.Description,
+				Expression:  c.Condition.Expression,
+			}
+		}
+		bindings = append(bindings, binding)
+	}
+	return bindings, nil
+}
+
+// syncInvokerBindings replaces the invoker bindings of the service with the
+// declared ones, if they differ.
+func syncInvokerBindings(
+	ctx context.Context,
+	client cloudrun.Client,
+	project, region, serviceName string,
+	cfgs []config.InvokerBinding,
+	lp sdk.StageLogPersister,
+) error {
+	desired, err := invokerBindings(cfgs)
+	if err != nil {
+		return err
+	}
+
+	policy, err := client.GetIAMPolicy(ctx, project, region, serviceName)
+	if err != nil {
+		return err
+	}
+	current := cloudrun.InvokerBindings(policy)
+	diff := cloudrun.DiffInvokerBindings(current, desired)
+	if len(diff) == 0 {
+		lp.Info("Invoker bindings are up to date")
+		return nil
+	}
+
+	lp.Info("Updating invoker bindings:")
+	for _, d := range diff {
+		lp.Infof("  %s", d)
+	}
+	granted, revoked := cloudrun.DiffPublicInvokers(current, desired)
+	for _, m := range granted {
+		lp.Infof("Warning: Granting public access to the service (%s)", m)
+	}
+	for _, m := range revoked {
+		lp.Infof("Warning: Revoking public access to the service (%s)", m)
+	}
+	cloudrun.SetInvokerBindings(policy, desired)
+	_, err = client.SetIAMPolicy(ctx, project, region, serviceName, policy)
+	return err
+}
+
+// invokerChanges are the invoker binding changes shown in the plan preview.
+type invokerChanges struct {
+	// Diff lists the member grants added and removed.
+	Diff []string
+
+	// PublicGranted and PublicRevoked are the public members (allUsers,
+	// allAuthenticatedUsers) gaining and losing invoker access.
+	PublicGranted []string
+	PublicRevoked []string
+}
+
+// invokerBindingsDiff returns the invoker binding changes for plan preview.
+// policy is nil if the service doesn't exist yet.
+func invokerBindingsDiff(policy *iampb.Policy, cfgs []config.InvokerBinding) (invokerChanges, error) {
+	desired, err := invokerBindings(cfgs)
+	if err != nil {
+		return invokerChanges{}, err
+	}
+	var current []cloudrun.IAMBinding
+	if policy != nil {
+		current = cloudrun.InvokerBindings(policy)
+	}
+	changes := invokerChanges{Diff: cloudrun.DiffInvokerBindings(current, desired)}
+	changes.PublicGranted, changes.PublicRevoked = cloudrun.DiffPublicInvokers(current, desired)
+	return changes, nil
+}
+
+// writePublicAccessChanges writes the public access section of the plan
+// preview, which reviewers must not miss.
+func writePublicAccessChanges(details *strings.Builder, changes invokerChanges) {
+	details.WriteString("\n🌐 Public Access:\n")
+	for _, m := range changes.PublicGranted {
+		details.WriteString(fmt.Sprintf("  ⚠️ %s will be GRANTED roles/run.invoker: %s can invoke the service\n", m, publicAudience(m)))
+	}
+	for _, m := range changes.PublicRevoked {
+		details.WriteString(fmt.Sprintf("  ⚠️ %s will be REVOKED roles/run.invoker: unauthenticated clients relying on it will get 403\n", m))
+	}
+}
+
+// publicAudience describes who a public member grants access to.
+func publicAudience(member string) string {
+	if member == "allAuthenticatedUsers" {
+		return "anyone with a Google account"
+	}
+	return "anyone on the internet"
+}
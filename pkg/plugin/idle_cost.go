@@ -0,0 +1,93 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// confirmIdleCostMarker in the commit message confirms an idle cost increase.
+const confirmIdleCostMarker = "[confirm-idle-cost]"
+
+// idleCostIncrease is a change of the idle capacity of a service that
+// increases its estimated monthly cost.
+type idleCostIncrease struct {
+	Live    cloudrun.IdleCapacity
+	Desired cloudrun.IdleCapacity
+}
+
+// Increase returns the estimated increase of the monthly cost in USD.
+func (c idleCostIncrease) Increase() float64 {
+	return c.Desired.MonthlyCost() - c.Live.MonthlyCost()
+}
+
+// computeIdleCostIncrease returns the idle cost increase from the live
+// service, nil if there is none yet, to the desired one. It returns false if
+// the cost doesn't increase.
+func computeIdleCostIncrease(live, desired *runpb.Service) (idleCostIncrease, bool, error) {
+	var c idleCostIncrease
+	var err error
+	if live != nil {
+		if c.Live, err = cloudrun.ServiceIdleCapacity(live); err != nil {
+			return idleCostIncrease{}, false, fmt.Errorf("live service: %w", err)
+		}
+	}
+	if c.Desired, err = cloudrun.ServiceIdleCapacity(desired); err != nil {
+		return idleCostIncrease{}, false, err
+	}
+	// Ignore rounding differences
+	return c, c.Increase() >= 0.01, nil
+}
+
+// writeIdleCostIncrease writes the idle cost increase to the plan preview
+// details.
+func writeIdleCostIncrease(details *strings.Builder, c idleCostIncrease) {
+	details.WriteString("\n⚠️ Idle Cost Increase:\n")
+	details.WriteString(fmt.Sprintf("  Always-allocated capacity: %s → %s\n", c.Live, c.Desired))
+	details.WriteString(fmt.Sprintf("  Estimated idle cost: $%.2f → $%.2f per month (+$%.2f, list prices)\n", c.Live.MonthlyCost(), c.Desired.MonthlyCost(), c.Increase()))
+}
+
+// idleCostConfirmation returns what confirms an idle cost increase: the
+// application config or the commit message, or "" if nothing does.
+func idleCostConfirmation(ctx context.Context, spec *config.ApplicationConfig, appDir string) string {
+	if spec.ConfirmIdleCost {
+		return "confirmIdleCost in the application config"
+	}
+	if appDir == "" {
+		return ""
+	}
+	msg, err := gitCommitMessage(ctx, appDir)
+	if err == nil && strings.Contains(msg, confirmIdleCostMarker) {
+		return confirmIdleCostMarker + " in the commit message"
+	}
+	return ""
+}
+
+// checkIdleCost returns an error if the idle cost increases on a production
+// deploy target and nothing confirms it.
+func checkIdleCost(dt *sdk.DeployTarget[config.DeployTargetConfig], c idleCostIncrease, confirmation string) error {
+	if !dt.Config.Production || confirmation != "" {
+		return nil
+	}
+	return fmt.Errorf("the estimated idle cost increases by $%.2f per month (%s) on production deploy target %s: set confirmIdleCost in the application config or add %s to the commit message to deploy it", c.Increase(), c.Desired, dt.Name, confirmIdleCostMarker)
+}
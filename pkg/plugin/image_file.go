@@ -0,0 +1,61 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// resolveInputImage sets the image of the application input from its image
+// file, a build artifact written by CI into the application directory, e.g.
+// ".artifact" containing "gcr.io/my-project/app@sha256:...". The image is the
+// first line that is neither blank nor a "#" comment.
+func resolveInputImage(appDir string, input *config.InputConfig) error {
+	if input.ImageFile == "" {
+		return nil
+	}
+	if input.Image != "" {
+		return fmt.Errorf("input.image and input.imageFile can't be set together")
+	}
+
+	// The image file must stay inside the application directory
+	if !filepath.IsLocal(input.ImageFile) {
+		return fmt.Errorf("input.imageFile %q must be a path inside the application directory", input.ImageFile)
+	}
+	data, err := os.ReadFile(filepath.Join(appDir, input.ImageFile))
+	if err != nil {
+		return fmt.Errorf("failed to read image file: %w", err)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, err := cloudrun.ParseImageReference(line); err != nil || strings.ContainsAny(line, " \t") {
+			return fmt.Errorf("image file %s contains an invalid image %q", input.ImageFile, line)
+		}
+		// Clear the image file so the input is only resolved once
+		input.Image, input.ImageFile = line, ""
+		return nil
+	}
+	return fmt.Errorf("image file %s contains no image", input.ImageFile)
+}
@@ -0,0 +1,188 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// applicationObjectKeyLastSuccessfulDeployment is the application shared
+// object holding the last fully successful deployment.
+const applicationObjectKeyLastSuccessfulDeployment = "lastSuccessfulDeployment"
+
+// applicationObjectClient is the part of the SDK client storing objects shared
+// across the deployments of an application.
+type applicationObjectClient interface {
+	GetApplicationSharedObject(ctx context.Context, key string) ([]byte, bool, error)
+	PutApplicationSharedObject(ctx context.Context, key string, object []byte) error
+}
+
+// lastSuccessfulDeployment points to the last deployment of the application
+// whose pipeline completed with one revision serving all traffic. Unlike the
+// stable revision recorded by CLOUDRUN_SYNC, it survives across deployments,
+// so it is known even if the previous deployment failed halfway.
+type lastSuccessfulDeployment struct {
+	DeploymentID string    `json:"deploymentID"`
+	CommitHash   string    `json:"commitHash,omitempty"`
+	Revision     string    `json:"revision"`
+	Manifest     string    `json:"manifest,omitempty"`
+	CompletedAt  time.Time `json:"completedAt"`
+}
+
+// getLastSuccessfulDeployment returns the last successful deployment of the
+// application and whether one was recorded.
+func getLastSuccessfulDeployment(ctx context.Context, client applicationObjectClient) (*lastSuccessfulDeployment, bool, error) {
+	data, ok, err := client.GetApplicationSharedObject(ctx, applicationObjectKeyLastSuccessfulDeployment)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get the last successful deployment: %w", err)
+	}
+	if !ok {
+		return nil, false, nil
+	}
+	var last lastSuccessfulDeployment
+	if err := json.Unmarshal(data, &last); err != nil {
+		return nil, false, fmt.Errorf("invalid last successful deployment: %w", err)
+	}
+	return &last, true, nil
+}
+
+// putLastSuccessfulDeployment records the last successful deployment of the
+// application.
+func putLastSuccessfulDeployment(ctx context.Context, client applicationObjectClient, last *lastSuccessfulDeployment) error {
+	data, err := json.Marshal(last)
+	if err != nil {
+		return fmt.Errorf("failed to encode the last successful deployment: %w", err)
+	}
+	if err := client.PutApplicationSharedObject(ctx, applicationObjectKeyLastSuccessfulDeployment, data); err != nil {
+		return fmt.Errorf("failed to store the last successful deployment: %w", err)
+	}
+	return nil
+}
+
+// isFinalStage reports whether the stage completes the deployment: the only
+// stage of a quick sync, or the last stage of the pipeline. Rollback stages
+// never do.
+func isFinalStage(spec *config.ApplicationConfig, stageName string, index int) bool {
+	switch stageName {
+	case StageCloudRunRollback, StageCloudRunJobRollback:
+		return false
+	}
+	if spec.PipelineSync == nil {
+		return stageName == StageCloudRunSync
+	}
+	return index == len(spec.PipelineSync.Stages)-1
+}
+
+// servingRevision returns the revision serving all traffic after the stage,
+// or "" if traffic is split or unknown.
+func servingRevision(result *StageResult) string {
+	if len(result.Traffic) != 1 {
+		return ""
+	}
+	for revision, percent := range result.Traffic {
+		if percent != 100 {
+			return ""
+		}
+		if revision == trafficKeyLatest {
+			return result.Revision
+		}
+		return revision
+	}
+	return ""
+}
+
+// recordLastSuccessfulDeployment records the deployment as the last successful
+// one once its final stage succeeded with one revision serving all traffic,
+// along with the rendered service manifest. Failing to record it does not
+// fail the stage.
+func recordLastSuccessfulDeployment(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	lp sdk.StageLogPersister,
+	result *StageResult,
+) {
+	spec := input.Request.TargetDeploymentSource.ApplicationConfig.Spec
+	if result.Status != StageStatusSuccess || !isFinalStage(spec, input.Request.StageName, input.Request.StageIndex) {
+		return
+	}
+	// Preview services are not the application's service
+	if result.Metadata[MetadataKeyPreviewService] != "" {
+		return
+	}
+	revision := servingRevision(result)
+	if revision == "" {
+		return
+	}
+
+	last := &lastSuccessfulDeployment{
+		DeploymentID: input.Request.Deployment.ID,
+		CommitHash:   input.Request.TargetDeploymentSource.CommitHash,
+		Revision:     revision,
+		CompletedAt:  time.Now().UTC(),
+	}
+	manifest, err := renderedServiceManifest(ctx, cfg, deployTargets, input)
+	if err != nil {
+		lp.Infof("Warning: Failed to snapshot the service manifest: %v", err)
+	} else {
+		last.Manifest = string(manifest)
+	}
+
+	recordTrafficHistory(ctx, input.Client, []string{revision}, lp)
+	if err := putLastSuccessfulDeployment(ctx, input.Client, last); err != nil {
+		lp.Infof("Warning: %v", err)
+		return
+	}
+	lp.Infof("Recorded revision %s as the last successful deployment", revision)
+}
+
+// renderedServiceManifest renders the service manifest of the target
+// deployment source with its renderer and deployment variables.
+func renderedServiceManifest(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+) ([]byte, error) {
+	appDir := input.Request.TargetDeploymentSource.ApplicationDirectory
+	_, data, err := renderServiceManifest(ctx, cfg, input.Request.TargetDeploymentSource.ApplicationConfig.Spec, appDir, stageVariables(ctx, deployTargets, input))
+	return data, err
+}
+
+// lastSuccessfulRevision returns the revision of the last successful
+// deployment of the application, or an empty string if none was recorded.
+func lastSuccessfulRevision(
+	ctx context.Context,
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	lp sdk.StageLogPersister,
+) string {
+	last, ok, err := getLastSuccessfulDeployment(ctx, input.Client)
+	if err != nil {
+		lp.Infof("Warning: %v", err)
+		return ""
+	}
+	if !ok {
+		return ""
+	}
+	return last.Revision
+}
@@ -0,0 +1,269 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// Ensure cloudrunPlugin implements the LivestatePlugin interface.
+var _ sdk.LivestatePlugin[config.PluginConfig, config.DeployTargetConfig, config.ApplicationConfig] = (*cloudrunPlugin)(nil)
+
+// Resource types reported in the live state.
+const (
+	ResourceTypeService   = "Service"
+	ResourceTypeRevision  = "Revision"
+	ResourceTypeJob       = "Job"
+	ResourceTypeExecution = "Execution"
+)
+
+// ResourceMetadataKeyYAML is the resource metadata key holding the normalized
+// YAML of the live resource. The service YAML can be copied back into Git
+// after emergency manual changes.
+const ResourceMetadataKeyYAML = "yaml"
+
+// GetLivestate returns the live state of the application's Cloud Run service
+// and its revisions, or of its job and recent executions, and whether the
+// service or job is in sync with Git.
+func (p *cloudrunPlugin) GetLivestate(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.GetLivestateInput[config.ApplicationConfig],
+) (*sdk.GetLivestateResponse, error) {
+	cfg, deployTargets = p.config.resolve(cfg, deployTargets)
+
+	if len(deployTargets) == 0 {
+		return nil, fmt.Errorf("no deploy targets configured")
+	}
+	appConfig := input.Request.DeploymentSource.ApplicationConfig.Spec
+	deployTargets, err := applyCredentialsOverride(ctx, cfg, deployTargets, input.Request.ApplicationID, appConfig.Input)
+	if err != nil {
+		return nil, err
+	}
+	p.credentials.watch(cfg, deployTargets)
+	dt := deployTargets[0]
+
+	// Resolve project and region
+	project := dt.Config.ProjectID
+	if appConfig.Input.ProjectID != "" {
+		project = appConfig.Input.ProjectID
+	}
+	if project == "" {
+		project = cfg.ProjectID
+	}
+	region := dt.Config.Region
+	if appConfig.Input.Region != "" {
+		region = appConfig.Input.Region
+	}
+	if region == "" {
+		region = cfg.Region
+	}
+
+	if appConfig.IsJob() {
+		return getJobLivestate(ctx, cfg, dt, input, project, region)
+	}
+
+	// Load desired service manifest from Git
+	appDir := input.Request.DeploymentSource.ApplicationDirectory
+	vars, loadErr := newDeploymentVariables(ctx, "", input.Request.ApplicationID, input.Request.ApplicationName, dt.Name, input.Request.DeploymentSource).withValues(dt, appDir)
+	var desiredService *runpb.Service
+	if loadErr == nil {
+		desiredService, loadErr = loadSourceService(ctx, cfg, input.Request.DeploymentSource, vars)
+	}
+
+	// Get service name
+	serviceName := appConfig.Input.ServiceName
+	if serviceName == "" && loadErr == nil {
+		serviceName = cloudrun.GetServiceName(desiredService.Name)
+		if serviceName == "" {
+			serviceName = desiredService.Name
+		}
+	}
+	if serviceName == "" {
+		serviceName = input.Request.ApplicationID
+	}
+
+	// Merge deploy target defaults so they don't show up as drift
+	if loadErr == nil {
+		if _, err := applyDeployTargetDefaults(desiredService, dt, serviceName, project, region); err != nil {
+			loadErr = err
+		}
+	}
+
+	client, err := newCloudRunClient(ctx, cfg, dt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud Run client: %w", err)
+	}
+	defer client.Close()
+
+	svc, err := client.GetService(ctx, project, region, serviceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service: %w", err)
+	}
+	revisions, err := client.ListRevisions(ctx, project, region, serviceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revisions: %w", err)
+	}
+
+	resources, err := buildServiceResourceStates(svc, revisions, dt.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sdk.GetLivestateResponse{
+		LiveState: sdk.ApplicationLiveState{
+			Resources: resources,
+		},
+		SyncState: calculateSyncState(svc, desiredService, loadErr),
+	}, nil
+}
+
+// buildServiceResourceStates builds the resource states of a service and its
+// revisions. The application health is derived from the resources, so only a
+// failed revision still serving traffic is reported unhealthy.
+func buildServiceResourceStates(svc *runpb.Service, revisions []*runpb.Revision, deployTarget string) ([]sdk.ResourceState, error) {
+	serviceState, err := buildServiceResourceState(svc, deployTarget)
+	if err != nil {
+		return nil, err
+	}
+	serving := make(map[string]bool)
+	for _, info := range cloudrun.FilterRevisions(cloudrun.RevisionInfos(revisions, svc), cloudrun.HasTraffic()) {
+		serving[info.Name] = true
+	}
+	resources := []sdk.ResourceState{serviceState}
+	for _, rev := range revisions {
+		state, err := buildRevisionResourceState(rev, serviceState.ID, deployTarget)
+		if err != nil {
+			return nil, err
+		}
+		if state.HealthStatus == sdk.ResourceHealthStateUnhealthy && !serving[cloudrun.ShortRevisionName(rev.Name)] {
+			state.HealthStatus = sdk.ResourceHealthStateHealthy
+			state.HealthDescription = "Not serving traffic: " + state.HealthDescription
+		}
+		resources = append(resources, state)
+	}
+	return resources, nil
+}
+
+// buildServiceResourceState builds the resource state of a service.
+func buildServiceResourceState(svc *runpb.Service, deployTarget string) (sdk.ResourceState, error) {
+	data, err := cloudrun.MarshalYAML(cloudrun.NormalizeService(svc))
+	if err != nil {
+		return sdk.ResourceState{}, err
+	}
+
+	health, description := conditionHealth(svc.TerminalCondition)
+	state := sdk.ResourceState{
+		ID:           svc.Name,
+		Name:         cloudrun.GetServiceName(svc.Name),
+		ResourceType: ResourceTypeService,
+		ResourceMetadata: map[string]string{
+			"uri":                   svc.Uri,
+			"latestReadyRevision":   cloudrun.ShortRevisionName(svc.LatestReadyRevision),
+			ResourceMetadataKeyYAML: string(data),
+		},
+		HealthStatus:      health,
+		HealthDescription: description,
+		DeployTarget:      deployTarget,
+	}
+	if svc.CreateTime != nil {
+		state.CreatedAt = svc.CreateTime.AsTime()
+	}
+	return state, nil
+}
+
+// buildRevisionResourceState builds the resource state of a revision.
+func buildRevisionResourceState(rev *runpb.Revision, serviceID, deployTarget string) (sdk.ResourceState, error) {
+	data, err := cloudrun.MarshalYAML(cloudrun.NormalizeRevision(rev))
+	if err != nil {
+		return sdk.ResourceState{}, err
+	}
+
+	// The Ready condition of a revision is in its conditions list
+	var ready *runpb.Condition
+	for _, c := range rev.Conditions {
+		if c.Type == "Ready" {
+			ready = c
+			break
+		}
+	}
+	health, description := conditionHealth(ready)
+
+	state := sdk.ResourceState{
+		ID:           rev.Name,
+		ParentIDs:    []string{serviceID},
+		Name:         cloudrun.ShortRevisionName(rev.Name),
+		ResourceType: ResourceTypeRevision,
+		ResourceMetadata: map[string]string{
+			ResourceMetadataKeyYAML: string(data),
+		},
+		HealthStatus:      health,
+		HealthDescription: description,
+		DeployTarget:      deployTarget,
+	}
+	if rev.CreateTime != nil {
+		state.CreatedAt = rev.CreateTime.AsTime()
+	}
+	return state, nil
+}
+
+// conditionHealth converts a Ready condition to a resource health status.
+func conditionHealth(c *runpb.Condition) (sdk.ResourceHealthStatus, string) {
+	if c == nil {
+		return sdk.ResourceHealthStateUnknown, ""
+	}
+	switch c.State {
+	case runpb.Condition_CONDITION_SUCCEEDED:
+		return sdk.ResourceHealthStateHealthy, ""
+	case runpb.Condition_CONDITION_FAILED:
+		return sdk.ResourceHealthStateUnhealthy, c.Message
+	default:
+		return sdk.ResourceHealthStateUnknown, c.Message
+	}
+}
+
+// calculateSyncState compares the live service with the manifest in Git.
+func calculateSyncState(live, desired *runpb.Service, loadErr error) sdk.ApplicationSyncState {
+	if loadErr != nil {
+		return sdk.ApplicationSyncState{
+			Status:      sdk.ApplicationSyncStateInvalidConfig,
+			ShortReason: "Failed to load the service manifest",
+			Reason:      loadErr.Error(),
+		}
+	}
+
+	var details strings.Builder
+	changes, _ := writeServiceDiff(&details, live, desired)
+	if len(changes) == 0 {
+		return sdk.ApplicationSyncState{
+			Status: sdk.ApplicationSyncStateSynced,
+		}
+	}
+
+	return sdk.ApplicationSyncState{
+		Status:      sdk.ApplicationSyncStateOutOfSync,
+		ShortReason: fmt.Sprintf("Changed: %s", strings.Join(changes, ", ")),
+		Reason:      details.String(),
+	}
+}
@@ -0,0 +1,317 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// livestateExecutionLimit is the number of recent executions reported in the
+// live state of a job.
+const livestateExecutionLimit = 10
+
+// getJobLivestate returns the live state of the application's Cloud Run job
+// and its recent executions, and whether the job is in sync with Git.
+func getJobLivestate(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	dt *sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.GetLivestateInput[config.ApplicationConfig],
+	project, region string,
+) (*sdk.GetLivestateResponse, error) {
+	src := input.Request.DeploymentSource
+
+	// Load desired job manifest from Git
+	vars, loadErr := newDeploymentVariables(ctx, "", input.Request.ApplicationID, input.Request.ApplicationName, dt.Name, src).withValues(dt, src.ApplicationDirectory)
+	var desiredJob *runpb.Job
+	if loadErr == nil {
+		desiredJob, loadErr = loadSourceJob(ctx, src, vars)
+	}
+	jobName := jobNameOf(src.ApplicationConfig.Spec, desiredJob, input.Request.ApplicationID)
+
+	client, err := newCloudRunClient(ctx, cfg, dt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud Run client: %w", err)
+	}
+	defer client.Close()
+
+	job, err := client.GetJob(ctx, project, region, jobName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	executions, err := client.ListExecutions(ctx, project, region, jobName, livestateExecutionLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	jobState, err := buildJobResourceState(job, executions, dt.Name)
+	if err != nil {
+		return nil, err
+	}
+	resources := []sdk.ResourceState{jobState}
+	now := time.Now()
+	for _, execution := range executions {
+		resources = append(resources, buildExecutionResourceState(execution, jobState.ID, dt.Name, now))
+	}
+
+	return &sdk.GetLivestateResponse{
+		LiveState: sdk.ApplicationLiveState{
+			Resources: resources,
+		},
+		SyncState: calculateJobSyncState(job, desiredJob, loadErr),
+	}, nil
+}
+
+// loadSourceJob renders the job manifest of a deployment source with its
+// renderer and the deployment variables, and applies the image override (or
+// image file) of its application config.
+func loadSourceJob(ctx context.Context, src sdk.DeploymentSource[config.ApplicationConfig], vars deploymentVariables) (*runpb.Job, error) {
+	appConfig := src.ApplicationConfig.Spec
+
+	vars, err := vars.withParams(appConfig.Params)
+	if err != nil {
+		return nil, err
+	}
+	_, data, err := renderJobManifest(ctx, appConfig, src.ApplicationDirectory, vars)
+	if err != nil {
+		return nil, err
+	}
+	job, err := cloudrun.ParseJobManifest(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := vars.interpolateInput(&appConfig.Input); err != nil {
+		return nil, err
+	}
+	if err := resolveInputImage(src.ApplicationDirectory, &appConfig.Input); err != nil {
+		return nil, err
+	}
+	cloudrun.ApplyJobImageOverride(job, appConfig.Input.Image)
+	return job, nil
+}
+
+// buildJobResourceState builds the resource state of a job. A job whose
+// latest finished execution failed is unhealthy, so failing scheduled runs
+// are visible.
+func buildJobResourceState(job *runpb.Job, executions []*runpb.Execution, deployTarget string) (sdk.ResourceState, error) {
+	data, err := cloudrun.MarshalYAML(cloudrun.NormalizeJob(job))
+	if err != nil {
+		return sdk.ResourceState{}, err
+	}
+
+	health, description := conditionHealth(job.TerminalCondition)
+	metadata := map[string]string{
+		"executionCount":        strconv.Itoa(int(job.ExecutionCount)),
+		ResourceMetadataKeyYAML: string(data),
+	}
+	for _, e := range executions {
+		if !cloudrun.ExecutionDone(e) {
+			continue
+		}
+		name := filepath.Base(e.Name)
+		metadata["latestFinishedExecution"] = name
+		if health == sdk.ResourceHealthStateHealthy && !cloudrun.ExecutionSucceeded(e) {
+			health = sdk.ResourceHealthStateUnhealthy
+			description = fmt.Sprintf("Latest execution %s failed", name)
+		}
+		break
+	}
+
+	state := sdk.ResourceState{
+		ID:                job.Name,
+		Name:              filepath.Base(job.Name),
+		ResourceType:      ResourceTypeJob,
+		ResourceMetadata:  metadata,
+		HealthStatus:      health,
+		HealthDescription: description,
+		DeployTarget:      deployTarget,
+	}
+	if job.CreateTime != nil {
+		state.CreatedAt = job.CreateTime.AsTime()
+	}
+	return state, nil
+}
+
+// buildExecutionResourceState builds the resource state of an execution,
+// with its status, task counts, and duration.
+func buildExecutionResourceState(execution *runpb.Execution, jobID, deployTarget string, now time.Time) sdk.ResourceState {
+	duration := cloudrun.ExecutionDuration(execution, now).Round(time.Second)
+	tasks := fmt.Sprintf("%d/%d succeeded", execution.SucceededCount, execution.TaskCount)
+
+	var health sdk.ResourceHealthStatus
+	var description string
+	switch {
+	case !cloudrun.ExecutionDone(execution):
+		health = sdk.ResourceHealthStateUnknown
+		description = fmt.Sprintf("Running for %s: %d task(s) running, %s", duration, execution.RunningCount, tasks)
+	case cloudrun.ExecutionSucceeded(execution):
+		health = sdk.ResourceHealthStateHealthy
+		description = fmt.Sprintf("Succeeded in %s", duration)
+	default:
+		health = sdk.ResourceHealthStateUnhealthy
+		description = fmt.Sprintf("Failed after %s: %d task(s) failed, %d cancelled", duration, execution.FailedCount, execution.CancelledCount)
+	}
+
+	metadata := map[string]string{
+		"tasks":    tasks,
+		"duration": duration.String(),
+	}
+	if execution.StartTime != nil {
+		metadata["startTime"] = execution.StartTime.AsTime().Format(time.RFC3339)
+	}
+	if execution.CompletionTime != nil {
+		metadata["completionTime"] = execution.CompletionTime.AsTime().Format(time.RFC3339)
+	}
+	if execution.LogUri != "" {
+		metadata["logUri"] = execution.LogUri
+	}
+
+	state := sdk.ResourceState{
+		ID:                execution.Name,
+		ParentIDs:         []string{jobID},
+		Name:              filepath.Base(execution.Name),
+		ResourceType:      ResourceTypeExecution,
+		ResourceMetadata:  metadata,
+		HealthStatus:      health,
+		HealthDescription: description,
+		DeployTarget:      deployTarget,
+	}
+	if execution.CreateTime != nil {
+		state.CreatedAt = execution.CreateTime.AsTime()
+	}
+	return state
+}
+
+// calculateJobSyncState compares the live job with the manifest in Git.
+func calculateJobSyncState(live, desired *runpb.Job, loadErr error) sdk.ApplicationSyncState {
+	if loadErr != nil {
+		return sdk.ApplicationSyncState{
+			Status:      sdk.ApplicationSyncStateInvalidConfig,
+			ShortReason: "Failed to load the job manifest",
+			Reason:      loadErr.Error(),
+		}
+	}
+
+	current, want := jobSettings(live), jobSettings(desired)
+	// Settings the manifest leaves to server defaults aren't drift, but
+	// removed environment variables are
+	for k := range current {
+		if _, ok := want[k]; !ok && !isEnvSetting(k) {
+			delete(current, k)
+		}
+	}
+	var changes []string
+	for _, k := range slices.Sorted(maps.Keys(settingKeys(current, want))) {
+		cv, inCurrent := current[k]
+		wv, inWant := want[k]
+		if inCurrent != inWant || cv != wv {
+			changes = append(changes, k)
+		}
+	}
+	if len(changes) == 0 {
+		return sdk.ApplicationSyncState{
+			Status: sdk.ApplicationSyncStateSynced,
+		}
+	}
+
+	var details strings.Builder
+	details.WriteString("📦 Job Template:\n")
+	writeSettingsDiff(&details, current, want)
+	return sdk.ApplicationSyncState{
+		Status:      sdk.ApplicationSyncStateOutOfSync,
+		ShortReason: fmt.Sprintf("Changed: %s", strings.Join(changes, ", ")),
+		Reason:      details.String(),
+	}
+}
+
+// settingKeys returns the keys of both settings.
+func settingKeys(a, b map[string]string) map[string]struct{} {
+	keys := make(map[string]struct{}, len(a)+len(b))
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+	return keys
+}
+
+// isEnvSetting reports whether a job setting is an environment variable.
+func isEnvSetting(k string) bool {
+	return strings.HasPrefix(k, "env.") || strings.Contains(k, "/env.")
+}
+
+// jobSettings flattens the execution template of a job: the task count,
+// parallelism, retries, timeout, service account, and for each container
+// its image, command, arguments, resource limits, and environment variables.
+func jobSettings(job *runpb.Job) map[string]string {
+	settings := make(map[string]string)
+	tmpl := job.GetTemplate()
+	if tmpl.GetTaskCount() != 0 {
+		settings["taskCount"] = strconv.Itoa(int(tmpl.GetTaskCount()))
+	}
+	if tmpl.GetParallelism() != 0 {
+		settings["parallelism"] = strconv.Itoa(int(tmpl.GetParallelism()))
+	}
+	task := tmpl.GetTemplate()
+	if task.GetRetries() != nil {
+		settings["maxRetries"] = strconv.Itoa(int(task.GetMaxRetries()))
+	}
+	if task.GetTimeout() != nil {
+		settings["timeout"] = task.GetTimeout().AsDuration().String()
+	}
+	if task.GetServiceAccount() != "" {
+		settings["serviceAccount"] = task.GetServiceAccount()
+	}
+
+	containers := task.GetContainers()
+	for i, c := range containers {
+		prefix := ""
+		if len(containers) > 1 {
+			prefix = containerKey(c, i) + "/"
+		}
+		settings[prefix+"image"] = c.Image
+		if len(c.Command) > 0 {
+			settings[prefix+"command"] = strings.Join(c.Command, " ")
+		}
+		if len(c.Args) > 0 {
+			settings[prefix+"args"] = strings.Join(c.Args, " ")
+		}
+		for k, v := range c.GetResources().GetLimits() {
+			settings[prefix+"limits."+k] = v
+		}
+		for _, e := range c.Env {
+			value := e.GetValue()
+			if ref := e.GetValueSource().GetSecretKeyRef(); ref != nil {
+				value = fmt.Sprintf("secret:%s:%s", ref.Secret, ref.Version)
+			}
+			settings[prefix+"env."+e.Name] = value
+		}
+	}
+	return settings
+}
@@ -0,0 +1,134 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// defaultServiceManifestCandidates are the paths searched for the service
+// manifest when neither the application nor the plugin config sets them.
+var defaultServiceManifestCandidates = []string{
+	"service.yaml",
+	"service.yml",
+	"cloudrun.yaml",
+	"cloudrun.yml",
+	"cloudrun/service.yaml",
+	"cloudrun/service.yml",
+}
+
+// defaultJobManifestCandidates are the paths searched for the job manifest of
+// CloudRunJob applications when the application config doesn't set it.
+var defaultJobManifestCandidates = []string{
+	"job.yaml",
+	"job.yml",
+	"cloudrun/job.yaml",
+}
+
+// resolveServiceManifestPaths returns the paths of the service manifests
+// relative to appDir. Configured paths are returned as is, and glob patterns
+// are expanded to the files they match, in lexical order; otherwise the first
+// existing candidate of the plugin config is used.
+func resolveServiceManifestPaths(cfg *config.PluginConfig, appDir string, configured []string) ([]string, error) {
+	if len(configured) > 0 {
+		return expandManifestPaths(appDir, configured)
+	}
+
+	candidates := defaultServiceManifestCandidates
+	if cfg != nil && len(cfg.ServiceManifestCandidates) > 0 {
+		candidates = cfg.ServiceManifestCandidates
+	}
+	if path, ok := findManifest(appDir, candidates); ok {
+		return []string{path}, nil
+	}
+	return nil, fmt.Errorf("no service manifest found in %s (tried %s); set serviceManifestPath in the application config", appDir, strings.Join(candidates, ", "))
+}
+
+// expandManifestPaths expands the glob patterns among paths to the files
+// they match in appDir. A pattern matching no file is an error. Paths listed
+// more than once are only returned the first time, and matches that are the
+// overlay of another match (e.g. "service.staging.yaml" of "service.yaml")
+// are left out.
+func expandManifestPaths(appDir string, paths []string) ([]string, error) {
+	var expanded []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		path = filepath.ToSlash(filepath.Clean(path))
+		if !seen[path] {
+			seen[path] = true
+			expanded = append(expanded, path)
+		}
+	}
+	for _, pattern := range paths {
+		if !strings.ContainsAny(pattern, "*?[") {
+			add(pattern)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(appDir, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("invalid serviceManifestPath pattern %q: %w", pattern, err)
+		}
+		var rels []string
+		for _, m := range matches {
+			if info, err := os.Stat(m); err != nil || info.IsDir() {
+				continue
+			}
+			rel, err := filepath.Rel(appDir, m)
+			if err != nil {
+				return nil, err
+			}
+			rels = append(rels, filepath.ToSlash(rel))
+		}
+		// Overlays of the matched manifests are merged onto them, not deployed
+		n := 0
+		for _, rel := range rels {
+			if !isOverlay(rel, rels) {
+				add(rel)
+				n++
+			}
+		}
+		if n == 0 {
+			return nil, fmt.Errorf("serviceManifestPath pattern %q matches no file in %s", pattern, appDir)
+		}
+	}
+	return expanded, nil
+}
+
+// resolveJobManifestPath returns the path of the job manifest relative to appDir.
+func resolveJobManifestPath(appDir, configured string) (string, error) {
+	if configured != "" {
+		return configured, nil
+	}
+	if path, ok := findManifest(appDir, defaultJobManifestCandidates); ok {
+		return path, nil
+	}
+	return "", fmt.Errorf("no job manifest found in %s (tried %s); set jobManifestPath in the application config", appDir, strings.Join(defaultJobManifestCandidates, ", "))
+}
+
+// findManifest returns the first candidate that is an existing file in appDir.
+func findManifest(appDir string, candidates []string) (string, bool) {
+	for _, c := range candidates {
+		info, err := os.Stat(filepath.Join(appDir, c))
+		if err == nil && !info.IsDir() {
+			return c, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,233 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Metadata namespaces used to share state between stages of a deployment.
+const (
+	metadataNamespaceSync       = "sync"
+	metadataNamespacePromote    = "promote"
+	metadataNamespaceRollback   = "rollback"
+	metadataNamespaceJob        = "job"
+	metadataNamespaceDeployment = "deployment"
+)
+
+// Keys shared between stages through the metadata store.
+const (
+	// metadataKeyStableRevision is the revision serving traffic before
+	// CLOUDRUN_SYNC deployed the new one. CLOUDRUN_ROLLBACK returns to it.
+	metadataKeyStableRevision = "stableRevision"
+
+	// metadataKeyTrafficSnapshot is the traffic split before CLOUDRUN_SYNC.
+	metadataKeyTrafficSnapshot = "trafficSnapshot"
+
+	// metadataKeyFailoverRegion is the secondary region CLOUDRUN_SYNC deployed
+	// to after the primary region failed.
+	metadataKeyFailoverRegion = "failoverRegion"
+
+	// metadataKeyRolledBack is set once CLOUDRUN_ROLLBACK has run.
+	metadataKeyRolledBack = "rolledBack"
+
+	// metadataKeyJobName is the job deployed by CLOUDRUN_JOB_SYNC.
+	metadataKeyJobName = "name"
+
+	// metadataKeyPreviousJobTemplate is the job template (protojson) before
+	// CLOUDRUN_JOB_SYNC. CLOUDRUN_JOB_ROLLBACK restores it.
+	metadataKeyPreviousJobTemplate = "previousTemplate"
+
+	// metadataKeyStartedAt is when the first stage of the deployment started
+	// (RFC 3339). The deployment budget and canary auto-promotion are
+	// counted from it.
+	metadataKeyStartedAt = "startedAt"
+
+	// metadataKeyImages is the container images CLOUDRUN_SYNC deployed to a
+	// deploy target. CLOUDRUN_SYNC with promoteFrom deploys them to another.
+	metadataKeyImages = "images"
+
+	// metadataKeySyncedTargets is the deploy targets other than the first
+	// CLOUDRUN_SYNC deployed to. CLOUDRUN_ROLLBACK rolls them back too.
+	metadataKeySyncedTargets = "syncedTargets"
+
+	// metadataKeyAdditionalServices is the services CLOUDRUN_SYNC deployed
+	// besides the application's service, with the revision serving each of
+	// them before ("" for new services). CLOUDRUN_ROLLBACK and
+	// CLOUDRUN_CANARY_CLEANUP handle them too.
+	metadataKeyAdditionalServices = "additionalServices"
+)
+
+// metadataClient is the part of the SDK client storing deployment metadata.
+type metadataClient interface {
+	GetDeploymentPluginMetadata(ctx context.Context, key string) (string, bool, error)
+	PutDeploymentPluginMetadata(ctx context.Context, key, value string) error
+}
+
+// metadataStoreRetries is the number of attempts for each metadata operation.
+const metadataStoreRetries = 3
+
+// metadataStoreBackoff is the delay before the first retry. It doubles on each retry.
+const metadataStoreBackoff = 200 * time.Millisecond
+
+// metadataLocks serializes updates of the same key within the plugin process.
+var metadataLocks sync.Map
+
+// metadataStore shares typed values between stage executions through the
+// deployment plugin metadata. Keys are namespaced per stage, e.g. "sync.stableRevision".
+//
+// Writes are a write with read-back check, retried with backoff: piped has no
+// versioned writes, so a writer in another process changing the key between
+// the write and the read-back is caught, but one changing it between the read
+// and the write is not. Updates within the plugin process are serialized.
+type metadataStore struct {
+	client    metadataClient
+	namespace string
+}
+
+// newMetadataStore returns a store for the namespace.
+func newMetadataStore(client metadataClient, namespace string) *metadataStore {
+	return &metadataStore{
+		client:    client,
+		namespace: namespace,
+	}
+}
+
+// key returns the namespaced key.
+func (s *metadataStore) key(key string) string {
+	if s.namespace == "" {
+		return key
+	}
+	return s.namespace + "." + key
+}
+
+// GetString returns the value of the key and whether it is set.
+func (s *metadataStore) GetString(ctx context.Context, key string) (string, bool, error) {
+	var (
+		value string
+		ok    bool
+	)
+	err := retryMetadata(ctx, func() error {
+		var err error
+		value, ok, err = s.client.GetDeploymentPluginMetadata(ctx, s.key(key))
+		return err
+	})
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get metadata %s: %w", s.key(key), err)
+	}
+	return value, ok, nil
+}
+
+// PutString sets the value of the key.
+func (s *metadataStore) PutString(ctx context.Context, key, value string) error {
+	return s.Update(ctx, key, func(string, bool) (string, error) {
+		return value, nil
+	})
+}
+
+// Update sets the key to the value returned by fn for the current value.
+// Updates of the key within the plugin process are serialized. If the value
+// read back after the write differs, fn is called again with a fresh read.
+func (s *metadataStore) Update(ctx context.Context, key string, fn func(current string, ok bool) (string, error)) error {
+	fullKey := s.key(key)
+	mu, _ := metadataLocks.LoadOrStore(fullKey, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	defer mu.(*sync.Mutex).Unlock()
+
+	return retryMetadata(ctx, func() error {
+		current, ok, err := s.client.GetDeploymentPluginMetadata(ctx, fullKey)
+		if err != nil {
+			return err
+		}
+		value, err := fn(current, ok)
+		if err != nil {
+			return permanentMetadataError{err}
+		}
+		if err := s.client.PutDeploymentPluginMetadata(ctx, fullKey, value); err != nil {
+			return err
+		}
+
+		// Read back the write to catch a writer overwriting it
+		stored, _, err := s.client.GetDeploymentPluginMetadata(ctx, fullKey)
+		if err != nil {
+			return err
+		}
+		if stored != value {
+			return fmt.Errorf("metadata %s was changed concurrently", fullKey)
+		}
+		return nil
+	})
+}
+
+// getMetadataJSON decodes the JSON value of the key.
+func getMetadataJSON[T any](ctx context.Context, s *metadataStore, key string) (T, bool, error) {
+	var v T
+	data, ok, err := s.GetString(ctx, key)
+	if err != nil || !ok {
+		return v, false, err
+	}
+	if err := json.Unmarshal([]byte(data), &v); err != nil {
+		return v, false, fmt.Errorf("invalid metadata %s: %w", s.key(key), err)
+	}
+	return v, true, nil
+}
+
+// putMetadataJSON stores the value of the key as JSON.
+func putMetadataJSON[T any](ctx context.Context, s *metadataStore, key string, v T) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata %s: %w", s.key(key), err)
+	}
+	return s.PutString(ctx, key, string(data))
+}
+
+// permanentMetadataError is an error that is not retried.
+type permanentMetadataError struct {
+	err error
+}
+
+func (e permanentMetadataError) Error() string { return e.err.Error() }
+
+func (e permanentMetadataError) Unwrap() error { return e.err }
+
+// retryMetadata calls fn until it succeeds, returns a permanent error, or the
+// attempts are exhausted.
+func retryMetadata(ctx context.Context, fn func() error) error {
+	backoff := metadataStoreBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil {
+			return nil
+		}
+		if p, ok := err.(permanentMetadataError); ok {
+			return p.err
+		}
+		if attempt == metadataStoreRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
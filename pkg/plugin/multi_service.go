@@ -0,0 +1,311 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// serviceReport is the outcome of deploying a service of the manifest,
+// reported in MetadataKeyServices.
+type serviceReport struct {
+	Service  string `json:"service"`
+	Revision string `json:"revision,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Ready    bool   `json:"ready"`
+	Error    string `json:"error,omitempty"`
+}
+
+// manifestServiceName returns the service name declared by a service of a
+// multi-service manifest, either a short name or a full resource name.
+func manifestServiceName(svc *runpb.Service) string {
+	if name := cloudrun.GetServiceName(svc.Name); name != "" {
+		return name
+	}
+	return svc.Name
+}
+
+// validateAdditionalServices checks the services following the first one in
+// a multi-service manifest before anything is deployed: each needs a name of
+// its own accepted by the deploy target, and a valid spec.
+func validateAdditionalServices(dt *sdk.DeployTarget[config.DeployTargetConfig], primary string, services []*runpb.Service) error {
+	seen := map[string]bool{primary: true}
+	for i, svc := range services {
+		name := manifestServiceName(svc)
+		switch {
+		case name == "" || strings.Contains(name, "/"):
+			return fmt.Errorf("document %d: the name of additional services is required", i+2)
+		case seen[name]:
+			return fmt.Errorf("document %d: service %s is declared twice", i+2, name)
+		}
+		seen[name] = true
+
+		for _, err := range []error{
+			checkServiceAllowed(dt, name),
+			checkNamingPolicy(dt, name, svc.Template.GetRevision()),
+			cloudrun.ValidateServiceMesh(svc),
+			cloudrun.ValidateContainerDependencies(svc.Template),
+			cloudrun.ValidateServiceLimits(svc),
+		} {
+			if err != nil {
+				return fmt.Errorf("service %s: %w", name, err)
+			}
+		}
+		if _, err := cloudrun.GetServingPort(svc.Template); err != nil {
+			return fmt.Errorf("service %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// deployAdditionalServices deploys the services following the first one in
+// a multi-service manifest, with all traffic on their new revision. All the
+// services are deployed even if some fail; the error joins their failures.
+func deployAdditionalServices(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	client cloudrun.Client,
+	project, region string,
+	services []*runpb.Service,
+	lp sdk.StageLogPersister,
+) ([]serviceReport, error) {
+	reports := make([]serviceReport, 0, len(services))
+	var errs []error
+	for _, svc := range services {
+		name := manifestServiceName(svc)
+		report := serviceReport{Service: name}
+		result, err := deployAdditionalService(ctx, cfg, deployTargets, input, client, project, region, name, svc, lp)
+		if err != nil {
+			lp.Errorf("Failed to deploy service %s: %v", name, err)
+			report.Error = err.Error()
+			errs = append(errs, fmt.Errorf("service %s: %w", name, err))
+		} else {
+			report.Revision = cloudrun.ShortRevisionName(result.LatestCreatedRevision)
+			report.URL = result.Uri
+			report.Ready = true
+			lp.Successf("Successfully deployed revision %s of service %s", report.Revision, name)
+		}
+		reports = append(reports, report)
+	}
+	return reports, errors.Join(errs...)
+}
+
+// deployAdditionalService deploys a service of a multi-service manifest with
+// the deploy target defaults and revision annotations of the application.
+func deployAdditionalService(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	client cloudrun.Client,
+	project, region, name string,
+	svc *runpb.Service,
+	lp sdk.StageLogPersister,
+) (*runpb.Service, error) {
+	lp.Infof("Deploying service: %s", name)
+	cloudrun.SetServiceName(svc, project, region, name)
+	collisions, err := applyDeployTargetDefaults(svc, deployTargets[0], name, project, region)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range collisions {
+		lp.Infof("Warning: %s", formatDefaultsCollision(c))
+	}
+	if cfg.RevisionAnnotations != nil {
+		annotations := revisionAuditAnnotations(cfg.RevisionAnnotations, stageVariables(ctx, deployTargets, input))
+		if err := cloudrun.AnnotateRevision(svc, annotations); err != nil {
+			return nil, err
+		}
+	}
+
+	// Route all traffic to the new revision, merged with the live service
+	desired, err := failoverService(ctx, client, svc, project, region, name, lp)
+	if err != nil {
+		return nil, err
+	}
+	return deployService(ctx, client, desired, project, region, name, lp)
+}
+
+// countNotReady returns the number of services that failed to deploy.
+func countNotReady(reports []serviceReport) int {
+	n := 0
+	for _, r := range reports {
+		if !r.Ready {
+			n++
+		}
+	}
+	return n
+}
+
+// encodeServiceReports encodes the reports for MetadataKeyServices.
+func encodeServiceReports(reports []serviceReport) string {
+	data, _ := json.Marshal(reports)
+	return string(data)
+}
+
+// additionalStableRevisions returns the revision serving each of the services before
+// the deployment, or "" for services that don't exist yet.
+func additionalStableRevisions(ctx context.Context, client cloudrun.Client, project, region string, services []*runpb.Service) map[string]string {
+	stable := make(map[string]string, len(services))
+	for _, svc := range services {
+		name := manifestServiceName(svc)
+		stable[name] = ""
+		if existing, err := client.GetService(ctx, project, region, name); err == nil {
+			stable[name] = cloudrun.ShortRevisionName(existing.LatestReadyRevision)
+		}
+	}
+	return stable
+}
+
+// recordAdditionalServices stores the additional services and the revision
+// serving each of them before the deployment, for CLOUDRUN_ROLLBACK and
+// CLOUDRUN_CANARY_CLEANUP. The revision recorded by the first sync of a
+// deployment is kept. Failing to store them does not fail the stage.
+func recordAdditionalServices(
+	ctx context.Context,
+	client metadataClient,
+	stable map[string]string,
+	lp sdk.StageLogPersister,
+) {
+	store := newMetadataStore(client, targetNamespace(ctx, metadataNamespaceSync))
+	err := store.Update(ctx, metadataKeyAdditionalServices, func(current string, ok bool) (string, error) {
+		recorded := make(map[string]string, len(stable))
+		if ok && current != "" {
+			if err := json.Unmarshal([]byte(current), &recorded); err != nil {
+				return "", fmt.Errorf("invalid metadata %s: %w", store.key(metadataKeyAdditionalServices), err)
+			}
+		}
+		for name, revision := range stable {
+			if _, ok := recorded[name]; !ok {
+				recorded[name] = revision
+			}
+		}
+		data, err := json.Marshal(recorded)
+		return string(data), err
+	})
+	if err != nil {
+		lp.Infof("Warning: Failed to record additional services: %v", err)
+	}
+}
+
+// recordedAdditionalServices returns the additional services recorded by
+// CLOUDRUN_SYNC and the revision serving each of them before, sorted by name.
+func recordedAdditionalServices(
+	ctx context.Context,
+	client metadataClient,
+	lp sdk.StageLogPersister,
+) ([]string, map[string]string) {
+	store := newMetadataStore(client, targetNamespace(ctx, metadataNamespaceSync))
+	stable, ok, err := getMetadataJSON[map[string]string](ctx, store, metadataKeyAdditionalServices)
+	if err != nil {
+		lp.Infof("Warning: Failed to get recorded additional services: %v", err)
+		return nil, nil
+	}
+	if !ok {
+		return nil, nil
+	}
+	names := slices.Sorted(maps.Keys(stable))
+	return names, stable
+}
+
+// rollbackAdditionalServices routes all traffic of the additional services
+// back to the revision serving before the deployment. Services created by the
+// deployment have no such revision and are left as they are.
+func rollbackAdditionalServices(
+	ctx context.Context,
+	client metadataClient,
+	tm *cloudrun.TrafficManager,
+	project, region string,
+	lp sdk.StageLogPersister,
+) error {
+	names, stable := recordedAdditionalServices(ctx, client, lp)
+	var errs []error
+	for _, name := range names {
+		revision := stable[name]
+		if revision == "" {
+			lp.Infof("Warning: Service %s was created by this deployment, leaving it in place", name)
+			continue
+		}
+		lp.Infof("Rolling back service %s to revision %s", name, revision)
+		if err := tm.Rollback(ctx, project, region, name, revision, ""); err != nil {
+			lp.Errorf("Failed to rollback service %s: %v", name, err)
+			errs = append(errs, fmt.Errorf("service %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// cleanupAdditionalServices deletes the old revisions of the additional
+// services with the cleanup options of the application's service, keeping
+// the revision each of them would be rolled back to during the retention. It
+// returns the deleted revisions.
+func cleanupAdditionalServices(
+	ctx context.Context,
+	client metadataClient,
+	rm *cloudrun.RevisionManager,
+	project, region string,
+	opts cloudrun.CleanupOptions,
+	retention time.Duration,
+	lp sdk.StageLogPersister,
+) ([]string, error) {
+	names, stable := recordedAdditionalServices(ctx, client, lp)
+	var deleted []string
+	var errs []error
+	for _, name := range names {
+		lp.Infof("Cleaning up revisions for service: %s", name)
+		revisions, err := rm.ListRevisions(ctx, project, region, name)
+		if err != nil {
+			lp.Errorf("Failed to list revisions of service %s: %v", name, err)
+			errs = append(errs, fmt.Errorf("service %s: %w", name, err))
+			continue
+		}
+
+		serviceOpts := opts
+		serviceOpts.Protected = nil
+		if target := stable[name]; target != "" {
+			if until, ok := rollbackTargetRetention(target, revisions, retention, time.Now()); ok {
+				lp.Infof("Keeping rollback target %s until %s", target, until.UTC().Format(time.RFC3339))
+				serviceOpts.Protected = []string{target}
+			}
+		}
+		result, err := rm.CleanupRevisions(ctx, project, region, name, serviceOpts)
+		if err != nil {
+			lp.Errorf("Failed to cleanup revisions of service %s: %v", name, err)
+			errs = append(errs, fmt.Errorf("service %s: %w", name, err))
+			continue
+		}
+		for _, rev := range result.Skipped {
+			lp.Infof("Warning: Revision %s still has running instances after %s, skipping deletion", rev, opts.DrainTimeout)
+		}
+		lp.Infof("Deleted %d revisions of service %s", len(result.Deleted), name)
+		deleted = append(deleted, result.Deleted...)
+	}
+	return deleted, errors.Join(errs...)
+}
@@ -0,0 +1,150 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"fmt"
+	"strings"
+
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// outputEmoji maps the emoji of plan previews and stage logs to their plain
+// text replacement. Emoji that only decorate a line are dropped; those that
+// carry meaning are replaced with a marker.
+var outputEmoji = []string{
+	"✨ ", "",
+	"✓ ", "",
+	"📝 ", "",
+	"🔄 ", "",
+	"📦 ", "",
+	"🚦 ", "",
+	"🔌 ", "",
+	"🔗 ", "",
+	"💾 ", "",
+	"📈 ", "",
+	"🔒 ", "",
+	"🔐 ", "",
+	"🏷️ ", "",
+	"📜 ", "",
+	"📊 ", "",
+	"🕸️ ", "",
+	"🌐 ", "",
+	"⚠️ ", "[!] ",
+	"⛔ ", "[!] ",
+}
+
+// plainReplacer replaces the emoji, bullets, and arrows with ASCII text.
+var plainReplacer = strings.NewReplacer(append([]string{
+	"• ", "- ",
+	"→", "->",
+}, outputEmoji...)...)
+
+// outputStyle returns the output style of the plugin config, defaulting to
+// emoji.
+func outputStyle(cfg *config.PluginConfig) (string, error) {
+	if cfg == nil || cfg.OutputStyle == "" {
+		return config.OutputStyleEmoji, nil
+	}
+	switch cfg.OutputStyle {
+	case config.OutputStyleEmoji, config.OutputStylePlain, config.OutputStyleMarkdown:
+		return cfg.OutputStyle, nil
+	default:
+		return "", fmt.Errorf("unsupported output style %q (supported: emoji, plain, markdown)", cfg.OutputStyle)
+	}
+}
+
+// styleText renders text written with emoji in the given style. In markdown,
+// section headings (lines starting with an emoji and ending with ":") become
+// Markdown headings.
+func styleText(style, text string) string {
+	switch style {
+	case config.OutputStylePlain:
+		return plainReplacer.Replace(text)
+	case config.OutputStyleMarkdown:
+		lines := strings.Split(text, "\n")
+		for i, line := range lines {
+			plain := plainReplacer.Replace(line)
+			if isSectionHeading(line) {
+				plain = "#### " + strings.TrimSuffix(plain, ":")
+			}
+			lines[i] = plain
+		}
+		return strings.Join(lines, "\n")
+	default:
+		return text
+	}
+}
+
+// isSectionHeading reports whether the line is a plan preview section heading,
+// e.g. "📦 Container Image:".
+func isSectionHeading(line string) bool {
+	if !strings.HasSuffix(line, ":") {
+		return false
+	}
+	for i := 0; i < len(outputEmoji); i += 2 {
+		if strings.HasPrefix(line, outputEmoji[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// styledLogPersister renders the stage logs in a plain output style.
+type styledLogPersister struct {
+	sdk.StageLogPersister
+}
+
+// styleLogPersister returns a log persister rendering the logs in the given
+// style. Logs aren't Markdown, so the markdown style logs plain text.
+func styleLogPersister(lp sdk.StageLogPersister, style string) sdk.StageLogPersister {
+	if style == config.OutputStyleEmoji {
+		return lp
+	}
+	return styledLogPersister{StageLogPersister: lp}
+}
+
+func (l styledLogPersister) Write(log []byte) (int, error) {
+	if _, err := l.StageLogPersister.Write([]byte(plainReplacer.Replace(string(log)))); err != nil {
+		return 0, err
+	}
+	return len(log), nil
+}
+
+func (l styledLogPersister) Info(log string) {
+	l.StageLogPersister.Info(plainReplacer.Replace(log))
+}
+
+func (l styledLogPersister) Infof(format string, a ...interface{}) {
+	l.Info(fmt.Sprintf(format, a...))
+}
+
+func (l styledLogPersister) Success(log string) {
+	l.StageLogPersister.Success(plainReplacer.Replace(log))
+}
+
+func (l styledLogPersister) Successf(format string, a ...interface{}) {
+	l.Success(fmt.Sprintf(format, a...))
+}
+
+func (l styledLogPersister) Error(log string) {
+	l.StageLogPersister.Error(plainReplacer.Replace(log))
+}
+
+func (l styledLogPersister) Errorf(format string, a ...interface{}) {
+	l.Error(fmt.Sprintf(format, a...))
+}
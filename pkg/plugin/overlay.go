@@ -0,0 +1,117 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/render"
+)
+
+// overlayPath returns the path of the overlay of the manifest for the deploy
+// target, e.g. "service.production.yaml" for "service.yaml".
+func overlayPath(manifestPath, target string) string {
+	ext := path.Ext(manifestPath)
+	return strings.TrimSuffix(manifestPath, ext) + "." + target + ext
+}
+
+// manifestOverlay returns the path of the overlay of the manifest for the
+// deploy target, relative to appDir, if it exists.
+func manifestOverlay(appDir, manifestPath, target string) (string, bool) {
+	if manifestPath == "" || target == "" {
+		return "", false
+	}
+	overlay := overlayPath(manifestPath, target)
+	_, ok := findManifest(appDir, []string{overlay})
+	return overlay, ok
+}
+
+// isOverlay reports whether the manifest is the overlay of one of the other
+// manifests for some deploy target, e.g. "service.staging.yaml" of
+// "service.yaml".
+func isOverlay(manifestPath string, manifestPaths []string) bool {
+	ext := path.Ext(manifestPath)
+	base := strings.TrimSuffix(manifestPath, ext)
+	dot := strings.LastIndex(base, ".")
+	if dot <= strings.LastIndex(base, "/") {
+		return false
+	}
+	for _, p := range manifestPaths {
+		if p == base[:dot]+ext {
+			return true
+		}
+	}
+	return false
+}
+
+// applyOverlay deep-merges the overlay of the manifest for the deploy target
+// of vars onto the rendered manifest, if the overlay exists. The overlay is
+// rendered like the manifest, without the patches of the patch renderer, and
+// merged as a JSON merge patch (RFC 7386): objects are merged, null removes a
+// field, and lists replace the manifest's. The documents of a multi-document
+// overlay are merged onto the documents of the manifest in order.
+func applyOverlay(ctx context.Context, spec *config.ApplicationConfig, appDir, manifestPath string, data []byte, vars deploymentVariables) ([]byte, error) {
+	overlay, ok := manifestOverlay(appDir, manifestPath, vars.Target)
+	if !ok {
+		return data, nil
+	}
+
+	name := render.GoTemplate
+	if rendererName(spec) == render.Raw {
+		name = render.Raw
+	}
+	renderer, err := render.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	patchData, err := renderer.Render(ctx, render.Input{AppDir: appDir, ManifestPath: overlay, Variables: vars})
+	if err != nil {
+		return nil, fmt.Errorf("overlay %s: %w", overlay, err)
+	}
+	if patchData, err = cloudrun.EvaluateManifest(ctx, filepath.Join(appDir, overlay), patchData); err != nil {
+		return nil, fmt.Errorf("overlay %s: %w", overlay, err)
+	}
+
+	docs := cloudrun.SplitManifestDocuments(data)
+	patches := cloudrun.SplitManifestDocuments(patchData)
+	if len(patches) > len(docs) {
+		return nil, fmt.Errorf("overlay %s has %d documents, but the manifest has %d", overlay, len(patches), len(docs))
+	}
+	for i, p := range patches {
+		var doc, patch any
+		if err := yaml.Unmarshal(docs[i], &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse manifest %s: %w", manifestPath, err)
+		}
+		if err := yaml.Unmarshal(p, &patch); err != nil {
+			return nil, fmt.Errorf("failed to parse overlay %s: %w", overlay, err)
+		}
+		merged, err := json.Marshal(render.MergePatch(doc, patch))
+		if err != nil {
+			return nil, err
+		}
+		docs[i] = merged
+	}
+	return bytes.Join(docs, []byte("\n---\n")), nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// paramNamePattern matches parameter names usable as {{ .Params.<name> }}.
+var paramNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// paramValues returns the values of the parameters for the deploy target,
+// converted to their declared types. Invalid parameters are left out and
+// reported in the error, so callers can still use the valid ones.
+func paramValues(params []config.ParamConfig, target string) (map[string]any, error) {
+	values := make(map[string]any, len(params))
+	var errs []error
+	for _, p := range params {
+		if !paramNamePattern.MatchString(p.Name) {
+			errs = append(errs, fmt.Errorf("param %q: name must be a letter or underscore followed by letters, digits, or underscores", p.Name))
+			continue
+		}
+		if _, ok := values[p.Name]; ok {
+			errs = append(errs, fmt.Errorf("param %s: declared more than once", p.Name))
+			continue
+		}
+
+		raw, ok := p.Targets[target]
+		source := "deploy target " + target
+		if !ok {
+			raw, source = p.Default, "default"
+		}
+		if raw == nil {
+			errs = append(errs, fmt.Errorf("param %s: no value for deploy target %s and no default", p.Name, target))
+			continue
+		}
+		value, err := convertParam(p.Type, raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("param %s: %s value: %w", p.Name, source, err))
+			continue
+		}
+		values[p.Name] = value
+	}
+	return values, errors.Join(errs...)
+}
+
+// convertParam converts a value decoded from the application config to the
+// parameter type. Strings are accepted for every type, e.g. "3" for an int.
+func convertParam(typ string, raw any) (any, error) {
+	if typ == "" {
+		typ = config.ParamTypeString
+	}
+	switch typ {
+	case config.ParamTypeString:
+		switch v := raw.(type) {
+		case string:
+			return v, nil
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		}
+	case config.ParamTypeInt:
+		switch v := raw.(type) {
+		case float64:
+			if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+				return int64(v), nil
+			}
+		case string:
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return n, nil
+			}
+		}
+	case config.ParamTypeNumber:
+		switch v := raw.(type) {
+		case float64:
+			return v, nil
+		case string:
+			if n, err := strconv.ParseFloat(v, 64); err == nil {
+				return n, nil
+			}
+		}
+	case config.ParamTypeBool:
+		switch v := raw.(type) {
+		case bool:
+			return v, nil
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b, nil
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported type %q (supported: string, int, number, bool)", typ)
+	}
+	return nil, fmt.Errorf("%v is not a valid %s", raw, typ)
+}
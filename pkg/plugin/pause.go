@@ -0,0 +1,110 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"time"
+
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+)
+
+// stageMetadataKeyApprovedUsers is the stage metadata key piped reads the
+// approvers of a stage from, to notify that the deployment was approved.
+const stageMetadataKeyApprovedUsers = "pipecd/stage-approved-users"
+
+// pauseCommandRetryInterval is the delay before listing stage commands again
+// after a failure.
+const pauseCommandRetryInterval = 5 * time.Second
+
+// pauseClient is the part of the SDK client a paused stage waits on.
+type pauseClient interface {
+	ListStageCommands(ctx context.Context, commandTypes ...sdk.CommandType) iter.Seq2[*sdk.StageCommand, error]
+	PutStageMetadata(ctx context.Context, key, value string) error
+}
+
+// pauseProgression keeps the traffic at the promoted percent until the stage
+// is approved, the pause times out, or the deployment is cancelled. It
+// returns who resumed the progression, or "" if it resumed on timeout.
+func pauseProgression(
+	ctx context.Context,
+	client pauseClient,
+	pauseCfg *PauseConfig,
+	percent int,
+	lp sdk.StageLogPersister,
+) (string, error) {
+	// Fill unset fields with defaults
+	defaults := DefaultPauseConfig()
+	timeout := pauseCfg.Timeout.Duration()
+	if timeout <= 0 {
+		timeout = defaults.Timeout.Duration()
+	}
+	onTimeout := pauseCfg.OnTimeout
+	if onTimeout == "" {
+		onTimeout = defaults.OnTimeout
+	}
+	if onTimeout != PauseOnTimeoutAbort && onTimeout != PauseOnTimeoutResume {
+		return "", fmt.Errorf("invalid pause onTimeout %q (supported: abort, resume)", onTimeout)
+	}
+
+	if report, ok := cloudrun.DryRun(ctx); ok {
+		report(fmt.Sprintf("pause at %d%% traffic until the stage is approved", percent))
+		return "", nil
+	}
+
+	lp.Infof("Paused at %d%% traffic: approve the stage to resume, or cancel the deployment with rollback to abort (%s after %s)", percent, onTimeout, timeout)
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	approved := make(chan string, 1)
+	go func() {
+		for cmd, err := range client.ListStageCommands(watchCtx, sdk.CommandTypeApproveStage) {
+			if err != nil {
+				select {
+				case <-watchCtx.Done():
+					return
+				case <-time.After(pauseCommandRetryInterval):
+				}
+				continue
+			}
+			approved <- cmd.Commander
+			return
+		}
+	}()
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	select {
+	case <-ctx.Done():
+		return "", fmt.Errorf("pause was interrupted: %w", ctx.Err())
+	case <-deadline.C:
+		if onTimeout == PauseOnTimeoutAbort {
+			return "", fmt.Errorf("nobody resumed the progression within %s", timeout)
+		}
+		lp.Infof("Nobody resumed the progression within %s, resuming", timeout)
+		return "", nil
+	case commander := <-approved:
+		if err := client.PutStageMetadata(ctx, stageMetadataKeyApprovedUsers, commander); err != nil {
+			lp.Infof("Warning: Failed to record the approver: %v", err)
+		}
+		lp.Successf("Progression resumed by %s", commander)
+		return commander, nil
+	}
+}
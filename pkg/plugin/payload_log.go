@@ -0,0 +1,128 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// withPayloadLog returns a context logging sampled Admin API payloads of the
+// stage to the sinks of the plugin config, if the service manifest of the
+// deployment sets the cloudrun.PayloadLogAnnotation. Otherwise, or if the
+// sinks can't be created, ctx is returned unchanged.
+func withPayloadLog(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	lp sdk.StageLogPersister,
+) context.Context {
+	if cfg == nil || cfg.PayloadLog == nil {
+		return ctx
+	}
+
+	// Stages report manifests that don't render or parse themselves
+	manifest, err := renderedServiceManifest(ctx, cfg, deployTargets, input)
+	if err != nil {
+		return ctx
+	}
+	service, err := cloudrun.ParseServiceManifest(manifest)
+	if err != nil {
+		return ctx
+	}
+	rate, ok, err := cloudrun.PayloadLogSampleRate(service)
+	if err != nil {
+		lp.Infof("Warning: Payloads not logged: %v", err)
+		return ctx
+	}
+	if !ok {
+		return ctx
+	}
+
+	sinks, destinations, err := payloadSinks(ctx, cfg.PayloadLog, deployTargets)
+	if err != nil {
+		lp.Infof("Warning: Payloads not logged: %v", err)
+		return ctx
+	}
+	if len(sinks) == 0 {
+		lp.Info("Warning: Payloads not logged: payloadLog sets neither a file nor a Cloud Logging project")
+		return ctx
+	}
+
+	labels := map[string]string{
+		"deployment":  input.Request.Deployment.ID,
+		"application": input.Request.Deployment.ApplicationName,
+		"stage":       input.Request.StageName,
+	}
+	if len(deployTargets) > 0 {
+		labels["deploy_target"] = deployTargets[0].Name
+	}
+
+	// Report the first failure only, as every sampled call would fail alike
+	var once sync.Once
+	lp.Infof("Logging %g%% of Admin API payloads to %s", rate*100, strings.Join(destinations, " and "))
+	return cloudrun.WithPayloadLog(ctx, &cloudrun.PayloadLogger{
+		Sink:       multiPayloadSink(sinks),
+		SampleRate: rate,
+		Labels:     labels,
+		OnError: func(err error) {
+			once.Do(func() { lp.Infof("Warning: Failed to log a payload: %v", err) })
+		},
+	})
+}
+
+// payloadSinks creates the sinks of the payload log config, and returns them
+// with a description of each.
+func payloadSinks(ctx context.Context, c *config.PayloadLogConfig, deployTargets []*sdk.DeployTarget[config.DeployTargetConfig]) ([]cloudrun.PayloadSink, []string, error) {
+	var sinks []cloudrun.PayloadSink
+	var destinations []string
+	if c.File != "" {
+		sinks = append(sinks, cloudrun.NewFilePayloadSink(c.File))
+		destinations = append(destinations, c.File)
+	}
+	if c.CloudLoggingProject != "" {
+		var credentialsFile string
+		if len(deployTargets) > 0 {
+			credentialsFile = deployTargets[0].Config.CredentialsFile
+		}
+		sink, err := cloudrun.NewCloudLoggingPayloadSink(ctx, credentialsFile, c.CloudLoggingProject, c.LogName)
+		if err != nil {
+			return nil, nil, err
+		}
+		sinks = append(sinks, sink)
+		destinations = append(destinations, "Cloud Logging in "+c.CloudLoggingProject)
+	}
+	return sinks, destinations, nil
+}
+
+// multiPayloadSink writes each record to all the sinks.
+type multiPayloadSink []cloudrun.PayloadSink
+
+// WritePayload writes the record to every sink, even if some fail.
+func (s multiPayloadSink) WritePayload(ctx context.Context, r cloudrun.PayloadRecord) error {
+	var errs []error
+	for _, sink := range s {
+		errs = append(errs, sink.WritePayload(ctx, r))
+	}
+	return errors.Join(errs...)
+}
@@ -17,15 +17,25 @@ package plugin
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
+	"strconv"
 	"strings"
 
+	"cloud.google.com/go/iam/apiv1/iampb"
 	"cloud.google.com/go/run/apiv2/runpb"
 	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun/knative"
 	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
 )
 
+// scalingAnnotationPrefix is the prefix of the Knative autoscaling annotations
+// (e.g. autoscaling.knative.dev/maxScale).
+const scalingAnnotationPrefix = "autoscaling.knative.dev/"
+
 // GetPlanPreview returns the plan preview for a Cloud Run deployment.
 // This shows what will change when the deployment is executed, enabling:
 //   - Pre-deployment visibility of changes
@@ -36,19 +46,39 @@ import (
 //   - Service configuration (container image, resources, environment variables)
 //   - Traffic allocation
 //   - Scaling configuration
+//
+// It compares the target commit against the live service, and also against
+// the manifest recorded with the last successful deployment so reviewers see
+// what changed in Git. piped-plugin-sdk-go v0.1.0 doesn't send the running
+// deployment source to plan preview, so the commit of the running deployment
+// can't be diffed against.
 func (p *cloudrunPlugin) GetPlanPreview(
 	ctx context.Context,
 	cfg *config.PluginConfig,
 	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
 	input *sdk.GetPlanPreviewInput[config.ApplicationConfig],
 ) (*sdk.GetPlanPreviewResponse, error) {
+	cfg, deployTargets = p.config.resolve(cfg, deployTargets)
+
+	style, err := outputStyle(cfg)
+	if err != nil {
+		return nil, err
+	}
+	deployTargets, err = applyCredentialsOverride(ctx, cfg, deployTargets, input.Request.ApplicationID, input.Request.TargetDeploymentSource.ApplicationConfig.Spec.Input)
+	if err != nil {
+		return nil, err
+	}
+	p.credentials.watch(cfg, deployTargets)
+
 	results := []sdk.PlanPreviewResult{}
 
 	for _, target := range deployTargets {
-		result, err := p.generatePlanPreviewForTarget(ctx, target, input)
+		result, err := p.generatePlanPreviewForTarget(ctx, cfg, target, input)
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate plan preview for target %s: %w", target.Name, err)
 		}
+		result.Summary = styleText(style, result.Summary)
+		result.Details = []byte(styleText(style, string(result.Details)))
 		results = append(results, result)
 	}
 
@@ -60,11 +90,11 @@ func (p *cloudrunPlugin) GetPlanPreview(
 // generatePlanPreviewForTarget generates plan preview for a single deploy target.
 func (p *cloudrunPlugin) generatePlanPreviewForTarget(
 	ctx context.Context,
+	cfg *config.PluginConfig,
 	target *sdk.DeployTarget[config.DeployTargetConfig],
 	input *sdk.GetPlanPreviewInput[config.ApplicationConfig],
 ) (sdk.PlanPreviewResult, error) {
 	appConfig := input.Request.TargetDeploymentSource.ApplicationConfig.Spec
-	appDir := input.Request.TargetDeploymentSource.ApplicationDirectory
 
 	// Get project ID and region (prefer app config, fallback to deploy target)
 	projectID := target.Config.ProjectID
@@ -78,42 +108,325 @@ func (p *cloudrunPlugin) generatePlanPreviewForTarget(
 	}
 
 	// Load desired service manifest from Git
-	manifestPath := appConfig.ServiceManifestPath
-	if manifestPath == "" {
-		manifestPath = "service.yaml"
+	vars, err := planPreviewVariables(ctx, target, input, input.Request.TargetDeploymentSource)
+	if err != nil {
+		return sdk.PlanPreviewResult{}, err
 	}
-
-	desiredService, err := cloudrun.LoadServiceManifestFromDir(appDir, manifestPath)
+	services, err := loadSourceServices(ctx, cfg, input.Request.TargetDeploymentSource, vars)
 	if err != nil {
 		return sdk.PlanPreviewResult{}, fmt.Errorf("failed to load service manifest: %w", err)
 	}
-
-	// Apply image override if specified
-	if appConfig.Input.Image != "" {
-		cloudrun.ApplyImageOverride(desiredService, appConfig.Input.Image)
-	}
+	desiredService, additional := services[0], services[1:]
 
 	serviceName := desiredService.Name
+	if name := cloudrun.GetServiceName(serviceName); name != "" {
+		// The manifest may use the full resource name
+		serviceName = name
+	}
 	if appConfig.Input.ServiceName != "" {
 		serviceName = appConfig.Input.ServiceName
 	}
 
+	if err := checkServiceAllowed(target, serviceName); err != nil {
+		return sdk.PlanPreviewResult{}, err
+	}
+	if err := checkNamingPolicy(target, serviceName, desiredService.Template.GetRevision()); err != nil {
+		return sdk.PlanPreviewResult{}, err
+	}
+
+	if _, err := cloudrun.GetServingPort(desiredService.Template); err != nil {
+		return sdk.PlanPreviewResult{}, fmt.Errorf("invalid service manifest: %w", err)
+	}
+	if err := cloudrun.ValidateServiceMesh(desiredService); err != nil {
+		return sdk.PlanPreviewResult{}, fmt.Errorf("invalid service manifest: %w", err)
+	}
+	if err := cloudrun.ValidateContainerDependencies(desiredService.Template); err != nil {
+		return sdk.PlanPreviewResult{}, fmt.Errorf("invalid service manifest: %w", err)
+	}
+	if err := cloudrun.ValidateServiceLimits(desiredService); err != nil {
+		return sdk.PlanPreviewResult{}, fmt.Errorf("invalid service manifest: %w", err)
+	}
+	if err := validateAdditionalServices(target, serviceName, additional); err != nil {
+		return sdk.PlanPreviewResult{}, fmt.Errorf("invalid service manifest: %w", err)
+	}
+
+	// Merge deploy target labels, annotations, and description
+	collisions, err := applyDeployTargetDefaults(desiredService, target, serviceName, projectID, region)
+	if err != nil {
+		return sdk.PlanPreviewResult{}, err
+	}
+
 	// Create Cloud Run client
-	client, err := cloudrun.NewClient(ctx, target.Config.CredentialsFile)
+	client, err := newCloudRunClient(ctx, cfg, target)
 	if err != nil {
 		return sdk.PlanPreviewResult{}, fmt.Errorf("failed to create Cloud Run client: %w", err)
 	}
 	defer client.Close()
 
+	var result sdk.PlanPreviewResult
+
 	// Get current service state from Cloud Run
 	currentService, err := client.GetService(ctx, projectID, region, serviceName)
+	if appConfig.IgnoreServiceMesh {
+		// The mesh is left to another system, so it never changes
+		cloudrun.KeepServiceMesh(desiredService, currentService)
+	}
 	if err != nil {
 		// Service doesn't exist - will be created
-		return generateCreateServicePlan(desiredService, projectID, region, target.Name), nil
+		result = generateCreateServicePlan(desiredService, projectID, region, target.Name)
+	} else {
+		// Service exists - compare and generate diff
+		result = generateUpdateServicePlan(currentService, desiredService, projectID, region, target.Name)
+
+		// Show the change score against the deploy target's change budget
+		if budget := target.Config.ChangeBudget; budget != nil {
+			score := computeChangeScore(currentService, desiredService)
+			var details strings.Builder
+			details.Write(result.Details)
+			details.WriteString(fmt.Sprintf("\n📊 Change Score: %s, budget %d\n", score, budget.MaxScore))
+			if score.Total() > budget.MaxScore {
+				if override := changeBudgetOverride(ctx, appConfig, input.Request.TargetDeploymentSource.ApplicationDirectory); override != "" {
+					details.WriteString(fmt.Sprintf("  Exceeds the budget, allowed by %s.\n", override))
+				} else {
+					details.WriteString(fmt.Sprintf("  ⛔ Exceeds the budget: CLOUDRUN_SYNC will fail unless allowLargeChange is set or the commit message contains %s.\n", allowLargeChangeMarker))
+					result.Summary += fmt.Sprintf("\n⛔ change score %d exceeds the budget of %d", score.Total(), budget.MaxScore)
+				}
+			}
+			result.Details = []byte(details.String())
+		}
+	}
+
+	// Warn about changes increasing the capacity billed without traffic
+	increase, increased, err := computeIdleCostIncrease(currentService, desiredService)
+	if err != nil {
+		return sdk.PlanPreviewResult{}, fmt.Errorf("invalid service manifest: %w", err)
+	}
+	if increased {
+		var details strings.Builder
+		details.Write(result.Details)
+		writeIdleCostIncrease(&details, increase)
+		result.Summary += fmt.Sprintf("\n⚠️ estimated idle cost increases by $%.2f per month", increase.Increase())
+		if target.Config.Production {
+			if confirmation := idleCostConfirmation(ctx, appConfig, input.Request.TargetDeploymentSource.ApplicationDirectory); confirmation != "" {
+				details.WriteString(fmt.Sprintf("  Confirmed by %s.\n", confirmation))
+			} else {
+				details.WriteString(fmt.Sprintf("  ⛔ Production deploy target: CLOUDRUN_SYNC will fail unless confirmIdleCost is set or the commit message contains %s.\n", confirmIdleCostMarker))
+				result.Summary += " (confirmation required)"
+			}
+		}
+		result.Details = []byte(details.String())
+	}
+
+	// Show invoker binding changes, including condition changes
+	if appConfig.Invokers != nil {
+		var policy *iampb.Policy
+		if currentService != nil {
+			policy, err = client.GetIAMPolicy(ctx, projectID, region, serviceName)
+			if err != nil {
+				return sdk.PlanPreviewResult{}, err
+			}
+		}
+		changes, err := invokerBindingsDiff(policy, appConfig.Invokers)
+		if err != nil {
+			return sdk.PlanPreviewResult{}, err
+		}
+		if len(changes.Diff) > 0 {
+			var details strings.Builder
+			details.Write(result.Details)
+			details.WriteString("\n🔐 Invoker Bindings (roles/run.invoker):\n")
+			for _, d := range changes.Diff {
+				details.WriteString(fmt.Sprintf("  %s\n", d))
+			}
+			if len(changes.PublicGranted) > 0 || len(changes.PublicRevoked) > 0 {
+				writePublicAccessChanges(&details, changes)
+			}
+			result.Details = []byte(details.String())
+			if result.NoChange {
+				result.Summary = fmt.Sprintf("🔐 Invoker bindings of service '%s' will be updated", serviceName)
+				result.NoChange = false
+			}
+			if len(changes.PublicGranted) > 0 {
+				result.Summary += fmt.Sprintf("\n⚠️ public access will be granted (%s)", strings.Join(changes.PublicGranted, ", "))
+			}
+			if len(changes.PublicRevoked) > 0 {
+				result.Summary += fmt.Sprintf("\n⚠️ public access will be revoked (%s)", strings.Join(changes.PublicRevoked, ", "))
+			}
+		}
 	}
 
-	// Service exists - compare and generate diff
-	return generateUpdateServicePlan(currentService, desiredService, projectID, region, target.Name), nil
+	// Show the other services of the manifest
+	if len(additional) > 0 {
+		var details strings.Builder
+		details.Write(result.Details)
+		changed, err := writeAdditionalServicesPlan(ctx, &details, client, target, additional, projectID, region)
+		if err != nil {
+			return sdk.PlanPreviewResult{}, err
+		}
+		result.Details = []byte(details.String())
+		if changed > 0 {
+			if result.NoChange {
+				result.Summary = ""
+				result.NoChange = false
+			} else {
+				result.Summary += "\n"
+			}
+			result.Summary += fmt.Sprintf("📦 %d of %d additional services will be created or updated", changed, len(additional))
+		}
+	}
+
+	if len(collisions) > 0 {
+		var details strings.Builder
+		details.Write(result.Details)
+		details.WriteString("\n🏷️ Deploy Target Defaults Overridden by Manifest:\n")
+		for _, c := range collisions {
+			details.WriteString(fmt.Sprintf("  - %s\n", formatDefaultsCollision(c)))
+		}
+		result.Details = []byte(details.String())
+	}
+
+	// Show what changed since the last successful deployment, even when the
+	// live state has drifted
+	if last, ok, err := getLastSuccessfulDeployment(ctx, input.Client); err != nil || (ok && last.Manifest != "") {
+		var details strings.Builder
+		details.Write(result.Details)
+		if err != nil {
+			details.WriteString(fmt.Sprintf("\n📜 Changes in Git since the last successful deployment:\n  %v\n", err))
+		} else {
+			details.WriteString("\n📜 Changes in Git since the last successful deployment")
+			if commit := shortHash(last.CommitHash); commit != "" {
+				details.WriteString(fmt.Sprintf(" (%s)", commit))
+			}
+			details.WriteString(":\n")
+			writeManifestChanges(&details, last.Manifest, desiredService, target, serviceName, projectID, region)
+		}
+		result.Details = []byte(details.String())
+	}
+
+	return result, nil
+}
+
+// writeAdditionalServicesPlan writes whether each additional service of a
+// multi-service manifest will be created or updated, and how. It returns the
+// number of services that will change.
+func writeAdditionalServicesPlan(
+	ctx context.Context,
+	details *strings.Builder,
+	client cloudrun.Client,
+	target *sdk.DeployTarget[config.DeployTargetConfig],
+	services []*runpb.Service,
+	projectID, region string,
+) (int, error) {
+	details.WriteString(fmt.Sprintf("\n📦 Additional Services (%d):\n", len(services)))
+	changed := 0
+	for _, svc := range services {
+		name := manifestServiceName(svc)
+		desired := proto.Clone(svc).(*runpb.Service)
+		if _, err := applyDeployTargetDefaults(desired, target, name, projectID, region); err != nil {
+			return 0, fmt.Errorf("service %s: %w", name, err)
+		}
+		// Additional services route all traffic to their new revision
+		desired.Traffic = []*runpb.TrafficTarget{{
+			Type:    runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST,
+			Percent: 100,
+		}}
+
+		current, err := client.GetService(ctx, projectID, region, name)
+		if err != nil {
+			changed++
+			details.WriteString(fmt.Sprintf("  ✨ %s will be created\n", name))
+			continue
+		}
+		changes, summaryLines := writeServiceDiff(&strings.Builder{}, current, desired)
+		if len(changes) == 0 {
+			details.WriteString(fmt.Sprintf("  ✓ %s has no changes\n", name))
+			continue
+		}
+		changed++
+		details.WriteString(fmt.Sprintf("  📝 %s will be updated (%s)\n", name, strings.Join(changes, ", ")))
+		for _, line := range summaryLines {
+			details.WriteString(fmt.Sprintf("    %s\n", line))
+		}
+	}
+	return changed, nil
+}
+
+// writeManifestChanges writes the changes from the recorded service manifest
+// of a previous deployment to the desired service.
+func writeManifestChanges(
+	details *strings.Builder,
+	manifest string,
+	desired *runpb.Service,
+	target *sdk.DeployTarget[config.DeployTargetConfig],
+	serviceName, projectID, region string,
+) {
+	previous, err := cloudrun.ParseServiceManifest([]byte(manifest))
+	if err == nil {
+		_, err = applyDeployTargetDefaults(previous, target, serviceName, projectID, region)
+	}
+	if err != nil {
+		details.WriteString(fmt.Sprintf("  Failed to load the recorded service manifest: %v\n", err))
+		return
+	}
+	if changes, _ := writeServiceDiff(details, previous, desired); len(changes) == 0 {
+		details.WriteString("  No manifest changes since the last successful deployment.\n")
+	}
+}
+
+// loadSourceService renders the service manifest of a deployment source with
+// its renderer and the deployment variables, and applies the image override
+// (or image file) of its application config.
+func loadSourceService(ctx context.Context, cfg *config.PluginConfig, src sdk.DeploymentSource[config.ApplicationConfig], vars deploymentVariables) (*runpb.Service, error) {
+	services, err := loadSourceServices(ctx, cfg, src, vars)
+	if err != nil {
+		return nil, err
+	}
+	return services[0], nil
+}
+
+// loadSourceServices is loadSourceService returning every service of the
+// manifests. The image override only applies to the first one.
+func loadSourceServices(ctx context.Context, cfg *config.PluginConfig, src sdk.DeploymentSource[config.ApplicationConfig], vars deploymentVariables) ([]*runpb.Service, error) {
+	appConfig := src.ApplicationConfig.Spec
+
+	vars, err := vars.withParams(appConfig.Params)
+	if err != nil {
+		return nil, err
+	}
+	_, data, err := renderServiceManifest(ctx, cfg, appConfig, src.ApplicationDirectory, vars)
+	if err != nil {
+		return nil, err
+	}
+	services, err := cloudrun.ParseServiceManifests(data)
+	if err != nil {
+		return nil, err
+	}
+	service := services[0]
+	if err := vars.interpolateInput(&appConfig.Input); err != nil {
+		return nil, err
+	}
+	if err := resolveInputImage(src.ApplicationDirectory, &appConfig.Input); err != nil {
+		return nil, err
+	}
+
+	// Apply image override if specified
+	if appConfig.Input.Image != "" {
+		cloudrun.ApplyImageOverride(service, appConfig.Input.Image)
+	}
+
+	return services, nil
+}
+
+// planPreviewVariables returns the variables of a deployment source for plan
+// preview, with the values of the deploy target. There is no deployment yet,
+// so DeploymentID is empty, and piped doesn't send the application name.
+func planPreviewVariables(
+	ctx context.Context,
+	target *sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.GetPlanPreviewInput[config.ApplicationConfig],
+	src sdk.DeploymentSource[config.ApplicationConfig],
+) (deploymentVariables, error) {
+	vars := newDeploymentVariables(ctx, "", input.Request.ApplicationID, "", target.Name, src)
+	return vars.withValues(target, src.ApplicationDirectory)
 }
 
 // generateCreateServicePlan generates a plan for creating a new service.
@@ -134,6 +447,12 @@ func generateCreateServicePlan(
 	if service.Template != nil && len(service.Template.Containers) > 0 {
 		details.WriteString(fmt.Sprintf("Container Image: %s\n", service.Template.Containers[0].Image))
 	}
+	if port, err := cloudrun.GetServingPort(service.Template); err == nil {
+		details.WriteString(fmt.Sprintf("Port: %d (%s)\n", port.Port, port.Protocol()))
+	}
+	if len(cloudrun.ContainerDependencies(service.Template)) > 0 {
+		details.WriteString(fmt.Sprintf("Startup Order: %s\n", formatStartupOrder(service.Template)))
+	}
 
 	// Initial traffic
 	if len(service.Traffic) > 0 {
@@ -147,21 +466,38 @@ func generateCreateServicePlan(
 		}
 	}
 
+	// Security settings
+	if security := securitySettings(service); len(security) > 0 {
+		details.WriteString("\nSecurity Settings:\n")
+		for _, k := range slices.Sorted(maps.Keys(security)) {
+			details.WriteString(fmt.Sprintf("  - %s: %s\n", k, security[k]))
+		}
+	}
+
 	// Scaling configuration
 	if service.Template != nil {
 		details.WriteString("\nScaling Configuration:\n")
-		annotations := service.Template.Annotations
-		if minScale, ok := annotations["autoscaling.knative.dev/minScale"]; ok {
+		scaling := scalingSettings(service.Template)
+		if minScale, ok := scaling["minInstanceCount"]; ok {
 			details.WriteString(fmt.Sprintf("  - Min instances: %s\n", minScale))
 		}
-		if maxScale, ok := annotations["autoscaling.knative.dev/maxScale"]; ok {
+		if maxScale, ok := scaling["maxInstanceCount"]; ok {
 			details.WriteString(fmt.Sprintf("  - Max instances: %s\n", maxScale))
 		}
 	}
 
+	// Compact one-line-per-change summary for notification channels
+	summaryLines := []string{}
+	if service.Template != nil && len(service.Template.Containers) > 0 {
+		summaryLines = append(summaryLines, formatSummaryLine("image", "", service.Template.Containers[0].Image))
+	}
+	if len(service.Traffic) > 0 {
+		summaryLines = append(summaryLines, formatSummaryLine("traffic", "", formatTrafficSummary(service.Traffic)))
+	}
+
 	return sdk.PlanPreviewResult{
 		DeployTarget: targetName,
-		Summary:      fmt.Sprintf("✨ New service '%s' will be created in %s/%s", service.Name, projectID, region),
+		Summary:      buildSummary(fmt.Sprintf("✨ New service '%s' will be created in %s/%s", service.Name, projectID, region), summaryLines),
 		NoChange:     false,
 		Details:      []byte(details.String()),
 	}
@@ -173,13 +509,39 @@ func generateUpdateServicePlan(
 	projectID, region, targetName string,
 ) sdk.PlanPreviewResult {
 	var details strings.Builder
-	changes := []string{}
 
 	details.WriteString(fmt.Sprintf("Target: %s\n", targetName))
 	details.WriteString(fmt.Sprintf("Project: %s\n", projectID))
 	details.WriteString(fmt.Sprintf("Region: %s\n", region))
 	details.WriteString(fmt.Sprintf("Service: %s\n\n", current.Name))
 
+	changes, summaryLines := writeServiceDiff(&details, current, desired)
+
+	// Generate summary
+	var summary string
+	noChange := len(changes) == 0
+	if noChange {
+		summary = fmt.Sprintf("✓ No changes - service '%s' matches desired state", current.Name)
+		details.WriteString("✓ No changes detected. Service is in sync with Git.\n")
+	} else {
+		summary = buildSummary(fmt.Sprintf("📝 Service '%s' will be updated (%s)", current.Name, strings.Join(changes, ", ")), summaryLines)
+		details.WriteString(fmt.Sprintf("🔄 A new revision will be created with %d change(s)\n", len(changes)))
+	}
+
+	return sdk.PlanPreviewResult{
+		DeployTarget: targetName,
+		Summary:      summary,
+		NoChange:     noChange,
+		Details:      []byte(details.String()),
+	}
+}
+
+// writeServiceDiff writes the differences between two services to details and
+// returns the changed areas and the compact summary lines.
+func writeServiceDiff(details *strings.Builder, current, desired *runpb.Service) ([]string, []string) {
+	changes := []string{}
+	summaryLines := []string{}
+
 	// Compare container images
 	currentImage := ""
 	desiredImage := ""
@@ -192,6 +554,7 @@ func generateUpdateServicePlan(
 
 	if currentImage != desiredImage {
 		changes = append(changes, "container image")
+		summaryLines = append(summaryLines, formatSummaryLine("image", currentImage, desiredImage))
 		details.WriteString("📦 Container Image:\n")
 		details.WriteString(fmt.Sprintf("  - Current: %s\n", currentImage))
 		details.WriteString(fmt.Sprintf("  + Desired: %s\n\n", desiredImage))
@@ -200,6 +563,7 @@ func generateUpdateServicePlan(
 	// Compare traffic allocation
 	if hasTrafficChanges(current.Traffic, desired.Traffic) {
 		changes = append(changes, "traffic allocation")
+		summaryLines = append(summaryLines, formatSummaryLine("traffic", formatTrafficSummary(current.Traffic), formatTrafficSummary(desired.Traffic)))
 		details.WriteString("🚦 Traffic Allocation:\n")
 		details.WriteString("  Current:\n")
 		for _, t := range current.Traffic {
@@ -212,62 +576,155 @@ func generateUpdateServicePlan(
 		details.WriteString("\n")
 	}
 
-	// Compare resource limits
+	// Compare the serving port; a protocol change breaks existing clients
+	currentPort, _ := cloudrun.GetServingPort(current.Template)
+	desiredPort, _ := cloudrun.GetServingPort(desired.Template)
+	if currentPort.Protocol() != desiredPort.Protocol() {
+		changes = append(changes, "⚠️ protocol")
+		summaryLines = append(summaryLines, "⚠️ "+strings.TrimPrefix(formatSummaryLine("protocol", currentPort.Protocol(), desiredPort.Protocol()), "• "))
+		details.WriteString("⚠️ Protocol (traffic-breaking):\n")
+		details.WriteString(fmt.Sprintf("  - Current: %s\n", currentPort.Protocol()))
+		details.WriteString(fmt.Sprintf("  + Desired: %s\n", desiredPort.Protocol()))
+		details.WriteString("  Clients of the current protocol will fail once traffic moves to the new revision.\n\n")
+	}
+	if currentPort.Port != desiredPort.Port {
+		changes = append(changes, "port")
+		summaryLines = append(summaryLines, formatSummaryLine("port", strconv.Itoa(int(currentPort.Port)), strconv.Itoa(int(desiredPort.Port))))
+		details.WriteString("🔌 Port:\n")
+		details.WriteString(fmt.Sprintf("  - Current: %d\n", currentPort.Port))
+		details.WriteString(fmt.Sprintf("  + Desired: %d\n\n", desiredPort.Port))
+	}
+
+	// Compare the container startup order; sidecar-dependent containers fail
+	// to start if it is wrong
+	if currentDeps, desiredDeps := cloudrun.ContainerDependencies(current.Template), cloudrun.ContainerDependencies(desired.Template); !maps.EqualFunc(currentDeps, desiredDeps, slices.Equal) {
+		changes = append(changes, "startup order")
+		summaryLines = append(summaryLines, formatSummaryLine("startup order", formatSettings(dependencySettings(currentDeps)), formatSettings(dependencySettings(desiredDeps))))
+		details.WriteString("🔗 Container Startup Order:\n")
+		details.WriteString(fmt.Sprintf("  - Current: %s\n", formatStartupOrder(current.Template)))
+		details.WriteString(fmt.Sprintf("  + Desired: %s\n", formatStartupOrder(desired.Template)))
+		writeSettingsDiff(details, dependencySettings(currentDeps), dependencySettings(desiredDeps))
+		details.WriteString("\n")
+	}
+
+	// Compare resources (limits of all containers, CPU allocation, accelerators)
 	if hasResourceChanges(current.Template, desired.Template) {
-		changes = append(changes, "resource limits")
-		details.WriteString("💾 Resource Limits:\n")
-		if current.Template != nil && len(current.Template.Containers) > 0 {
-			currentRes := current.Template.Containers[0].Resources
-			if currentRes != nil && currentRes.Limits != nil {
-				details.WriteString(fmt.Sprintf("  - CPU: %s, Memory: %s\n",
-					currentRes.Limits["cpu"], currentRes.Limits["memory"]))
-			}
-		}
-		if desired.Template != nil && len(desired.Template.Containers) > 0 {
-			desiredRes := desired.Template.Containers[0].Resources
-			if desiredRes != nil && desiredRes.Limits != nil {
-				details.WriteString(fmt.Sprintf("  + CPU: %s, Memory: %s\n\n",
-					desiredRes.Limits["cpu"], desiredRes.Limits["memory"]))
-			}
-		}
+		changes = append(changes, "resources")
+		summaryLines = append(summaryLines, formatSummaryLine("resources", formatResourceSummary(current.Template), formatResourceSummary(desired.Template)))
+		details.WriteString("💾 Resources:\n")
+		writeSettingsDiff(details, resourceSettings(current.Template), resourceSettings(desired.Template))
+		details.WriteString("\n")
 	}
 
 	// Compare scaling configuration
 	if hasScalingChanges(current.Template, desired.Template) {
 		changes = append(changes, "scaling configuration")
+		summaryLines = append(summaryLines, formatSummaryLine("scaling", formatScalingSummary(current.Template), formatScalingSummary(desired.Template)))
 		details.WriteString("📈 Scaling Configuration:\n")
-		if current.Template != nil {
-			currentMin := current.Template.Annotations["autoscaling.knative.dev/minScale"]
-			currentMax := current.Template.Annotations["autoscaling.knative.dev/maxScale"]
-			if currentMin != "" || currentMax != "" {
-				details.WriteString(fmt.Sprintf("  - Min: %s, Max: %s\n", currentMin, currentMax))
-			}
-		}
-		if desired.Template != nil {
-			desiredMin := desired.Template.Annotations["autoscaling.knative.dev/minScale"]
-			desiredMax := desired.Template.Annotations["autoscaling.knative.dev/maxScale"]
-			if desiredMin != "" || desiredMax != "" {
-				details.WriteString(fmt.Sprintf("  + Min: %s, Max: %s\n\n", desiredMin, desiredMax))
-			}
-		}
+		writeSettingsDiff(details, scalingSettings(current.Template), scalingSettings(desired.Template))
+		details.WriteString("\n")
 	}
 
-	// Generate summary
-	var summary string
-	noChange := len(changes) == 0
-	if noChange {
-		summary = fmt.Sprintf("✓ No changes - service '%s' matches desired state", current.Name)
-		details.WriteString("✓ No changes detected. Service is in sync with Git.\n")
-	} else {
-		summary = fmt.Sprintf("📝 Service '%s' will be updated (%s)", current.Name, strings.Join(changes, ", "))
-		details.WriteString(fmt.Sprintf("🔄 A new revision will be created with %d change(s)\n", len(changes)))
+	// Compare the service mesh, which is kept if the manifest doesn't manage it
+	if currentMesh, desiredMesh := cloudrun.ServiceMesh(current), cloudrun.AppliedServiceMesh(current, desired); currentMesh != desiredMesh {
+		changes = append(changes, "service mesh")
+		summaryLines = append(summaryLines, formatSummaryLine("mesh", formatMesh(currentMesh), formatMesh(desiredMesh)))
+		details.WriteString("🕸️ Service Mesh:\n")
+		details.WriteString(fmt.Sprintf("  - Current: %s\n", formatMesh(currentMesh)))
+		details.WriteString(fmt.Sprintf("  + Desired: %s\n\n", formatMesh(desiredMesh)))
 	}
 
-	return sdk.PlanPreviewResult{
-		DeployTarget: targetName,
-		Summary:      summary,
-		NoChange:     noChange,
-		Details:      []byte(details.String()),
+	// Compare the security settings declared in the manifest
+	desiredSecurity := securitySettings(desired)
+	currentSecurity := declaredSettings(securitySettings(current), desiredSecurity)
+	if !maps.Equal(currentSecurity, desiredSecurity) {
+		changes = append(changes, "security settings")
+		summaryLines = append(summaryLines, formatSummaryLine("security", formatSettings(currentSecurity), formatSettings(desiredSecurity)))
+		details.WriteString("🔒 Security Settings:\n")
+		writeSettingsDiff(details, currentSecurity, desiredSecurity)
+		details.WriteString("\n")
+	}
+
+	return changes, summaryLines
+}
+
+// buildSummary builds the plan preview summary: a headline followed by one
+// compact line per change, designed for notification channels (e.g. Slack)
+// so plan-preview bots don't have to parse the details.
+func buildSummary(headline string, lines []string) string {
+	if len(lines) == 0 {
+		return headline
+	}
+	return headline + "\n" + strings.Join(lines, "\n")
+}
+
+// formatSummaryLine formats a single change for the summary.
+// Example: "• image: gcr.io/p/app:v1 → gcr.io/p/app:v2"
+func formatSummaryLine(field, current, desired string) string {
+	if current == "" {
+		return fmt.Sprintf("• %s: %s", field, desired)
+	}
+	return fmt.Sprintf("• %s: %s → %s", field, current, desired)
+}
+
+// formatTrafficSummary formats traffic targets on a single line.
+// Example: "latest 90%, my-service-00042 10%"
+func formatTrafficSummary(traffic []*runpb.TrafficTarget) string {
+	if len(traffic) == 0 {
+		return "none"
+	}
+	parts := make([]string, 0, len(traffic))
+	for _, t := range traffic {
+		parts = append(parts, fmt.Sprintf("%s %d%%", getTrafficKey(t), t.Percent))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatResourceSummary formats the resource settings on a single line.
+func formatResourceSummary(tmpl *runpb.RevisionTemplate) string {
+	return formatSettings(resourceSettings(tmpl))
+}
+
+// formatScalingSummary formats the scaling settings on a single line.
+func formatScalingSummary(tmpl *runpb.RevisionTemplate) string {
+	return formatSettings(scalingSettings(tmpl))
+}
+
+// formatSettings formats settings as sorted key=value pairs.
+// Example: "limits.cpu=1000m limits.memory=512Mi"
+func formatSettings(settings map[string]string) string {
+	if len(settings) == 0 {
+		return "default"
+	}
+	parts := make([]string, 0, len(settings))
+	for _, k := range slices.Sorted(maps.Keys(settings)) {
+		parts = append(parts, fmt.Sprintf("%s=%s", k, settings[k]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// writeSettingsDiff writes the settings that differ between current and desired.
+func writeSettingsDiff(details *strings.Builder, current, desired map[string]string) {
+	keys := make(map[string]struct{}, len(current)+len(desired))
+	for k := range current {
+		keys[k] = struct{}{}
+	}
+	for k := range desired {
+		keys[k] = struct{}{}
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(keys)) {
+		currentValue, inCurrent := current[k]
+		desiredValue, inDesired := desired[k]
+		if inCurrent && inDesired && currentValue == desiredValue {
+			continue
+		}
+		if inCurrent {
+			details.WriteString(fmt.Sprintf("  - %s: %s\n", k, currentValue))
+		}
+		if inDesired {
+			details.WriteString(fmt.Sprintf("  + %s: %s\n", k, desiredValue))
+		}
 	}
 }
 
@@ -321,45 +778,172 @@ func formatTrafficTarget(t *runpb.TrafficTarget, prefix string) string {
 	return fmt.Sprintf("%sRevision %s: %d%%\n", prefix, t.Revision, t.Percent)
 }
 
-// hasResourceChanges checks if resource limits have changed.
+// hasResourceChanges checks if resource settings have changed.
 func hasResourceChanges(current, desired *runpb.RevisionTemplate) bool {
-	if current == nil || desired == nil {
-		return current != desired
+	return !maps.Equal(resourceSettings(current), resourceSettings(desired))
+}
+
+// hasScalingChanges checks if scaling configuration has changed.
+func hasScalingChanges(current, desired *runpb.RevisionTemplate) bool {
+	return !maps.Equal(scalingSettings(current), scalingSettings(desired))
+}
+
+// resourceSettings flattens the resource settings of a revision template so
+// every limit key (e.g. nvidia.com/gpu) is compared, not only cpu and memory.
+//
+// Keys are prefixed with the container name when the template has more than
+// one container. The Admin API v2 has no resource requests; CPU allocation
+// is expressed by cpuIdle instead.
+func resourceSettings(tmpl *runpb.RevisionTemplate) map[string]string {
+	settings := make(map[string]string)
+	if tmpl == nil {
+		return settings
+	}
+
+	for i, c := range tmpl.Containers {
+		if c.Resources == nil {
+			continue
+		}
+		prefix := ""
+		if len(tmpl.Containers) > 1 {
+			name := c.Name
+			if name == "" {
+				name = fmt.Sprintf("container-%d", i)
+			}
+			prefix = name + "/"
+		}
+		for k, v := range c.Resources.Limits {
+			settings[prefix+"limits."+k] = v
+		}
+		if c.Resources.CpuIdle {
+			settings[prefix+"cpuIdle"] = "true"
+		}
+		if c.Resources.StartupCpuBoost {
+			settings[prefix+"startupCpuBoost"] = "true"
+		}
 	}
 
-	if len(current.Containers) == 0 || len(desired.Containers) == 0 {
-		return len(current.Containers) != len(desired.Containers)
+	// GPUs also require an accelerator node selector
+	if accelerator := tmpl.GetNodeSelector().GetAccelerator(); accelerator != "" {
+		settings["nodeSelector.accelerator"] = accelerator
 	}
 
-	currentRes := current.Containers[0].Resources
-	desiredRes := desired.Containers[0].Resources
+	return settings
+}
 
-	if currentRes == nil || desiredRes == nil {
-		return currentRes != desiredRes
+// scalingSettings flattens the scaling settings of a revision template: the
+// scaling fields, the request concurrency, and the autoscaling.knative.dev
+// annotations without a field. Annotations with a field are converted first,
+// so minScale in a manifest compares equal to the minimum instance count of
+// the live service.
+func scalingSettings(tmpl *runpb.RevisionTemplate) map[string]string {
+	settings := make(map[string]string)
+	if tmpl == nil {
+		return settings
+	}
+
+	// Unsupported annotations are kept and compared as is
+	tmpl = proto.Clone(tmpl).(*runpb.RevisionTemplate)
+	_ = knative.ApplyRevisionAnnotations(tmpl)
+	for k, v := range tmpl.Annotations {
+		if name, ok := strings.CutPrefix(k, scalingAnnotationPrefix); ok {
+			settings[name] = v
+		}
 	}
-
-	if currentRes.Limits == nil && desiredRes.Limits == nil {
-		return false
+	if tmpl.Scaling != nil {
+		if tmpl.Scaling.MinInstanceCount != 0 {
+			settings["minInstanceCount"] = strconv.Itoa(int(tmpl.Scaling.MinInstanceCount))
+		}
+		if tmpl.Scaling.MaxInstanceCount != 0 {
+			settings["maxInstanceCount"] = strconv.Itoa(int(tmpl.Scaling.MaxInstanceCount))
+		}
+	}
+	if tmpl.MaxInstanceRequestConcurrency != 0 {
+		settings["maxInstanceRequestConcurrency"] = strconv.Itoa(int(tmpl.MaxInstanceRequestConcurrency))
 	}
 
-	if currentRes.Limits == nil || desiredRes.Limits == nil {
-		return true
+	return settings
+}
+
+// formatStartupOrder formats the order the containers of the revision
+// template start in, e.g. "otel-collector → app".
+func formatStartupOrder(tmpl *runpb.RevisionTemplate) string {
+	order, err := cloudrun.ContainerStartupOrder(tmpl)
+	if err != nil {
+		return "invalid"
+	}
+	if len(order) == 0 {
+		return "none"
 	}
+	return strings.Join(order, " → ")
+}
 
-	return currentRes.Limits["cpu"] != desiredRes.Limits["cpu"] ||
-		currentRes.Limits["memory"] != desiredRes.Limits["memory"]
+// dependencySettings flattens the container dependencies, e.g.
+// "app.dependsOn" = "otel-collector,proxy".
+func dependencySettings(deps map[string][]string) map[string]string {
+	settings := make(map[string]string, len(deps))
+	for name, dependsOn := range deps {
+		settings[name+".dependsOn"] = strings.Join(dependsOn, ",")
+	}
+	return settings
 }
 
-// hasScalingChanges checks if scaling configuration has changed.
-func hasScalingChanges(current, desired *runpb.RevisionTemplate) bool {
-	if current == nil || desired == nil {
-		return current != desired
+// securitySettings flattens the security settings of a service: the
+// execution environment (sandbox generation), the service account, CMEK
+// encryption, and Binary Authorization. Enum values are shown as in manifests.
+func securitySettings(svc *runpb.Service) map[string]string {
+	settings := make(map[string]string)
+	if binauthz := svc.GetBinaryAuthorization(); binauthz != nil {
+		if binauthz.GetUseDefault() {
+			settings["binaryAuthorization.useDefault"] = "true"
+		}
+		if policy := binauthz.GetPolicy(); policy != "" {
+			settings["binaryAuthorization.policy"] = policy
+		}
+		if justification := binauthz.GetBreakglassJustification(); justification != "" {
+			settings["binaryAuthorization.breakglassJustification"] = justification
+		}
+	}
+
+	tmpl := svc.GetTemplate()
+	if tmpl == nil {
+		return settings
+	}
+	if tmpl.ExecutionEnvironment != runpb.ExecutionEnvironment_EXECUTION_ENVIRONMENT_UNSPECIFIED {
+		settings["executionEnvironment"] = tmpl.ExecutionEnvironment.String()
+	}
+	if tmpl.ServiceAccount != "" {
+		settings["serviceAccount"] = tmpl.ServiceAccount
+	}
+	if tmpl.EncryptionKey != "" {
+		settings["encryptionKey"] = tmpl.EncryptionKey
 	}
+	if tmpl.EncryptionKeyRevocationAction != runpb.EncryptionKeyRevocationAction_ENCRYPTION_KEY_REVOCATION_ACTION_UNSPECIFIED {
+		settings["encryptionKeyRevocationAction"] = tmpl.EncryptionKeyRevocationAction.String()
+	}
+	if tmpl.EncryptionKeyShutdownDuration != nil {
+		settings["encryptionKeyShutdownDuration"] = tmpl.EncryptionKeyShutdownDuration.AsDuration().String()
+	}
+	return settings
+}
 
-	currentMin := current.Annotations["autoscaling.knative.dev/minScale"]
-	currentMax := current.Annotations["autoscaling.knative.dev/maxScale"]
-	desiredMin := desired.Annotations["autoscaling.knative.dev/minScale"]
-	desiredMax := desired.Annotations["autoscaling.knative.dev/maxScale"]
+// formatMesh formats a service mesh for the plan preview and logs.
+func formatMesh(mesh string) string {
+	if mesh == "" {
+		return "none"
+	}
+	return mesh
+}
 
-	return currentMin != desiredMin || currentMax != desiredMax
+// declaredSettings returns the settings with the keys set in declared. Settings
+// a manifest leaves to Cloud Run (e.g. the default service account) are filled
+// in by the server, and comparing them would always show a difference.
+func declaredSettings(settings, declared map[string]string) map[string]string {
+	out := make(map[string]string, len(declared))
+	for k := range declared {
+		if v, ok := settings[k]; ok {
+			out[k] = v
+		}
+	}
+	return out
 }
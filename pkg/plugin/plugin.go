@@ -47,9 +47,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
 
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
 	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
 )
 
@@ -60,25 +62,49 @@ var _ sdk.DeploymentPlugin[config.PluginConfig, config.DeployTargetConfig, confi
 type cloudrunPlugin struct {
 	// stageExecutor handles the execution of individual stages
 	stageExecutor *StageExecutor
+
+	// credentials validates deploy target credentials in the background
+	credentials *credentialsWatcher
+
+	// config holds the plugin config reloaded from a file, if any
+	config *configReloader
 }
 
 // NewCloudRunPlugin creates a new Cloud Run plugin instance.
 func NewCloudRunPlugin() *cloudrunPlugin {
+	credentials := newCredentialsWatcher()
 	return &cloudrunPlugin{
 		stageExecutor: NewStageExecutor(),
+		credentials:   credentials,
+		config:        newConfigReloader(credentials),
 	}
 }
 
 // FetchDefinedStages returns the list of stages this plugin can execute.
 // This is called by piped to discover what stages the plugin supports.
 //
-// Returns: ["CLOUDRUN_SYNC", "CLOUDRUN_PROMOTE", "CLOUDRUN_ROLLBACK", "CLOUDRUN_CANARY_CLEANUP"]
+// Returns: ["CLOUDRUN_SYNC", "CLOUDRUN_PROMOTE", "CLOUDRUN_ROLLBACK", "CLOUDRUN_CANARY_CLEANUP", "CLOUDRUN_HOLD",
+// "CLOUDRUN_UPDATE_TASK_QUEUE", "CLOUDRUN_FAULT_INJECTION", "CLOUDRUN_PREVIEW_CLEANUP", "CLOUDRUN_ANALYSIS",
+// "CLOUDRUN_JOB_SYNC", "CLOUDRUN_JOB_RUN", "CLOUDRUN_JOB_ROLLBACK", "CLOUDRUN_MIRROR_VERIFY", "CLOUDRUN_DOMAIN_VERIFY",
+// "CLOUDRUN_BACKUP", "CLOUDRUN_RESTORE"]
 func (p *cloudrunPlugin) FetchDefinedStages() []string {
 	return []string{
 		StageCloudRunSync,
 		StageCloudRunPromote,
 		StageCloudRunRollback,
 		StageCloudRunCanaryCleanup,
+		StageCloudRunHold,
+		StageCloudRunUpdateTaskQueue,
+		StageCloudRunFaultInjection,
+		StageCloudRunPreviewCleanup,
+		StageCloudRunAnalysis,
+		StageCloudRunJobSync,
+		StageCloudRunJobRun,
+		StageCloudRunJobRollback,
+		StageCloudRunMirrorVerify,
+		StageCloudRunDomainVerify,
+		StageCloudRunBackup,
+		StageCloudRunRestore,
 	}
 }
 
@@ -93,7 +119,11 @@ func (p *cloudrunPlugin) DetermineVersions(
 	input *sdk.DetermineVersionsInput[config.ApplicationConfig],
 ) (*sdk.DetermineVersionsResponse, error) {
 	// Extract version from container image
-	image := input.Request.DeploymentSource.ApplicationConfig.Spec.Input.Image
+	src := input.Request.DeploymentSource
+	if err := resolveInputImage(src.ApplicationDirectory, &src.ApplicationConfig.Spec.Input); err != nil {
+		return nil, err
+	}
+	image := src.ApplicationConfig.Spec.Input.Image
 	version := extractVersionFromImage(image)
 
 	return &sdk.DetermineVersionsResponse{
@@ -175,6 +205,10 @@ func (p *cloudrunPlugin) BuildPipelineSyncStages(
 			Rollback:           false,
 			AvailableOperation: sdk.ManualOperationNone,
 		}
+		// Paused promotions are resumed by approving the stage
+		if rs.Name == StageCloudRunPromote && hasStageConfigField(rs.Config, "pause") {
+			stage.AvailableOperation = sdk.ManualOperationApprove
+		}
 		stages = append(stages, stage)
 	}
 
@@ -193,31 +227,250 @@ func (p *cloudrunPlugin) BuildPipelineSyncStages(
 //   - CLOUDRUN_PROMOTE: Adjust traffic split
 //   - CLOUDRUN_ROLLBACK: Rollback to previous revision
 //   - CLOUDRUN_CANARY_CLEANUP: Clean up old revisions
+//   - CLOUDRUN_HOLD: Hold the current traffic split
+//   - CLOUDRUN_UPDATE_TASK_QUEUE: Point a Cloud Tasks queue at the service
+//   - CLOUDRUN_FAULT_INJECTION: Verify error handling with an injected fault
+//   - CLOUDRUN_PREVIEW_CLEANUP: Delete expired preview services
+//   - CLOUDRUN_ANALYSIS: Check the candidate revision's metrics
+//   - CLOUDRUN_JOB_SYNC: Deploy the job template
+//   - CLOUDRUN_JOB_RUN: Run the job
+//   - CLOUDRUN_JOB_ROLLBACK: Restore the previous job template
+//   - CLOUDRUN_MIRROR_VERIFY: Compare the candidate's responses with the stable revision
+//   - CLOUDRUN_DOMAIN_VERIFY: Verify the DNS and TLS of the service's domains
+//   - CLOUDRUN_BACKUP: Back up the service configuration to Cloud Storage
+//   - CLOUDRUN_RESTORE: Restore the service configuration from a backup
+//
+// For CloudRunJob applications, CLOUDRUN_SYNC and CLOUDRUN_ROLLBACK run the
+// job stages, so quick sync deploys the job template.
 func (p *cloudrunPlugin) ExecuteStage(
 	ctx context.Context,
 	cfg *config.PluginConfig,
 	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
 	input *sdk.ExecuteStageInput[config.ApplicationConfig],
 ) (*sdk.ExecuteStageResponse, error) {
-	// Get log persister for logging stage execution
+	cfg, deployTargets = p.config.resolve(cfg, deployTargets)
+
+	// Get log persister for logging stage execution, in the output style of
+	// the plugin
 	lp := input.Client.LogPersister()
+	style, err := outputStyle(cfg)
+	if err != nil {
+		lp.Errorf("Invalid plugin config: %v", err)
+		return &sdk.ExecuteStageResponse{
+			Status: sdk.StageStatusFailure,
+		}, err
+	}
+	lp = styleLogPersister(lp, style)
 
 	lp.Infof("Executing stage: %s", input.Request.StageName)
 
+	// Deploy with the application's own credentials if it overrides them
+	deployTargets, err = applyCredentialsOverride(ctx, cfg, deployTargets, input.Request.Deployment.ApplicationID, input.Request.TargetDeploymentSource.ApplicationConfig.Spec.Input)
+	if err != nil {
+		lp.Errorf("Failed to override credentials: %v", err)
+		return &sdk.ExecuteStageResponse{
+			Status: sdk.StageStatusFailure,
+		}, err
+	}
+
+	// Run the stage against the deploy target it selects
+	ctx, deployTargets, err = selectStageTarget(ctx, deployTargets, input.Request.StageConfig)
+	if err != nil {
+		lp.Errorf("Invalid stage config: %v", err)
+		return &sdk.ExecuteStageResponse{
+			Status: sdk.StageStatusFailure,
+		}, err
+	}
+
+	// Report the progress of long waits so the stage doesn't look hung
+	ctx = cloudrun.WithProgress(ctx, func(message string) {
+		lp.Info(message)
+	}, cloudrun.DefaultProgressInterval)
+
+	// Log the mutations of a dry-run deployment instead of making them
+	dryRun := input.Request.TargetDeploymentSource.ApplicationConfig.Spec.DryRun
+	if dryRun {
+		lp.Info("Dry run: no changes will be made")
+		ctx = cloudrun.WithDryRun(ctx, func(message string) {
+			lp.Infof("[dry run] %s", message)
+		})
+	}
+
+	// Refuse stages the deploy targets don't allow before making any call
+	stageName := jobStageName(input.Request.TargetDeploymentSource.ApplicationConfig.Spec, input.Request.StageName)
+	for _, dt := range deployTargets {
+		if err := checkStageAllowed(dt, input.Request.StageName, stageName); err != nil {
+			lp.Errorf("%v", err)
+			result := &StageResult{
+				Status:  StageStatusFailure,
+				Message: err.Error(),
+			}
+			reportStageResult(ctx, input, lp, result)
+			return result.toResponse(), err
+		}
+	}
+
+	// Resolve secretRef values in the stage config before dispatching
+	stageConfig, err := resolveStageConfigSecrets(ctx, cfg, deployTargets, input.Request.StageConfig)
+	if err != nil {
+		lp.Errorf("Failed to resolve secrets in stage config: %v", err)
+		return &sdk.ExecuteStageResponse{
+			Status: sdk.StageStatusFailure,
+		}, err
+	}
+	input.Request.StageConfig = stageConfig
+
+	// Resolve secretRef values in the plugin and deploy target configs
+	cfg, deployTargets, err = resolvePluginConfigSecrets(ctx, cfg, deployTargets)
+	if err != nil {
+		lp.Errorf("Failed to resolve secrets in plugin config: %v", err)
+		return &sdk.ExecuteStageResponse{
+			Status: sdk.StageStatusFailure,
+		}, err
+	}
+
+	// Validate the credentials the stage deploys with, see /readyz
+	p.credentials.watch(cfg, deployTargets)
+
+	// Render deployment variables into the stage config and application input
+	if err := interpolateStageInput(ctx, deployTargets, input); err != nil {
+		lp.Errorf("Failed to render deployment variables: %v", err)
+		return &sdk.ExecuteStageResponse{
+			Status: sdk.StageStatusFailure,
+		}, err
+	}
+
+	// Read the image from the build artifact file of the application
+	target := input.Request.TargetDeploymentSource
+	if err := resolveInputImage(target.ApplicationDirectory, &target.ApplicationConfig.Spec.Input); err != nil {
+		lp.Errorf("Failed to resolve the input image: %v", err)
+		return &sdk.ExecuteStageResponse{
+			Status: sdk.StageStatusFailure,
+		}, err
+	}
+
+	// Skip the stage if its condition does not hold
+	run, reason, err := evaluateStageCondition(ctx, deployTargets, input)
+	if err != nil {
+		lp.Errorf("Failed to evaluate stage condition: %v", err)
+		return &sdk.ExecuteStageResponse{
+			Status: sdk.StageStatusFailure,
+		}, err
+	}
+	if !run {
+		lp.Infof("Skipping stage: %s", reason)
+		result := &StageResult{
+			Status:  StageStatusSuccess,
+			Message: "skipped: " + reason,
+		}
+		reportStageResult(ctx, input, lp, result)
+		return result.toResponse(), nil
+	}
+
+	// Bound the stage by the remaining deployment budget
+	ctx, cancelBudget, err := applyDeploymentBudget(ctx, input, lp, time.Now())
+	if err != nil {
+		lp.Errorf("%v", err)
+		result := &StageResult{
+			Status:  StageStatusFailure,
+			Message: err.Error(),
+		}
+		reportStageResult(ctx, input, lp, result)
+		return result.toResponse(), err
+	}
+	defer cancelBudget()
+
+	// Log sampled Admin API payloads if the deployment asks for it
+	ctx = withPayloadLog(ctx, cfg, deployTargets, input, lp)
+
+	// Share the Cloud Run clients of the deployment's stages until it completes
+	deploymentID := input.Request.Deployment.ID
+	p.stageExecutor.targets.begin(deploymentID, time.Now())
+	recordAutoPromoteStart(ctx, input, lp, time.Now())
+
 	// Dispatch to appropriate stage handler
-	switch input.Request.StageName {
+	var result *StageResult
+	started := time.Now()
+	p.stageExecutor.notifyStageHooks(ctx, cfg, deployTargets, input, lp, StageEventStarted, nil)
+	switch stageName {
 	case StageCloudRunSync:
-		return p.stageExecutor.ExecuteSyncStage(ctx, cfg, deployTargets, input, lp)
+		result, err = p.stageExecutor.ExecuteSyncStage(ctx, cfg, deployTargets, input, lp)
 	case StageCloudRunPromote:
-		return p.stageExecutor.ExecutePromoteStage(ctx, cfg, deployTargets, input, lp)
+		result, err = p.stageExecutor.ExecutePromoteStage(ctx, cfg, deployTargets, input, lp)
 	case StageCloudRunRollback:
-		return p.stageExecutor.ExecuteRollbackStage(ctx, cfg, deployTargets, input, lp)
+		result, err = p.stageExecutor.ExecuteRollbackStages(ctx, cfg, deployTargets, input, lp)
 	case StageCloudRunCanaryCleanup:
-		return p.stageExecutor.ExecuteCanaryCleanupStage(ctx, cfg, deployTargets, input, lp)
+		result, err = p.stageExecutor.ExecuteCanaryCleanupStage(ctx, cfg, deployTargets, input, lp)
+	case StageCloudRunHold:
+		result, err = p.stageExecutor.ExecuteHoldStage(ctx, cfg, deployTargets, input, lp)
+	case StageCloudRunUpdateTaskQueue:
+		result, err = p.stageExecutor.ExecuteUpdateTaskQueueStage(ctx, cfg, deployTargets, input, lp)
+	case StageCloudRunFaultInjection:
+		result, err = p.stageExecutor.ExecuteFaultInjectionStage(ctx, cfg, deployTargets, input, lp)
+	case StageCloudRunPreviewCleanup:
+		result, err = p.stageExecutor.ExecutePreviewCleanupStage(ctx, cfg, deployTargets, input, lp)
+	case StageCloudRunAnalysis:
+		result, err = p.stageExecutor.ExecuteAnalysisStage(ctx, cfg, deployTargets, input, lp)
+	case StageCloudRunJobSync:
+		result, err = p.stageExecutor.ExecuteJobSyncStage(ctx, cfg, deployTargets, input, lp)
+	case StageCloudRunJobRun:
+		result, err = p.stageExecutor.ExecuteJobRunStage(ctx, cfg, deployTargets, input, lp)
+	case StageCloudRunJobRollback:
+		result, err = p.stageExecutor.ExecuteJobRollbackStage(ctx, cfg, deployTargets, input, lp)
+	case StageCloudRunMirrorVerify:
+		result, err = p.stageExecutor.ExecuteMirrorVerifyStage(ctx, cfg, deployTargets, input, lp)
+	case StageCloudRunDomainVerify:
+		result, err = p.stageExecutor.ExecuteDomainVerifyStage(ctx, cfg, deployTargets, input, lp)
+	case StageCloudRunBackup:
+		result, err = p.stageExecutor.ExecuteBackupStage(ctx, cfg, deployTargets, input, lp)
+	case StageCloudRunRestore:
+		result, err = p.stageExecutor.ExecuteRestoreStage(ctx, cfg, deployTargets, input, lp)
 	default:
+		p.stageExecutor.targets.end(deploymentID, false, time.Now())
 		lp.Errorf("Unsupported stage: %s", input.Request.StageName)
 		return nil, fmt.Errorf("unsupported stage: %s", input.Request.StageName)
 	}
+	// Promote the canary once the last stage passed, if automatic
+	if err == nil {
+		result, err = p.stageExecutor.autoPromote(ctx, cfg, deployTargets, input, lp, result, time.Now())
+	}
+
+	// A rollback, or the final stage succeeding, completes the deployment
+	completed := stageName == StageCloudRunRollback || stageName == StageCloudRunJobRollback ||
+		(err == nil && isFinalStage(input.Request.TargetDeploymentSource.ApplicationConfig.Spec, input.Request.StageName, input.Request.StageIndex))
+	p.stageExecutor.targets.end(deploymentID, completed, time.Now())
+
+	// Surface the structured result to the deployment summary, with the
+	// error details of the Admin API (field violations, quota failures, ...)
+	if err != nil {
+		for _, detail := range cloudrun.ErrorDetails(err) {
+			lp.Errorf("Error detail: %s", detail)
+		}
+		if result.Message == "" {
+			result.Message = cloudrun.DescribeError(err)
+		}
+	}
+	if dryRun {
+		if result.Metadata == nil {
+			result.Metadata = make(map[string]string)
+		}
+		result.Metadata[MetadataKeyDryRun] = "true"
+	}
+	reportStageResult(ctx, input, lp, result)
+	reportDeploymentResult(ctx, cfg, deployTargets, input, lp, result)
+	pushStageMetrics(ctx, cfg, deployTargets, input, lp, result, started)
+	event := StageEventSucceeded
+	if err != nil || result.Status != StageStatusSuccess {
+		event = StageEventFailed
+	}
+	p.stageExecutor.notifyStageHooks(ctx, cfg, deployTargets, input, lp, event, result)
+
+	// Remember the deployment once it completed, for later rollbacks
+	if err == nil && !dryRun {
+		recordLastSuccessfulDeployment(ctx, cfg, deployTargets, input, lp, result)
+	}
+
+	return result.toResponse(), err
 }
 
 // extractVersionFromImage extracts the version from a container image URL.
@@ -248,6 +501,30 @@ func getStageDescription(stageName string) string {
 		return StageDescriptionCloudRunRollback
 	case StageCloudRunCanaryCleanup:
 		return StageDescriptionCloudRunCanaryCleanup
+	case StageCloudRunHold:
+		return StageDescriptionCloudRunHold
+	case StageCloudRunUpdateTaskQueue:
+		return StageDescriptionCloudRunUpdateTaskQueue
+	case StageCloudRunFaultInjection:
+		return StageDescriptionCloudRunFaultInjection
+	case StageCloudRunPreviewCleanup:
+		return StageDescriptionCloudRunPreviewCleanup
+	case StageCloudRunAnalysis:
+		return StageDescriptionCloudRunAnalysis
+	case StageCloudRunJobSync:
+		return StageDescriptionCloudRunJobSync
+	case StageCloudRunJobRun:
+		return StageDescriptionCloudRunJobRun
+	case StageCloudRunJobRollback:
+		return StageDescriptionCloudRunJobRollback
+	case StageCloudRunMirrorVerify:
+		return StageDescriptionCloudRunMirrorVerify
+	case StageCloudRunDomainVerify:
+		return StageDescriptionCloudRunDomainVerify
+	case StageCloudRunBackup:
+		return StageDescriptionCloudRunBackup
+	case StageCloudRunRestore:
+		return StageDescriptionCloudRunRestore
 	default:
 		return "Unknown stage"
 	}
@@ -255,12 +532,261 @@ func getStageDescription(stageName string) string {
 
 // StageExecutor handles the execution of individual deployment stages.
 type StageExecutor struct {
-	// Add any shared dependencies here
+	// targets holds the Cloud Run clients of the deploy targets of running
+	// deployments
+	targets *targetRegistry
+
+	// hooks are notified of the transitions of every stage
+	hooks []StageHook
 }
 
 // NewStageExecutor creates a new StageExecutor.
 func NewStageExecutor() *StageExecutor {
-	return &StageExecutor{}
+	return &StageExecutor{
+		targets: newTargetRegistry(),
+	}
+}
+
+// targetClients returns the Cloud Run client and managers of the deploy
+// target, shared by the stages of the deployment, and the location of the
+// application's service.
+func (e *StageExecutor) targetClients(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	dt *sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+) (*targetClients, error) {
+	clients, err := e.targets.get(ctx, cfg, dt, input.Request.Deployment.ID)
+	if err != nil {
+		return nil, err
+	}
+	clients.service = applicationServiceName(input)
+	return clients, nil
+}
+
+// newCloudRunClient creates a Cloud Run client for the given deploy target,
+// for calls outside of stages. In a dry run, the client logs its write calls
+// instead of making them.
+func newCloudRunClient(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	dt *sdk.DeployTarget[config.DeployTargetConfig],
+) (cloudrun.Client, error) {
+	client, err := newTargetClient(ctx, cfg, dt)
+	if err != nil {
+		return nil, err
+	}
+	if report, ok := cloudrun.DryRun(ctx); ok {
+		return cloudrun.NewDryRunClient(client, report), nil
+	}
+	return client, nil
+}
+
+// newTargetClient creates a Cloud Run client for the given deploy target.
+// Per-call timeouts, rate limits, and the Admin API endpoint are resolved
+// from the deploy target, then the plugin config, then the client defaults.
+func newTargetClient(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	dt *sdk.DeployTarget[config.DeployTargetConfig],
+) (cloudrun.Client, error) {
+	timeouts := cloudrun.DefaultTimeouts()
+	rateLimit := cloudrun.DefaultRateLimit()
+	if cfg != nil {
+		applyAPITimeouts(&timeouts, cfg.APITimeouts)
+		applyRateLimit(&rateLimit, cfg.RateLimit)
+	}
+	applyAPITimeouts(&timeouts, dt.Config.APITimeouts)
+	applyRateLimit(&rateLimit, dt.Config.RateLimit)
+
+	return cloudrun.NewClient(ctx, dt.Config.CredentialsFile,
+		cloudrun.WithTimeouts(timeouts),
+		cloudrun.WithRateLimit(rateLimit),
+		cloudrun.WithCallObserver(targetHealth.observer(dt.Name)),
+		cloudrun.WithEndpoint(apiEndpoint(cfg, dt)),
+	)
+}
+
+// apiEndpoint returns the Admin API endpoint of the deploy target, resolving
+// "regional" to the regional endpoint of the deploy target's region.
+func apiEndpoint(cfg *config.PluginConfig, dt *sdk.DeployTarget[config.DeployTargetConfig]) string {
+	endpoint, region := dt.Config.APIEndpoint, dt.Config.Region
+	if cfg != nil {
+		if endpoint == "" {
+			endpoint = cfg.APIEndpoint
+		}
+		if region == "" {
+			region = cfg.Region
+		}
+	}
+	switch {
+	case endpoint == "":
+		return cloudrun.DefaultEndpoint
+	case endpoint == config.APIEndpointRegional && region != "":
+		return cloudrun.RegionalEndpoint(region)
+	case endpoint == config.APIEndpointRegional:
+		return cloudrun.DefaultEndpoint
+	default:
+		return cloudrun.NormalizeEndpoint(endpoint)
+	}
+}
+
+// applyAPITimeouts overrides timeouts with the non-zero values from the config.
+func applyAPITimeouts(timeouts *cloudrun.Timeouts, c config.APITimeoutConfig) {
+	if c.Get > 0 {
+		timeouts.Get = c.Get.Duration()
+	}
+	if c.List > 0 {
+		timeouts.List = c.List.Duration()
+	}
+	if c.Update > 0 {
+		timeouts.Update = c.Update.Duration()
+	}
+	if c.Delete > 0 {
+		timeouts.Delete = c.Delete.Duration()
+	}
+	if c.OperationInProgress > 0 {
+		timeouts.OperationInProgress = c.OperationInProgress.Duration()
+	}
+	if c.TrafficConvergence > 0 {
+		timeouts.TrafficConvergence = c.TrafficConvergence.Duration()
+	}
+}
+
+// applyRateLimit overrides the rate limit with the non-zero values from the config.
+func applyRateLimit(limit *cloudrun.RateLimit, c config.RateLimitConfig) {
+	if c.QPS > 0 {
+		limit.QPS = c.QPS
+	}
+	if c.Burst > 0 {
+		limit.Burst = c.Burst
+	}
+	if c.MaxRetries > 0 {
+		limit.MaxRetries = c.MaxRetries
+	}
+	if c.InitialBackoff > 0 {
+		limit.InitialBackoff = c.InitialBackoff.Duration()
+	}
+	if c.MaxBackoff > 0 {
+		limit.MaxBackoff = c.MaxBackoff.Duration()
+	}
+}
+
+// evaluateStageCondition evaluates the `when` condition of the stage config.
+func evaluateStageCondition(
+	ctx context.Context,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+) (bool, string, error) {
+	var cond stageConditionConfig
+	if err := parseStageConfig(input.Request.StageConfig, &cond); err != nil {
+		return false, "", fmt.Errorf("failed to parse stage condition: %w", err)
+	}
+	if cond.When == nil {
+		return true, "", nil
+	}
+
+	env := conditionEnv{
+		ImageTag: extractVersionFromImage(input.Request.TargetDeploymentSource.ApplicationConfig.Spec.Input.Image),
+		CommitMessage: func(ctx context.Context) (string, error) {
+			return gitCommitMessage(ctx, input.Request.TargetDeploymentSource.ApplicationDirectory)
+		},
+	}
+	if len(deployTargets) > 0 {
+		env.TargetName = deployTargets[0].Name
+	}
+
+	return cond.When.Evaluate(ctx, env)
+}
+
+// resolveStageConfigSecrets resolves secretRef values in the stage config from
+// GCP Secret Manager.
+func resolveStageConfigSecrets(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	data []byte,
+) ([]byte, error) {
+	if !cloudrun.HasSecretRefs(data) {
+		return data, nil
+	}
+
+	resolver, err := newSecretResolver(ctx, cfg, deployTargets)
+	if err != nil {
+		return nil, err
+	}
+	return resolver.ResolveSecretRefs(ctx, data)
+}
+
+// resolvePluginConfigSecrets resolves secretRef values in the plugin config
+// and the deploy target configs from GCP Secret Manager. The given configs
+// are not modified.
+func resolvePluginConfigSecrets(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+) (*config.PluginConfig, []*sdk.DeployTarget[config.DeployTargetConfig], error) {
+	data, err := json.Marshal(pluginConfigFile{Config: cfg, DeployTargets: deployTargets})
+	if err != nil {
+		return nil, nil, err
+	}
+	if !cloudrun.HasSecretRefs(data) {
+		return cfg, deployTargets, nil
+	}
+
+	resolver, err := newSecretResolver(ctx, cfg, deployTargets)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resolveConfigSecrets(ctx, resolver, data)
+}
+
+// secretRefResolver resolves the secretRef values of an encoded config.
+type secretRefResolver interface {
+	ResolveSecretRefs(ctx context.Context, data []byte) ([]byte, error)
+}
+
+// resolveConfigSecrets resolves the secretRefs of the encoded plugin config
+// file with the resolver.
+func resolveConfigSecrets(
+	ctx context.Context,
+	resolver secretRefResolver,
+	data []byte,
+) (*config.PluginConfig, []*sdk.DeployTarget[config.DeployTargetConfig], error) {
+	resolved, err := resolver.ResolveSecretRefs(ctx, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	var file pluginConfigFile
+	if err := json.Unmarshal(resolved, &file); err != nil {
+		return nil, nil, fmt.Errorf("invalid resolved plugin config: %w", err)
+	}
+	return file.Config, file.DeployTargets, nil
+}
+
+// newSecretResolver creates a Secret Manager resolver. Credentials and the
+// default project are taken from the first deploy target, falling back to
+// the plugin-level configuration.
+func newSecretResolver(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+) (*cloudrun.SecretResolver, error) {
+	var credentialsFile, project string
+	if cfg != nil {
+		credentialsFile = cfg.CredentialsFile
+		project = cfg.ProjectID
+	}
+	if len(deployTargets) > 0 {
+		if deployTargets[0].Config.CredentialsFile != "" {
+			credentialsFile = deployTargets[0].Config.CredentialsFile
+		}
+		if deployTargets[0].Config.ProjectID != "" {
+			project = deployTargets[0].Config.ProjectID
+		}
+	}
+
+	return cloudrun.NewSecretResolver(ctx, credentialsFile, project)
 }
 
 // parseStageConfig parses stage configuration from JSON.
@@ -270,3 +796,13 @@ func parseStageConfig(data []byte, v interface{}) error {
 	}
 	return json.Unmarshal(data, v)
 }
+
+// hasStageConfigField returns true if the stage config sets the given top-level field.
+func hasStageConfigField(data []byte, field string) bool {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return false
+	}
+	_, ok := fields[field]
+	return ok
+}
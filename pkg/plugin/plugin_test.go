@@ -16,13 +16,35 @@ package plugin
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"cloud.google.com/go/iam/apiv1/iampb"
 	"cloud.google.com/go/run/apiv2/runpb"
 	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
 	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/plugin/plugintest"
 )
 
 func TestCloudRunPlugin_FetchDefinedStages(t *testing.T) {
@@ -34,6 +56,18 @@ func TestCloudRunPlugin_FetchDefinedStages(t *testing.T) {
 		StageCloudRunPromote,
 		StageCloudRunRollback,
 		StageCloudRunCanaryCleanup,
+		StageCloudRunHold,
+		StageCloudRunUpdateTaskQueue,
+		StageCloudRunFaultInjection,
+		StageCloudRunPreviewCleanup,
+		StageCloudRunAnalysis,
+		StageCloudRunJobSync,
+		StageCloudRunJobRun,
+		StageCloudRunJobRollback,
+		StageCloudRunMirrorVerify,
+		StageCloudRunDomainVerify,
+		StageCloudRunBackup,
+		StageCloudRunRestore,
 	}
 
 	if len(stages) != len(expected) {
@@ -148,6 +182,19 @@ func TestCloudRunPlugin_BuildPipelineSyncStages(t *testing.T) {
 		if stage.Name != expectedStages[i] {
 			t.Errorf("expected stage %d to be %s, got %s", i, expectedStages[i], stage.Name)
 		}
+		if stage.AvailableOperation != sdk.ManualOperationNone {
+			t.Errorf("expected stage %d to have no manual operation, got %v", i, stage.AvailableOperation)
+		}
+	}
+
+	// Paused promotions can be approved
+	input.Request.Stages[1].Config = []byte(`{"percent":10,"pause":{"timeout":"2h"}}`)
+	resp, err = p.BuildPipelineSyncStages(context.Background(), nil, input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if op := resp.Stages[1].AvailableOperation; op != sdk.ManualOperationApprove {
+		t.Errorf("expected paused promote to be approvable, got %v", op)
 	}
 }
 
@@ -197,6 +244,15 @@ func TestGetStageDescription(t *testing.T) {
 		{StageCloudRunPromote, StageDescriptionCloudRunPromote},
 		{StageCloudRunRollback, StageDescriptionCloudRunRollback},
 		{StageCloudRunCanaryCleanup, StageDescriptionCloudRunCanaryCleanup},
+		{StageCloudRunHold, StageDescriptionCloudRunHold},
+		{StageCloudRunUpdateTaskQueue, StageDescriptionCloudRunUpdateTaskQueue},
+		{StageCloudRunFaultInjection, StageDescriptionCloudRunFaultInjection},
+		{StageCloudRunPreviewCleanup, StageDescriptionCloudRunPreviewCleanup},
+		{StageCloudRunAnalysis, StageDescriptionCloudRunAnalysis},
+		{StageCloudRunJobSync, StageDescriptionCloudRunJobSync},
+		{StageCloudRunJobRun, StageDescriptionCloudRunJobRun},
+		{StageCloudRunJobRollback, StageDescriptionCloudRunJobRollback},
+		{StageCloudRunMirrorVerify, StageDescriptionCloudRunMirrorVerify},
 		{"UNKNOWN_STAGE", "Unknown stage"},
 	}
 
@@ -243,6 +299,22 @@ func TestDefaultStageConfigs(t *testing.T) {
 		if cfg.KeepLatest != true {
 			t.Errorf("expected KeepLatest to be true")
 		}
+		if cfg.DrainWait.Duration() != 30*time.Second {
+			t.Errorf("expected DrainWait to be 30s, got %s", cfg.DrainWait.Duration())
+		}
+		if cfg.WaitForZeroInstances {
+			t.Errorf("expected WaitForZeroInstances to be false")
+		}
+	})
+
+	t.Run("HoldStageConfig", func(t *testing.T) {
+		cfg := DefaultHoldStageConfig()
+		if cfg.Duration.Duration() != 5*time.Minute {
+			t.Errorf("expected Duration to be 5m, got %s", cfg.Duration.Duration())
+		}
+		if cfg.CheckInterval.Duration() != 30*time.Second {
+			t.Errorf("expected CheckInterval to be 30s, got %s", cfg.CheckInterval.Duration())
+		}
 	})
 }
 
@@ -363,6 +435,130 @@ func TestPlanPreview_UpdateService_ImageChange(t *testing.T) {
 	}
 }
 
+func TestWriteManifestChanges(t *testing.T) {
+	dt := plugintest.NewDeployTarget("production", config.DeployTargetConfig{})
+	desired, err := cloudrun.ParseServiceManifest([]byte(plugintest.ServiceManifest("my-service", "gcr.io/p/app:v2")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := applyDeployTargetDefaults(desired, dt, "my-service", "my-project", "us-central1"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		manifest string
+		want     string
+	}{
+		{
+			name:     "image changed",
+			manifest: plugintest.ServiceManifest("my-service", "gcr.io/p/app:v1"),
+			want:     "gcr.io/p/app:v1",
+		},
+		{
+			name:     "no changes",
+			manifest: plugintest.ServiceManifest("my-service", "gcr.io/p/app:v2"),
+			want:     "No manifest changes since the last successful deployment.",
+		},
+		{
+			name:     "invalid manifest",
+			manifest: "{",
+			want:     "Failed to load the recorded service manifest",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var details strings.Builder
+			writeManifestChanges(&details, tt.manifest, desired, dt, "my-service", "my-project", "us-central1")
+			if !strings.Contains(details.String(), tt.want) {
+				t.Errorf("expected %q in the details, got:\n%s", tt.want, details.String())
+			}
+		})
+	}
+}
+
+func TestPlanPreview_ChatSummary(t *testing.T) {
+	// Test the compact one-line-per-change summary
+	current := &runpb.Service{
+		Name: "test-service",
+		Template: &runpb.RevisionTemplate{
+			Containers: []*runpb.Container{
+				{Image: "gcr.io/project/app:v1.0.0"},
+			},
+		},
+		Traffic: []*runpb.TrafficTarget{
+			{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST, Percent: 100},
+		},
+	}
+
+	desired := &runpb.Service{
+		Name: "test-service",
+		Template: &runpb.RevisionTemplate{
+			Containers: []*runpb.Container{
+				{Image: "gcr.io/project/app:v2.0.0"},
+			},
+		},
+		Traffic: []*runpb.TrafficTarget{
+			{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST, Percent: 90},
+			{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "test-service-00001", Percent: 10},
+		},
+	}
+
+	result := generateUpdateServicePlan(current, desired, "test-project", "us-central1", "production")
+
+	lines := strings.Split(result.Summary, "\n")
+	expected := []string{
+		"• image: gcr.io/project/app:v1.0.0 → gcr.io/project/app:v2.0.0",
+		"• traffic: latest 100% → latest 90%, test-service-00001 10%",
+	}
+	if len(lines) != len(expected)+1 {
+		t.Fatalf("expected %d summary lines, got %d: %s", len(expected)+1, len(lines), result.Summary)
+	}
+	for i, want := range expected {
+		if lines[i+1] != want {
+			t.Errorf("expected summary line %q, got %q", want, lines[i+1])
+		}
+	}
+}
+
+func TestPlanPreview_ProtocolChange(t *testing.T) {
+	current := &runpb.Service{
+		Name: "test-service",
+		Template: &runpb.RevisionTemplate{
+			Containers: []*runpb.Container{
+				{Image: "gcr.io/project/app:v1.0.0"},
+			},
+		},
+	}
+
+	desired := &runpb.Service{
+		Name: "test-service",
+		Template: &runpb.RevisionTemplate{
+			Containers: []*runpb.Container{
+				{Image: "gcr.io/project/app:v1.0.0", Ports: []*runpb.ContainerPort{{Name: "h2c", ContainerPort: 50051}}},
+			},
+		},
+	}
+
+	result := generateUpdateServicePlan(current, desired, "test-project", "us-central1", "production")
+
+	if !strings.Contains(result.Summary, "⚠️ protocol: HTTP/1 → HTTP/2 (h2c)") {
+		t.Errorf("expected summary to flag the protocol change, got: %s", result.Summary)
+	}
+	if !strings.Contains(result.Summary, "• port: 8080 → 50051") {
+		t.Errorf("expected summary to contain the port change, got: %s", result.Summary)
+	}
+
+	// Only one serving port can be declared
+	desired.Template.Containers = append(desired.Template.Containers, &runpb.Container{
+		Name:  "sidecar",
+		Ports: []*runpb.ContainerPort{{ContainerPort: 9090}},
+	})
+	if _, err := cloudrun.GetServingPort(desired.Template); err == nil {
+		t.Errorf("expected an error for two serving ports")
+	}
+}
+
 func TestPlanPreview_TrafficChanges(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -464,6 +660,28 @@ func TestPlanPreview_ResourceChanges(t *testing.T) {
 			},
 			expected: true,
 		},
+		{
+			name: "Added GPU limit",
+			current: &runpb.RevisionTemplate{
+				Containers: []*runpb.Container{
+					{
+						Resources: &runpb.ResourceRequirements{
+							Limits: map[string]string{"cpu": "4", "memory": "16Gi"},
+						},
+					},
+				},
+			},
+			desired: &runpb.RevisionTemplate{
+				Containers: []*runpb.Container{
+					{
+						Resources: &runpb.ResourceRequirements{
+							Limits: map[string]string{"cpu": "4", "memory": "16Gi", "nvidia.com/gpu": "1"},
+						},
+					},
+				},
+			},
+			expected: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -526,3 +744,2935 @@ func TestPlanPreview_ScalingChanges(t *testing.T) {
 		})
 	}
 }
+
+func TestStageResult_ToMetadata(t *testing.T) {
+	result := &StageResult{
+		Status:           StageStatusSuccess,
+		Revision:         "my-service-00042",
+		Traffic:          map[string]int32{"LATEST": 10, "my-service-00041": 90},
+		DeletedRevisions: []string{"my-service-00030", "my-service-00031"},
+	}
+
+	metadata := result.ToMetadata()
+
+	expected := map[string]string{
+		MetadataKeyStatus:           "SUCCESS",
+		MetadataKeyRevision:         "my-service-00042",
+		MetadataKeyTraffic:          `{"LATEST":10,"my-service-00041":90}`,
+		MetadataKeyDeletedRevisions: "my-service-00030,my-service-00031",
+	}
+	if len(metadata) != len(expected) {
+		t.Errorf("expected %d metadata entries, got %d: %v", len(expected), len(metadata), metadata)
+	}
+	for k, v := range expected {
+		if metadata[k] != v {
+			t.Errorf("expected metadata %s to be %q, got %q", k, v, metadata[k])
+		}
+	}
+
+	// A later result without traffic clears the traffic of the earlier one
+	// in the deployment metadata, but not the keys it doesn't own
+	later := &StageResult{
+		Status:   StageStatusFailure,
+		Message:  "verification failed",
+		Metadata: map[string]string{MetadataKeyCandidateURL: "https://candidate---my-service.a.run.app"},
+	}
+	expected = map[string]string{
+		MetadataKeyStatus:           "FAILURE",
+		MetadataKeyMessage:          "verification failed",
+		MetadataKeyRevision:         "",
+		MetadataKeyTraffic:          "",
+		MetadataKeyDeletedRevisions: "",
+		MetadataKeyCandidateURL:     "https://candidate---my-service.a.run.app",
+	}
+	if got := later.deploymentMetadata(); !maps.Equal(got, expected) {
+		t.Errorf("expected deployment metadata %v, got %v", expected, got)
+	}
+	if _, ok := later.ToMetadata()[MetadataKeyTraffic]; ok {
+		t.Error("expected the stage metadata to leave unset keys out")
+	}
+
+	if result.toResponse().Status != sdk.StageStatusSuccess {
+		t.Errorf("expected success response")
+	}
+	if (&StageResult{Status: StageStatusCancelled}).toResponse().Status != sdk.StageStatusFailure {
+		t.Errorf("expected cancelled result to be reported as failure")
+	}
+}
+
+func TestApplyDeployTargetDefaults(t *testing.T) {
+	dt := &sdk.DeployTarget[config.DeployTargetConfig]{
+		Name: "production",
+		Config: config.DeployTargetConfig{
+			ServiceDefaults: &config.ServiceDefaultsConfig{
+				Labels:      map[string]string{"team": "platform", "env": "prod"},
+				Description: "{{ .Service }} in {{ .Target }}",
+			},
+		},
+	}
+	service := &runpb.Service{
+		Labels: map[string]string{"env": "staging"},
+	}
+
+	collisions, err := applyDeployTargetDefaults(service, dt, "my-service", "my-project", "us-central1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if service.Labels["team"] != "platform" {
+		t.Errorf("expected team label to be merged, got %q", service.Labels["team"])
+	}
+	if service.Labels["env"] != "staging" {
+		t.Errorf("expected manifest env label to be kept, got %q", service.Labels["env"])
+	}
+	if service.Description != "my-service in production" {
+		t.Errorf("unexpected description: %q", service.Description)
+	}
+	if len(collisions) != 1 || collisions[0].Key != "env" || collisions[0].Default != "prod" {
+		t.Errorf("expected a single env label collision, got %+v", collisions)
+	}
+}
+
+func TestStageCondition_Evaluate(t *testing.T) {
+	env := conditionEnv{
+		TargetName: "production",
+		ImageTag:   "v1.2.3",
+		CommitMessage: func(ctx context.Context) (string, error) {
+			return "Release v1.2.3 [full-rollout]", nil
+		},
+	}
+
+	tests := []struct {
+		name     string
+		cond     *StageCondition
+		expected bool
+	}{
+		{name: "No condition", cond: nil, expected: true},
+		{name: "Target matches", cond: &StageCondition{Targets: []string{"production"}}, expected: true},
+		{name: "Target does not match", cond: &StageCondition{Targets: []string{"staging"}}, expected: false},
+		{name: "Commit message contains", cond: &StageCondition{CommitMessageContains: "[full-rollout]"}, expected: true},
+		{name: "Commit message does not contain", cond: &StageCondition{CommitMessageContains: "[skip]"}, expected: false},
+		{name: "Image tag matches", cond: &StageCondition{ImageTagMatches: `^v\d+\.\d+\.\d+$`}, expected: true},
+		{name: "Image tag does not match", cond: &StageCondition{ImageTagMatches: `-rc\d+$`}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run, reason, err := tt.cond.Evaluate(context.Background(), env)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if run != tt.expected {
+				t.Errorf("expected %v, got %v (reason: %s)", tt.expected, run, reason)
+			}
+			if !run && reason == "" {
+				t.Errorf("expected a reason when the stage is skipped")
+			}
+		})
+	}
+}
+
+func TestHasStageConfigField(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected bool
+	}{
+		{data: ``, expected: false},
+		{data: `{}`, expected: false},
+		{data: `{"candidateTag":"candidate"}`, expected: false},
+		{data: `{"percent":0}`, expected: true},
+		{data: `{"percent":50}`, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			if got := hasStageConfigField([]byte(tt.data), "percent"); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCheckServiceAllowed(t *testing.T) {
+	dt := &sdk.DeployTarget[config.DeployTargetConfig]{
+		Name: "shared",
+		Config: config.DeployTargetConfig{
+			AllowedServices: []string{"payments-*", "billing"},
+			DeniedServices:  []string{"payments-legacy-*"},
+		},
+	}
+
+	tests := []struct {
+		service string
+		allowed bool
+	}{
+		{service: "payments-api", allowed: true},
+		{service: "billing", allowed: true},
+		{service: "billing-worker", allowed: false},
+		{service: "search-api", allowed: false},
+		{service: "payments-legacy-api", allowed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.service, func(t *testing.T) {
+			err := checkServiceAllowed(dt, tt.service)
+			if (err == nil) != tt.allowed {
+				t.Errorf("expected allowed=%v, got error %v", tt.allowed, err)
+			}
+		})
+	}
+}
+
+func TestCheckNamingPolicy(t *testing.T) {
+	dt := &sdk.DeployTarget[config.DeployTargetConfig]{
+		Name: "prod",
+		Config: config.DeployTargetConfig{
+			NamingPolicy: &config.NamingPolicyConfig{
+				ServicePattern:  "payments-[a-z0-9-]+",
+				RevisionPattern: "payments-[a-z0-9-]+-v[0-9]+",
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		service  string
+		revision string
+		wantErr  bool
+	}{
+		{name: "generated revision", service: "payments-api"},
+		{name: "named revision", service: "payments-api", revision: "payments-api-v3"},
+		{name: "invalid service", service: "search-api", wantErr: true},
+		{name: "partial service match", service: "old-payments-api", wantErr: true},
+		{name: "invalid revision", service: "payments-api", revision: "payments-api-canary", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkNamingPolicy(dt, tt.service, tt.revision)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error=%v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestHealthHandler_Readiness(t *testing.T) {
+	registry := newConnectivityRegistry()
+	handler := newHealthHandler(registry)
+	now := time.Now()
+
+	readyz := func() (int, healthResponse) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var resp healthResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		return rec.Code, resp
+	}
+
+	registry.record("staging", nil, now)
+	registry.record("prod", status.Error(codes.NotFound, "service not found"), now)
+	if code, resp := readyz(); code != http.StatusOK || !resp.Ready || len(resp.Targets) != 2 {
+		t.Errorf("expected ready with 2 targets, got %d %+v", code, resp)
+	}
+
+	registry.record("prod", status.Error(codes.Unauthenticated, "invalid credentials"), now.Add(time.Second))
+	code, resp := readyz()
+	if code != http.StatusServiceUnavailable || resp.Ready {
+		t.Errorf("expected not ready, got %d %+v", code, resp)
+	}
+	if resp.Targets["prod"].LastError == "" {
+		t.Errorf("expected the prod error to be reported, got %+v", resp.Targets["prod"])
+	}
+
+	registry.record("prod", nil, now.Add(2*time.Second))
+	if code, _ := readyz(); code != http.StatusOK {
+		t.Errorf("expected ready after a successful call, got %d", code)
+	}
+}
+
+func TestCredentialsWatcher_Watch(t *testing.T) {
+	checked := make(chan string, 10)
+	w := newCredentialsWatcher()
+	w.check = func(_ context.Context, _ *config.PluginConfig, dt *sdk.DeployTarget[config.DeployTargetConfig], project, region string) error {
+		checked <- dt.Name + ":" + project + "/" + region
+		return nil
+	}
+
+	cfg := &config.PluginConfig{ProjectID: "default-project", Region: "us-central1"}
+	targets := []*sdk.DeployTarget[config.DeployTargetConfig]{
+		{Name: "staging"},
+		{Name: "prod", Config: config.DeployTargetConfig{ProjectID: "prod-project", Region: "us-east1"}},
+	}
+	w.watch(cfg, targets)
+	w.watch(cfg, targets)
+
+	got := map[string]bool{}
+	for range 2 {
+		select {
+		case c := <-checked:
+			got[c] = true
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for credentials checks")
+		}
+	}
+	for _, want := range []string{"staging:default-project/us-central1", "prod:prod-project/us-east1"} {
+		if !got[want] {
+			t.Errorf("expected check %s, got %v", want, got)
+		}
+	}
+
+	// Targets are checked once until the interval elapses
+	select {
+	case c := <-checked:
+		t.Errorf("unexpected check %s", c)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestResolveServiceManifestPaths(t *testing.T) {
+	appDir := t.TempDir()
+	for _, path := range []string{"cloudrun/service.yml", "services/b.yaml", "services/a.yaml"} {
+		if err := os.MkdirAll(filepath.Join(appDir, filepath.Dir(path)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(appDir, path), []byte("{}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		cfg        *config.PluginConfig
+		configured []string
+		want       []string
+		wantErr    bool
+	}{
+		{name: "configured path", configured: []string{"manifests/svc.yaml"}, want: []string{"manifests/svc.yaml"}},
+		{name: "default candidates", cfg: &config.PluginConfig{}, want: []string{"cloudrun/service.yml"}},
+		{name: "plugin candidates", cfg: &config.PluginConfig{ServiceManifestCandidates: []string{"deploy/run.yaml"}}, wantErr: true},
+		{name: "glob", configured: []string{"services/*.yaml"}, want: []string{"services/a.yaml", "services/b.yaml"}},
+		{name: "list", configured: []string{"cloudrun/service.yml", "services/*.yaml", "./services/a.yaml"}, want: []string{"cloudrun/service.yml", "services/a.yaml", "services/b.yaml"}},
+		{name: "glob without match", configured: []string{"services/*.yml"}, wantErr: true},
+		{name: "invalid glob", configured: []string{"services/[.yaml"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveServiceManifestPaths(tt.cfg, appDir, tt.configured)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error=%v, got %v", tt.wantErr, err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestManifestPathsUnmarshal(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    config.ManifestPaths
+		wantErr bool
+	}{
+		{name: "string", data: `"service.yaml"`, want: config.ManifestPaths{"service.yaml"}},
+		{name: "empty string", data: `""`},
+		{name: "list", data: `["a.yaml", "services/*.yaml"]`, want: config.ManifestPaths{"a.yaml", "services/*.yaml"}},
+		{name: "empty entry", data: `["a.yaml", ""]`, wantErr: true},
+		{name: "number", data: `1`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got config.ManifestPaths
+			err := json.Unmarshal([]byte(tt.data), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error=%v, got %v", tt.wantErr, err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// fakeMetricReader returns fixed values per query name.
+type fakeMetricReader struct {
+	values map[string]float64
+}
+
+func (r *fakeMetricReader) ReadMetric(_ context.Context, _ cloudrun.MetricScope, q cloudrun.MetricQuery, _ time.Time) (float64, bool, error) {
+	if q.Name == "broken" {
+		return 0, false, errors.New("permission denied")
+	}
+	v, ok := r.values[q.Name]
+	return v, ok, nil
+}
+
+func TestEvaluateAnalysisQueries(t *testing.T) {
+	reader := &fakeMetricReader{values: map[string]float64{
+		"latency": 812,
+		"errors":  3,
+	}}
+	queries := []cloudrun.MetricQuery{
+		{Name: "latency", Metric: "run.googleapis.com/request_latencies", Aggregation: cloudrun.AggregationP95, Operator: cloudrun.OperatorLess, Threshold: 500},
+		{Name: "errors", Metric: "run.googleapis.com/request_count", Aggregation: cloudrun.AggregationSum, Operator: cloudrun.OperatorLessEqual, Threshold: 10},
+		{Name: "idle", Metric: "run.googleapis.com/container/cpu/utilizations", Aggregation: cloudrun.AggregationMean, Operator: cloudrun.OperatorLess, Threshold: 0.8},
+		{Name: "broken", Metric: "run.googleapis.com/request_count", Aggregation: cloudrun.AggregationSum, Operator: cloudrun.OperatorLess, Threshold: 1},
+	}
+
+	results, passed := evaluateAnalysisQueries(context.Background(), reader, cloudrun.MetricScope{}, queries, time.Now())
+	if passed {
+		t.Error("expected the check to fail")
+	}
+	verdicts := make([]string, 0, len(results))
+	for _, r := range results {
+		verdicts = append(verdicts, r.Verdict)
+	}
+	want := []string{analysisVerdictFail, analysisVerdictPass, analysisVerdictNoData, analysisVerdictError}
+	if strings.Join(verdicts, ",") != strings.Join(want, ",") {
+		t.Errorf("expected verdicts %v, got %v", want, verdicts)
+	}
+	if got := failedAnalysisQueries(results); got != "latency: p95 812 < 500: FAIL" {
+		t.Errorf("unexpected failure summary %q", got)
+	}
+
+	report := analysisReport{Checks: 3, Failures: 1, Queries: results}
+	var decoded analysisReport
+	if err := json.Unmarshal([]byte(report.metadata()[MetadataKeyAnalysis]), &decoded); err != nil {
+		t.Fatalf("invalid analysis metadata: %v", err)
+	}
+	if decoded.Queries[0].Value == nil || *decoded.Queries[0].Value != 812 || decoded.Queries[0].Threshold != 500 {
+		t.Errorf("expected the value and threshold in the metadata, got %+v", decoded.Queries[0])
+	}
+}
+
+// fakeMetadataClient is an in-memory metadataClient.
+type fakeMetadataClient struct {
+	mu   sync.Mutex
+	data map[string]string
+}
+
+func (c *fakeMetadataClient) GetDeploymentPluginMetadata(_ context.Context, key string) (string, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.data[key]
+	return v, ok, nil
+}
+
+func (c *fakeMetadataClient) PutDeploymentPluginMetadata(_ context.Context, key, value string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data[key] = value
+	return nil
+}
+
+func TestMetadataStore(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeMetadataClient{data: map[string]string{}}
+	store := newMetadataStore(client, metadataNamespaceSync)
+
+	if err := putMetadataJSON(ctx, store, metadataKeyTrafficSnapshot, map[string]int32{"my-service-00001": 100}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := client.data["sync.trafficSnapshot"]; !ok {
+		t.Errorf("expected key to be namespaced, got %v", client.data)
+	}
+	traffic, ok, err := getMetadataJSON[map[string]int32](ctx, store, metadataKeyTrafficSnapshot)
+	if err != nil || !ok || traffic["my-service-00001"] != 100 {
+		t.Errorf("unexpected snapshot: %v, %v, %v", traffic, ok, err)
+	}
+
+	// Concurrent updates of the same key in the process must not be lost
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := store.Update(ctx, "counter", func(current string, ok bool) (string, error) {
+				n := 0
+				if ok {
+					n, _ = strconv.Atoi(current)
+				}
+				return strconv.Itoa(n + 1), nil
+			})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := client.data["sync.counter"]; got != "10" {
+		t.Errorf("expected 10, got %s", got)
+	}
+}
+
+func TestDeploymentVariables_Interpolate(t *testing.T) {
+	vars := deploymentVariables{
+		DeploymentID: "deployment-1",
+		PRNumber:     "123",
+	}
+
+	out, err := vars.interpolate("service.yaml", []byte(`{"name": "my-svc-pr-{{ .PRNumber }}", "id": "{{ .DeploymentID }}"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := string(out), `{"name": "my-svc-pr-123", "id": "deployment-1"}`; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	if _, err := vars.interpolate("service.yaml", []byte(`{{ .Unknown }}`)); err == nil {
+		t.Error("expected error for unknown variable")
+	}
+
+	if m := prNumberPattern.FindStringSubmatch("Merge pull request #42 from org/branch"); m == nil || m[1] != "42" {
+		t.Errorf("expected PR number 42, got %v", m)
+	}
+}
+
+func TestRollbackTargetRetention(t *testing.T) {
+	now := time.Now()
+	revisions := []*cloudrun.RevisionInfo{
+		{Name: "my-service-00002", CreatedAt: now.Add(-time.Hour)},
+		{Name: "my-service-00001", CreatedAt: now.Add(-48 * time.Hour)},
+	}
+
+	until, ok := rollbackTargetRetention("my-service-00001", revisions, 24*time.Hour, now)
+	if !ok || !until.Equal(revisions[0].CreatedAt.Add(24*time.Hour)) {
+		t.Errorf("expected rollback target to be kept, got %v, %v", until, ok)
+	}
+	if _, ok := rollbackTargetRetention("my-service-00001", revisions, 30*time.Minute, now); ok {
+		t.Error("expected retention to have expired")
+	}
+	if _, ok := rollbackTargetRetention("my-service-00002", revisions, 24*time.Hour, now); ok {
+		t.Error("expected the newest revision not to be a rollback target")
+	}
+}
+
+func TestJobStageName(t *testing.T) {
+	app := &config.ApplicationConfig{}
+	job := &config.ApplicationConfig{Kind: config.KindCloudRunJob}
+
+	tests := []struct {
+		spec     *config.ApplicationConfig
+		stage    string
+		expected string
+	}{
+		{app, StageCloudRunSync, StageCloudRunSync},
+		{app, StageCloudRunRollback, StageCloudRunRollback},
+		{job, StageCloudRunSync, StageCloudRunJobSync},
+		{job, StageCloudRunRollback, StageCloudRunJobRollback},
+		{job, StageCloudRunJobRun, StageCloudRunJobRun},
+	}
+	for _, tt := range tests {
+		if got := jobStageName(tt.spec, tt.stage); got != tt.expected {
+			t.Errorf("jobStageName(%q, %s) = %s, want %s", tt.spec.Kind, tt.stage, got, tt.expected)
+		}
+	}
+}
+
+func TestLoadJobManifest(t *testing.T) {
+	input := plugintest.NewExecuteStageInput(t, plugintest.StageInput{
+		StageName: StageCloudRunJobSync,
+		Spec:      &config.ApplicationConfig{Kind: config.KindCloudRunJob},
+		Files: map[string]string{
+			"cloudrun/job.yaml": plugintest.JobManifest("report-{{ .DeploymentID }}", "gcr.io/my-project/report:v1"),
+		},
+	})
+
+	job, path, err := loadJobManifest(context.Background(), nil, input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := filepath.Join(input.Request.TargetDeploymentSource.ApplicationDirectory, "cloudrun/job.yaml"); path != want {
+		t.Errorf("expected manifest %s, got %s", want, path)
+	}
+	if want := "report-" + plugintest.DefaultDeploymentID; job.Name != want {
+		t.Errorf("expected job name %s, got %s", want, job.Name)
+	}
+}
+
+func TestRemainingBudget(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	remaining, err := remainingBudget(start, 45*time.Minute, 10*time.Minute, start.Add(30*time.Minute))
+	if err != nil || remaining != 15*time.Minute {
+		t.Errorf("expected 15m remaining, got %s, %v", remaining, err)
+	}
+
+	_, err = remainingBudget(start, 45*time.Minute, 20*time.Minute, start.Add(30*time.Minute))
+	if err == nil || !strings.Contains(err.Error(), "insufficient time remaining") {
+		t.Errorf("expected insufficient time error, got %v", err)
+	}
+
+	if _, err := remainingBudget(start, 45*time.Minute, 0, start.Add(time.Hour)); err == nil {
+		t.Error("expected error for an exhausted budget")
+	}
+}
+
+func TestFailoverRegion(t *testing.T) {
+	outage := status.Error(codes.Unavailable, "service unavailable")
+	withFailover := &sdk.DeployTarget[config.DeployTargetConfig]{
+		Config: config.DeployTargetConfig{Failover: &config.FailoverConfig{Region: "us-east1"}},
+	}
+
+	if got := failoverRegion(withFailover, "us-central1", outage); got != "us-east1" {
+		t.Errorf("expected failover to us-east1, got %q", got)
+	}
+	if got := failoverRegion(withFailover, "us-central1", status.Error(codes.InvalidArgument, "bad manifest")); got != "" {
+		t.Errorf("expected no failover for a request error, got %q", got)
+	}
+	if got := failoverRegion(withFailover, "us-east1", outage); got != "" {
+		t.Errorf("expected no failover to the failed region, got %q", got)
+	}
+	if got := failoverRegion(plugintest.NewDeployTarget("prod", config.DeployTargetConfig{}), "us-central1", outage); got != "" {
+		t.Errorf("expected no failover without a secondary region, got %q", got)
+	}
+}
+
+func TestMirrorTraffic(t *testing.T) {
+	traffic := []*runpb.TrafficTarget{
+		{
+			Type:     runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION,
+			Revision: "my-service-00001",
+			Percent:  90,
+			Tag:      "stable",
+		},
+		{
+			Type:     runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION,
+			Revision: "my-service-00001",
+			Percent:  10,
+			Tag:      "candidate",
+		},
+	}
+
+	got, tagged := mirrorTraffic(traffic, map[string]string{"stable": "my-service-00001", "candidate": "my-service-00002"})
+	if !tagged {
+		t.Fatal("expected the candidate tag to be added")
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 traffic targets, got %d", len(got))
+	}
+	if got[0].Tag != "stable" || got[1].Tag != "" || got[1].Percent != 10 {
+		t.Errorf("expected the stale candidate tag to be removed, got %v", got[:2])
+	}
+	if got[2].Revision != "my-service-00002" || got[2].Tag != "candidate" || got[2].Percent != 0 {
+		t.Errorf("unexpected candidate target: %v", got[2])
+	}
+	if traffic[1].Tag != "candidate" {
+		t.Error("expected the original traffic to be unchanged")
+	}
+
+	if _, tagged := mirrorTraffic(got, map[string]string{"stable": "my-service-00001", "candidate": "my-service-00002"}); tagged {
+		t.Error("expected no change when both tags point to their revisions")
+	}
+}
+
+func TestPlanPreview_SecurityChanges(t *testing.T) {
+	live := &runpb.Service{
+		Template: &runpb.RevisionTemplate{
+			ExecutionEnvironment: runpb.ExecutionEnvironment_EXECUTION_ENVIRONMENT_GEN1,
+			ServiceAccount:       "123-compute@developer.gserviceaccount.com",
+		},
+	}
+
+	// The default service account set by Cloud Run is not drift
+	desired := &runpb.Service{
+		Template: &runpb.RevisionTemplate{
+			ExecutionEnvironment: runpb.ExecutionEnvironment_EXECUTION_ENVIRONMENT_GEN1,
+		},
+	}
+	var details strings.Builder
+	if changes, _ := writeServiceDiff(&details, live, desired); len(changes) != 0 {
+		t.Errorf("expected no changes, got %v", changes)
+	}
+
+	desired.Template.ExecutionEnvironment = runpb.ExecutionEnvironment_EXECUTION_ENVIRONMENT_GEN2
+	desired.Template.EncryptionKey = "projects/p/locations/us/keyRings/r/cryptoKeys/k"
+	changes, summaryLines := writeServiceDiff(&details, live, desired)
+	if !slices.Contains(changes, "security settings") {
+		t.Fatalf("expected security settings to change, got %v", changes)
+	}
+	for _, want := range []string{
+		"  - executionEnvironment: EXECUTION_ENVIRONMENT_GEN1\n",
+		"  + executionEnvironment: EXECUTION_ENVIRONMENT_GEN2\n",
+		"  + encryptionKey: projects/p/locations/us/keyRings/r/cryptoKeys/k\n",
+	} {
+		if !strings.Contains(details.String(), want) {
+			t.Errorf("expected details to contain %q, got:\n%s", want, details.String())
+		}
+	}
+	if strings.Contains(details.String(), "serviceAccount") {
+		t.Errorf("expected the undeclared service account not to be compared, got:\n%s", details.String())
+	}
+	if want := "• security: executionEnvironment=EXECUTION_ENVIRONMENT_GEN1 → encryptionKey=projects/p/locations/us/keyRings/r/cryptoKeys/k executionEnvironment=EXECUTION_ENVIRONMENT_GEN2"; !slices.Contains(summaryLines, want) {
+		t.Errorf("expected summary line %q, got %v", want, summaryLines)
+	}
+}
+
+func TestCheckStageAllowed(t *testing.T) {
+	dt := &sdk.DeployTarget[config.DeployTargetConfig]{
+		Name: "traffic-only",
+		Config: config.DeployTargetConfig{
+			AllowedStages: []string{StageCloudRunPromote, StageCloudRunRollback, StageCloudRunJobSync},
+		},
+	}
+
+	tests := []struct {
+		stage    string
+		executed string
+		allowed  bool
+	}{
+		{stage: StageCloudRunPromote, executed: StageCloudRunPromote, allowed: true},
+		{stage: StageCloudRunRollback, executed: StageCloudRunRollback, allowed: true},
+		{stage: StageCloudRunSync, executed: StageCloudRunSync, allowed: false},
+		{stage: StageCloudRunSync, executed: StageCloudRunJobSync, allowed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.stage+"/"+tt.executed, func(t *testing.T) {
+			err := checkStageAllowed(dt, tt.stage, tt.executed)
+			if (err == nil) != tt.allowed {
+				t.Errorf("expected allowed=%v, got error %v", tt.allowed, err)
+			}
+		})
+	}
+
+	if err := checkStageAllowed(plugintest.NewDeployTarget("any", config.DeployTargetConfig{}), StageCloudRunSync, StageCloudRunSync); err != nil {
+		t.Errorf("expected every stage to be allowed without allowedStages, got %v", err)
+	}
+}
+
+func TestRecordLastSuccessfulDeployment(t *testing.T) {
+	pipeline := &config.ApplicationConfig{
+		PipelineSync: &config.PipelineSyncConfig{
+			Stages: []config.PipelineStage{{Name: StageCloudRunSync}, {Name: StageCloudRunPromote}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		spec     *config.ApplicationConfig
+		stage    string
+		index    int
+		result   *StageResult
+		revision string
+	}{
+		{
+			name:     "quick sync",
+			spec:     &config.ApplicationConfig{},
+			stage:    StageCloudRunSync,
+			result:   &StageResult{Status: StageStatusSuccess, Revision: "my-service-00002", Traffic: map[string]int32{trafficKeyLatest: 100}},
+			revision: "my-service-00002",
+		},
+		{
+			name:     "last pipeline stage",
+			spec:     pipeline,
+			stage:    StageCloudRunPromote,
+			index:    1,
+			result:   &StageResult{Status: StageStatusSuccess, Revision: "my-service-00002", Traffic: map[string]int32{"my-service-00002": 100}},
+			revision: "my-service-00002",
+		},
+		{
+			name:   "intermediate pipeline stage",
+			spec:   pipeline,
+			stage:  StageCloudRunSync,
+			result: &StageResult{Status: StageStatusSuccess, Revision: "my-service-00002", Traffic: map[string]int32{trafficKeyLatest: 100}},
+		},
+		{
+			name:   "split traffic",
+			spec:   pipeline,
+			stage:  StageCloudRunPromote,
+			index:  1,
+			result: &StageResult{Status: StageStatusSuccess, Revision: "my-service-00002", Traffic: map[string]int32{"my-service-00001": 90, "my-service-00002": 10}},
+		},
+		{
+			name:   "rollback",
+			spec:   &config.ApplicationConfig{},
+			stage:  StageCloudRunRollback,
+			result: &StageResult{Status: StageStatusSuccess, Revision: "my-service-00001", Traffic: map[string]int32{"my-service-00001": 100}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var revision string
+			if isFinalStage(tt.spec, tt.stage, tt.index) {
+				revision = servingRevision(tt.result)
+			}
+			if revision != tt.revision {
+				t.Errorf("expected revision %q, got %q", tt.revision, revision)
+			}
+		})
+	}
+}
+
+type fakePauseClient struct {
+	commander string
+	metadata  map[string]string
+}
+
+func (c *fakePauseClient) ListStageCommands(ctx context.Context, _ ...sdk.CommandType) iter.Seq2[*sdk.StageCommand, error] {
+	return func(yield func(*sdk.StageCommand, error) bool) {
+		if c.commander == "" {
+			<-ctx.Done()
+			return
+		}
+		yield(&sdk.StageCommand{Commander: c.commander, Type: sdk.CommandTypeApproveStage}, nil)
+	}
+}
+
+func (c *fakePauseClient) PutStageMetadata(_ context.Context, key, value string) error {
+	c.metadata[key] = value
+	return nil
+}
+
+func TestPauseProgression(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("approved", func(t *testing.T) {
+		client := &fakePauseClient{commander: "alice", metadata: map[string]string{}}
+		resumedBy, err := pauseProgression(ctx, client, &PauseConfig{}, 10, &plugintest.LogRecorder{})
+		if err != nil || resumedBy != "alice" {
+			t.Fatalf("expected resumed by alice, got %q, %v", resumedBy, err)
+		}
+		if client.metadata[stageMetadataKeyApprovedUsers] != "alice" {
+			t.Errorf("expected approver to be recorded, got %v", client.metadata)
+		}
+	})
+
+	t.Run("abort on timeout", func(t *testing.T) {
+		client := &fakePauseClient{metadata: map[string]string{}}
+		pauseCfg := &PauseConfig{Timeout: config.Duration(10 * time.Millisecond)}
+		if _, err := pauseProgression(ctx, client, pauseCfg, 10, &plugintest.LogRecorder{}); err == nil {
+			t.Error("expected the pause to abort on timeout")
+		}
+	})
+
+	t.Run("resume on timeout", func(t *testing.T) {
+		client := &fakePauseClient{metadata: map[string]string{}}
+		pauseCfg := &PauseConfig{Timeout: config.Duration(10 * time.Millisecond), OnTimeout: PauseOnTimeoutResume}
+		resumedBy, err := pauseProgression(ctx, client, pauseCfg, 10, &plugintest.LogRecorder{})
+		if err != nil || resumedBy != "" {
+			t.Errorf("expected the pause to resume on timeout, got %q, %v", resumedBy, err)
+		}
+	})
+
+	t.Run("invalid onTimeout", func(t *testing.T) {
+		client := &fakePauseClient{metadata: map[string]string{}}
+		if _, err := pauseProgression(ctx, client, &PauseConfig{OnTimeout: "wait"}, 10, &plugintest.LogRecorder{}); err == nil {
+			t.Error("expected an error for an invalid onTimeout")
+		}
+	})
+}
+
+func TestComputeChangeScore(t *testing.T) {
+	live := &runpb.Service{
+		Template: &runpb.RevisionTemplate{
+			Containers: []*runpb.Container{{
+				Image: "gcr.io/p/app:v1",
+				Env: []*runpb.EnvVar{
+					{Name: "A", Values: &runpb.EnvVar_Value{Value: "1"}},
+					{Name: "B", Values: &runpb.EnvVar_Value{Value: "2"}},
+				},
+			}},
+			Scaling: &runpb.RevisionScaling{MinInstanceCount: 2, MaxInstanceCount: 10},
+		},
+	}
+	desired := &runpb.Service{
+		Template: &runpb.RevisionTemplate{
+			Containers: []*runpb.Container{{
+				Image: "gcr.io/p/app:v2",
+				Env: []*runpb.EnvVar{
+					{Name: "A", Values: &runpb.EnvVar_Value{Value: "changed"}},
+					{Name: "C", Values: &runpb.EnvVar_Value{Value: "3"}},
+				},
+			}},
+			Scaling: &runpb.RevisionScaling{MinInstanceCount: 3, MaxInstanceCount: 10},
+		},
+	}
+
+	// Image 10, min instances +50% is 5 steps, env A changed, B removed, C added
+	score := computeChangeScore(live, desired)
+	expected := changeScore{Images: 10, Scaling: 5, Env: 3}
+	if score != expected {
+		t.Errorf("expected %s, got %s", expected, score)
+	}
+
+	if score := computeChangeScore(live, live); score.Total() != 0 {
+		t.Errorf("expected no change to score 0, got %s", score)
+	}
+}
+
+func TestCheckChangeBudget(t *testing.T) {
+	dt := plugintest.NewDeployTarget("prod", config.DeployTargetConfig{
+		ChangeBudget: &config.ChangeBudgetConfig{MaxScore: 15},
+	})
+	large := changeScore{Images: 10, Env: 6}
+
+	if err := checkChangeBudget(dt, changeScore{Images: 10}, ""); err != nil {
+		t.Errorf("expected a change within the budget to be allowed, got %v", err)
+	}
+	if err := checkChangeBudget(dt, large, ""); err == nil {
+		t.Error("expected a change over the budget to be rejected")
+	}
+	if err := checkChangeBudget(dt, large, "allowLargeChange in the application config"); err != nil {
+		t.Errorf("expected an overridden change to be allowed, got %v", err)
+	}
+	if err := checkChangeBudget(plugintest.NewDeployTarget("dev", config.DeployTargetConfig{}), large, ""); err != nil {
+		t.Errorf("expected targets without a budget to allow any change, got %v", err)
+	}
+}
+
+func TestResolveInputImage(t *testing.T) {
+	appDir := t.TempDir()
+	files := map[string]string{
+		".artifact": "# built by CI\n\ngcr.io/my-project/app@sha256:abc\n",
+		"empty":     "# nothing yet\n",
+		"invalid":   "gcr.io/my-project/app v2\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(appDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		input   config.InputConfig
+		want    string
+		wantErr bool
+	}{
+		{name: "no image file", input: config.InputConfig{Image: "gcr.io/my-project/app:v1"}, want: "gcr.io/my-project/app:v1"},
+		{name: "image file", input: config.InputConfig{ImageFile: ".artifact"}, want: "gcr.io/my-project/app@sha256:abc"},
+		{name: "image and image file", input: config.InputConfig{Image: "gcr.io/my-project/app:v1", ImageFile: ".artifact"}, wantErr: true},
+		{name: "missing file", input: config.InputConfig{ImageFile: "missing"}, wantErr: true},
+		{name: "no image", input: config.InputConfig{ImageFile: "empty"}, wantErr: true},
+		{name: "invalid image", input: config.InputConfig{ImageFile: "invalid"}, wantErr: true},
+		{name: "outside the application directory", input: config.InputConfig{ImageFile: "../.artifact"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := tt.input
+			err := resolveInputImage(appDir, &input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveInputImage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && input.Image != tt.want {
+				t.Errorf("image = %q, want %q", input.Image, tt.want)
+			}
+		})
+	}
+}
+
+func TestStyleText(t *testing.T) {
+	text := "📝 Service 'my-service' will be updated (image)\n• image: app:v1 → app:v2\n\n📦 Container Image:\n  ⚠️ Protocol changes"
+
+	tests := []struct {
+		style    string
+		expected string
+	}{
+		{style: config.OutputStyleEmoji, expected: text},
+		{style: config.OutputStylePlain, expected: "Service 'my-service' will be updated (image)\n- image: app:v1 -> app:v2\n\nContainer Image:\n  [!] Protocol changes"},
+		{style: config.OutputStyleMarkdown, expected: "Service 'my-service' will be updated (image)\n- image: app:v1 -> app:v2\n\n#### Container Image\n  [!] Protocol changes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			if got := styleText(tt.style, text); got != tt.expected {
+				t.Errorf("styleText() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+
+	if style, err := outputStyle(&config.PluginConfig{}); err != nil || style != config.OutputStyleEmoji {
+		t.Errorf("outputStyle() = %q, %v, expected the emoji default", style, err)
+	}
+	if _, err := outputStyle(&config.PluginConfig{OutputStyle: "ascii"}); err == nil {
+		t.Error("expected an unsupported output style to be rejected")
+	}
+}
+
+func TestIdleCostIncrease(t *testing.T) {
+	service := func(minInstances int32, cpuIdle bool) *runpb.Service {
+		return &runpb.Service{
+			Template: &runpb.RevisionTemplate{
+				Scaling: &runpb.RevisionScaling{MinInstanceCount: minInstances},
+				Containers: []*runpb.Container{
+					{Resources: &runpb.ResourceRequirements{Limits: map[string]string{"cpu": "1", "memory": "512Mi"}, CpuIdle: cpuIdle}},
+				},
+			},
+		}
+	}
+
+	if _, increased, err := computeIdleCostIncrease(service(1, true), service(1, true)); err != nil || increased {
+		t.Errorf("expected no increase for an unchanged service, got %v, %v", increased, err)
+	}
+	if _, increased, _ := computeIdleCostIncrease(service(2, false), service(1, false)); increased {
+		t.Error("expected no increase for fewer minimum instances")
+	}
+	if _, increased, _ := computeIdleCostIncrease(nil, service(0, false)); increased {
+		t.Error("expected no increase for a new service without minimum instances")
+	}
+
+	// Always-on CPU bills the idle instance at the full rate
+	c, increased, err := computeIdleCostIncrease(service(1, true), service(1, false))
+	if err != nil || !increased {
+		t.Fatalf("expected an increase for always-on CPU, got %v, %v", increased, err)
+	}
+
+	prod := plugintest.NewDeployTarget("prod", config.DeployTargetConfig{Production: true})
+	if err := checkIdleCost(prod, c, ""); err == nil {
+		t.Error("expected an unconfirmed increase on production to be rejected")
+	}
+	if err := checkIdleCost(prod, c, "confirmIdleCost in the application config"); err != nil {
+		t.Errorf("expected a confirmed increase to be allowed, got %v", err)
+	}
+	if err := checkIdleCost(plugintest.NewDeployTarget("dev", config.DeployTargetConfig{}), c, ""); err != nil {
+		t.Errorf("expected non-production targets to allow the increase, got %v", err)
+	}
+
+	var details strings.Builder
+	writeIdleCostIncrease(&details, c)
+	if !strings.Contains(details.String(), "+$") {
+		t.Errorf("expected the estimated increase in the details, got %q", details.String())
+	}
+}
+
+func TestAnalysisSampleCount(t *testing.T) {
+	stageCfg := DefaultAnalysisStageConfig()
+	stageCfg.Duration = config.Duration(10 * time.Minute)
+	stageCfg.Queries = []AnalysisQueryConfig{{Metric: "run.googleapis.com/request_latencies", Operator: "<", Threshold: 500}}
+	stageCfg.MinSampleCount = 100
+	if _, err := analysisQueries(stageCfg); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+	stageCfg.InsufficientTraffic = "retry"
+	if _, err := analysisQueries(stageCfg); err == nil {
+		t.Error("expected error for an unsupported insufficientTraffic")
+	}
+
+	start := time.Now().Add(-5 * time.Minute)
+	var report analysisReport
+	reader := &fakeMetricReader{values: map[string]float64{sampleCountQuery.Name: 42}}
+	if report.countSamples(context.Background(), reader, cloudrun.MetricScope{}, start, 100, &plugintest.LogRecorder{}) {
+		t.Error("expected 42 requests not to reach the minimum of 100")
+	}
+	if report.Samples == nil || *report.Samples != 42 {
+		t.Errorf("expected 42 samples in the report, got %v", report.Samples)
+	}
+	if !report.countSamples(context.Background(), reader, cloudrun.MetricScope{}, start, 42, &plugintest.LogRecorder{}) {
+		t.Error("expected 42 requests to reach the minimum of 42")
+	}
+
+	// A revision without traffic has no data
+	if report.countSamples(context.Background(), &fakeMetricReader{}, cloudrun.MetricScope{}, start, 1, &plugintest.LogRecorder{}) || *report.Samples != 0 {
+		t.Errorf("expected no data to count as 0 requests, got %v", *report.Samples)
+	}
+}
+
+func TestJobLivestate(t *testing.T) {
+	desired := &runpb.Job{
+		Template: &runpb.ExecutionTemplate{
+			TaskCount: 3,
+			Template: &runpb.TaskTemplate{
+				Containers: []*runpb.Container{{
+					Image: "gcr.io/my-project/report:v2",
+					Env:   []*runpb.EnvVar{{Name: "MODE", Values: &runpb.EnvVar_Value{Value: "full"}}},
+				}},
+			},
+		},
+	}
+	live := &runpb.Job{
+		Name: "projects/my-project/locations/us-central1/jobs/nightly-report",
+		Template: &runpb.ExecutionTemplate{
+			TaskCount:   3,
+			Parallelism: 1,
+			Template: &runpb.TaskTemplate{
+				Containers: []*runpb.Container{{
+					Image: "gcr.io/my-project/report:v2",
+					Env:   []*runpb.EnvVar{{Name: "MODE", Values: &runpb.EnvVar_Value{Value: "full"}}},
+				}},
+				Timeout: durationpb.New(10 * time.Minute),
+			},
+		},
+		TerminalCondition: &runpb.Condition{Type: "Ready", State: runpb.Condition_CONDITION_SUCCEEDED},
+	}
+
+	// Server defaults not set in the manifest aren't drift
+	if state := calculateJobSyncState(live, desired, nil); state.Status != sdk.ApplicationSyncStateSynced {
+		t.Errorf("expected synced, got %v: %s", state.Status, state.Reason)
+	}
+
+	live.Template.Template.Containers[0].Image = "gcr.io/my-project/report:v1"
+	live.Template.Template.Containers[0].Env = append(live.Template.Template.Containers[0].Env, &runpb.EnvVar{Name: "DEBUG", Values: &runpb.EnvVar_Value{Value: "1"}})
+	state := calculateJobSyncState(live, desired, nil)
+	if state.Status != sdk.ApplicationSyncStateOutOfSync || state.ShortReason != "Changed: env.DEBUG, image" {
+		t.Errorf("expected drift of env.DEBUG and image, got %v: %s", state.Status, state.ShortReason)
+	}
+
+	start := time.Date(2025, 1, 1, 3, 0, 0, 0, time.UTC)
+	failed := &runpb.Execution{
+		Name:           live.Name + "/executions/nightly-report-b2",
+		CreateTime:     timestamppb.New(start.Add(24 * time.Hour)),
+		StartTime:      timestamppb.New(start.Add(24 * time.Hour)),
+		CompletionTime: timestamppb.New(start.Add(24*time.Hour + 90*time.Second)),
+		TaskCount:      3,
+		SucceededCount: 2,
+		FailedCount:    1,
+	}
+	succeeded := &runpb.Execution{
+		Name:           live.Name + "/executions/nightly-report-a1",
+		CreateTime:     timestamppb.New(start),
+		StartTime:      timestamppb.New(start),
+		CompletionTime: timestamppb.New(start.Add(2 * time.Minute)),
+		TaskCount:      3,
+		SucceededCount: 3,
+	}
+	executions := []*runpb.Execution{succeeded, failed}
+	cloudrun.SortExecutions(executions)
+
+	jobState, err := buildJobResourceState(live, executions, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobState.Name != "nightly-report" || jobState.HealthStatus != sdk.ResourceHealthStateUnhealthy || jobState.HealthDescription != "Latest execution nightly-report-b2 failed" {
+		t.Errorf("expected the job to be unhealthy after a failed execution, got %+v", jobState)
+	}
+
+	now := start.Add(48 * time.Hour)
+	if s := buildExecutionResourceState(failed, jobState.ID, "prod", now); s.HealthStatus != sdk.ResourceHealthStateUnhealthy || s.ResourceMetadata["duration"] != "1m30s" {
+		t.Errorf("unexpected failed execution state %+v", s)
+	}
+	if s := buildExecutionResourceState(succeeded, jobState.ID, "prod", now); s.HealthStatus != sdk.ResourceHealthStateHealthy || s.HealthDescription != "Succeeded in 2m0s" || s.ResourceMetadata["tasks"] != "3/3 succeeded" {
+		t.Errorf("unexpected succeeded execution state %+v", s)
+	}
+	running := &runpb.Execution{Name: live.Name + "/executions/nightly-report-c3", StartTime: timestamppb.New(now.Add(-time.Minute)), TaskCount: 3, RunningCount: 3}
+	if s := buildExecutionResourceState(running, jobState.ID, "prod", now); s.HealthStatus != sdk.ResourceHealthStateUnknown || !strings.HasPrefix(s.HealthDescription, "Running for 1m0s") {
+		t.Errorf("unexpected running execution state %+v", s)
+	}
+}
+
+// fakeApplicationObjectClient is an in-memory applicationObjectClient.
+type fakeApplicationObjectClient struct {
+	objects map[string][]byte
+}
+
+func (c *fakeApplicationObjectClient) GetApplicationSharedObject(_ context.Context, key string) ([]byte, bool, error) {
+	data, ok := c.objects[key]
+	return data, ok, nil
+}
+
+func (c *fakeApplicationObjectClient) PutApplicationSharedObject(_ context.Context, key string, object []byte) error {
+	c.objects[key] = object
+	return nil
+}
+
+func TestTrafficHistory(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeApplicationObjectClient{objects: make(map[string][]byte)}
+	lp := &plugintest.LogRecorder{}
+
+	if history, err := getTrafficHistory(ctx, client); err != nil || history != nil {
+		t.Fatalf("expected no history, got %v, %v", history, err)
+	}
+
+	svc := &runpb.Service{TrafficStatuses: []*runpb.TrafficTargetStatus{
+		{Revision: "my-service-00002", Percent: 90},
+		{Revision: "my-service-00001", Percent: 10},
+		{Revision: "my-service-00003", Percent: 0, Tag: "candidate"},
+	}}
+	recordTrafficHistory(ctx, client, servingRevisions(svc), lp)
+	recordTrafficHistory(ctx, client, []string{"my-service-00003", "my-service-00001"}, lp)
+
+	history, err := getTrafficHistory(ctx, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"my-service-00003", "my-service-00001", "my-service-00002"}
+	if !slices.Equal(history, expected) {
+		t.Errorf("expected %v, got %v", expected, history)
+	}
+
+	for i := 0; i < maxTrafficHistory+5; i++ {
+		recordTrafficHistory(ctx, client, []string{fmt.Sprintf("my-service-%05d", 100+i)}, lp)
+	}
+	if history, _ := getTrafficHistory(ctx, client); len(history) != maxTrafficHistory || history[0] != fmt.Sprintf("my-service-%05d", 100+maxTrafficHistory+4) {
+		t.Errorf("expected the %d most recent revisions, got %d starting with %s", maxTrafficHistory, len(history), history[0])
+	}
+}
+
+func TestConfigReload(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key.json")
+	if err := os.WriteFile(keyFile, []byte("{}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "plugin.yaml")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checked := make(chan string, 10)
+	credentials := &credentialsWatcher{
+		check: func(_ context.Context, _ *config.PluginConfig, dt *sdk.DeployTarget[config.DeployTargetConfig], _, _ string) error {
+			checked <- dt.Name + ":" + dt.Config.ProjectID
+			return nil
+		},
+		watched: make(map[string]chan struct{}),
+	}
+	r := newConfigReloader(credentials)
+
+	given := &config.PluginConfig{Region: "us-central1"}
+	targets := []*sdk.DeployTarget[config.DeployTargetConfig]{
+		{Name: "production", Config: config.DeployTargetConfig{ProjectID: "old-project"}},
+		{Name: "staging", Config: config.DeployTargetConfig{ProjectID: "staging-project"}},
+	}
+	if cfg, dts := r.resolve(given, targets); cfg != given || dts[0] != targets[0] {
+		t.Fatal("expected the given config before a reload")
+	}
+
+	write(fmt.Sprintf(`
+config:
+  region: us-east1
+deployTargets:
+  - name: production
+    config:
+      projectID: new-project
+      credentialsFile: %s
+`, keyFile))
+	if err := r.load(path); err != nil {
+		t.Fatal(err)
+	}
+	cfg, dts := r.resolve(given, targets)
+	if cfg.Region != "us-east1" {
+		t.Errorf("expected the reloaded region, got %q", cfg.Region)
+	}
+	if dts[0].Config.ProjectID != "new-project" || dts[1] != targets[1] {
+		t.Errorf("expected the reloaded production target and the given staging target, got %s and %s", dts[0].Config.ProjectID, dts[1].Config.ProjectID)
+	}
+	select {
+	case name := <-checked:
+		if name != "production:new-project" {
+			t.Errorf("expected the reloaded target to be validated, got %s", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected the credentials of the reloaded target to be validated")
+	}
+
+	// Invalid files keep the current config
+	write(`
+deployTargets:
+  - name: production
+    config:
+      credentialsFile: /nonexistent/key.json
+`)
+	if err := r.load(path); err == nil || !strings.Contains(err.Error(), "credentials file") {
+		t.Errorf("expected a credentials file error, got %v", err)
+	}
+	write(`
+config:
+  outputStyle: fancy
+`)
+	if err := r.load(path); err == nil {
+		t.Error("expected an output style error")
+	}
+	if cfg, dts := r.resolve(given, targets); cfg.Region != "us-east1" || dts[0].Config.ProjectID != "new-project" {
+		t.Error("expected the previous config to be kept")
+	}
+}
+
+func TestDeploymentResult(t *testing.T) {
+	spec := &config.ApplicationConfig{PipelineSync: &config.PipelineSyncConfig{Stages: []config.PipelineStage{
+		{Name: StageCloudRunSync}, {Name: StageCloudRunAnalysis}, {Name: StageCloudRunPromote},
+	}}}
+	success := &StageResult{Status: StageStatusSuccess}
+	failure := &StageResult{Status: StageStatusFailure}
+	for _, tc := range []struct {
+		stage   string
+		index   int
+		result  *StageResult
+		outcome string
+	}{
+		{StageCloudRunSync, 0, success, ""},
+		{StageCloudRunAnalysis, 1, failure, deploymentOutcomeFailed},
+		{StageCloudRunPromote, 2, success, deploymentOutcomeSucceeded},
+		{StageCloudRunRollback, 3, success, deploymentOutcomeRolledBack},
+		{StageCloudRunRollback, 3, failure, deploymentOutcomeFailed},
+	} {
+		outcome, ok := deploymentOutcome(spec, tc.stage, tc.index, tc.result)
+		if outcome != tc.outcome || ok != (tc.outcome != "") {
+			t.Errorf("%s (%s): expected outcome %q, got %q", tc.stage, tc.result.Status, tc.outcome, outcome)
+		}
+	}
+
+	client := &fakeMetadataClient{data: map[string]string{
+		MetadataKeyAnalysis: `{"checks":3,"failures":0}`,
+		MetadataKeyMirror:   "not json",
+	}}
+	input := plugintest.NewExecuteStageInput(t, plugintest.StageInput{StageName: StageCloudRunPromote, Spec: spec})
+	input.Request.StageIndex = 2
+	input.Request.TargetDeploymentSource.CommitHash = "abc123"
+	targets := []*sdk.DeployTarget[config.DeployTargetConfig]{plugintest.NewDeployTarget("production", config.DeployTargetConfig{APIEndpoint: config.APIEndpointRegional})}
+	cfg := &config.PluginConfig{Region: "us-central1"}
+	result := &StageResult{
+		Status:   StageStatusSuccess,
+		Revision: "my-service-00002",
+		Traffic:  map[string]int32{"my-service-00002": 100},
+	}
+	now := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	doc, err := buildDeploymentResult(context.Background(), client, cfg, targets, input, result, deploymentOutcomeSucceeded, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]any{
+		"kind":          deploymentResultKind,
+		"deploymentID":  plugintest.DefaultDeploymentID,
+		"applicationID": plugintest.DefaultApplicationID,
+		"commitHash":    "abc123",
+		"outcome":       deploymentOutcomeSucceeded,
+		"revision":      "my-service-00002",
+		"completedAt":   "2025-06-01T10:00:00Z",
+	} {
+		if decoded[key] != want {
+			t.Errorf("expected %s %v, got %v", key, want, decoded[key])
+		}
+	}
+	if endpoints, _ := decoded["apiEndpoints"].(map[string]any); endpoints["production"] != "us-central1-run.googleapis.com:443" {
+		t.Errorf("unexpected API endpoints %v", endpoints)
+	}
+	reports, _ := decoded["reports"].(map[string]any)
+	if _, ok := reports[MetadataKeyAnalysis]; !ok || len(reports) != 1 {
+		t.Errorf("expected only the valid analysis report, got %v", reports)
+	}
+	if stage, _ := decoded["stage"].(map[string]any); stage["name"] != StageCloudRunPromote || stage["index"] != float64(2) {
+		t.Errorf("unexpected stage %v", stage)
+	}
+
+	if got := resultObjectName("deployments/", "app-1", "deploy-1"); got != "deployments/app-1/deploy-1.json" {
+		t.Errorf("unexpected object name %q", got)
+	}
+}
+
+func TestPublicInvokerChanges(t *testing.T) {
+	policy := &iampb.Policy{Bindings: []*iampb.Binding{
+		{Role: cloudrun.InvokerRole, Members: []string{"serviceAccount:caller@p.iam.gserviceaccount.com"}},
+	}}
+	changes, err := invokerBindingsDiff(policy, []config.InvokerBinding{
+		{Members: []string{"serviceAccount:caller@p.iam.gserviceaccount.com", "allUsers"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(changes.Diff, []string{"+ allUsers"}) || !slices.Equal(changes.PublicGranted, []string{"allUsers"}) || len(changes.PublicRevoked) != 0 {
+		t.Fatalf("unexpected changes %+v", changes)
+	}
+
+	var details strings.Builder
+	writePublicAccessChanges(&details, changes)
+	if !strings.Contains(details.String(), "allUsers will be GRANTED roles/run.invoker: anyone on the internet can invoke the service") {
+		t.Errorf("expected the grant to be described, got %q", details.String())
+	}
+	if plain := styleText(config.OutputStylePlain, details.String()); !strings.Contains(plain, "\nPublic Access:\n  [!] allUsers") {
+		t.Errorf("unexpected plain output %q", plain)
+	}
+
+	// A new service without public members doesn't change public access
+	changes, err = invokerBindingsDiff(nil, []config.InvokerBinding{{Members: []string{"group:devs@example.com"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes.PublicGranted) != 0 || len(changes.PublicRevoked) != 0 {
+		t.Errorf("expected no public access change, got %+v", changes)
+	}
+}
+
+func TestStageMetric(t *testing.T) {
+	counters := newStageCounters()
+	targets := []*sdk.DeployTarget[config.DeployTargetConfig]{
+		plugintest.NewDeployTarget("production", config.DeployTargetConfig{ProjectID: "prod-project"}),
+	}
+	input := plugintest.NewExecuteStageInput(t, plugintest.StageInput{StageName: StageCloudRunSync})
+	started := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	stageMetric(counters, targets, input, &StageResult{Status: StageStatusSuccess}, started, started.Add(time.Minute))
+	m := stageMetric(counters, targets, input, &StageResult{Status: StageStatusSuccess}, started, started.Add(2*time.Minute))
+	if m.Count != 2 || m.Duration != 2*time.Minute || m.DeployTarget != "production" || m.Application != plugintest.DefaultApplicationName {
+		t.Errorf("unexpected metric %+v", m)
+	}
+	if m := stageMetric(counters, targets, input, &StageResult{Status: StageStatusFailure}, started, started); m.Count != 1 || m.Start != counters.start {
+		t.Errorf("expected failures to be counted separately, got %+v", m)
+	}
+
+	cfg := &config.PluginConfig{ProjectID: "default-project", StageMetrics: &config.StageMetricsConfig{}}
+	if project := stageMetricsProject(cfg, targets); project != "prod-project" {
+		t.Errorf("expected the deploy target project, got %s", project)
+	}
+	cfg.StageMetrics.ProjectID = "monitoring-project"
+	if project := stageMetricsProject(cfg, targets); project != "monitoring-project" {
+		t.Errorf("expected the configured project, got %s", project)
+	}
+}
+
+func TestCooldownRemaining(t *testing.T) {
+	now := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	last := &lastSuccessfulDeployment{DeploymentID: "previous", CompletedAt: now.Add(-10 * time.Minute)}
+
+	if remaining := cooldownRemaining(last, "current", 30*time.Minute, now); remaining != 20*time.Minute {
+		t.Errorf("expected 20m remaining, got %s", remaining)
+	}
+	if remaining := cooldownRemaining(last, "current", 5*time.Minute, now); remaining != 0 {
+		t.Errorf("expected the cooldown to have elapsed, got %s", remaining)
+	}
+	if remaining := cooldownRemaining(last, "previous", 30*time.Minute, now); remaining != 0 {
+		t.Errorf("expected the last deployment not to cool down itself, got %s", remaining)
+	}
+	if remaining := cooldownRemaining(nil, "current", 30*time.Minute, now); remaining != 0 {
+		t.Errorf("expected no cooldown without a previous deployment, got %s", remaining)
+	}
+}
+
+func TestRevisionAuditAnnotations(t *testing.T) {
+	vars := deploymentVariables{ApplicationID: "app-1", CommitHash: "abc123", CommitAuthor: "Jane Doe"}
+
+	annotations := revisionAuditAnnotations(&config.RevisionAnnotationsConfig{ConsoleURL: "https://pipecd.example.com/"}, vars)
+	want := map[string]string{
+		"pipecd.dev/commit":          "abc123",
+		"pipecd.dev/commit-author":   "Jane Doe",
+		"pipecd.dev/application-url": "https://pipecd.example.com/applications/app-1",
+	}
+	if !maps.Equal(annotations, want) {
+		t.Errorf("expected %v, got %v", want, annotations)
+	}
+
+	annotations = revisionAuditAnnotations(&config.RevisionAnnotationsConfig{CommitKey: "example.com/sha"}, vars)
+	if annotations["example.com/sha"] != "abc123" || annotations["pipecd.dev/application-url"] != "" {
+		t.Errorf("expected the configured commit key and no application URL, got %v", annotations)
+	}
+}
+
+func TestParamValues(t *testing.T) {
+	params := []config.ParamConfig{
+		{Name: "logLevel", Default: "info"},
+		{Name: "maxInstances", Type: config.ParamTypeInt, Default: float64(3), Targets: map[string]any{"production": float64(20)}},
+		{Name: "cpu", Type: config.ParamTypeNumber, Default: "0.5"},
+		{Name: "debug", Type: config.ParamTypeBool, Default: false},
+	}
+
+	values, err := paramValues(params, "production")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]any{"logLevel": "info", "maxInstances": int64(20), "cpu": 0.5, "debug": false}
+	if !maps.Equal(values, want) {
+		t.Errorf("expected %v, got %v", want, values)
+	}
+
+	vars, err := deploymentVariables{Target: "staging"}.withParams(params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out, err := vars.interpolate("service.yaml", []byte("maxInstanceCount: {{ .Params.maxInstances }}"))
+	if err != nil || string(out) != "maxInstanceCount: 3" {
+		t.Errorf("expected the default to be rendered, got %q, %v", out, err)
+	}
+	if _, err := vars.interpolate("service.yaml", []byte("{{ .Params.undeclared }}")); err == nil {
+		t.Error("expected undeclared params to fail rendering")
+	}
+
+	invalid := []config.ParamConfig{
+		{Name: "maxInstances", Type: config.ParamTypeInt, Default: 2.5},
+		{Name: "region"},
+		{Name: "log-level", Default: "info"},
+		{Name: "debug", Type: "boolean", Default: true},
+		{Name: "ok", Default: "yes"},
+		{Name: "ok", Default: "no"},
+	}
+	values, err = paramValues(invalid, "production")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"param maxInstances: default value: 2.5 is not a valid int", "param region: no value", "param \"log-level\"", "unsupported type \"boolean\"", "param ok: declared more than once"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected the error to contain %q, got %v", want, err)
+		}
+	}
+	if len(values) != 1 || values["ok"] != "yes" {
+		t.Errorf("expected only the valid param, got %v", values)
+	}
+}
+
+// closeCountingClient is a Cloud Run client counting how many times it's closed.
+type closeCountingClient struct {
+	cloudrun.Client
+	closed *int
+}
+
+func (c closeCountingClient) Close() error {
+	*c.closed++
+	return nil
+}
+
+func TestTargetRegistry(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.PluginConfig{}
+	dt := &sdk.DeployTarget[config.DeployTargetConfig]{Name: "prod", Config: config.DeployTargetConfig{ProjectID: "p"}}
+
+	created, closed := 0, 0
+	r := newTargetRegistry()
+	r.newClient = func(context.Context, *config.PluginConfig, *sdk.DeployTarget[config.DeployTargetConfig]) (cloudrun.Client, error) {
+		created++
+		return closeCountingClient{closed: &closed}, nil
+	}
+
+	now := time.Now()
+	r.begin("d1", now)
+	first, err := r.get(ctx, cfg, dt, "d1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.end("d1", false, now)
+	r.begin("d1", now)
+	second, err := r.get(ctx, cfg, dt, "d1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created != 1 || first.client != second.client {
+		t.Errorf("expected the stages of a deployment to share the client, created %d", created)
+	}
+
+	// A changed deploy target config replaces the client, but the replaced
+	// one stays open for the stages still using it
+	changed := &sdk.DeployTarget[config.DeployTargetConfig]{Name: "prod", Config: config.DeployTargetConfig{ProjectID: "p", CredentialsFile: "/new.json"}}
+	if _, err := r.get(ctx, cfg, changed, "d1"); err != nil {
+		t.Fatal(err)
+	}
+	if created != 2 || closed != 0 {
+		t.Errorf("expected the client to be replaced, created %d, closed %d", created, closed)
+	}
+
+	// Dry runs wrap the shared client
+	dry, err := r.get(cloudrun.WithDryRun(ctx, func(string) {}), cfg, changed, "d1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dry.client.(closeCountingClient); ok || created != 2 {
+		t.Errorf("expected the dry run to wrap the shared client, created %d", created)
+	}
+
+	// Idle deployments are closed when another begins
+	r.begin("d2", now)
+	if _, err := r.get(ctx, cfg, dt, "d2"); err != nil {
+		t.Fatal(err)
+	}
+	r.end("d1", false, now)
+	r.begin("d3", now.Add(targetIdleTimeout+time.Minute))
+	if closed != 2 {
+		t.Errorf("expected the idle deployment and its replaced client to be closed, closed %d", closed)
+	}
+
+	// Completed deployments are closed
+	r.end("d2", true, now)
+	if closed != 3 {
+		t.Errorf("expected the completed deployment to be closed, closed %d", closed)
+	}
+	if _, ok := r.deployments["d3"]; !ok || len(r.deployments) != 1 {
+		t.Errorf("expected only the running deployment to remain, got %d", len(r.deployments))
+	}
+}
+
+func TestTargetRegistry_EndpointChange(t *testing.T) {
+	ctx := context.Background()
+	dt := plugintest.NewDeployTarget("prod", config.DeployTargetConfig{ProjectID: "p"})
+
+	var endpoints []string
+	r := newTargetRegistry()
+	r.newClient = func(_ context.Context, cfg *config.PluginConfig, dt *sdk.DeployTarget[config.DeployTargetConfig]) (cloudrun.Client, error) {
+		endpoints = append(endpoints, apiEndpoint(cfg, dt))
+		return closeCountingClient{closed: new(int)}, nil
+	}
+
+	// The plugin config endpoint, and the region resolving "regional",
+	// select the client of the deploy target
+	for _, cfg := range []*config.PluginConfig{
+		{},
+		{APIEndpoint: config.APIEndpointRegional, Region: "us-central1"},
+		{APIEndpoint: config.APIEndpointRegional, Region: "us-central1"},
+		{APIEndpoint: config.APIEndpointRegional, Region: "europe-west1"},
+	} {
+		if _, err := r.get(ctx, cfg, dt, "d1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{cloudrun.DefaultEndpoint, cloudrun.RegionalEndpoint("us-central1"), cloudrun.RegionalEndpoint("europe-west1")}
+	if !slices.Equal(endpoints, want) {
+		t.Errorf("expected clients for %v, got %v", want, endpoints)
+	}
+}
+
+func TestTargetRegistry_Location(t *testing.T) {
+	e := NewStageExecutor()
+	e.targets.newClient = func(context.Context, *config.PluginConfig, *sdk.DeployTarget[config.DeployTargetConfig]) (cloudrun.Client, error) {
+		return closeCountingClient{closed: new(int)}, nil
+	}
+	cfg := &config.PluginConfig{ProjectID: "default-project", Region: "us-central1"}
+
+	input := plugintest.NewExecuteStageInput(t, plugintest.StageInput{StageName: StageCloudRunPromote})
+	clients, err := e.targetClients(context.Background(), cfg, plugintest.NewDeployTarget("staging", config.DeployTargetConfig{}), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clients.project != "default-project" || clients.region != "us-central1" || clients.service != plugintest.DefaultApplicationID {
+		t.Errorf("expected the plugin config location and the application ID, got %s/%s/%s", clients.project, clients.region, clients.service)
+	}
+
+	spec := &config.ApplicationConfig{Input: config.InputConfig{ServiceName: "my-service"}}
+	input = plugintest.NewExecuteStageInput(t, plugintest.StageInput{StageName: StageCloudRunPromote, Spec: spec})
+	clients, err = e.targetClients(context.Background(), cfg, plugintest.NewDeployTarget("production", config.DeployTargetConfig{ProjectID: "prod-project", Region: "europe-west1"}), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clients.project != "prod-project" || clients.region != "europe-west1" || clients.service != "my-service" {
+		t.Errorf("expected the deploy target location and the service name, got %s/%s/%s", clients.project, clients.region, clients.service)
+	}
+}
+
+func TestTargetRegistry_CreateOutsideLock(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.PluginConfig{}
+	release := make(chan struct{})
+	var created atomic.Int32
+
+	r := newTargetRegistry()
+	r.newClient = func(_ context.Context, _ *config.PluginConfig, dt *sdk.DeployTarget[config.DeployTargetConfig]) (cloudrun.Client, error) {
+		if dt.Name == "slow" {
+			<-release
+		}
+		created.Add(1)
+		return closeCountingClient{closed: new(int)}, nil
+	}
+
+	// A slow client creation doesn't block the stages of other deployments
+	slow := make(chan error)
+	go func() {
+		_, err := r.get(ctx, cfg, plugintest.NewDeployTarget("slow", config.DeployTargetConfig{}), "d1")
+		slow <- err
+	}()
+	fast := make(chan error)
+	go func() {
+		_, err := r.get(ctx, cfg, plugintest.NewDeployTarget("fast", config.DeployTargetConfig{}), "d2")
+		fast <- err
+	}()
+	select {
+	case err := <-fast:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the client of another deployment to be created while one is slow")
+	}
+	close(release)
+	if err := <-slow; err != nil {
+		t.Fatal(err)
+	}
+	if created.Load() != 2 {
+		t.Errorf("expected 2 clients, got %d", created.Load())
+	}
+}
+
+func TestApplyCredentialsOverride(t *testing.T) {
+	ctx := context.Background()
+	deployTargets := []*sdk.DeployTarget[config.DeployTargetConfig]{
+		{Name: "prod", Config: config.DeployTargetConfig{ProjectID: "p", CredentialsFile: "/etc/piped/prod.json"}},
+	}
+	in := config.InputConfig{CredentialsFile: "/etc/piped/teams/payments.json"}
+
+	got, err := applyCredentialsOverride(ctx, &config.PluginConfig{}, deployTargets, "app-1", config.InputConfig{})
+	if err != nil || got[0].Config.CredentialsFile != "/etc/piped/prod.json" {
+		t.Errorf("expected the deploy target credentials without override, got %v, %v", got, err)
+	}
+
+	if _, err := applyCredentialsOverride(ctx, &config.PluginConfig{}, deployTargets, "app-1", in); err == nil || !strings.Contains(err.Error(), "not allowed to override credentials") {
+		t.Errorf("expected applications to need allowing, got %v", err)
+	}
+
+	cfg := &config.PluginConfig{CredentialsOverride: &config.CredentialsOverrideConfig{
+		AllowedApplications: []string{"app-1"},
+		AllowedCredentials:  []string{"/etc/piped/teams/*.json"},
+	}}
+	got, err = applyCredentialsOverride(ctx, cfg, deployTargets, "app-1", in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0].Config.CredentialsFile != in.CredentialsFile || got[0].Config.ProjectID != "p" {
+		t.Errorf("expected the application credentials, got %+v", got[0].Config)
+	}
+	if deployTargets[0].Config.CredentialsFile != "/etc/piped/prod.json" {
+		t.Error("expected the deploy targets passed in not to be modified")
+	}
+
+	if _, err := applyCredentialsOverride(ctx, cfg, deployTargets, "app-1", config.InputConfig{CredentialsFile: "/etc/piped/prod.json"}); err == nil {
+		t.Error("expected credentials outside the allowed patterns to be refused")
+	}
+	if _, err := applyCredentialsOverride(ctx, cfg, deployTargets, "app-1", config.InputConfig{CredentialsFile: in.CredentialsFile, CredentialsSecret: "key"}); err == nil {
+		t.Error("expected credentialsFile and credentialsSecret to be exclusive")
+	}
+}
+
+func TestWriteCredentialsFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "credentials")
+	key := []byte(`{"type": "service_account"}`)
+
+	file, err := writeCredentialsFile(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil || string(data) != string(key) {
+		t.Errorf("expected the key to be written, got %q, %v", data, err)
+	}
+	if info, err := os.Stat(file); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("expected the key to be readable only by the plugin, got %v, %v", info.Mode(), err)
+	}
+
+	again, err := writeCredentialsFile(dir, key)
+	if err != nil || again != file {
+		t.Errorf("expected the same key to reuse the file, got %s, %v", again, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("expected one file, got %d", len(entries))
+	}
+
+	if _, err := writeCredentialsFile(dir, []byte("not json")); err == nil {
+		t.Error("expected a non-JSON key to be refused")
+	}
+}
+
+func TestCheckAutoPromote(t *testing.T) {
+	if err := checkAutoPromoteWithin(40*time.Minute, time.Hour); err != nil {
+		t.Errorf("expected a canary within the time to be promoted, got %v", err)
+	}
+	if err := checkAutoPromoteWithin(90*time.Minute, time.Hour); err == nil || !strings.Contains(err.Error(), "the canary took 1h30m0s, longer than the 1h0m0s") {
+		t.Errorf("expected a slow canary not to be promoted, got %v", err)
+	}
+	if err := checkAutoPromoteWithin(time.Minute, 0); err == nil {
+		t.Error("expected within to be required")
+	}
+
+	deployTargets := []*sdk.DeployTarget[config.DeployTargetConfig]{
+		{Name: "prod", Config: config.DeployTargetConfig{AllowedStages: []string{StageCloudRunSync, StageCloudRunPromote}}},
+	}
+	if err := checkAutoPromoteAllowed(deployTargets); err == nil || !strings.Contains(err.Error(), StageCloudRunCanaryCleanup) {
+		t.Errorf("expected the cleanup to need allowing, got %v", err)
+	}
+	deployTargets[0].Config.AllowedStages = nil
+	if err := checkAutoPromoteAllowed(deployTargets); err != nil {
+		t.Errorf("expected all stages to be allowed, got %v", err)
+	}
+
+	spec := &config.ApplicationConfig{Canary: &config.CanaryConfig{AutoPromote: &config.AutoPromoteConfig{}}}
+	if autoPromoteConfig(spec) != nil {
+		t.Error("expected quick syncs not to be promoted automatically")
+	}
+	spec.PipelineSync = &config.PipelineSyncConfig{}
+	if autoPromoteConfig(spec) == nil {
+		t.Error("expected pipelines to be promoted automatically")
+	}
+}
+
+func TestColdStartReport(t *testing.T) {
+	traffic := []cloudrun.TrafficSplit{
+		{RevisionName: "LATEST", IsLatest: true, Percent: 10},
+		{RevisionName: "my-service-00041", Percent: 60},
+		{RevisionName: "my-service-00040", Percent: 30},
+		{RevisionName: "my-service-00042", Tag: "candidate"},
+	}
+	if got := baselineRevision(traffic, "my-service-00042"); got != "my-service-00041" {
+		t.Errorf("expected the revision serving the most traffic, got %q", got)
+	}
+	if got := baselineRevision(traffic[:1], "my-service-00042"); got != "" {
+		t.Errorf("expected no baseline, got %q", got)
+	}
+
+	report := coldStartReport{
+		coldStartLatency: coldStartLatency{Revision: "my-service-00042", P50Ms: 850, P95Ms: 1900},
+		Baseline:         &coldStartLatency{Revision: "my-service-00041", P50Ms: 800, P95Ms: 1200},
+	}
+	if got, want := report.String(), "p50 850ms, p95 1.9s (my-service-00041: p50 800ms, p95 1.2s, p95 +58%)"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	want := `{"revision":"my-service-00042","p50Ms":850,"p95Ms":1900,"baseline":{"revision":"my-service-00041","p50Ms":800,"p95Ms":1200}}`
+	if got := report.metadata()[MetadataKeyColdStart]; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestTargetPromotion(t *testing.T) {
+	ctx := context.Background()
+	targets := []*sdk.DeployTarget[config.DeployTargetConfig]{
+		plugintest.NewDeployTarget("staging", config.DeployTargetConfig{}),
+		plugintest.NewDeployTarget("production", config.DeployTargetConfig{}),
+	}
+
+	// Without a selection the stage runs against all deploy targets
+	stagingCtx, selected, err := selectStageTarget(ctx, targets, []byte(`{"skipTrafficShift":true}`))
+	if err != nil || len(selected) != 2 {
+		t.Fatalf("expected all deploy targets, got %d: %v", len(selected), err)
+	}
+	productionCtx, selected, err := selectStageTarget(ctx, targets, []byte(`{"deployTarget":"production"}`))
+	if err != nil || len(selected) != 1 || selected[0].Name != "production" {
+		t.Fatalf("expected the production deploy target, got %v: %v", selected, err)
+	}
+	if _, _, err := selectStageTarget(ctx, targets, []byte(`{"deployTarget":"qa"}`)); err == nil {
+		t.Error("expected an error for an unknown deploy target")
+	}
+
+	// The first deploy target keeps the namespaces of single-target pipelines
+	if got := targetNamespace(stagingCtx, metadataNamespaceSync); got != "sync" {
+		t.Errorf("expected namespace sync, got %s", got)
+	}
+	if got := targetNamespace(productionCtx, metadataNamespaceSync); got != "sync.production" {
+		t.Errorf("expected namespace sync.production, got %s", got)
+	}
+
+	client := &fakeMetadataClient{data: map[string]string{}}
+	lp := &plugintest.LogRecorder{}
+	if _, err := promotedImages(productionCtx, client, "staging"); err == nil {
+		t.Error("expected an error before staging was synced")
+	}
+
+	svc := &runpb.Service{Template: &runpb.RevisionTemplate{Containers: []*runpb.Container{
+		{Image: "gcr.io/my-project/app@sha256:abc"},
+		{Image: "gcr.io/my-project/proxy:v2"},
+	}}}
+	recordSyncedImages(stagingCtx, client, svc, lp)
+	images, err := promotedImages(productionCtx, client, "staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(images, []string{"gcr.io/my-project/app@sha256:abc", "gcr.io/my-project/proxy:v2"}) {
+		t.Errorf("unexpected promoted images: %v", images)
+	}
+	if _, err := promotedImages(productionCtx, client, "production"); err == nil {
+		t.Error("expected an error promoting a deploy target to itself")
+	}
+
+	// Only deploy targets other than the first are rolled back separately
+	recordSyncedImages(productionCtx, client, svc, lp)
+	recordSyncedImages(productionCtx, client, svc, lp)
+	synced, err := syncedTargets(ctx, client)
+	if err != nil || !slices.Equal(synced, []string{"production"}) {
+		t.Errorf("expected synced deploy targets [production], got %v: %v", synced, err)
+	}
+
+	promoted := &runpb.Service{Template: &runpb.RevisionTemplate{Containers: []*runpb.Container{{Image: "app:latest"}, {Image: "proxy:latest"}}}}
+	if err := cloudrun.SetContainerImages(promoted, images); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(cloudrun.ContainerImages(promoted), images) {
+		t.Errorf("expected the promoted images, got %v", cloudrun.ContainerImages(promoted))
+	}
+	if err := cloudrun.SetContainerImages(promoted, images[:1]); err == nil {
+		t.Error("expected an error for a different number of containers")
+	}
+}
+
+// selfTestClient is a Cloud Run client answering the self-test checks.
+type selfTestClient struct {
+	cloudrun.Client
+	accessErr   error
+	getErr      error
+	validateErr error
+	validated   *runpb.Service
+}
+
+func (c *selfTestClient) CheckAccess(context.Context, string, string) error { return c.accessErr }
+
+func (c *selfTestClient) GetService(context.Context, string, string, string) (*runpb.Service, error) {
+	return nil, c.getErr
+}
+
+func (c *selfTestClient) ValidateService(_ context.Context, svc *runpb.Service) error {
+	c.validated = svc
+	return c.validateErr
+}
+
+func (c *selfTestClient) Close() error { return nil }
+
+func TestSelfTest(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.PluginConfig{ProjectID: "my-project"}
+	notFound := status.Error(codes.NotFound, "service not found")
+	denied := status.Error(codes.PermissionDenied, "run.services.create denied")
+
+	ready := &selfTestClient{getErr: notFound}
+	newClient := func(client cloudrun.Client) func(context.Context, *config.PluginConfig, *sdk.DeployTarget[config.DeployTargetConfig]) (cloudrun.Client, error) {
+		return func(context.Context, *config.PluginConfig, *sdk.DeployTarget[config.DeployTargetConfig]) (cloudrun.Client, error) {
+			return client, nil
+		}
+	}
+	staging := selfTest(ctx, cfg, plugintest.NewDeployTarget("staging", config.DeployTargetConfig{Region: "us-central1"}), "pipecd-selftest-1", newClient(ready))
+	if !staging.Ready() || staging.Project != "my-project" {
+		t.Errorf("expected staging to be ready in my-project, got %+v", staging)
+	}
+	if ready.validated.GetName() != "projects/my-project/locations/us-central1/services/pipecd-selftest-1" {
+		t.Errorf("unexpected validated service %q", ready.validated.GetName())
+	}
+
+	// A service that exists, or a denied create, is not ready
+	production := selfTest(ctx, cfg, plugintest.NewDeployTarget("production", config.DeployTargetConfig{Region: "europe-west1"}), "pipecd-selftest-1", newClient(&selfTestClient{validateErr: denied}))
+	if production.Ready() || production.Errors[selfTestCheckList] != nil || production.Errors[selfTestCheckGet] == nil || production.Errors[selfTestCheckCreate] != denied {
+		t.Errorf("expected get and create to fail, got %+v", production.Errors)
+	}
+
+	// Without a region no check can run
+	unset := selfTest(ctx, cfg, plugintest.NewDeployTarget("dev", config.DeployTargetConfig{}), "pipecd-selftest-1", newClient(ready))
+	if len(unset.Errors) != len(selfTestChecks) {
+		t.Errorf("expected all checks to fail, got %+v", unset.Errors)
+	}
+
+	var out strings.Builder
+	if err := writeSelfTestMatrix(&out, []SelfTestResult{staging, production}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"staging", "production", "FAIL", "production create:", "run.services.create denied"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in the matrix, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestScalingSettingsNormalizesAnnotations(t *testing.T) {
+	// Manifests declare scaling as annotations, live services as fields
+	manifest := &runpb.RevisionTemplate{Annotations: map[string]string{
+		"autoscaling.knative.dev/minScale": "1",
+		"autoscaling.knative.dev/maxScale": "10",
+		"autoscaling.knative.dev/target":   "70",
+	}}
+	live := &runpb.RevisionTemplate{
+		Annotations: map[string]string{"autoscaling.knative.dev/target": "70"},
+		Scaling:     &runpb.RevisionScaling{MinInstanceCount: 1, MaxInstanceCount: 10},
+	}
+	if hasScalingChanges(live, manifest) {
+		t.Errorf("expected no scaling changes, got %v and %v", scalingSettings(live), scalingSettings(manifest))
+	}
+	if len(manifest.Annotations) != 3 {
+		t.Errorf("expected the template not to be modified, got %v", manifest.Annotations)
+	}
+
+	live.Scaling.MaxInstanceCount = 20
+	if !hasScalingChanges(live, manifest) {
+		t.Errorf("expected the maximum instance count to change")
+	}
+}
+
+func TestWithPayloadLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "payloads.jsonl")
+	cfg := &config.PluginConfig{PayloadLog: &config.PayloadLogConfig{File: path}}
+	targets := []*sdk.DeployTarget[config.DeployTargetConfig]{plugintest.NewDeployTarget("staging", config.DeployTargetConfig{})}
+	manifest := func(rate string) string {
+		return fmt.Sprintf(`{"name": "my-service", "annotations": {%q: %q}, "template": {"containers": [{"image": "app"}]}}`, cloudrun.PayloadLogAnnotation, rate)
+	}
+
+	// The annotation enables the payload log of the deployment
+	input := plugintest.NewExecuteStageInput(t, plugintest.StageInput{
+		StageName: StageCloudRunSync,
+		Files:     map[string]string{"service.yaml": manifest("0.5")},
+	})
+	lp := &plugintest.LogRecorder{}
+	if ctx := withPayloadLog(context.Background(), cfg, targets, input, lp); ctx == context.Background() {
+		t.Fatal("expected the payload log to be enabled")
+	}
+	if !lp.Contains(plugintest.LogLevelInfo, fmt.Sprintf("Logging 50%% of Admin API payloads to %s", path)) {
+		t.Errorf("expected the payload log to be reported, got %v", lp.Lines())
+	}
+
+	// Without the annotation, or the plugin config, payloads are not logged
+	plain := plugintest.NewExecuteStageInput(t, plugintest.StageInput{
+		StageName: StageCloudRunSync,
+		Files:     map[string]string{"service.yaml": plugintest.ServiceManifest("my-service", "app")},
+	})
+	if ctx := withPayloadLog(context.Background(), cfg, targets, plain, lp); ctx != context.Background() {
+		t.Error("expected no payload log without the annotation")
+	}
+	if ctx := withPayloadLog(context.Background(), &config.PluginConfig{}, targets, input, lp); ctx != context.Background() {
+		t.Error("expected no payload log without the plugin config")
+	}
+
+	// An invalid sample rate is reported without failing the stage
+	invalid := plugintest.NewExecuteStageInput(t, plugintest.StageInput{
+		StageName: StageCloudRunSync,
+		Files:     map[string]string{"service.yaml": manifest("all")},
+	})
+	lp = &plugintest.LogRecorder{}
+	if ctx := withPayloadLog(context.Background(), cfg, targets, invalid, lp); ctx != context.Background() || !lp.Contains(plugintest.LogLevelInfo, "Warning: Payloads not logged") {
+		t.Errorf("expected a warning, got %v", lp.Lines())
+	}
+}
+
+func TestPlanPreview_ContainerStartupOrder(t *testing.T) {
+	service := func(appDeps ...string) *runpb.Service {
+		return &runpb.Service{
+			Name: "test-service",
+			Template: &runpb.RevisionTemplate{Containers: []*runpb.Container{
+				{Name: "app", Image: "gcr.io/project/app:v1", DependsOn: appDeps},
+				{Name: "otel", Image: "gcr.io/project/otel:v1"},
+				{Name: "proxy", Image: "gcr.io/project/proxy:v1"},
+			}},
+		}
+	}
+
+	result := generateUpdateServicePlan(service("otel"), service("otel", "proxy"), "test-project", "us-central1", "production")
+	details := string(result.Details)
+	for _, want := range []string{
+		"Container Startup Order",
+		"- Current: otel → app → proxy",
+		"+ Desired: otel → proxy → app",
+		"+ app.dependsOn: otel,proxy",
+	} {
+		if !strings.Contains(details, want) {
+			t.Errorf("expected %q in the details, got:\n%s", want, details)
+		}
+	}
+	if !strings.Contains(result.Summary, "startup order: app.dependsOn=otel → app.dependsOn=otel,proxy") {
+		t.Errorf("expected the startup order in the summary, got:\n%s", result.Summary)
+	}
+
+	// The section renders in the plain and markdown output styles
+	if plain := styleText(config.OutputStylePlain, details); !strings.Contains(plain, "\nContainer Startup Order:\n  - Current: otel -> app -> proxy\n") {
+		t.Errorf("expected the plain startup order section, got:\n%s", plain)
+	}
+	if markdown := styleText(config.OutputStyleMarkdown, details); !strings.Contains(markdown, "#### Container Startup Order\n") {
+		t.Errorf("expected the startup order heading, got:\n%s", markdown)
+	}
+
+	if result := generateCreateServicePlan(service("otel"), "test-project", "us-central1", "staging"); !strings.Contains(string(result.Details), "Startup Order: otel → app → proxy") {
+		t.Errorf("expected the startup order of the new service, got:\n%s", result.Details)
+	}
+}
+
+// multiServiceClient is a Cloud Run client deploying services, failing those
+// in failing.
+type multiServiceClient struct {
+	cloudrun.Client
+	failing  map[string]bool
+	deployed []*runpb.Service
+}
+
+func (c *multiServiceClient) GetService(context.Context, string, string, string) (*runpb.Service, error) {
+	return nil, status.Error(codes.NotFound, "service not found")
+}
+
+func (c *multiServiceClient) CreateOrUpdateService(_ context.Context, svc *runpb.Service) (*runpb.Service, error) {
+	name := cloudrun.GetServiceName(svc.Name)
+	if c.failing[name] {
+		return nil, status.Error(codes.InvalidArgument, "invalid image")
+	}
+	c.deployed = append(c.deployed, svc)
+	return &runpb.Service{Name: svc.Name, LatestCreatedRevision: svc.Name + "/revisions/" + name + "-00001", Uri: "https://" + name + ".a.run.app"}, nil
+}
+
+func (c *multiServiceClient) WaitForServiceReady(context.Context, string, string, string) error {
+	return nil
+}
+
+func TestAdditionalServices(t *testing.T) {
+	ctx := context.Background()
+	dt := plugintest.NewDeployTarget("production", config.DeployTargetConfig{DeniedServices: []string{"legacy-*"}})
+	services, err := cloudrun.ParseServiceManifests([]byte(`name: api
+template: {containers: [{image: gcr.io/p/api:v1}]}
+---
+name: admin
+template: {containers: [{image: gcr.io/p/admin:v1}]}
+---
+name: worker
+template: {containers: [{image: gcr.io/p/worker:v1}]}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	additional := services[1:]
+
+	if err := validateAdditionalServices(dt, "api", additional); err != nil {
+		t.Fatal(err)
+	}
+	for name, svcs := range map[string][]*runpb.Service{
+		"document 2: the name":         {{}},
+		"service api is declared":      {{Name: "api"}},
+		"service legacy-admin":         {{Name: "legacy-admin"}},
+		"service admin: container app": {{Name: "admin", Template: &runpb.RevisionTemplate{Containers: []*runpb.Container{{Name: "app", DependsOn: []string{"app"}}}}}},
+	} {
+		if err := validateAdditionalServices(dt, "api", svcs); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("expected an error containing %q, got %v", name, err)
+		}
+	}
+
+	// All services are deployed even if one fails
+	client := &multiServiceClient{failing: map[string]bool{"admin": true}}
+	input := plugintest.NewExecuteStageInput(t, plugintest.StageInput{StageName: StageCloudRunSync})
+	reports, err := deployAdditionalServices(ctx, &config.PluginConfig{}, []*sdk.DeployTarget[config.DeployTargetConfig]{dt}, input, client, "my-project", "us-central1", additional, &plugintest.LogRecorder{})
+	if err == nil || !strings.Contains(err.Error(), "service admin") {
+		t.Errorf("expected the admin service to fail, got %v", err)
+	}
+	if len(client.deployed) != 1 || client.deployed[0].Name != "projects/my-project/locations/us-central1/services/worker" {
+		t.Fatalf("expected the worker service to be deployed, got %v", client.deployed)
+	}
+	if got := client.deployed[0].Traffic; len(got) != 1 || got[0].Percent != 100 {
+		t.Errorf("expected all traffic on the new revision, got %v", got)
+	}
+	if reports[0].Ready || reports[0].Error == "" || !reports[1].Ready || reports[1].Revision != "worker-00001" || countNotReady(reports) != 1 {
+		t.Errorf("unexpected reports %+v", reports)
+	}
+	if got := encodeServiceReports(reports[1:]); got != `[{"service":"worker","revision":"worker-00001","url":"https://worker.a.run.app","ready":true}]` {
+		t.Errorf("unexpected metadata %s", got)
+	}
+}
+
+func TestRenderServiceManifest_MultipleFiles(t *testing.T) {
+	input := plugintest.NewExecuteStageInput(t, plugintest.StageInput{
+		StageName: StageCloudRunSync,
+		Spec:      &config.ApplicationConfig{ServiceManifestPath: config.ManifestPaths{"service.yaml", "services/*.yaml"}},
+		Files: map[string]string{
+			"service.yaml":         plugintest.ServiceManifest("api", "gcr.io/p/api:v1"),
+			"services/worker.yaml": plugintest.ServiceManifest("worker", "gcr.io/p/worker:v1"),
+			"services/admin.yaml":  plugintest.ServiceManifest("admin", "gcr.io/p/admin:v1"),
+		},
+	})
+	src := input.Request.TargetDeploymentSource
+
+	paths, data, err := renderServiceManifest(context.Background(), &config.PluginConfig{}, src.ApplicationConfig.Spec, src.ApplicationDirectory, deploymentVariables{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"service.yaml", "services/admin.yaml", "services/worker.yaml"}; !slices.Equal(paths, want) {
+		t.Errorf("expected paths %q, got %q", want, paths)
+	}
+	services, err := cloudrun.ParseServiceManifests(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, svc := range services {
+		names = append(names, manifestServiceName(svc))
+	}
+	if want := []string{"api", "admin", "worker"}; !slices.Equal(names, want) {
+		t.Errorf("expected services %q, got %q", want, names)
+	}
+}
+
+// trafficUpdateClient is a Cloud Run client recording traffic updates.
+type trafficUpdateClient struct {
+	cloudrun.Client
+	updated map[string][]*runpb.TrafficTarget
+}
+
+func (c *trafficUpdateClient) UpdateTraffic(_ context.Context, _, _, service string, traffic []*runpb.TrafficTarget) error {
+	c.updated[service] = traffic
+	return nil
+}
+
+func TestRollbackAdditionalServices(t *testing.T) {
+	ctx := context.Background()
+	metadata := &fakeMetadataClient{data: map[string]string{}}
+	lp := &plugintest.LogRecorder{}
+
+	recordAdditionalServices(ctx, metadata, map[string]string{"admin": "admin-00003", "worker": ""}, lp)
+	// A later sync of the same deployment keeps the recorded revisions
+	recordAdditionalServices(ctx, metadata, map[string]string{"admin": "admin-00004", "cron": "cron-00001"}, lp)
+	names, stable := recordedAdditionalServices(ctx, metadata, lp)
+	if want := []string{"admin", "cron", "worker"}; !slices.Equal(names, want) {
+		t.Fatalf("expected services %q, got %q", want, names)
+	}
+	if stable["admin"] != "admin-00003" {
+		t.Errorf("expected the first recorded revision, got %s", stable["admin"])
+	}
+
+	client := &trafficUpdateClient{updated: map[string][]*runpb.TrafficTarget{}}
+	if err := rollbackAdditionalServices(ctx, metadata, cloudrun.NewTrafficManager(client), "my-project", "us-central1", lp); err != nil {
+		t.Fatal(err)
+	}
+	if got := client.updated["admin"]; len(got) != 1 || got[0].Revision != "admin-00003" || got[0].Percent != 100 {
+		t.Errorf("expected all traffic on admin-00003, got %v", got)
+	}
+	if _, ok := client.updated["worker"]; ok {
+		t.Error("expected the new worker service to be left in place")
+	}
+	if !lp.Contains(plugintest.LogLevelInfo, "Service worker was created by this deployment") {
+		t.Errorf("expected the new service to be reported, got %v", lp.Lines())
+	}
+}
+
+func TestWriteAdditionalServicesPlan(t *testing.T) {
+	dt := plugintest.NewDeployTarget("production", config.DeployTargetConfig{})
+	services, err := cloudrun.ParseServiceManifests([]byte(plugintest.ServiceManifest("worker", "gcr.io/p/worker:v1")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var details strings.Builder
+	changed, err := writeAdditionalServicesPlan(context.Background(), &details, &multiServiceClient{}, dt, services, "my-project", "us-central1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed != 1 || !strings.Contains(details.String(), "✨ worker will be created") {
+		t.Errorf("expected the worker service to be created, got %d:\n%s", changed, details.String())
+	}
+}
+
+func TestTargetValues(t *testing.T) {
+	appDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(appDir, "values"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(appDir, "values", "production.yaml"), []byte("minScale: 3\nenv:\n  LOG_LEVEL: warn\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(appDir, "values", "broken.yaml"), []byte("- not a map"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	dt := plugintest.NewDeployTarget("production", config.DeployTargetConfig{
+		Values: map[string]any{
+			"minScale": 1,
+			"env":      map[string]any{"LOG_LEVEL": "info", "REGION": "eu"},
+		},
+		ValuesFile: "values/production.yaml",
+	})
+	vars, err := deploymentVariables{Target: "production"}.withValues(dt, appDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := vars.interpolate("service.yaml", []byte(`{"minScale": {{ .Values.minScale }}, "env": "{{ .Values.env.LOG_LEVEL }}/{{ .Values.env.REGION }}"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(out), `{"minScale": 3, "env": "warn/eu"}`; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if _, err := vars.interpolate("service.yaml", []byte(`{{ .Values.maxScale }}`)); err == nil {
+		t.Error("expected an error for an undefined value")
+	}
+
+	// Applications without the values file only get the inline values
+	dt.Config.ValuesFile = "values/staging.yaml"
+	if values, err := targetValues(dt, appDir); err != nil || values["minScale"] != 1 {
+		t.Errorf("expected the inline values, got %v, %v", values, err)
+	}
+	dt.Config.ValuesFile = "values/broken.yaml"
+	if _, err := targetValues(dt, appDir); err == nil || !strings.Contains(err.Error(), "invalid values file values/broken.yaml of deploy target production") {
+		t.Errorf("expected an invalid values file error, got %v", err)
+	}
+}
+
+// restoreClient is a Cloud Run client recording the restored IAM policy.
+type restoreClient struct {
+	cloudrun.Client
+	existing *runpb.Service
+	policy   *iampb.Policy
+}
+
+func (c *restoreClient) GetService(context.Context, string, string, string) (*runpb.Service, error) {
+	if c.existing == nil {
+		return nil, status.Error(codes.NotFound, "service not found")
+	}
+	return c.existing, nil
+}
+
+func (c *restoreClient) SetIAMPolicy(_ context.Context, _, _, _ string, policy *iampb.Policy) (*iampb.Policy, error) {
+	c.policy = policy
+	return policy, nil
+}
+
+func TestRestoreService(t *testing.T) {
+	ctx := context.Background()
+	backedUp := &runpb.Service{
+		Name: "projects/old-project/locations/us-east1/services/old-service",
+		Template: &runpb.RevisionTemplate{
+			Revision:   "old-service-v2",
+			Containers: []*runpb.Container{{Image: "gcr.io/p/app:v2"}},
+		},
+		Traffic: []*runpb.TrafficTarget{{
+			Type:     runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION,
+			Revision: "old-service-v1",
+			Percent:  100,
+		}},
+	}
+	policy := &iampb.Policy{
+		Etag:     []byte("etag"),
+		Bindings: []*iampb.Binding{{Role: "roles/run.invoker", Members: []string{"allUsers"}}},
+	}
+	backup, err := cloudrun.NewServiceBackup("old-project", "us-east1", backedUp, policy, nil, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("missing service", func(t *testing.T) {
+		client := &restoreClient{}
+		lp := &plugintest.LogRecorder{}
+		svc, err := restoredService(ctx, client, backup, "new-project", "us-central1", "my-service", lp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if svc.Name != "projects/new-project/locations/us-central1/services/my-service" {
+			t.Errorf("unexpected service name %q", svc.Name)
+		}
+		if svc.Template.Revision != "" {
+			t.Errorf("expected the revision name to be cleared, got %q", svc.Template.Revision)
+		}
+		if len(svc.Traffic) != 1 || svc.Traffic[0].Type != runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST {
+			t.Errorf("expected all traffic to the latest revision, got %v", svc.Traffic)
+		}
+		if !lp.Contains(plugintest.LogLevelInfo, "does not exist") {
+			t.Error("expected a warning about the missing service")
+		}
+	})
+
+	t.Run("existing service", func(t *testing.T) {
+		client := &restoreClient{existing: &runpb.Service{Name: "projects/new-project/locations/us-central1/services/my-service"}}
+		svc, err := restoredService(ctx, client, backup, "new-project", "us-central1", "my-service", &plugintest.LogRecorder{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(svc.Traffic) != 1 || svc.Traffic[0].Revision != "old-service-v1" {
+			t.Errorf("expected the backed up traffic, got %v", svc.Traffic)
+		}
+	})
+
+	t.Run("IAM policy", func(t *testing.T) {
+		client := &restoreClient{}
+		err := restoreServiceAccess(ctx, client, backup, DefaultRestoreStageConfig(), "", "new-project", "us-central1", "my-service", &plugintest.LogRecorder{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if client.policy == nil || client.policy.Etag != nil || len(client.policy.Bindings) != 1 {
+			t.Errorf("expected the backed up policy without etag, got %v", client.policy)
+		}
+
+		client = &restoreClient{}
+		err = restoreServiceAccess(ctx, client, backup, &RestoreStageConfig{SkipIAMPolicy: true}, "", "new-project", "us-central1", "my-service", &plugintest.LogRecorder{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if client.policy != nil {
+			t.Errorf("expected the IAM policy to be skipped, got %v", client.policy)
+		}
+	})
+}
+
+func TestNewDeploymentVariables_Source(t *testing.T) {
+	appDir := t.TempDir()
+	appConfig := "apiVersion: pipecd.dev/v1beta1\nkind: Application\nspec:\n  name: my-app\n"
+	if err := os.WriteFile(filepath.Join(appDir, "app.pipecd.yaml"), []byte(appConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src := sdk.DeploymentSource[config.ApplicationConfig]{
+		ApplicationDirectory:      appDir,
+		ApplicationConfigFilename: "app.pipecd.yaml",
+		CommitHash:                "0123456789abcdef",
+	}
+
+	vars := newDeploymentVariables(context.Background(), "", "app-1", "", "staging", src)
+	if vars.ApplicationName != "my-app" {
+		t.Errorf("expected the application name from the config file, got %q", vars.ApplicationName)
+	}
+	if vars.CommitHash != "0123456789abcdef" || vars.CommitShortHash != "0123456" {
+		t.Errorf("expected the commit of the source, got %q (%q)", vars.CommitHash, vars.CommitShortHash)
+	}
+
+	manifest := []byte(`{"image": "gcr.io/p/app:{{ .CommitShortHash }}", "labels": {"app": "{{ .ApplicationName }}"}}`)
+	out, err := vars.interpolate("service.yaml", manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(out), `{"image": "gcr.io/p/app:0123456", "labels": {"app": "my-app"}}`; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	vars = newDeploymentVariables(context.Background(), "deploy-1", "app-1", "named-app", "staging", src)
+	if vars.ApplicationName != "named-app" {
+		t.Errorf("expected the application name of the deployment, got %q", vars.ApplicationName)
+	}
+}
+
+func TestStageHooks(t *testing.T) {
+	ctx := context.Background()
+	var requests []StageNotification
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		var n StageNotification
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			t.Errorf("invalid notification: %v", err)
+		}
+		requests = append(requests, n)
+		if n.Event == StageEventFailed {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.PluginConfig{
+		ProjectID: "my-project",
+		Region:    "us-central1",
+		StageHooks: []config.StageHookConfig{
+			{URL: server.URL, Headers: map[string]config.SecretString{"Authorization": {Value: "Bearer token"}}},
+			{URL: server.URL, Events: []string{config.StageHookEventFailed}},
+			{URL: server.URL, Topic: "projects/p/topics/t"},
+		},
+	}
+	deployTargets := []*sdk.DeployTarget[config.DeployTargetConfig]{
+		plugintest.NewDeployTarget("production", config.DeployTargetConfig{Region: "europe-west1"}),
+	}
+	input := plugintest.NewExecuteStageInput(t, plugintest.StageInput{
+		StageName: StageCloudRunPromote,
+		Spec:      &config.ApplicationConfig{Input: config.InputConfig{ServiceName: "my-service"}},
+	})
+	var registered []StageEvent
+	executor := NewStageExecutor()
+	executor.hooks = append(executor.hooks, StageHookFunc(func(_ context.Context, n StageNotification) error {
+		registered = append(registered, n.Event)
+		return nil
+	}))
+
+	lp := &plugintest.LogRecorder{}
+	executor.notifyStageHooks(ctx, cfg, deployTargets, input, lp, StageEventStarted, nil)
+	if len(requests) != 1 {
+		t.Fatalf("expected the started event to be posted to the hook of all events, got %d requests", len(requests))
+	}
+	n := requests[0]
+	if n.Event != StageEventStarted || n.Stage != StageCloudRunPromote || n.Service != "my-service" ||
+		n.Target != "production" || n.Project != "my-project" || n.Region != "europe-west1" || n.DeploymentID != plugintest.DefaultDeploymentID {
+		t.Errorf("unexpected notification %+v", n)
+	}
+	if gotAuth != "Bearer token" {
+		t.Errorf("expected the configured header, got %q", gotAuth)
+	}
+	if !lp.Contains(plugintest.LogLevelInfo, "Ignoring stage hook 2") {
+		t.Error("expected a warning about the hook with both url and topic")
+	}
+
+	requests = nil
+	result := &StageResult{Status: StageStatusFailure, Revision: "my-service-00002", Traffic: map[string]int32{"my-service-00002": 10, "my-service-00001": 90}, Message: "analysis failed"}
+	executor.notifyStageHooks(ctx, cfg, deployTargets, input, lp, StageEventFailed, result)
+	if len(requests) != 2 {
+		t.Fatalf("expected the failed event to be posted to both hooks, got %d requests", len(requests))
+	}
+	if n := requests[0]; n.Revision != "my-service-00002" || n.Traffic["my-service-00002"] != 10 || n.Message != "analysis failed" {
+		t.Errorf("expected the stage result in the notification, got %+v", n)
+	}
+	if !lp.Contains(plugintest.LogLevelInfo, "500 Internal Server Error") {
+		t.Error("expected a warning about the failed notification")
+	}
+	if len(registered) != 2 || registered[0] != StageEventStarted || registered[1] != StageEventFailed {
+		t.Errorf("expected the registered hook to be notified of both events, got %v", registered)
+	}
+}
+
+func TestRenderServiceManifest_Overlay(t *testing.T) {
+	base := `{
+  "name": "api",
+  "labels": {"team": "payments", "debug": "true"},
+  "template": {
+    "containers": [{"image": "gcr.io/p/api:{{ .CommitShortHash }}"}],
+    "scaling": {"minInstanceCount": 0, "maxInstanceCount": 5}
+  }
+}`
+	overlay := `labels:
+  debug: null
+  env: {{ .Target }}
+template:
+  scaling:
+    maxInstanceCount: 50
+`
+	input := plugintest.NewExecuteStageInput(t, plugintest.StageInput{
+		StageName: StageCloudRunSync,
+		Spec:      &config.ApplicationConfig{ServiceManifestPath: config.ManifestPaths{"services/*.json"}},
+		Files: map[string]string{
+			"services/api.json":            base,
+			"services/api.production.json": overlay,
+			"services/api.staging.json":    `{"template": {"scaling": {"maxInstanceCount": 2}}}`,
+		},
+	})
+	src := input.Request.TargetDeploymentSource
+
+	vars := deploymentVariables{Target: "production", CommitShortHash: "0123456"}
+	paths, data, err := renderServiceManifest(context.Background(), &config.PluginConfig{}, src.ApplicationConfig.Spec, src.ApplicationDirectory, vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"services/api.json"}; !slices.Equal(paths, want) {
+		t.Errorf("expected the overlays to be left out of the manifests, got %q", paths)
+	}
+	svc, err := cloudrun.ParseServiceManifest(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := svc.Labels; len(got) != 2 || got["team"] != "payments" || got["env"] != "production" {
+		t.Errorf("expected the labels merged with the overlay, got %v", got)
+	}
+	if got := svc.Template.Scaling; got.GetMaxInstanceCount() != 50 {
+		t.Errorf("expected the overlay's max instances, got %v", got)
+	}
+	if got := svc.Template.Containers[0].Image; got != "gcr.io/p/api:0123456" {
+		t.Errorf("expected the base image, got %q", got)
+	}
+
+	// Deploy targets without an overlay deploy the base manifest
+	_, data, err = renderServiceManifest(context.Background(), &config.PluginConfig{}, src.ApplicationConfig.Spec, src.ApplicationDirectory, deploymentVariables{Target: "dev"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if svc, err = cloudrun.ParseServiceManifest(data); err != nil || svc.Template.Scaling.GetMaxInstanceCount() != 5 {
+		t.Errorf("expected the base manifest, got %v, %v", svc, err)
+	}
+}
+
+func TestAPIEndpoint(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		cfg    *config.PluginConfig
+		target config.DeployTargetConfig
+		want   string
+	}{
+		{"default", nil, config.DeployTargetConfig{}, cloudrun.DefaultEndpoint},
+		{"plugin", &config.PluginConfig{APIEndpoint: "run.sandbox.googleapis.com"}, config.DeployTargetConfig{}, "run.sandbox.googleapis.com:443"},
+		{"target overrides plugin", &config.PluginConfig{APIEndpoint: "run.sandbox.googleapis.com"}, config.DeployTargetConfig{APIEndpoint: "https://europe-west1-run.googleapis.com"}, "europe-west1-run.googleapis.com:443"},
+		{"regional target region", &config.PluginConfig{Region: "us-central1"}, config.DeployTargetConfig{APIEndpoint: config.APIEndpointRegional, Region: "us-east1"}, "us-east1-run.googleapis.com:443"},
+		{"regional plugin region", &config.PluginConfig{Region: "us-central1", APIEndpoint: config.APIEndpointRegional}, config.DeployTargetConfig{}, "us-central1-run.googleapis.com:443"},
+		{"regional without region", nil, config.DeployTargetConfig{APIEndpoint: config.APIEndpointRegional}, cloudrun.DefaultEndpoint},
+	} {
+		dt := plugintest.NewDeployTarget("production", tc.target)
+		if got := apiEndpoint(tc.cfg, dt); got != tc.want {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.want, got)
+		}
+	}
+}
+
+// replacingSecretResolver resolves secretRefs by replacing the encoded
+// {"secretRef": ...} objects.
+type replacingSecretResolver map[string]string
+
+func (r replacingSecretResolver) ResolveSecretRefs(_ context.Context, data []byte) ([]byte, error) {
+	s := string(data)
+	for ref, value := range r {
+		s = strings.ReplaceAll(s, `{"secretRef":"`+ref+`"}`, strconv.Quote(value))
+	}
+	return []byte(s), nil
+}
+
+func TestResolvePluginConfigSecrets(t *testing.T) {
+	var cfg config.PluginConfig
+	if err := json.Unmarshal([]byte(`{
+		"projectID": "my-project",
+		"stageHooks": [{"url": "https://hooks.example.com", "headers": {"Authorization": {"secretRef": "hook-token"}, "X-Team": "platform"}}]
+	}`), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.StageHooks[0].Headers["Authorization"]; got.SecretRef != "hook-token" || got.Value != "" {
+		t.Fatalf("unexpected secret header %+v", got)
+	}
+	deployTargets := []*sdk.DeployTarget[config.DeployTargetConfig]{
+		plugintest.NewDeployTarget("production", config.DeployTargetConfig{
+			Region: "us-central1",
+			Values: map[string]any{"apiKey": map[string]any{"secretRef": "api-key"}},
+		}),
+	}
+
+	data, err := json.Marshal(pluginConfigFile{Config: &cfg, DeployTargets: deployTargets})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolvedCfg, resolvedTargets, err := resolveConfigSecrets(context.Background(), replacingSecretResolver{"hook-token": "Bearer secret", "api-key": "key"}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	headers := stageHookHeaders(resolvedCfg.StageHooks[0].Headers)
+	if headers["Authorization"] != "Bearer secret" || headers["X-Team"] != "platform" {
+		t.Errorf("unexpected resolved headers %v", headers)
+	}
+	if resolvedCfg.ProjectID != "my-project" {
+		t.Errorf("expected the rest of the config kept, got project %q", resolvedCfg.ProjectID)
+	}
+	if len(resolvedTargets) != 1 || resolvedTargets[0].Name != "production" || resolvedTargets[0].Config.Region != "us-central1" {
+		t.Fatalf("unexpected resolved deploy targets %+v", resolvedTargets)
+	}
+	if got := resolvedTargets[0].Config.Values["apiKey"]; got != "key" {
+		t.Errorf("expected the deploy target value resolved, got %v", got)
+	}
+	// The given configs are not modified
+	if cfg.StageHooks[0].Headers["Authorization"].SecretRef != "hook-token" {
+		t.Error("expected the given plugin config unchanged")
+	}
+
+	// Configs without secretRefs are returned as is
+	plain := &config.PluginConfig{ProjectID: "my-project"}
+	gotCfg, gotTargets, err := resolvePluginConfigSecrets(context.Background(), plain, deployTargets[:0])
+	if err != nil || gotCfg != plain || len(gotTargets) != 0 {
+		t.Errorf("expected the config returned as is, got %+v, %v", gotCfg, err)
+	}
+}
+
+// rollbackVerifyClient is a Client serving a fixed service for rollback
+// verification.
+type rollbackVerifyClient struct {
+	cloudrun.Client
+	service  *runpb.Service
+	readyErr error
+}
+
+func (c *rollbackVerifyClient) WaitForServiceReady(context.Context, string, string, string) error {
+	return c.readyErr
+}
+
+func (c *rollbackVerifyClient) GetService(context.Context, string, string, string) (*runpb.Service, error) {
+	return c.service, nil
+}
+
+func TestVerifyRollback(t *testing.T) {
+	var requests atomic.Int32
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path != "/healthz" || !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	serving := func(statuses ...*runpb.TrafficTargetStatus) *runpb.Service {
+		return &runpb.Service{Uri: server.URL, TrafficStatuses: statuses}
+	}
+	dt := plugintest.NewDeployTarget("production", config.DeployTargetConfig{})
+	verifyCfg := &RollbackVerifyConfig{
+		Path:     "healthz",
+		Timeout:  config.Duration(50 * time.Millisecond),
+		Interval: config.Duration(10 * time.Millisecond),
+	}
+
+	tests := []struct {
+		name         string
+		client       *rollbackVerifyClient
+		unhealthy    bool
+		wantErr      string
+		wantRequests bool
+	}{
+		{
+			name:         "restored revision serves all traffic",
+			client:       &rollbackVerifyClient{service: serving(&runpb.TrafficTargetStatus{Revision: "my-service-00001", Percent: 100})},
+			wantRequests: true,
+		},
+		{
+			name: "restored revision doesn't serve traffic",
+			client: &rollbackVerifyClient{service: serving(
+				&runpb.TrafficTargetStatus{Revision: "my-service-00002", Percent: 100},
+				&runpb.TrafficTargetStatus{Revision: "my-service-00001", Tag: "stable"},
+			)},
+			wantErr: "revision my-service-00002 still serves 100% of traffic",
+		},
+		{
+			name:    "service not ready",
+			client:  &rollbackVerifyClient{readyErr: errors.New("revision failed")},
+			wantErr: "service is not ready: revision failed",
+		},
+		{
+			name:      "health check failing",
+			client:    &rollbackVerifyClient{service: serving(&runpb.TrafficTargetStatus{Revision: "my-service-00001", Percent: 100})},
+			unhealthy: true,
+			// The last check may be cut by the deadline rather than get the 503
+			wantErr:      "did not pass within",
+			wantRequests: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			healthy.Store(!tt.unhealthy)
+
+			err := verifyRollback(context.Background(), tt.client, dt, "p", "r", "my-service", "my-service-00001", verifyCfg, &plugintest.LogRecorder{})
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("expected the rollback to be verified, got %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+			if got := requests.Load() > 0; got != tt.wantRequests {
+				t.Errorf("expected health requests %v, got %d", tt.wantRequests, requests.Load())
+			}
+		})
+	}
+}
+
+func TestBuildServiceResourceStates(t *testing.T) {
+	svc := &runpb.Service{
+		Name:              "projects/p/locations/r/services/my-service",
+		Uid:               "8f3c0e1a",
+		Etag:              `"abc"`,
+		Uri:               "https://my-service-abc123-uc.a.run.app",
+		TerminalCondition: &runpb.Condition{Type: "Ready", State: runpb.Condition_CONDITION_SUCCEEDED},
+		Template: &runpb.RevisionTemplate{
+			Containers: []*runpb.Container{{Image: "gcr.io/p/app:v2"}},
+		},
+		Traffic: []*runpb.TrafficTarget{
+			{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "my-service-00002", Percent: 100},
+			{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "my-service-00001", Tag: "old"},
+		},
+	}
+	revision := func(name string, state runpb.Condition_State, message string) *runpb.Revision {
+		return &runpb.Revision{
+			Name:       svc.Name + "/revisions/" + name,
+			Uid:        name + "-uid",
+			Conditions: []*runpb.Condition{{Type: "Ready", State: state, Message: message}},
+			Containers: []*runpb.Container{{Image: "gcr.io/p/app:" + name}},
+		}
+	}
+	revisions := []*runpb.Revision{
+		revision("my-service-00003", runpb.Condition_CONDITION_SUCCEEDED, ""),
+		revision("my-service-00002", runpb.Condition_CONDITION_FAILED, "container crashed"),
+		revision("my-service-00001", runpb.Condition_CONDITION_FAILED, "image not found"),
+	}
+
+	states, err := buildServiceResourceStates(svc, revisions, "production")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 4 {
+		t.Fatalf("expected the service and 3 revisions, got %d states", len(states))
+	}
+
+	t.Run("service YAML", func(t *testing.T) {
+		data := states[0].ResourceMetadata[ResourceMetadataKeyYAML]
+		for _, field := range []string{"uid:", "etag:", "uri:", "terminalCondition:"} {
+			if strings.Contains(data, field) {
+				t.Errorf("expected output-only field %s to be dropped, got:\n%s", field, data)
+			}
+		}
+		parsed, err := cloudrun.ParseServiceManifest([]byte(data))
+		if err != nil {
+			t.Fatalf("expected the YAML to parse as a manifest: %v", err)
+		}
+		if parsed.Name != "my-service" || parsed.Template.Containers[0].Image != "gcr.io/p/app:v2" {
+			t.Errorf("unexpected service from the YAML: %v", parsed)
+		}
+	})
+
+	t.Run("revision YAML", func(t *testing.T) {
+		data := states[1].ResourceMetadata[ResourceMetadataKeyYAML]
+		if !strings.Contains(data, "name: my-service-00003") || !strings.Contains(data, "gcr.io/p/app:my-service-00003") {
+			t.Errorf("expected the short name and image in the YAML, got:\n%s", data)
+		}
+		if strings.Contains(data, "uid:") || strings.Contains(data, "conditions:") {
+			t.Errorf("expected output-only fields to be dropped, got:\n%s", data)
+		}
+	})
+
+	t.Run("revision health", func(t *testing.T) {
+		want := []struct {
+			name        string
+			health      sdk.ResourceHealthStatus
+			description string
+		}{
+			{"my-service", sdk.ResourceHealthStateHealthy, ""},
+			{"my-service-00003", sdk.ResourceHealthStateHealthy, ""},
+			{"my-service-00002", sdk.ResourceHealthStateUnhealthy, "container crashed"},
+			{"my-service-00001", sdk.ResourceHealthStateHealthy, "Not serving traffic: image not found"},
+		}
+		for i, w := range want {
+			s := states[i]
+			if s.Name != w.name || s.HealthStatus != w.health || s.HealthDescription != w.description || s.DeployTarget != "production" {
+				t.Errorf("state %d: expected %s %v %q, got %s %v %q", i, w.name, w.health, w.description, s.Name, s.HealthStatus, s.HealthDescription)
+			}
+		}
+	})
+}
+
+func TestCandidateTagURL(t *testing.T) {
+	var requests, failures atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures.Load() || r.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	dt := plugintest.NewDeployTarget("production", config.DeployTargetConfig{})
+	svc := &runpb.Service{
+		Uri: "https://my-service.a.run.app",
+		TrafficStatuses: []*runpb.TrafficTargetStatus{
+			{Revision: "my-service-00002", Tag: "candidate", Uri: server.URL},
+		},
+	}
+	readiness := &TagReadinessConfig{
+		Path:     "/healthz",
+		Timeout:  config.Duration(100 * time.Millisecond),
+		Interval: config.Duration(5 * time.Millisecond),
+	}
+
+	tests := []struct {
+		name         string
+		tag          string
+		readiness    *TagReadinessConfig
+		failures     int32
+		want         string
+		wantRequests bool
+		wantWarning  string
+	}{
+		{
+			name:         "ready after retries",
+			tag:          "candidate",
+			readiness:    readiness,
+			failures:     2,
+			want:         server.URL,
+			wantRequests: true,
+		},
+		{
+			name:         "non-2xx until timeout",
+			tag:          "candidate",
+			readiness:    readiness,
+			failures:     1000,
+			wantRequests: true,
+			wantWarning:  "Tag URL is not ready, not exposing it",
+		},
+		{
+			name:      "missing tag URL",
+			tag:       "missing",
+			readiness: readiness,
+		},
+		{
+			name: "readiness not configured",
+			tag:  "candidate",
+			want: server.URL,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			failures.Store(tt.failures)
+			lp := &plugintest.LogRecorder{}
+
+			if got := candidateTagURL(context.Background(), dt, svc, tt.tag, tt.readiness, lp); got != tt.want {
+				t.Errorf("expected URL %q, got %q", tt.want, got)
+			}
+			if got := requests.Load() > 0; got != tt.wantRequests {
+				t.Errorf("expected requests %v, got %d", tt.wantRequests, requests.Load())
+			}
+			if tt.wantWarning != "" && !lp.Contains(plugintest.LogLevelInfo, tt.wantWarning) {
+				t.Errorf("expected a warning %q, got %v", tt.wantWarning, lp.Lines())
+			}
+		})
+	}
+}
+
+// overridesClient is a Cloud Run client serving a service whose latest
+// revision was deployed with canary overrides, recording the deployed service.
+type overridesClient struct {
+	cloudrun.Client
+	service   *runpb.Service
+	revisions []*runpb.Revision
+	deployed  *runpb.Service
+}
+
+func (c *overridesClient) GetService(ctx context.Context, project, region, service string) (*runpb.Service, error) {
+	return proto.Clone(c.service).(*runpb.Service), nil
+}
+
+func (c *overridesClient) ListRevisions(ctx context.Context, project, region, service string) ([]*runpb.Revision, error) {
+	return c.revisions, nil
+}
+
+func (c *overridesClient) CreateOrUpdateService(ctx context.Context, svc *runpb.Service) (*runpb.Service, error) {
+	c.deployed = svc
+	return &runpb.Service{LatestCreatedRevision: "my-service-00004"}, nil
+}
+
+func (c *overridesClient) WaitForServiceReady(ctx context.Context, project, region, service string) error {
+	return nil
+}
+
+func TestRevertCanaryOverrides(t *testing.T) {
+	latest := runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST
+	byRevision := runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION
+	maxInstances := int32(2)
+	svc := &runpb.Service{
+		Template: &runpb.RevisionTemplate{
+			Containers: []*runpb.Container{{Image: "gcr.io/p/app:v2"}},
+		},
+		Traffic: []*runpb.TrafficTarget{
+			{Type: latest, Percent: 50, Tag: "canary"},
+			{Type: byRevision, Revision: "my-service-00002", Percent: 50, Tag: "stable"},
+			{Type: byRevision, Revision: "my-service-00001", Tag: "preview"},
+			{Type: byRevision, Revision: "my-service-00000", Tag: "old"},
+		},
+	}
+	if err := cloudrun.ApplyRevisionOverrides(svc, cloudrun.RevisionOverrides{MaxInstances: &maxInstances}); err != nil {
+		t.Fatal(err)
+	}
+	client := &overridesClient{
+		service: svc,
+		revisions: []*runpb.Revision{
+			{Name: "my-service-00001"},
+			{Name: "my-service-00002"},
+			{Name: "my-service-00003"},
+		},
+	}
+	lp := &plugintest.LogRecorder{}
+
+	revision, err := revertCanaryOverrides(context.Background(), client, "my-project", "us-central1", "my-service", "canary", lp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revision != "my-service-00004" {
+		t.Errorf("expected the reverted revision my-service-00004, got %s", revision)
+	}
+	if cloudrun.HasRevisionOverrides(client.deployed.Template) {
+		t.Error("expected the canary overrides to be reverted")
+	}
+
+	type target struct {
+		typ      runpb.TrafficTargetAllocationType
+		revision string
+		percent  int32
+		tag      string
+	}
+	want := []target{
+		{latest, "", 100, "canary"},
+		{byRevision, "my-service-00002", 0, "stable"},
+		{byRevision, "my-service-00001", 0, "preview"},
+	}
+	traffic := client.deployed.Traffic
+	if len(traffic) != len(want) {
+		t.Fatalf("expected %d targets, got %v", len(want), traffic)
+	}
+	for i, w := range want {
+		got := target{traffic[i].Type, traffic[i].Revision, traffic[i].Percent, traffic[i].Tag}
+		if got != w {
+			t.Errorf("target %d: expected %v, got %v", i, w, got)
+		}
+	}
+	if !lp.Contains(plugintest.LogLevelInfo, "Live traffic points at revision my-service-00000 (deleted, 0%, tag old)") {
+		t.Errorf("expected the deleted revision to be reported, got %v", lp.Lines())
+	}
+
+	// Without overrides, nothing is deployed
+	client.service, client.deployed = client.deployed, nil
+	if revision, err := revertCanaryOverrides(context.Background(), client, "my-project", "us-central1", "my-service", "canary", lp); err != nil || revision != "" || client.deployed != nil {
+		t.Errorf("expected no deployment, got %q, %v", revision, err)
+	}
+}
@@ -0,0 +1,278 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package plugintest provides fixtures for unit tests of the helpers of stage
+// executors, such as manifest loading and stage config handling.
+//
+// It builds ExecuteStageInput values the way piped sends them: the
+// application config, the stage config as JSON, and deployment sources backed
+// by temporary directories holding the manifests.
+//
+// Example:
+//
+//	input := plugintest.NewExecuteStageInput(t, plugintest.StageInput{
+//		StageName:   "CLOUDRUN_PROMOTE",
+//		StageConfig: map[string]any{"percent": 10},
+//		Spec:        &config.ApplicationConfig{Input: config.InputConfig{ServiceName: "my-service"}},
+//		Files:       map[string]string{"service.yaml": plugintest.ServiceManifest("my-service", "gcr.io/p/app:v2")},
+//	})
+//	lp := &plugintest.LogRecorder{}
+//
+// The SDK client (input.Client) is nil: piped-plugin-sdk-go v0.1.0 has no way
+// to build one outside piped. ExecuteStage and the stage executors log through
+// it and share deployment metadata with it, so they panic with these inputs;
+// pass the inputs to helpers that only read the request instead.
+package plugintest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// Defaults of the deployment built by NewExecuteStageInput.
+const (
+	DefaultApplicationID   = "test-app"
+	DefaultApplicationName = "test-app"
+	DefaultDeploymentID    = "test-deployment"
+)
+
+// StageInput describes the stage execution to build.
+type StageInput struct {
+	// StageName is the stage to execute, e.g. "CLOUDRUN_SYNC".
+	StageName string
+
+	// StageConfig is the `with` block of the stage. []byte and string values
+	// are used as is, other values are encoded as JSON.
+	StageConfig any
+
+	// ApplicationID, ApplicationName, and DeploymentID identify the deployment.
+	// Default: DefaultApplicationID, DefaultApplicationName, DefaultDeploymentID
+	ApplicationID   string
+	ApplicationName string
+	DeploymentID    string
+
+	// Spec is the application config of the target deployment source.
+	// Default: an empty config
+	Spec *config.ApplicationConfig
+
+	// RunningSpec is the application config of the running deployment source.
+	// Default: Spec
+	RunningSpec *config.ApplicationConfig
+
+	// Files are written to the application directory of the target deployment
+	// source, keyed by path relative to it, e.g. {"service.yaml": "..."}.
+	Files map[string]string
+
+	// RunningFiles are written to the application directory of the running
+	// deployment source.
+	// Default: Files
+	RunningFiles map[string]string
+}
+
+// NewExecuteStageInput builds the input of a stage execution. The deployment
+// sources are written to temporary directories removed when the test ends.
+// The SDK client of the input is nil, see the package doc.
+func NewExecuteStageInput(t testing.TB, in StageInput) *sdk.ExecuteStageInput[config.ApplicationConfig] {
+	t.Helper()
+
+	stageConfig, err := encodeStageConfig(in.StageConfig)
+	if err != nil {
+		t.Fatalf("invalid stage config: %v", err)
+	}
+
+	// Fill unset fields with defaults
+	if in.ApplicationID == "" {
+		in.ApplicationID = DefaultApplicationID
+	}
+	if in.ApplicationName == "" {
+		in.ApplicationName = DefaultApplicationName
+	}
+	if in.DeploymentID == "" {
+		in.DeploymentID = DefaultDeploymentID
+	}
+	if in.Spec == nil {
+		in.Spec = &config.ApplicationConfig{}
+	}
+	if in.RunningSpec == nil {
+		in.RunningSpec = in.Spec
+	}
+	if in.RunningFiles == nil {
+		in.RunningFiles = in.Files
+	}
+
+	return &sdk.ExecuteStageInput[config.ApplicationConfig]{
+		Request: sdk.ExecuteStageRequest[config.ApplicationConfig]{
+			StageName:               in.StageName,
+			StageConfig:             stageConfig,
+			RunningDeploymentSource: NewDeploymentSource(t, in.RunningSpec, in.RunningFiles),
+			TargetDeploymentSource:  NewDeploymentSource(t, in.Spec, in.Files),
+			Deployment: sdk.Deployment{
+				ID:              in.DeploymentID,
+				ApplicationID:   in.ApplicationID,
+				ApplicationName: in.ApplicationName,
+			},
+		},
+	}
+}
+
+// NewDeploymentSource writes the files to a temporary application directory
+// and returns the deployment source of the application config.
+func NewDeploymentSource(t testing.TB, spec *config.ApplicationConfig, files map[string]string) sdk.DeploymentSource[config.ApplicationConfig] {
+	t.Helper()
+
+	appDir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(appDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create directory of %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	return sdk.DeploymentSource[config.ApplicationConfig]{
+		ApplicationDirectory: appDir,
+		ApplicationConfig: &sdk.ApplicationConfig[config.ApplicationConfig]{
+			Spec: spec,
+		},
+	}
+}
+
+// NewDeployTarget returns a deploy target with the config.
+func NewDeployTarget(name string, cfg config.DeployTargetConfig) *sdk.DeployTarget[config.DeployTargetConfig] {
+	return &sdk.DeployTarget[config.DeployTargetConfig]{
+		Name:   name,
+		Config: cfg,
+	}
+}
+
+// ServiceManifest returns a minimal service manifest (JSON) serving the image on port 8080.
+func ServiceManifest(name, image string) string {
+	return fmt.Sprintf(`{
+  "name": %q,
+  "template": {
+    "labels": {"app": %q},
+    "containers": [{"image": %q, "ports": [{"containerPort": 8080}]}]
+  }
+}`, name, name, image)
+}
+
+// JobManifest returns a minimal job manifest (JSON) running the image.
+func JobManifest(name, image string) string {
+	return fmt.Sprintf(`{
+  "name": %q,
+  "template": {
+    "template": {
+      "containers": [{"image": %q}]
+    }
+  }
+}`, name, image)
+}
+
+// encodeStageConfig encodes the stage config as JSON.
+func encodeStageConfig(v any) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	default:
+		return json.Marshal(v)
+	}
+}
+
+// Ensure LogRecorder implements the StageLogPersister interface.
+var _ sdk.StageLogPersister = (*LogRecorder)(nil)
+
+// LogLevel is the level of a recorded log line.
+type LogLevel string
+
+const (
+	LogLevelInfo    LogLevel = "INFO"
+	LogLevelSuccess LogLevel = "SUCCESS"
+	LogLevelError   LogLevel = "ERROR"
+)
+
+// LogLine is a line logged by a stage.
+type LogLine struct {
+	Level   LogLevel
+	Message string
+}
+
+// LogRecorder is a StageLogPersister recording the stage logs in memory.
+// The zero value is ready to use.
+type LogRecorder struct {
+	mu    sync.Mutex
+	lines []LogLine
+}
+
+func (r *LogRecorder) record(level LogLevel, message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines = append(r.lines, LogLine{Level: level, Message: message})
+}
+
+// Write records the data as an info line.
+func (r *LogRecorder) Write(data []byte) (int, error) {
+	r.record(LogLevelInfo, string(data))
+	return len(data), nil
+}
+
+func (r *LogRecorder) Info(log string) { r.record(LogLevelInfo, log) }
+
+func (r *LogRecorder) Infof(format string, a ...interface{}) {
+	r.record(LogLevelInfo, fmt.Sprintf(format, a...))
+}
+
+func (r *LogRecorder) Success(log string) { r.record(LogLevelSuccess, log) }
+
+func (r *LogRecorder) Successf(format string, a ...interface{}) {
+	r.record(LogLevelSuccess, fmt.Sprintf(format, a...))
+}
+
+func (r *LogRecorder) Error(log string) { r.record(LogLevelError, log) }
+
+func (r *LogRecorder) Errorf(format string, a ...interface{}) {
+	r.record(LogLevelError, fmt.Sprintf(format, a...))
+}
+
+// Lines returns the recorded lines.
+func (r *LogRecorder) Lines() []LogLine {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]LogLine(nil), r.lines...)
+}
+
+// Contains reports whether a line of the level contains the substring.
+// An empty level matches any level.
+func (r *LogRecorder) Contains(level LogLevel, substr string) bool {
+	for _, l := range r.Lines() {
+		if (level == "" || l.Level == level) && strings.Contains(l.Message, substr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,225 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+	"sigs.k8s.io/yaml"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// DefaultConfigPollInterval is how often the plugin config file is checked
+// for changes.
+const DefaultConfigPollInterval = 30 * time.Second
+
+// pluginConfigFile is the plugin entry of the piped config, as JSON or YAML:
+//
+//	config:
+//	  projectID: my-default-project
+//	deployTargets:
+//	  - name: production
+//	    config:
+//	      credentialsFile: /etc/piped/gcp-prod-key.json
+type pluginConfigFile struct {
+	Config        *config.PluginConfig                           `json:"config"`
+	DeployTargets []*sdk.DeployTarget[config.DeployTargetConfig] `json:"deployTargets"`
+}
+
+// configReloader holds the plugin config and deploy targets reloaded from a
+// file, which take precedence over the ones piped started the plugin with.
+//
+// Each call resolves the config once when it starts, so a reload doesn't
+// change the config of stages and previews already running.
+type configReloader struct {
+	// credentials is restarted with the reloaded deploy targets.
+	credentials *credentialsWatcher
+
+	mu            sync.RWMutex
+	path          string
+	modTime       time.Time
+	cfg           *config.PluginConfig
+	deployTargets map[string]*sdk.DeployTarget[config.DeployTargetConfig]
+}
+
+// newConfigReloader returns a reloader without a file, passing the config
+// given by piped through.
+func newConfigReloader(credentials *credentialsWatcher) *configReloader {
+	return &configReloader{credentials: credentials}
+}
+
+// resolve returns the reloaded plugin config and the reloaded version of
+// each deploy target. Deploy targets missing from the file, and everything
+// before the first reload, are returned as given.
+func (r *configReloader) resolve(
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+) (*config.PluginConfig, []*sdk.DeployTarget[config.DeployTargetConfig]) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.cfg == nil {
+		return cfg, deployTargets
+	}
+	resolved := make([]*sdk.DeployTarget[config.DeployTargetConfig], 0, len(deployTargets))
+	for _, dt := range deployTargets {
+		if reloaded, ok := r.deployTargets[dt.Name]; ok {
+			dt = reloaded
+		}
+		resolved = append(resolved, dt)
+	}
+	return r.cfg, resolved
+}
+
+// load reads and validates the config file. An invalid file leaves the
+// current config in place. Once loaded, the Admin API rate limiters are
+// rebuilt and the credentials of the deploy targets validated again.
+func (r *configReloader) load(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read plugin config file: %w", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read plugin config file: %w", err)
+	}
+	cfg, deployTargets, err := parsePluginConfigFile(data)
+	if err != nil {
+		return fmt.Errorf("invalid plugin config file %s: %w", path, err)
+	}
+
+	targets := make([]*sdk.DeployTarget[config.DeployTargetConfig], 0, len(deployTargets))
+	r.mu.Lock()
+	r.path = path
+	r.modTime = info.ModTime()
+	r.cfg = cfg
+	r.deployTargets = make(map[string]*sdk.DeployTarget[config.DeployTargetConfig], len(deployTargets))
+	for _, dt := range deployTargets {
+		r.deployTargets[dt.Name] = dt
+		targets = append(targets, dt)
+	}
+	r.mu.Unlock()
+
+	cloudrun.ResetRateLimiters()
+	r.credentials.rewatch(cfg, targets)
+	return nil
+}
+
+// modified reports whether the config file changed since it was loaded.
+func (r *configReloader) modified() bool {
+	r.mu.RLock()
+	path, modTime := r.path, r.modTime
+	r.mu.RUnlock()
+
+	info, err := os.Stat(path)
+	return err == nil && !info.ModTime().Equal(modTime)
+}
+
+// run reloads the config file on SIGHUP, and when piped rewrites it, until
+// ctx is done.
+func (r *configReloader) run(ctx context.Context, interval time.Duration) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+		case <-ticker.C:
+			if !r.modified() {
+				continue
+			}
+		}
+		r.mu.RLock()
+		path := r.path
+		r.mu.RUnlock()
+		if err := r.load(path); err != nil {
+			log.Printf("Failed to reload plugin config, keeping the current config: %v", err)
+			continue
+		}
+		log.Printf("Reloaded plugin config from %s", path)
+	}
+}
+
+// parsePluginConfigFile parses the plugin config and its deploy targets.
+func parsePluginConfigFile(data []byte) (*config.PluginConfig, []*sdk.DeployTarget[config.DeployTargetConfig], error) {
+	var file pluginConfigFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, nil, err
+	}
+	if file.Config == nil {
+		file.Config = &config.PluginConfig{}
+	}
+	if _, err := outputStyle(file.Config); err != nil {
+		return nil, nil, err
+	}
+	if err := checkCredentialsFile(file.Config.CredentialsFile); err != nil {
+		return nil, nil, err
+	}
+
+	names := make(map[string]bool, len(file.DeployTargets))
+	for i, dt := range file.DeployTargets {
+		if dt == nil || dt.Name == "" {
+			return nil, nil, fmt.Errorf("deploy target %d has no name", i)
+		}
+		if names[dt.Name] {
+			return nil, nil, fmt.Errorf("deploy target %s is defined more than once", dt.Name)
+		}
+		names[dt.Name] = true
+		if err := checkCredentialsFile(dt.Config.CredentialsFile); err != nil {
+			return nil, nil, fmt.Errorf("deploy target %s: %w", dt.Name, err)
+		}
+	}
+	return file.Config, file.DeployTargets, nil
+}
+
+// checkCredentialsFile checks that a configured credentials file exists, so
+// a reload naming a missing key file is rejected instead of breaking calls.
+func checkCredentialsFile(path string) error {
+	if path == "" {
+		return nil
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("credentials file: %w", err)
+	}
+	return nil
+}
+
+// WatchConfigFile loads the plugin config and deploy targets from the file,
+// then reloads them on SIGHUP or when the file changes, checked every
+// interval, until ctx is done. Stages, previews, and live state calls
+// already running keep the config they started with.
+func (p *cloudrunPlugin) WatchConfigFile(ctx context.Context, path string, interval time.Duration) error {
+	if err := p.config.load(path); err != nil {
+		return err
+	}
+	go p.config.run(ctx, interval)
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/render"
+)
+
+// renderServiceManifest finds the service manifests of the application in
+// appDir and renders them with the renderer of the application config. It
+// returns the manifest paths, relative to appDir, and the rendered manifest.
+// Several manifests are rendered one by one and joined into a multi-document
+// manifest.
+func renderServiceManifest(ctx context.Context, cfg *config.PluginConfig, spec *config.ApplicationConfig, appDir string, vars deploymentVariables) ([]string, []byte, error) {
+	manifestPaths, err := resolveServiceManifestPaths(cfg, appDir, spec.ServiceManifestPath)
+	if err != nil || len(manifestPaths) == 1 {
+		var manifestPath string
+		if err == nil {
+			manifestPath = manifestPaths[0]
+		}
+		data, err := renderManifest(ctx, spec, appDir, manifestPath, err, vars)
+		return manifestPaths, data, err
+	}
+
+	docs := make([][]byte, 0, len(manifestPaths))
+	for _, manifestPath := range manifestPaths {
+		data, err := renderManifest(ctx, spec, appDir, manifestPath, nil, vars)
+		if err != nil {
+			return manifestPaths, nil, fmt.Errorf("%s: %w", manifestPath, err)
+		}
+		docs = append(docs, bytes.TrimSpace(data))
+	}
+	return manifestPaths, bytes.Join(docs, []byte("\n---\n")), nil
+}
+
+// renderJobManifest finds the job manifest of the application in appDir and
+// renders it with the renderer of the application config.
+func renderJobManifest(ctx context.Context, spec *config.ApplicationConfig, appDir string, vars deploymentVariables) (string, []byte, error) {
+	manifestPath, err := resolveJobManifestPath(appDir, spec.JobManifestPath)
+	data, err := renderManifest(ctx, spec, appDir, manifestPath, err, vars)
+	return manifestPath, data, err
+}
+
+// rendererName returns the name of the renderer of the application config.
+func rendererName(spec *config.ApplicationConfig) string {
+	if spec.Renderer == nil || spec.Renderer.Name == "" {
+		return render.Default
+	}
+	return spec.Renderer.Name
+}
+
+// renderManifest renders the manifest with the renderer of the application
+// config. pathErr is the error finding the manifest, which only the remote
+// renderer, fetching the manifest elsewhere, doesn't need.
+func renderManifest(ctx context.Context, spec *config.ApplicationConfig, appDir, manifestPath string, pathErr error, vars deploymentVariables) ([]byte, error) {
+	var name string
+	var options []byte
+	if r := spec.Renderer; r != nil {
+		name = r.Name
+		if len(r.With) > 0 {
+			var err error
+			if options, err = json.Marshal(r.With); err != nil {
+				return nil, fmt.Errorf("invalid renderer options: %w", err)
+			}
+		}
+	}
+	if pathErr != nil && name != render.Remote {
+		return nil, pathErr
+	}
+
+	renderer, err := render.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := renderer.Render(ctx, render.Input{
+		AppDir:       appDir,
+		ManifestPath: manifestPath,
+		Variables:    vars,
+		Options:      options,
+	})
+	if err != nil || manifestPath == "" {
+		return data, err
+	}
+	// CUE and Jsonnet manifests are evaluated after rendering the variables
+	if data, err = cloudrun.EvaluateManifest(ctx, filepath.Join(appDir, manifestPath), data); err != nil {
+		return nil, err
+	}
+	return applyOverlay(ctx, spec, appDir, manifestPath, data, vars)
+}
@@ -0,0 +1,50 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// Default audit annotation keys of revisions.
+const (
+	defaultCommitAnnotation         = "pipecd.dev/commit"
+	defaultAuthorAnnotation         = "pipecd.dev/commit-author"
+	defaultApplicationURLAnnotation = "pipecd.dev/application-url"
+)
+
+// revisionAuditAnnotations returns the audit annotations of a revision
+// deployed with the variables. Unknown values are left empty.
+func revisionAuditAnnotations(cfg *config.RevisionAnnotationsConfig, vars deploymentVariables) map[string]string {
+	keyOrDefault := func(key, def string) string {
+		if key == "" {
+			return def
+		}
+		return key
+	}
+
+	var appURL string
+	if cfg.ConsoleURL != "" && vars.ApplicationID != "" {
+		appURL = strings.TrimSuffix(cfg.ConsoleURL, "/") + "/applications/" + url.PathEscape(vars.ApplicationID)
+	}
+	return map[string]string{
+		keyOrDefault(cfg.CommitKey, defaultCommitAnnotation):                 vars.CommitHash,
+		keyOrDefault(cfg.AuthorKey, defaultAuthorAnnotation):                 vars.CommitAuthor,
+		keyOrDefault(cfg.ApplicationURLKey, defaultApplicationURLAnnotation): appURL,
+	}
+}
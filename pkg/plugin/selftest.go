@@ -0,0 +1,202 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// selfTestImage is the image of the service the self-test validates creating.
+const selfTestImage = "us-docker.pkg.dev/cloudrun/container/hello"
+
+// The checks the self-test runs against each deploy target, in order.
+const (
+	selfTestCheckList   = "list"
+	selfTestCheckGet    = "get"
+	selfTestCheckCreate = "create"
+)
+
+var selfTestChecks = []string{selfTestCheckList, selfTestCheckGet, selfTestCheckCreate}
+
+// SelfTestResult is the outcome of the self-test checks of a deploy target.
+type SelfTestResult struct {
+	Target  string
+	Project string
+	Region  string
+
+	// Errors maps the failed checks to their error. Checks not run, e.g.
+	// without a project and region, are failed too.
+	Errors map[string]error
+}
+
+// Ready reports whether all checks passed.
+func (r SelfTestResult) Ready() bool {
+	return len(r.Errors) == 0
+}
+
+// RunSelfTest runs the self-test against each deploy target of the plugin
+// config file, in the format of pluginConfigFile, and writes the readiness
+// matrix to w. It lists services, gets a disposable service, and validates
+// creating it without creating it, so a new piped install can be verified
+// before deploying anything. It reports whether all deploy targets are ready.
+func RunSelfTest(ctx context.Context, path string, w io.Writer) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read plugin config file: %w", err)
+	}
+	cfg, deployTargets, err := parsePluginConfigFile(data)
+	if err != nil {
+		return false, fmt.Errorf("invalid plugin config file %s: %w", path, err)
+	}
+	if len(deployTargets) == 0 {
+		return false, fmt.Errorf("plugin config file %s has no deploy targets", path)
+	}
+
+	service, err := selfTestServiceName()
+	if err != nil {
+		return false, err
+	}
+	fmt.Fprintf(w, "Testing %d deploy targets with disposable service %s\n\n", len(deployTargets), service)
+
+	results := make([]SelfTestResult, 0, len(deployTargets))
+	ready := true
+	for _, dt := range deployTargets {
+		result := selfTest(ctx, cfg, dt, service, newTargetClient)
+		ready = ready && result.Ready()
+		results = append(results, result)
+	}
+	return ready, writeSelfTestMatrix(w, results)
+}
+
+// selfTestServiceName returns a random name no service is expected to have.
+func selfTestServiceName() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate the service name: %w", err)
+	}
+	return "pipecd-selftest-" + hex.EncodeToString(b), nil
+}
+
+// selfTest runs the checks against the deploy target. Every check runs even
+// if an earlier one failed, so the matrix shows each missing permission.
+func selfTest(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	dt *sdk.DeployTarget[config.DeployTargetConfig],
+	service string,
+	newClient func(context.Context, *config.PluginConfig, *sdk.DeployTarget[config.DeployTargetConfig]) (cloudrun.Client, error),
+) SelfTestResult {
+	result := SelfTestResult{
+		Target:  dt.Name,
+		Project: dt.Config.ProjectID,
+		Region:  dt.Config.Region,
+		Errors:  make(map[string]error),
+	}
+	if result.Project == "" {
+		result.Project = cfg.ProjectID
+	}
+	if result.Region == "" {
+		result.Region = cfg.Region
+	}
+	failAll := func(err error) SelfTestResult {
+		for _, check := range selfTestChecks {
+			result.Errors[check] = err
+		}
+		return result
+	}
+	if result.Project == "" || result.Region == "" {
+		return failAll(fmt.Errorf("projectID and region are not set in the deploy target or plugin config"))
+	}
+
+	client, err := newClient(ctx, cfg, dt)
+	if err != nil {
+		return failAll(fmt.Errorf("failed to create Cloud Run client: %w", err))
+	}
+	defer client.Close()
+
+	if err := client.CheckAccess(ctx, result.Project, result.Region); err != nil {
+		result.Errors[selfTestCheckList] = err
+	}
+
+	// The disposable service must not exist, so only not found passes
+	if _, err := client.GetService(ctx, result.Project, result.Region, service); err == nil {
+		result.Errors[selfTestCheckGet] = fmt.Errorf("service %s unexpectedly exists", service)
+	} else if !cloudrun.IsNotFound(err) {
+		result.Errors[selfTestCheckGet] = err
+	}
+
+	svc := &runpb.Service{
+		Template: &runpb.RevisionTemplate{
+			Containers: []*runpb.Container{{Image: selfTestImage}},
+		},
+	}
+	cloudrun.SetServiceName(svc, result.Project, result.Region, service)
+	if err := client.ValidateService(ctx, svc); err != nil {
+		result.Errors[selfTestCheckCreate] = err
+	}
+	return result
+}
+
+// writeSelfTestMatrix writes a row per deploy target with the outcome of each
+// check, followed by the errors of the failed checks.
+func writeSelfTestMatrix(w io.Writer, results []SelfTestResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprint(tw, "DEPLOY TARGET\tPROJECT\tREGION")
+	for _, check := range selfTestChecks {
+		fmt.Fprintf(tw, "\t%s", check)
+	}
+	fmt.Fprint(tw, "\tREADY\n")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s", r.Target, r.Project, r.Region)
+		for _, check := range selfTestChecks {
+			outcome := "ok"
+			if r.Errors[check] != nil {
+				outcome = "FAIL"
+			}
+			fmt.Fprintf(tw, "\t%s", outcome)
+		}
+		fmt.Fprintf(tw, "\t%t\n", r.Ready())
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	var failures []string
+	for _, r := range results {
+		for _, check := range selfTestChecks {
+			if err := r.Errors[check]; err != nil {
+				failures = append(failures, fmt.Sprintf("%s %s: %s", r.Target, check, cloudrun.DescribeError(err)))
+			}
+		}
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	_, err := fmt.Fprintf(w, "\n%s\n", strings.Join(failures, "\n"))
+	return err
+}
@@ -0,0 +1,83 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// serviceDefaultsTemplateData is the data available to the description template.
+type serviceDefaultsTemplateData struct {
+	Service string
+	Target  string
+	Project string
+	Region  string
+}
+
+// applyDeployTargetDefaults merges the deploy target's service defaults into
+// the service and returns the defaults that were overridden by the manifest.
+func applyDeployTargetDefaults(
+	service *runpb.Service,
+	dt *sdk.DeployTarget[config.DeployTargetConfig],
+	serviceName, project, region string,
+) ([]cloudrun.DefaultsCollision, error) {
+	cfg := dt.Config.ServiceDefaults
+	if cfg == nil {
+		return nil, nil
+	}
+
+	defaults := cloudrun.ServiceDefaults{
+		Labels:      cfg.Labels,
+		Annotations: cfg.Annotations,
+	}
+
+	if cfg.Description != "" {
+		tmpl, err := template.New("description").Option("missingkey=error").Parse(cfg.Description)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse service description template: %w", err)
+		}
+		var b strings.Builder
+		err = tmpl.Execute(&b, serviceDefaultsTemplateData{
+			Service: serviceName,
+			Target:  dt.Name,
+			Project: project,
+			Region:  region,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to render service description template: %w", err)
+		}
+		defaults.Description = b.String()
+	}
+
+	return cloudrun.ApplyServiceDefaults(service, defaults), nil
+}
+
+// formatDefaultsCollision formats a collision for display.
+// Example: "label env: manifest value \"staging\" kept (deploy target default: \"prod\")"
+func formatDefaultsCollision(c cloudrun.DefaultsCollision) string {
+	field := c.Kind
+	if c.Key != "" {
+		field = fmt.Sprintf("%s %s", c.Kind, c.Key)
+	}
+	return fmt.Sprintf("%s: manifest value %q kept (deploy target default: %q)", field, c.Manifest, c.Default)
+}
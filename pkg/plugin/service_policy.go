@@ -0,0 +1,113 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// checkServiceAllowed returns an error if the deploy target does not accept
+// the service name, according to its allowedServices and deniedServices.
+func checkServiceAllowed(dt *sdk.DeployTarget[config.DeployTargetConfig], serviceName string) error {
+	denied, err := matchServicePattern(dt.Config.DeniedServices, serviceName)
+	if err != nil {
+		return fmt.Errorf("invalid deniedServices of deploy target %s: %w", dt.Name, err)
+	}
+	if denied != "" {
+		return fmt.Errorf("service %s is denied by deploy target %s (pattern %q)", serviceName, dt.Name, denied)
+	}
+
+	if len(dt.Config.AllowedServices) == 0 {
+		return nil
+	}
+	allowed, err := matchServicePattern(dt.Config.AllowedServices, serviceName)
+	if err != nil {
+		return fmt.Errorf("invalid allowedServices of deploy target %s: %w", dt.Name, err)
+	}
+	if allowed == "" {
+		return fmt.Errorf("service %s is not allowed by deploy target %s (allowed: %v)", serviceName, dt.Name, dt.Config.AllowedServices)
+	}
+	return nil
+}
+
+// matchServicePattern returns the first pattern matching the service name, or
+// an empty string if none matches.
+func matchServicePattern(patterns []string, serviceName string) (string, error) {
+	for _, p := range patterns {
+		ok, err := path.Match(p, serviceName)
+		if err != nil {
+			return "", fmt.Errorf("pattern %q: %w", p, err)
+		}
+		if ok {
+			return p, nil
+		}
+	}
+	return "", nil
+}
+
+// checkNamingPolicy returns an error if the service name, or the revision name
+// set in the manifest, doesn't follow the deploy target's naming policy.
+// An empty revision name is not checked, as Cloud Run generates it.
+func checkNamingPolicy(dt *sdk.DeployTarget[config.DeployTargetConfig], serviceName, revisionName string) error {
+	policy := dt.Config.NamingPolicy
+	if policy == nil {
+		return nil
+	}
+
+	if err := matchNamingPattern(policy.ServicePattern, serviceName); err != nil {
+		return fmt.Errorf("service %s violates the naming policy of deploy target %s: %w", serviceName, dt.Name, err)
+	}
+	if revisionName == "" {
+		return nil
+	}
+	if err := matchNamingPattern(policy.RevisionPattern, revisionName); err != nil {
+		return fmt.Errorf("revision %s violates the naming policy of deploy target %s: %w", revisionName, dt.Name, err)
+	}
+	return nil
+}
+
+// matchNamingPattern returns an error if the name doesn't match the whole pattern.
+func matchNamingPattern(pattern, name string) error {
+	if pattern == "" {
+		return nil
+	}
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	if !re.MatchString(name) {
+		return fmt.Errorf("name does not match %q", pattern)
+	}
+	return nil
+}
+
+// checkStageAllowed returns an error if the deploy target doesn't allow the
+// stage, according to its allowedStages. The stage is allowed if either its
+// name in the pipeline or the stage it runs as (e.g. CLOUDRUN_JOB_SYNC for
+// CLOUDRUN_SYNC of a job) is listed.
+func checkStageAllowed(dt *sdk.DeployTarget[config.DeployTargetConfig], stageName, executedStage string) error {
+	allowed := dt.Config.AllowedStages
+	if len(allowed) == 0 || slices.Contains(allowed, stageName) || slices.Contains(allowed, executedStage) {
+		return nil
+	}
+	return fmt.Errorf("stage %s is not allowed on deploy target %s (allowed: %v)", stageName, dt.Name, allowed)
+}
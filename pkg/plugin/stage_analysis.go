@@ -0,0 +1,402 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// ExecuteAnalysisStage executes the CLOUDRUN_ANALYSIS stage.
+//
+// This stage checks the metrics of the candidate revision (the latest created
+// revision) every interval for the configured duration. Each query reads a
+// Cloud Monitoring metric of the revision, aggregated over its alignment
+// period, and compares it with its threshold. The stage fails once more
+// checks fail than the failure limit allows. With a minimum sample count,
+// the requests served during the analysis are counted at its end, and too
+// few pass, fail, or extend the analysis.
+func (e *StageExecutor) ExecuteAnalysisStage(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	lp sdk.StageLogPersister,
+) (*StageResult, error) {
+	// Parse stage configuration
+	stageCfg := DefaultAnalysisStageConfig()
+	if err := parseStageConfig(input.Request.StageConfig, stageCfg); err != nil {
+		lp.Errorf("Failed to parse stage config: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	queries, err := analysisQueries(stageCfg)
+	if err != nil {
+		lp.Errorf("Invalid analysis config: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	interval := stageCfg.Interval.Duration()
+	if interval <= 0 {
+		interval = DefaultAnalysisStageConfig().Interval.Duration()
+	}
+	maxExtension := stageCfg.MaxExtension.Duration()
+	if maxExtension <= 0 {
+		maxExtension = stageCfg.Duration.Duration()
+	}
+
+	// Get deploy target config
+	if len(deployTargets) == 0 {
+		lp.Errorf("No deploy targets configured")
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, fmt.Errorf("no deploy targets configured")
+	}
+	dt := deployTargets[0]
+
+	// Get the Cloud Run client of the deploy target
+	clients, err := e.targetClients(ctx, cfg, dt, input)
+	if err != nil {
+		lp.Errorf("Failed to create Cloud Run client: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	client := clients.client
+	project, serviceName := clients.project, clients.service
+	region := deployedRegion(ctx, dt, input, clients.region, lp)
+
+	svc, err := client.GetService(ctx, project, region, serviceName)
+	if err != nil {
+		lp.Errorf("Failed to get service: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	candidate := cloudrun.ShortRevisionName(svc.LatestCreatedRevision)
+
+	reader, err := cloudrun.NewMetricReader(ctx, dt.Config.CredentialsFile)
+	if err != nil {
+		lp.Errorf("Failed to create metric reader: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	scope := cloudrun.MetricScope{
+		Project:  project,
+		Region:   region,
+		Service:  serviceName,
+		Revision: candidate,
+	}
+
+	lp.Infof("Analyzing revision %s for %s (%d query(s), every %s)", candidate, stageCfg.Duration.Duration(), len(queries), interval)
+
+	start := time.Now()
+	deadline := time.NewTimer(stageCfg.Duration.Duration())
+	defer deadline.Stop()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// The report keeps the results of the last check, or of the last failed
+	// check once one has failed
+	report := analysisReport{FailureLimit: stageCfg.FailureLimit}
+	extended := false
+	for {
+		select {
+		case <-ctx.Done():
+			lp.Errorf("Analysis was interrupted: %v", ctx.Err())
+			return &StageResult{
+				Status:   StageStatusCancelled,
+				Revision: candidate,
+				Metadata: report.metadata(),
+			}, ctx.Err()
+		case <-deadline.C:
+			if stageCfg.MinSampleCount > 0 && !report.countSamples(ctx, reader, scope, start, stageCfg.MinSampleCount, lp) {
+				if stageCfg.InsufficientTraffic == InsufficientTrafficExtend && !extended {
+					extended = true
+					lp.Infof("Warning: Only %d of %d required request(s) served, extending the analysis by up to %s", *report.Samples, stageCfg.MinSampleCount, maxExtension)
+					deadline.Reset(maxExtension)
+					continue
+				}
+				if stageCfg.InsufficientTraffic == InsufficientTrafficPass {
+					lp.Infof("Warning: Only %d of %d required request(s) served, passing the analysis anyway", *report.Samples, stageCfg.MinSampleCount)
+					return &StageResult{
+						Status:   StageStatusSuccess,
+						Message:  "analysis passed with insufficient traffic",
+						Revision: candidate,
+						Metadata: report.metadata(),
+					}, nil
+				}
+				err := fmt.Errorf("insufficient traffic: revision %s served %d request(s) during the analysis (minimum %d)", candidate, *report.Samples, stageCfg.MinSampleCount)
+				lp.Errorf("%v", err)
+				return &StageResult{
+					Status:   StageStatusFailure,
+					Message:  err.Error(),
+					Revision: candidate,
+					Metadata: report.metadata(),
+				}, err
+			}
+			lp.Successf("Analysis passed: %d check(s), %d failed", report.Checks, report.Failures)
+			return &StageResult{
+				Status:   StageStatusSuccess,
+				Revision: candidate,
+				Metadata: report.metadata(),
+			}, nil
+		case <-ticker.C:
+		}
+
+		results, passed := evaluateAnalysisQueries(ctx, reader, scope, queries, time.Now())
+		logAnalysisResults(results, lp)
+		report.Checks++
+		if passed {
+			if report.Failures == 0 {
+				report.Queries = results
+			}
+			// An extended analysis ends once enough requests were served
+			if extended && report.countSamples(ctx, reader, scope, start, stageCfg.MinSampleCount, lp) {
+				lp.Successf("Analysis passed: %d check(s), %d failed", report.Checks, report.Failures)
+				return &StageResult{
+					Status:   StageStatusSuccess,
+					Revision: candidate,
+					Metadata: report.metadata(),
+				}, nil
+			}
+			continue
+		}
+		report.Failures++
+		report.Queries = results
+		if report.Failures > stageCfg.FailureLimit {
+			err := fmt.Errorf("analysis of revision %s failed: %d check(s) failed (limit %d)", candidate, report.Failures, stageCfg.FailureLimit)
+			lp.Errorf("%v", err)
+			return &StageResult{
+				Status:   StageStatusFailure,
+				Message:  "analysis failed: " + failedAnalysisQueries(results),
+				Revision: candidate,
+				Metadata: report.metadata(),
+			}, err
+		}
+		lp.Infof("Warning: Check %d failed (%d of %d tolerated failure(s))", report.Checks, report.Failures, stageCfg.FailureLimit)
+	}
+}
+
+// analysisQueries validates the stage config and returns its queries, with
+// unset fields filled with defaults.
+func analysisQueries(stageCfg *AnalysisStageConfig) ([]cloudrun.MetricQuery, error) {
+	if stageCfg.Duration <= 0 {
+		return nil, fmt.Errorf("invalid analysis duration: %s", stageCfg.Duration.Duration())
+	}
+	if len(stageCfg.Queries) == 0 {
+		return nil, fmt.Errorf("at least one query is required")
+	}
+	if stageCfg.MinSampleCount < 0 {
+		return nil, fmt.Errorf("invalid minSampleCount: %d", stageCfg.MinSampleCount)
+	}
+	switch stageCfg.InsufficientTraffic {
+	case InsufficientTrafficPass, InsufficientTrafficFail, InsufficientTrafficExtend:
+	default:
+		return nil, fmt.Errorf("unsupported insufficientTraffic %q (supported: pass, fail, extend)", stageCfg.InsufficientTraffic)
+	}
+
+	// Fill unset fields with defaults
+	defaults := DefaultAnalysisQueryConfig()
+	queries := make([]cloudrun.MetricQuery, 0, len(stageCfg.Queries))
+	for _, q := range stageCfg.Queries {
+		query := cloudrun.MetricQuery{
+			Name:            q.Name,
+			Metric:          q.Metric,
+			Filter:          q.Filter,
+			Aggregation:     cloudrun.Aggregation(q.Aggregation),
+			AlignmentPeriod: q.AlignmentPeriod.Duration(),
+			Operator:        cloudrun.Operator(q.Operator),
+			Threshold:       q.Threshold,
+		}
+		if query.Name == "" {
+			query.Name = q.Metric
+		}
+		if query.Aggregation == "" {
+			query.Aggregation = cloudrun.Aggregation(defaults.Aggregation)
+		}
+		if query.AlignmentPeriod <= 0 {
+			query.AlignmentPeriod = defaults.AlignmentPeriod.Duration()
+		}
+		if err := query.Validate(); err != nil {
+			return nil, err
+		}
+		queries = append(queries, query)
+	}
+	return queries, nil
+}
+
+// Verdicts of an analysis query.
+const (
+	analysisVerdictPass   = "PASS"
+	analysisVerdictFail   = "FAIL"
+	analysisVerdictNoData = "NO_DATA"
+	analysisVerdictError  = "ERROR"
+)
+
+// analysisQueryResult is the outcome of evaluating a query once.
+type analysisQueryResult struct {
+	Name        string   `json:"name"`
+	Metric      string   `json:"metric"`
+	Aggregation string   `json:"aggregation"`
+	Value       *float64 `json:"value,omitempty"`
+	Operator    string   `json:"operator"`
+	Threshold   float64  `json:"threshold"`
+	Verdict     string   `json:"verdict"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// String formats the result for logs and messages,
+// e.g. "p95 latency: p95 812 < 500: FAIL".
+func (r analysisQueryResult) String() string {
+	switch r.Verdict {
+	case analysisVerdictNoData:
+		return fmt.Sprintf("%s: no data", r.Name)
+	case analysisVerdictError:
+		return fmt.Sprintf("%s: %s", r.Name, r.Error)
+	default:
+		return fmt.Sprintf("%s: %s %g %s %g: %s", r.Name, r.Aggregation, *r.Value, r.Operator, r.Threshold, r.Verdict)
+	}
+}
+
+// analysisReport is stored as the analysis stage metadata, so the UI and
+// notifications can show why the analysis failed.
+type analysisReport struct {
+	Checks       int                   `json:"checks"`
+	Failures     int                   `json:"failures"`
+	FailureLimit int                   `json:"failureLimit"`
+	Queries      []analysisQueryResult `json:"queries"`
+
+	// Samples is the number of requests served during the analysis, if a
+	// minimum sample count is required.
+	Samples *int64 `json:"samples,omitempty"`
+}
+
+// metadata returns the report as stage metadata.
+func (r analysisReport) metadata() map[string]string {
+	data, _ := json.Marshal(r)
+	return map[string]string{
+		MetadataKeyAnalysis: string(data),
+	}
+}
+
+// sampleCountQuery counts the requests served by the revision.
+var sampleCountQuery = cloudrun.MetricQuery{
+	Name:        "request count",
+	Metric:      "run.googleapis.com/request_count",
+	Aggregation: cloudrun.AggregationSum,
+}
+
+// countSamples records the number of requests served by the revision since
+// start, and reports whether they reach minSamples. A revision without traffic has
+// no data, counted as 0. If the count can't be read, the requirement is
+// skipped like queries that can't be read.
+func (r *analysisReport) countSamples(
+	ctx context.Context,
+	reader cloudrun.MetricReader,
+	scope cloudrun.MetricScope,
+	start time.Time,
+	minSamples int64,
+	lp sdk.StageLogPersister,
+) bool {
+	now := time.Now()
+	q := sampleCountQuery
+	// Cloud Monitoring aligns on whole seconds, with a minimum of 60s
+	q.AlignmentPeriod = max(now.Sub(start).Round(time.Second)+time.Second, time.Minute)
+	value, _, err := reader.ReadMetric(ctx, scope, q, now)
+	if err != nil {
+		lp.Infof("Warning: Failed to count the requests served, skipping the minimum sample count: %v", err)
+		return true
+	}
+	samples := int64(value)
+	r.Samples = &samples
+	return samples >= minSamples
+}
+
+// evaluateAnalysisQueries evaluates every query once. Queries without data or
+// that can't be read don't fail the check.
+func evaluateAnalysisQueries(
+	ctx context.Context,
+	reader cloudrun.MetricReader,
+	scope cloudrun.MetricScope,
+	queries []cloudrun.MetricQuery,
+	now time.Time,
+) ([]analysisQueryResult, bool) {
+	results := make([]analysisQueryResult, 0, len(queries))
+	passed := true
+	for _, q := range queries {
+		result := analysisQueryResult{
+			Name:        q.Name,
+			Metric:      q.Metric,
+			Aggregation: string(q.Aggregation),
+			Operator:    string(q.Operator),
+			Threshold:   q.Threshold,
+		}
+		value, ok, err := reader.ReadMetric(ctx, scope, q, now)
+		switch {
+		case err != nil:
+			result.Verdict = analysisVerdictError
+			result.Error = err.Error()
+		case !ok:
+			result.Verdict = analysisVerdictNoData
+		case q.Evaluate(value):
+			result.Value = &value
+			result.Verdict = analysisVerdictPass
+		default:
+			result.Value = &value
+			result.Verdict = analysisVerdictFail
+			passed = false
+		}
+		results = append(results, result)
+	}
+	return results, passed
+}
+
+// logAnalysisResults logs the outcome of each query.
+func logAnalysisResults(results []analysisQueryResult, lp sdk.StageLogPersister) {
+	for _, r := range results {
+		switch r.Verdict {
+		case analysisVerdictFail:
+			lp.Errorf("Query %s", r)
+		case analysisVerdictPass:
+			lp.Infof("Query %s", r)
+		default:
+			lp.Infof("Warning: Query %s", r)
+		}
+	}
+}
+
+// failedAnalysisQueries summarizes the failed queries, e.g.
+// "p95 latency: p95 812 < 500: FAIL".
+func failedAnalysisQueries(results []analysisQueryResult) string {
+	var failed []string
+	for _, r := range results {
+		if r.Verdict == analysisVerdictFail {
+			failed = append(failed, r.String())
+		}
+	}
+	return strings.Join(failed, "; ")
+}
@@ -0,0 +1,333 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// ExecuteBackupStage executes the CLOUDRUN_BACKUP stage.
+//
+// This stage writes the live service spec, its IAM policy, and its domain
+// mappings to a Cloud Storage object, which CLOUDRUN_RESTORE can recreate
+// the service from, e.g. after the service was deleted or in another project.
+func (e *StageExecutor) ExecuteBackupStage(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	lp sdk.StageLogPersister,
+) (*StageResult, error) {
+	// Parse stage configuration
+	stageCfg := DefaultBackupStageConfig()
+	if err := parseStageConfig(input.Request.StageConfig, stageCfg); err != nil {
+		lp.Errorf("Failed to parse stage config: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	if stageCfg.Bucket == "" {
+		err := fmt.Errorf("bucket is required")
+		lp.Errorf("Invalid stage config: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+
+	// Get deploy target config
+	if len(deployTargets) == 0 {
+		lp.Errorf("No deploy targets configured")
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, fmt.Errorf("no deploy targets configured")
+	}
+	dt := deployTargets[0]
+
+	// Get the Cloud Run client of the deploy target
+	clients, err := e.targetClients(ctx, cfg, dt, input)
+	if err != nil {
+		lp.Errorf("Failed to create Cloud Run client: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	client := clients.client
+	project, serviceName := clients.project, clients.service
+	region := deployedRegion(ctx, dt, input, clients.region, lp)
+
+	service, err := client.GetService(ctx, project, region, serviceName)
+	if err != nil {
+		lp.Errorf("Failed to get service %s: %v", serviceName, err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	policy, err := client.GetIAMPolicy(ctx, project, region, serviceName)
+	if err != nil {
+		lp.Errorf("Failed to get the IAM policy of service %s: %v", serviceName, err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	mappings, err := cloudrun.ServiceDomainMappings(ctx, dt.Config.CredentialsFile, project, region, serviceName)
+	if err != nil {
+		lp.Errorf("Failed to list the domain mappings of service %s: %v", serviceName, err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+
+	now := time.Now()
+	backup, err := cloudrun.NewServiceBackup(project, region, service, policy, mappings, now)
+	if err != nil {
+		lp.Errorf("Failed to back up service %s: %v", serviceName, err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	data, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		lp.Errorf("Failed to encode the backup: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+
+	object := cloudrun.BackupObjectName(stageCfg.Prefix, project, region, serviceName, now)
+	uri := fmt.Sprintf("gs://%s/%s", stageCfg.Bucket, object)
+	lp.Infof("Backing up service %s: %d IAM binding(s), %d domain mapping(s)", serviceName, len(policy.GetBindings()), len(mappings))
+	if report, ok := cloudrun.DryRun(ctx); ok {
+		report(fmt.Sprintf("write the backup of service %s to %s", serviceName, uri))
+	} else if err := cloudrun.WriteGCSObject(ctx, dt.Config.CredentialsFile, stageCfg.Bucket, object, "application/json", data); err != nil {
+		lp.Errorf("Failed to write the backup to %s: %v", uri, err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+
+	lp.Successf("Backed up service %s to %s", serviceName, uri)
+	return &StageResult{
+		Status: StageStatusSuccess,
+		Metadata: map[string]string{
+			MetadataKeyBackup: uri,
+		},
+	}, nil
+}
+
+// ExecuteRestoreStage executes the CLOUDRUN_RESTORE stage.
+//
+// This stage reads a backup written by CLOUDRUN_BACKUP, the configured object
+// or the latest backup of the service, and recreates the service from it in
+// the deploy target, then restores its IAM policy and domain mappings.
+func (e *StageExecutor) ExecuteRestoreStage(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	lp sdk.StageLogPersister,
+) (*StageResult, error) {
+	// Parse stage configuration
+	stageCfg := DefaultRestoreStageConfig()
+	if err := parseStageConfig(input.Request.StageConfig, stageCfg); err != nil {
+		lp.Errorf("Failed to parse stage config: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	if stageCfg.Bucket == "" {
+		err := fmt.Errorf("bucket is required")
+		lp.Errorf("Invalid stage config: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+
+	// Get deploy target config
+	if len(deployTargets) == 0 {
+		lp.Errorf("No deploy targets configured")
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, fmt.Errorf("no deploy targets configured")
+	}
+	dt := deployTargets[0]
+
+	// Get the Cloud Run client of the deploy target
+	clients, err := e.targetClients(ctx, cfg, dt, input)
+	if err != nil {
+		lp.Errorf("Failed to create Cloud Run client: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	client := clients.client
+	project, serviceName := clients.project, clients.service
+	region := deployedRegion(ctx, dt, input, clients.region, lp)
+
+	// Find the backup to restore
+	object := stageCfg.Object
+	if object == "" {
+		prefix := cloudrun.BackupObjectPrefix(stageCfg.Prefix, project, region, serviceName)
+		objects, err := cloudrun.ListGCSObjects(ctx, dt.Config.CredentialsFile, stageCfg.Bucket, prefix)
+		if err != nil {
+			lp.Errorf("Failed to list the backups in gs://%s/%s: %v", stageCfg.Bucket, prefix, err)
+			return &StageResult{
+				Status: StageStatusFailure,
+			}, err
+		}
+		latest, ok := cloudrun.LatestBackupObject(objects)
+		if !ok {
+			err := fmt.Errorf("no backups of service %s in gs://%s/%s", serviceName, stageCfg.Bucket, prefix)
+			lp.Errorf("%v", err)
+			return &StageResult{
+				Status: StageStatusFailure,
+			}, err
+		}
+		object = latest
+	}
+	uri := fmt.Sprintf("gs://%s/%s", stageCfg.Bucket, object)
+
+	data, err := cloudrun.ReadGCSObject(ctx, dt.Config.CredentialsFile, uri)
+	if err != nil {
+		lp.Errorf("Failed to read the backup %s: %v", uri, err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	backup, err := cloudrun.ParseServiceBackup(data)
+	if err != nil {
+		lp.Errorf("Failed to parse the backup %s: %v", uri, err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	lp.Infof("Restoring service %s from %s (service %s in %s/%s, backed up at %s)",
+		serviceName, uri, backup.Service, backup.Project, backup.Region, backup.CreatedAt.Format(time.RFC3339))
+
+	service, err := restoredService(ctx, client, backup, project, region, serviceName, lp)
+	if err != nil {
+		lp.Errorf("Failed to restore service %s: %v", serviceName, err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	result, err := deployService(ctx, client, service, project, region, serviceName, lp)
+	if err != nil {
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	lp.Infof("Restored service %s, latest revision %s", serviceName, cloudrun.ShortRevisionName(result.GetLatestCreatedRevision()))
+
+	if err := restoreServiceAccess(ctx, client, backup, stageCfg, dt.Config.CredentialsFile, project, region, serviceName, lp); err != nil {
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+
+	lp.Successf("Restored service %s from %s", serviceName, uri)
+	return &StageResult{
+		Status: StageStatusSuccess,
+		Metadata: map[string]string{
+			MetadataKeyBackup: uri,
+		},
+	}, nil
+}
+
+// restoredService returns the service to deploy from the backup, named after
+// the service of the deploy target. The revision name is cleared, since the
+// backed up revision may still exist. If the service doesn't exist, the
+// backed up traffic is replaced by all traffic to the new revision, since the
+// revisions it refers to don't exist either.
+func restoredService(
+	ctx context.Context,
+	client cloudrun.Client,
+	backup *cloudrun.ServiceBackup,
+	project, region, serviceName string,
+	lp sdk.StageLogPersister,
+) (*runpb.Service, error) {
+	svc, err := backup.ServiceSpec()
+	if err != nil {
+		return nil, err
+	}
+	cloudrun.SetServiceName(svc, project, region, serviceName)
+	if svc.Template != nil {
+		svc.Template.Revision = ""
+	}
+	if _, err := client.GetService(ctx, project, region, serviceName); err != nil {
+		lp.Infof("Warning: Service %s does not exist, routing all traffic to the restored revision", serviceName)
+		svc.Traffic = []*runpb.TrafficTarget{
+			{
+				Type:    runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST,
+				Percent: 100,
+			},
+		}
+	}
+	if err := cloudrun.SetLastApplied(svc); err != nil {
+		return nil, err
+	}
+	return svc, nil
+}
+
+// restoreServiceAccess restores the IAM policy and the domain mappings of the
+// backup, unless the stage skips them.
+func restoreServiceAccess(
+	ctx context.Context,
+	client cloudrun.Client,
+	backup *cloudrun.ServiceBackup,
+	stageCfg *RestoreStageConfig,
+	credentialsFile, project, region, serviceName string,
+	lp sdk.StageLogPersister,
+) error {
+	if !stageCfg.SkipIAMPolicy {
+		policy, err := backup.Policy()
+		if err != nil {
+			lp.Errorf("Failed to restore the IAM policy: %v", err)
+			return err
+		}
+		if policy != nil {
+			if _, err := client.SetIAMPolicy(ctx, project, region, serviceName, policy); err != nil {
+				lp.Errorf("Failed to restore the IAM policy of service %s: %v", serviceName, err)
+				return err
+			}
+			lp.Infof("Restored %d IAM binding(s)", len(policy.GetBindings()))
+		}
+	}
+
+	if stageCfg.SkipDomainMappings || len(backup.DomainMappings) == 0 {
+		return nil
+	}
+	if report, ok := cloudrun.DryRun(ctx); ok {
+		for _, m := range backup.DomainMappings {
+			report(fmt.Sprintf("map domain %s to service %s", m.Domain, serviceName))
+		}
+		return nil
+	}
+	created, err := cloudrun.CreateDomainMappings(ctx, credentialsFile, project, region, serviceName, backup.DomainMappings)
+	if err != nil {
+		lp.Errorf("Failed to restore the domain mappings of service %s: %v", serviceName, err)
+		return err
+	}
+	lp.Infof("Restored %d of %d domain mapping(s), the others were already mapped", len(created), len(backup.DomainMappings))
+	return nil
+}
@@ -17,6 +17,8 @@ package plugin
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
 
@@ -33,6 +35,9 @@ import (
 //   - Keep the specified number of recent revisions (default: 5)
 //   - Always keep the latest revision (configurable)
 //   - Only delete revisions with 0% traffic
+//   - Wait for revisions to drain before deleting them (default: 30s)
+//   - Optionally check that no instances are running (waitForZeroInstances)
+//   - Clean up the other services of a multi-service manifest the same way
 //
 // Example Pipeline:
 //
@@ -46,78 +51,97 @@ func (e *StageExecutor) ExecuteCanaryCleanupStage(
 	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
 	input *sdk.ExecuteStageInput[config.ApplicationConfig],
 	lp sdk.StageLogPersister,
-) (*sdk.ExecuteStageResponse, error) {
+) (*StageResult, error) {
 	// Parse stage configuration
 	stageCfg := DefaultCanaryCleanupStageConfig()
 	if err := parseStageConfig(input.Request.StageConfig, stageCfg); err != nil {
 		lp.Errorf("Failed to parse stage config: %v", err)
-		return &sdk.ExecuteStageResponse{
-			Status: sdk.StageStatusFailure,
+		return &StageResult{
+			Status: StageStatusFailure,
 		}, err
 	}
 
 	// Get deploy target config
 	if len(deployTargets) == 0 {
 		lp.Errorf("No deploy targets configured")
-		return &sdk.ExecuteStageResponse{
-			Status: sdk.StageStatusFailure,
+		return &StageResult{
+			Status: StageStatusFailure,
 		}, fmt.Errorf("no deploy targets configured")
 	}
 	dt := deployTargets[0]
 
-	// Resolve project and region
-	project := dt.Config.ProjectID
-	if project == "" {
-		project = cfg.ProjectID
-	}
-	region := dt.Config.Region
-	if region == "" {
-		region = cfg.Region
-	}
-
-	// Get service name
-	serviceName := input.Request.TargetDeploymentSource.ApplicationConfig.Spec.Input.ServiceName
-	if serviceName == "" {
-		serviceName = input.Request.Deployment.ApplicationID
-	}
-
-	lp.Infof("Cleaning up revisions for service: %s", serviceName)
-	lp.Infof("Keep count: %d, Keep latest: %v", stageCfg.KeepCount, stageCfg.KeepLatest)
-
-	// Create Cloud Run client
-	client, err := cloudrun.NewClient(ctx, dt.Config.CredentialsFile)
+	// Get the Cloud Run client of the deploy target
+	clients, err := e.targetClients(ctx, cfg, dt, input)
 	if err != nil {
 		lp.Errorf("Failed to create Cloud Run client: %v", err)
-		return &sdk.ExecuteStageResponse{
-			Status: sdk.StageStatusFailure,
+		return &StageResult{
+			Status: StageStatusFailure,
 		}, err
 	}
-	defer client.Close()
+	rm := clients.revisions
+	project, serviceName := clients.project, clients.service
+	region := deployedRegion(ctx, dt, input, clients.region, lp)
 
-	// Create revision manager
-	rm := cloudrun.NewRevisionManager(client)
+	lp.Infof("Cleaning up revisions for service: %s", serviceName)
+	lp.Infof("Keep count: %d, Keep latest: %v", stageCfg.KeepCount, stageCfg.KeepLatest)
 
 	// List all revisions before cleanup
 	revisions, err := rm.ListRevisions(ctx, project, region, serviceName)
 	if err != nil {
 		lp.Errorf("Failed to list revisions: %v", err)
-		return &sdk.ExecuteStageResponse{
-			Status: sdk.StageStatusFailure,
+		return &StageResult{
+			Status: StageStatusFailure,
 		}, err
 	}
 
 	lp.Infof("Found %d revisions", len(revisions))
 	for _, rev := range revisions {
-		lp.Infof("  - %s: %d%% traffic, created at %s", rev.Name, rev.TrafficPercent, rev.CreatedAt.Format("2006-01-02 15:04:05"))
+		lp.Infof("  - %s: %d%% traffic, created at %s%s", rev.Name, rev.TrafficPercent, rev.CreatedAt.Format("2006-01-02 15:04:05"), formatTagsSuffix(rev.Tags))
+	}
+
+	opts := cloudrun.CleanupOptions{
+		KeepCount:    stageCfg.KeepCount,
+		KeepLatest:   stageCfg.KeepLatest,
+		DrainWait:    stageCfg.DrainWait.Duration(),
+		DrainTimeout: stageCfg.DrainTimeout.Duration(),
+		KeepTagged:   stageCfg.KeepTagged,
+	}
+
+	// Keep the rollback target while the new revision may still be rolled back
+	if stable := recordedStableRevision(ctx, input, lp); stable != "" {
+		if until, ok := rollbackTargetRetention(stable, revisions, stageCfg.RollbackRetention.Duration(), time.Now()); ok {
+			lp.Infof("Keeping rollback target %s until %s", stable, until.UTC().Format(time.RFC3339))
+			opts.Protected = append(opts.Protected, stable)
+		}
+	}
+	if stageCfg.WaitForZeroInstances {
+		counter, err := cloudrun.NewInstanceCounter(ctx, dt.Config.CredentialsFile)
+		if err != nil {
+			lp.Errorf("Failed to create instance counter: %v", err)
+			return &StageResult{
+				Status: StageStatusFailure,
+			}, err
+		}
+		opts.InstanceCounter = counter
+	}
+	if opts.DrainWait > 0 {
+		lp.Infof("Waiting %s for revisions with 0%% traffic to drain before deletion", opts.DrainWait)
 	}
 
 	// Perform cleanup
-	if err := rm.CleanupOldRevisions(ctx, project, region, serviceName, stageCfg.KeepCount, stageCfg.KeepLatest); err != nil {
+	result, err := rm.CleanupRevisions(ctx, project, region, serviceName, opts)
+	if err != nil {
 		lp.Errorf("Failed to cleanup revisions: %v", err)
-		return &sdk.ExecuteStageResponse{
-			Status: sdk.StageStatusFailure,
+		return &StageResult{
+			Status: StageStatusFailure,
 		}, err
 	}
+	if len(result.Untagged) > 0 {
+		lp.Infof("Removed tags of deleted revisions: %s", strings.Join(result.Untagged, ", "))
+	}
+	for _, name := range result.Skipped {
+		lp.Infof("Warning: Revision %s still has running instances after %s, skipping deletion", name, opts.DrainTimeout)
+	}
 
 	// List revisions after cleanup
 	revisionsAfter, err := rm.ListRevisions(ctx, project, region, serviceName)
@@ -128,9 +152,41 @@ func (e *StageExecutor) ExecuteCanaryCleanupStage(
 		lp.Infof("Cleanup complete. Deleted %d revisions, %d remaining", deletedCount, len(revisionsAfter))
 	}
 
+	// Clean up the other services of the manifest too
+	deleted, err := cleanupAdditionalServices(ctx, input.Client, rm, project, region, opts, stageCfg.RollbackRetention.Duration(), lp)
+	deleted = append(result.Deleted, deleted...)
+	if err != nil {
+		return &StageResult{
+			Status:           StageStatusFailure,
+			Message:          err.Error(),
+			DeletedRevisions: deleted,
+		}, err
+	}
+
 	lp.Successf("Successfully cleaned up old revisions")
 
-	return &sdk.ExecuteStageResponse{
-		Status: sdk.StageStatusSuccess,
+	return &StageResult{
+		Status:           StageStatusSuccess,
+		DeletedRevisions: deleted,
 	}, nil
 }
+
+// rollbackTargetRetention returns until when the stable revision is kept as the
+// rollback target: the retention period after the newest revision was created.
+// It returns false if the retention has expired or the newest revision is the
+// stable revision itself.
+func rollbackTargetRetention(stable string, revisions []*cloudrun.RevisionInfo, retention time.Duration, now time.Time) (time.Time, bool) {
+	if len(revisions) == 0 || revisions[0].Name == stable {
+		return time.Time{}, false
+	}
+	until := revisions[0].CreatedAt.Add(retention)
+	return until, now.Before(until)
+}
+
+// formatTagsSuffix formats revision tags for log output.
+func formatTagsSuffix(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (tags: %s)", strings.Join(tags, ", "))
+}
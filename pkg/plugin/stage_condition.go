@@ -0,0 +1,115 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// StageCondition defines when a stage is executed. It can be set on any stage
+// with the `when` field; all set conditions must hold, otherwise the stage is
+// skipped with a logged reason.
+//
+// Example:
+//
+//	stages:
+//	  - name: CLOUDRUN_PROMOTE
+//	    with:
+//	      percent: 100
+//	      when:
+//	        targets: [production]
+//	        commitMessageContains: "[full-rollout]"
+//	        imageTagMatches: "^v[0-9]+\\.[0-9]+\\.[0-9]+$"
+type StageCondition struct {
+	// Targets runs the stage only for these deploy target names.
+	Targets []string `json:"targets,omitempty"`
+
+	// CommitMessageContains runs the stage only when the commit message of
+	// the deployment contains this string.
+	CommitMessageContains string `json:"commitMessageContains,omitempty"`
+
+	// ImageTagMatches runs the stage only when the image tag matches this
+	// regular expression.
+	ImageTagMatches string `json:"imageTagMatches,omitempty"`
+}
+
+// stageConditionConfig is the part of every stage config holding the condition.
+type stageConditionConfig struct {
+	When *StageCondition `json:"when,omitempty"`
+}
+
+// conditionEnv provides the values conditions are evaluated against.
+type conditionEnv struct {
+	// TargetName is the name of the deploy target.
+	TargetName string
+
+	// ImageTag is the tag of the deployed image.
+	ImageTag string
+
+	// CommitMessage returns the commit message of the deployment.
+	// It is only called when a condition needs it.
+	CommitMessage func(ctx context.Context) (string, error)
+}
+
+// Evaluate returns whether the stage should run and, if not, the reason.
+func (c *StageCondition) Evaluate(ctx context.Context, env conditionEnv) (bool, string, error) {
+	if c == nil {
+		return true, "", nil
+	}
+
+	if len(c.Targets) > 0 && !slices.Contains(c.Targets, env.TargetName) {
+		return false, fmt.Sprintf("deploy target %q is not one of %v", env.TargetName, c.Targets), nil
+	}
+
+	if c.ImageTagMatches != "" {
+		re, err := regexp.Compile(c.ImageTagMatches)
+		if err != nil {
+			return false, "", fmt.Errorf("invalid imageTagMatches pattern: %w", err)
+		}
+		if !re.MatchString(env.ImageTag) {
+			return false, fmt.Sprintf("image tag %q does not match %q", env.ImageTag, c.ImageTagMatches), nil
+		}
+	}
+
+	if c.CommitMessageContains != "" {
+		if env.CommitMessage == nil {
+			return false, "", fmt.Errorf("commit message is not available")
+		}
+		message, err := env.CommitMessage(ctx)
+		if err != nil {
+			return false, "", fmt.Errorf("failed to get commit message: %w", err)
+		}
+		if !strings.Contains(message, c.CommitMessageContains) {
+			return false, fmt.Sprintf("commit message does not contain %q", c.CommitMessageContains), nil
+		}
+	}
+
+	return true, "", nil
+}
+
+// gitCommitMessage returns the message of the HEAD commit of the repository
+// containing dir.
+func gitCommitMessage(ctx context.Context, dir string) (string, error) {
+	out, err := exec.CommandContext(ctx, "git", "-C", dir, "log", "-1", "--format=%B").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
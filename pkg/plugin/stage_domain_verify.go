@@ -0,0 +1,193 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// ExecuteDomainVerifyStage executes the CLOUDRUN_DOMAIN_VERIFY stage.
+//
+// This stage checks the public endpoint of each domain of the service (the
+// configured domains, or the domains mapped to the service): the domain
+// resolves, its certificate is trusted, covers the domain, and doesn't expire
+// soon, and an HTTPS request gets a response that isn't a server error.
+// Failing domains are checked again until the wait timeout, since managed
+// certificates of new domain mappings take a while to be provisioned.
+func (e *StageExecutor) ExecuteDomainVerifyStage(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	lp sdk.StageLogPersister,
+) (*StageResult, error) {
+	// Parse stage configuration
+	stageCfg := DefaultDomainVerifyStageConfig()
+	if err := parseStageConfig(input.Request.StageConfig, stageCfg); err != nil {
+		lp.Errorf("Failed to parse stage config: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	if !strings.HasPrefix(stageCfg.Path, "/") {
+		err := fmt.Errorf("path must start with /, got %q", stageCfg.Path)
+		lp.Errorf("Invalid stage config: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+
+	// Get deploy target config
+	if len(deployTargets) == 0 {
+		lp.Errorf("No deploy targets configured")
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, fmt.Errorf("no deploy targets configured")
+	}
+	dt := deployTargets[0]
+
+	// Resolve the application's service
+	project, region := targetLocation(cfg, dt)
+	region = deployedRegion(ctx, dt, input, region, lp)
+	serviceName := applicationServiceName(input)
+
+	domains := stageCfg.Domains
+	if len(domains) == 0 {
+		var err error
+		domains, err = cloudrun.ServiceDomains(ctx, dt.Config.CredentialsFile, project, region, serviceName)
+		if err != nil {
+			lp.Errorf("Failed to list the domain mappings of service %s: %v", serviceName, err)
+			return &StageResult{
+				Status: StageStatusFailure,
+			}, err
+		}
+	}
+	if len(domains) == 0 {
+		lp.Successf("No domains are mapped to service %s, nothing to verify", serviceName)
+		return &StageResult{
+			Status: StageStatusSuccess,
+		}, nil
+	}
+
+	opts := cloudrun.DomainCheckOptions{
+		Path:                   stageCfg.Path,
+		MinCertificateValidity: stageCfg.MinCertificateValidity.Duration(),
+		Timeout:                stageCfg.RequestTimeout.Duration(),
+	}
+	interval := stageCfg.Interval.Duration()
+	if interval <= 0 {
+		interval = DefaultDomainVerifyStageConfig().Interval.Duration()
+	}
+	deadline := time.Now().Add(stageCfg.WaitTimeout.Duration())
+
+	lp.Infof("Verifying %d domain(s) of service %s: %s", len(domains), serviceName, strings.Join(domains, ", "))
+	report := domainReport{Domains: make([]cloudrun.DomainCheckResult, len(domains))}
+	pending := domains
+	for {
+		// Check the domains that haven't passed yet
+		var failing []string
+		for _, domain := range pending {
+			result := cloudrun.VerifyDomain(ctx, domain, opts)
+			report.set(domains, result)
+			if result.Passed() {
+				lp.Infof("Domain %s", formatDomainResult(result))
+				continue
+			}
+			lp.Infof("Warning: Domain %s", formatDomainResult(result))
+			failing = append(failing, domain)
+		}
+		if len(failing) == 0 {
+			lp.Successf("All %d domain(s) verified", len(domains))
+			return &StageResult{
+				Status:   StageStatusSuccess,
+				Metadata: report.metadata(),
+			}, nil
+		}
+		pending = failing
+
+		if time.Now().Add(interval).After(deadline) {
+			err := fmt.Errorf("%d of %d domain(s) failed verification: %s", len(failing), len(domains), strings.Join(failing, ", "))
+			lp.Errorf("%v", err)
+			return &StageResult{
+				Status:   StageStatusFailure,
+				Message:  "domain verification failed: " + report.failures(),
+				Metadata: report.metadata(),
+			}, err
+		}
+		lp.Infof("Checking %d domain(s) again in %s", len(failing), interval)
+		select {
+		case <-ctx.Done():
+			lp.Errorf("Domain verification was interrupted: %v", ctx.Err())
+			return &StageResult{
+				Status:   StageStatusCancelled,
+				Metadata: report.metadata(),
+			}, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// domainReport is stored as the domain verify stage metadata, with the last
+// result of each domain.
+type domainReport struct {
+	Domains []cloudrun.DomainCheckResult `json:"domains"`
+}
+
+// set records the result at the position of its domain.
+func (r *domainReport) set(domains []string, result cloudrun.DomainCheckResult) {
+	for i, d := range domains {
+		if d == result.Domain {
+			r.Domains[i] = result
+		}
+	}
+}
+
+// failures summarizes the failed domains, e.g.
+// "api.example.com: DNS lookup failed: ...".
+func (r domainReport) failures() string {
+	var failed []string
+	for _, d := range r.Domains {
+		if !d.Passed() {
+			failed = append(failed, d.Domain+": "+d.Error)
+		}
+	}
+	return strings.Join(failed, "; ")
+}
+
+// metadata returns the report as stage metadata.
+func (r domainReport) metadata() map[string]string {
+	data, _ := json.Marshal(r)
+	return map[string]string{
+		MetadataKeyDomains: string(data),
+	}
+}
+
+// formatDomainResult formats a domain check for logs, e.g.
+// "api.example.com: 34.1.2.3, certificate expires 2026-01-02, HTTPS 200".
+func formatDomainResult(r cloudrun.DomainCheckResult) string {
+	if !r.Passed() {
+		return fmt.Sprintf("%s: %s", r.Domain, r.Error)
+	}
+	return fmt.Sprintf("%s: %s, certificate expires %s, HTTPS %d", r.Domain, strings.Join(r.Addresses, " "), r.CertificateExpiry.UTC().Format(time.DateOnly), r.StatusCode)
+}
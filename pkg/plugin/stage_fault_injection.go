@@ -0,0 +1,300 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// ExecuteFaultInjectionStage executes the CLOUDRUN_FAULT_INJECTION stage.
+//
+// This stage checks that the candidate revision handles a fault gracefully
+// before it is fully promoted:
+//
+//  1. Deploy a copy of the candidate with the fault flag env vars, tagged and
+//     with 0% traffic (existing traffic is pinned so it doesn't move)
+//  2. Request the tag URL (optionally with header-based fault flags) until it
+//     returns the expected status
+//  3. Redeploy the candidate without the fault, restore the traffic split, and
+//     delete the fault revision
+//
+// The service is restored even if the check fails.
+func (e *StageExecutor) ExecuteFaultInjectionStage(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	lp sdk.StageLogPersister,
+) (*StageResult, error) {
+	// Parse stage configuration
+	stageCfg := DefaultFaultInjectionStageConfig()
+	if err := parseStageConfig(input.Request.StageConfig, stageCfg); err != nil {
+		lp.Errorf("Failed to parse stage config: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	if len(stageCfg.Env) == 0 && len(stageCfg.Check.Headers) == 0 {
+		lp.Errorf("No fault configured")
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, fmt.Errorf("env or check.headers is required")
+	}
+
+	// Get deploy target config
+	if len(deployTargets) == 0 {
+		lp.Errorf("No deploy targets configured")
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, fmt.Errorf("no deploy targets configured")
+	}
+	dt := deployTargets[0]
+
+	// Get the Cloud Run client of the deploy target
+	clients, err := e.targetClients(ctx, cfg, dt, input)
+	if err != nil {
+		lp.Errorf("Failed to create Cloud Run client: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	client := clients.client
+	project, serviceName := clients.project, clients.service
+	region := deployedRegion(ctx, dt, input, clients.region, lp)
+
+	original, err := client.GetService(ctx, project, region, serviceName)
+	if err != nil {
+		lp.Errorf("Failed to get service: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	candidate := cloudrun.ShortRevisionName(original.LatestCreatedRevision)
+
+	// Expose the revision with the fault under the tag
+	faultRevision := candidate
+	if len(stageCfg.Env) > 0 {
+		faultRevision, err = deployFaultRevision(ctx, client, original, serviceName, stageCfg, lp)
+	} else {
+		lp.Infof("Tagging candidate revision %s as %q", candidate, stageCfg.Tag)
+		traffic := append(cloneTraffic(original.Traffic), taggedTrafficTarget(candidate, stageCfg.Tag))
+		err = client.UpdateTraffic(ctx, project, region, serviceName, traffic)
+	}
+
+	if err != nil {
+		lp.Errorf("Failed to inject fault: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+
+	// Check the revision, then restore the service whatever the outcome
+	err = checkFault(ctx, client, dt, project, region, serviceName, stageCfg, lp)
+	if err != nil {
+		lp.Errorf("Fault check failed: %v", err)
+	}
+	if restoreErr := restoreFaultInjection(context.WithoutCancel(ctx), client, original, project, region, serviceName, faultRevision, len(stageCfg.Env) > 0, lp); restoreErr != nil {
+		lp.Errorf("Failed to restore the service after fault injection: %v", restoreErr)
+		if err == nil {
+			err = restoreErr
+		}
+	}
+	if err != nil {
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+
+	lp.Successf("Revision handled the injected fault as expected")
+	return &StageResult{
+		Status: StageStatusSuccess,
+	}, nil
+}
+
+// deployFaultRevision deploys a copy of the latest revision with the fault
+// flag env vars, tagged and with 0% traffic. It returns the revision name.
+func deployFaultRevision(
+	ctx context.Context,
+	client cloudrun.Client,
+	original *runpb.Service,
+	serviceName string,
+	stageCfg *FaultInjectionStageConfig,
+	lp sdk.StageLogPersister,
+) (string, error) {
+	svc := proto.Clone(original).(*runpb.Service)
+	if svc.Template == nil || len(svc.Template.Containers) == 0 {
+		return "", fmt.Errorf("service has no containers to set env on")
+	}
+
+	revision := cloudrun.FaultRevisionName(serviceName, time.Now())
+	svc.Template.Revision = revision
+	container := svc.Template.Containers[0]
+	for name, value := range stageCfg.Env {
+		container.Env = append(removeEnv(container.Env, name), &runpb.EnvVar{
+			Name:   name,
+			Values: &runpb.EnvVar_Value{Value: value},
+		})
+	}
+
+	// Keep traffic on the current revisions while the fault revision is latest
+	svc.Traffic = append(
+		cloudrun.PinLatestTraffic(original.Traffic, original.LatestReadyRevision),
+		taggedTrafficTarget(revision, stageCfg.Tag),
+	)
+
+	lp.Infof("Deploying fault revision %s", revision)
+	if _, err := client.CreateOrUpdateService(ctx, svc); err != nil {
+		return "", err
+	}
+	return revision, nil
+}
+
+// checkFault requests the tag URL of the fault revision until it returns the
+// expected status.
+func checkFault(
+	ctx context.Context,
+	client cloudrun.Client,
+	dt *sdk.DeployTarget[config.DeployTargetConfig],
+	project, region, serviceName string,
+	stageCfg *FaultInjectionStageConfig,
+	lp sdk.StageLogPersister,
+) error {
+	// Fill unset fields with defaults
+	checkCfg := stageCfg.Check
+	defaults := DefaultFaultInjectionStageConfig().Check
+	if checkCfg.Path == "" {
+		checkCfg.Path = defaults.Path
+	}
+	if checkCfg.Timeout <= 0 {
+		checkCfg.Timeout = defaults.Timeout
+	}
+	if checkCfg.Interval <= 0 {
+		checkCfg.Interval = defaults.Interval
+	}
+
+	svc, err := client.GetService(ctx, project, region, serviceName)
+	if err != nil {
+		return fmt.Errorf("failed to get service: %w", err)
+	}
+	tagURL := cloudrun.TagURL(svc, stageCfg.Tag)
+	if tagURL == "" {
+		return fmt.Errorf("service has no URL for tag %q", stageCfg.Tag)
+	}
+
+	healthCheck := cloudrun.HealthCheck{
+		URL:            strings.TrimSuffix(tagURL, "/") + "/" + strings.TrimPrefix(checkCfg.Path, "/"),
+		ExpectedStatus: checkCfg.ExpectedStatus,
+		Timeout:        checkCfg.Timeout.Duration(),
+		Interval:       checkCfg.Interval.Duration(),
+		Headers:        checkCfg.Headers,
+	}
+	if checkCfg.Authenticated {
+		httpClient, err := cloudrun.NewAuthenticatedHTTPClient(ctx, svc.Uri, dt.Config.CredentialsFile)
+		if err != nil {
+			return err
+		}
+		healthCheck.HTTPClient = httpClient
+	}
+
+	lp.Infof("Checking %s", healthCheck.URL)
+	return cloudrun.CheckHealth(ctx, healthCheck)
+}
+
+// restoreFaultInjection restores the service to its state before the fault was
+// injected. A fault revision is replaced by a new revision of the original
+// template, and the fault revision and the superseded candidate are deleted
+// so later stages see the same revision history.
+func restoreFaultInjection(
+	ctx context.Context,
+	client cloudrun.Client,
+	original *runpb.Service,
+	project, region, serviceName, faultRevision string,
+	redeployed bool,
+	lp sdk.StageLogPersister,
+) error {
+	if !redeployed {
+		lp.Info("Restoring the traffic split")
+		return client.UpdateTraffic(ctx, project, region, serviceName, cloneTraffic(original.Traffic))
+	}
+
+	svc := proto.Clone(original).(*runpb.Service)
+	svc.Template.Revision = ""
+
+	lp.Info("Redeploying the candidate without the fault")
+	result, err := client.CreateOrUpdateService(ctx, svc)
+	if err != nil {
+		return err
+	}
+	if err := client.WaitForServiceReady(ctx, project, region, serviceName); err != nil {
+		return err
+	}
+	lp.Infof("Candidate revision is now %s", cloudrun.ShortRevisionName(result.LatestCreatedRevision))
+
+	candidate := cloudrun.ShortRevisionName(original.LatestCreatedRevision)
+	for _, rev := range []string{faultRevision, candidate} {
+		if rev == "" || rev == cloudrun.ShortRevisionName(result.LatestCreatedRevision) {
+			continue
+		}
+		if cloudrun.RevisionInTraffic(original.Traffic, rev) {
+			continue
+		}
+		if err := client.DeleteRevision(ctx, project, region, serviceName, rev); err != nil {
+			lp.Infof("Warning: Failed to delete revision %s: %v", rev, err)
+			continue
+		}
+		lp.Infof("Deleted revision %s", rev)
+	}
+	return nil
+}
+
+// taggedTrafficTarget returns a tagged 0% traffic target for the revision.
+func taggedTrafficTarget(revision, tag string) *runpb.TrafficTarget {
+	return &runpb.TrafficTarget{
+		Type:     runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION,
+		Revision: revision,
+		Percent:  0,
+		Tag:      tag,
+	}
+}
+
+// cloneTraffic returns a deep copy of the traffic targets.
+func cloneTraffic(traffic []*runpb.TrafficTarget) []*runpb.TrafficTarget {
+	out := make([]*runpb.TrafficTarget, 0, len(traffic))
+	for _, t := range traffic {
+		out = append(out, proto.Clone(t).(*runpb.TrafficTarget))
+	}
+	return out
+}
+
+// removeEnv returns the env vars without the named variable.
+func removeEnv(env []*runpb.EnvVar, name string) []*runpb.EnvVar {
+	out := make([]*runpb.EnvVar, 0, len(env))
+	for _, e := range env {
+		if e.Name != name {
+			out = append(out, e)
+		}
+	}
+	return out
+}
@@ -0,0 +1,153 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// ExecuteHoldStage executes the CLOUDRUN_HOLD stage.
+//
+// This stage records the current traffic split and holds it for the configured
+// duration. If the split is changed by something else (e.g. a manual change in
+// the console), the recorded split is re-applied.
+//
+// It is used as a controlled bake step between promotions:
+//
+//	┌─────────────┐     ┌─────────────┐     ┌─────────────┐
+//	│ PROMOTE     │────▶│ HOLD        │────▶│ PROMOTE     │
+//	│ (10%)       │     │ (10m)       │     │ (100%)      │
+//	└─────────────┘     └─────────────┘     └─────────────┘
+func (e *StageExecutor) ExecuteHoldStage(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	lp sdk.StageLogPersister,
+) (*StageResult, error) {
+	// Parse stage configuration
+	stageCfg := DefaultHoldStageConfig()
+	if err := parseStageConfig(input.Request.StageConfig, stageCfg); err != nil {
+		lp.Errorf("Failed to parse stage config: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+
+	if stageCfg.Duration <= 0 {
+		lp.Errorf("Invalid hold duration: %s", stageCfg.Duration.Duration())
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, fmt.Errorf("invalid hold duration: %s", stageCfg.Duration.Duration())
+	}
+	checkInterval := stageCfg.CheckInterval.Duration()
+	if checkInterval <= 0 {
+		checkInterval = DefaultHoldStageConfig().CheckInterval.Duration()
+	}
+
+	// Get deploy target config
+	if len(deployTargets) == 0 {
+		lp.Errorf("No deploy targets configured")
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, fmt.Errorf("no deploy targets configured")
+	}
+	dt := deployTargets[0]
+
+	// Get the Cloud Run client of the deploy target
+	clients, err := e.targetClients(ctx, cfg, dt, input)
+	if err != nil {
+		lp.Errorf("Failed to create Cloud Run client: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	client := clients.client
+	project, serviceName := clients.project, clients.service
+	region := deployedRegion(ctx, dt, input, clients.region, lp)
+
+	// Record the current traffic split
+	svc, err := client.GetService(ctx, project, region, serviceName)
+	if err != nil {
+		lp.Errorf("Failed to get service: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	held := svc.Traffic
+
+	lp.Infof("Holding traffic for service %s for %s", serviceName, stageCfg.Duration.Duration())
+	lp.Info("Held traffic allocation:")
+	for _, t := range held {
+		lp.Info(strings.TrimSuffix(formatTrafficTarget(t, "  - "), "\n"))
+	}
+
+	deadline := time.NewTimer(stageCfg.Duration.Duration())
+	defer deadline.Stop()
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	reapplied := 0
+	start := time.Now()
+	lastProgress := start
+	for {
+		select {
+		case <-ctx.Done():
+			lp.Errorf("Hold was interrupted: %v", ctx.Err())
+			return &StageResult{
+				Status: StageStatusCancelled,
+			}, ctx.Err()
+		case <-deadline.C:
+			lp.Successf("Held traffic split for %s (re-applied %d time(s))", stageCfg.Duration.Duration(), reapplied)
+			return &StageResult{
+				Status:  StageStatusSuccess,
+				Traffic: trafficMapFromTargets(held),
+			}, nil
+		case <-ticker.C:
+			current, err := client.GetService(ctx, project, region, serviceName)
+			if err != nil {
+				lp.Infof("Warning: Failed to get service: %v", err)
+				continue
+			}
+			if !hasTrafficChanges(held, current.Traffic) {
+				if time.Since(lastProgress) >= cloudrun.DefaultProgressInterval {
+					lastProgress = time.Now()
+					lp.Infof("Holding traffic (%s/%s), split unchanged", time.Since(start).Round(time.Second), stageCfg.Duration.Duration())
+				}
+				continue
+			}
+
+			lp.Info("Traffic split was changed externally, re-applying held split")
+			for _, t := range current.Traffic {
+				lp.Info(strings.TrimSuffix(formatTrafficTarget(t, "  - "), "\n"))
+			}
+			if err := client.UpdateTraffic(ctx, project, region, serviceName, held); err != nil {
+				lp.Errorf("Failed to re-apply held traffic split: %v", err)
+				return &StageResult{
+					Status: StageStatusFailure,
+				}, err
+			}
+			reapplied++
+		}
+	}
+}
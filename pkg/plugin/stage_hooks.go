@@ -0,0 +1,296 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// stageHookTimeout bounds notifying a hook, which doesn't share the stage
+// deadline so failures of timed out stages are notified.
+const stageHookTimeout = 10 * time.Second
+
+// StageEvent is the stage transition a hook is notified of.
+type StageEvent string
+
+// Stage transitions.
+const (
+	StageEventStarted   StageEvent = config.StageHookEventStarted
+	StageEventSucceeded StageEvent = config.StageHookEventSucceeded
+	StageEventFailed    StageEvent = config.StageHookEventFailed
+)
+
+// StageNotification describes a stage transition.
+type StageNotification struct {
+	Event           StageEvent `json:"event"`
+	Time            time.Time  `json:"time"`
+	ApplicationID   string     `json:"applicationID"`
+	ApplicationName string     `json:"applicationName,omitempty"`
+	DeploymentID    string     `json:"deploymentID"`
+	Target          string     `json:"target,omitempty"`
+	Project         string     `json:"project,omitempty"`
+	Region          string     `json:"region,omitempty"`
+	Stage           string     `json:"stage"`
+	StageIndex      int        `json:"stageIndex"`
+
+	// Service is the service, or the job of CloudRunJob applications.
+	Service string `json:"service,omitempty"`
+
+	// Revision and Traffic are those of the stage result, set once the
+	// stage ended.
+	Revision string           `json:"revision,omitempty"`
+	Traffic  map[string]int32 `json:"traffic,omitempty"`
+
+	// Message is the message of the stage result, e.g. why it failed.
+	Message string `json:"message,omitempty"`
+
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// StageHook is notified of stage transitions, to integrate systems such as
+// chat, incident management, or audit logs without changing the stages.
+type StageHook interface {
+	// Notify sends the notification. Errors are logged as warnings and do
+	// not fail the stage.
+	Notify(ctx context.Context, n StageNotification) error
+}
+
+// StageHookFunc adapts a function to a StageHook.
+type StageHookFunc func(ctx context.Context, n StageNotification) error
+
+// Notify calls f.
+func (f StageHookFunc) Notify(ctx context.Context, n StageNotification) error {
+	return f(ctx, n)
+}
+
+// HTTPStageHook posts the notifications as JSON to a URL.
+type HTTPStageHook struct {
+	URL string
+
+	// Headers are added to the requests.
+	Headers map[string]string
+
+	// Client sends the requests. Default: http.DefaultClient
+	Client *http.Client
+}
+
+// Notify posts the notification. Responses other than 2xx are errors.
+func (h *HTTPStageHook) Notify(ctx context.Context, n StageNotification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range h.Headers {
+		req.Header.Set(k, v)
+	}
+
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s responded %s", h.URL, resp.Status)
+	}
+	return nil
+}
+
+// PubSubStageHook publishes the notifications as JSON to a Pub/Sub topic,
+// with the event, application ID, and stage as message attributes for
+// subscription filters.
+type PubSubStageHook struct {
+	// Topic is "projects/<project>/topics/<topic>".
+	Topic string
+
+	// CredentialsFile is the credentials to publish with. Default:
+	// Application Default Credentials
+	CredentialsFile string
+}
+
+// Notify publishes the notification.
+func (h *PubSubStageHook) Notify(ctx context.Context, n StageNotification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	attributes := map[string]string{
+		"event":         string(n.Event),
+		"applicationID": n.ApplicationID,
+		"stage":         n.Stage,
+	}
+	return cloudrun.PublishPubSubMessage(ctx, h.CredentialsFile, h.Topic, data, attributes)
+}
+
+// AddStageHook registers a hook notified of the transitions of every stage,
+// in addition to the stageHooks of the plugin config.
+func (p *cloudrunPlugin) AddStageHook(hook StageHook) {
+	p.stageExecutor.hooks = append(p.stageExecutor.hooks, hook)
+}
+
+// configuredStageHook is a hook of the plugin config, with the events it is
+// notified of.
+type configuredStageHook struct {
+	name   string
+	hook   StageHook
+	events []string
+}
+
+// stageHooks returns the hooks of the plugin config notified of event,
+// followed by the registered hooks. Invalid hooks are reported as warnings.
+func (e *StageExecutor) stageHooks(
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	event StageEvent,
+	lp sdk.StageLogPersister,
+) []configuredStageHook {
+	var credentialsFile string
+	if len(deployTargets) > 0 {
+		credentialsFile = deployTargets[0].Config.CredentialsFile
+	}
+
+	var hooks []configuredStageHook
+	if cfg != nil {
+		for i, h := range cfg.StageHooks {
+			if len(h.Events) > 0 && !slices.Contains(h.Events, string(event)) {
+				continue
+			}
+			switch {
+			case h.URL != "" && h.Topic == "":
+				hooks = append(hooks, configuredStageHook{name: h.URL, hook: &HTTPStageHook{URL: h.URL, Headers: stageHookHeaders(h.Headers)}})
+			case h.Topic != "" && h.URL == "":
+				hooks = append(hooks, configuredStageHook{name: h.Topic, hook: &PubSubStageHook{Topic: h.Topic, CredentialsFile: credentialsFile}})
+			default:
+				lp.Infof("Warning: Ignoring stage hook %d: set either url or topic", i)
+			}
+		}
+	}
+	for i, h := range e.hooks {
+		hooks = append(hooks, configuredStageHook{name: fmt.Sprintf("registered hook %d", i), hook: h})
+	}
+	return hooks
+}
+
+// notifyStageHooks notifies the hooks of a stage transition. The result is
+// nil when the stage starts. Failing to notify a hook does not fail the stage.
+func (e *StageExecutor) notifyStageHooks(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	lp sdk.StageLogPersister,
+	event StageEvent,
+	result *StageResult,
+) {
+	hooks := e.stageHooks(cfg, deployTargets, event, lp)
+	if len(hooks) == 0 {
+		return
+	}
+	n := stageNotification(ctx, cfg, deployTargets, input, event, result, time.Now())
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), stageHookTimeout)
+	defer cancel()
+	for _, h := range hooks {
+		if err := h.hook.Notify(ctx, n); err != nil {
+			lp.Infof("Warning: Failed to notify stage hook %s: %v", h.name, err)
+		}
+	}
+}
+
+// stageNotification returns the notification of a stage transition.
+func stageNotification(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	event StageEvent,
+	result *StageResult,
+	now time.Time,
+) StageNotification {
+	deployment := input.Request.Deployment
+	spec := input.Request.TargetDeploymentSource.ApplicationConfig.Spec
+	n := StageNotification{
+		Event:           event,
+		Time:            now.UTC(),
+		ApplicationID:   deployment.ApplicationID,
+		ApplicationName: deployment.ApplicationName,
+		DeploymentID:    deployment.ID,
+		Stage:           input.Request.StageName,
+		StageIndex:      input.Request.StageIndex,
+		Service:         spec.Input.ServiceName,
+	}
+	if spec.IsJob() {
+		n.Service = spec.Input.JobName
+	}
+	if n.Service == "" {
+		n.Service = deployment.ApplicationID
+	}
+	if cfg != nil {
+		n.Project, n.Region = cfg.ProjectID, cfg.Region
+	}
+	if len(deployTargets) > 0 {
+		dt := deployTargets[0]
+		n.Target = dt.Name
+		if dt.Config.ProjectID != "" {
+			n.Project = dt.Config.ProjectID
+		}
+		if dt.Config.Region != "" {
+			n.Region = dt.Config.Region
+		}
+	}
+	if _, ok := cloudrun.DryRun(ctx); ok {
+		n.DryRun = true
+	}
+	if result != nil {
+		n.Revision = result.Revision
+		n.Traffic = result.Traffic
+		n.Message = result.Message
+	}
+	return n
+}
+
+// stageHookHeaders returns the header values of a stage hook config, whose
+// secretRefs are resolved before the stage executes.
+func stageHookHeaders(headers map[string]config.SecretString) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+	values := make(map[string]string, len(headers))
+	for k, v := range headers {
+		values[k] = v.Value
+	}
+	return values
+}
@@ -0,0 +1,379 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// jobStageName returns the stage executed for stageName. CloudRunJob
+// applications run the job stages for CLOUDRUN_SYNC and CLOUDRUN_ROLLBACK,
+// so quick sync and rollback pipelines work for jobs too.
+func jobStageName(spec *config.ApplicationConfig, stageName string) string {
+	if !spec.IsJob() {
+		return stageName
+	}
+	switch stageName {
+	case StageCloudRunSync:
+		return StageCloudRunJobSync
+	case StageCloudRunRollback:
+		return StageCloudRunJobRollback
+	default:
+		return stageName
+	}
+}
+
+// ExecuteJobSyncStage executes the CLOUDRUN_JOB_SYNC stage.
+//
+// This stage:
+//  1. Reads the job manifest from the application directory
+//  2. Applies any image overrides from the app config
+//  3. Records the current job template for CLOUDRUN_JOB_ROLLBACK
+//  4. Creates or updates the Cloud Run job
+//
+// The job is not run; use CLOUDRUN_JOB_RUN or the job's schedule.
+func (e *StageExecutor) ExecuteJobSyncStage(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	lp sdk.StageLogPersister,
+) (*StageResult, error) {
+	// Get deploy target config
+	if len(deployTargets) == 0 {
+		lp.Errorf("No deploy targets configured")
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, fmt.Errorf("no deploy targets configured")
+	}
+	dt := deployTargets[0]
+
+	// Get the Cloud Run client of the deploy target
+	clients, err := e.targetClients(ctx, cfg, dt, input)
+	if err != nil {
+		lp.Errorf("Failed to create Cloud Run client: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	client := clients.client
+	project, region := clients.project, clients.region
+
+	spec := input.Request.TargetDeploymentSource.ApplicationConfig.Spec
+	job, manifestPath, err := loadJobManifest(ctx, deployTargets, input)
+	if err != nil {
+		lp.Errorf("Failed to load job manifest: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	lp.Infof("Read job manifest from: %s", manifestPath)
+
+	// Get job name from config, then the manifest
+	jobName := jobNameOf(spec, job, input.Request.Deployment.ApplicationID)
+	job.Name = cloudrun.JobName(project, region, jobName)
+
+	// Override image if specified in app config
+	if spec.Input.Image != "" {
+		lp.Infof("Overriding container image: %s", spec.Input.Image)
+		cloudrun.ApplyJobImageOverride(job, spec.Input.Image)
+	}
+
+	// Record the template before this deployment
+	existing, err := client.GetJob(ctx, project, region, jobName)
+	if err != nil {
+		lp.Infof("Job does not exist, creating new job")
+	}
+	recordPreJobSyncState(ctx, input, jobName, existing, lp)
+
+	lp.Infof("Deploying job: %s", job.Name)
+	if _, err := client.CreateOrUpdateJob(ctx, job); err != nil {
+		lp.Errorf("Failed to deploy job: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+
+	lp.Successf("Successfully deployed job: %s", jobName)
+
+	return &StageResult{
+		Status: StageStatusSuccess,
+		Metadata: map[string]string{
+			MetadataKeyJob: jobName,
+		},
+	}, nil
+}
+
+// ExecuteJobRunStage executes the CLOUDRUN_JOB_RUN stage.
+//
+// This stage runs the job deployed by CLOUDRUN_JOB_SYNC and waits for the
+// execution to finish. The stage fails if any task fails.
+func (e *StageExecutor) ExecuteJobRunStage(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	lp sdk.StageLogPersister,
+) (*StageResult, error) {
+	// Parse stage configuration
+	stageCfg := DefaultJobRunStageConfig()
+	if err := parseStageConfig(input.Request.StageConfig, stageCfg); err != nil {
+		lp.Errorf("Failed to parse stage config: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+
+	// Get deploy target config
+	if len(deployTargets) == 0 {
+		lp.Errorf("No deploy targets configured")
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, fmt.Errorf("no deploy targets configured")
+	}
+	dt := deployTargets[0]
+
+	// Get the Cloud Run client of the deploy target
+	clients, err := e.targetClients(ctx, cfg, dt, input)
+	if err != nil {
+		lp.Errorf("Failed to create Cloud Run client: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	client := clients.client
+	project, region := clients.project, clients.region
+
+	jobName := deployedJobName(ctx, input, lp)
+
+	lp.Infof("Running job: %s", jobName)
+	execution, err := client.RunJob(ctx, project, region, jobName)
+	if err != nil {
+		lp.Errorf("Failed to run job: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	executionID := filepath.Base(execution.Name)
+	metadata := map[string]string{
+		MetadataKeyJob:       jobName,
+		MetadataKeyExecution: executionID,
+	}
+	lp.Infof("Started execution %s", executionID)
+	if execution.LogUri != "" {
+		lp.Infof("Logs: %s", execution.LogUri)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, stageCfg.Timeout.Duration())
+	defer cancel()
+	execution, err = client.WaitForExecution(waitCtx, execution.Name)
+	if err != nil {
+		if ctx.Err() == nil && waitCtx.Err() != nil {
+			err = fmt.Errorf("execution %s did not finish within %s", executionID, stageCfg.Timeout.Duration())
+		}
+		lp.Errorf("Job execution failed: %v", err)
+		return &StageResult{
+			Status:   StageStatusFailure,
+			Metadata: metadata,
+		}, err
+	}
+
+	lp.Successf("Execution %s succeeded: %d task(s) completed", executionID, execution.SucceededCount)
+
+	return &StageResult{
+		Status:   StageStatusSuccess,
+		Metadata: metadata,
+	}, nil
+}
+
+// ExecuteJobRollbackStage executes the CLOUDRUN_JOB_ROLLBACK stage.
+//
+// This stage restores the job template recorded by CLOUDRUN_JOB_SYNC.
+// Executions that already started are not affected.
+func (e *StageExecutor) ExecuteJobRollbackStage(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	lp sdk.StageLogPersister,
+) (*StageResult, error) {
+	// Get deploy target config
+	if len(deployTargets) == 0 {
+		lp.Errorf("No deploy targets configured")
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, fmt.Errorf("no deploy targets configured")
+	}
+	dt := deployTargets[0]
+
+	// Get the Cloud Run client of the deploy target
+	clients, err := e.targetClients(ctx, cfg, dt, input)
+	if err != nil {
+		lp.Errorf("Failed to create Cloud Run client: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	client := clients.client
+	project, region := clients.project, clients.region
+
+	jobName := deployedJobName(ctx, input, lp)
+
+	data, ok, err := newMetadataStore(input.Client, metadataNamespaceJob).GetString(ctx, metadataKeyPreviousJobTemplate)
+	if err != nil {
+		lp.Errorf("Failed to get the previous job template: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	if !ok || data == "" {
+		lp.Successf("No previous template of job %s was recorded; nothing to restore", jobName)
+		return &StageResult{
+			Status:  StageStatusSuccess,
+			Message: "no previous job template",
+		}, nil
+	}
+	var template runpb.ExecutionTemplate
+	if err := protojson.Unmarshal([]byte(data), &template); err != nil {
+		lp.Errorf("Invalid previous job template: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+
+	job, err := client.GetJob(ctx, project, region, jobName)
+	if err != nil {
+		lp.Errorf("Failed to get job: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	job.Template = &template
+
+	lp.Infof("Restoring the previous template of job: %s", jobName)
+	if _, err := client.CreateOrUpdateJob(ctx, job); err != nil {
+		lp.Errorf("Failed to restore job: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+
+	lp.Successf("Successfully restored job: %s", jobName)
+
+	return &StageResult{
+		Status: StageStatusSuccess,
+		Metadata: map[string]string{
+			MetadataKeyJob: jobName,
+		},
+	}, nil
+}
+
+// loadJobManifest renders the job manifest of the target deployment source
+// with its renderer and deployment variables, and parses it.
+func loadJobManifest(
+	ctx context.Context,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+) (*runpb.Job, string, error) {
+	appDir := input.Request.TargetDeploymentSource.ApplicationDirectory
+	manifestPath, data, err := renderJobManifest(ctx, input.Request.TargetDeploymentSource.ApplicationConfig.Spec, appDir, stageVariables(ctx, deployTargets, input))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to render job manifest: %w", err)
+	}
+	job, err := cloudrun.ParseJobManifest(data)
+	if err != nil {
+		return nil, "", err
+	}
+	return job, filepath.Join(appDir, manifestPath), nil
+}
+
+// recordPreJobSyncState records the deployed job name and the template of the
+// existing job. The first recorded template of a deployment is kept, so a
+// retried sync doesn't replace it with the new template.
+func recordPreJobSyncState(
+	ctx context.Context,
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	jobName string,
+	existing *runpb.Job,
+	lp sdk.StageLogPersister,
+) {
+	store := newMetadataStore(input.Client, metadataNamespaceJob)
+	if err := store.PutString(ctx, metadataKeyJobName, jobName); err != nil {
+		lp.Infof("Warning: Failed to record job name: %v", err)
+	}
+	if existing == nil || existing.Template == nil {
+		return
+	}
+
+	data, err := protojson.Marshal(existing.Template)
+	if err != nil {
+		lp.Infof("Warning: Failed to encode the current job template: %v", err)
+		return
+	}
+	err = store.Update(ctx, metadataKeyPreviousJobTemplate, func(current string, ok bool) (string, error) {
+		if ok && current != "" {
+			return current, nil
+		}
+		return string(data), nil
+	})
+	if err != nil {
+		lp.Infof("Warning: Failed to record the current job template: %v", err)
+	}
+}
+
+// jobNameOf returns the job of the application: the configured name, then
+// the name in the job manifest (nil if it couldn't be loaded), then the
+// application ID.
+func jobNameOf(spec *config.ApplicationConfig, job *runpb.Job, applicationID string) string {
+	if spec.Input.JobName != "" {
+		return spec.Input.JobName
+	}
+	if job != nil && job.Name != "" {
+		if _, id, err := cloudrun.ParseJobName(job.Name); err == nil {
+			return id
+		}
+		return job.Name
+	}
+	return applicationID
+}
+
+// deployedJobName returns the job of the application: the configured name,
+// then the job deployed by CLOUDRUN_JOB_SYNC, then the application ID.
+func deployedJobName(
+	ctx context.Context,
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	lp sdk.StageLogPersister,
+) string {
+	if name := input.Request.TargetDeploymentSource.ApplicationConfig.Spec.Input.JobName; name != "" {
+		return name
+	}
+	name, ok, err := newMetadataStore(input.Client, metadataNamespaceJob).GetString(ctx, metadataKeyJobName)
+	if err != nil {
+		lp.Infof("Warning: Failed to get the deployed job name: %v", err)
+	}
+	if ok && name != "" {
+		return name
+	}
+	return input.Request.Deployment.ApplicationID
+}
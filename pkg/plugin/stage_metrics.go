@@ -0,0 +1,138 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// stageMetricsTimeout bounds pushing the metrics of a stage, which doesn't
+// share the stage deadline so failures of timed out stages are recorded.
+const stageMetricsTimeout = 10 * time.Second
+
+// stageMetricKey identifies the time series of a stage execution count.
+type stageMetricKey struct {
+	application, target, stage, status string
+}
+
+// stageCounters counts stage executions since the process started counting,
+// as Cloud Monitoring cumulative metrics are written as running totals.
+type stageCounters struct {
+	mu     sync.Mutex
+	start  time.Time
+	counts map[stageMetricKey]int64
+}
+
+// stageExecutions counts the stage executions of this process.
+var stageExecutions = newStageCounters()
+
+// newStageCounters returns counters starting now.
+func newStageCounters() *stageCounters {
+	return &stageCounters{
+		start:  time.Now(),
+		counts: make(map[stageMetricKey]int64),
+	}
+}
+
+// increment counts an execution and returns the new total and the time
+// counting started.
+func (c *stageCounters) increment(key stageMetricKey) (int64, time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[key]++
+	return c.counts[key], c.start
+}
+
+// stageMetric builds the metric of the stage execution and counts it.
+func stageMetric(
+	counters *stageCounters,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	result *StageResult,
+	started, now time.Time,
+) cloudrun.StageMetric {
+	m := cloudrun.StageMetric{
+		Application: input.Request.Deployment.ApplicationName,
+		Stage:       input.Request.StageName,
+		Status:      string(result.Status),
+		Duration:    now.Sub(started),
+		End:         now,
+	}
+	if len(deployTargets) > 0 {
+		m.DeployTarget = deployTargets[0].Name
+	}
+	m.Count, m.Start = counters.increment(stageMetricKey{m.Application, m.DeployTarget, m.Stage, m.Status})
+	return m
+}
+
+// stageMetricsProject returns the project stage metrics are written to.
+func stageMetricsProject(cfg *config.PluginConfig, deployTargets []*sdk.DeployTarget[config.DeployTargetConfig]) string {
+	if cfg.StageMetrics.ProjectID != "" {
+		return cfg.StageMetrics.ProjectID
+	}
+	if len(deployTargets) > 0 && deployTargets[0].Config.ProjectID != "" {
+		return deployTargets[0].Config.ProjectID
+	}
+	return cfg.ProjectID
+}
+
+// pushStageMetrics pushes the count and duration of the stage execution to
+// Cloud Monitoring if the plugin config enables it. Dry runs are not
+// counted. Failing to push them does not fail the stage.
+func pushStageMetrics(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	lp sdk.StageLogPersister,
+	result *StageResult,
+	started time.Time,
+) {
+	if cfg == nil || cfg.StageMetrics == nil {
+		return
+	}
+	if _, ok := cloudrun.DryRun(ctx); ok {
+		return
+	}
+	project := stageMetricsProject(cfg, deployTargets)
+	if project == "" {
+		lp.Info("Warning: Stage metrics not pushed: no project is configured")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), stageMetricsTimeout)
+	defer cancel()
+
+	var credentialsFile string
+	if len(deployTargets) > 0 {
+		credentialsFile = deployTargets[0].Config.CredentialsFile
+	}
+	writer, err := cloudrun.NewMetricWriter(ctx, credentialsFile)
+	if err != nil {
+		lp.Infof("Warning: Stage metrics not pushed: %v", err)
+		return
+	}
+	m := stageMetric(stageExecutions, deployTargets, input, result, started, time.Now())
+	if err := writer.WriteStageMetric(ctx, project, m); err != nil {
+		lp.Infof("Warning: Stage metrics not pushed: %v", err)
+	}
+}
@@ -0,0 +1,371 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math/rand"
+	"slices"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// maxMirrorReportMismatches is the number of mismatches kept in the report.
+const maxMirrorReportMismatches = 10
+
+// ExecuteMirrorVerifyStage executes the CLOUDRUN_MIRROR_VERIFY stage.
+//
+// This stage replays a sample of recorded production requests and synthetic
+// requests against the candidate tag URL and the stable tag URL, and compares
+// the status code and latency of each response:
+//
+//  1. Tag the candidate (latest created) and stable revisions with 0% traffic,
+//     if they aren't tagged yet
+//  2. Send each request to both revisions
+//  3. Restore the traffic split, and fail if more responses mismatched than
+//     tolerated
+func (e *StageExecutor) ExecuteMirrorVerifyStage(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	lp sdk.StageLogPersister,
+) (*StageResult, error) {
+	// Parse stage configuration
+	stageCfg := DefaultMirrorVerifyStageConfig()
+	if err := parseStageConfig(input.Request.StageConfig, stageCfg); err != nil {
+		lp.Errorf("Failed to parse stage config: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	if stageCfg.Capture == nil && len(stageCfg.Requests) == 0 {
+		lp.Errorf("No requests configured")
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, fmt.Errorf("capture or requests is required")
+	}
+	if stageCfg.CandidateTag == "" || stageCfg.StableTag == "" || stageCfg.CandidateTag == stageCfg.StableTag {
+		err := fmt.Errorf("candidateTag and stableTag must be set and different, got %q and %q", stageCfg.CandidateTag, stageCfg.StableTag)
+		lp.Errorf("Invalid stage config: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+
+	// Get deploy target config
+	if len(deployTargets) == 0 {
+		lp.Errorf("No deploy targets configured")
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, fmt.Errorf("no deploy targets configured")
+	}
+	dt := deployTargets[0]
+
+	// Get the Cloud Run client of the deploy target
+	clients, err := e.targetClients(ctx, cfg, dt, input)
+	if err != nil {
+		lp.Errorf("Failed to create Cloud Run client: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	client := clients.client
+	project, serviceName := clients.project, clients.service
+	region := deployedRegion(ctx, dt, input, clients.region, lp)
+
+	requests, err := mirrorRequests(ctx, dt, stageCfg, lp)
+	if err != nil {
+		lp.Errorf("Failed to load requests: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	if len(requests) == 0 {
+		lp.Errorf("No requests to replay")
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, fmt.Errorf("no requests to replay")
+	}
+
+	original, err := client.GetService(ctx, project, region, serviceName)
+	if err != nil {
+		lp.Errorf("Failed to get service: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	candidate := cloudrun.ShortRevisionName(original.LatestCreatedRevision)
+	stable := recordedStableRevision(ctx, input, lp)
+	if stable == "" {
+		stable = mainServingRevision(original, candidate)
+	}
+	if stable == "" || stable == candidate {
+		err := fmt.Errorf("no stable revision to compare candidate revision %s with", candidate)
+		lp.Errorf("%v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	lp.Infof("Comparing candidate revision %s with stable revision %s on %d request(s)", candidate, stable, len(requests))
+
+	// Tag the revisions for the stage if needed
+	traffic, tagged := mirrorTraffic(original.Traffic, map[string]string{
+		stageCfg.CandidateTag: candidate,
+		stageCfg.StableTag:    stable,
+	})
+	if tagged {
+		if _, ok := cloudrun.DryRun(ctx); ok {
+			lp.Infof("[dry run] Would tag revisions %s and %s and replay %d request(s)", candidate, stable, len(requests))
+			return &StageResult{
+				Status:   StageStatusSuccess,
+				Revision: candidate,
+			}, nil
+		}
+		lp.Infof("Tagging revisions %s as %q and %s as %q", candidate, stageCfg.CandidateTag, stable, stageCfg.StableTag)
+		if err := client.UpdateTraffic(ctx, project, region, serviceName, traffic); err != nil {
+			lp.Errorf("Failed to tag revisions: %v", err)
+			return &StageResult{
+				Status: StageStatusFailure,
+			}, err
+		}
+	}
+
+	// Replay the requests, then restore the traffic split whatever the outcome
+	report, err := replayMirrorRequests(ctx, client, dt, project, region, serviceName, stageCfg, requests, lp)
+	if err != nil {
+		lp.Errorf("Failed to replay requests: %v", err)
+	}
+	if tagged {
+		lp.Info("Restoring the traffic split")
+		if restoreErr := client.UpdateTraffic(context.WithoutCancel(ctx), project, region, serviceName, cloneTraffic(original.Traffic)); restoreErr != nil {
+			lp.Errorf("Failed to restore the traffic split: %v", restoreErr)
+			if err == nil {
+				err = restoreErr
+			}
+		}
+	}
+	if err != nil {
+		return &StageResult{
+			Status:   StageStatusFailure,
+			Revision: candidate,
+			Metadata: report.metadata(),
+		}, err
+	}
+
+	if report.Mismatches > stageCfg.MaxMismatches {
+		err := fmt.Errorf("%d of %d request(s) mismatched (limit %d)", report.Mismatches, report.Requests, stageCfg.MaxMismatches)
+		lp.Errorf("Candidate revision %s doesn't match the stable revision: %v", candidate, err)
+		return &StageResult{
+			Status:   StageStatusFailure,
+			Message:  "mirror verification failed: " + err.Error(),
+			Revision: candidate,
+			Metadata: report.metadata(),
+		}, err
+	}
+
+	lp.Successf("Candidate revision %s matched the stable revision on %d of %d request(s)", candidate, report.Requests-report.Mismatches, report.Requests)
+	return &StageResult{
+		Status:   StageStatusSuccess,
+		Revision: candidate,
+		Metadata: report.metadata(),
+	}, nil
+}
+
+// mirrorRequests returns the synthetic requests and a sample of the capture,
+// without unsafe methods unless they are allowed.
+func mirrorRequests(
+	ctx context.Context,
+	dt *sdk.DeployTarget[config.DeployTargetConfig],
+	stageCfg *MirrorVerifyStageConfig,
+	lp sdk.StageLogPersister,
+) ([]cloudrun.MirrorRequest, error) {
+	requests := append([]cloudrun.MirrorRequest(nil), stageCfg.Requests...)
+	if capture := stageCfg.Capture; capture != nil {
+		// Fill unset fields with defaults
+		sampleSize := capture.SampleSize
+		if sampleSize <= 0 {
+			sampleSize = DefaultMirrorCaptureConfig().SampleSize
+		}
+
+		lp.Infof("Reading request capture from %s", capture.URI)
+		data, err := cloudrun.ReadGCSObject(ctx, dt.Config.CredentialsFile, capture.URI)
+		if err != nil {
+			return nil, err
+		}
+		captured, err := cloudrun.ParseMirrorCapture(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid capture %s: %w", capture.URI, err)
+		}
+		sample := cloudrun.SampleMirrorRequests(captured, sampleSize, rand.New(rand.NewSource(time.Now().UnixNano())))
+		lp.Infof("Sampled %d of %d captured request(s)", len(sample), len(captured))
+		requests = append(requests, sample...)
+	}
+
+	if stageCfg.AllowUnsafeMethods {
+		return requests, nil
+	}
+	safe := requests[:0]
+	for _, r := range requests {
+		if r.IsSafe() {
+			safe = append(safe, r)
+		}
+	}
+	if skipped := len(requests) - len(safe); skipped > 0 {
+		lp.Infof("Warning: Skipping %d request(s) with methods other than GET, HEAD, and OPTIONS (set allowUnsafeMethods to replay them)", skipped)
+	}
+	return safe, nil
+}
+
+// replayMirrorRequests sends the requests to the tag URLs of both revisions
+// and compares the responses.
+func replayMirrorRequests(
+	ctx context.Context,
+	client cloudrun.Client,
+	dt *sdk.DeployTarget[config.DeployTargetConfig],
+	project, region, serviceName string,
+	stageCfg *MirrorVerifyStageConfig,
+	requests []cloudrun.MirrorRequest,
+	lp sdk.StageLogPersister,
+) (mirrorReport, error) {
+	report := mirrorReport{MaxMismatches: stageCfg.MaxMismatches}
+
+	svc, err := client.GetService(ctx, project, region, serviceName)
+	if err != nil {
+		return report, fmt.Errorf("failed to get service: %w", err)
+	}
+	target := cloudrun.MirrorTarget{
+		StableURL:    cloudrun.TagURL(svc, stageCfg.StableTag),
+		CandidateURL: cloudrun.TagURL(svc, stageCfg.CandidateTag),
+		Timeout:      stageCfg.RequestTimeout.Duration(),
+		Concurrency:  stageCfg.Concurrency,
+	}
+	if target.StableURL == "" || target.CandidateURL == "" {
+		return report, fmt.Errorf("service has no URL for tags %q and %q", stageCfg.StableTag, stageCfg.CandidateTag)
+	}
+	if stageCfg.Authenticated {
+		httpClient, err := cloudrun.NewAuthenticatedHTTPClient(ctx, svc.Uri, dt.Config.CredentialsFile)
+		if err != nil {
+			return report, err
+		}
+		target.HTTPClient = httpClient
+	}
+
+	lp.Infof("Replaying %d request(s) against %s and %s", len(requests), target.CandidateURL, target.StableURL)
+	results, err := cloudrun.ReplayMirrorRequests(ctx, target, requests)
+	if err != nil {
+		return report, err
+	}
+	report.add(results, stageCfg.MaxLatencyIncrease.Duration())
+	for _, m := range report.Samples {
+		lp.Infof("Mismatch: %s: %s", m.Request, m.Reason)
+	}
+	if report.Mismatches > len(report.Samples) {
+		lp.Infof("... and %d more mismatch(es)", report.Mismatches-len(report.Samples))
+	}
+	return report, nil
+}
+
+// mirrorTraffic returns the traffic targets with each tag pointing to its
+// revision (tag -> revision), and whether any target was added. Targets
+// already carrying a tag for another revision are untagged.
+func mirrorTraffic(traffic []*runpb.TrafficTarget, tags map[string]string) ([]*runpb.TrafficTarget, bool) {
+	out := cloneTraffic(traffic)
+	missing := make(map[string]string, len(tags))
+	for tag, revision := range tags {
+		missing[tag] = revision
+	}
+	for _, t := range out {
+		revision, ok := missing[t.Tag]
+		if !ok {
+			continue
+		}
+		if t.Type == runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION && cloudrun.ShortRevisionName(t.Revision) == revision {
+			delete(missing, t.Tag)
+			continue
+		}
+		t.Tag = ""
+	}
+	if len(missing) == 0 {
+		return out, false
+	}
+	for _, tag := range slices.Sorted(maps.Keys(missing)) {
+		out = append(out, taggedTrafficTarget(missing[tag], tag))
+	}
+	return out, true
+}
+
+// mainServingRevision returns the revision other than exclude serving the
+// most traffic, or an empty string if there is none.
+func mainServingRevision(svc *runpb.Service, exclude string) string {
+	var (
+		revision string
+		percent  int32
+	)
+	for _, t := range svc.TrafficStatuses {
+		name := cloudrun.ShortRevisionName(t.Revision)
+		if name == "" || name == exclude || t.Percent <= percent {
+			continue
+		}
+		revision, percent = name, t.Percent
+	}
+	return revision
+}
+
+// mirrorMismatch is a request the candidate answered differently.
+type mirrorMismatch struct {
+	Request string `json:"request"`
+	Reason  string `json:"reason"`
+}
+
+// mirrorReport is stored as the mirror verify stage metadata, so the UI and
+// notifications can show why the verification failed.
+type mirrorReport struct {
+	Requests      int              `json:"requests"`
+	Mismatches    int              `json:"mismatches"`
+	MaxMismatches int              `json:"maxMismatches"`
+	Samples       []mirrorMismatch `json:"samples,omitempty"`
+}
+
+// add counts the results, keeping the first mismatches as samples.
+func (r *mirrorReport) add(results []cloudrun.MirrorResult, maxLatencyIncrease time.Duration) {
+	for _, result := range results {
+		r.Requests++
+		reason := result.Mismatch(maxLatencyIncrease)
+		if reason == "" {
+			continue
+		}
+		r.Mismatches++
+		if len(r.Samples) < maxMirrorReportMismatches {
+			r.Samples = append(r.Samples, mirrorMismatch{Request: result.Request.String(), Reason: reason})
+		}
+	}
+}
+
+// metadata returns the report as stage metadata.
+func (r mirrorReport) metadata() map[string]string {
+	data, _ := json.Marshal(r)
+	return map[string]string{
+		MetadataKeyMirror: string(data),
+	}
+}
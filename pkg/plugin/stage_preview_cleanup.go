@@ -0,0 +1,114 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// ExecutePreviewCleanupStage executes the CLOUDRUN_PREVIEW_CLEANUP stage.
+//
+// This stage deletes the preview services deployed by CLOUDRUN_SYNC in preview
+// mode whose expiry label has passed. Only previews of the application's
+// service are deleted unless "all" is set.
+//
+// Services that fail to be deleted are logged and do not fail the stage.
+func (e *StageExecutor) ExecutePreviewCleanupStage(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	lp sdk.StageLogPersister,
+) (*StageResult, error) {
+	// Parse stage configuration
+	stageCfg := DefaultPreviewCleanupStageConfig()
+	if err := parseStageConfig(input.Request.StageConfig, stageCfg); err != nil {
+		lp.Errorf("Failed to parse stage config: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+
+	// Get deploy target config
+	if len(deployTargets) == 0 {
+		lp.Errorf("No deploy targets configured")
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, fmt.Errorf("no deploy targets configured")
+	}
+	dt := deployTargets[0]
+
+	// Get the Cloud Run client of the deploy target
+	clients, err := e.targetClients(ctx, cfg, dt, input)
+	if err != nil {
+		lp.Errorf("Failed to create Cloud Run client: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	client := clients.client
+	project, region, serviceName := clients.project, clients.region, clients.service
+	base := serviceName
+	if stageCfg.All {
+		base = ""
+		lp.Infof("Cleaning up expired preview services in %s/%s", project, region)
+	} else {
+		lp.Infof("Cleaning up expired preview services of %s", serviceName)
+	}
+
+	services, err := client.ListServices(ctx, project, region)
+	if err != nil {
+		lp.Errorf("Failed to list services: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+
+	expired := cloudrun.ExpiredPreviews(services, base, time.Now())
+	if len(expired) == 0 {
+		lp.Successf("No expired preview services")
+		return &StageResult{
+			Status: StageStatusSuccess,
+		}, nil
+	}
+
+	var deleted []string
+	for _, name := range expired {
+		// Never delete a service rejected by the deploy target's naming conventions
+		if err := checkServiceAllowed(dt, name); err != nil {
+			lp.Infof("Warning: Skipping preview service %s: %v", name, err)
+			continue
+		}
+		if err := client.DeleteService(ctx, project, region, name); err != nil {
+			lp.Infof("Warning: Failed to delete preview service %s: %v", name, err)
+			continue
+		}
+		lp.Infof("Deleted preview service %s", name)
+		deleted = append(deleted, name)
+	}
+
+	lp.Successf("Deleted %d of %d expired preview service(s)", len(deleted), len(expired))
+	return &StageResult{
+		Status:  StageStatusSuccess,
+		Message: fmt.Sprintf("deleted preview services: %v", deleted),
+	}, nil
+}
@@ -15,9 +15,15 @@
 package plugin
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+	"time"
 
+	"cloud.google.com/go/run/apiv2/runpb"
 	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
 
 	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
@@ -52,64 +58,90 @@ func (e *StageExecutor) ExecutePromoteStage(
 	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
 	input *sdk.ExecuteStageInput[config.ApplicationConfig],
 	lp sdk.StageLogPersister,
-) (*sdk.ExecuteStageResponse, error) {
+) (*StageResult, error) {
 	// Parse stage configuration
 	stageCfg := DefaultPromoteStageConfig()
 	if err := parseStageConfig(input.Request.StageConfig, stageCfg); err != nil {
 		lp.Errorf("Failed to parse stage config: %v", err)
-		return &sdk.ExecuteStageResponse{
-			Status: sdk.StageStatusFailure,
+		return &StageResult{
+			Status: StageStatusFailure,
 		}, err
 	}
 
+	// Without a percent, move to the next step of the app's canary config
+	var (
+		canaryMetadata map[string]string
+		canaryStep     int
+	)
+	canary := input.Request.TargetDeploymentSource.ApplicationConfig.Spec.Canary
+	if canary != nil && !hasStageConfigField(input.Request.StageConfig, "percent") {
+		step, number, err := nextCanaryStep(ctx, input, canary)
+		if err != nil {
+			lp.Errorf("Failed to determine canary step: %v", err)
+			return &StageResult{
+				Status: StageStatusFailure,
+			}, err
+		}
+		lp.Infof("Using canary step %d of %d: %d%%", number, len(canary.Steps), step)
+		stageCfg.Percent = int(step)
+		canaryMetadata = map[string]string{MetadataKeyCanaryStep: strconv.Itoa(number)}
+		canaryStep = number
+	}
+
 	// Validate percentage
 	if stageCfg.Percent < 0 || stageCfg.Percent > 100 {
 		lp.Errorf("Invalid traffic percentage: %d (must be 0-100)", stageCfg.Percent)
-		return &sdk.ExecuteStageResponse{
-			Status: sdk.StageStatusFailure,
+		return &StageResult{
+			Status: StageStatusFailure,
 		}, fmt.Errorf("invalid traffic percentage: %d", stageCfg.Percent)
 	}
+	if stageCfg.TargetTag != "" && stageCfg.CandidateTag != "" {
+		lp.Errorf("candidateTag can't be used with targetTag, the target tag stays on its revision")
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, fmt.Errorf("candidateTag can't be used with targetTag")
+	}
 
 	// Get deploy target config
 	if len(deployTargets) == 0 {
 		lp.Errorf("No deploy targets configured")
-		return &sdk.ExecuteStageResponse{
-			Status: sdk.StageStatusFailure,
+		return &StageResult{
+			Status: StageStatusFailure,
 		}, fmt.Errorf("no deploy targets configured")
 	}
 	dt := deployTargets[0]
 
-	// Resolve project and region
-	project := dt.Config.ProjectID
-	if project == "" {
-		project = cfg.ProjectID
-	}
-	region := dt.Config.Region
-	if region == "" {
-		region = cfg.Region
-	}
-
-	// Get service name
-	serviceName := input.Request.TargetDeploymentSource.ApplicationConfig.Spec.Input.ServiceName
-	if serviceName == "" {
-		// Try to get from metadata
-		serviceName = input.Request.Deployment.ApplicationID
+	// Refuse promotions too soon after the previous deployment
+	if stageCfg.Percent > 0 {
+		if err := checkPromotionCooldown(ctx, dt, input, lp, time.Now()); err != nil {
+			lp.Errorf("%v", err)
+			return &StageResult{
+				Status:  StageStatusFailure,
+				Message: err.Error(),
+			}, err
+		}
 	}
 
-	lp.Infof("Promoting service %s to %d%% traffic", serviceName, stageCfg.Percent)
-
-	// Create Cloud Run client
-	client, err := cloudrun.NewClient(ctx, dt.Config.CredentialsFile)
+	// Get the Cloud Run client of the deploy target
+	clients, err := e.targetClients(ctx, cfg, dt, input)
 	if err != nil {
 		lp.Errorf("Failed to create Cloud Run client: %v", err)
-		return &sdk.ExecuteStageResponse{
-			Status: sdk.StageStatusFailure,
+		return &StageResult{
+			Status: StageStatusFailure,
 		}, err
 	}
-	defer client.Close()
+	client := clients.client
+	project, serviceName := clients.project, clients.service
+	region := deployedRegion(ctx, dt, input, clients.region, lp)
+
+	if stageCfg.TargetTag != "" {
+		lp.Infof("Promoting traffic tag %s of service %s to %d%% traffic", stageCfg.TargetTag, serviceName, stageCfg.Percent)
+	} else {
+		lp.Infof("Promoting service %s to %d%% traffic", serviceName, stageCfg.Percent)
+	}
 
 	// Create traffic manager
-	tm := cloudrun.NewTrafficManager(client)
+	tm := clients.traffic
 
 	// Get current traffic for logging
 	currentTraffic, err := tm.GetCurrentTraffic(ctx, project, region, serviceName)
@@ -118,32 +150,481 @@ func (e *StageExecutor) ExecutePromoteStage(
 	} else {
 		lp.Info("Current traffic allocation:")
 		for _, t := range currentTraffic {
-			lp.Infof("  - %s: %d%%", t.RevisionName, t.Percent)
+			lp.Infof("  - %s: %d%%%s", t.RevisionName, t.Percent, formatTagSuffix(t.Tag))
+		}
+	}
+
+	// Resolve the revision the target tag points at; otherwise the
+	// candidate is the latest created revision
+	var candidate string
+	if stageCfg.TargetTag != "" {
+		svc, err := client.GetService(ctx, project, region, serviceName)
+		if err == nil {
+			_, candidate, err = cloudrun.TagTraffic(svc, stageCfg.TargetTag, int32(stageCfg.Percent), stageCfg.StableTag)
 		}
+		if err != nil {
+			lp.Errorf("Failed to resolve traffic tag %s: %v", stageCfg.TargetTag, err)
+			return &StageResult{
+				Status: StageStatusFailure,
+			}, err
+		}
+		lp.Infof("Traffic tag %s points at revision %s", stageCfg.TargetTag, candidate)
+	}
+
+	// Show what is about to receive traffic
+	if stageCfg.Percent > 0 {
+		logCandidateDiff(ctx, client, project, region, serviceName, candidate, lp)
+	}
+
+	// Require the candidate revision to have enough ready instances
+	if stageCfg.ReadyInstances != nil && stageCfg.Percent > 0 {
+		if err := waitForCandidateInstances(ctx, client, dt, project, region, serviceName, candidate, stageCfg.ReadyInstances, lp); err != nil {
+			lp.Errorf("Candidate revision is not ready for traffic: %v", err)
+			return &StageResult{
+				Status: StageStatusFailure,
+			}, err
+		}
+	}
+
+	// At full promotion, replace a canary revision deployed with overrides
+	// by a revision with the standard settings
+	if stageCfg.Percent == 100 && stageCfg.TargetTag == "" {
+		reverted, err := revertCanaryOverrides(ctx, client, project, region, serviceName, stageCfg.CandidateTag, lp)
+		if err != nil {
+			lp.Errorf("Failed to revert canary overrides: %v", err)
+			return &StageResult{
+				Status: StageStatusFailure,
+			}, err
+		}
+		if reverted != "" {
+			if stageCfg.SuccessfulRequests != nil {
+				if err := waitForCandidateRequests(ctx, client, dt, project, region, serviceName, reverted, stageCfg.SuccessfulRequests, lp); err != nil {
+					lp.Errorf("Revision is not serving successful requests: %v", err)
+					return &StageResult{
+						Status:   StageStatusFailure,
+						Revision: reverted,
+					}, err
+				}
+			}
+			stageResult := &StageResult{
+				Status:   StageStatusSuccess,
+				Revision: reverted,
+				Traffic:  map[string]int32{trafficKeyLatest: 100},
+				Metadata: canaryMetadata,
+			}
+			if stageCfg.Pause != nil {
+				if err := pausePromotion(ctx, input, stageCfg, stageResult, lp); err != nil {
+					return stageResult, err
+				}
+			}
+			recordCanaryStep(ctx, input, canaryStep, lp)
+			lp.Successf("Successfully promoted service to 100%% traffic with standard settings")
+			return stageResult, nil
+		}
+	}
+
+	// Warn about traffic pointing at manually deleted or failed revisions,
+	// which the promotion reallocates
+	if stale, err := tm.StaleTraffic(ctx, project, region, serviceName); err != nil {
+		lp.Infof("Warning: Failed to check the live traffic: %v", err)
+	} else {
+		logStaleTraffic(stale, lp)
 	}
 
 	// Perform promotion
-	if err := tm.Promote(ctx, project, region, serviceName, int32(stageCfg.Percent)); err != nil {
+	if stageCfg.TargetTag != "" {
+		candidate, err = tm.PromoteTag(ctx, project, region, serviceName, stageCfg.TargetTag, int32(stageCfg.Percent), stageCfg.StableTag)
+	} else {
+		err = tm.Promote(ctx, project, region, serviceName, int32(stageCfg.Percent), cloudrun.TrafficTags{
+			Candidate: stageCfg.CandidateTag,
+			Stable:    stageCfg.StableTag,
+		})
+	}
+	if err != nil {
 		lp.Errorf("Failed to promote service: %v", err)
-		return &sdk.ExecuteStageResponse{
-			Status: sdk.StageStatusFailure,
+		return &StageResult{
+			Status: StageStatusFailure,
 		}, err
 	}
 
+	stageResult := &StageResult{
+		Status:   StageStatusSuccess,
+		Metadata: canaryMetadata,
+	}
+
 	// Get new traffic allocation
 	newTraffic, err := tm.GetCurrentTraffic(ctx, project, region, serviceName)
 	if err != nil {
 		lp.Infof("Warning: Failed to get new traffic: %v", err)
 	} else {
+		stageResult.Traffic = trafficMapFromSplits(newTraffic)
 		lp.Info("New traffic allocation:")
 		for _, t := range newTraffic {
-			lp.Infof("  - %s: %d%%", t.RevisionName, t.Percent)
+			lp.Infof("  - %s: %d%%%s", t.RevisionName, t.Percent, formatTagSuffix(t.Tag))
+		}
+	}
+
+	// Report the candidate revision and tag URL
+	if svc, err := client.GetService(ctx, project, region, serviceName); err == nil {
+		stageResult.Revision = cloudrun.ShortRevisionName(svc.LatestCreatedRevision)
+		if candidate != "" {
+			stageResult.Revision = candidate
+		}
+		if tag := cmp.Or(stageCfg.TargetTag, stageCfg.CandidateTag); tag != "" {
+			if url := candidateTagURL(ctx, dt, svc, tag, stageCfg.TagReadiness, lp); url != "" {
+				if stageResult.Metadata == nil {
+					stageResult.Metadata = make(map[string]string)
+				}
+				stageResult.Metadata[MetadataKeyCandidateURL] = url
+			}
+		}
+	}
+
+	// Require the candidate revision to serve successful requests
+	if stageCfg.SuccessfulRequests != nil && stageCfg.Percent > 0 {
+		if err := waitForCandidateRequests(ctx, client, dt, project, region, serviceName, stageResult.Revision, stageCfg.SuccessfulRequests, lp); err != nil {
+			lp.Errorf("Candidate revision is not serving successful requests: %v", err)
+			stageResult.Status = StageStatusFailure
+			return stageResult, err
+		}
+	}
+
+	// Report how long the candidate revision takes to start
+	if stageCfg.ColdStartReport != nil && stageCfg.Percent > 0 && stageResult.Revision != "" {
+		baseline := baselineRevision(currentTraffic, stageResult.Revision)
+		if metadata := reportColdStart(ctx, client, dt, project, region, serviceName, stageResult.Revision, baseline, stageCfg.ColdStartReport, lp); metadata != nil {
+			if stageResult.Metadata == nil {
+				stageResult.Metadata = make(map[string]string)
+			}
+			maps.Copy(stageResult.Metadata, metadata)
+		}
+	}
+
+	// Keep the traffic at this percent until an operator resumes
+	if stageCfg.Pause != nil {
+		if err := pausePromotion(ctx, input, stageCfg, stageResult, lp); err != nil {
+			return stageResult, err
 		}
 	}
 
+	recordCanaryStep(ctx, input, canaryStep, lp)
 	lp.Successf("Successfully promoted service to %d%% traffic", stageCfg.Percent)
 
-	return &sdk.ExecuteStageResponse{
-		Status: sdk.StageStatusSuccess,
-	}, nil
+	return stageResult, nil
+}
+
+// pausePromotion waits for an operator to resume the progression, and records
+// who did in the stage result. An aborted or interrupted pause fails the
+// stage result.
+func pausePromotion(
+	ctx context.Context,
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	stageCfg *PromoteStageConfig,
+	stageResult *StageResult,
+	lp sdk.StageLogPersister,
+) error {
+	resumedBy, err := pauseProgression(ctx, input.Client, stageCfg.Pause, stageCfg.Percent, lp)
+	if err != nil {
+		lp.Errorf("Progression was aborted: %v", err)
+		stageResult.Status = StageStatusFailure
+		if ctx.Err() != nil {
+			stageResult.Status = StageStatusCancelled
+		}
+		return err
+	}
+	if resumedBy != "" {
+		if stageResult.Metadata == nil {
+			stageResult.Metadata = make(map[string]string)
+		}
+		stageResult.Metadata[MetadataKeyResumedBy] = resumedBy
+	}
+	return nil
+}
+
+// logCandidateDiff logs the configuration changes from the revision serving
+// the most traffic to the candidate revision (the latest created revision if
+// empty). Failures are logged as warnings.
+func logCandidateDiff(
+	ctx context.Context,
+	client cloudrun.Client,
+	project, region, serviceName, candidate string,
+	lp sdk.StageLogPersister,
+) {
+	svc, err := client.GetService(ctx, project, region, serviceName)
+	if err != nil {
+		lp.Infof("Warning: Failed to get service: %v", err)
+		return
+	}
+	if candidate == "" {
+		candidate = cloudrun.ShortRevisionName(svc.LatestCreatedRevision)
+	}
+	serving := mainServingRevision(svc, candidate)
+	if serving == "" {
+		return
+	}
+
+	infos, err := cloudrun.NewRevisionManager(client).GetRevisions(ctx, project, region, serviceName, []string{serving, candidate})
+	if err != nil {
+		lp.Infof("Warning: Failed to compare revisions: %v", err)
+		return
+	}
+	// The revisions are returned newest first, which is not always the candidate
+	if infos[0].Name == serving {
+		infos[0], infos[1] = infos[1], infos[0]
+	}
+	diff := cloudrun.CompareRevisions(infos[1], infos[0])
+	if len(diff.Changes) == 0 {
+		lp.Infof("Candidate revision %s has the same configuration as %s", candidate, serving)
+		return
+	}
+	lp.Infof("Changes from %s to candidate revision %s:", serving, candidate)
+	for _, c := range diff.Changes {
+		lp.Infof("  %s", c)
+	}
+}
+
+// revertCanaryOverrides deploys a new revision with the canary overrides reverted
+// and routes 100% traffic to it, keeping the other tags without traffic, see
+// cloudrun.LatestTraffic. It returns the new revision, or an empty string if the
+// latest revision was deployed without overrides.
+func revertCanaryOverrides(
+	ctx context.Context,
+	client cloudrun.Client,
+	project, region, serviceName, candidateTag string,
+	lp sdk.StageLogPersister,
+) (string, error) {
+	svc, err := client.GetService(ctx, project, region, serviceName)
+	if err != nil {
+		return "", err
+	}
+
+	reverted, err := cloudrun.RevertRevisionOverrides(svc.Template)
+	if err != nil || !reverted {
+		return "", err
+	}
+
+	lp.Info("Latest revision was deployed with canary overrides, deploying standard settings")
+	current := reconcileLiveTraffic(ctx, client, project, region, serviceName, svc.Traffic, lp)
+	svc.Traffic = cloudrun.LatestTraffic(current, candidateTag)
+
+	result, err := client.CreateOrUpdateService(ctx, svc)
+	if err != nil {
+		return "", err
+	}
+	if err := client.WaitForServiceReady(ctx, project, region, serviceName); err != nil {
+		return "", err
+	}
+
+	revision := cloudrun.ShortRevisionName(result.LatestCreatedRevision)
+	lp.Infof("Deployed revision with standard settings: %s", revision)
+	return revision, nil
+}
+
+// waitForCandidateInstances waits until the revision (the latest created
+// revision if empty) has the minimum number of ready instances configured in
+// the gate.
+func waitForCandidateInstances(
+	ctx context.Context,
+	client cloudrun.Client,
+	dt *sdk.DeployTarget[config.DeployTargetConfig],
+	project, region, serviceName, revision string,
+	gateCfg *ReadyInstancesGateConfig,
+	lp sdk.StageLogPersister,
+) error {
+	// Fill unset fields with defaults
+	defaults := DefaultReadyInstancesGateConfig()
+	timeout := gateCfg.Timeout.Duration()
+	if timeout <= 0 {
+		timeout = defaults.Timeout.Duration()
+	}
+	interval := gateCfg.Interval.Duration()
+	if interval <= 0 {
+		interval = defaults.Interval.Duration()
+	}
+
+	if revision == "" {
+		svc, err := client.GetService(ctx, project, region, serviceName)
+		if err != nil {
+			return err
+		}
+		revision = cloudrun.ShortRevisionName(svc.LatestCreatedRevision)
+	}
+
+	counter, err := cloudrun.NewInstanceCounter(ctx, dt.Config.CredentialsFile)
+	if err != nil {
+		return err
+	}
+
+	lp.Infof("Waiting for revision %s to have at least %d ready instance(s) (timeout %s)", revision, gateCfg.Min, timeout)
+	count, err := cloudrun.WaitForReadyInstances(ctx, client, counter, project, region, serviceName, revision, cloudrun.ReadyInstancesGate{
+		MinInstances: gateCfg.Min,
+		Timeout:      timeout,
+		Interval:     interval,
+	})
+	if err != nil {
+		return err
+	}
+
+	lp.Infof("Revision %s has %d ready instance(s)", revision, count)
+	return nil
+}
+
+// waitForCandidateRequests waits until the revision (the latest created
+// revision if empty) has served the minimum number of successful requests
+// configured in the gate, counted from the revision creation.
+func waitForCandidateRequests(
+	ctx context.Context,
+	client cloudrun.Client,
+	dt *sdk.DeployTarget[config.DeployTargetConfig],
+	project, region, serviceName, revision string,
+	gateCfg *SuccessfulRequestsGateConfig,
+	lp sdk.StageLogPersister,
+) error {
+	// Fill unset fields with defaults
+	defaults := DefaultSuccessfulRequestsGateConfig()
+	minRequests := gateCfg.Min
+	if minRequests <= 0 {
+		minRequests = defaults.Min
+	}
+	timeout := gateCfg.Timeout.Duration()
+	if timeout <= 0 {
+		timeout = defaults.Timeout.Duration()
+	}
+	interval := gateCfg.Interval.Duration()
+	if interval <= 0 {
+		interval = defaults.Interval.Duration()
+	}
+
+	if revision == "" {
+		svc, err := client.GetService(ctx, project, region, serviceName)
+		if err != nil {
+			return err
+		}
+		revision = cloudrun.ShortRevisionName(svc.LatestCreatedRevision)
+	}
+	rev, err := client.GetRevision(ctx, project, region, serviceName, revision)
+	if err != nil {
+		return err
+	}
+
+	counter, err := cloudrun.NewRequestCounter(ctx, dt.Config.CredentialsFile)
+	if err != nil {
+		return err
+	}
+
+	lp.Infof("Waiting for revision %s to serve at least %d successful request(s) (timeout %s)", revision, minRequests, timeout)
+	counts, err := cloudrun.WaitForSuccessfulRequests(ctx, counter, project, region, serviceName, revision, cloudrun.SuccessfulRequestsGate{
+		MinRequests: minRequests,
+		Since:       rev.CreateTime.AsTime(),
+		Timeout:     timeout,
+		Interval:    interval,
+	})
+	if err != nil {
+		return err
+	}
+
+	lp.Infof("Revision %s served %d successful request(s) (%d 5xx, %d 4xx)", revision, counts.Success, counts.ServerError, counts.ClientError)
+	return nil
+}
+
+// nextCanaryStep returns the traffic percentage of the next canary step and its
+// 1-based number. The last applied step is tracked in the metadata store.
+func nextCanaryStep(
+	ctx context.Context,
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	spec *config.CanaryConfig,
+) (int32, int, error) {
+	canary := &cloudrun.CanaryConfig{Steps: spec.Steps}
+	if err := canary.Validate(); err != nil {
+		return 0, 0, err
+	}
+
+	applied, _, err := getMetadataJSON[int](ctx, newMetadataStore(input.Client, targetNamespace(ctx, metadataNamespacePromote)), MetadataKeyCanaryStep)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get applied canary step: %w", err)
+	}
+
+	step, err := canary.Step(applied)
+	if err != nil {
+		return 0, 0, err
+	}
+	return step, applied + 1, nil
+}
+
+// candidateTagURL returns the URL of the candidate tag. If readiness is
+// configured, the URL is polled until it responds; an empty string is
+// returned if it doesn't, so reviewers never get a URL that fails.
+func candidateTagURL(
+	ctx context.Context,
+	dt *sdk.DeployTarget[config.DeployTargetConfig],
+	svc *runpb.Service,
+	tag string,
+	readiness *TagReadinessConfig,
+	lp sdk.StageLogPersister,
+) string {
+	url := cloudrun.TagURL(svc, tag)
+	if url == "" || readiness == nil {
+		return url
+	}
+
+	// Fill unset fields with defaults
+	defaults := DefaultTagReadinessConfig()
+	path := readiness.Path
+	if path == "" {
+		path = defaults.Path
+	}
+	timeout := readiness.Timeout.Duration()
+	if timeout <= 0 {
+		timeout = defaults.Timeout.Duration()
+	}
+	interval := readiness.Interval.Duration()
+	if interval <= 0 {
+		interval = defaults.Interval.Duration()
+	}
+
+	healthCheck := cloudrun.HealthCheck{
+		URL:            strings.TrimSuffix(url, "/") + "/" + strings.TrimPrefix(path, "/"),
+		ExpectedStatus: readiness.ExpectedStatus,
+		Timeout:        timeout,
+		Interval:       interval,
+	}
+	if readiness.Authenticated {
+		httpClient, err := cloudrun.NewAuthenticatedHTTPClient(ctx, svc.Uri, dt.Config.CredentialsFile)
+		if err != nil {
+			lp.Infof("Warning: Failed to create authenticated HTTP client: %v", err)
+			return ""
+		}
+		healthCheck.HTTPClient = httpClient
+	}
+
+	lp.Infof("Waiting for tag URL %s to respond", healthCheck.URL)
+	if err := cloudrun.CheckHealth(ctx, healthCheck); err != nil {
+		lp.Infof("Warning: Tag URL is not ready, not exposing it: %v", err)
+		return ""
+	}
+	lp.Infof("Candidate URL: %s", url)
+	return url
+}
+
+// recordCanaryStep stores the number of the applied canary step, if any.
+func recordCanaryStep(
+	ctx context.Context,
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	step int,
+	lp sdk.StageLogPersister,
+) {
+	if step == 0 {
+		return
+	}
+	if err := putMetadataJSON(ctx, newMetadataStore(input.Client, targetNamespace(ctx, metadataNamespacePromote)), MetadataKeyCanaryStep, step); err != nil {
+		lp.Infof("Warning: Failed to record canary step: %v", err)
+	}
+}
+
+// formatTagSuffix formats a traffic tag for log output.
+func formatTagSuffix(tag string) string {
+	if tag == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (tag: %s)", tag)
 }
@@ -0,0 +1,218 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// Metadata keys written for each stage result. They are stored both as stage
+// metadata and as deployment plugin metadata, where the last stage wins, so the
+// deployment summary always shows the latest state of the service.
+const (
+	MetadataKeyStatus           = "status"
+	MetadataKeyMessage          = "message"
+	MetadataKeyRevision         = "revision"
+	MetadataKeyTraffic          = "traffic"
+	MetadataKeyDeletedRevisions = "deletedRevisions"
+
+	// MetadataKeyCandidateURL is the URL of the candidate traffic tag set by
+	// CLOUDRUN_PROMOTE. It is only written once the URL responds if tag
+	// readiness is configured.
+	MetadataKeyCandidateURL = "candidateURL"
+
+	// MetadataKeyCanaryStep is the number of the last canary step applied by
+	// CLOUDRUN_PROMOTE, counted from 1.
+	MetadataKeyCanaryStep = "canaryStep"
+
+	// MetadataKeyAutoPromoted is set to "true" on the last stage of a
+	// pipeline that promoted the canary to 100% automatically.
+	MetadataKeyAutoPromoted = "autoPromoted"
+
+	// MetadataKeyResumedBy is the operator who resumed the progression of a
+	// paused CLOUDRUN_PROMOTE.
+	MetadataKeyResumedBy = "resumedBy"
+
+	// MetadataKeyPreviewService and MetadataKeyPreviewURL are the name and URL
+	// of the preview service deployed by CLOUDRUN_SYNC in preview mode.
+	MetadataKeyPreviewService = "previewService"
+	MetadataKeyPreviewURL     = "previewURL"
+
+	// MetadataKeyAnalysis is the JSON report of CLOUDRUN_ANALYSIS: the number
+	// of checks and failures, and the value, threshold, and verdict of each
+	// query in the last check (or the last failed check).
+	MetadataKeyAnalysis = "analysis"
+
+	// MetadataKeyColdStart is the JSON report of the cold start latency of
+	// the candidate revision of CLOUDRUN_PROMOTE, and of the revision serving
+	// before it.
+	MetadataKeyColdStart = "coldStart"
+
+	// MetadataKeyMirror is the JSON report of CLOUDRUN_MIRROR_VERIFY: the
+	// number of replayed requests and mismatches, and the first mismatches.
+	MetadataKeyMirror = "mirror"
+
+	// MetadataKeyDomains is the JSON report of CLOUDRUN_DOMAIN_VERIFY: the
+	// addresses, certificate expiry, HTTPS status, and error of each domain.
+	MetadataKeyDomains = "domains"
+
+	// MetadataKeyBackup is the Cloud Storage URI of the backup written by
+	// CLOUDRUN_BACKUP or restored by CLOUDRUN_RESTORE.
+	MetadataKeyBackup = "backup"
+
+	// MetadataKeyJob and MetadataKeyExecution are the job deployed or run by
+	// the job stages, and the execution started by CLOUDRUN_JOB_RUN.
+	MetadataKeyJob       = "job"
+	MetadataKeyExecution = "execution"
+
+	// MetadataKeyFailoverRegion is the secondary region CLOUDRUN_SYNC
+	// deployed to after the primary region failed.
+	MetadataKeyFailoverRegion = "failoverRegion"
+
+	// MetadataKeyServices is the JSON report of the services CLOUDRUN_SYNC
+	// deployed from a manifest with several: the name, revision, URL, and
+	// readiness of each, the first service first.
+	MetadataKeyServices = "services"
+
+	// MetadataKeyDryRun is set to "true" for stages of a dry-run deployment.
+	MetadataKeyDryRun = "dryRun"
+
+	// MetadataKeyResult is the JSON deployment result document, written to
+	// the deployment metadata only, by the stage ending the deployment.
+	MetadataKeyResult = "result"
+)
+
+// trafficKeyLatest is the traffic map key for the latest revision.
+const trafficKeyLatest = "LATEST"
+
+// ToMetadata flattens the result into machine-readable metadata.
+//
+// Example:
+//
+//	status: SUCCESS
+//	revision: my-service-00042
+//	traffic: {"LATEST":10,"my-service-00041":90}
+//	deletedRevisions: my-service-00030,my-service-00031
+func (r *StageResult) ToMetadata() map[string]string {
+	metadata := make(map[string]string, len(r.Metadata)+5)
+	for k, v := range r.Metadata {
+		metadata[k] = v
+	}
+
+	metadata[MetadataKeyStatus] = string(r.Status)
+	if r.Message != "" {
+		metadata[MetadataKeyMessage] = r.Message
+	}
+	if r.Revision != "" {
+		metadata[MetadataKeyRevision] = r.Revision
+	}
+	if len(r.Traffic) > 0 {
+		// Map keys are sorted by encoding/json, so the value is stable
+		data, _ := json.Marshal(r.Traffic)
+		metadata[MetadataKeyTraffic] = string(data)
+	}
+	if len(r.DeletedRevisions) > 0 {
+		metadata[MetadataKeyDeletedRevisions] = strings.Join(r.DeletedRevisions, ",")
+	}
+
+	return metadata
+}
+
+// resultKeys are the metadata keys of the result fields, which every stage
+// owns in the deployment plugin metadata.
+var resultKeys = []string{
+	MetadataKeyStatus,
+	MetadataKeyMessage,
+	MetadataKeyRevision,
+	MetadataKeyTraffic,
+	MetadataKeyDeletedRevisions,
+}
+
+// deploymentMetadata returns the metadata of the result to store as
+// deployment plugin metadata. Result keys the result doesn't set are cleared,
+// so the values of earlier stages don't outlive them. Other keys, such as
+// candidateURL, stay until a later stage writes them.
+func (r *StageResult) deploymentMetadata() map[string]string {
+	metadata := r.ToMetadata()
+	for _, k := range resultKeys {
+		if _, ok := metadata[k]; !ok {
+			metadata[k] = ""
+		}
+	}
+	return metadata
+}
+
+// toResponse converts the result to the SDK response.
+func (r *StageResult) toResponse() *sdk.ExecuteStageResponse {
+	// Cancelled stages are reported as failures
+	if r.Status == StageStatusSuccess {
+		return &sdk.ExecuteStageResponse{
+			Status: sdk.StageStatusSuccess,
+		}
+	}
+	return &sdk.ExecuteStageResponse{
+		Status: sdk.StageStatusFailure,
+	}
+}
+
+// reportStageResult stores the result as stage metadata and deployment plugin
+// metadata. Failing to store metadata does not fail the stage.
+func reportStageResult(
+	ctx context.Context,
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	lp sdk.StageLogPersister,
+	result *StageResult,
+) {
+	if err := input.Client.PutStageMetadataMulti(ctx, result.ToMetadata()); err != nil {
+		lp.Infof("Warning: Failed to store stage metadata: %v", err)
+	}
+	if err := input.Client.PutDeploymentPluginMetadataMulti(ctx, result.deploymentMetadata()); err != nil {
+		lp.Infof("Warning: Failed to store deployment metadata: %v", err)
+	}
+}
+
+// trafficMapFromTargets builds a traffic map keyed by revision name.
+func trafficMapFromTargets(traffic []*runpb.TrafficTarget) map[string]int32 {
+	m := make(map[string]int32, len(traffic))
+	for _, t := range traffic {
+		if t.Type == runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST {
+			m[trafficKeyLatest] += t.Percent
+		} else {
+			m[t.Revision] += t.Percent
+		}
+	}
+	return m
+}
+
+// trafficMapFromSplits builds a traffic map keyed by revision name.
+func trafficMapFromSplits(splits []cloudrun.TrafficSplit) map[string]int32 {
+	m := make(map[string]int32, len(splits))
+	for _, s := range splits {
+		if s.IsLatest {
+			m[trafficKeyLatest] += s.Percent
+		} else {
+			m[s.RevisionName] += s.Percent
+		}
+	}
+	return m
+}
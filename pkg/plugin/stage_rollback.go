@@ -17,6 +17,7 @@ package plugin
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
 
@@ -35,10 +36,17 @@ import (
 //     - Finds the second most recent revision
 //     - Routes 100% traffic to it
 //
-//  2. Rollback to specific revision:
+//  2. Rollback to the last successful deployment (no recorded stable revision):
+//     - Uses the revision serving when the last deployment completed
+//     - Routes 100% traffic to it
+//
+//  3. Rollback to specific revision:
 //     - Uses the revision specified in config
 //     - Routes 100% traffic to it
 //
+// The other services of a multi-service manifest are rolled back to the
+// revision serving before CLOUDRUN_SYNC deployed them.
+//
 // Example Pipeline with Rollback:
 //
 //	┌─────────────┐     ┌─────────────┐     ┌─────────────┐
@@ -57,56 +65,41 @@ func (e *StageExecutor) ExecuteRollbackStage(
 	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
 	input *sdk.ExecuteStageInput[config.ApplicationConfig],
 	lp sdk.StageLogPersister,
-) (*sdk.ExecuteStageResponse, error) {
+) (*StageResult, error) {
 	// Parse stage configuration
 	stageCfg := DefaultRollbackStageConfig()
 	if err := parseStageConfig(input.Request.StageConfig, stageCfg); err != nil {
 		lp.Errorf("Failed to parse stage config: %v", err)
-		return &sdk.ExecuteStageResponse{
-			Status: sdk.StageStatusFailure,
+		return &StageResult{
+			Status: StageStatusFailure,
 		}, err
 	}
 
 	// Get deploy target config
 	if len(deployTargets) == 0 {
 		lp.Errorf("No deploy targets configured")
-		return &sdk.ExecuteStageResponse{
-			Status: sdk.StageStatusFailure,
+		return &StageResult{
+			Status: StageStatusFailure,
 		}, fmt.Errorf("no deploy targets configured")
 	}
 	dt := deployTargets[0]
 
-	// Resolve project and region
-	project := dt.Config.ProjectID
-	if project == "" {
-		project = cfg.ProjectID
-	}
-	region := dt.Config.Region
-	if region == "" {
-		region = cfg.Region
-	}
-
-	// Get service name
-	serviceName := input.Request.TargetDeploymentSource.ApplicationConfig.Spec.Input.ServiceName
-	if serviceName == "" {
-		serviceName = input.Request.Deployment.ApplicationID
-	}
-
-	lp.Infof("Rolling back service: %s", serviceName)
-
-	// Create Cloud Run client
-	client, err := cloudrun.NewClient(ctx, dt.Config.CredentialsFile)
+	// Get the Cloud Run client of the deploy target
+	clients, err := e.targetClients(ctx, cfg, dt, input)
 	if err != nil {
 		lp.Errorf("Failed to create Cloud Run client: %v", err)
-		return &sdk.ExecuteStageResponse{
-			Status: sdk.StageStatusFailure,
+		return &StageResult{
+			Status: StageStatusFailure,
 		}, err
 	}
-	defer client.Close()
+	client := clients.client
+	project, region, serviceName := clients.project, clients.region, clients.service
+
+	lp.Infof("Rolling back service: %s", serviceName)
 
 	// Create revision manager
-	rm := cloudrun.NewRevisionManager(client)
-	tm := cloudrun.NewTrafficManager(client)
+	rm := clients.revisions
+	tm := clients.traffic
 
 	var targetRevision string
 
@@ -114,14 +107,26 @@ func (e *StageExecutor) ExecuteRollbackStage(
 		// Rollback to specific revision
 		targetRevision = stageCfg.Revision
 		lp.Infof("Rolling back to specified revision: %s", targetRevision)
+	} else if stable := recordedStableRevision(ctx, input, lp); stable != "" {
+		// Rollback to the revision serving traffic before this deployment
+		targetRevision = stable
+		lp.Infof("Rolling back to revision serving before this deployment: %s", targetRevision)
+	} else if last := lastSuccessfulRevision(ctx, input, lp); last != "" {
+		// Rollback to the revision of the last successful deployment
+		targetRevision = last
+		lp.Infof("Rolling back to revision of the last successful deployment: %s", targetRevision)
 	} else {
-		// Rollback to previous revision
+		// Rollback to the most recent ready revision that served traffic
 		lp.Info("Finding previous revision...")
-		prevRev, err := rm.GetPreviousRevision(ctx, project, region, serviceName)
+		served, err := getTrafficHistory(ctx, input.Client)
+		if err != nil {
+			lp.Infof("Warning: %v", err)
+		}
+		prevRev, err := rm.GetPreviousRevision(ctx, project, region, serviceName, served)
 		if err != nil {
 			lp.Errorf("Failed to find previous revision: %v", err)
-			return &sdk.ExecuteStageResponse{
-				Status: sdk.StageStatusFailure,
+			return &StageResult{
+				Status: StageStatusFailure,
 			}, err
 		}
 		targetRevision = prevRev.Name
@@ -137,16 +142,120 @@ func (e *StageExecutor) ExecuteRollbackStage(
 	}
 
 	// Perform rollback
-	if err := tm.Rollback(ctx, project, region, serviceName, targetRevision); err != nil {
+	if stageCfg.Tag != "" {
+		lp.Infof("Tagging rollback target with: %s", stageCfg.Tag)
+	}
+	if err := tm.Rollback(ctx, project, region, serviceName, targetRevision, stageCfg.Tag); err != nil {
 		lp.Errorf("Failed to rollback service: %v", err)
-		return &sdk.ExecuteStageResponse{
-			Status: sdk.StageStatusFailure,
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+
+	// Roll back the other services of the manifest too
+	if err := rollbackAdditionalServices(ctx, input.Client, tm, project, region, lp); err != nil {
+		return &StageResult{
+			Status:   StageStatusFailure,
+			Message:  err.Error(),
+			Revision: targetRevision,
 		}, err
 	}
 
+	// Confirm the rollback actually restored service
+	if stageCfg.Verify != nil {
+		lp.Info("Verifying the restored revision...")
+		if err := verifyRollback(ctx, client, dt, project, region, serviceName, targetRevision, stageCfg.Verify, lp); err != nil {
+			lp.Errorf("Rollback did not restore service: %v", err)
+			return &StageResult{
+				Status:   StageStatusFailure,
+				Message:  fmt.Sprintf("rollback verification failed: %v", err),
+				Revision: targetRevision,
+			}, err
+		}
+		lp.Info("Restored revision is healthy")
+	}
+
+	if err := newMetadataStore(input.Client, targetNamespace(ctx, metadataNamespaceRollback)).PutString(ctx, metadataKeyRolledBack, "true"); err != nil {
+		lp.Infof("Warning: Failed to record rollback: %v", err)
+	}
+
 	lp.Successf("Successfully rolled back to revision: %s", targetRevision)
 
-	return &sdk.ExecuteStageResponse{
-		Status: sdk.StageStatusSuccess,
+	return &StageResult{
+		Status:   StageStatusSuccess,
+		Revision: targetRevision,
+		Traffic:  map[string]int32{targetRevision: 100},
 	}, nil
 }
+
+// recordedStableRevision returns the stable revision recorded by CLOUDRUN_SYNC,
+// or an empty string if none was recorded.
+func recordedStableRevision(
+	ctx context.Context,
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	lp sdk.StageLogPersister,
+) string {
+	stable, _, err := newMetadataStore(input.Client, targetNamespace(ctx, metadataNamespaceSync)).GetString(ctx, metadataKeyStableRevision)
+	if err != nil {
+		lp.Infof("Warning: Failed to get recorded stable revision: %v", err)
+		return ""
+	}
+	return stable
+}
+
+// verifyRollback checks that the service is ready, serves all traffic from the
+// restored revision, and answers the health check.
+func verifyRollback(
+	ctx context.Context,
+	client cloudrun.Client,
+	dt *sdk.DeployTarget[config.DeployTargetConfig],
+	project, region, serviceName, revision string,
+	verifyCfg *RollbackVerifyConfig,
+	lp sdk.StageLogPersister,
+) error {
+	// Fill unset fields with defaults
+	defaults := DefaultRollbackVerifyConfig()
+	path := verifyCfg.Path
+	if path == "" {
+		path = defaults.Path
+	}
+	timeout := verifyCfg.Timeout.Duration()
+	if timeout <= 0 {
+		timeout = defaults.Timeout.Duration()
+	}
+	interval := verifyCfg.Interval.Duration()
+	if interval <= 0 {
+		interval = defaults.Interval.Duration()
+	}
+
+	readyCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := client.WaitForServiceReady(readyCtx, project, region, serviceName); err != nil {
+		return fmt.Errorf("service is not ready: %w", err)
+	}
+
+	svc, err := client.GetService(ctx, project, region, serviceName)
+	if err != nil {
+		return fmt.Errorf("failed to get service: %w", err)
+	}
+	if err := cloudrun.VerifyServingRevision(svc, revision); err != nil {
+		return err
+	}
+
+	healthCheck := cloudrun.HealthCheck{
+		URL:            strings.TrimSuffix(svc.Uri, "/") + "/" + strings.TrimPrefix(path, "/"),
+		ExpectedStatus: verifyCfg.ExpectedStatus,
+		Timeout:        timeout,
+		Interval:       interval,
+	}
+	if verifyCfg.Authenticated {
+		httpClient, err := cloudrun.NewAuthenticatedHTTPClient(ctx, svc.Uri, dt.Config.CredentialsFile)
+		if err != nil {
+			return err
+		}
+		healthCheck.HTTPClient = httpClient
+	}
+
+	lp.Infof("Checking %s", healthCheck.URL)
+	return cloudrun.CheckHealth(ctx, healthCheck)
+}
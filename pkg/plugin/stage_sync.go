@@ -17,12 +17,13 @@ package plugin
 import (
 	"context"
 	"fmt"
-	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/run/apiv2/runpb"
 	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
-	"google.golang.org/protobuf/encoding/protojson"
 
 	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
 	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
@@ -33,8 +34,9 @@ import (
 // This stage:
 //  1. Reads the service manifest from the application directory
 //  2. Applies any image overrides from the app config
-//  3. Creates or updates the Cloud Run service
-//  4. Optionally routes traffic to the new revision
+//  3. Applies canary overrides from the stage config, if any
+//  4. Creates or updates the Cloud Run service
+//  5. Optionally routes traffic to the new revision
 //
 // For Quick Sync: Routes 100% traffic immediately
 // For Pipeline Sync: May skip traffic shift (controlled by skipTrafficShift option)
@@ -44,75 +46,65 @@ func (e *StageExecutor) ExecuteSyncStage(
 	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
 	input *sdk.ExecuteStageInput[config.ApplicationConfig],
 	lp sdk.StageLogPersister,
-) (*sdk.ExecuteStageResponse, error) {
+) (*StageResult, error) {
 	// Parse stage configuration
 	stageCfg := DefaultSyncStageConfig()
 	if err := parseStageConfig(input.Request.StageConfig, stageCfg); err != nil {
 		lp.Errorf("Failed to parse stage config: %v", err)
-		return &sdk.ExecuteStageResponse{
-			Status: sdk.StageStatusFailure,
+		return &StageResult{
+			Status: StageStatusFailure,
 		}, err
 	}
 
 	// Get deploy target config (use first deploy target)
 	if len(deployTargets) == 0 {
 		lp.Errorf("No deploy targets configured")
-		return &sdk.ExecuteStageResponse{
-			Status: sdk.StageStatusFailure,
+		return &StageResult{
+			Status: StageStatusFailure,
 		}, fmt.Errorf("no deploy targets configured")
 	}
 	dt := deployTargets[0]
 
-	// Resolve project and region (deploy target overrides plugin config)
-	project := dt.Config.ProjectID
-	if project == "" {
-		project = cfg.ProjectID
-	}
-	region := dt.Config.Region
-	if region == "" {
-		region = cfg.Region
-	}
-
-	lp.Infof("Deploying to project: %s, region: %s", project, region)
-
-	// Create Cloud Run client
-	client, err := cloudrun.NewClient(ctx, dt.Config.CredentialsFile)
+	// Get the Cloud Run client of the deploy target
+	clients, err := e.targetClients(ctx, cfg, dt, input)
 	if err != nil {
 		lp.Errorf("Failed to create Cloud Run client: %v", err)
-		return &sdk.ExecuteStageResponse{
-			Status: sdk.StageStatusFailure,
+		return &StageResult{
+			Status: StageStatusFailure,
 		}, err
 	}
-	defer client.Close()
+	client := clients.client
+	project, region := clients.project, clients.region
 
-	// Read service manifest
-	appDir := input.Request.RunningDeploymentSource.ApplicationDirectory
-	manifestPath := input.Request.TargetDeploymentSource.ApplicationConfig.Spec.ServiceManifestPath
-	if manifestPath == "" {
-		manifestPath = "service.yaml" // Default manifest path
-	}
-
-	fullManifestPath := filepath.Join(appDir, manifestPath)
-	lp.Infof("Reading service manifest from: %s", fullManifestPath)
+	lp.Infof("Deploying to project: %s, region: %s", project, region)
 
-	manifestData, err := os.ReadFile(fullManifestPath)
+	// Render service manifest
+	appDir := input.Request.RunningDeploymentSource.ApplicationDirectory
+	spec := input.Request.TargetDeploymentSource.ApplicationConfig.Spec
+	manifestPaths, manifestData, err := renderServiceManifest(ctx, cfg, spec, appDir, stageVariables(ctx, deployTargets, input))
 	if err != nil {
-		lp.Errorf("Failed to read service manifest: %v", err)
-		return &sdk.ExecuteStageResponse{
-			Status: sdk.StageStatusFailure,
+		lp.Errorf("Failed to render service manifest: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
 		}, err
 	}
+	for _, manifestPath := range manifestPaths {
+		lp.Infof("Rendered service manifest %s with the %s renderer", filepath.Join(appDir, manifestPath), rendererName(spec))
+		if overlay, ok := manifestOverlay(appDir, manifestPath, dt.Name); ok {
+			lp.Infof("Merged overlay %s for deploy target %s", filepath.Join(appDir, overlay), dt.Name)
+		}
+	}
 
-	// Parse service manifest (JSON format)
-	var service runpb.Service
-	if err := protojson.Unmarshal(manifestData, &service); err != nil {
+	// Parse service manifest (Knative or Admin API v2, JSON or YAML). The
+	// services following the first one are deployed alongside it
+	services, err := cloudrun.ParseServiceManifests(manifestData)
+	if err != nil {
 		lp.Errorf("Failed to parse service manifest: %v", err)
-		lp.Info("Attempting to parse as YAML...")
-		// TODO: Add YAML parsing support
-		return &sdk.ExecuteStageResponse{
-			Status: sdk.StageStatusFailure,
+		return &StageResult{
+			Status: StageStatusFailure,
 		}, err
 	}
+	service, additional := services[0], services[1:]
 
 	// Extract service name from manifest or use configured name
 	serviceName := input.Request.TargetDeploymentSource.ApplicationConfig.Spec.Input.ServiceName
@@ -120,19 +112,175 @@ func (e *StageExecutor) ExecuteSyncStage(
 		serviceName = service.Template.Labels["app"]
 	}
 	if serviceName == "" {
-		return &sdk.ExecuteStageResponse{
-			Status: sdk.StageStatusFailure,
+		return &StageResult{
+			Status: StageStatusFailure,
 		}, fmt.Errorf("service name not specified in manifest or config")
 	}
 
+	// Deploy an ephemeral preview service instead of the application's service
+	baseServiceName := serviceName
+	if stageCfg.Preview != nil {
+		serviceName, err = previewServiceName(ctx, deployTargets, input, serviceName, stageCfg.Preview)
+		if err != nil {
+			lp.Errorf("Failed to determine the preview service name: %v", err)
+			return &StageResult{
+				Status: StageStatusFailure,
+			}, err
+		}
+		lp.Infof("Deploying preview service %s of %s", serviceName, baseServiceName)
+	}
+
+	// Validate the serving port, service mesh, container dependencies, and
+	// Cloud Run limits before deploying
+	if _, err := cloudrun.GetServingPort(service.Template); err != nil {
+		lp.Errorf("Invalid service manifest: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	if err := cloudrun.ValidateServiceMesh(service); err != nil {
+		lp.Errorf("Invalid service manifest: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	if err := cloudrun.ValidateContainerDependencies(service.Template); err != nil {
+		lp.Errorf("Invalid service manifest: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	if err := cloudrun.ValidateServiceLimits(service); err != nil {
+		lp.Errorf("Invalid service manifest %s: %v", strings.Join(manifestPaths, ", "), err)
+		return &StageResult{
+			Status:  StageStatusFailure,
+			Message: err.Error(),
+		}, err
+	}
+
+	// Reject services outside the deploy target's naming conventions
+	if err := checkServiceAllowed(dt, serviceName); err != nil {
+		lp.Errorf("Service is not accepted by the deploy target: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+
+	if err := checkNamingPolicy(dt, serviceName, service.Template.GetRevision()); err != nil {
+		lp.Errorf("Naming policy violation: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+
+	// Check the other services of the manifest before deploying any
+	if len(additional) > 0 && stageCfg.Preview != nil {
+		lp.Info("Warning: Only the first service of the manifest is deployed as a preview")
+		additional = nil
+	}
+	if err := validateAdditionalServices(dt, serviceName, additional); err != nil {
+		lp.Errorf("Invalid service manifest: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+
 	// Set full resource name
-	cloudrun.SetServiceName(&service, project, region, serviceName)
+	cloudrun.SetServiceName(service, project, region, serviceName)
+
+	// Merge deploy target labels, annotations, and description
+	collisions, err := applyDeployTargetDefaults(service, dt, serviceName, project, region)
+	if err != nil {
+		lp.Errorf("Failed to apply deploy target service defaults: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	for _, c := range collisions {
+		lp.Infof("Warning: %s", formatDefaultsCollision(c))
+	}
+
+	// Label the preview with its expiry, renewed on every deployment
+	if stageCfg.Preview != nil {
+		ttl := stageCfg.Preview.TTL
+		if ttl <= 0 {
+			ttl = DefaultPreviewConfig().TTL
+		}
+		expires := time.Now().Add(ttl.Duration())
+		cloudrun.MarkPreview(service, baseServiceName, expires)
+		lp.Infof("Preview expires at %s", expires.UTC().Format(time.RFC3339))
+	}
 
 	// Override image if specified in app config
 	image := input.Request.TargetDeploymentSource.ApplicationConfig.Spec.Input.Image
 	if image != "" {
 		lp.Infof("Overriding container image: %s", image)
-		cloudrun.ApplyImageOverride(&service, image)
+		cloudrun.ApplyImageOverride(service, image)
+	}
+
+	// Deploy the images verified on another deploy target
+	if stageCfg.PromoteFrom != "" {
+		images, err := promotedImages(ctx, input.Client, stageCfg.PromoteFrom)
+		if err == nil {
+			err = cloudrun.SetContainerImages(service, images)
+		}
+		if err != nil {
+			lp.Errorf("Failed to promote the images of deploy target %s: %v", stageCfg.PromoteFrom, err)
+			return &StageResult{
+				Status:  StageStatusFailure,
+				Message: err.Error(),
+			}, err
+		}
+		lp.Infof("Promoting images from deploy target %s: %s", stageCfg.PromoteFrom, strings.Join(images, ", "))
+	}
+
+	// Apply canary-only settings
+	if stageCfg.CanaryOverrides != nil {
+		overrides := cloudrun.RevisionOverrides{
+			Env:          stageCfg.CanaryOverrides.Env,
+			MinInstances: stageCfg.CanaryOverrides.MinInstances,
+			MaxInstances: stageCfg.CanaryOverrides.MaxInstances,
+		}
+		if !overrides.IsEmpty() {
+			lp.Info("Applying canary overrides to the new revision")
+			if err := cloudrun.ApplyRevisionOverrides(service, overrides); err != nil {
+				lp.Errorf("Failed to apply canary overrides: %v", err)
+				return &StageResult{
+					Status: StageStatusFailure,
+				}, err
+			}
+		}
+	}
+
+	// Annotate the revision with the change that produced it
+	if cfg.RevisionAnnotations != nil {
+		annotations := revisionAuditAnnotations(cfg.RevisionAnnotations, stageVariables(ctx, deployTargets, input))
+		if err := cloudrun.AnnotateRevision(service, annotations); err != nil {
+			lp.Errorf("Failed to annotate the revision: %v", err)
+			return &StageResult{
+				Status: StageStatusFailure,
+			}, err
+		}
+	}
+
+	// Fail early if an image from another project can't be pulled
+	if !dt.Config.SkipImagePullCheck {
+		if err := checkImagePullAccess(ctx, dt, project, service, lp); err != nil {
+			lp.Errorf("Image can't be pulled: %v", err)
+			return &StageResult{
+				Status: StageStatusFailure,
+			}, err
+		}
+	}
+
+	// Fail early if an image isn't built for the platform Cloud Run runs on
+	if !dt.Config.SkipImagePlatformCheck {
+		if err := checkImagePlatforms(ctx, dt, service, lp); err != nil {
+			lp.Errorf("Image can't run on Cloud Run: %v", err)
+			return &StageResult{
+				Status: StageStatusFailure,
+			}, err
+		}
 	}
 
 	lp.Infof("Deploying service: %s", service.Name)
@@ -143,20 +291,95 @@ func (e *StageExecutor) ExecuteSyncStage(
 		lp.Infof("Service does not exist, creating new service")
 	}
 
+	// Refuse sweeping changes the deploy target's change budget doesn't allow
+	if existingSvc != nil && stageCfg.Preview == nil && dt.Config.ChangeBudget != nil {
+		score := computeChangeScore(existingSvc, service)
+		override := changeBudgetOverride(ctx, input.Request.TargetDeploymentSource.ApplicationConfig.Spec, input.Request.TargetDeploymentSource.ApplicationDirectory)
+		if err := checkChangeBudget(dt, score, override); err != nil {
+			lp.Errorf("%v", err)
+			return &StageResult{
+				Status:  StageStatusFailure,
+				Message: err.Error(),
+			}, err
+		}
+		lp.Infof("Change score: %s, budget %d", score, dt.Config.ChangeBudget.MaxScore)
+		if score.Total() > dt.Config.ChangeBudget.MaxScore {
+			lp.Infof("Warning: Change score exceeds the budget, allowed by %s", override)
+		}
+	}
+
+	// Refuse idle cost increases that production deploy targets need
+	// confirmed
+	if stageCfg.Preview == nil {
+		increase, increased, err := computeIdleCostIncrease(existingSvc, service)
+		if err != nil {
+			lp.Errorf("Invalid service manifest: %v", err)
+			return &StageResult{
+				Status: StageStatusFailure,
+			}, err
+		}
+		if increased {
+			lp.Infof("Warning: Always-allocated capacity increases from %s to %s: estimated idle cost +$%.2f per month", increase.Live, increase.Desired, increase.Increase())
+			confirmation := idleCostConfirmation(ctx, input.Request.TargetDeploymentSource.ApplicationConfig.Spec, input.Request.TargetDeploymentSource.ApplicationDirectory)
+			if err := checkIdleCost(dt, increase, confirmation); err != nil {
+				lp.Errorf("%v", err)
+				return &StageResult{
+					Status:  StageStatusFailure,
+					Message: err.Error(),
+				}, err
+			}
+			if dt.Config.Production {
+				lp.Infof("Idle cost increase confirmed by %s", confirmation)
+			}
+		}
+	}
+
+	// Refuse shifting traffic too soon after the previous deployment
+	if existingSvc != nil && stageCfg.Preview == nil && !stageCfg.SkipTrafficShift {
+		if err := checkPromotionCooldown(ctx, dt, input, lp, time.Now()); err != nil {
+			lp.Errorf("%v", err)
+			return &StageResult{
+				Status:  StageStatusFailure,
+				Message: err.Error(),
+			}, err
+		}
+	}
+
+	// Record the revision serving traffic before this deployment
+	if existingSvc != nil && stageCfg.Preview == nil {
+		recordPreSyncState(ctx, input, existingSvc, lp)
+	}
+
 	// Preserve or set traffic configuration
 	if existingSvc != nil {
+		// Leave out targets pointing at manually deleted or failed revisions
+		liveTraffic := reconcileLiveTraffic(ctx, client, project, region, serviceName, existingSvc.Traffic, lp)
 		if stageCfg.SkipTrafficShift {
 			// Preserve existing traffic configuration
 			lp.Info("Preserving existing traffic configuration")
-			service.Traffic = existingSvc.Traffic
+			service.Traffic = liveTraffic
 		} else {
-			// Route 100% traffic to new revision (quick sync behavior)
+			// Route 100% traffic to new revision (quick sync behavior),
+			// keeping the tags without traffic
+			tagged, pruned, err := cloudrun.TagOnlyTargets(liveTraffic, stageCfg.PruneTags)
+			if err != nil {
+				lp.Errorf("Invalid pruneTags: %v", err)
+				return &StageResult{
+					Status: StageStatusFailure,
+				}, err
+			}
 			lp.Info("Routing 100% traffic to new revision")
-			service.Traffic = []*runpb.TrafficTarget{
+			service.Traffic = append([]*runpb.TrafficTarget{
 				{
 					Type:    runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST,
 					Percent: 100,
 				},
+			}, tagged...)
+			for _, t := range tagged {
+				lp.Infof("Keeping traffic tag %s", t.Tag)
+			}
+			if len(pruned) > 0 {
+				lp.Infof("Removing traffic tags: %s", strings.Join(pruned, ", "))
 			}
 		}
 	} else {
@@ -169,38 +392,319 @@ func (e *StageExecutor) ExecuteSyncStage(
 		}
 	}
 
-	// Deploy the service
-	result, err := client.CreateOrUpdateService(ctx, &service)
-	if err != nil {
-		lp.Errorf("Failed to deploy service: %v", err)
-		return &sdk.ExecuteStageResponse{
-			Status: sdk.StageStatusFailure,
+	// Leave the service mesh to the system managing it
+	if input.Request.TargetDeploymentSource.ApplicationConfig.Spec.IgnoreServiceMesh {
+		cloudrun.KeepServiceMesh(service, existingSvc)
+	} else if mesh := cloudrun.AppliedServiceMesh(existingSvc, service); mesh != cloudrun.ServiceMesh(existingSvc) {
+		lp.Infof("Service mesh: %s -> %s", formatMesh(cloudrun.ServiceMesh(existingSvc)), formatMesh(mesh))
+	}
+
+	// Record the applied manifest, and merge it with the live service so
+	// fields not managed by the manifest and server defaults are kept
+	if err := cloudrun.SetLastApplied(service); err != nil {
+		lp.Errorf("Failed to record the applied service: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
 		}, err
 	}
+	desired := service
+	if existingSvc != nil {
+		last, err := cloudrun.LastApplied(existingSvc)
+		if err != nil {
+			lp.Infof("Warning: Ignoring the last applied service: %v", err)
+		}
+		desired = cloudrun.ThreeWayMerge(last, existingSvc, service)
+	}
 
-	// Wait for service to be ready
-	lp.Info("Waiting for service to be ready...")
-	if err := client.WaitForServiceReady(ctx, project, region, serviceName); err != nil {
-		lp.Errorf("Service failed to become ready: %v", err)
-		return &sdk.ExecuteStageResponse{
-			Status: sdk.StageStatusFailure,
+	// Deploy the service, failing over to the secondary region if the
+	// primary region is unavailable
+	result, err := deployService(ctx, client, desired, project, region, serviceName, lp)
+	var failedOver bool
+	if secondary := failoverRegion(dt, region, err); secondary != "" && stageCfg.Preview == nil {
+		lp.Infof("Region %s is unavailable, failing over to %s", region, secondary)
+		desired, err = failoverService(ctx, client, service, project, secondary, serviceName, lp)
+		if err != nil {
+			lp.Errorf("Failed to prepare the failover service: %v", err)
+			return &StageResult{
+				Status: StageStatusFailure,
+			}, err
+		}
+		result, err = deployService(ctx, client, desired, project, secondary, serviceName, lp)
+		if err == nil {
+			lp.Successf("Failed over from region %s to %s", region, secondary)
+			recordFailover(ctx, input, secondary, lp)
+			region = secondary
+			failedOver = true
+		}
+	}
+	if err != nil {
+		return &StageResult{
+			Status: StageStatusFailure,
 		}, err
 	}
 
-	lp.Successf("Successfully deployed revision: %s", result.Template.Revision)
+	// Apply the declared invoker bindings
+	if invokers := input.Request.TargetDeploymentSource.ApplicationConfig.Spec.Invokers; invokers != nil {
+		if err := syncInvokerBindings(ctx, client, project, region, serviceName, invokers, lp); err != nil {
+			lp.Errorf("Failed to update invoker bindings: %v", err)
+			return &StageResult{
+				Status: StageStatusFailure,
+			}, err
+		}
+	}
+
+	revision := cloudrun.ShortRevisionName(result.LatestCreatedRevision)
+	lp.Successf("Successfully deployed revision: %s", revision)
+	if stageCfg.Preview == nil {
+		recordSyncedImages(ctx, input.Client, service, lp)
+	}
 	lp.Infof("Service URL: %s", result.Uri)
 
+	// Deploy the other services of the manifest to the region the first one
+	// was deployed to, recording the revisions serving them before for
+	// CLOUDRUN_ROLLBACK
+	var reports []serviceReport
+	var additionalErr error
+	if len(additional) > 0 {
+		recordAdditionalServices(ctx, input.Client, additionalStableRevisions(ctx, client, project, region, additional), lp)
+		reports, additionalErr = deployAdditionalServices(ctx, cfg, deployTargets, input, client, project, region, additional, lp)
+		reports = append([]serviceReport{{Service: serviceName, Revision: revision, URL: result.Uri, Ready: true}}, reports...)
+	}
+
+	stageResult := &StageResult{
+		Status:   StageStatusSuccess,
+		Revision: revision,
+		Traffic:  trafficMapFromTargets(result.Traffic),
+		Metadata: make(map[string]string),
+	}
+	if stageCfg.Preview != nil {
+		stageResult.Metadata[MetadataKeyPreviewService] = serviceName
+		stageResult.Metadata[MetadataKeyPreviewURL] = result.Uri
+	}
+	if failedOver {
+		stageResult.Metadata[MetadataKeyFailoverRegion] = region
+	}
+	if len(reports) > 0 {
+		stageResult.Metadata[MetadataKeyServices] = encodeServiceReports(reports)
+	}
+	if additionalErr != nil {
+		lp.Errorf("%d of %d services are not ready", countNotReady(reports), len(reports))
+		stageResult.Status = StageStatusFailure
+		stageResult.Message = additionalErr.Error()
+		return stageResult, additionalErr
+	}
+
+	// Wait for the revisions that served traffic before to release their
+	// capacity
+	if stageCfg.WaitForScaleDown != nil && existingSvc != nil && !stageCfg.SkipTrafficShift {
+		superseded := slices.DeleteFunc(servingRevisions(existingSvc), func(rev string) bool { return rev == revision })
+		switch {
+		case failedOver:
+			lp.Infof("Warning: Not waiting for the revisions of the unavailable region to scale down after failing over to %s", region)
+		case len(superseded) > 0:
+			if err := waitForScaleDown(ctx, dt, project, region, serviceName, superseded, stageCfg.WaitForScaleDown, lp); err != nil {
+				lp.Errorf("Superseded revisions did not scale to zero: %v", err)
+				stageResult.Status = StageStatusFailure
+				stageResult.Message = err.Error()
+				return stageResult, err
+			}
+		}
+	}
+
 	// Prune old revisions if requested
 	if stageCfg.Prune {
 		lp.Info("Pruning old revisions...")
-		rm := cloudrun.NewRevisionManager(client)
-		if err := rm.CleanupOldRevisions(ctx, project, region, serviceName, 5, true); err != nil {
+		rm := clients.revisions
+		opts := cloudrun.CleanupOptions{
+			KeepCount:  5,
+			KeepLatest: true,
+		}
+		// Never prune the revision this deployment would roll back to
+		if existingSvc != nil && existingSvc.LatestReadyRevision != "" {
+			opts.Protected = []string{cloudrun.ShortRevisionName(existingSvc.LatestReadyRevision)}
+		}
+		pruned, err := rm.CleanupRevisions(ctx, project, region, serviceName, opts)
+		if err != nil {
 			lp.Infof("Warning: Failed to prune old revisions: %v", err)
 			// Don't fail the stage for pruning errors
 		}
+		if pruned != nil {
+			stageResult.DeletedRevisions = pruned.Deleted
+		}
+	}
+
+	return stageResult, nil
+}
+
+// waitForScaleDown waits until the superseded revisions have no running
+// instances, as configured in the scale down wait.
+func waitForScaleDown(
+	ctx context.Context,
+	dt *sdk.DeployTarget[config.DeployTargetConfig],
+	project, region, serviceName string,
+	revisions []string,
+	waitCfg *ScaleDownWaitConfig,
+	lp sdk.StageLogPersister,
+) error {
+	// Fill unset fields with defaults
+	defaults := DefaultScaleDownWaitConfig()
+	timeout := waitCfg.Timeout.Duration()
+	if timeout <= 0 {
+		timeout = defaults.Timeout.Duration()
+	}
+	interval := waitCfg.Interval.Duration()
+	if interval <= 0 {
+		interval = defaults.Interval.Duration()
+	}
+
+	counter, err := cloudrun.NewInstanceCounter(ctx, dt.Config.CredentialsFile)
+	if err != nil {
+		return err
+	}
+
+	lp.Infof("Waiting for revisions %s to scale to zero (timeout %s)", strings.Join(revisions, ", "), timeout)
+	if _, err := cloudrun.WaitForScaleDown(ctx, counter, project, region, serviceName, revisions, cloudrun.ScaleDownGate{
+		Timeout:  timeout,
+		Interval: interval,
+	}); err != nil {
+		return err
+	}
+
+	lp.Successf("Revisions %s scaled to zero", strings.Join(revisions, ", "))
+	return nil
+}
+
+// recordPreSyncState stores the stable revision and traffic split before the
+// deployment, for CLOUDRUN_ROLLBACK. Only the first sync of a deployment
+// records them. Failing to store them does not fail the stage.
+func recordPreSyncState(
+	ctx context.Context,
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	svc *runpb.Service,
+	lp sdk.StageLogPersister,
+) {
+	recordTrafficHistory(ctx, input.Client, servingRevisions(svc), lp)
+
+	store := newMetadataStore(input.Client, targetNamespace(ctx, metadataNamespaceSync))
+
+	stable := cloudrun.ShortRevisionName(svc.LatestReadyRevision)
+	var recorded bool
+	err := store.Update(ctx, metadataKeyStableRevision, func(current string, ok bool) (string, error) {
+		if ok && current != "" {
+			return current, nil
+		}
+		recorded = true
+		return stable, nil
+	})
+	if err != nil {
+		lp.Infof("Warning: Failed to record stable revision: %v", err)
+		return
+	}
+	if !recorded {
+		return
+	}
+
+	if err := putMetadataJSON(ctx, store, metadataKeyTrafficSnapshot, trafficMapFromTargets(svc.Traffic)); err != nil {
+		lp.Infof("Warning: Failed to record traffic snapshot: %v", err)
+	}
+}
+
+// previewServiceName returns the name of the preview service of base. The ID
+// defaults to the pull request referenced by the commit, then the branch.
+func previewServiceName(
+	ctx context.Context,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	base string,
+	previewCfg *PreviewConfig,
+) (string, error) {
+	id := previewCfg.ID
+	if id == "" {
+		vars := stageVariables(ctx, deployTargets, input)
+		switch {
+		case vars.PRNumber != "":
+			id = "pr-" + vars.PRNumber
+		case vars.Branch != "":
+			id = vars.Branch
+		default:
+			return "", fmt.Errorf("preview.id is required when the commit references no pull request or branch")
+		}
+	}
+	return cloudrun.PreviewServiceName(base, id)
+}
+
+// checkImagePlatforms checks that the images of the service are built for
+// the deploy target's platform, since images built only for another platform
+// (e.g. arm64) deploy but crash at startup. Errors reading the image
+// manifests are logged as warnings.
+func checkImagePlatforms(
+	ctx context.Context,
+	dt *sdk.DeployTarget[config.DeployTargetConfig],
+	service *runpb.Service,
+	lp sdk.StageLogPersister,
+) error {
+	if service.Template == nil {
+		return nil
+	}
+	platform := dt.Config.ImagePlatform
+	if platform == "" {
+		platform = cloudrun.DefaultImagePlatform
+	}
+
+	for _, c := range service.Template.Containers {
+		ref, err := cloudrun.ParseImageReference(c.Image)
+		if err != nil {
+			lp.Infof("Warning: Failed to check the platforms of %s: %v", c.Image, err)
+			continue
+		}
+		httpClient, err := cloudrun.NewRegistryHTTPClient(ctx, dt.Config.CredentialsFile, ref)
+		if err != nil {
+			lp.Infof("Warning: Failed to check the platforms of %s: %v", c.Image, err)
+			continue
+		}
+		platforms, err := cloudrun.ImagePlatforms(ctx, httpClient, ref)
+		if err != nil {
+			lp.Infof("Warning: Failed to check the platforms of %s: %v", c.Image, err)
+			continue
+		}
+		if !cloudrun.HasPlatform(platforms, platform) {
+			return fmt.Errorf("image %s is built for %s, not %s; rebuild it with --platform=%s", c.Image, strings.Join(platforms, ", "), platform, platform)
+		}
+	}
+	return nil
+}
+
+// checkImagePullAccess checks that the images of the service stored in
+// Artifact Registry repositories of other projects can be pulled by the
+// deploy project. Errors checking the access are logged as warnings.
+func checkImagePullAccess(
+	ctx context.Context,
+	dt *sdk.DeployTarget[config.DeployTargetConfig],
+	project string,
+	service *runpb.Service,
+	lp sdk.StageLogPersister,
+) error {
+	if service.Template == nil {
+		return nil
 	}
+	for _, c := range service.Template.Containers {
+		img, ok := cloudrun.ParseArtifactRegistryImage(c.Image)
+		if !ok || img.Project == project {
+			continue
+		}
 
-	return &sdk.ExecuteStageResponse{
-		Status: sdk.StageStatusSuccess,
-	}, nil
+		lp.Infof("Checking that %s can be pulled from project %s", c.Image, img.Project)
+		access, err := cloudrun.CheckImagePullAccess(ctx, dt.Config.CredentialsFile, project, service.Template.ServiceAccount, img)
+		if err != nil {
+			lp.Infof("Warning: Failed to check image pull access: %v", err)
+			continue
+		}
+		if !access.Granted {
+			return fmt.Errorf(
+				"neither the Cloud Run service agent %s nor the runtime service account %s can read repository %s; grant access with: %s",
+				access.ServiceAgent, access.RuntimeServiceAccount, img.RepositoryName(), access.Remediation(img),
+			)
+		}
+	}
+	return nil
 }
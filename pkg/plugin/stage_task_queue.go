@@ -0,0 +1,149 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// ExecuteUpdateTaskQueueStage executes the CLOUDRUN_UPDATE_TASK_QUEUE stage.
+//
+// This stage points a Cloud Tasks queue's HTTP target at the service (or a
+// custom URL such as a domain) and updates the OIDC audience, so tasks are
+// delivered to the service that was just rolled out. It is typically placed
+// after the final CLOUDRUN_PROMOTE.
+func (e *StageExecutor) ExecuteUpdateTaskQueueStage(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	lp sdk.StageLogPersister,
+) (*StageResult, error) {
+	// Parse stage configuration
+	stageCfg := DefaultUpdateTaskQueueStageConfig()
+	if err := parseStageConfig(input.Request.StageConfig, stageCfg); err != nil {
+		lp.Errorf("Failed to parse stage config: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+	if stageCfg.Queue == "" {
+		lp.Errorf("No queue configured")
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, fmt.Errorf("queue is required")
+	}
+
+	// Get deploy target config
+	if len(deployTargets) == 0 {
+		lp.Errorf("No deploy targets configured")
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, fmt.Errorf("no deploy targets configured")
+	}
+	dt := deployTargets[0]
+
+	project, region := targetLocation(cfg, dt)
+	serviceName := applicationServiceName(input)
+
+	// Resolve the target URL and audience, defaulting to the service URL
+	targetURL := stageCfg.URL
+	audience := stageCfg.Audience
+	if targetURL == "" || audience == "" {
+		clients, err := e.targetClients(ctx, cfg, dt, input)
+		if err != nil {
+			lp.Errorf("Failed to create Cloud Run client: %v", err)
+			return &StageResult{
+				Status: StageStatusFailure,
+			}, err
+		}
+		client := clients.client
+
+		svc, err := client.GetService(ctx, project, region, serviceName)
+		if err != nil {
+			lp.Errorf("Failed to get service: %v", err)
+			return &StageResult{
+				Status: StageStatusFailure,
+			}, err
+		}
+		if svc.Uri == "" {
+			lp.Errorf("Service %s has no URL", serviceName)
+			return &StageResult{
+				Status: StageStatusFailure,
+			}, fmt.Errorf("service %s has no URL", serviceName)
+		}
+		if targetURL == "" {
+			targetURL = svc.Uri
+		}
+		if audience == "" {
+			audience = svc.Uri
+		}
+	}
+	if stageCfg.Path != "" {
+		targetURL = strings.TrimSuffix(targetURL, "/") + "/" + strings.TrimPrefix(stageCfg.Path, "/")
+	}
+
+	location := stageCfg.Location
+	if location == "" {
+		location = region
+	}
+	queue := cloudrun.QueueName(project, location, stageCfg.Queue)
+
+	lp.Infof("Updating Cloud Tasks queue %s", queue)
+	update, err := cloudrun.UpdateQueueTarget(ctx, dt.Config.CredentialsFile, cloudrun.QueueTarget{
+		Queue:               queue,
+		URL:                 targetURL,
+		Audience:            audience,
+		ServiceAccountEmail: stageCfg.ServiceAccountEmail,
+	})
+	if err != nil {
+		lp.Errorf("Failed to update queue target: %v", err)
+		return &StageResult{
+			Status: StageStatusFailure,
+		}, err
+	}
+
+	if !update.Changed() {
+		lp.Infof("Queue already targets %s (audience %s), nothing to update", update.URL, update.Audience)
+	} else {
+		lp.Infof("  URL: %s → %s", formatQueueValue(update.PreviousURL), update.URL)
+		lp.Infof("  OIDC audience: %s → %s", formatQueueValue(update.PreviousAudience), update.Audience)
+		lp.Successf("Updated Cloud Tasks queue %s", queue)
+	}
+
+	return &StageResult{
+		Status: StageStatusSuccess,
+		Metadata: map[string]string{
+			"queue":         queue,
+			"queueURL":      update.URL,
+			"queueAudience": update.Audience,
+		},
+	}, nil
+}
+
+// formatQueueValue formats a previous queue setting, which may be unset.
+func formatQueueValue(v string) string {
+	if v == "" {
+		return "(none)"
+	}
+	return v
+}
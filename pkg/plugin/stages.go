@@ -14,6 +14,13 @@
 
 package plugin
 
+import (
+	"time"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
 // Stage names for Cloud Run deployments.
 // These are the stages that the plugin can execute.
 const (
@@ -32,14 +39,74 @@ const (
 	// StageCloudRunCanaryCleanup removes canary revisions that have no traffic.
 	// This stage cleans up old revisions after a successful deployment.
 	StageCloudRunCanaryCleanup = "CLOUDRUN_CANARY_CLEANUP"
+
+	// StageCloudRunHold holds the current traffic split for a duration.
+	// This stage re-applies the recorded split if it is changed externally.
+	StageCloudRunHold = "CLOUDRUN_HOLD"
+
+	// StageCloudRunUpdateTaskQueue points a Cloud Tasks queue at the service.
+	// This stage keeps task delivery consistent after a new service or domain is rolled out.
+	StageCloudRunUpdateTaskQueue = "CLOUDRUN_UPDATE_TASK_QUEUE"
+
+	// StageCloudRunFaultInjection checks the service's error handling before promotion.
+	// This stage deploys the candidate with a fault flag, checks it, and restores it.
+	StageCloudRunFaultInjection = "CLOUDRUN_FAULT_INJECTION"
+
+	// StageCloudRunPreviewCleanup deletes expired preview services.
+	// This stage removes the ephemeral services deployed by CLOUDRUN_SYNC in preview mode.
+	StageCloudRunPreviewCleanup = "CLOUDRUN_PREVIEW_CLEANUP"
+
+	// StageCloudRunAnalysis checks the candidate revision's metrics.
+	// This stage evaluates Cloud Monitoring queries against thresholds for a duration.
+	StageCloudRunAnalysis = "CLOUDRUN_ANALYSIS"
+
+	// StageCloudRunJobSync deploys the job template of a CloudRunJob application.
+	// This stage creates or updates a Cloud Run job without running it.
+	StageCloudRunJobSync = "CLOUDRUN_JOB_SYNC"
+
+	// StageCloudRunJobRun runs the job and waits for the execution to finish.
+	// This stage fails if any task of the execution fails.
+	StageCloudRunJobRun = "CLOUDRUN_JOB_RUN"
+
+	// StageCloudRunJobRollback restores the job template from before CLOUDRUN_JOB_SYNC.
+	// This stage doesn't affect executions that already started.
+	StageCloudRunJobRollback = "CLOUDRUN_JOB_ROLLBACK"
+
+	// StageCloudRunMirrorVerify replays requests against the candidate and stable revisions.
+	// This stage compares their status codes and latency before promotion.
+	StageCloudRunMirrorVerify = "CLOUDRUN_MIRROR_VERIFY"
+
+	// StageCloudRunDomainVerify checks the DNS, TLS certificates, and HTTPS
+	// endpoints of the service's domains after a deployment.
+	StageCloudRunDomainVerify = "CLOUDRUN_DOMAIN_VERIFY"
+
+	// StageCloudRunBackup stores the service spec, IAM policy, and domain
+	// mappings to Cloud Storage, for disaster recovery.
+	StageCloudRunBackup = "CLOUDRUN_BACKUP"
+
+	// StageCloudRunRestore restores the service spec, IAM policy, and domain
+	// mappings from a backup made by CLOUDRUN_BACKUP.
+	StageCloudRunRestore = "CLOUDRUN_RESTORE"
 )
 
 // Stage descriptions for UI display.
 const (
-	StageDescriptionCloudRunSync          = "Deploy a new Cloud Run revision"
-	StageDescriptionCloudRunPromote       = "Promote the new revision by adjusting traffic split"
-	StageDescriptionCloudRunRollback      = "Rollback to the previous revision"
-	StageDescriptionCloudRunCanaryCleanup = "Clean up canary revisions"
+	StageDescriptionCloudRunSync            = "Deploy a new Cloud Run revision"
+	StageDescriptionCloudRunPromote         = "Promote the new revision by adjusting traffic split"
+	StageDescriptionCloudRunRollback        = "Rollback to the previous revision"
+	StageDescriptionCloudRunCanaryCleanup   = "Clean up canary revisions"
+	StageDescriptionCloudRunHold            = "Hold the current traffic split"
+	StageDescriptionCloudRunUpdateTaskQueue = "Update the Cloud Tasks queue target"
+	StageDescriptionCloudRunFaultInjection  = "Verify error handling with an injected fault"
+	StageDescriptionCloudRunPreviewCleanup  = "Delete expired preview services"
+	StageDescriptionCloudRunAnalysis        = "Analyze the candidate revision's metrics"
+	StageDescriptionCloudRunJobSync         = "Deploy the Cloud Run job template"
+	StageDescriptionCloudRunJobRun          = "Run the Cloud Run job"
+	StageDescriptionCloudRunJobRollback     = "Restore the previous job template"
+	StageDescriptionCloudRunMirrorVerify    = "Compare the candidate's responses with the stable revision"
+	StageDescriptionCloudRunDomainVerify    = "Verify the DNS and TLS of the service's domains"
+	StageDescriptionCloudRunBackup          = "Back up the service configuration to Cloud Storage"
+	StageDescriptionCloudRunRestore         = "Restore the service configuration from a backup"
 )
 
 // SyncStageConfig defines configuration for CLOUDRUN_SYNC stage.
@@ -51,6 +118,68 @@ type SyncStageConfig struct {
 
 	// Prune indicates whether to remove unused revisions after deployment.
 	Prune bool `json:"prune,omitempty"`
+
+	// PruneTags lists the traffic tags, as glob patterns (e.g. "pr-*"),
+	// removed when traffic is routed to the new revision. Other tags without
+	// traffic, such as preview URLs, are kept.
+	PruneTags []string `json:"pruneTags,omitempty"`
+
+	// WaitForScaleDown waits, after traffic is routed to the new revision,
+	// until the revisions that served traffic before have no instances, so
+	// later stages don't run with both revisions holding capacity.
+	WaitForScaleDown *ScaleDownWaitConfig `json:"waitForScaleDown,omitempty"`
+
+	// CanaryOverrides defines settings applied only to the new (canary) revision.
+	// They are reverted to the manifest settings by CLOUDRUN_PROMOTE at 100%.
+	CanaryOverrides *CanaryOverridesConfig `json:"canaryOverrides,omitempty"`
+
+	// Preview deploys an ephemeral preview service instead of the application's
+	// service. Expired previews are deleted by CLOUDRUN_PREVIEW_CLEANUP.
+	Preview *PreviewConfig `json:"preview,omitempty"`
+
+	// PromoteFrom deploys the container images CLOUDRUN_SYNC deployed to
+	// another deploy target earlier in the deployment, e.g. "staging", so the
+	// stage's deploy target runs exactly what was verified there. The
+	// manifest is still rendered for the stage's deploy target.
+	PromoteFrom string `json:"promoteFrom,omitempty"`
+}
+
+// PreviewConfig defines the ephemeral preview service deployed by CLOUDRUN_SYNC.
+// The service name is the application's service name followed by the ID.
+// Example:
+//
+//	preview:
+//	  id: "pr-{{ .PRNumber }}"
+//	  ttl: 72h
+type PreviewConfig struct {
+	// ID identifies the preview, e.g. the pull request or branch.
+	// Default: "pr-<PR number>" if the commit references a pull request,
+	// otherwise the branch name.
+	ID string `json:"id,omitempty"`
+
+	// TTL is how long the preview service is kept after its last deployment.
+	// Default: 72h
+	TTL config.Duration `json:"ttl,omitempty"`
+}
+
+// CanaryOverridesConfig defines settings that differ between the canary revision
+// and the production configuration.
+//
+// Example:
+//
+//	canaryOverrides:
+//	  maxInstances: 2
+//	  env:
+//	    LOG_LEVEL: debug
+type CanaryOverridesConfig struct {
+	// Env sets environment variables on the main container.
+	Env map[string]string `json:"env,omitempty"`
+
+	// MinInstances overrides the minimum number of instances.
+	MinInstances *int32 `json:"minInstances,omitempty"`
+
+	// MaxInstances overrides the maximum number of instances.
+	MaxInstances *int32 `json:"maxInstances,omitempty"`
 }
 
 // PromoteStageConfig defines configuration for CLOUDRUN_PROMOTE stage.
@@ -58,14 +187,228 @@ type PromoteStageConfig struct {
 	// Percent is the percentage of traffic to route to the new revision (0-100).
 	// Example: 10 means 10% to new revision, 90% to previous revision.
 	// Example: 100 means 100% to new revision (full promotion).
+	// If omitted and the application defines canary steps, the next step is used.
 	Percent int `json:"percent"`
+
+	// CandidateTag is the traffic tag attached to the new revision.
+	// Example: "candidate" gives https://candidate---my-service-xxx.a.run.app
+	CandidateTag string `json:"candidateTag,omitempty"`
+
+	// StableTag is the traffic tag attached to the previous revision.
+	// The tag is kept (with 0% traffic) after full promotion.
+	StableTag string `json:"stableTag,omitempty"`
+
+	// TargetTag routes the percent to the revision the traffic tag points
+	// at, instead of the latest revision, and the rest to the revision
+	// serving the most traffic besides it. The tag is resolved when the
+	// stage runs and stays on the revision, so the tag is the single source
+	// of truth of which revision is the candidate.
+	// Example: "canary"
+	TargetTag string `json:"targetTag,omitempty"`
+
+	// TagReadiness polls the candidate tag URL until it responds before the
+	// URL is written to the stage metadata.
+	TagReadiness *TagReadinessConfig `json:"tagReadiness,omitempty"`
+
+	// ReadyInstances requires the candidate revision to have a minimum number
+	// of ready instances before traffic is shifted to it.
+	ReadyInstances *ReadyInstancesGateConfig `json:"readyInstances,omitempty"`
+
+	// SuccessfulRequests requires the candidate revision to serve a minimum
+	// number of successful (2xx) requests after traffic is shifted to it.
+	SuccessfulRequests *SuccessfulRequestsGateConfig `json:"successfulRequests,omitempty"`
+
+	// Pause keeps the traffic at the promoted percent until an operator
+	// resumes the progression by approving the stage in the PipeCD UI.
+	Pause *PauseConfig `json:"pause,omitempty"`
+
+	// ColdStartReport reports the cold start latency of the candidate
+	// revision after the promotion, compared with the revision serving
+	// before it.
+	ColdStartReport *ColdStartReportConfig `json:"coldStartReport,omitempty"`
+}
+
+// ColdStartReportConfig defines the cold start report of CLOUDRUN_PROMOTE.
+// Startup latencies are read from Cloud Monitoring, which needs
+// roles/monitoring.viewer and lags behind by a few minutes.
+//
+// Example:
+//
+//	coldStartReport:
+//	  delay: 3m
+type ColdStartReportConfig struct {
+	// Delay is how long to wait after the promotion before reading the
+	// startup latencies, so the instances started for the shifted traffic
+	// are included.
+	// Default: 0s
+	Delay config.Duration `json:"delay,omitempty"`
+
+	// Window is how far back instance starts are read.
+	// Default: since the candidate revision was created
+	Window config.Duration `json:"window,omitempty"`
+}
+
+// TagReadinessConfig defines how the candidate tag URL is checked.
+//
+// Example:
+//
+//	tagReadiness:
+//	  path: /healthz
+//	  timeout: 2m
+type TagReadinessConfig struct {
+	// Path is the path requested on the tag URL.
+	// Default: "/"
+	Path string `json:"path,omitempty"`
+
+	// ExpectedStatus is the expected HTTP status code.
+	// If zero, any 2xx status is accepted.
+	ExpectedStatus int `json:"expectedStatus,omitempty"`
+
+	// Timeout is how long to keep polling the URL.
+	// Default: 2m
+	Timeout config.Duration `json:"timeout,omitempty"`
+
+	// Interval is the delay between attempts.
+	// Default: 5s
+	Interval config.Duration `json:"interval,omitempty"`
+
+	// Authenticated sends an ID token with the request.
+	Authenticated bool `json:"authenticated,omitempty"`
+}
+
+// ScaleDownWaitConfig defines how CLOUDRUN_SYNC waits for the superseded
+// revisions to scale to zero. Instance counts are read from Cloud Monitoring,
+// which needs roles/monitoring.viewer and lags behind by a few minutes.
+//
+// Example:
+//
+//	waitForScaleDown:
+//	  timeout: 20m
+type ScaleDownWaitConfig struct {
+	// Timeout is how long to wait for the revisions to scale to zero before
+	// failing.
+	// Default: 15m
+	Timeout config.Duration `json:"timeout,omitempty"`
+
+	// Interval is the delay between checks.
+	// Default: 30s
+	Interval config.Duration `json:"interval,omitempty"`
+}
+
+// ReadyInstancesGateConfig defines the ready instance precondition of CLOUDRUN_PROMOTE.
+// Instance counts are read from Cloud Monitoring, which needs roles/monitoring.viewer.
+//
+// Example:
+//
+//	readyInstances:
+//	  min: 3
+//	  timeout: 5m
+type ReadyInstancesGateConfig struct {
+	// Min is the minimum number of ready instances.
+	Min int64 `json:"min"`
+
+	// Timeout is how long to wait for the instances before failing.
+	// Default: 5m
+	Timeout config.Duration `json:"timeout,omitempty"`
+
+	// Interval is the delay between checks.
+	// Default: 15s
+	Interval config.Duration `json:"interval,omitempty"`
 }
 
+// SuccessfulRequestsGateConfig defines the successful requests check of
+// CLOUDRUN_PROMOTE. It catches revisions that become ready but fail every
+// request. Request counts are read from Cloud Monitoring, which needs
+// roles/monitoring.viewer and lags behind by a few minutes.
+//
+// Example:
+//
+//	successfulRequests:
+//	  min: 10
+//	  timeout: 10m
+type SuccessfulRequestsGateConfig struct {
+	// Min is the minimum number of 2xx responses served by the revision.
+	// Default: 1
+	Min int64 `json:"min,omitempty"`
+
+	// Timeout is how long to wait for the requests before failing.
+	// Default: 10m
+	Timeout config.Duration `json:"timeout,omitempty"`
+
+	// Interval is the delay between checks.
+	// Default: 30s
+	Interval config.Duration `json:"interval,omitempty"`
+}
+
+// PauseConfig defines the pause of CLOUDRUN_PROMOTE after shifting traffic.
+// Approving the stage resumes the progression. Cancelling the deployment
+// with rollback aborts it.
+//
+// Example:
+//
+//	pause:
+//	  timeout: 2h
+//	  onTimeout: abort
+type PauseConfig struct {
+	// Timeout is how long to wait for an operator.
+	// Default: 1h
+	Timeout config.Duration `json:"timeout,omitempty"`
+
+	// OnTimeout is what happens when nobody acts before the timeout:
+	// "abort" fails the stage, so the deployment is rolled back, and
+	// "resume" continues the progression.
+	// Default: "abort"
+	OnTimeout string `json:"onTimeout,omitempty"`
+}
+
+// Actions taken when a pause times out.
+const (
+	PauseOnTimeoutAbort  = "abort"
+	PauseOnTimeoutResume = "resume"
+)
+
 // RollbackStageConfig defines configuration for CLOUDRUN_ROLLBACK stage.
 type RollbackStageConfig struct {
 	// Revision is the revision name to rollback to.
 	// If empty, rolls back to the previous revision.
 	Revision string `json:"revision,omitempty"`
+
+	// Tag is the traffic tag attached to the rollback target.
+	// Example: "stable"
+	Tag string `json:"tag,omitempty"`
+
+	// Verify defines checks run after the rollback to confirm the restored
+	// revision is healthy. The stage fails if they don't pass.
+	Verify *RollbackVerifyConfig `json:"verify,omitempty"`
+}
+
+// RollbackVerifyConfig defines post-rollback verification.
+//
+// Example:
+//
+//	verify:
+//	  path: /healthz
+//	  timeout: 2m
+type RollbackVerifyConfig struct {
+	// Path is the path requested on the service URL.
+	// Default: "/"
+	Path string `json:"path,omitempty"`
+
+	// ExpectedStatus is the expected HTTP status code.
+	// If zero, any 2xx status is accepted.
+	ExpectedStatus int `json:"expectedStatus,omitempty"`
+
+	// Timeout is how long to keep retrying the check.
+	// Default: 1m
+	Timeout config.Duration `json:"timeout,omitempty"`
+
+	// Interval is the delay between attempts.
+	// Default: 5s
+	Interval config.Duration `json:"interval,omitempty"`
+
+	// Authenticated sends an ID token with the request, for services that
+	// don't allow unauthenticated invocations.
+	Authenticated bool `json:"authenticated,omitempty"`
 }
 
 // CanaryCleanupStageConfig defines configuration for CLOUDRUN_CANARY_CLEANUP stage.
@@ -77,6 +420,332 @@ type CanaryCleanupStageConfig struct {
 	// KeepLatest indicates whether to always keep the latest revision.
 	// Default: true
 	KeepLatest bool `json:"keepLatest,omitempty"`
+
+	// DrainWait is how long to wait before deleting revisions with 0% traffic
+	// so in-flight requests can complete.
+	// Default: 30s
+	DrainWait config.Duration `json:"drainWait,omitempty"`
+
+	// WaitForZeroInstances indicates whether to check (via Cloud Monitoring)
+	// that a revision has no running instances before deleting it.
+	// Revisions still running instances after DrainTimeout are kept.
+	WaitForZeroInstances bool `json:"waitForZeroInstances,omitempty"`
+
+	// DrainTimeout is how long to wait for a revision's instances to reach zero.
+	// Default: 5m
+	DrainTimeout config.Duration `json:"drainTimeout,omitempty"`
+
+	// KeepTagged is the number of most recent tagged revisions (e.g. per-release
+	// preview URLs) to keep regardless of keepCount and traffic.
+	// Tags of deleted revisions are always removed first.
+	KeepTagged int `json:"keepTagged,omitempty"`
+
+	// RollbackRetention is how long the revision serving traffic before this
+	// deployment (the CLOUDRUN_ROLLBACK target) is kept after the new revision
+	// was created, even once its traffic reaches 0%.
+	// Default: 24h
+	RollbackRetention config.Duration `json:"rollbackRetention,omitempty"`
+}
+
+// HoldStageConfig defines configuration for CLOUDRUN_HOLD stage.
+type HoldStageConfig struct {
+	// Duration is how long to hold the current traffic split.
+	// Example: "10m"
+	Duration config.Duration `json:"duration"`
+
+	// CheckInterval is how often the traffic split is checked for changes.
+	// Default: 30s
+	CheckInterval config.Duration `json:"checkInterval,omitempty"`
+}
+
+// UpdateTaskQueueStageConfig defines configuration for CLOUDRUN_UPDATE_TASK_QUEUE stage.
+//
+// Example:
+//
+//	queue: my-queue
+//	path: /tasks/handle
+//	serviceAccountEmail: tasks-invoker@my-project.iam.gserviceaccount.com
+type UpdateTaskQueueStageConfig struct {
+	// Queue is the queue ID or full queue name.
+	// Example: "my-queue" or "projects/my-project/locations/us-central1/queues/my-queue"
+	Queue string `json:"queue"`
+
+	// Location is the queue location.
+	// Default: the region of the service
+	Location string `json:"location,omitempty"`
+
+	// URL is the base URL tasks are delivered to, e.g. a custom domain.
+	// Default: the service URL
+	URL string `json:"url,omitempty"`
+
+	// Path is appended to the URL.
+	// Example: "/tasks/handle"
+	Path string `json:"path,omitempty"`
+
+	// Audience is the OIDC token audience.
+	// Default: the service URL
+	Audience string `json:"audience,omitempty"`
+
+	// ServiceAccountEmail is the service account used to generate the OIDC token.
+	// If empty, the queue's current service account is kept.
+	ServiceAccountEmail string `json:"serviceAccountEmail,omitempty"`
+}
+
+// FaultInjectionStageConfig defines configuration for CLOUDRUN_FAULT_INJECTION stage.
+//
+// Example:
+//
+//	env:
+//	  FAULT_DB_UNAVAILABLE: "true"
+//	check:
+//	  path: /orders
+//	  expectedStatus: 503
+type FaultInjectionStageConfig struct {
+	// Env sets fault flag environment variables on a copy of the candidate
+	// revision. If empty, the candidate revision is checked as is, e.g. with
+	// header-based fault flags.
+	Env map[string]string `json:"env,omitempty"`
+
+	// Tag is the traffic tag of the checked revision.
+	// Default: "fault"
+	Tag string `json:"tag,omitempty"`
+
+	// Check defines the request sent to the revision and the expected response.
+	Check FaultCheckConfig `json:"check"`
+}
+
+// FaultCheckConfig defines the check run against the revision with the fault.
+type FaultCheckConfig struct {
+	// Path is the path requested on the tag URL.
+	// Default: "/"
+	Path string `json:"path,omitempty"`
+
+	// Headers are added to the request, e.g. a header-based fault flag.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// ExpectedStatus is the expected HTTP status code.
+	// If zero, any 2xx status is accepted.
+	ExpectedStatus int `json:"expectedStatus,omitempty"`
+
+	// Timeout is how long to keep retrying the check.
+	// Default: 1m
+	Timeout config.Duration `json:"timeout,omitempty"`
+
+	// Interval is the delay between attempts.
+	// Default: 5s
+	Interval config.Duration `json:"interval,omitempty"`
+
+	// Authenticated sends an ID token with the request.
+	Authenticated bool `json:"authenticated,omitempty"`
+}
+
+// PreviewCleanupStageConfig defines configuration for CLOUDRUN_PREVIEW_CLEANUP stage.
+type PreviewCleanupStageConfig struct {
+	// All deletes the expired previews of every service in the region, not only
+	// the previews of the application's service.
+	All bool `json:"all,omitempty"`
+}
+
+// AnalysisStageConfig defines configuration for CLOUDRUN_ANALYSIS stage.
+// Metrics are read from Cloud Monitoring, which needs roles/monitoring.viewer.
+//
+// Example:
+//
+//	duration: 10m
+//	queries:
+//	  - name: p95 latency
+//	    metric: run.googleapis.com/request_latencies
+//	    aggregation: p95
+//	    alignmentPeriod: 5m
+//	    operator: "<"
+//	    threshold: 500
+//	  - name: 5xx responses
+//	    metric: run.googleapis.com/request_count
+//	    filter: metric.labels.response_code_class="5xx"
+//	    aggregation: sum
+//	    operator: "<="
+//	    threshold: 10
+//	minSampleCount: 500
+//	insufficientTraffic: extend
+type AnalysisStageConfig struct {
+	// Duration is how long the analysis runs.
+	// Example: "10m"
+	Duration config.Duration `json:"duration"`
+
+	// Interval is the delay between checks of the queries.
+	// Default: 1m
+	Interval config.Duration `json:"interval,omitempty"`
+
+	// FailureLimit is the number of failed checks tolerated before the
+	// analysis fails. A check fails if any query doesn't meet its condition.
+	// Default: 0
+	FailureLimit int `json:"failureLimit,omitempty"`
+
+	// Queries are the metrics checked, each with its own condition.
+	Queries []AnalysisQueryConfig `json:"queries"`
+
+	// MinSampleCount is the number of requests the candidate revision must
+	// serve during the analysis for its verdict to count, since metrics of
+	// near-zero traffic are meaningless. 0 disables the requirement.
+	MinSampleCount int64 `json:"minSampleCount,omitempty"`
+
+	// InsufficientTraffic is what happens when fewer than MinSampleCount
+	// requests were served: "pass" passes the analysis, "fail" fails it, and
+	// "extend" keeps analyzing until enough requests were served, for up to
+	// MaxExtension, then fails.
+	// Default: "fail"
+	InsufficientTraffic string `json:"insufficientTraffic,omitempty"`
+
+	// MaxExtension is how long "extend" keeps analyzing after Duration.
+	// Default: the duration
+	MaxExtension config.Duration `json:"maxExtension,omitempty"`
+}
+
+// Results of an analysis that observed too little traffic.
+const (
+	InsufficientTrafficPass   = "pass"
+	InsufficientTrafficFail   = "fail"
+	InsufficientTrafficExtend = "extend"
+)
+
+// AnalysisQueryConfig defines a metric of the candidate revision and the
+// condition its value must meet.
+type AnalysisQueryConfig struct {
+	// Name identifies the query in logs.
+	// Default: the metric
+	Name string `json:"name,omitempty"`
+
+	// Metric is the Cloud Monitoring metric type.
+	// Example: "run.googleapis.com/request_latencies"
+	Metric string `json:"metric"`
+
+	// Filter is an additional Cloud Monitoring filter.
+	// Example: metric.labels.response_code_class="5xx"
+	Filter string `json:"filter,omitempty"`
+
+	// Aggregation is how samples are combined: mean, max, min, sum, or
+	// p50, p95, p99 for distribution metrics.
+	// Default: "mean"
+	Aggregation string `json:"aggregation,omitempty"`
+
+	// AlignmentPeriod is the window the value is computed over, ending at
+	// each check. Cloud Monitoring requires at least 1m.
+	// Default: 1m
+	AlignmentPeriod config.Duration `json:"alignmentPeriod,omitempty"`
+
+	// Operator compares the value with the threshold: <, <=, >, >=, ==, !=.
+	Operator string `json:"operator"`
+
+	// Threshold is the value compared with.
+	Threshold float64 `json:"threshold"`
+}
+
+// JobRunStageConfig defines configuration for CLOUDRUN_JOB_RUN stage.
+type JobRunStageConfig struct {
+	// Timeout is how long to wait for the execution to finish.
+	// The execution keeps running if the stage times out.
+	// Default: 1h
+	Timeout config.Duration `json:"timeout,omitempty"`
+}
+
+// MirrorVerifyStageConfig defines configuration for CLOUDRUN_MIRROR_VERIFY stage.
+//
+// Example:
+//
+//	capture:
+//	  uri: gs://my-bucket/captures/orders.jsonl
+//	  sampleSize: 200
+//	requests:
+//	  - path: /healthz
+//	maxLatencyIncrease: 300ms
+//	maxMismatches: 2
+type MirrorVerifyStageConfig struct {
+	// CandidateTag and StableTag are the traffic tags the requests are sent
+	// to. Missing tags are added to the candidate (latest created) and stable
+	// revisions with 0% traffic for the stage, and removed afterwards.
+	// Default: "candidate" and "stable"
+	CandidateTag string `json:"candidateTag,omitempty"`
+	StableTag    string `json:"stableTag,omitempty"`
+
+	// Capture is a sample of recorded production requests to replay.
+	Capture *MirrorCaptureConfig `json:"capture,omitempty"`
+
+	// Requests are synthetic requests replayed in addition to the capture.
+	Requests []cloudrun.MirrorRequest `json:"requests,omitempty"`
+
+	// AllowUnsafeMethods replays requests with methods other than GET, HEAD,
+	// and OPTIONS. They are sent to both revisions, so they must be
+	// idempotent or target test data.
+	AllowUnsafeMethods bool `json:"allowUnsafeMethods,omitempty"`
+
+	// MaxLatencyIncrease is how much slower than the stable revision the
+	// candidate may answer a request. Zero disables the latency comparison.
+	// Default: 500ms
+	MaxLatencyIncrease config.Duration `json:"maxLatencyIncrease,omitempty"`
+
+	// MaxMismatches is the number of mismatched responses tolerated.
+	MaxMismatches int `json:"maxMismatches,omitempty"`
+
+	// Concurrency is the number of requests replayed at the same time.
+	// Default: 4
+	Concurrency int `json:"concurrency,omitempty"`
+
+	// RequestTimeout is the timeout of each request.
+	// Default: 30s
+	RequestTimeout config.Duration `json:"requestTimeout,omitempty"`
+
+	// Authenticated sends an ID token with the requests.
+	Authenticated bool `json:"authenticated,omitempty"`
+}
+
+// MirrorCaptureConfig defines the recorded requests replayed by
+// CLOUDRUN_MIRROR_VERIFY.
+type MirrorCaptureConfig struct {
+	// URI is the Cloud Storage object holding the capture, one JSON request
+	// ({"method", "path", "headers", "body"}) per line.
+	// Example: "gs://my-bucket/captures/orders.jsonl"
+	URI string `json:"uri"`
+
+	// SampleSize is the number of requests picked at random from the capture.
+	// Default: 100
+	SampleSize int `json:"sampleSize,omitempty"`
+}
+
+// DomainVerifyStageConfig defines configuration for CLOUDRUN_DOMAIN_VERIFY stage.
+//
+// Example:
+//
+//	domains: [api.example.com]
+//	path: /healthz
+//	minCertificateValidity: 336h
+type DomainVerifyStageConfig struct {
+	// Domains are the domains to verify. If empty, the domains mapped to the
+	// service by domain mappings are verified.
+	Domains []string `json:"domains,omitempty"`
+
+	// Path is the path requested over HTTPS.
+	// Default: "/"
+	Path string `json:"path,omitempty"`
+
+	// MinCertificateValidity is how long the certificate of each domain must
+	// remain valid.
+	// Default: 168h (7 days)
+	MinCertificateValidity config.Duration `json:"minCertificateValidity,omitempty"`
+
+	// WaitTimeout is how long failing domains are checked again, e.g. while
+	// the managed certificate of a new domain mapping is provisioned. Zero
+	// checks once.
+	// Default: 15m
+	WaitTimeout config.Duration `json:"waitTimeout,omitempty"`
+
+	// Interval is the delay between checks.
+	// Default: 30s
+	Interval config.Duration `json:"interval,omitempty"`
+
+	// RequestTimeout bounds the DNS lookup, TLS handshake, and HTTPS request
+	// of each domain.
+	// Default: 10s
+	RequestTimeout config.Duration `json:"requestTimeout,omitempty"`
 }
 
 // DefaultSyncStageConfig returns default sync stage configuration.
@@ -94,6 +763,48 @@ func DefaultPromoteStageConfig() *PromoteStageConfig {
 	}
 }
 
+// DefaultTagReadinessConfig returns default tag readiness configuration.
+func DefaultTagReadinessConfig() *TagReadinessConfig {
+	return &TagReadinessConfig{
+		Path:     "/",
+		Timeout:  config.Duration(2 * time.Minute),
+		Interval: config.Duration(5 * time.Second),
+	}
+}
+
+// DefaultReadyInstancesGateConfig returns default ready instance gate configuration.
+func DefaultReadyInstancesGateConfig() *ReadyInstancesGateConfig {
+	return &ReadyInstancesGateConfig{
+		Timeout:  config.Duration(5 * time.Minute),
+		Interval: config.Duration(15 * time.Second),
+	}
+}
+
+// DefaultScaleDownWaitConfig returns default scale down wait configuration.
+func DefaultScaleDownWaitConfig() *ScaleDownWaitConfig {
+	return &ScaleDownWaitConfig{
+		Timeout:  config.Duration(15 * time.Minute),
+		Interval: config.Duration(30 * time.Second),
+	}
+}
+
+// DefaultSuccessfulRequestsGateConfig returns default successful requests gate configuration.
+func DefaultSuccessfulRequestsGateConfig() *SuccessfulRequestsGateConfig {
+	return &SuccessfulRequestsGateConfig{
+		Min:      1,
+		Timeout:  config.Duration(10 * time.Minute),
+		Interval: config.Duration(30 * time.Second),
+	}
+}
+
+// DefaultPauseConfig returns default promote pause configuration.
+func DefaultPauseConfig() *PauseConfig {
+	return &PauseConfig{
+		Timeout:   config.Duration(time.Hour),
+		OnTimeout: PauseOnTimeoutAbort,
+	}
+}
+
 // DefaultRollbackStageConfig returns default rollback stage configuration.
 func DefaultRollbackStageConfig() *RollbackStageConfig {
 	return &RollbackStageConfig{
@@ -101,11 +812,169 @@ func DefaultRollbackStageConfig() *RollbackStageConfig {
 	}
 }
 
+// DefaultRollbackVerifyConfig returns default post-rollback verification configuration.
+func DefaultRollbackVerifyConfig() *RollbackVerifyConfig {
+	return &RollbackVerifyConfig{
+		Path:     "/",
+		Timeout:  config.Duration(time.Minute),
+		Interval: config.Duration(5 * time.Second),
+	}
+}
+
 // DefaultCanaryCleanupStageConfig returns default canary cleanup stage configuration.
 func DefaultCanaryCleanupStageConfig() *CanaryCleanupStageConfig {
 	return &CanaryCleanupStageConfig{
-		KeepCount:  5,
-		KeepLatest: true,
+		KeepCount:         5,
+		KeepLatest:        true,
+		DrainWait:         config.Duration(30 * time.Second),
+		DrainTimeout:      config.Duration(5 * time.Minute),
+		RollbackRetention: config.Duration(24 * time.Hour),
+	}
+}
+
+// DefaultHoldStageConfig returns default hold stage configuration.
+func DefaultHoldStageConfig() *HoldStageConfig {
+	return &HoldStageConfig{
+		Duration:      config.Duration(5 * time.Minute),
+		CheckInterval: config.Duration(30 * time.Second),
+	}
+}
+
+// DefaultUpdateTaskQueueStageConfig returns default update task queue stage configuration.
+func DefaultUpdateTaskQueueStageConfig() *UpdateTaskQueueStageConfig {
+	return &UpdateTaskQueueStageConfig{}
+}
+
+// DefaultFaultInjectionStageConfig returns default fault injection stage configuration.
+func DefaultFaultInjectionStageConfig() *FaultInjectionStageConfig {
+	return &FaultInjectionStageConfig{
+		Tag: "fault",
+		Check: FaultCheckConfig{
+			Path:     "/",
+			Timeout:  config.Duration(time.Minute),
+			Interval: config.Duration(5 * time.Second),
+		},
+	}
+}
+
+// DefaultPreviewConfig returns default preview configuration.
+func DefaultPreviewConfig() *PreviewConfig {
+	return &PreviewConfig{
+		TTL: config.Duration(72 * time.Hour),
+	}
+}
+
+// DefaultPreviewCleanupStageConfig returns default preview cleanup stage configuration.
+func DefaultPreviewCleanupStageConfig() *PreviewCleanupStageConfig {
+	return &PreviewCleanupStageConfig{}
+}
+
+// DefaultAnalysisStageConfig returns default analysis stage configuration.
+func DefaultAnalysisStageConfig() *AnalysisStageConfig {
+	return &AnalysisStageConfig{
+		Interval:            config.Duration(time.Minute),
+		InsufficientTraffic: InsufficientTrafficFail,
+	}
+}
+
+// DefaultAnalysisQueryConfig returns default analysis query configuration.
+func DefaultAnalysisQueryConfig() *AnalysisQueryConfig {
+	return &AnalysisQueryConfig{
+		Aggregation:     string(cloudrun.AggregationMean),
+		AlignmentPeriod: config.Duration(time.Minute),
+	}
+}
+
+// DefaultJobRunStageConfig returns default job run stage configuration.
+func DefaultJobRunStageConfig() *JobRunStageConfig {
+	return &JobRunStageConfig{
+		Timeout: config.Duration(time.Hour),
+	}
+}
+
+// DefaultMirrorVerifyStageConfig returns default mirror verify stage configuration.
+func DefaultMirrorVerifyStageConfig() *MirrorVerifyStageConfig {
+	return &MirrorVerifyStageConfig{
+		CandidateTag:       "candidate",
+		StableTag:          "stable",
+		MaxLatencyIncrease: config.Duration(500 * time.Millisecond),
+		Concurrency:        4,
+		RequestTimeout:     config.Duration(30 * time.Second),
+	}
+}
+
+// BackupStageConfig defines configuration for CLOUDRUN_BACKUP stage.
+//
+// Example:
+//
+//	bucket: my-dr-bucket
+//	prefix: cloudrun-backups
+type BackupStageConfig struct {
+	// Bucket is the Cloud Storage bucket the backup is written to. Enable
+	// object versioning or a retention policy on it to keep backups safe
+	// from deletion.
+	Bucket string `json:"bucket"`
+
+	// Prefix is the object name prefix, without a trailing slash. Backups
+	// are written to <prefix>/<project>/<region>/<service>/<time>.json.
+	// Default: "cloudrun-backups"
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// RestoreStageConfig defines configuration for CLOUDRUN_RESTORE stage.
+//
+// Example:
+//
+//	bucket: my-dr-bucket
+//	object: cloudrun-backups/my-project/us-central1/my-service/20260102T150405Z.json
+type RestoreStageConfig struct {
+	// Bucket is the Cloud Storage bucket the backups are read from.
+	Bucket string `json:"bucket"`
+
+	// Prefix is the object name prefix of the backups.
+	// Default: "cloudrun-backups"
+	Prefix string `json:"prefix,omitempty"`
+
+	// Object is the backup to restore. If empty, the latest backup of the
+	// service in the deploy target's project and region is restored.
+	Object string `json:"object,omitempty"`
+
+	// SkipIAMPolicy leaves the live IAM policy of the service as is.
+	SkipIAMPolicy bool `json:"skipIAMPolicy,omitempty"`
+
+	// SkipDomainMappings doesn't recreate the domain mappings.
+	SkipDomainMappings bool `json:"skipDomainMappings,omitempty"`
+}
+
+// DefaultBackupStageConfig returns default backup stage configuration.
+func DefaultBackupStageConfig() *BackupStageConfig {
+	return &BackupStageConfig{
+		Prefix: "cloudrun-backups",
+	}
+}
+
+// DefaultRestoreStageConfig returns default restore stage configuration.
+func DefaultRestoreStageConfig() *RestoreStageConfig {
+	return &RestoreStageConfig{
+		Prefix: "cloudrun-backups",
+	}
+}
+
+// DefaultDomainVerifyStageConfig returns default domain verify stage configuration.
+func DefaultDomainVerifyStageConfig() *DomainVerifyStageConfig {
+	return &DomainVerifyStageConfig{
+		Path:                   "/",
+		MinCertificateValidity: config.Duration(7 * 24 * time.Hour),
+		WaitTimeout:            config.Duration(15 * time.Minute),
+		Interval:               config.Duration(30 * time.Second),
+		RequestTimeout:         config.Duration(10 * time.Second),
+	}
+}
+
+// DefaultMirrorCaptureConfig returns default mirror capture configuration.
+func DefaultMirrorCaptureConfig() *MirrorCaptureConfig {
+	return &MirrorCaptureConfig{
+		SampleSize: 100,
 	}
 }
 
@@ -117,6 +986,16 @@ type StageResult struct {
 	// Message provides additional information about the result.
 	Message string
 
+	// Revision is the revision the stage deployed or routed traffic to.
+	Revision string
+
+	// Traffic maps revision names to their traffic percent after the stage.
+	// The latest revision is keyed as "LATEST" when traffic follows it.
+	Traffic map[string]int32
+
+	// DeletedRevisions is the list of revisions deleted by the stage.
+	DeletedRevisions []string
+
 	// Metadata contains stage-specific output data.
 	Metadata map[string]string
 }
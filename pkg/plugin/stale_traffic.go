@@ -0,0 +1,61 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+)
+
+// reconcileLiveTraffic returns the live traffic of the service without the
+// targets pointing at deleted or failed revisions, which the Admin API would
+// reject, with their traffic moved to the remaining targets. The live traffic
+// is returned as is if the revisions can't be listed.
+func reconcileLiveTraffic(
+	ctx context.Context,
+	client cloudrun.Client,
+	project, region, serviceName string,
+	traffic []*runpb.TrafficTarget,
+	lp sdk.StageLogPersister,
+) []*runpb.TrafficTarget {
+	named := false
+	for _, t := range traffic {
+		named = named || t.Revision != ""
+	}
+	if !named {
+		return traffic
+	}
+
+	revisions, err := client.ListRevisions(ctx, project, region, serviceName)
+	if err != nil {
+		lp.Infof("Warning: Failed to list revisions to check the live traffic: %v", err)
+		return traffic
+	}
+	reconciled, stale := cloudrun.ReconcileTraffic(traffic, revisions)
+	logStaleTraffic(stale, lp)
+	return reconciled
+}
+
+// logStaleTraffic warns about the traffic targets pointing at deleted or
+// failed revisions.
+func logStaleTraffic(stale []cloudrun.StaleTarget, lp sdk.StageLogPersister) {
+	for _, t := range stale {
+		lp.Infof("Warning: Live traffic points at revision %s; its traffic is reallocated to the remaining targets", t)
+	}
+}
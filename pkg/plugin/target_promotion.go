@@ -0,0 +1,213 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// stageTargetConfig is the part of every stage config selecting the deploy
+// target the stage runs against.
+type stageTargetConfig struct {
+	// DeployTarget is the name of the application's deploy target the stage
+	// runs against, e.g. "production". Default: the first deploy target
+	DeployTarget string `json:"deployTarget,omitempty"`
+}
+
+// stageTarget is the deploy target a stage runs against, and the first
+// deploy target of the application.
+type stageTarget struct {
+	name  string
+	first string
+}
+
+type stageTargetKey struct{}
+
+// withStageTarget returns a context recording the deploy target the stage
+// runs against.
+func withStageTarget(ctx context.Context, name, first string) context.Context {
+	return context.WithValue(ctx, stageTargetKey{}, stageTarget{name: name, first: first})
+}
+
+// selectStageTarget returns the deploy target the stage config selects, as
+// the only deploy target, and the context recording it. Without a selection,
+// the deploy targets are returned as is and the stage runs against the first.
+func selectStageTarget(
+	ctx context.Context,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	stageConfig []byte,
+) (context.Context, []*sdk.DeployTarget[config.DeployTargetConfig], error) {
+	if len(deployTargets) == 0 {
+		return ctx, deployTargets, nil
+	}
+
+	var stageCfg stageTargetConfig
+	if err := parseStageConfig(stageConfig, &stageCfg); err != nil {
+		return ctx, nil, fmt.Errorf("failed to parse stage deploy target: %w", err)
+	}
+	first := deployTargets[0].Name
+	if stageCfg.DeployTarget == "" {
+		return withStageTarget(ctx, first, first), deployTargets, nil
+	}
+
+	for _, dt := range deployTargets {
+		if dt.Name == stageCfg.DeployTarget {
+			return withStageTarget(ctx, dt.Name, first), []*sdk.DeployTarget[config.DeployTargetConfig]{dt}, nil
+		}
+	}
+	names := make([]string, 0, len(deployTargets))
+	for _, dt := range deployTargets {
+		names = append(names, dt.Name)
+	}
+	return ctx, nil, fmt.Errorf("deploy target %q is not a deploy target of the application (deploy targets: %v)", stageCfg.DeployTarget, names)
+}
+
+// targetNamespace returns the metadata namespace of the deploy target the
+// stage runs against, so each deploy target keeps its own stable revision
+// and canary step. The first deploy target uses the namespace itself, e.g.
+// "sync", and others a namespace per target, e.g. "sync.production".
+func targetNamespace(ctx context.Context, namespace string) string {
+	target, _ := ctx.Value(stageTargetKey{}).(stageTarget)
+	return namespaceOfTarget(target, target.name, namespace)
+}
+
+// namespaceOfTarget returns the metadata namespace of the named deploy target.
+func namespaceOfTarget(target stageTarget, name, namespace string) string {
+	if name == "" || name == target.first {
+		return namespace
+	}
+	return namespace + "." + name
+}
+
+// recordSyncedImages stores the container images CLOUDRUN_SYNC deployed to
+// the deploy target, for the syncs promoting them to other deploy targets,
+// and the deploy target for CLOUDRUN_ROLLBACK. Failing to store them does not
+// fail the stage.
+func recordSyncedImages(
+	ctx context.Context,
+	client metadataClient,
+	svc *runpb.Service,
+	lp sdk.StageLogPersister,
+) {
+	store := newMetadataStore(client, targetNamespace(ctx, metadataNamespaceSync))
+	if err := putMetadataJSON(ctx, store, metadataKeyImages, cloudrun.ContainerImages(svc)); err != nil {
+		lp.Infof("Warning: Failed to record deployed images: %v", err)
+	}
+
+	target, _ := ctx.Value(stageTargetKey{}).(stageTarget)
+	if target.name == "" || target.name == target.first {
+		return
+	}
+	err := newMetadataStore(client, metadataNamespaceDeployment).Update(ctx, metadataKeySyncedTargets, func(current string, ok bool) (string, error) {
+		targets, err := decodeSyncedTargets(current, ok)
+		if err != nil {
+			return "", err
+		}
+		if slices.Contains(targets, target.name) {
+			return current, nil
+		}
+		data, err := json.Marshal(append(targets, target.name))
+		return string(data), err
+	})
+	if err != nil {
+		lp.Infof("Warning: Failed to record the synced deploy target: %v", err)
+	}
+}
+
+// promotedImages returns the container images CLOUDRUN_SYNC deployed to the
+// source deploy target earlier in the deployment.
+func promotedImages(ctx context.Context, client metadataClient, source string) ([]string, error) {
+	target, _ := ctx.Value(stageTargetKey{}).(stageTarget)
+	if source == target.name {
+		return nil, fmt.Errorf("cannot promote from deploy target %q to itself", source)
+	}
+	store := newMetadataStore(client, namespaceOfTarget(target, source, metadataNamespaceSync))
+	images, ok, err := getMetadataJSON[[]string](ctx, store, metadataKeyImages)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || len(images) == 0 {
+		return nil, fmt.Errorf("no images were deployed to deploy target %q in this deployment: run CLOUDRUN_SYNC with deployTarget %q earlier in the pipeline", source, source)
+	}
+	return images, nil
+}
+
+// syncedTargets returns the deploy targets other than the first that
+// CLOUDRUN_SYNC deployed to in this deployment, in the order of the syncs.
+func syncedTargets(ctx context.Context, client metadataClient) ([]string, error) {
+	value, ok, err := newMetadataStore(client, metadataNamespaceDeployment).GetString(ctx, metadataKeySyncedTargets)
+	if err != nil {
+		return nil, err
+	}
+	return decodeSyncedTargets(value, ok)
+}
+
+func decodeSyncedTargets(value string, ok bool) ([]string, error) {
+	if !ok || value == "" {
+		return nil, nil
+	}
+	var targets []string
+	if err := json.Unmarshal([]byte(value), &targets); err != nil {
+		return nil, fmt.Errorf("invalid synced deploy targets: %w", err)
+	}
+	return targets, nil
+}
+
+// ExecuteRollbackStages rolls back the deploy targets of the deployment. A
+// rollback selecting a deploy target rolls back that one. Otherwise the deploy
+// targets CLOUDRUN_SYNC promoted to are rolled back first, the latest first,
+// then the first deploy target.
+func (e *StageExecutor) ExecuteRollbackStages(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+	lp sdk.StageLogPersister,
+) (*StageResult, error) {
+	if hasStageConfigField(input.Request.StageConfig, "deployTarget") || len(deployTargets) < 2 {
+		return e.ExecuteRollbackStage(ctx, cfg, deployTargets, input, lp)
+	}
+
+	targets, err := syncedTargets(ctx, input.Client)
+	if err != nil {
+		lp.Infof("Warning: Rolling back only deploy target %s: %v", deployTargets[0].Name, err)
+	}
+	for _, name := range slices.Backward(targets) {
+		i := slices.IndexFunc(deployTargets, func(dt *sdk.DeployTarget[config.DeployTargetConfig]) bool { return dt.Name == name })
+		if i < 0 {
+			lp.Infof("Warning: Not rolling back deploy target %s, which is no longer a deploy target of the application", name)
+			continue
+		}
+		lp.Infof("Rolling back deploy target: %s", name)
+		targetCtx := withStageTarget(ctx, name, deployTargets[0].Name)
+		if result, err := e.ExecuteRollbackStage(targetCtx, cfg, deployTargets[i:i+1], input, lp); err != nil {
+			return result, fmt.Errorf("failed to roll back deploy target %s: %w", name, err)
+		}
+	}
+
+	if len(targets) > 0 {
+		lp.Infof("Rolling back deploy target: %s", deployTargets[0].Name)
+	}
+	return e.ExecuteRollbackStage(ctx, cfg, deployTargets[:1], input, lp)
+}
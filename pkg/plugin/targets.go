@@ -0,0 +1,256 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// targetIdleTimeout is how long the clients of a deployment are kept after
+// its last stage ran, for deployments that don't complete (e.g. cancelled
+// ones, or failed ones without a rollback).
+const targetIdleTimeout = time.Hour
+
+// targetClients are the Cloud Run client and managers of a deploy target,
+// shared by the stages of a deployment, with the location of the
+// application's service in the deploy target.
+type targetClients struct {
+	client    cloudrun.Client
+	services  *cloudrun.ServiceManager
+	traffic   *cloudrun.TrafficManager
+	revisions *cloudrun.RevisionManager
+
+	// project and region are those of the deploy target, see targetLocation.
+	project string
+	region  string
+
+	// service is the name of the application's service, see
+	// applicationServiceName.
+	service string
+}
+
+// newTargetClients builds the managers of the client.
+func newTargetClients(client cloudrun.Client) *targetClients {
+	return &targetClients{
+		client:    client,
+		services:  cloudrun.NewServiceManager(client),
+		traffic:   cloudrun.NewTrafficManager(client),
+		revisions: cloudrun.NewRevisionManager(client),
+	}
+}
+
+// targetRegistry creates the Cloud Run client of each deploy target once per
+// deployment, so the stages of a deployment share its connections, rate
+// limiter, and retries instead of each building their own.
+type targetRegistry struct {
+	mu          sync.Mutex
+	deployments map[string]*deploymentTargets
+
+	// newClient creates the client of a deploy target.
+	newClient func(ctx context.Context, cfg *config.PluginConfig, dt *sdk.DeployTarget[config.DeployTargetConfig]) (cloudrun.Client, error)
+}
+
+// deploymentTargets are the clients of the deploy targets of a deployment.
+type deploymentTargets struct {
+	// running is the number of stages of the deployment running.
+	running  int
+	lastUsed time.Time
+	clients  map[string]*targetClient
+
+	// replaced are the clients replaced after a config change. Stages still
+	// running may use them, so they are closed with the deployment.
+	replaced []cloudrun.Client
+}
+
+// targetClient is the client of a deploy target, with the fingerprint of the
+// config it was created with.
+type targetClient struct {
+	fingerprint string
+	client      cloudrun.Client
+}
+
+func newTargetRegistry() *targetRegistry {
+	return &targetRegistry{
+		deployments: make(map[string]*deploymentTargets),
+		newClient:   newTargetClient,
+	}
+}
+
+// begin marks a stage of the deployment as running, and closes the clients
+// of deployments idle for longer than targetIdleTimeout.
+func (r *targetRegistry) begin(deploymentID string, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, d := range r.deployments {
+		if id != deploymentID && d.running == 0 && now.Sub(d.lastUsed) > targetIdleTimeout {
+			d.close()
+			delete(r.deployments, id)
+		}
+	}
+
+	d := r.deployment(deploymentID)
+	d.running++
+	d.lastUsed = now
+}
+
+// deployment returns the clients of the deployment, adding them if missing.
+// It must be called with r.mu held.
+func (r *targetRegistry) deployment(deploymentID string) *deploymentTargets {
+	d, ok := r.deployments[deploymentID]
+	if !ok {
+		d = &deploymentTargets{clients: make(map[string]*targetClient), lastUsed: time.Now()}
+		r.deployments[deploymentID] = d
+	}
+	return d
+}
+
+// end marks a stage of the deployment as finished. The clients of a
+// completed deployment are closed once none of its stages runs.
+func (r *targetRegistry) end(deploymentID string, completed bool, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d, ok := r.deployments[deploymentID]
+	if !ok {
+		return
+	}
+	d.running--
+	d.lastUsed = now
+	if completed && d.running <= 0 {
+		d.close()
+		delete(r.deployments, deploymentID)
+	}
+}
+
+// get returns the clients of the deploy target for the deployment, creating
+// the client on first use, or again if the config of the deploy target
+// changed (e.g. reloaded credentials). In a dry run, the client logs its write
+// calls instead of making them.
+func (r *targetRegistry) get(
+	ctx context.Context,
+	cfg *config.PluginConfig,
+	dt *sdk.DeployTarget[config.DeployTargetConfig],
+	deploymentID string,
+) (*targetClients, error) {
+	fingerprint := targetFingerprint(cfg, dt)
+
+	// Stages always run between begin and end; the deployment is added
+	// anyway so the client is kept until it is idle
+	r.mu.Lock()
+	tc, ok := r.deployment(deploymentID).clients[dt.Name]
+	r.mu.Unlock()
+
+	// Create the client without holding the lock, so stages of other
+	// deployments don't wait for it
+	if !ok || tc.fingerprint != fingerprint {
+		client, err := r.newClient(ctx, cfg, dt)
+		if err != nil {
+			return nil, err
+		}
+		tc = r.put(deploymentID, dt.Name, &targetClient{fingerprint: fingerprint, client: client})
+	}
+
+	client := tc.client
+	if report, ok := cloudrun.DryRun(ctx); ok {
+		client = cloudrun.NewDryRunClient(client, report)
+	}
+	clients := newTargetClients(client)
+	clients.project, clients.region = targetLocation(cfg, dt)
+	return clients, nil
+}
+
+// put stores the client of the deploy target and returns the client to use.
+// If a stage stored a client for the same config meanwhile, that one is
+// returned and tc is closed.
+func (r *targetRegistry) put(deploymentID, target string, tc *targetClient) *targetClient {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d := r.deployment(deploymentID)
+	current, ok := d.clients[target]
+	if ok && current.fingerprint == tc.fingerprint {
+		tc.client.Close()
+		return current
+	}
+	if ok {
+		d.replaced = append(d.replaced, current.client)
+	}
+	d.clients[target] = tc
+	return tc
+}
+
+// close closes the clients of the deployment.
+func (d *deploymentTargets) close() {
+	for _, tc := range d.clients {
+		tc.client.Close()
+	}
+	for _, client := range d.replaced {
+		client.Close()
+	}
+}
+
+// targetLocation returns the project and region of the deploy target,
+// falling back to those of the plugin config.
+func targetLocation(cfg *config.PluginConfig, dt *sdk.DeployTarget[config.DeployTargetConfig]) (project, region string) {
+	project, region = dt.Config.ProjectID, dt.Config.Region
+	if cfg != nil {
+		if project == "" {
+			project = cfg.ProjectID
+		}
+		if region == "" {
+			region = cfg.Region
+		}
+	}
+	return project, region
+}
+
+// applicationServiceName returns the name of the application's service: the
+// configured service name, or the application ID.
+func applicationServiceName(input *sdk.ExecuteStageInput[config.ApplicationConfig]) string {
+	if name := input.Request.TargetDeploymentSource.ApplicationConfig.Spec.Input.ServiceName; name != "" {
+		return name
+	}
+	return input.Request.Deployment.ApplicationID
+}
+
+// targetFingerprint identifies the config a deploy target's client is created
+// with. The plugin config region is included since it resolves the
+// "regional" endpoint.
+func targetFingerprint(cfg *config.PluginConfig, dt *sdk.DeployTarget[config.DeployTargetConfig]) string {
+	v := struct {
+		APITimeouts config.APITimeoutConfig   `json:"apiTimeouts"`
+		RateLimit   config.RateLimitConfig    `json:"rateLimit"`
+		APIEndpoint string                    `json:"apiEndpoint"`
+		Region      string                    `json:"region"`
+		Target      config.DeployTargetConfig `json:"target"`
+	}{Target: dt.Config}
+	if cfg != nil {
+		v.APITimeouts = cfg.APITimeouts
+		v.RateLimit = cfg.RateLimit
+		v.APIEndpoint = cfg.APIEndpoint
+		v.Region = cfg.Region
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,103 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/cloudrun"
+)
+
+// applicationObjectKeyTrafficHistory is the application shared object
+// holding the revisions that served traffic.
+const applicationObjectKeyTrafficHistory = "trafficHistory"
+
+// maxTrafficHistory is the number of revisions kept in the traffic history.
+const maxTrafficHistory = 50
+
+// trafficHistory lists the revisions of the application that served traffic,
+// most recent first. CLOUDRUN_ROLLBACK only falls back to one of them, so it
+// never selects a revision that never served.
+type trafficHistory struct {
+	Revisions []string `json:"revisions"`
+}
+
+// getTrafficHistory returns the revisions that served traffic, most recent
+// first, or nil if none were recorded.
+func getTrafficHistory(ctx context.Context, client applicationObjectClient) ([]string, error) {
+	data, ok, err := client.GetApplicationSharedObject(ctx, applicationObjectKeyTrafficHistory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the traffic history: %w", err)
+	}
+	if !ok {
+		return nil, nil
+	}
+	var history trafficHistory
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("invalid traffic history: %w", err)
+	}
+	return history.Revisions, nil
+}
+
+// recordTrafficHistory adds the revisions to the front of the traffic
+// history, keeping the most recent maxTrafficHistory revisions. Failing to
+// record them does not fail the stage.
+func recordTrafficHistory(ctx context.Context, client applicationObjectClient, revisions []string, lp sdk.StageLogPersister) {
+	if len(revisions) == 0 {
+		return
+	}
+	previous, err := getTrafficHistory(ctx, client)
+	if err != nil {
+		lp.Infof("Warning: %v", err)
+		return
+	}
+
+	history := trafficHistory{Revisions: make([]string, 0, len(revisions)+len(previous))}
+	for _, rev := range append(slices.Clone(revisions), previous...) {
+		if rev != "" && !slices.Contains(history.Revisions, rev) {
+			history.Revisions = append(history.Revisions, rev)
+		}
+	}
+	history.Revisions = history.Revisions[:min(len(history.Revisions), maxTrafficHistory)]
+	if slices.Equal(history.Revisions, previous) {
+		return
+	}
+
+	data, err := json.Marshal(history)
+	if err == nil {
+		err = client.PutApplicationSharedObject(ctx, applicationObjectKeyTrafficHistory, data)
+	}
+	if err != nil {
+		lp.Infof("Warning: Failed to record the traffic history: %v", err)
+	}
+}
+
+// servingRevisions returns the revisions serving traffic according to the
+// traffic statuses of the service.
+func servingRevisions(svc *runpb.Service) []string {
+	var revisions []string
+	for _, t := range svc.GetTrafficStatuses() {
+		if t.Percent > 0 && t.Revision != "" {
+			revisions = append(revisions, cloudrun.ShortRevisionName(t.Revision))
+		}
+	}
+	return revisions
+}
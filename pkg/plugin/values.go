@@ -0,0 +1,71 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+	"sigs.k8s.io/yaml"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// targetValues returns the values of the deploy target for the application
+// in appDir: the values of its config, overridden by its values file. A
+// values file missing from the application directory is skipped.
+func targetValues(dt *sdk.DeployTarget[config.DeployTargetConfig], appDir string) (map[string]any, error) {
+	values := mergeValues(nil, dt.Config.Values)
+	path := dt.Config.ValuesFile
+	if path == "" || appDir == "" {
+		return values, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(appDir, path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read values file %s of deploy target %s: %w", path, dt.Name, err)
+	}
+	var fileValues map[string]any
+	if err := yaml.Unmarshal(data, &fileValues); err != nil {
+		return nil, fmt.Errorf("invalid values file %s of deploy target %s: %w", path, dt.Name, err)
+	}
+	return mergeValues(values, fileValues), nil
+}
+
+// mergeValues returns dst with the values of src set over it. Maps present
+// in both are merged recursively; other values of src replace those of dst.
+// dst is not modified.
+func mergeValues(dst, src map[string]any) map[string]any {
+	merged := make(map[string]any, len(dst)+len(src))
+	maps.Copy(merged, dst)
+	for k, v := range src {
+		srcMap, ok := v.(map[string]any)
+		dstMap, ok2 := merged[k].(map[string]any)
+		if ok && ok2 {
+			merged[k] = mergeValues(dstMap, srcMap)
+			continue
+		}
+		merged[k] = v
+	}
+	return merged
+}
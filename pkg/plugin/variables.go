@@ -0,0 +1,271 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	sdk "github.com/pipe-cd/piped-plugin-sdk-go"
+	"sigs.k8s.io/yaml"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/render"
+)
+
+// deploymentVariables are the built-in variables available for interpolation
+// into service manifests, the application input (service name, image), and
+// stage configs, using Go template syntax.
+//
+// Example:
+//
+//	input:
+//	  serviceName: "my-svc-pr-{{ .PRNumber }}"
+type deploymentVariables struct {
+	// DeploymentID is the ID of the deployment. Empty in plan preview.
+	DeploymentID string
+
+	// ApplicationID is the ID of the application.
+	ApplicationID string
+
+	// ApplicationName is the name of the application. In plan preview, it
+	// is read from the application config file.
+	ApplicationName string
+
+	// Target is the name of the deploy target.
+	Target string
+
+	// CommitHash is the hash of the deployed commit, as reported by piped,
+	// or of the checked out commit otherwise.
+	CommitHash string
+
+	// CommitShortHash is the first 7 characters of CommitHash.
+	CommitShortHash string
+
+	// Branch is the branch of the deployed commit, if checked out on one.
+	Branch string
+
+	// PRNumber is the pull request number referenced by the commit message,
+	// e.g. "Fix login (#123)" or "Merge pull request #123 from ...".
+	PRNumber string
+
+	// CommitAuthor is the author of the deployed commit.
+	CommitAuthor string
+
+	// Params are the values of the application params for the deploy
+	// target, e.g. {{ .Params.maxInstances }}.
+	Params map[string]any
+
+	// Values are the values of the deploy target, from its config and
+	// values file, e.g. {{ .Values.minScale }}.
+	Values map[string]any
+}
+
+// prNumberPattern matches pull request references in merge and squash commit messages.
+var prNumberPattern = regexp.MustCompile(`(?:\(#|[Pp]ull [Rr]equest #)(\d+)`)
+
+// newDeploymentVariables collects the variables of a deployment of the
+// source. Values that can't be determined are left empty.
+func newDeploymentVariables(
+	ctx context.Context,
+	deploymentID, applicationID, applicationName, target string,
+	src sdk.DeploymentSource[config.ApplicationConfig],
+) deploymentVariables {
+	vars := deploymentVariables{
+		DeploymentID:    deploymentID,
+		ApplicationID:   applicationID,
+		ApplicationName: applicationName,
+		Target:          target,
+	}
+	vars.addGitVariables(ctx, src.ApplicationDirectory)
+	if src.CommitHash != "" {
+		vars.CommitHash = src.CommitHash
+		vars.CommitShortHash = shortHash(src.CommitHash)
+	}
+	if vars.ApplicationName == "" {
+		vars.ApplicationName = applicationConfigName(src)
+	}
+	return vars
+}
+
+// applicationConfigName returns the application name set in the application
+// config file of the source, or "" if it can't be read.
+func applicationConfigName(src sdk.DeploymentSource[config.ApplicationConfig]) string {
+	if src.ApplicationDirectory == "" || src.ApplicationConfigFilename == "" {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join(src.ApplicationDirectory, src.ApplicationConfigFilename))
+	if err != nil {
+		return ""
+	}
+	var file struct {
+		Spec struct {
+			Name string `json:"name"`
+		} `json:"spec"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return ""
+	}
+	return file.Spec.Name
+}
+
+// shortHash returns the first 7 characters of a commit hash.
+func shortHash(hash string) string {
+	if len(hash) < 7 {
+		return ""
+	}
+	return hash[:7]
+}
+
+// stageVariables returns the variables of the deployment running the stage.
+func stageVariables(
+	ctx context.Context,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+) deploymentVariables {
+	var target string
+	if len(deployTargets) > 0 {
+		target = deployTargets[0].Name
+	}
+	deployment := input.Request.Deployment
+	src := input.Request.TargetDeploymentSource
+	appDir := src.ApplicationDirectory
+	vars := newDeploymentVariables(ctx, deployment.ID, deployment.ApplicationID, deployment.ApplicationName, target, src)
+	// Invalid params and values fail the stage in interpolateStageInput
+	vars, _ = vars.withParams(input.Request.TargetDeploymentSource.ApplicationConfig.Spec.Params)
+	if len(deployTargets) > 0 {
+		vars, _ = vars.withValues(deployTargets[0], appDir)
+	}
+	return vars
+}
+
+// withParams returns the variables with the values of the params for their
+// deploy target. Invalid params are reported in the error and left out.
+func (v deploymentVariables) withParams(params []config.ParamConfig) (deploymentVariables, error) {
+	values, err := paramValues(params, v.Target)
+	v.Params = values
+	if err != nil {
+		return v, fmt.Errorf("invalid params: %w", err)
+	}
+	return v, nil
+}
+
+// withValues returns the variables with the values of the deploy target for
+// the application in appDir.
+func (v deploymentVariables) withValues(dt *sdk.DeployTarget[config.DeployTargetConfig], appDir string) (deploymentVariables, error) {
+	values, err := targetValues(dt, appDir)
+	if err != nil {
+		return v, err
+	}
+	v.Values = values
+	return v, nil
+}
+
+// interpolateStageInput renders the variables into the stage config and the
+// application input of the stage, so every stage handler sees the rendered values.
+func interpolateStageInput(
+	ctx context.Context,
+	deployTargets []*sdk.DeployTarget[config.DeployTargetConfig],
+	input *sdk.ExecuteStageInput[config.ApplicationConfig],
+) error {
+	spec := input.Request.TargetDeploymentSource.ApplicationConfig.Spec
+	var target string
+	if len(deployTargets) > 0 {
+		target = deployTargets[0].Name
+	}
+	if _, err := paramValues(spec.Params, target); err != nil {
+		return fmt.Errorf("invalid params: %w", err)
+	}
+	if len(deployTargets) > 0 {
+		if _, err := targetValues(deployTargets[0], input.Request.TargetDeploymentSource.ApplicationDirectory); err != nil {
+			return err
+		}
+	}
+	if !hasVariables(input.Request.StageConfig) && !inputHasVariables(spec.Input) {
+		return nil
+	}
+
+	vars := stageVariables(ctx, deployTargets, input)
+	stageConfig, err := vars.interpolate("stage config", input.Request.StageConfig)
+	if err != nil {
+		return err
+	}
+	input.Request.StageConfig = stageConfig
+	return vars.interpolateInput(&spec.Input)
+}
+
+// addGitVariables fills the commit variables from the repository containing dir.
+func (v *deploymentVariables) addGitVariables(ctx context.Context, dir string) {
+	if dir == "" {
+		return
+	}
+
+	out, err := exec.CommandContext(ctx, "git", "-C", dir, "log", "-1", "--format=%H%x00%an%x00%B").Output()
+	if err == nil {
+		parts := strings.SplitN(string(out), "\x00", 3)
+		if len(parts) == 3 {
+			v.CommitHash = parts[0]
+			v.CommitAuthor = parts[1]
+			if m := prNumberPattern.FindStringSubmatch(parts[2]); m != nil {
+				v.PRNumber = m[1]
+			}
+		}
+	}
+	v.CommitShortHash = shortHash(v.CommitHash)
+
+	// A detached HEAD is reported as "HEAD"
+	out, err = exec.CommandContext(ctx, "git", "-C", dir, "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if branch := strings.TrimSpace(string(out)); err == nil && branch != "HEAD" {
+		v.Branch = branch
+	}
+}
+
+// hasVariables reports whether the data contains template actions.
+func hasVariables(data []byte) bool {
+	return bytes.Contains(data, []byte("{{"))
+}
+
+// interpolate renders the variables into data. Data without template actions
+// is returned unchanged.
+func (v deploymentVariables) interpolate(name string, data []byte) ([]byte, error) {
+	return render.Template(name, data, v)
+}
+
+// interpolateInput renders the variables into the application input.
+func (v deploymentVariables) interpolateInput(input *config.InputConfig) error {
+	for name, field := range map[string]*string{
+		"input.serviceName": &input.ServiceName,
+		"input.jobName":     &input.JobName,
+		"input.image":       &input.Image,
+	} {
+		out, err := v.interpolate(name, []byte(*field))
+		if err != nil {
+			return err
+		}
+		*field = string(out)
+	}
+	return nil
+}
+
+// inputHasVariables reports whether the application input contains template actions.
+func inputHasVariables(input config.InputConfig) bool {
+	return hasVariables([]byte(input.ServiceName)) || hasVariables([]byte(input.JobName)) || hasVariables([]byte(input.Image))
+}
@@ -0,0 +1,98 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// PatchOptions are the options of the patch renderer.
+//
+// Example:
+//
+//	renderer:
+//	  name: patch
+//	  with:
+//	    patches: [patches/production.json]
+type PatchOptions struct {
+	// Patches are the paths, relative to the application directory, of the
+	// JSON merge patches applied in order. They can reference deployment
+	// variables like the manifest.
+	Patches []string `json:"patches"`
+}
+
+func renderPatch(ctx context.Context, in Input) ([]byte, error) {
+	var opts PatchOptions
+	if err := DecodeOptions(in, &opts); err != nil {
+		return nil, err
+	}
+	if len(opts.Patches) == 0 {
+		return nil, fmt.Errorf("patch renderer requires at least one patch")
+	}
+
+	data, err := renderGoTemplate(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	var manifest any
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s (expected JSON): %w", in.ManifestPath, err)
+	}
+
+	for _, path := range opts.Patches {
+		if !filepath.IsLocal(path) {
+			return nil, fmt.Errorf("patch %q must be a path inside the application directory", path)
+		}
+		data, err := os.ReadFile(filepath.Join(in.AppDir, path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read patch: %w", err)
+		}
+		if data, err = Template(path, data, in.Variables); err != nil {
+			return nil, err
+		}
+		var patch any
+		if err := json.Unmarshal(data, &patch); err != nil {
+			return nil, fmt.Errorf("failed to parse patch %s (expected JSON): %w", path, err)
+		}
+		manifest = MergePatch(manifest, patch)
+	}
+	return json.Marshal(manifest)
+}
+
+// MergePatch applies a JSON merge patch (RFC 7386) to a decoded JSON
+// document: objects are merged recursively, null removes a field, and other
+// values (including arrays) replace the target.
+func MergePatch(target, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = make(map[string]any)
+	}
+	for k, v := range patchObj {
+		if v == nil {
+			delete(targetObj, k)
+			continue
+		}
+		targetObj[k] = MergePatch(targetObj[k], v)
+	}
+	return targetObj
+}
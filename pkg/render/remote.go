@@ -0,0 +1,89 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pipe-cd/pipecd-plugin-cloudrun/pkg/config"
+)
+
+// maxRemoteManifestSize bounds the size of fetched manifests.
+const maxRemoteManifestSize = 10 << 20
+
+// RemoteOptions are the options of the remote renderer.
+//
+// Example:
+//
+//	renderer:
+//	  name: remote
+//	  with:
+//	    url: https://config.example.com/services/api.json
+type RemoteOptions struct {
+	// URL is the HTTP(S) URL of the manifest. It can reference deployment
+	// variables, e.g. "https://config.example.com/{{ .Target }}/api.json".
+	URL string `json:"url"`
+
+	// Timeout bounds the request.
+	// Default: 30s
+	Timeout config.Duration `json:"timeout,omitempty"`
+}
+
+// RemoteHTTPClient is the client the remote renderer fetches manifests with.
+var RemoteHTTPClient = http.DefaultClient
+
+func renderRemote(ctx context.Context, in Input) ([]byte, error) {
+	opts := RemoteOptions{Timeout: config.Duration(30 * time.Second)}
+	if err := DecodeOptions(in, &opts); err != nil {
+		return nil, err
+	}
+	rendered, err := Template("url", []byte(opts.URL), in.Variables)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(string(rendered))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("remote renderer requires an http(s) url, got %q", opts.URL)
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout.Duration())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := RemoteHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch manifest: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("failed to fetch manifest from %s: %d %s", u.Redacted(), resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteManifestSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch manifest: %w", err)
+	}
+	if len(data) > maxRemoteManifestSize {
+		return nil, fmt.Errorf("manifest at %s is larger than %d bytes", u.Redacted(), maxRemoteManifestSize)
+	}
+	return Template(u.Path, data, in.Variables)
+}
@@ -0,0 +1,177 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package render renders application manifests into the manifests to deploy.
+//
+// Renderers are registered by name and selected in the application config,
+// so new templating mechanisms can be added without changing the stages that
+// deploy the manifests.
+package render
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"text/template"
+)
+
+// Names of the built-in renderers.
+const (
+	// Raw deploys the manifest as is.
+	Raw = "raw"
+
+	// GoTemplate renders the deployment variables into the manifest with Go
+	// template syntax. It is the default renderer.
+	GoTemplate = "go-template"
+
+	// Patch renders the manifest like GoTemplate, then applies JSON merge
+	// patches (RFC 7386) to it, like kustomize patches.
+	Patch = "patch"
+
+	// Remote fetches the manifest from a URL instead of the application
+	// directory, then renders it like GoTemplate.
+	Remote = "remote"
+)
+
+// Default is the renderer used when the application config selects none.
+const Default = GoTemplate
+
+// Input is what a renderer renders a manifest from.
+type Input struct {
+	// AppDir is the application directory.
+	AppDir string
+
+	// ManifestPath is the path of the manifest, relative to AppDir.
+	ManifestPath string
+
+	// Variables are the deployment variables templates can reference.
+	Variables any
+
+	// Options are the options of the renderer from the application config,
+	// as JSON. They are empty if none are set.
+	Options []byte
+}
+
+// Renderer renders a manifest.
+type Renderer interface {
+	// Render returns the manifest to deploy.
+	Render(ctx context.Context, in Input) ([]byte, error)
+}
+
+// RendererFunc adapts a function to a Renderer.
+type RendererFunc func(ctx context.Context, in Input) ([]byte, error)
+
+// Render calls f.
+func (f RendererFunc) Render(ctx context.Context, in Input) ([]byte, error) {
+	return f(ctx, in)
+}
+
+var (
+	mu        sync.RWMutex
+	renderers = map[string]Renderer{
+		Raw:        RendererFunc(renderRaw),
+		GoTemplate: RendererFunc(renderGoTemplate),
+		Patch:      RendererFunc(renderPatch),
+		Remote:     RendererFunc(renderRemote),
+	}
+)
+
+// Register registers a renderer under a name, replacing any renderer
+// registered under the same name.
+func Register(name string, r Renderer) {
+	mu.Lock()
+	defer mu.Unlock()
+	renderers[name] = r
+}
+
+// Get returns the renderer registered under a name, or the default renderer
+// if name is empty.
+func Get(name string) (Renderer, error) {
+	if name == "" {
+		name = Default
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	r, ok := renderers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown renderer %q (registered: %v)", name, names())
+	}
+	return r, nil
+}
+
+// names returns the sorted names of the registered renderers.
+func names() []string {
+	out := make([]string, 0, len(renderers))
+	for name := range renderers {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ReadManifest reads the manifest of the input.
+func ReadManifest(in Input) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(in.AppDir, in.ManifestPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	return data, nil
+}
+
+// DecodeOptions decodes the options of the input into v. Empty options leave
+// v unchanged.
+func DecodeOptions(in Input, v any) error {
+	if len(in.Options) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(in.Options, v); err != nil {
+		return fmt.Errorf("invalid renderer options: %w", err)
+	}
+	return nil
+}
+
+func renderRaw(_ context.Context, in Input) ([]byte, error) {
+	return ReadManifest(in)
+}
+
+func renderGoTemplate(_ context.Context, in Input) ([]byte, error) {
+	data, err := ReadManifest(in)
+	if err != nil {
+		return nil, err
+	}
+	return Template(in.ManifestPath, data, in.Variables)
+}
+
+// Template renders the variables into data with Go template syntax. Missing
+// variables are errors. Data without template actions is returned as is.
+func Template(name string, data []byte, variables any) ([]byte, error) {
+	if !bytes.Contains(data, []byte("{{")) {
+		return data, nil
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse variables in %s: %w", name, err)
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, variables); err != nil {
+		return nil, fmt.Errorf("failed to render variables in %s: %w", name, err)
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2025 The PipeCD Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testVariables struct {
+	Target string
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRenderers(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"service.json": `{"name": "api-{{ .Target }}", "labels": {"team": "a", "tier": "web"}}`,
+		"prod.json":    `{"labels": {"tier": null, "env": "{{ .Target }}"}, "scaling": {"minInstanceCount": 2}}`,
+	})
+	vars := testVariables{Target: "prod"}
+
+	tests := []struct {
+		name     string
+		renderer string
+		options  string
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "default",
+			expected: `{"name": "api-prod", "labels": {"team": "a", "tier": "web"}}`,
+		},
+		{
+			name:     "raw",
+			renderer: Raw,
+			expected: `{"name": "api-{{ .Target }}", "labels": {"team": "a", "tier": "web"}}`,
+		},
+		{
+			name:     "patch",
+			renderer: Patch,
+			options:  `{"patches": ["prod.json"]}`,
+			expected: `{"labels":{"env":"prod","team":"a"},"name":"api-prod","scaling":{"minInstanceCount":2}}`,
+		},
+		{name: "patch without patches", renderer: Patch, wantErr: true},
+		{name: "patch outside app dir", renderer: Patch, options: `{"patches": ["../prod.json"]}`, wantErr: true},
+		{name: "invalid options", renderer: Patch, options: `{"patches": "prod.json"}`, wantErr: true},
+		{name: "unknown", renderer: "jsonnet", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Get(tt.renderer)
+			if err == nil {
+				var got []byte
+				got, err = r.Render(context.Background(), Input{
+					AppDir:       dir,
+					ManifestPath: "service.json",
+					Variables:    vars,
+					Options:      []byte(tt.options),
+				})
+				if err == nil && string(got) != tt.expected {
+					t.Errorf("expected %s, got %s", tt.expected, got)
+				}
+			}
+			if tt.wantErr != (err != nil) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRemoteRenderer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/prod/service.json" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"name": "api-{{ .Target }}"}`)
+	}))
+	defer server.Close()
+
+	r, err := Get(Remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	render := func(url string) ([]byte, error) {
+		return r.Render(context.Background(), Input{
+			Variables: testVariables{Target: "prod"},
+			Options:   []byte(fmt.Sprintf(`{"url": %q}`, url)),
+		})
+	}
+
+	got, err := render(server.URL + "/{{ .Target }}/service.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != `{"name": "api-prod"}` {
+		t.Errorf("unexpected manifest %s", got)
+	}
+
+	if _, err := render(server.URL + "/staging/service.json"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	if _, err := render("file:///etc/passwd"); err == nil {
+		t.Error("expected error for a non-http url")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("test-upper", RendererFunc(func(_ context.Context, in Input) ([]byte, error) {
+		data, err := ReadManifest(in)
+		return []byte(strings.ToUpper(string(data))), err
+	}))
+
+	dir := writeFiles(t, map[string]string{"service.json": `{"name": "api"}`})
+	r, err := Get("test-upper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.Render(context.Background(), Input{AppDir: dir, ManifestPath: "service.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != `{"NAME": "API"}` {
+		t.Errorf("unexpected manifest %s", got)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	target := map[string]any{"a": "b", "c": map[string]any{"d": "e", "f": "g"}, "list": []any{1, 2}}
+	patch := map[string]any{"a": "z", "c": map[string]any{"f": nil}, "list": []any{3}}
+
+	got := fmt.Sprint(MergePatch(target, patch))
+	expected := fmt.Sprint(map[string]any{"a": "z", "c": map[string]any{"d": "e"}, "list": []any{3}})
+	if got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}